@@ -0,0 +1,134 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// LocalStore keeps artifacts on disk as "<dir>/<task id>/<name>".
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore returns a store rooted at dir, "artifacts" when empty. The
+// directory is created on the first Put.
+func NewLocalStore(dir string) *LocalStore {
+	if dir == "" {
+		dir = "artifacts"
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return &LocalStore{dir: dir}
+}
+
+func (s *LocalStore) taskDir(taskID uuid.UUID) string {
+	return filepath.Join(s.dir, taskID.String())
+}
+
+// Put implements Store. The file is renamed into place so a reader never
+// sees a partial one.
+func (s *LocalStore) Put(ctx context.Context, taskID uuid.UUID, name string, r io.Reader) (Object, error) {
+	if err := ValidName(name); err != nil {
+		return Object{}, err
+	}
+	dir := s.taskDir(taskID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Object{}, fmt.Errorf("failed to prepare artifact directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".artifact-*")
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to save artifact: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to save artifact: %w", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Object{}, fmt.Errorf("failed to save artifact: %w", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to save artifact: %w", err)
+	}
+	return objectFor(path, stat), nil
+}
+
+// Get implements Store. The reader is an *os.File.
+func (s *LocalStore) Get(ctx context.Context, taskID uuid.UUID, name string) (io.ReadCloser, Object, error) {
+	if err := ValidName(name); err != nil {
+		return nil, Object{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	path := filepath.Join(s.taskDir(taskID), name)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, Object{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, Object{}, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	stat, err := f.Stat()
+	if err != nil || !stat.Mode().IsRegular() {
+		f.Close()
+		return nil, Object{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return f, objectFor(path, stat), nil
+}
+
+// List implements Store. A task without artifacts has an empty list.
+func (s *LocalStore) List(ctx context.Context, taskID uuid.UUID) ([]Object, error) {
+	entries, err := os.ReadDir(s.taskDir(taskID))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Object{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	objects := make([]Object, 0, len(entries))
+	for _, entry := range entries {
+		// Skip files still being written by Put
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, objectFor(filepath.Join(s.taskDir(taskID), entry.Name()), stat))
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+// Delete implements Store.
+func (s *LocalStore) Delete(ctx context.Context, taskID uuid.UUID) error {
+	if err := os.RemoveAll(s.taskDir(taskID)); err != nil {
+		return fmt.Errorf("failed to delete artifacts: %w", err)
+	}
+	return nil
+}
+
+func objectFor(path string, stat os.FileInfo) Object {
+	return Object{
+		Name:        stat.Name(),
+		Size:        stat.Size(),
+		ContentType: ContentType(stat.Name()),
+		ModifiedAt:  stat.ModTime().UTC(),
+		Path:        path,
+	}
+}
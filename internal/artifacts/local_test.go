@@ -0,0 +1,81 @@
+package artifacts
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewLocalStore(dir)
+	taskID := uuid.New()
+
+	list, err := store.List(ctx, taskID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	obj, err := store.Put(ctx, taskID, "screenshot-1.png", strings.NewReader("png"))
+	require.NoError(t, err)
+	assert.Equal(t, "screenshot-1.png", obj.Name)
+	assert.Equal(t, int64(3), obj.Size)
+	assert.Equal(t, "image/png", obj.ContentType)
+	assert.Equal(t, filepath.Join(dir, taskID.String(), "screenshot-1.png"), obj.Path)
+
+	_, err = store.Put(ctx, taskID, "network.har", strings.NewReader(`{"log":{}}`))
+	require.NoError(t, err)
+	// Putting the same name again replaces the artifact
+	_, err = store.Put(ctx, taskID, "screenshot-1.png", strings.NewReader("newer"))
+	require.NoError(t, err)
+
+	list, err = store.List(ctx, taskID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "network.har", list[0].Name)
+	assert.Equal(t, "application/json", list[0].ContentType)
+	assert.Equal(t, int64(5), list[1].Size)
+
+	r, obj, err := store.Get(ctx, taskID, "screenshot-1.png")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	r.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "newer", string(data))
+	assert.Implements(t, (*io.Seeker)(nil), r)
+
+	_, _, err = store.Get(ctx, taskID, "missing.png")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = store.Get(ctx, uuid.New(), "screenshot-1.png")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = store.Get(ctx, taskID, "../"+taskID.String())
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Delete(ctx, taskID))
+	list, err = store.List(ctx, taskID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestValidName(t *testing.T) {
+	assert.NoError(t, ValidName("download-report.csv"))
+	for _, name := range []string{"", ".hidden", "..", "a/b", `a\b`} {
+		assert.Error(t, ValidName(name), name)
+	}
+}
+
+func TestNew(t *testing.T) {
+	store, err := New(config.ArtifactConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+	assert.IsType(t, &LocalStore{}, store)
+
+	_, err = New(config.ArtifactConfig{Backend: "ftp"})
+	assert.Error(t, err)
+}
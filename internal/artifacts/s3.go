@@ -0,0 +1,335 @@
+package artifacts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/awssig"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/google/uuid"
+)
+
+// s3Timeout bounds each request to the object store.
+const s3Timeout = 5 * time.Minute
+
+// S3Store keeps artifacts in an S3-compatible bucket as
+// "<prefix><task id>/<name>". Requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	endpoint     *url.URL
+	region       string
+	bucket       string
+	prefix       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	pathStyle    bool
+	client       *http.Client
+	now          func() time.Time
+}
+
+// NewS3Store checks cfg and returns a store for its bucket. Credentials not
+// set in cfg are read from the standard AWS environment variables.
+func NewS3Store(cfg config.S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("browser.artifacts.s3.bucket is required")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	rawEndpoint := cfg.Endpoint
+	if rawEndpoint == "" {
+		rawEndpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(rawEndpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid browser.artifacts.s3.endpoint %q", cfg.Endpoint)
+	}
+
+	store := &S3Store{
+		endpoint:     endpoint,
+		region:       region,
+		bucket:       cfg.Bucket,
+		prefix:       cfg.Prefix,
+		accessKey:    firstNonEmpty(cfg.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID")),
+		secretKey:    firstNonEmpty(cfg.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		sessionToken: firstNonEmpty(cfg.SessionToken, os.Getenv("AWS_SESSION_TOKEN")),
+		pathStyle:    cfg.PathStyle,
+		client:       &http.Client{Timeout: s3Timeout},
+		now:          time.Now,
+	}
+	if store.accessKey == "" || store.secretKey == "" {
+		return nil, fmt.Errorf("browser.artifacts.s3 needs an access key ID and secret access key")
+	}
+	if store.prefix != "" && !strings.HasSuffix(store.prefix, "/") {
+		store.prefix += "/"
+	}
+	return store, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (s *S3Store) taskPrefix(taskID uuid.UUID) string {
+	return s.prefix + taskID.String() + "/"
+}
+
+// objectURL returns the URL of key, or of the bucket itself when key is empty.
+func (s *S3Store) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	path := "/" + key
+	if s.pathStyle {
+		path = "/" + s.bucket + path
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = u.Path + path
+	u.RawPath = awssig.Escape(u.Path, true)
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// Put implements Store. The body is spooled to a temporary file first, as S3
+// needs its length and a signed hash of it up front.
+func (s *S3Store) Put(ctx context.Context, taskID uuid.UUID, name string, r io.Reader) (Object, error) {
+	if err := ValidName(name); err != nil {
+		return Object{}, err
+	}
+	spool, err := os.CreateTemp("", "goscry-artifact-*")
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to save artifact: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), r)
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to save artifact: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return Object{}, fmt.Errorf("failed to save artifact: %w", err)
+	}
+
+	contentType := ContentType(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(s.taskPrefix(taskID)+name, nil).String(), spool)
+	if err != nil {
+		return Object{}, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	resp, err := s.do(req, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to save artifact %s: %w", name, err)
+	}
+	resp.Body.Close()
+	return Object{Name: name, Size: size, ContentType: contentType, ModifiedAt: s.now().UTC()}, nil
+}
+
+// Get implements Store. The reader streams the object and cannot seek.
+func (s *S3Store) Get(ctx context.Context, taskID uuid.UUID, name string) (io.ReadCloser, Object, error) {
+	if err := ValidName(name); err != nil {
+		return nil, Object{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(s.taskPrefix(taskID)+name, nil).String(), nil)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, Object{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, Object{}, fmt.Errorf("failed to open artifact %s: %w", name, err)
+	}
+	obj := Object{Name: name, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if obj.ContentType == "" {
+		obj.ContentType = ContentType(name)
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		obj.ModifiedAt = modified.UTC()
+	}
+	return resp.Body, obj, nil
+}
+
+// maxPresignExpiry is the longest lifetime S3 accepts for a presigned URL.
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+// PresignGet implements Presigner with a SigV4 presigned URL. Lifetimes are
+// capped at the seven days S3 allows. URLs signed with a session token stop
+// working when the token expires, whichever comes first.
+func (s *S3Store) PresignGet(taskID uuid.UUID, name string, expires time.Duration) (string, time.Time, error) {
+	if err := ValidName(name); err != nil {
+		return "", time.Time{}, err
+	}
+	if expires <= 0 {
+		return "", time.Time{}, fmt.Errorf("presigned URL lifetime must be positive, got %s", expires)
+	}
+	if expires > maxPresignExpiry {
+		expires = maxPresignExpiry
+	}
+	now := s.now()
+	u := awssig.Presign(http.MethodGet, s.objectURL(s.taskPrefix(taskID)+name, nil), awssig.Credentials{
+		AccessKeyID:     s.accessKey,
+		SecretAccessKey: s.secretKey,
+		SessionToken:    s.sessionToken,
+	}, s.region, "s3", expires, now)
+	return u.String(), now.Add(expires).UTC(), nil
+}
+
+// listBucketResult is the part of a ListObjectsV2 response the store reads.
+type listBucketResult struct {
+	Contents              []s3Entry `xml:"Contents"`
+	IsTruncated           bool      `xml:"IsTruncated"`
+	NextContinuationToken string    `xml:"NextContinuationToken"`
+}
+
+// s3Entry is one object in a ListObjectsV2 response.
+type s3Entry struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// List implements Store.
+func (s *S3Store) List(ctx context.Context, taskID uuid.UUID) ([]Object, error) {
+	keys, err := s.listKeys(ctx, s.taskPrefix(taskID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	objects := make([]Object, 0, len(keys))
+	for _, item := range keys {
+		name := strings.TrimPrefix(item.Key, s.taskPrefix(taskID))
+		if ValidName(name) != nil {
+			continue
+		}
+		objects = append(objects, Object{
+			Name:        name,
+			Size:        item.Size,
+			ContentType: ContentType(name),
+			ModifiedAt:  item.LastModified.UTC(),
+		})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+// listKeys returns every object under prefix, following continuation tokens.
+func (s *S3Store) listKeys(ctx context.Context, prefix string) ([]s3Entry, error) {
+	var all []s3Entry
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL("", query).String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid list response: %w", err)
+		}
+		all = append(all, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return all, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Delete implements Store.
+func (s *S3Store) Delete(ctx context.Context, taskID uuid.UUID) error {
+	keys, err := s.listKeys(ctx, s.taskPrefix(taskID))
+	if err != nil {
+		return fmt.Errorf("failed to delete artifacts: %w", err)
+	}
+	for _, item := range keys {
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(item.Key, nil).String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(req, emptyPayloadHash)
+		if err != nil && !isS3NotFound(err) {
+			return fmt.Errorf("failed to delete artifact %s: %w", item.Key, err)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+	return nil
+}
+
+// s3Error is a non-2xx response from the object store.
+type s3Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("object store returned %d %s: %s", e.Status, e.Code, e.Message)
+	}
+	return fmt.Sprintf("object store returned %d", e.Status)
+}
+
+func isS3NotFound(err error) bool {
+	s3Err, ok := err.(*s3Error)
+	return ok && s3Err.Status == http.StatusNotFound
+}
+
+// do signs and sends req. Responses other than 2xx are returned as an
+// *s3Error with the body closed.
+func (s *S3Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	s3Err := &s3Error{Status: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	_ = xml.Unmarshal(body, s3Err)
+	return nil, s3Err
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body, signed for requests without one.
+const emptyPayloadHash = awssig.EmptyPayloadHash
+
+// sign adds AWS Signature Version 4 headers to req, signing the host and
+// every header already set on it.
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	awssig.Sign(req, awssig.Credentials{
+		AccessKeyID:     s.accessKey,
+		SecretAccessKey: s.secretKey,
+		SessionToken:    s.sessionToken,
+	}, s.region, "s3", payloadHash, now)
+}
@@ -0,0 +1,88 @@
+package artifacts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealed(t *testing.T) {
+	ctx := context.Background()
+	keys := map[string]string{}
+	for _, tenant := range []string{"default", "acme"} {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		require.NoError(t, err)
+		keys[tenant] = base64.StdEncoding.EncodeToString(key)
+	}
+	keyring, err := encryption.NewKeyring(config.EncryptionConfig{Enabled: true, Keys: keys})
+	require.NoError(t, err)
+
+	local := NewLocalStore(t.TempDir())
+	assert.Same(t, local, Sealed(local, nil), "no keyring leaves the store as is")
+
+	store := Sealed(local, keyring)
+	_, ok := store.(Presigner)
+	assert.False(t, ok, "presigned URLs would serve ciphertext")
+	taskID := uuid.New()
+
+	obj, err := store.Put(ctx, taskID, "page.html", strings.NewReader("<p>account 1234</p>"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(19), obj.Size)
+	assert.Equal(t, "text/html; charset=utf-8", obj.ContentType)
+
+	// The backend only holds ciphertext
+	raw, err := os.ReadFile(obj.Path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "account 1234")
+
+	var env encryption.Envelope
+	require.NoError(t, json.Unmarshal(raw, &env))
+	assert.Equal(t, "default", env.KeyID)
+
+	// Sealed with the key of the tenant in the context
+	otherTask := uuid.New()
+	tenantObj, err := store.Put(encryption.WithTenant(ctx, "acme"), otherTask, "acme.txt", strings.NewReader("acme"))
+	require.NoError(t, err)
+	raw, err = os.ReadFile(tenantObj.Path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &env))
+	assert.Equal(t, "acme", env.KeyID)
+
+	body, got, err := store.Get(ctx, taskID, "page.html")
+	require.NoError(t, err)
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>account 1234</p>", string(data))
+	assert.Equal(t, int64(19), got.Size)
+	_, seekable := body.(io.Seeker)
+	assert.True(t, seekable, "range requests need a seekable body")
+
+	list, err := store.List(ctx, taskID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, int64(19), list[0].Size)
+
+	// Artifacts written before encryption was enabled cannot be opened
+	_, err = local.Put(ctx, taskID, "old.txt", strings.NewReader("plain"))
+	require.NoError(t, err)
+	_, _, err = store.Get(ctx, taskID, "old.txt")
+	assert.Error(t, err)
+
+	require.NoError(t, store.Delete(ctx, taskID))
+	list, err = local.List(ctx, taskID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,92 @@
+// Package artifacts stores the files tasks produce, such as screenshots,
+// downloads, and HAR archives, keyed by task ID and file name.
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when a task has no artifact with the requested name.
+var ErrNotFound = errors.New("artifact not found")
+
+// Object describes a stored artifact.
+type Object struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	ModifiedAt  time.Time `json:"modified_at"`
+	Path        string    `json:"path,omitempty"` // Set by the local backend
+}
+
+// Store keeps artifacts grouped by task. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Put saves r as the task's artifact called name, replacing any existing one.
+	Put(ctx context.Context, taskID uuid.UUID, name string, r io.Reader) (Object, error)
+	// Get opens an artifact. The caller closes the reader, which also
+	// implements io.Seeker when the backend supports it.
+	Get(ctx context.Context, taskID uuid.UUID, name string) (io.ReadCloser, Object, error)
+	// List returns a task's artifacts sorted by name.
+	List(ctx context.Context, taskID uuid.UUID) ([]Object, error)
+	// Delete removes every artifact of a task.
+	Delete(ctx context.Context, taskID uuid.UUID) error
+}
+
+// Presigner is implemented by stores that can hand out URLs to download an
+// artifact without GoScry credentials, such as S3 presigned URLs.
+type Presigner interface {
+	// PresignGet returns a URL that fetches the artifact for the next
+	// expires, or less when the backend caps it, and when the URL stops
+	// working. It does not check that the artifact exists.
+	PresignGet(taskID uuid.UUID, name string, expires time.Duration) (string, time.Time, error)
+}
+
+// New opens the backend selected by browser.artifacts.backend.
+func New(cfg config.ArtifactConfig) (Store, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return NewLocalStore(cfg.Dir), nil
+	case "s3":
+		store, err := NewS3Store(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown artifact backend: %s", cfg.Backend)
+	}
+}
+
+// ValidName reports whether name can be used as an artifact name: a plain
+// file name without directories that is not hidden.
+func ValidName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid artifact name %q", name)
+	}
+	return nil
+}
+
+// ContentType guesses an artifact's type from its extension.
+func ContentType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".har":
+		return "application/json"
+	case ".zip": // Not in Go's built-in table, so it would depend on the host
+		return "application/zip"
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,162 @@
+// Package awssig signs requests to AWS and S3-compatible APIs with AWS
+// Signature Version 4, so GoScry can call them without the AWS SDK.
+package awssig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmptyPayloadHash is the SHA-256 of an empty body, signed for requests without one.
+const EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Credentials are an access key pair, with a session token for temporary ones.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// PayloadHash returns the hex SHA-256 of body, as signed requests carry it.
+func PayloadHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// UnsignedPayload stands in for the body hash in presigned URLs, whose body
+// is not known when they are signed.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Sign adds AWS Signature Version 4 headers to req for service in region,
+// signing the host and every header already set on it.
+func Sign(req *http.Request, creds Credentials, region, service, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	signature := requestSignature(creds, region, service, amzDate, strings.Join([]string{
+		req.Method,
+		Escape(req.URL.Path, true),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n"))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// Presign returns u with query parameters that let anyone holding it make a
+// method request to service for the next expires, without credentials of
+// their own. Only the host header is signed.
+func Presign(method string, u *url.URL, creds Credentials, region, service string, expires time.Duration, now time.Time) *url.URL {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+
+	signed := *u
+	query := signed.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if creds.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	signature := requestSignature(creds, region, service, amzDate, strings.Join([]string{
+		method,
+		Escape(u.Path, true),
+		canonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		UnsignedPayload,
+	}, "\n"))
+	signed.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return &signed
+}
+
+// requestSignature signs a canonical request with a key derived for the day of
+// amzDate, region, and service.
+func requestSignature(creds Credentials, region, service, amzDate, canonicalRequest string) string {
+	day := amzDate[:8]
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 requires.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		values := append([]string{}, query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, Escape(name, false)+"="+Escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// Escape percent-encodes everything but unreserved characters, and slashes
+// when keepSlash is set, as SigV4 canonical requests expect.
+func Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(c), 16)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+// Package bench runs GoScry's Go benchmarks for the "goscry bench" command.
+package bench
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+const usage = `usage: goscry bench [-bench regexp] [-count n] [-out file] [packages]`
+
+// defaultPackages are benchmarked when no packages are given.
+var defaultPackages = []string{"./internal/..."}
+
+// Command runs "goscry bench". It runs the benchmarks of a GoScry source
+// checkout with the Go toolchain and prints results benchstat can compare,
+// also writing them to -out if set. Save a baseline before a change and
+// compare it with the results after.
+func Command(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	pattern := flags.String("bench", ".", "regular expression selecting the benchmarks to run")
+	count := flags.Int("count", 6, "runs of each benchmark; benchstat needs several to compare")
+	out := flags.String("out", "", "file to also write the results to, e.g. bench/base.txt")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *count < 1 {
+		return fmt.Errorf("-count must be at least 1\n%s", usage)
+	}
+	if _, err := regexp.Compile(*pattern); err != nil {
+		return fmt.Errorf("invalid -bench: %v", err)
+	}
+	packages := flags.Args()
+	if len(packages) == 0 {
+		packages = defaultPackages
+	}
+
+	w := stdout
+	if *out != "" {
+		if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+			return err
+		}
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = io.MultiWriter(stdout, f)
+	}
+
+	cmd := exec.CommandContext(ctx, "go", testArgs(*pattern, *count, packages)...)
+	cmd.Stdout, cmd.Stderr = w, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("goscry bench needs the Go toolchain and a GoScry source checkout: %w", err)
+		}
+		return fmt.Errorf("benchmarks failed: %w", err)
+	}
+	return nil
+}
+
+// testArgs returns the go test arguments that run only the selected
+// benchmarks, with allocations reported.
+func testArgs(pattern string, count int, packages []string) []string {
+	args := []string{"test", "-run", "^$", "-bench", pattern, "-benchmem", "-count", strconv.Itoa(count)}
+	return append(args, packages...)
+}
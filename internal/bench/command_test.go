@@ -0,0 +1,26 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestArgs(t *testing.T) {
+	assert.Equal(t,
+		[]string{"test", "-run", "^$", "-bench", "DomAST", "-benchmem", "-count", "6", "./internal/dom"},
+		testArgs("DomAST", 6, []string{"./internal/dom"}))
+}
+
+func TestCommand_InvalidFlags(t *testing.T) {
+	var out bytes.Buffer
+	for _, args := range [][]string{
+		{"-count", "0"},
+		{"-bench", "(unclosed"},
+		{"-unknown"},
+	} {
+		assert.Error(t, Command(context.Background(), args, &out), "%v", args)
+	}
+}
@@ -1,6 +1,7 @@
 package browser
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"time"
@@ -28,7 +29,13 @@ func GenerateActionSequence(taskAction taskstypes.Action, taskCreds *taskstypes.
 		if taskAction.Value == "" {
 			return nil, fmt.Errorf("navigate action requires a non-empty URL value")
 		}
-		return dom.NavigateAction(taskAction.Value), nil
+		if !dom.ValidWaitUntil(taskAction.WaitUntil) {
+			return nil, fmt.Errorf("invalid wait_until '%s' for navigate action", taskAction.WaitUntil)
+		}
+		if taskAction.WaitUntil == taskstypes.WaitUntilSelector && taskAction.Selector == "" {
+			return nil, fmt.Errorf("navigate with wait_until selector requires a selector")
+		}
+		return dom.NavigateWaitAction(taskAction.Value, taskAction.WaitUntil, taskAction.Selector), nil
 
 	case taskstypes.ActionWaitVisible:
 		if taskAction.Selector == "" {
@@ -51,6 +58,9 @@ func GenerateActionSequence(taskAction taskstypes.Action, taskCreds *taskstypes.
 		}
 		return chromedp.Sleep(dur), nil
 
+	case taskstypes.ActionAdvanceClock:
+		return advanceClockAction(taskAction)
+
 	case taskstypes.ActionClick:
 		if taskAction.Selector == "" {
 			return nil, fmt.Errorf("click action requires a selector")
@@ -88,60 +98,79 @@ func GenerateActionSequence(taskAction taskstypes.Action, taskCreds *taskstypes.
 		if q, err := strconv.Atoi(taskAction.Value); err == nil && q >= 0 && q <= 100 {
 			quality = q
 		}
-		// Return the screenshot action directly
-		return dom.ScreenshotAction(quality, nil), nil
+		// The Manager saves task screenshots itself; here the image is discarded
+		var buf []byte
+		return dom.ScreenshotAction(quality, &buf), nil
+
+	case taskstypes.ActionGetDOM, taskstypes.ActionRunScript, taskstypes.ActionFindText:
+		// The Manager keeps their output for later actions; here it is discarded
+		var out interface{}
+		return GenerateOutputAction(taskAction, &out)
+
+	case taskstypes.ActionLogin:
+		// High-level action, requires credentials passed from the task context.
+		if taskCreds == nil || taskCreds.Username == "" || taskCreds.Password == "" {
+			return nil, fmt.Errorf("credentials required for login action but not provided or incomplete")
+		}
+		form, err := loginForm(taskAction)
+		if err != nil {
+			return nil, err
+		}
+		var check taskstypes.LoginCheck
+		if taskAction.Verify != nil {
+			check = *taskAction.Verify
+		}
+		verify, err := verifyLoginAction(check, form.PasswordSelector, ErrLoginFailed)
+		if err != nil {
+			return nil, err
+		}
+		return append(fillLoginForm(form, taskCreds), verify), nil
 
+	default:
+		return nil, fmt.Errorf("unknown action type: %s", taskAction.Type)
+	}
+}
+
+// GenerateOutputAction translates get_dom, run_script, and find_text, the
+// actions that produce output, into a chromedp Action that stores the output
+// in out: the DOM as a string, or the script's or search's JSON result.
+func GenerateOutputAction(taskAction taskstypes.Action, out *interface{}) (chromedp.Action, error) {
+	switch taskAction.Type {
 	case taskstypes.ActionGetDOM:
-		// Returns an action that populates a string pointed to by the result arg of Run.
-		// The caller (ExecuteTask) needs to provide a pointer to a string.
 		sel := taskAction.Selector
 		if sel == "" {
 			sel = "body" // Default to body
 		}
+		var html string
+		var get chromedp.Action
 		switch taskAction.Format {
 		case "full_html":
-			return dom.GetOuterHTMLAction(sel, nil), nil // Expects *string in Run
+			get = dom.GetOuterHTMLAction(sel, &html)
 		case "simplified_html":
-			// Needs two steps: get raw HTML, then simplify. The caller must orchestrate this.
-			// Returning just the raw fetch for now. Simplification must happen in ExecuteTask.
-			// Or return a complex action. Let's return just the raw fetch.
-			return dom.GetOuterHTMLAction(sel, nil), nil // Expects *string in Run
+			// Simplification runs on the DOM worker pool, not the browser's goroutines
+			get = dom.GetSimplifiedDOMAction(sel, &html)
 		case "text_content":
 			fallthrough
 		default:
 			script := fmt.Sprintf(`document.querySelector('%s') ? document.querySelector('%s').innerText : document.body.innerText`, sel, sel)
-			return chromedp.Evaluate(script, nil), nil // Expects *string in Run
+			get = chromedp.Evaluate(script, &html)
 		}
+		return chromedp.ActionFunc(func(ctx context.Context) error {
+			if err := get.Do(ctx); err != nil {
+				return err
+			}
+			*out = html
+			return nil
+		}), nil
 
 	case taskstypes.ActionRunScript:
 		if taskAction.Value == "" {
 			return nil, fmt.Errorf("run_script action requires script code in value")
 		}
-		// Returns an action that populates an interface{} pointed to by the result arg of Run.
-		return dom.RunScriptAction(taskAction.Value, nil), nil // Expects *interface{} in Run
+		return dom.RunScriptAction(taskAction.Value, out), nil
 
-	case taskstypes.ActionLogin:
-		// High-level action, requires credentials passed from the task context.
-		if taskCreds == nil || taskCreds.Username == "" || taskCreds.Password == "" {
-			return nil, fmt.Errorf("credentials required for login action but not provided or incomplete")
-		}
-		// Use generic selectors; ideally make these configurable per task/action
-		userSel := "#username"
-		passSel := "#password"
-		submitSel := "button[type='submit'], input[type='submit']"
-
-		// Build sequence
-		loginSequence := chromedp.Tasks{
-			chromedp.WaitVisible(userSel, chromedp.ByQuery),
-			chromedp.SendKeys(userSel, taskCreds.Username, chromedp.ByQuery),
-			chromedp.WaitVisible(passSel, chromedp.ByQuery),
-			chromedp.SendKeys(passSel, taskCreds.Password, chromedp.ByQuery),
-			chromedp.WaitVisible(submitSel, chromedp.ByQuery),
-			chromedp.Click(submitSel, chromedp.ByQuery),
-		}
-		return loginSequence, nil
-
-	default:
-		return nil, fmt.Errorf("unknown action type: %s", taskAction.Type)
+	case taskstypes.ActionFindText:
+		return findTextAction(taskAction, out)
 	}
+	return nil, fmt.Errorf("%s action produces no output", taskAction.Type)
 }
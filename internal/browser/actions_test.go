@@ -3,6 +3,7 @@ package browser
 import (
 	"testing"
 
+	"github.com/chromedp/chromedp"
 	"github.com/copyleftdev/goscry/internal/taskstypes"
 	"github.com/stretchr/testify/assert"
 )
@@ -111,3 +112,66 @@ func TestGenerateActionSequence_2FACodeResolution(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, cdpAction)
 }
+
+func TestGenerateActionSequence_LoginVerify(t *testing.T) {
+	creds := &taskstypes.Credentials{Username: "ops", Password: "hunter2"}
+	action := taskstypes.Action{
+		Type: taskstypes.ActionLogin,
+		Verify: &taskstypes.LoginCheck{
+			SuccessURL:       `/dashboard`,
+			FailureSelectors: []string{".alert-error"},
+		},
+	}
+
+	cdpAction, err := GenerateActionSequence(action, creds, "")
+	assert.NoError(t, err)
+	if assert.IsType(t, chromedp.Tasks{}, cdpAction) {
+		assert.Len(t, cdpAction.(chromedp.Tasks), 7, "verification runs after the submit click")
+	}
+
+	// Logins are verified even without a check
+	action.Verify = nil
+	cdpAction, err = GenerateActionSequence(action, creds, "")
+	assert.NoError(t, err)
+	assert.Len(t, cdpAction.(chromedp.Tasks), 7)
+
+	// An invalid success URL pattern is rejected up front
+	action.Verify = &taskstypes.LoginCheck{}
+	action.Verify.SuccessURL = "(dashboard"
+	_, err = GenerateActionSequence(action, creds, "")
+	assert.ErrorContains(t, err, "success_url")
+
+	// Empty failure text would match every page
+	action.Verify = &taskstypes.LoginCheck{FailureText: []string{" "}}
+	_, err = GenerateActionSequence(action, creds, "")
+	assert.ErrorContains(t, err, "failure_text")
+}
+
+func TestGenerateActionSequence_NavigateWaitUntil(t *testing.T) {
+	action := taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://example.com", WaitUntil: taskstypes.WaitUntilNetworkIdle}
+	_, err := GenerateActionSequence(action, nil, "")
+	assert.NoError(t, err)
+
+	action.WaitUntil = "idle"
+	_, err = GenerateActionSequence(action, nil, "")
+	assert.Error(t, err)
+
+	action.WaitUntil = taskstypes.WaitUntilSelector
+	_, err = GenerateActionSequence(action, nil, "")
+	assert.Error(t, err, "selector strategy needs a selector")
+
+	action.Selector = "#app"
+	_, err = GenerateActionSequence(action, nil, "")
+	assert.NoError(t, err)
+}
+
+func TestGenerateActionSequence_AdvanceClock(t *testing.T) {
+	cdpAction, err := GenerateActionSequence(taskstypes.Action{Type: taskstypes.ActionAdvanceClock, Value: "90s"}, nil, "")
+	assert.NoError(t, err)
+	assert.NotNil(t, cdpAction)
+
+	for _, value := range []string{"", "soon", "-1m"} {
+		_, err := GenerateActionSequence(taskstypes.Action{Type: taskstypes.ActionAdvanceClock, Value: value}, nil, "")
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,251 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// ErrArtifactTooLarge is returned when a screenshot or download exceeds browser.artifacts.maxBytes.
+var ErrArtifactTooLarge = errors.New("artifact exceeds browser.artifacts.maxBytes")
+
+// ArtifactInfo describes a file saved by a screenshot action.
+type ArtifactInfo struct {
+	Action      int                  `json:"action"`
+	Name        string               `json:"name"`           // In the task's artifact store, see GET /tasks/{id}/artifacts
+	Path        string               `json:"path,omitempty"` // Set by the local backend
+	Size        int64                `json:"size"`
+	ContentType string               `json:"content_type"`
+	Selector    string               `json:"selector,omitempty"` // Set for element screenshots
+	Clip        *taskstypes.ClipRect `json:"clip,omitempty"`     // Set for region screenshots
+}
+
+// artifactMetrics counts captures for ArtifactStats.
+type artifactMetrics struct {
+	written, bytesWritten, rejected atomic.Int64
+	inProgress, waiting, waited     atomic.Int64
+	peakBytes                       atomic.Int64
+}
+
+// ArtifactStats implements tasks.ArtifactStatsProvider.
+func (m *Manager) ArtifactStats() tasks.ArtifactStats {
+	return tasks.ArtifactStats{
+		Written:      m.artifacts.written.Load(),
+		BytesWritten: m.artifacts.bytesWritten.Load(),
+		Rejected:     m.artifacts.rejected.Load(),
+		InProgress:   m.artifacts.inProgress.Load(),
+		Waiting:      m.artifacts.waiting.Load(),
+		Waited:       m.artifacts.waited.Load(),
+		PeakBytes:    m.artifacts.peakBytes.Load(),
+	}
+}
+
+// acquireArtifactSlot blocks until fewer than browser.artifacts.maxConcurrent
+// captures are in progress, so capture-heavy tasks queue instead of holding
+// many large screenshots in memory at once.
+func (m *Manager) acquireArtifactSlot(ctx context.Context) (func(), error) {
+	if m.artifactSlots != nil && !m.artifactSlots.TryAcquire(1) {
+		m.artifacts.waited.Add(1)
+		m.artifacts.waiting.Add(1)
+		err := m.artifactSlots.Acquire(ctx, 1)
+		m.artifacts.waiting.Add(-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.artifacts.inProgress.Add(1)
+	return func() {
+		m.artifacts.inProgress.Add(-1)
+		if m.artifactSlots != nil {
+			m.artifactSlots.Release(1)
+		}
+	}, nil
+}
+
+// recordArtifact counts a saved artifact of size bytes.
+func (m *Manager) recordArtifact(size int64) {
+	m.artifacts.written.Add(1)
+	m.artifacts.bytesWritten.Add(size)
+	for {
+		peak := m.artifacts.peakBytes.Load()
+		if size <= peak || m.artifacts.peakBytes.CompareAndSwap(peak, size) {
+			return
+		}
+	}
+}
+
+// rejectArtifact counts an artifact refused for its size and returns the error to report.
+func (m *Manager) rejectArtifact(what string, size int64) error {
+	m.artifacts.rejected.Add(1)
+	return fmt.Errorf("%w: %s of %d bytes is over the %d byte limit", ErrArtifactTooLarge, what, size, m.cfg.Artifacts.MaxBytes)
+}
+
+// overArtifactLimit reports whether size exceeds browser.artifacts.maxBytes. Zero means no limit.
+func (m *Manager) overArtifactLimit(size int64) bool {
+	return m.cfg.Artifacts.MaxBytes > 0 && size > m.cfg.Artifacts.MaxBytes
+}
+
+// artifactStore returns where artifacts are saved, sealed with the keyring
+// when encryption is on. Managers built without NewManager, as in tests, use
+// a local store under browser.artifacts.dir.
+func (m *Manager) artifactStore() artifacts.Store {
+	store := m.store
+	if store == nil {
+		store = artifacts.NewLocalStore(m.cfg.Artifacts.Dir)
+	}
+	return artifacts.Sealed(store, m.keyring)
+}
+
+// elementRectScript scrolls the first match into view and returns its box
+// in document coordinates.
+const elementRectScript = `(function(sel) {
+	const el = document.querySelector(sel);
+	if (!el) return null;
+	el.scrollIntoView({block: 'center', inline: 'center'});
+	const rect = el.getBoundingClientRect();
+	return {x: rect.left + window.scrollX, y: rect.top + window.scrollY, width: rect.width, height: rect.height};
+})(%s)`
+
+// screenshotAction captures the full page, the element matching Selector, or
+// the Clip region, and saves it in the artifact store as
+// "screenshot-<action index>.<ext>". Value is the JPEG quality; 100 saves a
+// PNG. Without a Value, full-page captures use quality 90 and element or clip
+// captures are PNG. Saved files are listed in result.custom_data.screenshots.
+func (m *Manager) screenshotAction(task *taskstypes.Task, index int, action taskstypes.Action, result *taskstypes.TaskResult) (chromedp.Action, error) {
+	if action.Selector != "" && action.Clip != nil {
+		return nil, fmt.Errorf("screenshot takes either a selector or a clip, not both")
+	}
+	if clip := action.Clip; clip != nil && (clip.X < 0 || clip.Y < 0 || clip.Width <= 0 || clip.Height <= 0) {
+		return nil, fmt.Errorf("screenshot clip needs a non-negative x and y and a positive width and height")
+	}
+	quality := 90
+	if action.Selector != "" || action.Clip != nil {
+		quality = 100
+	}
+	if action.Value != "" {
+		q, err := strconv.Atoi(action.Value)
+		if err != nil || q < 0 || q > 100 {
+			return nil, fmt.Errorf("invalid screenshot quality %q (expected 0-100)", action.Value)
+		}
+		quality = q
+	}
+	format, contentType, ext := page.CaptureScreenshotFormatJpeg, "image/jpeg", ".jpg"
+	if quality == 100 {
+		format, contentType, ext = page.CaptureScreenshotFormatPng, "image/png", ".png"
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		params := page.CaptureScreenshot().
+			WithCaptureBeyondViewport(true).
+			WithFromSurface(true).
+			WithFormat(format)
+		if format == page.CaptureScreenshotFormatJpeg {
+			params = params.WithQuality(int64(quality))
+		}
+		switch {
+		case action.Selector != "":
+			clip, err := elementClip(ctx, action.Selector)
+			if err != nil {
+				return err
+			}
+			params = params.WithClip(clip)
+		case action.Clip != nil:
+			params = params.WithClip(&page.Viewport{
+				X: action.Clip.X, Y: action.Clip.Y, Width: action.Clip.Width, Height: action.Clip.Height, Scale: 1,
+			})
+		}
+
+		release, err := m.acquireArtifactSlot(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		// Keep the data base64-encoded as Chrome sent it and decode while
+		// writing, rather than holding the decoded image as well
+		var shot struct {
+			Data string `json:"data"`
+		}
+		if err := cdp.Execute(ctx, page.CommandCaptureScreenshot, params, &shot); err != nil {
+			return fmt.Errorf("failed to capture screenshot: %w", err)
+		}
+
+		if size := decodedSize(shot.Data); m.overArtifactLimit(size) {
+			return m.rejectArtifact("screenshot", size)
+		}
+		obj, err := m.putBase64(ctx, task, fmt.Sprintf("screenshot-%d%s", index, ext), shot.Data)
+		if err != nil {
+			return err
+		}
+		m.recordArtifact(obj.Size)
+
+		screenshots, _ := result.CustomData["screenshots"].([]ArtifactInfo)
+		setCustomData(result, "screenshots", append(screenshots, ArtifactInfo{
+			Action: index, Name: obj.Name, Path: obj.Path, Size: obj.Size, ContentType: contentType,
+			Selector: action.Selector, Clip: action.Clip,
+		}))
+		return nil
+	}), nil
+}
+
+// elementClip waits for selector to be visible and returns its box as a screenshot clip.
+func elementClip(ctx context.Context, selector string) (*page.Viewport, error) {
+	if err := chromedp.WaitVisible(selector, chromedp.ByQuery).Do(ctx); err != nil {
+		return nil, err
+	}
+	var rect *taskstypes.ClipRect
+	if err := chromedp.Evaluate(fmt.Sprintf(elementRectScript, jsString(selector)), &rect).Do(ctx); err != nil {
+		return nil, fmt.Errorf("failed to measure %s: %w", selector, err)
+	}
+	if rect == nil || rect.Width <= 0 || rect.Height <= 0 {
+		return nil, fmt.Errorf("element %s has no visible area to capture", selector)
+	}
+	return &page.Viewport{X: rect.X, Y: rect.Y, Width: rect.Width, Height: rect.Height, Scale: 1}, nil
+}
+
+// decodedSize returns the number of bytes padded base64 data decodes to.
+func decodedSize(encoded string) int64 {
+	size := base64.StdEncoding.DecodedLen(len(encoded))
+	size -= len(encoded) - len(strings.TrimRight(encoded, "="))
+	return int64(size)
+}
+
+// putBase64 decodes encoded into the task's artifact called name as it writes.
+func (m *Manager) putBase64(ctx context.Context, task *taskstypes.Task, name, encoded string) (artifacts.Object, error) {
+	return m.artifactStore().Put(encryption.WithTenant(ctx, task.Tenant), task.ID, name, base64.NewDecoder(base64.StdEncoding, strings.NewReader(encoded)))
+}
+
+// readBase64File encodes a file as base64 while reading it, so only the
+// encoded form is held in memory.
+func readBase64File(path string, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	b.Grow(base64.StdEncoding.EncodedLen(int(size)))
+	enc := base64.NewEncoder(base64.StdEncoding, &b)
+	if _, err := io.Copy(enc, f); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,128 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+)
+
+func TestBase64FileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	m := &Manager{cfg: &config.BrowserConfig{Artifacts: config.ArtifactConfig{Dir: dir}}}
+	task := &taskstypes.Task{ID: uuid.New()}
+	for _, data := range [][]byte{{}, []byte("a"), []byte("ab"), []byte("abc"), make([]byte, 100_000)} {
+		encoded := base64.StdEncoding.EncodeToString(data)
+		assert.Equal(t, int64(len(data)), decodedSize(encoded))
+
+		obj, err := m.putBase64(context.Background(), task, "artifact", encoded)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(data)), obj.Size)
+
+		back, err := readBase64File(obj.Path, obj.Size)
+		require.NoError(t, err)
+		assert.Equal(t, encoded, back)
+	}
+
+	_, err := m.putBase64(context.Background(), task, "bad", "not base64!")
+	assert.Error(t, err)
+	_, statErr := os.Stat(filepath.Join(dir, task.ID.String(), "bad"))
+	assert.True(t, os.IsNotExist(statErr), "partial files are removed")
+}
+
+func TestArtifactSlots_Backpressure(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{}, artifactSlots: semaphore.NewWeighted(1)}
+
+	release, err := m.acquireArtifactSlot(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), m.ArtifactStats().InProgress)
+
+	acquired := make(chan func())
+	go func() {
+		next, err := m.acquireArtifactSlot(context.Background())
+		if err == nil {
+			acquired <- next
+		}
+	}()
+	assert.Eventually(t, func() bool { return m.ArtifactStats().Waiting == 1 }, time.Second, 5*time.Millisecond)
+
+	release()
+	next := <-acquired
+	stats := m.ArtifactStats()
+	assert.Equal(t, int64(1), stats.Waited)
+	assert.Zero(t, stats.Waiting)
+	next()
+	assert.Zero(t, m.ArtifactStats().InProgress)
+
+	// A waiting capture gives up with its context
+	release, _ = m.acquireArtifactSlot(context.Background())
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = m.acquireArtifactSlot(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestArtifactLimitsAndCounters(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{Artifacts: config.ArtifactConfig{MaxBytes: 1000}}}
+	assert.False(t, m.overArtifactLimit(1000))
+	assert.True(t, m.overArtifactLimit(1001))
+
+	m.recordArtifact(300)
+	m.recordArtifact(700)
+	m.recordArtifact(100)
+	assert.ErrorIs(t, m.rejectArtifact("screenshot", 5000), ErrArtifactTooLarge)
+
+	stats := m.ArtifactStats()
+	assert.Equal(t, int64(3), stats.Written)
+	assert.Equal(t, int64(1100), stats.BytesWritten)
+	assert.Equal(t, int64(700), stats.PeakBytes)
+	assert.Equal(t, int64(1), stats.Rejected)
+
+	m.cfg.Artifacts.MaxBytes = 0
+	assert.False(t, m.overArtifactLimit(1<<40), "zero means no limit")
+}
+
+func TestScreenshotAction_Quality(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{}}
+	task := &taskstypes.Task{}
+	result := &taskstypes.TaskResult{}
+
+	for _, value := range []string{"", "0", "90", "100"} {
+		_, err := m.screenshotAction(task, 0, taskstypes.Action{Type: taskstypes.ActionScreenshot, Value: value}, result)
+		assert.NoError(t, err, value)
+	}
+	for _, value := range []string{"high", "101", "-1"} {
+		_, err := m.screenshotAction(task, 0, taskstypes.Action{Type: taskstypes.ActionScreenshot, Value: value}, result)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestScreenshotAction_ElementAndClip(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{}}
+	task := &taskstypes.Task{}
+	result := &taskstypes.TaskResult{}
+	clip := &taskstypes.ClipRect{X: 0, Y: 100, Width: 800, Height: 600}
+
+	_, err := m.screenshotAction(task, 0, taskstypes.Action{Type: taskstypes.ActionScreenshot, Selector: "#chart"}, result)
+	assert.NoError(t, err)
+	_, err = m.screenshotAction(task, 0, taskstypes.Action{Type: taskstypes.ActionScreenshot, Clip: clip}, result)
+	assert.NoError(t, err)
+
+	_, err = m.screenshotAction(task, 0, taskstypes.Action{Type: taskstypes.ActionScreenshot, Selector: "#chart", Clip: clip}, result)
+	assert.Error(t, err, "selector and clip are exclusive")
+	for _, bad := range []taskstypes.ClipRect{{Width: 0, Height: 10}, {Width: 10, Height: -1}, {X: -5, Width: 10, Height: 10}} {
+		bad := bad
+		_, err = m.screenshotAction(task, 0, taskstypes.Action{Type: taskstypes.ActionScreenshot, Clip: &bad}, result)
+		assert.Error(t, err, "%+v", bad)
+	}
+}
@@ -0,0 +1,109 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cdpbrowser "github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/tasks"
+)
+
+// cdpEndpointTimeout bounds asking a session's browser where it serves DevTools.
+const cdpEndpointTimeout = 5 * time.Second
+
+var _ tasks.CDPExecutor = (*Manager)(nil)
+
+// LeaseCDP lends the named session's browser to an outside CDP client. The
+// session is held as if a task were running on it until the lease is
+// released, and closing the session revokes the lease before waiting on it.
+func (m *Manager) LeaseCDP(name string) (*tasks.CDPLease, error) {
+	m.sessionsMu.Lock()
+	sess := m.sessions[name]
+	m.sessionsMu.Unlock()
+	if sess == nil {
+		return nil, fmt.Errorf("%w: %s", tasks.ErrSessionNotFound, name)
+	}
+	if !sess.run.TryLock() {
+		return nil, fmt.Errorf("%w: %s", tasks.ErrSessionBusy, name)
+	}
+	if sess.ctx.Err() != nil {
+		sess.run.Unlock()
+		return nil, fmt.Errorf("%w: %s", tasks.ErrSessionNotFound, name)
+	}
+
+	ctx, cancel := context.WithTimeout(sess.ctx, cdpEndpointTimeout)
+	endpoint, err := devToolsEndpoint(ctx)
+	cancel()
+	if err != nil {
+		sess.run.Unlock()
+		return nil, fmt.Errorf("failed to find the DevTools endpoint of session %s: %w", name, err)
+	}
+
+	revoked := make(chan struct{})
+	var revokeOnce, releaseOnce sync.Once
+	sess.mu.Lock()
+	sess.busy = true
+	sess.cdpRevoke = func() { revokeOnce.Do(func() { close(revoked) }) }
+	sess.mu.Unlock()
+
+	release := func() {
+		releaseOnce.Do(func() {
+			sess.mu.Lock()
+			sess.busy = false
+			sess.cdpRevoke = nil
+			sess.lastUsedAt = time.Now().UTC()
+			sess.mu.Unlock()
+			sess.run.Unlock()
+		})
+	}
+	return &tasks.CDPLease{Endpoint: endpoint, Revoked: revoked, Release: release}, nil
+}
+
+// devToolsEndpoint returns the host:port the browser behind ctx serves
+// DevTools on. chromedp starts Chrome with --remote-debugging-port=0, so
+// Chrome picks the port and records it in its profile directory.
+func devToolsEndpoint(ctx context.Context) (string, error) {
+	c := chromedp.FromContext(ctx)
+	if c == nil || c.Browser == nil {
+		return "", chromedp.ErrInvalidContext
+	}
+	args, err := cdpbrowser.GetBrowserCommandLine().Do(cdp.WithExecutor(ctx, c.Browser))
+	if err != nil {
+		return "", err
+	}
+	return devToolsActivePort(args)
+}
+
+// devToolsActivePort reads the DevTools endpoint of a browser launched with
+// args from the DevToolsActivePort file in its --user-data-dir.
+func devToolsActivePort(args []string) (string, error) {
+	var dir string
+	for _, arg := range args {
+		if v, ok := strings.CutPrefix(arg, "--user-data-dir="); ok {
+			dir = v
+		}
+	}
+	if dir == "" {
+		return "", errors.New("browser was started without --user-data-dir")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "DevToolsActivePort"))
+	if err != nil {
+		return "", err
+	}
+	port, _, _ := strings.Cut(string(data), "\n")
+	port = strings.TrimSpace(port)
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return "", fmt.Errorf("invalid DevToolsActivePort %q", port)
+	}
+	return net.JoinHostPort("127.0.0.1", port), nil
+}
@@ -0,0 +1,30 @@
+package browser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevToolsActivePort(t *testing.T) {
+	dir := t.TempDir()
+	args := []string{"--headless", "--user-data-dir=" + dir, "--remote-debugging-port=0"}
+
+	_, err := devToolsActivePort(args)
+	assert.Error(t, err, "Chrome has not written the file yet")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "DevToolsActivePort"), []byte("38125\n/devtools/browser/4f1c\n"), 0o600))
+	endpoint, err := devToolsActivePort(args)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:38125", endpoint)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "DevToolsActivePort"), []byte("0\n"), 0o600))
+	_, err = devToolsActivePort(args)
+	assert.Error(t, err)
+
+	_, err = devToolsActivePort([]string{"--headless"})
+	assert.Error(t, err)
+}
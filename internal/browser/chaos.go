@@ -0,0 +1,126 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// ErrChaosNavigation is the failure chaos mode injects into navigations. Its
+// text carries a net:: code so it is classified as NAVIGATION_FAILED, like a
+// real connection failure.
+var ErrChaosNavigation = errors.New("net::ERR_CONNECTION_RESET (injected by chaos mode)")
+
+// Faults chaos mode injects, as reported in result.custom_data.chaos.
+const (
+	ChaosLatency           = "latency"
+	ChaosNavigationFailure = "navigation_failure"
+	ChaosSlowSelector      = "slow_selector"
+)
+
+// ChaosFault records a fault injected into an action.
+type ChaosFault struct {
+	Action  int    `json:"action"`
+	Fault   string `json:"fault"`
+	DelayMs int64  `json:"delay_ms,omitempty"`
+}
+
+// chaos decides which actions get faults. A nil *chaos injects nothing.
+type chaos struct {
+	cfg config.ChaosConfig
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// newChaos returns nil unless cfg enables chaos mode.
+func newChaos(cfg config.ChaosConfig) (*chaos, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	rates := map[string]float64{
+		"latencyRate":           cfg.LatencyRate,
+		"navigationFailureRate": cfg.NavigationFailureRate,
+		"slowSelectorRate":      cfg.SlowSelectorRate,
+	}
+	for name, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if cfg.MaxLatency < 0 || cfg.SelectorDelay < 0 {
+		return nil, errors.New("maxLatency and selectorDelay cannot be negative")
+	}
+	seed := uint64(cfg.Seed)
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	return &chaos{cfg: cfg, rng: rand.New(rand.NewPCG(seed, seed))}, nil
+}
+
+// roll reports whether an event with the given rate happens.
+func (c *chaos) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Float64() < rate
+}
+
+// latency picks a delay up to MaxLatency.
+func (c *chaos) latency() time.Duration {
+	if c.cfg.MaxLatency <= 0 {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.rng.Int64N(int64(c.cfg.MaxLatency) + 1))
+}
+
+// inject wraps an action with the faults picked for it and records them in
+// result. The delays run inside the action, so they count against its timeout.
+func (c *chaos) inject(index int, action taskstypes.Action, run chromedp.Action, result *taskstypes.TaskResult) chromedp.Action {
+	if c == nil {
+		return run
+	}
+	var faults []ChaosFault
+	var delay time.Duration
+	if c.roll(c.cfg.LatencyRate) {
+		d := c.latency()
+		delay += d
+		faults = append(faults, ChaosFault{Action: index, Fault: ChaosLatency, DelayMs: d.Milliseconds()})
+	}
+	if failedSelector(action) != "" && c.roll(c.cfg.SlowSelectorRate) {
+		delay += c.cfg.SelectorDelay
+		faults = append(faults, ChaosFault{Action: index, Fault: ChaosSlowSelector, DelayMs: c.cfg.SelectorDelay.Milliseconds()})
+	}
+	_, navigates := navigationURL(action)
+	fail := navigates && c.roll(c.cfg.NavigationFailureRate)
+	if fail {
+		faults = append(faults, ChaosFault{Action: index, Fault: ChaosNavigationFailure})
+	}
+	if len(faults) == 0 {
+		return run
+	}
+	recorded, _ := result.CustomData["chaos"].([]ChaosFault)
+	setCustomData(result, "chaos", append(recorded, faults...))
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if delay > 0 {
+			if err := chromedp.Sleep(delay).Do(ctx); err != nil {
+				return err
+			}
+		}
+		if fail {
+			return ErrChaosNavigation
+		}
+		return run.Do(ctx)
+	})
+}
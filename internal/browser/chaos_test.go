@@ -0,0 +1,86 @@
+package browser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChaos(t *testing.T) {
+	c, err := newChaos(config.ChaosConfig{LatencyRate: 1})
+	require.NoError(t, err)
+	assert.Nil(t, c, "disabled chaos mode injects nothing")
+
+	_, err = newChaos(config.ChaosConfig{Enabled: true, NavigationFailureRate: 1.5})
+	assert.ErrorContains(t, err, "navigationFailureRate")
+	_, err = newChaos(config.ChaosConfig{Enabled: true, SelectorDelay: -time.Second})
+	assert.Error(t, err)
+}
+
+func TestChaos_Inject(t *testing.T) {
+	ran := 0
+	run := chromedp.ActionFunc(func(context.Context) error {
+		ran++
+		return nil
+	})
+	navigate := taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://example.com"}
+	click := taskstypes.Action{Type: taskstypes.ActionClick, Selector: "#buy"}
+
+	var none *chaos
+	result := &taskstypes.TaskResult{}
+	require.NoError(t, none.inject(0, navigate, run, result).Do(context.Background()))
+	assert.Equal(t, 1, ran)
+	assert.Nil(t, result.CustomData)
+
+	c, err := newChaos(config.ChaosConfig{Enabled: true, Seed: 1, NavigationFailureRate: 1, SlowSelectorRate: 1, SelectorDelay: 20 * time.Millisecond})
+	require.NoError(t, err)
+
+	err = c.inject(0, navigate, run, result).Do(context.Background())
+	assert.ErrorIs(t, err, ErrChaosNavigation)
+	assert.Equal(t, taskstypes.ErrorNavigationFailed, classifyActionError(context.Background(), navigate, err))
+	assert.Equal(t, 1, ran, "a failed navigation never runs")
+
+	start := time.Now()
+	require.NoError(t, c.inject(1, click, run, result).Do(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, 2, ran)
+
+	// Delays count against the action's own deadline
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.inject(2, click, run, result).Do(ctx), context.DeadlineExceeded)
+
+	assert.Equal(t, []ChaosFault{
+		{Action: 0, Fault: ChaosNavigationFailure},
+		{Action: 1, Fault: ChaosSlowSelector, DelayMs: 20},
+		{Action: 2, Fault: ChaosSlowSelector, DelayMs: 20},
+	}, result.CustomData["chaos"])
+
+	// Actions the faults don't apply to are left alone
+	wait := taskstypes.Action{Type: taskstypes.ActionWaitDelay, Value: "1s"}
+	require.NoError(t, c.inject(3, wait, run, result).Do(context.Background()))
+	assert.Len(t, result.CustomData["chaos"], 3)
+}
+
+func TestChaos_SeedRepeats(t *testing.T) {
+	faults := func() []ChaosFault {
+		c, err := newChaos(config.ChaosConfig{Enabled: true, Seed: 42, LatencyRate: 0.5, MaxLatency: time.Second, NavigationFailureRate: 0.3})
+		require.NoError(t, err)
+		result := &taskstypes.TaskResult{}
+		for i := 0; i < 50; i++ {
+			c.inject(i, taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://example.com"}, chromedp.ActionFunc(nil), result)
+		}
+		recorded, _ := result.CustomData["chaos"].([]ChaosFault)
+		return recorded
+	}
+	first := faults()
+	assert.NotEmpty(t, first)
+	assert.Less(t, len(first), 100, "rates below 1 skip some actions")
+	assert.Equal(t, first, faults())
+}
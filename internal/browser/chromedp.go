@@ -2,34 +2,68 @@ package browser
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/auth"
 	"github.com/copyleftdev/goscry/internal/config"
 	"github.com/copyleftdev/goscry/internal/dom"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/secrets"
 	"github.com/copyleftdev/goscry/internal/tasks"
 	"github.com/copyleftdev/goscry/internal/taskstypes"
 	"golang.org/x/sync/semaphore"
 )
 
+// ErrActionTimeout is returned when a single action exceeds its timeout.
+var ErrActionTimeout = errors.New("action timed out")
+
 // Compile-time check to ensure Manager implements the interface
 var _ tasks.BrowserExecutor = (*Manager)(nil)
+var _ tasks.CredentialKeyReceiver = (*Manager)(nil)
+var _ tasks.TemplateReceiver = (*Manager)(nil)
+var _ tasks.EventStreamer = (*Manager)(nil)
+var _ tasks.TwoFactorNotifierReceiver = (*Manager)(nil)
 
 type Manager struct {
 	allocatorCtx    context.Context
 	allocatorCancel context.CancelFunc
+	execOpts        []chromedp.ExecAllocatorOption // Allocator options without browser.proxy, for tasks with their own proxy
 	cfg             *config.BrowserConfig
-	logger          *log.Logger
+	logger          *slog.Logger
 	sem             *semaphore.Weighted
 	activeCtxWg     sync.WaitGroup
+	sessionsMu      sync.Mutex
+	sessions        map[string]*session
+	credentialKey   *encryption.CredentialKey
+	credentials     *secrets.Resolver       // Resolves credentials_ref when a task runs
+	templates       *tasks.TemplateStore    // Used to re-login sessions
+	eventSink       tasks.EventSink         // Streams events to task callbacks
+	tfaNotifier     tasks.TwoFactorNotifier // Asks the client for 2FA codes
+	artifactSlots   *semaphore.Weighted     // Bounds concurrent screenshot captures; nil means unbounded
+	artifacts       artifactMetrics
+	store           artifacts.Store     // Where screenshots, downloads, and HAR archives are saved
+	keyring         *encryption.Keyring // Seals profile snapshots; nil stores them in plaintext
+	profilesMu      sync.Mutex          // Serializes changes to profile metadata
+
+	chaos *chaos       // Injects faults when browser.chaos is enabled; nil otherwise
+	pool  *browserPool // Warm browsers when browser.pool.size is set; nil otherwise
+
+	urlPolicy *taskstypes.URLPolicy // security.urlPolicy; nil restricts nothing
+
+	checkPage func(context.Context, chromedp.Action) error // Runs the 2FA prompt checks; chromedp.Run outside tests
 }
 
-func NewManager(cfg *config.BrowserConfig, logger *log.Logger) (*Manager, error) {
+func NewManager(cfg *config.BrowserConfig, logger *slog.Logger) (*Manager, error) {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("headless", cfg.Headless),
 		chromedp.Flag("disable-gpu", true),
@@ -50,83 +84,619 @@ func NewManager(cfg *config.BrowserConfig, logger *log.Logger) (*Manager, error)
 	}
 
 	// Store context and its cancel func
-	allocatorCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	proxy := configProxy(cfg)
+	if err := proxy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid browser.proxy: %w", err)
+	}
+	allocatorCtx, cancel := chromedp.NewExecAllocator(context.Background(), append(opts[:len(opts):len(opts)], proxyFlags(proxy)...)...)
+
+	if cfg.DOMWorkers > 0 {
+		dom.SetDefaultPool(dom.NewPool(cfg.DOMWorkers))
+	}
+	if cfg.DOMCache.MaxEntries > 0 {
+		dom.SetDefaultCache(dom.NewCache(cfg.DOMCache.MaxEntries, cfg.DOMCache.MaxBytes))
+	}
 
-	return &Manager{
+	store, err := artifacts.New(cfg.Artifacts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid browser.artifacts: %w", err)
+	}
+	chaos, err := newChaos(cfg.Chaos)
+	if err != nil {
+		return nil, fmt.Errorf("invalid browser.chaos: %w", err)
+	}
+	if chaos != nil {
+		logger.Warn("Chaos mode is enabled: tasks will see injected latency and failures",
+			"latency_rate", cfg.Chaos.LatencyRate,
+			"navigation_failure_rate", cfg.Chaos.NavigationFailureRate,
+			"slow_selector_rate", cfg.Chaos.SlowSelectorRate,
+		)
+	}
+	var artifactSlots *semaphore.Weighted
+	if cfg.Artifacts.MaxConcurrent > 0 {
+		artifactSlots = semaphore.NewWeighted(int64(cfg.Artifacts.MaxConcurrent))
+	}
+
+	m := &Manager{
 		allocatorCtx:    allocatorCtx,
 		allocatorCancel: cancel,
+		execOpts:        opts,
 		cfg:             cfg,
 		logger:          logger,
 		sem:             semaphore.NewWeighted(int64(cfg.MaxSessions)),
-	}, nil
+		sessions:        make(map[string]*session),
+		artifactSlots:   artifactSlots,
+		store:           store,
+		chaos:           chaos,
+		checkPage:       runCheck,
+	}
+	if cfg.Pool.Size > 0 {
+		m.pool = newBrowserPool(cfg.Pool, m.sem, m.startWarmBrowser, browserHealthy, logger)
+	}
+	return m, nil
 }
 
-// ExecuteTask implements the tasks.BrowserExecutor interface.
-func (m *Manager) ExecuteTask(task *taskstypes.Task) (*taskstypes.TaskResult, error) {
-	// Create a context with timeout for this task execution
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute) // Default timeout
-	defer cancel()
+// SetCredentialKey implements tasks.CredentialKeyReceiver.
+func (m *Manager) SetCredentialKey(key *encryption.CredentialKey) {
+	m.credentialKey = key
+}
+
+// SetCredentialResolver implements tasks.CredentialResolverReceiver.
+func (m *Manager) SetCredentialResolver(resolver *secrets.Resolver) {
+	m.credentials = resolver
+}
+
+// SetTemplates implements tasks.TemplateReceiver.
+func (m *Manager) SetTemplates(templates *tasks.TemplateStore) {
+	m.templates = templates
+}
+
+// SetEventSink implements tasks.EventStreamer.
+func (m *Manager) SetEventSink(sink tasks.EventSink) {
+	m.eventSink = sink
+}
+
+// SetTwoFactorNotifier implements tasks.TwoFactorNotifierReceiver.
+func (m *Manager) SetTwoFactorNotifier(notify tasks.TwoFactorNotifier) {
+	m.tfaNotifier = notify
+}
+
+// DOMCacheStats implements tasks.DOMCacheStatsProvider.
+func (m *Manager) DOMCacheStats() tasks.DOMCacheStats {
+	stats := dom.DefaultCache().Stats()
+	return tasks.DOMCacheStats{
+		Entries:   stats.Entries,
+		Bytes:     stats.Bytes,
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+		Evictions: stats.Evictions,
+	}
+}
 
-	// Acquire a browser slot from our semaphore
-	if err := m.sem.Acquire(ctx, 1); err != nil {
-		return nil, fmt.Errorf("failed to acquire browser slot: %w", err)
+// taskCredentials returns the task's credentials, decrypting sealed ones and
+// looking up referenced ones. Those are kept local to the execution and never
+// stored on the task.
+func (m *Manager) taskCredentials(ctx context.Context, task *taskstypes.Task) (*taskstypes.Credentials, error) {
+	if task.CredentialsRef != "" {
+		return m.credentials.Resolve(ctx, task.CredentialsRef)
 	}
-	defer m.sem.Release(1)
+	if task.SealedCreds == nil {
+		return task.Credentials, nil
+	}
+	if m.credentialKey == nil {
+		return nil, tasks.ErrSealedCredentialsUnsupported
+	}
+	creds, err := m.credentialKey.Open(task.SealedCreds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt task credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// ExecuteTask implements the tasks.BrowserExecutor interface. Cancelling
+// ctx aborts the running action and skips the rest; reports are still
+// collected into the returned result.
+func (m *Manager) ExecuteTask(ctx context.Context, task *taskstypes.Task) (*taskstypes.TaskResult, error) {
+	ctx = logging.WithTask(ctx, task.ID)
+	// Create a context with timeout for this task execution
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute) // Default timeout
+	defer cancel()
 
 	// Track this active browser context for graceful shutdown
 	m.activeCtxWg.Add(1)
 	defer m.activeCtxWg.Done()
 
-	// Create a new browser context for this task
-	browserCtx, browserCancel := chromedp.NewContext(
-		m.allocatorCtx,
-		chromedp.WithLogf(m.logger.Printf),
-	)
-	defer browserCancel()
+	proxy, err := m.taskProxy(task)
+	if err != nil {
+		return nil, err
+	}
+
+	var browserCtx context.Context
+	var result *taskstypes.TaskResult
+	if task.Session != "" {
+		// Run on the named session's page so browser state carries over between tasks.
+		// The session already holds its own browser slot.
+		sess, release, err := m.acquireSession(task.Session)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		browserCtx = sess.ctx
+	} else {
+		// A warm browser brings its own slot; otherwise acquire one from our semaphore
+		var warm *warmBrowser
+		if m.usesPool(task, proxy) {
+			warm = m.pool.get()
+		}
+		if warm == nil {
+			if err := m.acquireSlot(ctx); err != nil {
+				return nil, fmt.Errorf("failed to acquire browser slot: %w", err)
+			}
+			defer m.releaseSlot()
+		}
+
+		// Create a new browser context for this task, on the saved profile if it names one
+		profile := task.Options.Profile
+		var allocatorCtx context.Context
+		var allocatorCancel context.CancelFunc
+		var profileDir string
+		if profile != nil {
+			if profileDir, err = m.restoreProfile(profile.Name); err != nil {
+				return nil, err
+			}
+			defer os.RemoveAll(profileDir)
+			allocatorCtx, allocatorCancel = m.profileAllocator(proxy, profileDir)
+		} else {
+			allocatorCtx, allocatorCancel = m.taskAllocator(proxy)
+		}
+		defer allocatorCancel()
+		var browserCancel context.CancelFunc
+		if warm != nil {
+			// Deferred before browserCancel so the browser is returned once
+			// the task's browser context is gone
+			defer m.pool.put(warm)
+			browserCtx, browserCancel = chromedp.NewContext(warm.ctx, chromedp.WithNewBrowserContext())
+		} else {
+			browserCtx, browserCancel = chromedp.NewContext(
+				allocatorCtx,
+				chromedp.WithLogf(logging.Printf(ctx, m.logger, slog.LevelDebug)),
+				chromedp.WithErrorf(logging.Printf(ctx, m.logger, slog.LevelWarn)),
+			)
+		}
+		defer browserCancel()
+
+		if profile != nil && (profile.Save || m.profileAutoSave(profile.Name)) {
+			// Deferred after browserCancel so it runs first, once every report is collected
+			defer func() {
+				if result == nil || !result.Success {
+					return
+				}
+				// A graceful close makes Chrome flush cookies and storage to disk
+				if err := chromedp.Cancel(browserCtx); err != nil {
+					m.logger.WarnContext(ctx, "Failed to close browser cleanly", "error", err)
+				}
+				info, err := m.saveProfile(task, profile.Name, profileDir)
+				if err != nil {
+					m.logger.ErrorContext(ctx, "Failed to save profile", "profile", profile.Name, "error", err)
+					setCustomData(result, "profile_error", err.Error())
+					return
+				}
+				setCustomData(result, "profile", info)
+			}()
+		}
+	}
+
+	// Actions run on contexts derived from the browser's, not the task's
+	browserCtx = logging.WithTask(browserCtx, task.ID)
+
+	// Start the browser with no deadline: the first Run allocates it and binds
+	// its lifetime to the context, so it must not use a per-action timeout context.
+	if err := chromedp.Run(browserCtx); err != nil {
+		return nil, fmt.Errorf("failed to start browser: %w", err)
+	}
 
 	// Store the task's browser context ID for future reference if needed
+	browserContextID := "unknown" // Placeholder when the target is not known
 	if chromeTarget := chromedp.FromContext(browserCtx); chromeTarget != nil && chromeTarget.Target != nil {
-		task.BrowserContextID = chromeTarget.Target.TargetID.String()
+		browserContextID = chromeTarget.Target.TargetID.String()
 	} else {
-		m.logger.Printf("Warning: Could not get Target ID, browser context might not be fully initialized")
-		// Set a placeholder value instead of nil
-		task.BrowserContextID = "unknown"
+		m.logger.WarnContext(ctx, "Could not get target ID, browser context might not be fully initialized")
+	}
+	task.Update(func(task *taskstypes.Task) {
+		task.BrowserContextID = browserContextID
+	})
+
+	credentials, err := m.taskCredentials(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+
+	restoreOptions, err := m.applyTaskOptions(browserCtx, task.Options)
+	if err != nil {
+		restoreOptions()
+		return nil, err
 	}
+	defer restoreOptions()
 
 	// Initialize the result
-	result := &taskstypes.TaskResult{
+	result = &taskstypes.TaskResult{
 		Success: true,
 		Message: "Task completed successfully",
 	}
+	// Hooks run before each action, e.g. to start a new per-navigation report
+	var beforeAction []func(taskstypes.Action)
 
-	// Execute each action in sequence until done or error
-	for i, action := range task.Actions {
-		// Update current action index
-		task.CurrentAction = i
+	if task.Options.PageWeight {
+		recorder := newPageWeightRecorder()
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		chromedp.ListenTarget(listenCtx, recorder.handleEvent)
+		defer func() {
+			stopListening()
+			setCustomData(result, "page_weight", recorder.report())
+		}()
+		beforeAction = append(beforeAction, func(action taskstypes.Action) {
+			if url, ok := navigationURL(action); ok {
+				recorder.startPage(url)
+			}
+		})
+	}
 
-		// Generate the chromedp action from task action
-		chromedpAction, err := GenerateActionSequence(action, task.Credentials, "")
+	// Every request is held to security.urlPolicy. The first-party filter and
+	// replay resolve paused requests themselves, so they consult the guard.
+	// Each interceptor is also installed on the tabs the page opens.
+	var tabInterceptors []func(context.Context) error
+	guard := newURLGuard(m.urlPolicy)
+	if guard != nil {
+		defer func() {
+			if report := guard.report(); report.Count > 0 {
+				setCustomData(result, "url_policy_blocked", report)
+			}
+		}()
+		beforeAction = append(beforeAction, func(taskstypes.Action) {
+			guard.beforeAction()
+		})
+	}
+
+	if task.Options.FirstPartyOnly {
+		filter := newFirstPartyFilter(task.Options.ThirdPartyAllowlist)
+		filter.guard = guard
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		if err := filter.install(listenCtx); err != nil {
+			stopListening()
+			return nil, fmt.Errorf("failed to enable first-party-only mode: %w", err)
+		}
+		tabInterceptors = append(tabInterceptors, filter.install)
+		defer func() {
+			if err := filter.uninstall(browserCtx); err != nil {
+				m.logger.WarnContext(ctx, "Failed to disable request interception", "error", err)
+			}
+			stopListening()
+			setCustomData(result, "blocked_requests", filter.report())
+		}()
+		beforeAction = append(beforeAction, func(action taskstypes.Action) {
+			if url, ok := navigationURL(action); ok {
+				filter.setSite(url)
+			}
+		})
+	}
+
+	if opts := task.Options.Replay; opts != nil {
+		replay, err := m.loadReplay(ctx, *opts)
 		if err != nil {
-			result.Success = false
-			result.Message = "Failed to generate action"
-			result.Error = err.Error()
-			return result, err
+			return nil, err
+		}
+		replay.guard = guard
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		if err := replay.install(listenCtx); err != nil {
+			stopListening()
+			return nil, fmt.Errorf("failed to enable network replay: %w", err)
+		}
+		tabInterceptors = append(tabInterceptors, replay.install)
+		defer func() {
+			if err := replay.uninstall(browserCtx); err != nil {
+				m.logger.WarnContext(ctx, "Failed to disable request interception", "error", err)
+			}
+			stopListening()
+			setCustomData(result, "replay", replay.report())
+		}()
+	}
+
+	if guard != nil && !task.Options.FirstPartyOnly && task.Options.Replay == nil {
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		if err := guard.install(listenCtx); err != nil {
+			stopListening()
+			return nil, fmt.Errorf("failed to enforce the URL policy: %w", err)
 		}
+		tabInterceptors = append(tabInterceptors, guard.install)
+		defer func() {
+			if err := guard.uninstall(browserCtx); err != nil {
+				m.logger.WarnContext(ctx, "Failed to disable request interception", "error", err)
+			}
+			stopListening()
+		}()
+	}
 
-		// We might need to handle 2FA during execution
-		if action.Type == taskstypes.ActionNavigate || action.Type == taskstypes.ActionClick {
-			// Execute with potential 2FA checks
-			err = m.executeWithPotential2FA(browserCtx, chromedpAction, task)
+	if proxy.Username != "" {
+		// Installed after the request filters so its Fetch.enable, which
+		// also handles auth challenges, is the one in effect
+		auth := newProxyAuth(proxy, !task.Options.FirstPartyOnly && task.Options.Replay == nil && guard == nil)
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		if err := auth.install(listenCtx); err != nil {
+			stopListening()
+			return nil, fmt.Errorf("failed to enable proxy authentication: %w", err)
+		}
+		tabInterceptors = append(tabInterceptors, auth.install)
+		defer func() {
+			if err := auth.uninstall(browserCtx); err != nil {
+				m.logger.WarnContext(ctx, "Failed to disable proxy authentication", "error", err)
+			}
+			stopListening()
+		}()
+	}
+
+	if task.Options.SecurityFindings {
+		collector := newFindingsCollector()
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		if err := collector.install(listenCtx); err != nil {
+			stopListening()
+			return nil, fmt.Errorf("failed to enable security findings: %w", err)
+		}
+		defer func() {
+			stopListening()
+			setCustomData(result, "security_findings", collector.report())
+		}()
+	}
+
+	if task.Options.TrackNavigations {
+		recorder := &navigationRecorder{}
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		if err := recorder.install(listenCtx); err != nil {
+			stopListening()
+			return nil, fmt.Errorf("failed to enable navigation tracking: %w", err)
+		}
+		defer func() {
+			stopListening()
+			setCustomData(result, "navigations", recorder.report())
+		}()
+		beforeAction = append(beforeAction, func(action taskstypes.Action) {
+			recorder.beforeAction(task.CurrentAction, action)
+		})
+	}
+
+	if task.Options.Console {
+		recorder := &consoleRecorder{}
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		recorder.install(listenCtx)
+		defer func() {
+			stopListening()
+			entries, dropped := recorder.report()
+			setCustomData(result, "console", entries)
+			if dropped > 0 {
+				setCustomData(result, "console_dropped", dropped)
+			}
+		}()
+		beforeAction = append(beforeAction, func(taskstypes.Action) {
+			recorder.beforeAction(task.CurrentAction)
+		})
+	}
+
+	if policy := task.Options.Cookies; policy != nil {
+		jar := newCookieJar(*policy)
+		if err := jar.apply(browserCtx); err != nil {
+			return nil, fmt.Errorf("failed to apply cookie policy: %w", err)
+		}
+		defer func() {
+			if err := jar.finish(browserCtx); err != nil {
+				m.logger.WarnContext(ctx, "Failed to finish cookie policy", "error", err)
+			}
+			setCustomData(result, "cookie_policy", jar.report)
+		}()
+		if policy.ReadOnly {
+			beforeAction = append(beforeAction, func(taskstypes.Action) {
+				if err := jar.revert(browserCtx); err != nil {
+					m.logger.WarnContext(ctx, "Failed to restore read-only cookies", "error", err)
+				}
+			})
+		}
+	}
+
+	if capture := task.Options.Network; capture != nil {
+		recorder, err := newNetworkRecorder(browserCtx, *capture)
+		if err != nil {
+			return nil, err
+		}
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		chromedp.ListenTarget(listenCtx, recorder.handleEvent)
+		streamDone := make(chan struct{})
+		if capture.Stream && m.eventSink != nil {
+			go func() {
+				defer close(streamDone)
+				recorder.stream(listenCtx, task, m.eventSink)
+			}()
 		} else {
-			// Normal execution for other action types
-			err = chromedp.Run(browserCtx, chromedpAction)
+			close(streamDone)
+		}
+		defer func() {
+			entries := recorder.report()
+			stopListening()
+			<-streamDone
+			setCustomData(result, "network", entries)
+		}()
+		beforeAction = append(beforeAction, func(taskstypes.Action) {
+			recorder.beforeAction(task.CurrentAction)
+		})
+	}
+
+	if task.Options.HAR {
+		// Recorded separately from Network, whose filters would leave gaps in the archive
+		// Bodies follow the network option's settings so the archive can be replayed
+		capture := taskstypes.NetworkCapture{}
+		if n := task.Options.Network; n != nil {
+			capture.Bodies, capture.MaxBodyBytes, capture.BodyRules = n.Bodies, n.MaxBodyBytes, n.BodyRules
+		}
+		recorder, err := newNetworkRecorder(browserCtx, capture)
+		if err != nil {
+			return nil, err
 		}
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		chromedp.ListenTarget(listenCtx, recorder.handleEvent)
+		defer func() {
+			entries := recorder.report()
+			stopListening()
+			// The task's context may already be done; the archive is still wanted
+			info, err := m.saveHAR(context.Background(), task, entries)
+			if err != nil {
+				m.logger.ErrorContext(ctx, "Failed to save HAR", "error", err)
+				return
+			}
+			setCustomData(result, "har", info)
+		}()
+		beforeAction = append(beforeAction, func(taskstypes.Action) {
+			recorder.beforeAction(task.CurrentAction)
+		})
+	}
 
-		// Handle action execution failure
+	if task.Options.Trace {
+		// Network entries are recorded as for HAR, so snapshots can be
+		// rendered with the response bodies the network option fetches
+		capture := taskstypes.NetworkCapture{}
+		if n := task.Options.Network; n != nil {
+			capture.Bodies, capture.MaxBodyBytes, capture.BodyRules = n.Bodies, n.MaxBodyBytes, n.BodyRules
+		}
+		network, err := newNetworkRecorder(browserCtx, capture)
 		if err != nil {
+			return nil, err
+		}
+		recorder := newTraceRecorder(task, time.Now())
+		listenCtx, stopListening := context.WithCancel(browserCtx)
+		chromedp.ListenTarget(listenCtx, network.handleEvent)
+		recorder.install(listenCtx)
+		capturePage := func() *tracePage {
+			if !recorder.wantsSnapshot() {
+				return nil
+			}
+			pg, err := captureTracePage(browserCtx)
+			if err != nil {
+				m.logger.DebugContext(ctx, "Skipping trace snapshot", "error", err)
+			}
+			return pg
+		}
+		defer func() {
+			recorder.finish(result, capturePage())
+			entries := network.report()
+			stopListening()
+			// The task's context may already be done; the trace is still wanted
+			info, err := m.saveTrace(context.Background(), task, recorder, entries)
+			if err != nil {
+				m.logger.ErrorContext(ctx, "Failed to save trace", "error", err)
+				return
+			}
+			setCustomData(result, "trace", info)
+		}()
+		beforeAction = append(beforeAction, func(action taskstypes.Action) {
+			network.beforeAction(task.CurrentAction)
+			recorder.beforeAction(task.CurrentAction, action, capturePage())
+		})
+	}
+
+	// Dialogs are always answered, since an open one blocks the page
+	dialogs := newDialogHandler(task.Options.Dialogs, logging.Printf(ctx, m.logger, slog.LevelInfo))
+	dialogsCtx, stopDialogs := context.WithCancel(browserCtx)
+	dialogs.install(dialogsCtx)
+	defer func() {
+		stopDialogs()
+		if report := dialogs.report(); report != nil {
+			setCustomData(result, "dialogs", report)
+		}
+	}()
+	beforeAction = append(beforeAction, func(taskstypes.Action) {
+		dialogs.beforeAction(task.CurrentAction)
+	})
+
+	// Tabs and popups the page opens can be switched to with switch_tab
+	tabs := newTabSet(browserCtx)
+	tabs.attached = func(tabCtx context.Context) error {
+		dialogs.install(tabCtx)
+		for _, install := range tabInterceptors {
+			if err := install(tabCtx); err != nil {
+				m.logger.WarnContext(ctx, "Failed to intercept a tab's requests, closing it", "error", err)
+				return err
+			}
+		}
+		return nil
+	}
+	if guard != nil {
+		// The URL a tab was opened with is checked again before switching to it
+		tabs.checkURL = func(ctx context.Context, url string) error {
+			return m.urlPolicy.CheckResolved(ctx, url, true)
+		}
+	}
+	tabsCtx, stopTabs := context.WithCancel(browserCtx)
+	tabs.install(tabsCtx)
+	defer func() {
+		stopTabs()
+		closeCtx, cancel := context.WithTimeout(browserCtx, 5*time.Second)
+		tabs.closeAll(closeCtx)
+		cancel()
+		if report := tabs.report(); report != nil {
+			setCustomData(result, "tabs", report)
+		}
+	}()
+	beforeAction = append(beforeAction, func(taskstypes.Action) {
+		tabs.beforeAction(task.CurrentAction)
+	})
+
+	// Actions run on the browser context, but stop when the task's context
+	// does. The browser context itself outlives them so reports can still be
+	// collected and sessions stay open.
+	actionCtx, stopActions := context.WithCancel(browserCtx)
+	defer stopActions()
+	defer context.AfterFunc(ctx, stopActions)()
+
+	// Later actions can reference earlier actions' output, e.g. {{actions.2.result}}
+	outputs := newActionOutputs()
+
+	// Failures caused by a renderer crash are reported as such, not as the action's
+	crashes := &crashWatch{}
+	crashCtx, stopCrashWatch := context.WithCancel(browserCtx)
+	defer stopCrashWatch()
+	crashes.install(crashCtx)
+
+	for i, action := range task.Actions {
+		if err := ctx.Err(); err != nil {
 			result.Success = false
-			result.Message = fmt.Sprintf("Failed on action %d: %s", i, action.Type)
+			result.Message = fmt.Sprintf("Task stopped before action %d", i)
 			result.Error = err.Error()
+			code, _ := crashes.taskFailureCode(ctx, browserCtx)
+			recordFailure(result, i, action, code)
+			return result, err
+		}
+		// Update current action index
+		task.SetCurrentAction(i)
+		started := time.Now()
+		err := m.runAction(actionCtx, task, tabs, outputs, i, action, credentials, result, beforeAction)
+		if task.Options.ScreenshotEachStep {
+			m.recordStep(actionCtx, task, tabs, i, action, started, err, result)
+		}
+		if err != nil {
+			if code, ok := crashes.taskFailureCode(ctx, browserCtx); ok {
+				result.ErrorCode = code
+			} else if dialogs.blocking() {
+				result.ErrorCode = taskstypes.ErrorDialogUnhandled
+			} else if botProtectionCheck(result.ErrorCode) {
+				// A challenge page has none of the elements the action expected
+				tabCtx, leaveTab := tabs.on(actionCtx)
+				if vendor := detectBotProtection(tabCtx); vendor != "" {
+					result.ErrorCode = taskstypes.ErrorBotProtection
+					result.Message += fmt.Sprintf(" (the page is a bot protection challenge, vendor: %s)", vendor)
+				}
+				leaveTab()
+			}
+			if blocked := guard.blockedNavigation(); blocked != nil {
+				// The page failed to load because the policy refused it, e.g. on a redirect
+				result.ErrorCode = taskstypes.ErrorURLBlocked
+				result.Error = blocked.Error()
+			}
 			return result, err
 		}
 	}
@@ -135,26 +705,209 @@ func (m *Manager) ExecuteTask(task *taskstypes.Task) (*taskstypes.TaskResult, er
 	return result, nil
 }
 
-// executeWithPotential2FA runs an action and checks for 2FA prompts
-func (m *Manager) executeWithPotential2FA(ctx context.Context, action chromedp.Action, task *taskstypes.Task) error {
+// runAction executes one action on the active tab, or its else branch when
+// its condition is not met, after filling in references to earlier outputs.
+// On failure it records the error in result and returns it.
+func (m *Manager) runAction(ctx context.Context, task *taskstypes.Task, tabs *tabSet, outputs *actionOutputs, i int, action taskstypes.Action, credentials *taskstypes.Credentials, result *taskstypes.TaskResult, beforeAction []func(taskstypes.Action)) error {
+	ctx = logging.WithAction(ctx, i)
+	tabCtx, leaveTab := tabs.on(ctx)
+	defer leaveTab()
+	tabCtx = logging.WithAction(tabCtx, i) // Other tabs' contexts don't derive from ctx
+
+	if action.If != nil {
+		met, err := m.evaluateCondition(tabCtx, *action.If)
+		if err != nil {
+			result.Success = false
+			result.Message = fmt.Sprintf("Failed to evaluate condition of action %d: %s", i, action.Type)
+			result.Error = err.Error()
+			recordFailure(result, i, action, classifyActionError(tabCtx, action, err))
+			return err
+		}
+		recordCondition(result, i, *action.If, met)
+		if !met {
+			for _, branch := range action.Else {
+				if err := m.runAction(ctx, task, tabs, outputs, i, branch, credentials, result, beforeAction); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	action, err := outputs.resolve(action, result)
+	if err != nil {
+		result.Success = false
+		result.Message = fmt.Sprintf("Failed to resolve references in action %d: %s", i, action.Type)
+		result.Error = err.Error()
+		recordFailure(result, i, action, taskstypes.ErrorInvalidAction)
+		return err
+	}
+
+	if err := m.checkNavigation(tabCtx, action); err != nil {
+		result.Success = false
+		result.Message = fmt.Sprintf("Action %d (%s) was blocked by the URL policy", i, action.Type)
+		result.Error = err.Error()
+		recordFailure(result, i, action, taskstypes.ErrorURLBlocked)
+		return err
+	}
+
+	for _, hook := range beforeAction {
+		hook(action)
+	}
+
+	// Generate the chromedp action from task action. Actions that need
+	// executor state such as the result or config are built by the Manager.
+	var chromedpAction chromedp.Action
+	var output interface{}
+	hasOutput := false
+	switch action.Type {
+	case taskstypes.ActionDownload:
+		chromedpAction, err = m.downloadAction(task, action, result)
+	case taskstypes.ActionScreenshot:
+		chromedpAction, err = m.screenshotAction(task, i, action, result)
+	case taskstypes.ActionSecurity:
+		chromedpAction, err = m.securityReportAction(action, result)
+	case taskstypes.ActionCloaking:
+		chromedpAction, err = m.cloakingCheckAction(action, task.Options, result)
+	case taskstypes.ActionExtract:
+		chromedpAction, err = m.extractAction(i, action, result)
+	case taskstypes.ActionChangePass:
+		chromedpAction, err = m.changePasswordAction(task, i, action, credentials, result)
+	case taskstypes.ActionSwitchTab:
+		chromedpAction = tabs.switchAction(action, result)
+	case taskstypes.ActionCloseTab:
+		chromedpAction = tabs.closeAction(action, result)
+	case taskstypes.ActionGetDOM, taskstypes.ActionRunScript, taskstypes.ActionFindText:
+		chromedpAction, err = GenerateOutputAction(action, &output)
+		hasOutput = true
+	default:
+		chromedpAction, err = GenerateActionSequence(action, credentials, "")
+	}
+	if err == nil && action.Repeat != nil {
+		chromedpAction, err = m.repeatAction(chromedpAction, *action.Repeat)
+	}
+	if err != nil {
+		result.Success = false
+		result.Message = "Failed to generate action"
+		result.Error = err.Error()
+		recordFailure(result, i, action, taskstypes.ErrorInvalidAction)
+		return err
+	}
+	chromedpAction = m.chaos.inject(i, action, chromedpAction, result)
+
+	timeout := m.actionTimeout(action)
+
+	execute := func() error {
+		// We might need to handle 2FA during execution
+		if action.Type == taskstypes.ActionNavigate || action.Type == taskstypes.ActionClick {
+			// Execute with potential 2FA checks
+			return m.executeWithPotential2FA(tabCtx, chromedpAction, timeout, task)
+		}
+		// Normal execution for other action types
+		return runWithTimeout(tabCtx, chromedpAction, timeout)
+	}
+	err = execute()
+	if login := m.sessionLogin(task); login != nil {
+		err = m.reloginAndRetry(tabCtx, i, action, login, result, err, execute)
+	}
+
+	// Handle action execution failure
+	if err != nil {
+		result.Success = false
+		result.Message = fmt.Sprintf("Failed on action %d: %s", i, action.Type)
+		if errors.Is(err, ErrActionTimeout) {
+			result.TimedOut = true
+			result.Message = fmt.Sprintf("Action %d (%s) timed out after %s", i, action.Type, timeout)
+		}
+		result.Error = err.Error()
+		recordFailure(result, i, action, classifyActionError(tabCtx, action, err))
+		return err
+	}
+	if hasOutput {
+		outputs.set(i, output)
+		result.Data = output
+	}
+	return nil
+}
+
+// navigationURL returns the URL an action navigates the page to, if any.
+func navigationURL(action taskstypes.Action) (string, bool) {
+	switch action.Type {
+	case taskstypes.ActionNavigate, taskstypes.ActionSecurity, taskstypes.ActionCloaking:
+		return action.Value, action.Value != ""
+	case taskstypes.ActionDownload:
+		// A download with a selector clicks it rather than loading the value
+		return action.Value, action.Selector == "" && action.Value != ""
+	}
+	return "", false
+}
+
+// setCustomData attaches a named report to the task result.
+func setCustomData(result *taskstypes.TaskResult, key string, value interface{}) {
+	if result.CustomData == nil {
+		result.CustomData = make(map[string]interface{})
+	}
+	result.CustomData[key] = value
+}
+
+// actionTimeout returns the deadline for one action: its own Timeout, or
+// browser.actionTimeout. A wait_delay without its own timeout gets the delay
+// on top of the default so the wait itself never trips it. Zero means no limit.
+func (m *Manager) actionTimeout(action taskstypes.Action) time.Duration {
+	if action.Timeout > 0 {
+		return action.Timeout
+	}
+	timeout := m.cfg.ActionTimeout
+	if timeout > 0 && action.Type == taskstypes.ActionWaitDelay {
+		if delay, err := time.ParseDuration(action.Value); err == nil {
+			timeout += delay
+		}
+	}
+	return timeout
+}
+
+// runWithTimeout runs an action under its own deadline and reports an
+// expired deadline as ErrActionTimeout rather than a generic context error.
+func runWithTimeout(ctx context.Context, action chromedp.Action, timeout time.Duration) error {
+	return withTimeout(ctx, timeout, func(ctx context.Context) error {
+		return chromedp.Run(ctx, action)
+	})
+}
+
+// withTimeout calls fn under its own deadline, as runWithTimeout runs an action.
+func withTimeout(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	actionCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(actionCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(actionCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s", ErrActionTimeout, timeout)
+	}
+	return err
+}
+
+// executeWithPotential2FA runs an action and checks for 2FA prompts.
+// The timeout applies to the action, the prompt check, and entering the
+// code separately, but not to waiting for a 2FA code.
+func (m *Manager) executeWithPotential2FA(ctx context.Context, action chromedp.Action, timeout time.Duration, task *taskstypes.Task) error {
 	// Run the action first
-	if err := chromedp.Run(ctx, action); err != nil {
+	if err := runWithTimeout(ctx, action, timeout); err != nil {
 		return err
 	}
 
 	// After navigation or click, check if we now have a 2FA prompt
-	if is2FA, promptType, err := m.detect2FAPrompt(ctx); err != nil {
-		m.logger.Printf("Error checking for 2FA: %v", err)
+	if is2FA, promptType, err := m.detect2FAPromptWithin(ctx, timeout); err != nil {
+		m.logger.WarnContext(ctx, "Error checking for 2FA", "error", err)
 	} else if is2FA {
-		m.logger.Printf("Detected 2FA prompt type: %s", promptType)
-
-		// Update task status to waiting for 2FA
-		task.Status = taskstypes.StatusWaitingFor2FA
+		m.logger.InfoContext(ctx, "Detected 2FA prompt", "prompt", promptType)
 
-		// Wait for 2FA code to be provided
-		code, err := task.WaitForTFACode(ctx)
+		code, err := m.twoFactorCode(ctx, task, promptType, timeout)
 		if err != nil {
-			return fmt.Errorf("2FA code wait error: %w", err)
+			return err
 		}
 
 		// We have a code, let's try to input it
@@ -173,23 +926,81 @@ func (m *Manager) executeWithPotential2FA(ctx context.Context, action chromedp.A
 
 		// Enter the code
 		if selector != "" {
-			if err := chromedp.Run(ctx, chromedp.Tasks{
+			if err := runWithTimeout(ctx, chromedp.Tasks{
 				chromedp.WaitVisible(selector),
 				chromedp.Clear(selector),
 				chromedp.SendKeys(selector, code),
 				chromedp.Submit(selector),
-			}); err != nil {
+			}, timeout); err != nil {
 				return fmt.Errorf("failed to input 2FA code: %w", err)
 			}
 		}
 
 		// Update task status back to running
-		task.Status = taskstypes.StatusRunning
+		task.UpdateStatus(taskstypes.StatusRunning)
 	}
 
 	return nil
 }
 
+// twoFactorCode returns the code for a 2FA prompt. App-based 2FA with a
+// TOTP secret gets a generated code at once; otherwise the client is asked
+// for one and the task waits for it to be provided through the API.
+func (m *Manager) twoFactorCode(ctx context.Context, task *taskstypes.Task, promptType string, timeout time.Duration) (string, error) {
+	if tfa := task.Snapshot().TwoFactorAuth; tfa.GeneratesCodes() {
+		code, err := auth.GenerateTOTP(tfa.Secret)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate 2FA code: %w", err)
+		}
+		m.logger.InfoContext(ctx, "Answering 2FA prompt with a generated TOTP code")
+		return code, nil
+	}
+
+	// Update task status to waiting for 2FA, with a channel ready for the code
+	task.Update(func(task *taskstypes.Task) {
+		task.Status = taskstypes.StatusWaitingFor2FA
+		task.UpdatedAt = time.Now()
+		if task.TfaCodeChan == nil {
+			task.TfaCodeChan = make(chan string, 1)
+		}
+	})
+	if m.tfaNotifier != nil {
+		prompt := tasks.TwoFactorPrompt{Kind: promptType}
+		if err := runWithTimeout(ctx, chromedp.Location(&prompt.PageURL), timeout); err != nil {
+			m.logger.DebugContext(ctx, "Could not read the 2FA page URL", "error", err)
+		}
+		m.tfaNotifier(task, prompt)
+	}
+
+	// Wait for 2FA code to be provided
+	code, err := task.WaitForTFACode(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			// WaitForTFACode gave up on its own deadline
+			return "", fmt.Errorf("%w: %v", ErrTFATimeout, err)
+		}
+		return "", fmt.Errorf("2FA code wait error: %w", err)
+	}
+	return code, nil
+}
+
+// detect2FAPromptWithin bounds the 2FA prompt check so a page that stops
+// responding after an action cannot hold the task until its overall deadline.
+func (m *Manager) detect2FAPromptWithin(ctx context.Context, timeout time.Duration) (found bool, details string, err error) {
+	err = withTimeout(ctx, timeout, func(ctx context.Context) error {
+		found, details, err = m.detect2FAPrompt(ctx)
+		return err
+	})
+	return found, details, err
+}
+
+// runCheck runs a read-only page check.
+func runCheck(ctx context.Context, action chromedp.Action) error {
+	return chromedp.Run(ctx, action)
+}
+
+// detect2FAPrompt looks for a 2FA prompt on the page. It gives up with the
+// context's error once ctx is done, rather than trying the remaining checks.
 func (m *Manager) detect2FAPrompt(ctx context.Context) (bool, string, error) {
 	tfaSelectors := []string{
 		"input[name='otp']", "input[name='security_code']", "input[autocomplete='one-time-code']",
@@ -205,18 +1016,21 @@ func (m *Manager) detect2FAPrompt(ctx context.Context) (bool, string, error) {
 	// Check selectors first
 	for _, selector := range tfaSelectors {
 		checkAction := dom.IsElementPresentAction(selector, &isPresent)
-		if err := chromedp.Run(ctx, checkAction); err == nil && isPresent {
+		if err := m.checkPage(ctx, checkAction); err == nil && isPresent {
 			details = fmt.Sprintf("Detected via selector: %s", selector)
 			return true, details, nil
 		} else if err != nil {
-			m.logger.Printf("Error checking 2FA selector %s: %v", selector, err) // Log non-critical error
+			if ctx.Err() != nil {
+				return false, "", ctx.Err()
+			}
+			m.logger.DebugContext(ctx, "Error checking 2FA selector", "selector", selector, "error", err) // Non-critical
 		}
 	}
 
 	// Check text content if no selector matched
 	var pageText string
 	getTextAction := dom.GetTextContentAction(&pageText)
-	if err := chromedp.Run(ctx, getTextAction); err == nil {
+	if err := m.checkPage(ctx, getTextAction); err == nil {
 		pageTextLower := strings.ToLower(pageText)
 		for _, pattern := range tfaTextPatterns {
 			if strings.Contains(pageTextLower, pattern) {
@@ -224,8 +1038,10 @@ func (m *Manager) detect2FAPrompt(ctx context.Context) (bool, string, error) {
 				return true, details, nil
 			}
 		}
+	} else if ctx.Err() != nil {
+		return false, "", ctx.Err()
 	} else {
-		m.logger.Printf("Error getting page text for 2FA check: %v", err) // Log non-critical error
+		m.logger.DebugContext(ctx, "Error getting page text for 2FA check", "error", err) // Non-critical
 	}
 
 	return false, "", nil // No prompt detected
@@ -233,7 +1049,12 @@ func (m *Manager) detect2FAPrompt(ctx context.Context) (bool, string, error) {
 
 // Shutdown implements the tasks.BrowserExecutor interface.
 func (m *Manager) Shutdown(ctx context.Context) error {
-	m.logger.Println("Shutting down browser manager...")
+	m.logger.InfoContext(ctx, "Shutting down browser manager")
+
+	m.closeAllSessions()
+	if m.pool != nil {
+		m.pool.close()
+	}
 
 	// Signal allocator context to cancel
 	if m.allocatorCancel != nil {
@@ -249,14 +1070,14 @@ func (m *Manager) Shutdown(ctx context.Context) error {
 
 	select {
 	case <-shutdownComplete:
-		m.logger.Println("All active browser sessions have finished.")
+		m.logger.InfoContext(ctx, "All active browser sessions have finished")
 	case <-ctx.Done():
-		m.logger.Println("Shutdown timeout reached while waiting for active browser sessions.")
+		m.logger.WarnContext(ctx, "Shutdown timeout reached while waiting for active browser sessions")
 		return ctx.Err()
 	}
 
 	// Allocator shutdown is handled by cancelling its context.
-	m.logger.Println("Browser manager shutdown complete.")
+	m.logger.InfoContext(ctx, "Browser manager shutdown complete")
 	return nil
 }
 
@@ -0,0 +1,87 @@
+package browser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/auth"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionTimeout(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{ActionTimeout: 30 * time.Second}}
+
+	assert.Equal(t, 30*time.Second, m.actionTimeout(taskstypes.Action{Type: taskstypes.ActionClick}))
+	assert.Equal(t, 2*time.Second, m.actionTimeout(taskstypes.Action{Type: taskstypes.ActionClick, Timeout: 2 * time.Second}))
+	assert.Equal(t, 40*time.Second, m.actionTimeout(taskstypes.Action{Type: taskstypes.ActionWaitDelay, Value: "10s"}),
+		"wait_delay gets its delay on top of the default")
+
+	m.cfg.ActionTimeout = 0
+	assert.Zero(t, m.actionTimeout(taskstypes.Action{Type: taskstypes.ActionClick}))
+}
+
+func TestDetect2FAPromptWithin_Timeout(t *testing.T) {
+	// A page that never answers, so no prompt ever appears
+	checks := 0
+	m := &Manager{logger: logging.Discard(), checkPage: func(ctx context.Context, _ chromedp.Action) error {
+		checks++
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	start := time.Now()
+	found, _, err := m.detect2FAPromptWithin(context.Background(), 50*time.Millisecond)
+
+	assert.Less(t, time.Since(start), time.Second, "the check returns once the action timeout expires")
+	assert.ErrorIs(t, err, ErrActionTimeout)
+	assert.False(t, found)
+	assert.Equal(t, 1, checks, "the remaining checks are skipped")
+
+	// Cancelling the task is not reported as a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = m.detect2FAPromptWithin(ctx, time.Minute)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrActionTimeout)
+}
+
+func TestTwoFactorCode(t *testing.T) {
+	m := &Manager{logger: logging.Discard()}
+	ctx := context.Background()
+
+	// App-based 2FA with a secret does not wait for anyone
+	task := &taskstypes.Task{Status: taskstypes.StatusRunning, TwoFactorAuth: taskstypes.TwoFactorAuthInfo{
+		Provider: taskstypes.TFAProviderApp,
+		Secret:   "JBSWY3DPEHPK3PXP",
+	}}
+	code, err := m.twoFactorCode(ctx, task, "input", time.Second)
+	require.NoError(t, err)
+	valid, err := auth.ValidateTOTP(code, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.Equal(t, taskstypes.StatusRunning, task.CurrentStatus())
+
+	// Other providers ask the client for the code and wait for it
+	var prompts []tasks.TwoFactorPrompt
+	m.SetTwoFactorNotifier(func(task *taskstypes.Task, prompt tasks.TwoFactorPrompt) {
+		assert.Equal(t, taskstypes.StatusWaitingFor2FA, task.CurrentStatus(), "clients are told only once the code can be provided")
+		prompts = append(prompts, prompt)
+	})
+	task = &taskstypes.Task{Status: taskstypes.StatusRunning, TwoFactorAuth: taskstypes.TwoFactorAuthInfo{
+		Provider: taskstypes.TFAProviderSMS,
+		Secret:   "JBSWY3DPEHPK3PXP",
+	}, TfaCodeChan: make(chan string, 1)}
+	task.TfaCodeChan <- "123456"
+	code, err = m.twoFactorCode(ctx, task, "input", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "123456", code)
+	assert.Equal(t, taskstypes.StatusWaitingFor2FA, task.CurrentStatus())
+	assert.Equal(t, []tasks.TwoFactorPrompt{{Kind: "input"}}, prompts, "the page URL is left out when it cannot be read")
+}
@@ -0,0 +1,250 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	cdpbrowser "github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+const (
+	maxNavigationsRecorded = 200
+	// cloakingSettleDelay gives client-side redirects time to fire after load.
+	cloakingSettleDelay = time.Second
+	// cloakingSimilarityThreshold is the word-overlap below which content
+	// served to two profiles counts as different.
+	cloakingSimilarityThreshold = 0.6
+)
+
+// NavigationEvent is a navigation the page made on its own or in response to an action.
+type NavigationEvent struct {
+	URL       string `json:"url"`
+	From      string `json:"from,omitempty"`   // Source URL for HTTP redirects
+	Reason    string `json:"reason"`           // CDP navigation reason, or "http_redirect"
+	Status    int64  `json:"status,omitempty"` // HTTP redirect status
+	MainFrame bool   `json:"main_frame"`
+	Automatic bool   `json:"automatic"` // Not triggered by a click, type, select, or login action
+	Action    int    `json:"action"`    // Index of the action running when it happened
+}
+
+// navigationRecorder records renderer-initiated navigations (meta refresh,
+// script location changes, link clicks) and HTTP redirects of documents.
+type navigationRecorder struct {
+	mu          sync.Mutex
+	mainFrame   cdp.FrameID
+	action      int
+	interacting bool
+	events      []NavigationEvent
+}
+
+func (r *navigationRecorder) install(ctx context.Context) error {
+	if err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		tree, err := page.GetFrameTree().Do(ctx)
+		if err != nil {
+			return err
+		}
+		r.mainFrame = tree.Frame.ID
+		return nil
+	})); err != nil {
+		return err
+	}
+	chromedp.ListenTarget(ctx, r.handleEvent)
+	return nil
+}
+
+// beforeAction notes which action is running so navigations can be attributed.
+func (r *navigationRecorder) beforeAction(index int, action taskstypes.Action) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.action = index
+	switch action.Type {
+	case taskstypes.ActionClick, taskstypes.ActionInput, taskstypes.ActionSelect, taskstypes.ActionLogin, taskstypes.ActionDownload, taskstypes.ActionChangePass:
+		r.interacting = true
+	default:
+		r.interacting = false
+	}
+}
+
+func (r *navigationRecorder) handleEvent(ev interface{}) {
+	switch ev := ev.(type) {
+	case *page.EventFrameRequestedNavigation:
+		refresh := ev.Reason == page.ClientNavigationReasonMetaTagRefresh || ev.Reason == page.ClientNavigationReasonHTTPHeaderRefresh
+		r.add(NavigationEvent{
+			URL:       ev.URL,
+			Reason:    string(ev.Reason),
+			MainFrame: ev.FrameID == r.mainFrame,
+		}, refresh)
+	case *network.EventRequestWillBeSent:
+		if ev.RedirectResponse == nil || ev.Type != network.ResourceTypeDocument || ev.Request == nil {
+			return
+		}
+		r.add(NavigationEvent{
+			URL:       ev.Request.URL,
+			From:      ev.RedirectResponse.URL,
+			Reason:    "http_redirect",
+			Status:    ev.RedirectResponse.Status,
+			MainFrame: ev.FrameID == r.mainFrame,
+		}, false)
+	}
+}
+
+func (r *navigationRecorder) add(event NavigationEvent, alwaysAutomatic bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) >= maxNavigationsRecorded {
+		return
+	}
+	event.Action = r.action
+	event.Automatic = alwaysAutomatic || !r.interacting
+	r.events = append(r.events, event)
+}
+
+func (r *navigationRecorder) report() []NavigationEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]NavigationEvent{}, r.events...)
+}
+
+// cloakingProfiles are the clients a cloaking check impersonates. The first
+// profile keeps the browser's own user agent and is the baseline.
+var cloakingProfiles = []struct {
+	Name      string
+	UserAgent string
+}{
+	{Name: "desktop"},
+	{Name: "googlebot", UserAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+	{Name: "mobile", UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"},
+}
+
+// ProfileView is what one client profile was served.
+type ProfileView struct {
+	Profile    string  `json:"profile"`
+	UserAgent  string  `json:"user_agent"`
+	FinalURL   string  `json:"final_url"`
+	Title      string  `json:"title"`
+	TextLength int     `json:"text_length"`
+	Similarity float64 `json:"similarity"` // Word overlap with the baseline, 0-1
+	Differs    bool    `json:"differs"`
+
+	words map[string]struct{}
+}
+
+// CloakingReport compares content served to different client profiles.
+type CloakingReport struct {
+	URL       string        `json:"url"`
+	Suspected bool          `json:"suspected"`
+	Profiles  []ProfileView `json:"profiles"`
+}
+
+// cloakingCheckAction loads Value once per profile and reports whether any
+// profile was redirected elsewhere or served substantially different text.
+// The desktop profile uses the task's user agent and language, if set, and
+// they are restored afterwards.
+func (m *Manager) cloakingCheckAction(action taskstypes.Action, opts taskstypes.TaskOptions, result *taskstypes.TaskResult) (chromedp.Action, error) {
+	if action.Value == "" {
+		return nil, fmt.Errorf("cloaking_check action requires a URL value")
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, _, _, defaultUA, _, err := cdpbrowser.GetVersion().Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read browser user agent: %w", err)
+		}
+		desktop := taskUserAgent(opts, defaultUA)
+		defer func() {
+			_ = desktop.Do(ctx)
+		}()
+
+		views := make([]ProfileView, 0, len(cloakingProfiles))
+		for _, profile := range cloakingProfiles {
+			override := desktop
+			if profile.UserAgent != "" {
+				override = emulation.SetUserAgentOverride(profile.UserAgent)
+			}
+			ua := override.UserAgent
+			if err := override.Do(ctx); err != nil {
+				return fmt.Errorf("failed to set user agent for %s: %w", profile.Name, err)
+			}
+
+			view := ProfileView{Profile: profile.Name, UserAgent: ua}
+			var text string
+			if err := chromedp.Run(ctx,
+				chromedp.Navigate(action.Value),
+				chromedp.Sleep(cloakingSettleDelay),
+				chromedp.Location(&view.FinalURL),
+				chromedp.Title(&view.Title),
+				chromedp.Evaluate(`document.body ? document.body.innerText : ""`, &text),
+			); err != nil {
+				return fmt.Errorf("failed to load %s as %s: %w", action.Value, profile.Name, err)
+			}
+			view.TextLength = len(text)
+			view.words = wordSet(text)
+			views = append(views, view)
+		}
+
+		report := compareProfiles(action.Value, views)
+		reports, _ := result.CustomData["cloaking_checks"].([]CloakingReport)
+		setCustomData(result, "cloaking_checks", append(reports, report))
+		return nil
+	}), nil
+}
+
+// compareProfiles scores every view against the first one.
+func compareProfiles(targetURL string, views []ProfileView) CloakingReport {
+	report := CloakingReport{URL: targetURL, Profiles: views}
+	if len(views) == 0 {
+		return report
+	}
+
+	baseline := views[0]
+	for i := range report.Profiles {
+		view := &report.Profiles[i]
+		view.Similarity = jaccard(baseline.words, view.words)
+		view.Differs = hostOf(view.FinalURL) != hostOf(baseline.FinalURL) || view.Similarity < cloakingSimilarityThreshold
+		view.words = nil
+		if view.Differs {
+			report.Suspected = true
+		}
+	}
+	return report
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func wordSet(text string) map[string]struct{} {
+	words := make(map[string]struct{})
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		words[w] = struct{}{}
+	}
+	return words
+}
+
+// jaccard returns |a∩b| / |a∪b|, treating two empty sets as identical.
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for w := range a {
+		if _, ok := b[w]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
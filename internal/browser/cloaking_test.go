@@ -0,0 +1,68 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNavigationRecorder(t *testing.T) {
+	r := &navigationRecorder{mainFrame: "main"}
+
+	r.beforeAction(0, taskstypes.Action{Type: taskstypes.ActionNavigate})
+	r.handleEvent(&network.EventRequestWillBeSent{
+		Type:             network.ResourceTypeDocument,
+		FrameID:          "main",
+		Request:          &network.Request{URL: "https://example.com/home"},
+		RedirectResponse: &network.Response{URL: "http://example.com/", Status: 301},
+	})
+	r.handleEvent(&page.EventFrameRequestedNavigation{FrameID: "main", Reason: page.ClientNavigationReasonScriptInitiated, URL: "https://ads.example.net/landing"})
+
+	r.beforeAction(1, taskstypes.Action{Type: taskstypes.ActionClick})
+	r.handleEvent(&page.EventFrameRequestedNavigation{FrameID: "main", Reason: page.ClientNavigationReasonAnchorClick, URL: "https://example.com/next"})
+	r.handleEvent(&page.EventFrameRequestedNavigation{FrameID: "child", Reason: page.ClientNavigationReasonMetaTagRefresh, URL: "https://example.com/frame"})
+
+	// Sub-resource redirects are not navigations
+	r.handleEvent(&network.EventRequestWillBeSent{Type: network.ResourceTypeScript, Request: &network.Request{URL: "x"}, RedirectResponse: &network.Response{}})
+
+	events := r.report()
+	require.Len(t, events, 4)
+
+	assert.Equal(t, "http_redirect", events[0].Reason)
+	assert.Equal(t, int64(301), events[0].Status)
+	assert.Equal(t, "http://example.com/", events[0].From)
+
+	assert.Equal(t, "scriptInitiated", events[1].Reason)
+	assert.True(t, events[1].Automatic, "script navigation outside an interaction")
+	assert.True(t, events[1].MainFrame)
+
+	assert.False(t, events[2].Automatic, "link followed by a click action")
+	assert.Equal(t, 1, events[2].Action)
+
+	assert.True(t, events[3].Automatic, "meta refresh is always automatic")
+	assert.False(t, events[3].MainFrame)
+}
+
+func TestCompareProfiles(t *testing.T) {
+	views := []ProfileView{
+		{Profile: "desktop", FinalURL: "https://example.com/", words: wordSet("Buy cheap widgets today")},
+		{Profile: "googlebot", FinalURL: "https://example.com/", words: wordSet("buy cheap widgets today!")},
+		{Profile: "mobile", FinalURL: "https://example.com/", words: wordSet("Buy cheap widgets today")},
+	}
+	report := compareProfiles("https://example.com/", views)
+	assert.False(t, report.Suspected)
+	assert.Equal(t, 1.0, report.Profiles[0].Similarity)
+	assert.InDelta(t, 0.6, report.Profiles[1].Similarity, 0.001)
+
+	views[2] = ProfileView{Profile: "mobile", FinalURL: "https://casino.example.net/", words: wordSet("Buy cheap widgets today")}
+	report = compareProfiles("https://example.com/", views)
+	assert.True(t, report.Suspected, "redirected to another host")
+	assert.True(t, report.Profiles[2].Differs)
+
+	assert.Equal(t, 1.0, jaccard(wordSet(""), wordSet("")))
+	assert.Equal(t, 0.0, jaccard(wordSet("a"), wordSet("b")))
+}
@@ -0,0 +1,107 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// clockScript replaces Date and performance.now with a clock that starts at
+// the given Unix millisecond and, when frozen, stands still. A frozen clock also
+// holds back setTimeout and setInterval callbacks until advance() moves it
+// past their due time; timers already due still run on the next tick so
+// pages keep working. advance(ms) is called by the advance_clock action.
+const clockScript = `(() => {
+	if (window.__goscryClock) return;
+	const start = %[1]d, frozen = %[2]t;
+	const RealDate = Date;
+	const realNow = RealDate.now.bind(RealDate);
+	const realPerf = performance.now.bind(performance);
+	const realSetTimeout = window.setTimeout.bind(window);
+	const originMs = realNow(), originPerf = realPerf();
+	let offset = 0;
+
+	const now = () => start + offset + (frozen ? 0 : realNow() - originMs);
+	const perfNow = () => originPerf + offset + (frozen ? 0 : realPerf() - originPerf);
+
+	function FakeDate(...args) {
+		if (!new.target) return new RealDate(now()).toString();
+		return args.length ? new RealDate(...args) : new RealDate(now());
+	}
+	FakeDate.prototype = RealDate.prototype;
+	FakeDate.now = now;
+	FakeDate.parse = RealDate.parse;
+	FakeDate.UTC = RealDate.UTC;
+	window.Date = FakeDate;
+	performance.now = perfNow;
+
+	const timers = new Map();
+	let nextTimer = 1;
+	let flushing = false;
+	const flush = () => {
+		if (flushing) return;
+		flushing = true;
+		try {
+			for (let fired = 0; fired < 10000; fired++) {
+				let due = null;
+				for (const [id, t] of timers) {
+					if (t.at <= now() && (!due || t.at < due[1].at)) due = [id, t];
+				}
+				if (!due) break;
+				const [id, t] = due;
+				if (t.every) t.at += t.every; else timers.delete(id);
+				try { t.fn(...t.args); } catch (e) { realSetTimeout(() => { throw e; }, 0); }
+			}
+		} finally {
+			flushing = false;
+		}
+	};
+	if (frozen) {
+		const schedule = (fn, delay, args, repeat) => {
+			const id = nextTimer++;
+			const ms = Math.max(repeat ? 1 : 0, Number(delay) || 0);
+			const callback = typeof fn === 'function' ? fn : () => (0, eval)(String(fn));
+			timers.set(id, { fn: callback, args, at: now() + ms, every: repeat ? ms : 0 });
+			if (ms === 0) realSetTimeout(flush, 0);
+			return id;
+		};
+		window.setTimeout = (fn, delay, ...args) => schedule(fn, delay, args, false);
+		window.setInterval = (fn, delay, ...args) => schedule(fn, delay, args, true);
+		window.clearTimeout = window.clearInterval = (id) => { timers.delete(id); };
+	}
+
+	Object.defineProperty(window, '__goscryClock', {
+		value: Object.freeze({
+			advance(ms) { offset += ms; flush(); return now(); },
+		}),
+	});
+})();`
+
+// installClock makes every document the task's page loads from now on see the
+// clock described by opts. The returned action removes the script again.
+func installClock(ctx context.Context, opts taskstypes.ClockOptions) (chromedp.Action, error) {
+	return addPageScript(ctx, fmt.Sprintf(clockScript, opts.Time.UnixMilli(), opts.Freeze))
+}
+
+// advanceClockAction moves the page clock forward by the duration in Value
+// and fires the timers that became due. The task needs the clock option.
+func advanceClockAction(action taskstypes.Action) (chromedp.Action, error) {
+	step, err := time.ParseDuration(action.Value)
+	if err != nil || step < 0 {
+		return nil, fmt.Errorf("advance_clock action requires a non-negative duration value, got '%s'", action.Value)
+	}
+	script := fmt.Sprintf(`window.__goscryClock ? (window.__goscryClock.advance(%d), true) : false`, step.Milliseconds())
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var installed bool
+		if err := chromedp.Evaluate(script, &installed).Do(ctx); err != nil {
+			return err
+		}
+		if !installed {
+			return fmt.Errorf("advance_clock needs the clock task option")
+		}
+		return nil
+	}), nil
+}
@@ -0,0 +1,81 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/dom"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// visibleScript reports whether the first match is rendered with a non-empty box.
+const visibleScript = `(function(sel) {
+	const el = document.querySelector(sel);
+	if (!el) return false;
+	const style = window.getComputedStyle(el);
+	const rect = el.getBoundingClientRect();
+	return style.display !== 'none' && style.visibility !== 'hidden' && rect.width > 0 && rect.height > 0;
+})(%s)`
+
+// ConditionOutcome records how an action's condition was decided.
+type ConditionOutcome struct {
+	Action   int    `json:"action"`
+	Selector string `json:"selector"`
+	State    string `json:"state"`
+	Met      bool   `json:"met"` // False means the action was skipped or its else branch ran
+}
+
+// evaluateCondition checks the page once, without waiting for the element.
+func (m *Manager) evaluateCondition(ctx context.Context, cond taskstypes.Condition) (bool, error) {
+	if cond.Selector == "" {
+		return false, fmt.Errorf("condition requires a selector")
+	}
+
+	checkCtx := ctx
+	if timeout := m.cfg.ActionTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		checkCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	switch conditionState(cond) {
+	case taskstypes.ConditionPresent, taskstypes.ConditionAbsent:
+		var present bool
+		if err := chromedp.Run(checkCtx, dom.IsElementPresentAction(cond.Selector, &present)); err != nil {
+			return false, err
+		}
+		return present == (conditionState(cond) == taskstypes.ConditionPresent), nil
+	case taskstypes.ConditionVisible, taskstypes.ConditionHidden:
+		var visible bool
+		err := chromedp.Run(checkCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			visible, err = evaluateSelector(ctx, visibleScript, cond.Selector)
+			return err
+		}))
+		if err != nil {
+			return false, err
+		}
+		return visible == (conditionState(cond) == taskstypes.ConditionVisible), nil
+	default:
+		return false, fmt.Errorf("unknown condition state %q", cond.State)
+	}
+}
+
+func conditionState(cond taskstypes.Condition) string {
+	if cond.State == "" {
+		return taskstypes.ConditionPresent
+	}
+	return cond.State
+}
+
+// recordCondition appends an outcome to result.custom_data.conditions.
+func recordCondition(result *taskstypes.TaskResult, index int, cond taskstypes.Condition, met bool) {
+	outcomes, _ := result.CustomData["conditions"].([]ConditionOutcome)
+	setCustomData(result, "conditions", append(outcomes, ConditionOutcome{
+		Action:   index,
+		Selector: cond.Selector,
+		State:    conditionState(cond),
+		Met:      met,
+	}))
+}
@@ -0,0 +1,31 @@
+package browser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateCondition_Invalid(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{}}
+
+	_, err := m.evaluateCondition(context.Background(), taskstypes.Condition{})
+	assert.Error(t, err, "selector is required")
+
+	_, err = m.evaluateCondition(context.Background(), taskstypes.Condition{Selector: "#banner", State: "blinking"})
+	assert.ErrorContains(t, err, "unknown condition state")
+}
+
+func TestRecordCondition(t *testing.T) {
+	result := &taskstypes.TaskResult{}
+	recordCondition(result, 0, taskstypes.Condition{Selector: "#cookie-banner"}, true)
+	recordCondition(result, 3, taskstypes.Condition{Selector: "#login-wall", State: taskstypes.ConditionVisible}, false)
+
+	assert.Equal(t, []ConditionOutcome{
+		{Action: 0, Selector: "#cookie-banner", State: taskstypes.ConditionPresent, Met: true},
+		{Action: 3, Selector: "#login-wall", State: taskstypes.ConditionVisible, Met: false},
+	}, result.CustomData["conditions"])
+}
@@ -0,0 +1,147 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	maxConsoleEntries = 500
+	maxConsoleText    = 4096
+)
+
+// Console entry sources.
+const (
+	ConsoleSourceConsole   = "console"   // console.log and friends
+	ConsoleSourceException = "exception" // Uncaught errors and unhandled rejections
+)
+
+// ConsoleEntry is a console message, browser log entry, or uncaught
+// exception, in result.custom_data.console.
+type ConsoleEntry struct {
+	Action int    `json:"action"` // Action running when the entry was logged
+	Source string `json:"source"` // console, exception, or a browser log source such as network or violation
+	Level  string `json:"level"`  // e.g. log, info, warning, error
+	Text   string `json:"text"`
+	URL    string `json:"url,omitempty"`
+	Line   int64  `json:"line,omitempty"`
+	Column int64  `json:"column,omitempty"`
+}
+
+// consoleRecorder collects what the page logs, so a run_script or click that
+// silently failed can be debugged from the task result.
+type consoleRecorder struct {
+	mu      sync.Mutex
+	action  int
+	entries []ConsoleEntry
+	dropped int
+}
+
+// install starts recording. chromedp already enables the Runtime and Log domains.
+func (r *consoleRecorder) install(ctx context.Context) {
+	chromedp.ListenTarget(ctx, r.handleEvent)
+}
+
+func (r *consoleRecorder) handleEvent(ev interface{}) {
+	switch ev := ev.(type) {
+	case *runtime.EventConsoleAPICalled:
+		texts := make([]string, 0, len(ev.Args))
+		for _, arg := range ev.Args {
+			texts = append(texts, remoteObjectText(arg))
+		}
+		entry := ConsoleEntry{Source: ConsoleSourceConsole, Level: string(ev.Type), Text: strings.Join(texts, " ")}
+		if ev.StackTrace != nil && len(ev.StackTrace.CallFrames) > 0 {
+			frame := ev.StackTrace.CallFrames[0]
+			entry.URL, entry.Line, entry.Column = frame.URL, frame.LineNumber+1, frame.ColumnNumber+1
+		}
+		r.add(entry)
+	case *runtime.EventExceptionThrown:
+		details := ev.ExceptionDetails
+		if details == nil {
+			return
+		}
+		text := details.Text
+		if details.Exception != nil && details.Exception.Description != "" {
+			text = details.Exception.Description
+		}
+		entry := ConsoleEntry{Source: ConsoleSourceException, Level: "error", Text: text, URL: details.URL}
+		if details.URL != "" {
+			entry.Line, entry.Column = details.LineNumber+1, details.ColumnNumber+1
+		}
+		r.add(entry)
+	case *log.EventEntryAdded:
+		if ev.Entry == nil {
+			return
+		}
+		entry := ConsoleEntry{Source: string(ev.Entry.Source), Level: string(ev.Entry.Level), Text: ev.Entry.Text, URL: ev.Entry.URL}
+		if ev.Entry.URL != "" && ev.Entry.LineNumber > 0 {
+			entry.Line = ev.Entry.LineNumber + 1
+		}
+		r.add(entry)
+	}
+}
+
+// remoteObjectText formats a console argument the way DevTools prints it.
+func remoteObjectText(obj *runtime.RemoteObject) string {
+	switch {
+	case obj == nil:
+		return ""
+	case obj.Type == runtime.TypeString && len(obj.Value) > 0:
+		var s string
+		if err := json.Unmarshal(obj.Value, &s); err == nil {
+			return s
+		}
+		return string(obj.Value)
+	case obj.UnserializableValue != "":
+		return string(obj.UnserializableValue)
+	case len(obj.Value) > 0:
+		return string(obj.Value)
+	case obj.Description != "":
+		return obj.Description
+	}
+	return string(obj.Type)
+}
+
+func (r *consoleRecorder) add(entry ConsoleEntry) {
+	entry.Text = truncateText(entry.Text, maxConsoleText)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) >= maxConsoleEntries {
+		r.dropped++
+		return
+	}
+	entry.Action = r.action
+	r.entries = append(r.entries, entry)
+}
+
+// beforeAction notes which action is running so entries can be attributed to it.
+func (r *consoleRecorder) beforeAction(index int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.action = index
+}
+
+// report returns the entries recorded and how many were dropped past the limit.
+func (r *consoleRecorder) report() ([]ConsoleEntry, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ConsoleEntry{}, r.entries...), r.dropped
+}
+
+// truncateText shortens s to at most limit bytes without splitting a rune.
+func truncateText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit] + "…"
+}
@@ -0,0 +1,57 @@
+package browser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsoleRecorder_RecordsEntries(t *testing.T) {
+	r := &consoleRecorder{}
+	r.beforeAction(1)
+	r.handleEvent(&runtime.EventConsoleAPICalled{
+		Type: runtime.APITypeLog,
+		Args: []*runtime.RemoteObject{
+			{Type: runtime.TypeString, Value: []byte(`"clicked"`)},
+			{Type: runtime.TypeNumber, Value: []byte(`42`)},
+			{Type: runtime.TypeObject, Description: "HTMLButtonElement"},
+			{Type: runtime.TypeNumber, UnserializableValue: "NaN"},
+		},
+		StackTrace: &runtime.StackTrace{CallFrames: []*runtime.CallFrame{{URL: "https://example.com/app.js", LineNumber: 9, ColumnNumber: 4}}},
+	})
+	r.beforeAction(2)
+	r.handleEvent(&runtime.EventExceptionThrown{ExceptionDetails: &runtime.ExceptionDetails{
+		Text:       "Uncaught",
+		URL:        "https://example.com/app.js",
+		LineNumber: 19,
+		Exception:  &runtime.RemoteObject{Type: runtime.TypeObject, Description: "TypeError: x is undefined"},
+	}})
+	r.handleEvent(&log.EventEntryAdded{Entry: &log.Entry{Source: log.SourceNetwork, Level: log.LevelError, Text: "Failed to load resource", URL: "https://example.com/missing.png"}})
+
+	entries, dropped := r.report()
+	assert.Zero(t, dropped)
+	assert.Equal(t, []ConsoleEntry{
+		{Action: 1, Source: "console", Level: "log", Text: "clicked 42 HTMLButtonElement NaN", URL: "https://example.com/app.js", Line: 10, Column: 5},
+		{Action: 2, Source: "exception", Level: "error", Text: "TypeError: x is undefined", URL: "https://example.com/app.js", Line: 20, Column: 1},
+		{Action: 2, Source: "network", Level: "error", Text: "Failed to load resource", URL: "https://example.com/missing.png"},
+	}, entries)
+}
+
+func TestConsoleRecorder_Limits(t *testing.T) {
+	r := &consoleRecorder{}
+	for i := 0; i < maxConsoleEntries+3; i++ {
+		r.add(ConsoleEntry{Source: ConsoleSourceConsole, Level: "log", Text: "tick"})
+	}
+	entries, dropped := r.report()
+	assert.Len(t, entries, maxConsoleEntries)
+	assert.Equal(t, 3, dropped)
+
+	long := strings.Repeat("é", maxConsoleText)
+	text := truncateText(long, maxConsoleText)
+	assert.True(t, strings.HasSuffix(text, "…"))
+	assert.LessOrEqual(t, len(text), maxConsoleText+len("…"))
+	assert.True(t, strings.HasPrefix(long, strings.TrimSuffix(text, "…")))
+}
@@ -0,0 +1,50 @@
+package browser
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/executortest"
+	"github.com/stretchr/testify/require"
+)
+
+// chromeInstalled reports whether chromedp can find a browser to start.
+func chromeInstalled() bool {
+	for _, name := range []string{"headless_shell", "chromium", "chromium-browser", "google-chrome", "google-chrome-stable"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func TestManager_ExecutorContract(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping executor contract in short mode")
+	}
+	if !chromeInstalled() {
+		t.Skip("Skipping executor contract: Chrome is not installed")
+	}
+	cfg := &config.BrowserConfig{
+		Headless:      true,
+		ActionTimeout: 10 * time.Second,
+		MaxSessions:   2,
+		Artifacts:     config.ArtifactConfig{Dir: t.TempDir()},
+	}
+	store, err := artifacts.New(cfg.Artifacts)
+	require.NoError(t, err)
+
+	executortest.Run(t, executortest.Config{
+		New: func(t *testing.T) tasks.BrowserExecutor {
+			m, err := NewManager(cfg, logging.Discard())
+			require.NoError(t, err)
+			return m
+		},
+		Artifacts: store,
+	})
+}
@@ -0,0 +1,190 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// CookiePolicyReport summarizes what a task's cookie policy did.
+type CookiePolicyReport struct {
+	ClearedBefore int `json:"cleared_before"`
+	ClearedAfter  int `json:"cleared_after"`
+	Reverted      int `json:"reverted"` // Times the read-only jar was restored after a page changed it
+}
+
+// cookieJar enforces a task's cookie policy on the browser's cookie store.
+type cookieJar struct {
+	policy   taskstypes.CookiePolicy
+	snapshot []*network.Cookie
+	report   CookiePolicyReport
+}
+
+func newCookieJar(policy taskstypes.CookiePolicy) *cookieJar {
+	return &cookieJar{policy: policy}
+}
+
+// apply runs before the first action: it restricts third-party cookies,
+// clears the ClearBefore domains, and snapshots the jar if it is read-only.
+func (j *cookieJar) apply(ctx context.Context) error {
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		if j.policy.BlockThirdParty {
+			if err := network.SetCookieControls(true, true, true).Do(ctx); err != nil {
+				return fmt.Errorf("failed to block third-party cookies: %w", err)
+			}
+		}
+		cleared, err := clearCookies(ctx, j.policy.ClearBefore)
+		if err != nil {
+			return err
+		}
+		j.report.ClearedBefore = cleared
+		if j.policy.ReadOnly {
+			if j.snapshot, err = storage.GetCookies().Do(ctx); err != nil {
+				return fmt.Errorf("failed to snapshot cookies: %w", err)
+			}
+		}
+		return nil
+	}))
+}
+
+// revert puts a read-only jar back to its snapshot if anything changed it.
+func (j *cookieJar) revert(ctx context.Context) error {
+	if !j.policy.ReadOnly {
+		return nil
+	}
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		current, err := storage.GetCookies().Do(ctx)
+		if err != nil {
+			return err
+		}
+		if sameCookies(current, j.snapshot) {
+			return nil
+		}
+		if err := storage.ClearCookies().Do(ctx); err != nil {
+			return err
+		}
+		params := make([]*network.CookieParam, 0, len(j.snapshot))
+		for _, c := range j.snapshot {
+			params = append(params, cookieParam(c))
+		}
+		if len(params) > 0 {
+			if err := storage.SetCookies(params).Do(ctx); err != nil {
+				return err
+			}
+		}
+		j.report.Reverted++
+		return nil
+	}))
+}
+
+// finish runs after the last action: it reverts a read-only jar, clears the
+// ClearAfter domains, and lifts the third-party restriction so a named
+// session is left as it was found.
+func (j *cookieJar) finish(ctx context.Context) error {
+	if err := j.revert(ctx); err != nil {
+		return fmt.Errorf("failed to restore read-only cookies: %w", err)
+	}
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		cleared, err := clearCookies(ctx, j.policy.ClearAfter)
+		if err != nil {
+			return err
+		}
+		j.report.ClearedAfter = cleared
+		if j.policy.BlockThirdParty {
+			return network.SetCookieControls(false, false, false).Do(ctx)
+		}
+		return nil
+	}))
+}
+
+// clearCookies deletes every cookie set for one of the domains or their
+// subdomains. "*" clears the whole jar.
+func clearCookies(ctx context.Context, domains []string) (int, error) {
+	if len(domains) == 0 {
+		return 0, nil
+	}
+	cookies, err := storage.GetCookies().Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cookies: %w", err)
+	}
+	cleared := 0
+	for _, c := range cookies {
+		if !cookieInDomains(c.Domain, domains) {
+			continue
+		}
+		if err := network.DeleteCookies(c.Name).WithDomain(c.Domain).WithPath(c.Path).Do(ctx); err != nil {
+			return cleared, fmt.Errorf("failed to delete cookie %s for %s: %w", c.Name, c.Domain, err)
+		}
+		cleared++
+	}
+	return cleared, nil
+}
+
+// cookieInDomains reports whether a cookie's domain is one of domains or a subdomain of one.
+func cookieInDomains(cookieDomain string, domains []string) bool {
+	host := strings.ToLower(strings.TrimPrefix(cookieDomain, "."))
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "."))
+		if d == "*" || host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameCookies compares two jars by name, domain, path, and value.
+func sameCookies(a, b []*network.Cookie) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	values := make(map[string]string, len(a))
+	for _, c := range a {
+		values[c.Name+"\x00"+c.Domain+"\x00"+c.Path] = c.Value
+	}
+	for _, c := range b {
+		if v, ok := values[c.Name+"\x00"+c.Domain+"\x00"+c.Path]; !ok || v != c.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// cookieParam turns a stored cookie back into a cookie that can be set.
+// Host-only cookies (no leading dot) are set by URL so they stay host-only.
+func cookieParam(c *network.Cookie) *network.CookieParam {
+	p := &network.CookieParam{
+		Name:         c.Name,
+		Value:        c.Value,
+		Path:         c.Path,
+		Secure:       c.Secure,
+		HTTPOnly:     c.HTTPOnly,
+		SameSite:     c.SameSite,
+		Priority:     c.Priority,
+		SourceScheme: c.SourceScheme,
+		SourcePort:   c.SourcePort,
+		PartitionKey: c.PartitionKey,
+	}
+	if strings.HasPrefix(c.Domain, ".") {
+		p.Domain = c.Domain
+	} else {
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		p.URL = scheme + "://" + c.Domain + c.Path
+	}
+	if !c.Session {
+		sec, frac := math.Modf(c.Expires)
+		expires := cdp.TimeSinceEpoch(time.Unix(int64(sec), int64(frac*1e9)))
+		p.Expires = &expires
+	}
+	return p
+}
@@ -0,0 +1,50 @@
+package browser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieInDomains(t *testing.T) {
+	domains := []string{"example.com", ".Tracker.net"}
+
+	assert.True(t, cookieInDomains("example.com", domains))
+	assert.True(t, cookieInDomains(".example.com", domains))
+	assert.True(t, cookieInDomains("www.example.com", domains))
+	assert.True(t, cookieInDomains("ads.tracker.net", domains))
+	assert.False(t, cookieInDomains("notexample.com", domains))
+	assert.False(t, cookieInDomains("example.org", domains))
+	assert.True(t, cookieInDomains("anything.org", []string{"*"}))
+	assert.False(t, cookieInDomains("example.com", nil))
+}
+
+func TestSameCookies(t *testing.T) {
+	a := []*network.Cookie{{Name: "sid", Domain: "example.com", Path: "/", Value: "1"}}
+	b := []*network.Cookie{{Name: "sid", Domain: "example.com", Path: "/", Value: "1"}}
+	assert.True(t, sameCookies(a, b))
+
+	b[0].Value = "2"
+	assert.False(t, sameCookies(a, b))
+	assert.False(t, sameCookies(a, append(b, &network.Cookie{Name: "new"})))
+}
+
+func TestCookieParam(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	hostOnly := cookieParam(&network.Cookie{
+		Name: "sid", Value: "1", Domain: "app.example.com", Path: "/account",
+		Secure: true, Expires: float64(expires.Unix()),
+	})
+	assert.Equal(t, "https://app.example.com/account", hostOnly.URL)
+	assert.Empty(t, hostOnly.Domain)
+	if assert.NotNil(t, hostOnly.Expires) {
+		assert.True(t, expires.Equal(hostOnly.Expires.Time()))
+	}
+
+	domain := cookieParam(&network.Cookie{Name: "pref", Value: "dark", Domain: ".example.com", Path: "/", Session: true})
+	assert.Equal(t, ".example.com", domain.Domain)
+	assert.Empty(t, domain.URL)
+	assert.Nil(t, domain.Expires, "session cookies have no expiry")
+}
@@ -0,0 +1,128 @@
+package browser
+
+import (
+	"context"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+const maxDialogsReported = 100
+
+// DialogRecord is a JavaScript dialog the page opened, in result.custom_data.dialogs.
+type DialogRecord struct {
+	Action   int    `json:"action"` // Action running when the dialog opened
+	Type     string `json:"type"`   // alert, confirm, prompt, or beforeunload
+	Message  string `json:"message"`
+	URL      string `json:"url,omitempty"`
+	Accepted bool   `json:"accepted"`
+}
+
+// dialogHandler answers dialogs as soon as they open, so alert() or
+// confirm() never leaves an action waiting on a blocked page.
+type dialogHandler struct {
+	policy taskstypes.DialogPolicy
+	logf   func(format string, args ...interface{})
+
+	mu      sync.Mutex
+	action  int
+	dialogs []DialogRecord
+	dropped int
+	open    int // Dialogs opened and not yet closed
+}
+
+func newDialogHandler(policy *taskstypes.DialogPolicy, logf func(format string, args ...interface{})) *dialogHandler {
+	d := &dialogHandler{logf: logf}
+	if policy != nil {
+		d.policy = *policy
+	}
+	return d
+}
+
+// install starts answering dialogs on the page ctx belongs to.
+func (d *dialogHandler) install(ctx context.Context) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		if _, ok := ev.(*page.EventJavascriptDialogClosed); ok {
+			d.closed()
+			return
+		}
+		opening, ok := ev.(*page.EventJavascriptDialogOpening)
+		if !ok {
+			return
+		}
+		accept := d.record(opening)
+		answer := page.HandleJavaScriptDialog(accept)
+		if accept && opening.Type == page.DialogTypePrompt {
+			text := d.policy.PromptText
+			if text == "" {
+				text = opening.DefaultPrompt
+			}
+			answer = answer.WithPromptText(text)
+		}
+		// Listeners must not block, and the answer is a CDP call of its own
+		go func() {
+			c := chromedp.FromContext(ctx)
+			if c == nil || c.Target == nil {
+				return
+			}
+			if err := answer.Do(cdp.WithExecutor(ctx, c.Target)); err != nil && ctx.Err() == nil {
+				d.logf("Failed to answer %s dialog: %v", opening.Type, err)
+			}
+		}()
+	})
+}
+
+// record notes a dialog and returns whether the policy accepts it.
+func (d *dialogHandler) record(ev *page.EventJavascriptDialogOpening) bool {
+	accept := d.policy.Action != taskstypes.DialogDismiss
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.open++
+	if len(d.dialogs) >= maxDialogsReported {
+		d.dropped++
+		return accept
+	}
+	d.dialogs = append(d.dialogs, DialogRecord{
+		Action:   d.action,
+		Type:     string(ev.Type),
+		Message:  ev.Message,
+		URL:      ev.URL,
+		Accepted: accept,
+	})
+	return accept
+}
+
+// closed notes that a dialog was answered, by the handler or the page.
+func (d *dialogHandler) closed() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.open = max(d.open-1, 0)
+}
+
+// blocking reports whether a dialog is still open, e.g. because answering it
+// failed, which leaves the page unable to run anything.
+func (d *dialogHandler) blocking() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.open > 0
+}
+
+// beforeAction notes which action is running so dialogs can be attributed to it.
+func (d *dialogHandler) beforeAction(index int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.action = index
+}
+
+// report returns the dialogs seen, or nil if there were none.
+func (d *dialogHandler) report() []DialogRecord {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dropped > 0 {
+		d.logf("%d dialogs beyond the first %d were answered but not reported", d.dropped, maxDialogsReported)
+	}
+	return append([]DialogRecord(nil), d.dialogs...)
+}
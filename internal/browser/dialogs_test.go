@@ -0,0 +1,51 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialogHandler_RecordsDialogs(t *testing.T) {
+	d := newDialogHandler(nil, t.Logf)
+	assert.Nil(t, d.report())
+
+	d.beforeAction(1)
+	assert.True(t, d.record(&page.EventJavascriptDialogOpening{Type: page.DialogTypeAlert, Message: "Saved", URL: "https://example.com/"}))
+	d.beforeAction(3)
+	assert.True(t, d.record(&page.EventJavascriptDialogOpening{Type: page.DialogTypeConfirm, Message: "Leave?"}))
+
+	assert.Equal(t, []DialogRecord{
+		{Action: 1, Type: "alert", Message: "Saved", URL: "https://example.com/", Accepted: true},
+		{Action: 3, Type: "confirm", Message: "Leave?", Accepted: true},
+	}, d.report())
+}
+
+func TestDialogHandler_DismissPolicy(t *testing.T) {
+	d := newDialogHandler(&taskstypes.DialogPolicy{Action: taskstypes.DialogDismiss}, t.Logf)
+	assert.False(t, d.record(&page.EventJavascriptDialogOpening{Type: page.DialogTypePrompt, Message: "Name?"}))
+	assert.False(t, d.report()[0].Accepted)
+}
+
+func TestDialogHandler_CapsReport(t *testing.T) {
+	d := newDialogHandler(nil, t.Logf)
+	for i := 0; i < maxDialogsReported+5; i++ {
+		d.record(&page.EventJavascriptDialogOpening{Type: page.DialogTypeAlert})
+	}
+	assert.Len(t, d.report(), maxDialogsReported)
+}
+
+func TestDialogHandler_Blocking(t *testing.T) {
+	d := newDialogHandler(nil, t.Logf)
+	assert.False(t, d.blocking())
+
+	d.record(&page.EventJavascriptDialogOpening{Type: page.DialogTypeAlert})
+	assert.True(t, d.blocking(), "open until the page reports it closed")
+	d.closed()
+	assert.False(t, d.blocking())
+	d.closed()
+	d.record(&page.EventJavascriptDialogOpening{Type: page.DialogTypeAlert})
+	assert.True(t, d.blocking(), "a stray close does not hide a later dialog")
+}
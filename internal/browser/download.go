@@ -0,0 +1,228 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/dom"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// DownloadInfo describes a file captured by a download action.
+type DownloadInfo struct {
+	URL         string `json:"url"`
+	Filename    string `json:"filename"`
+	Name        string `json:"name,omitempty"` // In the task's artifact store; unset for inline downloads
+	Path        string `json:"path,omitempty"` // Set by the local artifact backend
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Data        string `json:"data,omitempty"` // Base64 file contents when format is "base64"
+}
+
+// downloadAction clicks Selector, or navigates to Value, and waits for the
+// browser download it triggers. Chrome writes the file under
+// browser.downloadDir, and once complete it moves to the artifact store as
+// "download-<filename>", or is returned inline when Format is "base64".
+func (m *Manager) downloadAction(task *taskstypes.Task, action taskstypes.Action, result *taskstypes.TaskResult) (chromedp.Action, error) {
+	if action.Selector == "" && action.Value == "" {
+		return nil, fmt.Errorf("download action requires a selector to click or a URL value")
+	}
+	inline := action.Format == "base64"
+	if action.Format != "" && !inline {
+		return nil, fmt.Errorf("invalid download format %q (expected base64 or empty)", action.Format)
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		dir, err := m.downloadDir(inline)
+		if err != nil {
+			return err
+		}
+		if inline {
+			defer os.RemoveAll(dir)
+		}
+
+		listenCtx, stopListening := context.WithCancel(ctx)
+		defer stopListening()
+
+		var (
+			mu      sync.Mutex
+			started = make(map[string]*browser.EventDownloadWillBegin)
+			done    = make(chan *browser.EventDownloadWillBegin, 1)
+			failed  = make(chan error, 1)
+		)
+		chromedp.ListenTarget(listenCtx, func(ev interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch ev := ev.(type) {
+			case *browser.EventDownloadWillBegin:
+				started[ev.GUID] = ev
+			case *browser.EventDownloadProgress:
+				begin, ok := started[ev.GUID]
+				if !ok {
+					return
+				}
+				if m.overArtifactLimit(int64(ev.ReceivedBytes)) || m.overArtifactLimit(int64(ev.TotalBytes)) {
+					// Stop the transfer as soon as it is known to be too large
+					delete(started, ev.GUID)
+					go func() {
+						_ = chromedp.Run(listenCtx, browser.CancelDownload(ev.GUID))
+					}()
+					select {
+					case failed <- m.rejectArtifact("download of "+begin.URL, int64(max(ev.ReceivedBytes, ev.TotalBytes))):
+					default:
+					}
+					return
+				}
+				switch ev.State {
+				case browser.DownloadProgressStateCompleted:
+					select {
+					case done <- begin:
+					default:
+					}
+				case browser.DownloadProgressStateCanceled:
+					select {
+					case failed <- fmt.Errorf("download of %s was canceled", begin.URL):
+					default:
+					}
+				}
+			}
+		})
+
+		// AllowAndName saves the file as its GUID, so concurrent downloads never collide
+		contextID := browserContextID(ctx)
+		if err := browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllowAndName).
+			WithDownloadPath(dir).WithEventsEnabled(true).WithBrowserContextID(contextID).Do(ctx); err != nil {
+			return fmt.Errorf("failed to enable downloads: %w", err)
+		}
+		defer func() {
+			_ = browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorDefault).WithBrowserContextID(contextID).Do(ctx)
+		}()
+
+		if action.Selector != "" {
+			err = dom.ClickAction(action.Selector).Do(ctx)
+		} else {
+			// Chrome aborts the navigation once the response turns into a download
+			if err = chromedp.Navigate(action.Value).Do(ctx); err != nil && strings.Contains(err.Error(), "net::ERR_ABORTED") {
+				err = nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to trigger download: %w", err)
+		}
+
+		var begin *browser.EventDownloadWillBegin
+		select {
+		case begin = <-done:
+		case err := <-failed:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		info, err := collectDownload(encryption.WithTenant(ctx, task.Tenant), m.artifactStore(), dir, task, begin, inline)
+		if err != nil {
+			return err
+		}
+		m.recordArtifact(info.Size)
+		downloads, _ := result.CustomData["downloads"].([]DownloadInfo)
+		setCustomData(result, "downloads", append(downloads, *info))
+		return nil
+	}), nil
+}
+
+// downloadDir returns the absolute directory Chrome should write into.
+// Inline downloads use a temporary directory that the caller removes.
+func (m *Manager) downloadDir(inline bool) (string, error) {
+	var dir string
+	var err error
+	if inline {
+		dir, err = os.MkdirTemp("", "goscry-download-*")
+	} else {
+		dir = m.cfg.DownloadDir
+		if dir == "" {
+			dir = "downloads"
+		}
+		err = os.MkdirAll(dir, 0o755)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare download directory: %w", err)
+	}
+	return filepath.Abs(dir)
+}
+
+// collectDownload moves the GUID-named file Chrome wrote into the artifact
+// store, or reads it for an inline download, and describes it.
+func collectDownload(ctx context.Context, store artifacts.Store, dir string, task *taskstypes.Task, begin *browser.EventDownloadWillBegin, inline bool) (*DownloadInfo, error) {
+	src := filepath.Join(dir, begin.GUID)
+	name := safeFilename(begin.SuggestedFilename, begin.GUID)
+
+	info := &DownloadInfo{URL: begin.URL, Filename: name, ContentType: mime.TypeByExtension(filepath.Ext(name))}
+
+	stat, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read downloaded file: %w", err)
+	}
+	info.Size = stat.Size()
+	if info.ContentType == "" {
+		info.ContentType, err = sniffContentType(src)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if inline {
+		if info.Data, err = readBase64File(src, info.Size); err != nil {
+			return nil, fmt.Errorf("failed to read downloaded file: %w", err)
+		}
+		return info, nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read downloaded file: %w", err)
+	}
+	obj, err := store.Put(ctx, task.ID, "download-"+name, f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to save downloaded file: %w", err)
+	}
+	os.Remove(src)
+	info.Name, info.Path = obj.Name, obj.Path
+	return info, nil
+}
+
+// sniffContentType detects a file's type from its first 512 bytes.
+func sniffContentType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read downloaded file: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("failed to read downloaded file: %w", err)
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// safeFilename strips any directory components from a server-suggested name.
+func safeFilename(suggested, fallback string) string {
+	name := filepath.Base(strings.ReplaceAll(suggested, "\\", "/"))
+	if name == "." || name == "/" || name == ".." || name == "" {
+		return fallback
+	}
+	return name
+}
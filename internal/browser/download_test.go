@@ -0,0 +1,71 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeFilename(t *testing.T) {
+	assert.Equal(t, "report.csv", safeFilename("report.csv", "guid"))
+	assert.Equal(t, "passwd", safeFilename("../../etc/passwd", "guid"))
+	assert.Equal(t, "evil.exe", safeFilename(`..\..\evil.exe`, "guid"))
+	assert.Equal(t, "guid", safeFilename("", "guid"))
+	assert.Equal(t, "guid", safeFilename("..", "guid"))
+}
+
+func TestCollectDownload(t *testing.T) {
+	task := &taskstypes.Task{ID: uuid.New()}
+	begin := &browser.EventDownloadWillBegin{GUID: "abc", URL: "https://example.com/export", SuggestedFilename: "export.csv"}
+
+	dir := t.TempDir()
+	storeDir := t.TempDir()
+	store := artifacts.NewLocalStore(storeDir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "abc"), []byte("a,b\n1,2\n"), 0o644))
+
+	info, err := collectDownload(context.Background(), store, dir, task, begin, false)
+	require.NoError(t, err)
+	assert.Equal(t, "export.csv", info.Filename)
+	assert.Equal(t, int64(8), info.Size)
+	assert.Contains(t, info.ContentType, "text/csv")
+	assert.Equal(t, "download-export.csv", info.Name)
+	assert.Equal(t, filepath.Join(storeDir, task.ID.String(), "download-export.csv"), info.Path)
+	assert.FileExists(t, info.Path)
+	assert.NoFileExists(t, filepath.Join(dir, "abc"), "the staged file moves to the store")
+
+	// Inline downloads are returned as base64 and not kept on disk
+	begin = &browser.EventDownloadWillBegin{GUID: "def", URL: "https://example.com/file"}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "def"), []byte("%PDF-1.7 test"), 0o644))
+	info, err = collectDownload(context.Background(), store, dir, task, begin, true)
+	require.NoError(t, err)
+	assert.Equal(t, "def", info.Filename)
+	assert.Equal(t, "application/pdf", info.ContentType)
+	assert.Empty(t, info.Path)
+	assert.Empty(t, info.Name)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("%PDF-1.7 test")), info.Data)
+}
+
+func TestDownloadAction_Validation(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{}}
+	task := &taskstypes.Task{ID: uuid.New()}
+	result := &taskstypes.TaskResult{}
+
+	_, err := m.downloadAction(task, taskstypes.Action{Type: taskstypes.ActionDownload}, result)
+	assert.Error(t, err)
+
+	_, err = m.downloadAction(task, taskstypes.Action{Type: taskstypes.ActionDownload, Value: "https://example.com/a.pdf", Format: "zip"}, result)
+	assert.Error(t, err)
+
+	_, err = m.downloadAction(task, taskstypes.Action{Type: taskstypes.ActionDownload, Selector: "#export", Format: "base64"}, result)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,209 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/inspector"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// ErrTFATimeout is returned when no 2FA code arrives while an action waits for one.
+var ErrTFATimeout = errors.New("2FA code wait timed out")
+
+// selectorProbeTimeout bounds the check for whether a failed action's
+// selector still matches anything.
+const selectorProbeTimeout = 2 * time.Second
+
+// recordFailure sets the result's error code and failed action, unless an
+// action nested in an else branch already has.
+func recordFailure(result *taskstypes.TaskResult, index int, action taskstypes.Action, code taskstypes.ErrorCode) {
+	if result.ErrorCode != "" {
+		return
+	}
+	result.ErrorCode = code
+	result.FailedAction = &taskstypes.FailedAction{Index: index, Type: action.Type, Selector: failedSelector(action)}
+}
+
+// failedSelector is the selector an action waits for, if any.
+func failedSelector(action taskstypes.Action) string {
+	if action.Type == taskstypes.ActionNavigate && action.WaitUntil != taskstypes.WaitUntilSelector {
+		return ""
+	}
+	return action.Selector
+}
+
+// classifyActionError maps an action's error to an error code. Actions
+// waiting for an element time out rather than fail when it never appears,
+// so a timeout is reported as SELECTOR_NOT_FOUND if the selector still
+// matches nothing afterwards.
+func classifyActionError(ctx context.Context, action taskstypes.Action, err error) taskstypes.ErrorCode {
+	var exception *runtime.ExceptionDetails
+	switch {
+	case errors.Is(err, ErrTFATimeout):
+		return taskstypes.ErrorTFATimeout
+	case errors.Is(err, ErrLoginFailed):
+		return taskstypes.ErrorLoginFailed
+	case errors.Is(err, taskstypes.ErrURLBlocked):
+		return taskstypes.ErrorURLBlocked
+	case errors.Is(err, ErrActionTimeout), errors.Is(err, chromedp.ErrPollingTimeout):
+		if selector := failedSelector(action); selector != "" && selectorMissing(ctx, selector) {
+			return taskstypes.ErrorSelectorNotFound
+		}
+		if action.Type == taskstypes.ActionNavigate {
+			return taskstypes.ErrorNavigationTimeout
+		}
+		return taskstypes.ErrorActionTimeout
+	case errors.As(err, &exception):
+		return taskstypes.ErrorScriptError
+	case errors.Is(err, chromedp.ErrNoResults), strings.Contains(err.Error(), "could not find node"):
+		return taskstypes.ErrorSelectorNotFound
+	case errors.Is(err, chromedp.ErrInvalidTarget), errors.Is(err, chromedp.ErrInvalidContext), errors.Is(err, chromedp.ErrChannelClosed):
+		return taskstypes.ErrorBrowserCrash
+	case strings.Contains(err.Error(), "net::ERR_"):
+		return taskstypes.ErrorNavigationFailed
+	}
+	return taskstypes.ErrorActionFailed
+}
+
+// selectorMissing reports whether selector matches no element on the page.
+// Errors, such as an invalid selector, count as not missing.
+func selectorMissing(ctx context.Context, selector string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, selectorProbeTimeout)
+	defer cancel()
+	var missing bool
+	script := fmt.Sprintf(`document.querySelector(%q) === null`, selector)
+	if err := chromedp.Run(probeCtx, chromedp.Evaluate(script, &missing)); err != nil {
+		return false
+	}
+	return missing
+}
+
+// crashWatch notices when the page's renderer crashes, which otherwise only
+// shows up as actions that hang until they time out.
+type crashWatch struct {
+	crashed atomic.Bool
+}
+
+func (w *crashWatch) install(ctx context.Context) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		if _, ok := ev.(*inspector.EventTargetCrashed); ok {
+			w.crashed.Store(true)
+		}
+	})
+}
+
+// taskFailureCode corrects the code of a failed action when the real cause
+// was outside it: the task running out of time or being cancelled (ctx), or
+// the browser going away (browserCtx, or a renderer crash).
+func (w *crashWatch) taskFailureCode(ctx, browserCtx context.Context) (taskstypes.ErrorCode, bool) {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return taskstypes.ErrorTaskTimeout, true
+	case ctx.Err() != nil:
+		return taskstypes.ErrorCancelled, true
+	case w.crashed.Load(), browserCtx.Err() != nil:
+		return taskstypes.ErrorBrowserCrash, true
+	}
+	return "", false
+}
+
+// botMarkers are elements only bot protection challenge and block pages
+// have, by vendor.
+var botMarkers = []struct {
+	vendor   string
+	selector string
+}{
+	{"cloudflare", `#challenge-form, #cf-challenge-running, script[src*="/cdn-cgi/challenge-platform/"]`},
+	{"datadome", `iframe[src*="captcha-delivery.com"]`},
+	{"perimeterx", `#px-captcha`},
+	{"imperva", `iframe[src*="_Incapsula_Resource"]`},
+}
+
+// botTitles are the titles of challenge pages. Those marked needsStatus are
+// also used by ordinary pages, so only count when the page was refused.
+var botTitles = []struct {
+	vendor      string
+	title       string
+	needsStatus bool
+}{
+	{"cloudflare", "just a moment", false},
+	{"cloudflare", "attention required! | cloudflare", false},
+	{"imperva", "pardon our interruption", false},
+	{"", "verify you are human", false},
+	{"akamai", "access denied", true},
+	{"", "request blocked", true},
+	{"", "are you a robot", true},
+}
+
+// pageSignals is what a failed page is checked for bot protection by.
+type pageSignals struct {
+	Title   string   `json:"title"`
+	Status  int      `json:"status"`  // The main document's response status, or 0 if unknown
+	Markers []string `json:"markers"` // Vendors whose botMarkers matched
+}
+
+// botProtectionVendor returns the vendor of the bot protection signals
+// point to, "unknown" if the page is a challenge from an unrecognized one,
+// or "" if it is not one.
+func botProtectionVendor(signals pageSignals) string {
+	if len(signals.Markers) > 0 {
+		return signals.Markers[0]
+	}
+	title := strings.ToLower(strings.TrimSpace(signals.Title))
+	refused := signals.Status == 403 || signals.Status == 429 || signals.Status == 503
+	for _, t := range botTitles {
+		if strings.HasPrefix(title, t.title) && (refused || !t.needsStatus) {
+			if t.vendor == "" {
+				return "unknown"
+			}
+			return t.vendor
+		}
+	}
+	return ""
+}
+
+// botProtectionCheck reports whether a failure could be a bot protection
+// page's doing: the elements an action expected never appeared.
+func botProtectionCheck(code taskstypes.ErrorCode) bool {
+	switch code {
+	case taskstypes.ErrorSelectorNotFound, taskstypes.ErrorActionTimeout,
+		taskstypes.ErrorNavigationTimeout, taskstypes.ErrorActionFailed:
+		return true
+	}
+	return false
+}
+
+// detectBotProtection returns the vendor of the bot protection page ctx's
+// page shows, or "" if it is not one or could not be checked.
+func detectBotProtection(ctx context.Context) string {
+	if ctx.Err() != nil {
+		return ""
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, selectorProbeTimeout)
+	defer cancel()
+	var b strings.Builder
+	b.WriteString(`(() => {
+		const nav = performance.getEntriesByType("navigation")[0];
+		const markers = [];`)
+	for _, m := range botMarkers {
+		fmt.Fprintf(&b, "\n\t\tif (document.querySelector(%q)) markers.push(%q);", m.selector, m.vendor)
+	}
+	b.WriteString(`
+		return {title: document.title, status: (nav && nav.responseStatus) || 0, markers};
+	})()`)
+	var signals pageSignals
+	if err := chromedp.Run(probeCtx, chromedp.Evaluate(b.String(), &signals)); err != nil {
+		return ""
+	}
+	return botProtectionVendor(signals)
+}
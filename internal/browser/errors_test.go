@@ -0,0 +1,99 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyActionError(t *testing.T) {
+	navigate := taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://example.com"}
+	click := taskstypes.Action{Type: taskstypes.ActionClick, Selector: "#buy"}
+	script := taskstypes.Action{Type: taskstypes.ActionRunScript, Value: "boom()"}
+
+	for _, tc := range []struct {
+		action taskstypes.Action
+		err    error
+		want   taskstypes.ErrorCode
+	}{
+		{click, fmt.Errorf("%w: context deadline exceeded", ErrTFATimeout), taskstypes.ErrorTFATimeout},
+		{taskstypes.Action{Type: taskstypes.ActionLogin}, fmt.Errorf("%w: the page says %q", ErrLoginFailed, "incorrect password"), taskstypes.ErrorLoginFailed},
+		{navigate, fmt.Errorf("%w after 30s", ErrActionTimeout), taskstypes.ErrorNavigationTimeout},
+		// Without a page to probe, a timed-out click is not blamed on its selector
+		{click, fmt.Errorf("%w after 30s", ErrActionTimeout), taskstypes.ErrorActionTimeout},
+		{script, &runtime.ExceptionDetails{Text: "Uncaught ReferenceError: boom is not defined"}, taskstypes.ErrorScriptError},
+		{click, fmt.Errorf("could not find node with given id"), taskstypes.ErrorSelectorNotFound},
+		{click, chromedp.ErrNoResults, taskstypes.ErrorSelectorNotFound},
+		{click, chromedp.ErrInvalidTarget, taskstypes.ErrorBrowserCrash},
+		{navigate, errors.New("page load error net::ERR_NAME_NOT_RESOLVED"), taskstypes.ErrorNavigationFailed},
+		{click, errors.New("something else"), taskstypes.ErrorActionFailed},
+	} {
+		assert.Equal(t, tc.want, classifyActionError(context.Background(), tc.action, tc.err), "%s: %v", tc.action.Type, tc.err)
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	result := &taskstypes.TaskResult{}
+	recordFailure(result, 2, taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://example.com", Selector: "#ignored"}, taskstypes.ErrorNavigationTimeout)
+	require.NotNil(t, result.FailedAction)
+	assert.Equal(t, taskstypes.FailedAction{Index: 2, Type: taskstypes.ActionNavigate}, *result.FailedAction, "navigate only reports a selector it waits for")
+
+	// An action in an else branch records its failure first; its parent keeps it
+	recordFailure(result, 2, taskstypes.Action{Type: taskstypes.ActionClick, Selector: "#other"}, taskstypes.ErrorActionFailed)
+	assert.Equal(t, taskstypes.ErrorNavigationTimeout, result.ErrorCode)
+
+	result = &taskstypes.TaskResult{}
+	recordFailure(result, 0, taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://example.com", WaitUntil: taskstypes.WaitUntilSelector, Selector: "#app"}, taskstypes.ErrorSelectorNotFound)
+	assert.Equal(t, "#app", result.FailedAction.Selector)
+}
+
+func TestCrashWatch_TaskFailureCode(t *testing.T) {
+	watch := &crashWatch{}
+	ctx, browserCtx := context.Background(), context.Background()
+	_, ok := watch.taskFailureCode(ctx, browserCtx)
+	assert.False(t, ok, "the action's own code stands")
+
+	watch.crashed.Store(true)
+	code, _ := watch.taskFailureCode(ctx, browserCtx)
+	assert.Equal(t, taskstypes.ErrorBrowserCrash, code)
+
+	expired, cancel := context.WithTimeout(ctx, time.Nanosecond)
+	defer cancel()
+	<-expired.Done()
+	code, _ = watch.taskFailureCode(expired, browserCtx)
+	assert.Equal(t, taskstypes.ErrorTaskTimeout, code, "a task out of time is not a crash")
+
+	cancelled, cancelNow := context.WithCancel(ctx)
+	cancelNow()
+	code, _ = watch.taskFailureCode(cancelled, browserCtx)
+	assert.Equal(t, taskstypes.ErrorCancelled, code)
+}
+
+func TestBotProtectionVendor(t *testing.T) {
+	for _, tc := range []struct {
+		signals pageSignals
+		want    string
+	}{
+		{pageSignals{Title: "Just a moment...", Status: 403}, "cloudflare"},
+		{pageSignals{Title: "Checkout", Markers: []string{"datadome"}}, "datadome"},
+		{pageSignals{Title: "Access Denied", Status: 403}, "akamai"},
+		{pageSignals{Title: "Are you a robot?", Status: 429}, "unknown"},
+		// Ordinary pages can have these titles; only a refused page counts
+		{pageSignals{Title: "Access Denied", Status: 200}, ""},
+		{pageSignals{Title: "Request blocked"}, ""},
+		{pageSignals{Title: "Sign in", Status: 403}, ""},
+	} {
+		assert.Equal(t, tc.want, botProtectionVendor(tc.signals), "%+v", tc.signals)
+	}
+
+	assert.True(t, botProtectionCheck(taskstypes.ErrorSelectorNotFound))
+	assert.False(t, botProtectionCheck(taskstypes.ErrorScriptError), "a script error is the script's own")
+}
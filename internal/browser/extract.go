@@ -0,0 +1,110 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// extractScript maps fields onto the document, or onto every element matching
+// the container selector. href and src are read as properties so relative
+// URLs come back absolute.
+const extractScript = `(function(container, fields) {
+	function read(el, f) {
+		if (!f.attribute) return (el.innerText !== undefined ? el.innerText : el.textContent || '').trim();
+		if ((f.attribute === 'href' || f.attribute === 'src') && typeof el[f.attribute] === 'string') return el[f.attribute];
+		return el.getAttribute(f.attribute);
+	}
+	function pick(root, f) {
+		const els = f.selector ? Array.from(root.querySelectorAll(f.selector)) : [root];
+		if (f.all) return els.map(function(el) { return read(el, f); });
+		return els.length ? read(els[0], f) : null;
+	}
+	function extract(root) {
+		const out = {};
+		for (const name of Object.keys(fields)) out[name] = pick(root, fields[name]);
+		return out;
+	}
+	if (container) return Array.from(document.querySelectorAll(container)).map(extract);
+	return extract(document.body || document.documentElement);
+})(%s, %s)`
+
+// extractAction reads Fields from the page. With a Selector it returns an
+// array with one object per matching container, otherwise a single object.
+// Output goes to result.custom_data.extracted under Value, or "action_<index>".
+// With Repeat, the results of every page are concatenated into one array.
+// With Virtual, the containers are rows of a virtualized list, read while
+// scrolling through it.
+func (m *Manager) extractAction(index int, action taskstypes.Action, result *taskstypes.TaskResult) (chromedp.Action, error) {
+	if len(action.Fields) == 0 {
+		return nil, fmt.Errorf("extract action requires at least one field")
+	}
+	var virtual *virtualScroll
+	if action.Virtual != nil {
+		var err error
+		if virtual, err = newVirtualScroll(action); err != nil {
+			return nil, err
+		}
+	}
+
+	container, err := json.Marshal(action.Selector)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := json.Marshal(action.Fields)
+	if err != nil {
+		return nil, err
+	}
+	script := fmt.Sprintf(extractScript, container, fields)
+
+	key := action.Value
+	if key == "" {
+		key = fmt.Sprintf("action_%d", index)
+	}
+
+	// A repeating extract runs once per page; its pages are concatenated.
+	var pages []interface{}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var data interface{}
+		if virtual != nil {
+			rows, report, err := virtual.harvest(ctx, script)
+			if err != nil {
+				return err
+			}
+			data = rows
+			reports, _ := result.CustomData["virtual_lists"].(map[string]virtualReport)
+			if reports == nil {
+				reports = make(map[string]virtualReport)
+			}
+			if previous, ok := reports[key]; ok && action.Repeat != nil {
+				report.add(previous)
+			}
+			reports[key] = report
+			setCustomData(result, "virtual_lists", reports)
+		} else if err := chromedp.Evaluate(script, &data).Do(ctx); err != nil {
+			return err
+		}
+		if action.Repeat != nil {
+			pages = appendPage(pages, data)
+			data = pages
+		}
+		extracted, _ := result.CustomData["extracted"].(map[string]interface{})
+		if extracted == nil {
+			extracted = make(map[string]interface{})
+		}
+		extracted[key] = data
+		setCustomData(result, "extracted", extracted)
+		return nil
+	}), nil
+}
+
+// appendPage adds one page of extracted data, flattening per-container arrays.
+func appendPage(pages []interface{}, data interface{}) []interface{} {
+	if items, ok := data.([]interface{}); ok {
+		return append(pages, items...)
+	}
+	return append(pages, data)
+}
@@ -0,0 +1,64 @@
+package browser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractAction_RequiresFields(t *testing.T) {
+	m := &Manager{}
+	result := &taskstypes.TaskResult{}
+
+	_, err := m.extractAction(0, taskstypes.Action{Type: taskstypes.ActionExtract, Selector: ".item"}, result)
+	assert.Error(t, err)
+
+	action, err := m.extractAction(0, taskstypes.Action{
+		Type:   taskstypes.ActionExtract,
+		Fields: map[string]taskstypes.ExtractField{"title": {Selector: "h1"}},
+	}, result)
+	assert.NoError(t, err)
+	assert.NotNil(t, action)
+}
+
+func BenchmarkAppendPage(b *testing.B) {
+	// A repeating extract over 50 pages of 40 containers each
+	page := make([]interface{}, 40)
+	for i := range page {
+		page[i] = map[string]interface{}{"title": "Item", "price": "$9.99", "url": "https://example.com/p/1"}
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var pages []interface{}
+		for p := 0; p < 50; p++ {
+			pages = appendPage(pages, page)
+		}
+	}
+}
+
+func TestVirtualScroll(t *testing.T) {
+	fields := map[string]taskstypes.ExtractField{"id": {Attribute: "row-index"}, "name": {Selector: ".name"}}
+	v, err := newVirtualScroll(taskstypes.Action{Type: taskstypes.ActionExtract, Selector: ".ag-row", Fields: fields, Virtual: &taskstypes.VirtualList{}})
+	require.NoError(t, err)
+	assert.Equal(t, defaultVirtualSettle, v.settle)
+	assert.Equal(t, defaultVirtualMaxScrolls, v.MaxScrolls)
+	assert.Equal(t, v.rowKey(map[string]interface{}{"id": "1", "name": "Ada"}), v.rowKey(map[string]interface{}{"name": "Ada", "id": "1"}),
+		"without a key, rows with the same fields are the same row")
+	assert.NotEqual(t, v.rowKey(map[string]interface{}{"id": "1", "name": "Ada"}), v.rowKey(map[string]interface{}{"id": "2", "name": "Ada"}))
+
+	v, err = newVirtualScroll(taskstypes.Action{Type: taskstypes.ActionExtract, Selector: ".ag-row", Fields: fields, Virtual: &taskstypes.VirtualList{Key: "id", Settle: "1s"}})
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, v.settle)
+	assert.Equal(t, v.rowKey(map[string]interface{}{"id": "1", "name": "Ada"}), v.rowKey(map[string]interface{}{"id": "1", "name": "Ada Lovelace"}),
+		"rows with the same key are the same row, even when re-rendered differently")
+	assert.NotEqual(t, v.rowKey(map[string]interface{}{"id": nil, "name": "Ada"}), v.rowKey(map[string]interface{}{"id": nil, "name": "Bob"}),
+		"rows without a key value are compared whole")
+
+	for _, spec := range []taskstypes.VirtualList{{Step: -1}, {MaxRows: -1}, {Settle: "-1s"}} {
+		_, err := newVirtualScroll(taskstypes.Action{Type: taskstypes.ActionExtract, Selector: ".ag-row", Fields: fields, Virtual: &spec})
+		assert.Error(t, err, "%+v", spec)
+	}
+}
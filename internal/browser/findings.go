@@ -0,0 +1,119 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/chromedp/cdproto/audits"
+	"github.com/chromedp/cdproto/log"
+	"github.com/chromedp/chromedp"
+)
+
+const maxSecurityFindings = 200
+
+// Security finding types.
+const (
+	FindingMixedContent    = "mixed_content"
+	FindingCSPViolation    = "csp_violation"
+	FindingConsoleSecurity = "console_security"
+)
+
+// SecurityFinding is a mixed-content, CSP, or browser security warning seen
+// while the task ran.
+type SecurityFinding struct {
+	Type        string `json:"type"`
+	PageURL     string `json:"page_url,omitempty"`
+	ResourceURL string `json:"resource_url,omitempty"`
+	Directive   string `json:"directive,omitempty"`  // Violated CSP directive
+	Resolution  string `json:"resolution,omitempty"` // Mixed content: blocked, automatically upgraded, or warning only
+	Blocked     bool   `json:"blocked"`
+	ReportOnly  bool   `json:"report_only,omitempty"`
+	Source      string `json:"source,omitempty"` // Script location for CSP violations
+	Message     string `json:"message,omitempty"`
+}
+
+// findingsCollector turns Audits issues and security console entries into findings.
+type findingsCollector struct {
+	mu       sync.Mutex
+	findings []SecurityFinding
+	seen     map[SecurityFinding]struct{}
+}
+
+func newFindingsCollector() *findingsCollector {
+	return &findingsCollector{seen: make(map[SecurityFinding]struct{})}
+}
+
+// install enables the Audits domain, which reports mixed content and CSP
+// issues. The Log domain is already enabled by chromedp.
+func (c *findingsCollector) install(ctx context.Context) error {
+	chromedp.ListenTarget(ctx, c.handleEvent)
+	return chromedp.Run(ctx, audits.Enable())
+}
+
+func (c *findingsCollector) handleEvent(ev interface{}) {
+	switch ev := ev.(type) {
+	case *audits.EventIssueAdded:
+		if ev.Issue == nil || ev.Issue.Details == nil {
+			return
+		}
+		if mc := ev.Issue.Details.MixedContentIssueDetails; mc != nil {
+			c.add(SecurityFinding{
+				Type:        FindingMixedContent,
+				PageURL:     mc.MainResourceURL,
+				ResourceURL: mc.InsecureURL,
+				Resolution:  string(mc.ResolutionStatus),
+				Blocked:     mc.ResolutionStatus == audits.MixedContentResolutionStatusMixedContentBlocked,
+				Message:     fmt.Sprintf("insecure %s loaded over HTTP", resourceLabel(string(mc.ResourceType))),
+			})
+		}
+		if csp := ev.Issue.Details.ContentSecurityPolicyIssueDetails; csp != nil {
+			finding := SecurityFinding{
+				Type:        FindingCSPViolation,
+				ResourceURL: csp.BlockedURL,
+				Directive:   csp.ViolatedDirective,
+				Blocked:     !csp.IsReportOnly,
+				ReportOnly:  csp.IsReportOnly,
+				Message:     string(csp.ContentSecurityPolicyViolationType),
+			}
+			if loc := csp.SourceCodeLocation; loc != nil {
+				finding.Source = fmt.Sprintf("%s:%d:%d", loc.URL, loc.LineNumber+1, loc.ColumnNumber+1)
+			}
+			c.add(finding)
+		}
+	case *log.EventEntryAdded:
+		if ev.Entry == nil || ev.Entry.Source != log.SourceSecurity {
+			return
+		}
+		c.add(SecurityFinding{
+			Type:        FindingConsoleSecurity,
+			ResourceURL: ev.Entry.URL,
+			Blocked:     ev.Entry.Level == log.LevelError,
+			Message:     ev.Entry.Text,
+		})
+	}
+}
+
+func resourceLabel(resourceType string) string {
+	if resourceType == "" {
+		return "resource"
+	}
+	return resourceType
+}
+
+// add records a finding once; pages often repeat the same violation.
+func (c *findingsCollector) add(f SecurityFinding) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, dup := c.seen[f]; dup || len(c.findings) >= maxSecurityFindings {
+		return
+	}
+	c.seen[f] = struct{}{}
+	c.findings = append(c.findings, f)
+}
+
+func (c *findingsCollector) report() []SecurityFinding {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]SecurityFinding{}, c.findings...)
+}
@@ -0,0 +1,54 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/audits"
+	"github.com/chromedp/cdproto/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindingsCollector(t *testing.T) {
+	c := newFindingsCollector()
+
+	mixed := &audits.EventIssueAdded{Issue: &audits.InspectorIssue{
+		Code: audits.InspectorIssueCodeMixedContentIssue,
+		Details: &audits.InspectorIssueDetails{MixedContentIssueDetails: &audits.MixedContentIssueDetails{
+			ResourceType:     audits.MixedContentResourceTypeScript,
+			ResolutionStatus: audits.MixedContentResolutionStatusMixedContentBlocked,
+			InsecureURL:      "http://cdn.example.com/app.js",
+			MainResourceURL:  "https://example.com/",
+		}},
+	}}
+	c.handleEvent(mixed)
+	c.handleEvent(mixed) // Duplicates are reported once
+
+	c.handleEvent(&audits.EventIssueAdded{Issue: &audits.InspectorIssue{
+		Code: audits.InspectorIssueCodeContentSecurityPolicyIssue,
+		Details: &audits.InspectorIssueDetails{ContentSecurityPolicyIssueDetails: &audits.ContentSecurityPolicyIssueDetails{
+			ViolatedDirective:                  "script-src",
+			IsReportOnly:                       true,
+			ContentSecurityPolicyViolationType: audits.ContentSecurityPolicyViolationTypeKInlineViolation,
+			SourceCodeLocation:                 &audits.SourceCodeLocation{URL: "https://example.com/", LineNumber: 9, ColumnNumber: 0},
+		}},
+	}})
+
+	c.handleEvent(&log.EventEntryAdded{Entry: &log.Entry{Source: log.SourceSecurity, Level: log.LevelWarning, Text: "insecure form"}})
+	c.handleEvent(&log.EventEntryAdded{Entry: &log.Entry{Source: log.SourceJavascript, Level: log.LevelError, Text: "TypeError"}})
+
+	findings := c.report()
+	require.Len(t, findings, 3)
+
+	assert.Equal(t, FindingMixedContent, findings[0].Type)
+	assert.True(t, findings[0].Blocked)
+	assert.Equal(t, "http://cdn.example.com/app.js", findings[0].ResourceURL)
+
+	assert.Equal(t, FindingCSPViolation, findings[1].Type)
+	assert.Equal(t, "script-src", findings[1].Directive)
+	assert.True(t, findings[1].ReportOnly)
+	assert.False(t, findings[1].Blocked)
+	assert.Equal(t, "https://example.com/:10:1", findings[1].Source)
+
+	assert.Equal(t, FindingConsoleSecurity, findings[2].Type)
+}
@@ -0,0 +1,103 @@
+package browser
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+const (
+	defaultFindContext    = 40
+	defaultFindMaxMatches = 100
+	maxFindContext        = 1000
+)
+
+// findTextScript searches the visible text under the scope element, or the
+// body, with runs of whitespace collapsed so text split across elements and
+// line breaks still matches. Each listed match carries the text around it
+// and a selector of the element holding its first character: the nearest
+// ancestor with an id, followed by :nth-of-type steps.
+const findTextScript = `(function(scope, query, opts) {
+	const root = scope ? document.querySelector(scope) : (document.body || document.documentElement);
+	if (!root) throw new Error('no element matches ' + scope);
+	const skip = {SCRIPT: 1, STYLE: 1, NOSCRIPT: 1, TEMPLATE: 1};
+	const visible = function(el) {
+		if (el.checkVisibility) return el.checkVisibility({visibilityProperty: true});
+		return el.getClientRects().length > 0;
+	};
+	const walker = document.createTreeWalker(root, NodeFilter.SHOW_TEXT, {acceptNode: function(n) {
+		const el = n.parentElement;
+		return el && !skip[el.tagName] && visible(el) ? NodeFilter.FILTER_ACCEPT : NodeFilter.FILTER_REJECT;
+	}});
+	let text = '';
+	const nodes = [];
+	for (let n = walker.nextNode(); n; n = walker.nextNode()) {
+		let data = n.data.replace(/\s+/g, ' ');
+		if (data[0] === ' ' && (text === '' || text.endsWith(' '))) data = data.slice(1);
+		if (!data) continue;
+		nodes.push({el: n.parentElement, start: text.length});
+		text += data;
+	}
+	const selector = function(el) {
+		const steps = [];
+		for (; el && el.nodeType === 1; el = el.parentElement) {
+			if (el.id) { steps.unshift('#' + CSS.escape(el.id)); break; }
+			if (el === document.documentElement) { steps.unshift('html'); break; }
+			let nth = 1;
+			for (let s = el.previousElementSibling; s; s = s.previousElementSibling) if (s.tagName === el.tagName) nth++;
+			steps.unshift(el.tagName.toLowerCase() + ':nth-of-type(' + nth + ')');
+		}
+		return steps.join(' > ');
+	};
+	const source = opts.regex ? query : query.replace(/[.*+?^${}()|[\]\\]/g, '\\$&').replace(/\s+/g, '\\s+');
+	const pattern = new RegExp(source, opts.case_sensitive ? 'g' : 'gi');
+	const matches = [];
+	let count = 0, node = 0;
+	for (let m = pattern.exec(text); m; m = pattern.exec(text)) {
+		if (m[0] === '') { pattern.lastIndex++; continue; }
+		count++;
+		if (matches.length >= opts.max_matches) continue;
+		while (node + 1 < nodes.length && nodes[node + 1].start <= m.index) node++;
+		matches.push({
+			text: m[0],
+			context: text.slice(Math.max(0, m.index - opts.context), m.index + m[0].length + opts.context).trim(),
+			selector: selector(nodes[node].el)
+		});
+	}
+	return {count: count, matches: matches};
+})(%s, %s, %s)`
+
+// findTextAction searches the page's visible text for action.Value, within
+// action.Selector if set, and stores {"count", "matches"} in out, each match
+// with its "text", "context", and "selector". Finding nothing is not an
+// error; the count is zero. The output is kept as decoded JSON so later
+// actions can reference it, e.g. {{actions.2.result.count}}.
+func findTextAction(action taskstypes.Action, out *interface{}) (chromedp.Action, error) {
+	if action.Value == "" {
+		return nil, fmt.Errorf("find_text action requires the text to find in value")
+	}
+	var search taskstypes.TextSearch
+	if action.Find != nil {
+		search = *action.Find
+	}
+	if search.Context < 0 || search.Context > maxFindContext {
+		return nil, fmt.Errorf("find_text context must be between 0 and %d characters, got %d", maxFindContext, search.Context)
+	}
+	if search.MaxMatches < 0 {
+		return nil, fmt.Errorf("find_text max_matches cannot be negative, got %d", search.MaxMatches)
+	}
+	if search.Context == 0 {
+		search.Context = defaultFindContext
+	}
+	if search.MaxMatches == 0 {
+		search.MaxMatches = defaultFindMaxMatches
+	}
+	opts, err := json.Marshal(search)
+	if err != nil {
+		return nil, err
+	}
+	script := fmt.Sprintf(findTextScript, jsString(action.Selector), jsString(action.Value), opts)
+	return chromedp.Evaluate(script, out), nil
+}
@@ -0,0 +1,29 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindTextAction(t *testing.T) {
+	var out interface{}
+	_, err := findTextAction(taskstypes.Action{Type: taskstypes.ActionFindText}, &out)
+	assert.Error(t, err, "the text to find is required")
+
+	for _, search := range []taskstypes.TextSearch{{Context: -1}, {Context: maxFindContext + 1}, {MaxMatches: -1}} {
+		_, err := findTextAction(taskstypes.Action{Type: taskstypes.ActionFindText, Value: "Total", Find: &search}, &out)
+		assert.Error(t, err, "%+v", search)
+	}
+
+	action, err := findTextAction(taskstypes.Action{
+		Type:     taskstypes.ActionFindText,
+		Selector: "#cart",
+		Value:    `Total: \$\d+`,
+		Find:     &taskstypes.TextSearch{Regex: true, MaxMatches: 5},
+	}, &out)
+	require.NoError(t, err)
+	assert.NotNil(t, action)
+}
@@ -0,0 +1,141 @@
+package browser
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"golang.org/x/net/publicsuffix"
+)
+
+const maxBlockedURLsReported = 50
+
+// BlockedRequests summarizes third-party requests refused in first-party-only mode.
+type BlockedRequests struct {
+	Count int      `json:"count"`
+	Hosts []string `json:"hosts"`
+	URLs  []string `json:"urls"` // First maxBlockedURLsReported blocked URLs
+}
+
+// firstPartyFilter pauses every request and fails those whose site (eTLD+1)
+// differs from the site of the most recent navigate action, unless the host
+// is allowlisted.
+type firstPartyFilter struct {
+	allow []string  // Hosts; "*.example.com" also matches subdomains
+	guard *urlGuard // Checked first; nil without a URL policy
+
+	mu      sync.Mutex
+	site    string
+	count   int
+	hosts   map[string]struct{}
+	blocked []string
+}
+
+func newFirstPartyFilter(allowlist []string) *firstPartyFilter {
+	allow := make([]string, 0, len(allowlist))
+	for _, host := range allowlist {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			allow = append(allow, host)
+		}
+	}
+	return &firstPartyFilter{allow: allow, hosts: make(map[string]struct{})}
+}
+
+// siteOf returns the registrable domain of a URL's host, or the host itself
+// for IPs and single-label hosts such as localhost.
+func siteOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	if site, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return site
+	}
+	return host
+}
+
+// setSite records the first-party site for the navigation about to start.
+func (f *firstPartyFilter) setSite(navigateURL string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.site = siteOf(navigateURL)
+}
+
+// allowed reports whether a request URL may proceed.
+func (f *firstPartyFilter) allowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, a := range f.allow {
+		if host == a || (strings.HasPrefix(a, "*.") && (host == a[2:] || strings.HasSuffix(host, a[1:]))) {
+			return true
+		}
+	}
+
+	f.mu.Lock()
+	site := f.site
+	f.mu.Unlock()
+	// Before the first navigation there is nothing to compare against
+	return site == "" || siteOf(rawURL) == site
+}
+
+func (f *firstPartyFilter) recordBlocked(rawURL string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count++
+	if u, err := url.Parse(rawURL); err == nil {
+		f.hosts[u.Hostname()] = struct{}{}
+	}
+	if len(f.blocked) < maxBlockedURLsReported {
+		f.blocked = append(f.blocked, rawURL)
+	}
+}
+
+// install enables request interception on the browser context. Requests are
+// resolved from a goroutine because listeners must not block on CDP calls.
+func (f *firstPartyFilter) install(ctx context.Context) error {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		go func() {
+			var action chromedp.Action = fetch.ContinueRequest(paused.RequestID)
+			switch {
+			case !f.guard.allows(ctx, paused):
+				action = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient)
+			case !f.allowed(paused.Request.URL):
+				f.recordBlocked(paused.Request.URL)
+				action = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient)
+			}
+			// Errors here mean the target went away; nothing left to resolve
+			_ = chromedp.Run(ctx, action)
+		}()
+	})
+	return chromedp.Run(ctx, fetch.Enable())
+}
+
+// uninstall stops interception, restoring a named session's page to normal.
+func (f *firstPartyFilter) uninstall(ctx context.Context) error {
+	return chromedp.Run(ctx, fetch.Disable())
+}
+
+func (f *firstPartyFilter) report() BlockedRequests {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hosts := make([]string, 0, len(f.hosts))
+	for host := range f.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return BlockedRequests{Count: f.count, Hosts: hosts, URLs: append([]string(nil), f.blocked...)}
+}
@@ -0,0 +1,42 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstPartyFilter_Allowed(t *testing.T) {
+	f := newFirstPartyFilter([]string{"cdn.partner.net", "*.fonts.example", " "})
+
+	assert.True(t, f.allowed("https://tracker.example/pixel"), "nothing is blocked before the first navigation")
+
+	f.setSite("https://shop.example.co.uk/cart")
+	assert.True(t, f.allowed("https://static.example.co.uk/app.js"), "same registrable domain")
+	assert.True(t, f.allowed("https://example.co.uk/"))
+	assert.False(t, f.allowed("https://other.co.uk/x.js"), "different site under the same public suffix")
+	assert.False(t, f.allowed("https://www.google-analytics.com/collect"))
+
+	assert.True(t, f.allowed("https://cdn.partner.net/lib.js"), "exact allowlist host")
+	assert.False(t, f.allowed("https://img.partner.net/a.png"))
+	assert.True(t, f.allowed("https://a.b.fonts.example/font.woff2"), "wildcard allowlist")
+	assert.True(t, f.allowed("https://fonts.example/font.woff2"))
+
+	assert.True(t, f.allowed("data:image/png;base64,AAAA"), "non-network schemes pass through")
+
+	f.setSite("http://localhost:8080/")
+	assert.True(t, f.allowed("http://localhost:9090/api"))
+	assert.False(t, f.allowed("https://cdn.example.com/x.js"))
+}
+
+func TestFirstPartyFilter_Report(t *testing.T) {
+	f := newFirstPartyFilter(nil)
+	f.recordBlocked("https://b.example/1")
+	f.recordBlocked("https://a.example/2")
+	f.recordBlocked("https://b.example/3")
+
+	report := f.report()
+	assert.Equal(t, 3, report.Count)
+	assert.Equal(t, []string{"a.example", "b.example"}, report.Hosts)
+	assert.Len(t, report.URLs, 3)
+}
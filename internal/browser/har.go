@@ -0,0 +1,262 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// harArtifact is the name a task's HAR archive is saved under in the artifact store.
+const harArtifact = "network.har"
+
+// HARInfo describes the HAR archive saved for a task, in result.custom_data.har.
+type HARInfo struct {
+	Name    string `json:"name"`
+	Path    string `json:"path,omitempty"` // Set by the local backend
+	Size    int64  `json:"size"`
+	Entries int    `json:"entries"`
+}
+
+// HAR 1.2 (http://www.softwareishard.com/blog/har-12-spec/), limited to the
+// fields a NetworkEntry can fill.
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int64          `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	SHA1     string `json:"_sha1,omitempty"` // Body's resource in a Playwright trace
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// buildHAR converts recorded network entries into a HAR log, oldest first.
+func buildHAR(entries []NetworkEntry) harFile {
+	har := harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "goscry", Version: "1.0"},
+		Entries: make([]harEntry, 0, len(entries)),
+	}}
+	for _, e := range entries {
+		har.Log.Entries = append(har.Log.Entries, harEntryFor(e))
+	}
+	sort.SliceStable(har.Log.Entries, func(i, j int) bool {
+		return har.Log.Entries[i].StartedDateTime < har.Log.Entries[j].StartedDateTime
+	})
+	return har
+}
+
+func harEntryFor(e NetworkEntry) harEntry {
+	version := harHTTPVersion(e.Protocol)
+	entry := harEntry{
+		StartedDateTime: e.StartedAt.UTC().Format(time.RFC3339Nano),
+		Time:            e.DurationMs,
+		Request: harRequest{
+			Method:      e.Method,
+			URL:         e.URL,
+			HTTPVersion: version,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(e.RequestHeaders),
+			QueryString: harQuery(e.URL),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: harResponse{
+			Status:      e.Status,
+			StatusText:  e.StatusText,
+			HTTPVersion: version,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(e.ResponseHeaders),
+			Content:     harContent{Size: e.EncodedBytes, MimeType: e.MIMEType},
+			RedirectURL: headerValue(e.ResponseHeaders, "Location"),
+			HeadersSize: -1,
+			BodySize:    e.EncodedBytes,
+		},
+		Timings:         harTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: e.DurationMs},
+		ServerIPAddress: e.RemoteIP,
+		Comment:         fmt.Sprintf("action %d", e.Action),
+	}
+	if e.Failed {
+		// HAR has no failure field; status 0 with the error is what browsers export
+		entry.Response.Status = 0
+		entry.Response.Comment = e.ErrorText
+		entry.Response.BodySize = 0
+	}
+	if e.RequestBody != "" {
+		entry.Request.PostData = &harPostData{MimeType: headerValue(e.RequestHeaders, "Content-Type"), Text: e.RequestBody}
+		entry.Request.BodySize = len(e.RequestBody)
+	}
+	if e.ResponseBody != "" {
+		entry.Response.Content.Text = e.ResponseBody
+		if e.BodyBase64 {
+			entry.Response.Content.Encoding = "base64"
+		}
+	}
+
+	if t := e.Timing; t != nil {
+		entry.Timings = harTimings{
+			Blocked: t.BlockedMs,
+			DNS:     t.DNSMs,
+			Connect: t.ConnectMs,
+			SSL:     t.SSLMs,
+			Send:    t.SendMs,
+			Wait:    t.WaitMs,
+		}
+		// Receive is whatever the other phases leave of the total; SSL is part of Connect
+		spent := t.SendMs + t.WaitMs
+		for _, phase := range []float64{t.BlockedMs, t.DNSMs, t.ConnectMs} {
+			if phase > 0 {
+				spent += phase
+			}
+		}
+		entry.Timings.Receive = max(e.DurationMs-spent, 0)
+	}
+	return entry
+}
+
+// harHTTPVersion maps Chrome's ALPN protocol names to HTTP versions.
+func harHTTPVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "":
+		return ""
+	case "h2":
+		return "HTTP/2"
+	case "h3", "h3-29", "quic":
+		return "HTTP/3"
+	case "http/1.0":
+		return "HTTP/1.0"
+	case "http/1.1":
+		return "HTTP/1.1"
+	}
+	return protocol
+}
+
+func harHeaders(headers map[string]string) []harNameValue {
+	pairs := make([]harNameValue, 0, len(headers))
+	for name, value := range headers {
+		pairs = append(pairs, harNameValue{Name: name, Value: value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+func harQuery(rawURL string) []harNameValue {
+	pairs := []harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return pairs
+	}
+	for _, part := range strings.Split(u.RawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		name, _ = url.QueryUnescape(name)
+		value, _ = url.QueryUnescape(value)
+		pairs = append(pairs, harNameValue{Name: name, Value: value})
+	}
+	return pairs
+}
+
+// headerValue looks up a header case-insensitively, as CDP keeps the case the server sent.
+func headerValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// saveHAR saves the task's HAR archive in the artifact store as "network.har".
+func (m *Manager) saveHAR(ctx context.Context, task *taskstypes.Task, entries []NetworkEntry) (HARInfo, error) {
+	data, err := json.Marshal(buildHAR(entries))
+	if err != nil {
+		return HARInfo{}, fmt.Errorf("failed to encode HAR: %w", err)
+	}
+	if size := int64(len(data)); m.overArtifactLimit(size) {
+		return HARInfo{}, m.rejectArtifact("HAR archive", size)
+	}
+	obj, err := m.artifactStore().Put(encryption.WithTenant(ctx, task.Tenant), task.ID, harArtifact, bytes.NewReader(data))
+	if err != nil {
+		return HARInfo{}, fmt.Errorf("failed to save HAR: %w", err)
+	}
+	m.recordArtifact(obj.Size)
+	return HARInfo{Name: obj.Name, Path: obj.Path, Size: obj.Size, Entries: len(entries)}, nil
+}
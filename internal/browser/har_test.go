@@ -0,0 +1,68 @@
+package browser
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkTiming(t *testing.T) {
+	timing := networkTiming(&network.ResourceTiming{
+		DNSStart: 1, DNSEnd: 5, ConnectStart: 5, ConnectEnd: 30, SslStart: 12, SslEnd: 30,
+		SendStart: 31, SendEnd: 32, ReceiveHeadersEnd: 80,
+	})
+	assert.Equal(t, &NetworkTiming{BlockedMs: 1, DNSMs: 4, ConnectMs: 25, SSLMs: 18, SendMs: 1, WaitMs: 48}, timing)
+
+	// A reused connection skips DNS, connect, and SSL
+	timing = networkTiming(&network.ResourceTiming{
+		DNSStart: -1, DNSEnd: -1, ConnectStart: -1, ConnectEnd: -1, SslStart: -1, SslEnd: -1,
+		SendStart: 2, SendEnd: 3, ReceiveHeadersEnd: 10,
+	})
+	assert.Equal(t, &NetworkTiming{BlockedMs: 2, DNSMs: -1, ConnectMs: -1, SSLMs: -1, SendMs: 1, WaitMs: 7}, timing)
+	assert.Nil(t, networkTiming(nil))
+}
+
+func TestBuildHAR(t *testing.T) {
+	start := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	har := buildHAR([]NetworkEntry{
+		{
+			URL: "https://example.com/api?q=a%20b&page=2", Method: "POST", Status: 302, StatusText: "Found",
+			MIMEType: "application/json", StartedAt: start.Add(time.Second), DurationMs: 100, EncodedBytes: 512,
+			RequestHeaders:  map[string]string{"Content-Type": "application/json", "Accept": "*/*"},
+			ResponseHeaders: map[string]string{"location": "/next"},
+			RequestBody:     `{"a":1}`, ResponseBody: `{"ok":true}`,
+			Protocol: "h2", RemoteIP: "93.184.216.34", Action: 2,
+			Timing: &NetworkTiming{BlockedMs: 1, DNSMs: 4, ConnectMs: 25, SSLMs: 18, SendMs: 1, WaitMs: 48},
+		},
+		{URL: "https://example.com/", Method: "GET", StartedAt: start, Failed: true, ErrorText: "net::ERR_ABORTED"},
+	})
+
+	require.Len(t, har.Log.Entries, 2)
+	assert.Equal(t, "1.2", har.Log.Version)
+
+	failed := har.Log.Entries[0]
+	assert.Equal(t, "https://example.com/", failed.Request.URL, "entries are ordered by start time")
+	assert.Equal(t, int64(0), failed.Response.Status)
+	assert.Equal(t, "net::ERR_ABORTED", failed.Response.Comment)
+
+	entry := har.Log.Entries[1]
+	assert.Equal(t, "2025-04-01T12:00:01Z", entry.StartedDateTime)
+	assert.Equal(t, "HTTP/2", entry.Request.HTTPVersion)
+	assert.Equal(t, []harNameValue{{"Accept", "*/*"}, {"Content-Type", "application/json"}}, entry.Request.Headers)
+	assert.Equal(t, []harNameValue{{"q", "a b"}, {"page", "2"}}, entry.Request.QueryString)
+	assert.Equal(t, &harPostData{MimeType: "application/json", Text: `{"a":1}`}, entry.Request.PostData)
+	assert.Equal(t, "/next", entry.Response.RedirectURL)
+	assert.Equal(t, harContent{Size: 512, MimeType: "application/json", Text: `{"ok":true}`}, entry.Response.Content)
+	assert.Equal(t, harTimings{Blocked: 1, DNS: 4, Connect: 25, SSL: 18, Send: 1, Wait: 48, Receive: 21}, entry.Timings)
+	assert.Equal(t, "93.184.216.34", entry.ServerIPAddress)
+
+	// Required arrays are present even when empty
+	raw, err := json.Marshal(har)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"cookies":[]`)
+	assert.Contains(t, string(raw), `"queryString":[]`)
+}
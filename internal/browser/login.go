@@ -0,0 +1,302 @@
+package browser
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// ErrLoginFailed is returned when a login action's verification fails.
+var ErrLoginFailed = errors.New("login failed")
+
+const (
+	defaultUsernameSelector = "#username, input[autocomplete='username'], input[name='username'], input[type='email']"
+	defaultPasswordSelector = "#password, input[autocomplete='current-password'], input[type='password']"
+)
+
+// loginPresets are the selectors of well-known sign-in pages, by preset name.
+// Presets with a NextSelector ask for the username and password on separate
+// steps.
+var loginPresets = map[string]taskstypes.LoginForm{
+	"google": {
+		UsernameSelector: "#identifierId",
+		NextSelector:     "#identifierNext button",
+		PasswordSelector: "input[name='Passwd']",
+		SubmitSelector:   "#passwordNext button",
+	},
+	"microsoft": {
+		UsernameSelector: "input[name='loginfmt']",
+		NextSelector:     "#idSIButton9",
+		PasswordSelector: "input[name='passwd']",
+		SubmitSelector:   "#idSIButton9",
+	},
+	"okta": {
+		UsernameSelector: "input[name='identifier']",
+		NextSelector:     "input[type='submit']",
+		PasswordSelector: "input[name='credentials.passcode']",
+		SubmitSelector:   "input[type='submit']",
+	},
+	"auth0": {
+		UsernameSelector: "input#username",
+		PasswordSelector: "input#password",
+		SubmitSelector:   "button[type='submit'][name='action']",
+	},
+	"github": {
+		UsernameSelector: "#login_field",
+		PasswordSelector: "#password",
+		SubmitSelector:   "input[type='submit'][name='commit']",
+	},
+	"wordpress": {
+		UsernameSelector: "#user_login",
+		PasswordSelector: "#user_pass",
+		SubmitSelector:   "#wp-submit",
+	},
+}
+
+// loginPresetNames lists the presets in name order.
+func loginPresetNames() []string {
+	names := make([]string, 0, len(loginPresets))
+	for name := range loginPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loginForm resolves a login action's form: its preset, overridden by the
+// selectors it sets, with defaults for the rest.
+func loginForm(action taskstypes.Action) (taskstypes.LoginForm, error) {
+	var form taskstypes.LoginForm
+	if action.Login != nil {
+		form = *action.Login
+	}
+	if form.Preset != "" {
+		preset, ok := loginPresets[form.Preset]
+		if !ok {
+			return form, fmt.Errorf("unknown login preset %q", form.Preset)
+		}
+		form.UsernameSelector = cmp.Or(form.UsernameSelector, preset.UsernameSelector)
+		form.PasswordSelector = cmp.Or(form.PasswordSelector, preset.PasswordSelector)
+		form.SubmitSelector = cmp.Or(form.SubmitSelector, preset.SubmitSelector)
+		form.NextSelector = cmp.Or(form.NextSelector, preset.NextSelector)
+	}
+	form.UsernameSelector = cmp.Or(form.UsernameSelector, defaultUsernameSelector)
+	form.PasswordSelector = cmp.Or(form.PasswordSelector, defaultPasswordSelector)
+	form.SubmitSelector = cmp.Or(form.SubmitSelector, defaultSubmitSelector)
+	return form, nil
+}
+
+// fillLoginForm types the username and password and submits them. With a
+// NextSelector, the username is submitted on its own first and the password
+// field is waited for on the next step.
+func fillLoginForm(form taskstypes.LoginForm, creds *taskstypes.Credentials) chromedp.Tasks {
+	steps := chromedp.Tasks{
+		chromedp.WaitVisible(form.UsernameSelector, chromedp.ByQuery),
+		chromedp.SendKeys(form.UsernameSelector, creds.Username, chromedp.ByQuery),
+	}
+	if form.NextSelector != "" {
+		steps = append(steps,
+			chromedp.WaitVisible(form.NextSelector, chromedp.ByQuery),
+			chromedp.Click(form.NextSelector, chromedp.ByQuery),
+		)
+	}
+	return append(steps,
+		chromedp.WaitVisible(form.PasswordSelector, chromedp.ByQuery),
+		chromedp.SendKeys(form.PasswordSelector, creds.Password, chromedp.ByQuery),
+		chromedp.WaitVisible(form.SubmitSelector, chromedp.ByQuery),
+		chromedp.Click(form.SubmitSelector, chromedp.ByQuery),
+	)
+}
+
+const (
+	// loginVerifyWindow is how long a login waits for its success condition.
+	loginVerifyWindow = 10 * time.Second
+	loginPollInterval = 250 * time.Millisecond
+)
+
+// failureTextScript returns the text of the first visible element matching
+// the selector, or null when there is none.
+const failureTextScript = `(function(sel) {
+	for (const el of document.querySelectorAll(sel)) {
+		const style = window.getComputedStyle(el);
+		const rect = el.getBoundingClientRect();
+		if (style.display !== 'none' && style.visibility !== 'hidden' && rect.width > 0 && rect.height > 0) {
+			return (el.innerText || el.textContent || '').trim();
+		}
+	}
+	return null;
+})(%s)`
+
+// defaultLoginFailureText are messages sites commonly show when they reject
+// a login, matched case-insensitively.
+var defaultLoginFailureText = []string{
+	"incorrect password",
+	"wrong password",
+	"invalid password",
+	"password is incorrect",
+	"password you entered is incorrect",
+	"incorrect username or password",
+	"invalid username or password",
+	"incorrect email or password",
+	"invalid email or password",
+	"invalid login",
+	"invalid credentials",
+	"login failed",
+	"authentication failed",
+	"account has been locked",
+	"account is locked",
+}
+
+// shownTextScript returns the first of the given texts the page shows,
+// ignoring case, or null.
+const shownTextScript = `(function(texts) {
+	const shown = (document.body ? document.body.innerText : '').toLowerCase();
+	return texts.find(t => shown.includes(t.toLowerCase())) || null;
+})(%s)`
+
+// verifyLoginAction polls the page after a login (or password change) is
+// submitted until the check's success conditions hold, a failure banner or
+// message appears, or loginVerifyWindow passes. Failures wrap failed.
+//
+// For a login, form is the password field: while it is still shown, the
+// page is checked for failure text (by default, common messages such as
+// "incorrect password"), and when the check sets no success condition,
+// its disappearing is what counts as success.
+func verifyLoginAction(check taskstypes.LoginCheck, form string, failed error) (chromedp.Action, error) {
+	var successURL *regexp.Regexp
+	if check.SuccessURL != "" {
+		var err error
+		if successURL, err = regexp.Compile(check.SuccessURL); err != nil {
+			return nil, fmt.Errorf("invalid verify.success_url: %w", err)
+		}
+	}
+	for _, text := range check.FailureText {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("verify.failure_text cannot contain empty text")
+		}
+	}
+	failureText := check.FailureText
+	if len(failureText) == 0 && form != "" {
+		failureText = defaultLoginFailureText
+	}
+	texts, err := json.Marshal(failureText)
+	if err != nil {
+		return nil, err
+	}
+	leaveForm := form != "" && successURL == nil && check.SuccessSelector == "" && check.SuccessCookie == ""
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		deadline := time.Now().Add(loginVerifyWindow)
+		for {
+			// The page may be navigating; errors only count once time is up
+			unmet, err := func() (string, error) {
+				for _, selector := range check.FailureSelectors {
+					var text *string
+					if err := chromedp.Evaluate(fmt.Sprintf(failureTextScript, jsString(selector)), &text).Do(ctx); err != nil {
+						return "", err
+					}
+					if text != nil {
+						return "", fmt.Errorf("%w: %s shown: %q", failed, selector, *text)
+					}
+				}
+
+				formShown := form == ""
+				if form != "" {
+					var err error
+					if formShown, err = evaluateSelector(ctx, visibleScript, form); err != nil {
+						return "", err
+					}
+				}
+				if formShown && len(failureText) > 0 {
+					var text *string
+					if err := chromedp.Evaluate(fmt.Sprintf(shownTextScript, texts), &text).Do(ctx); err != nil {
+						return "", err
+					}
+					if text != nil {
+						return "", fmt.Errorf("%w: the page says %q", failed, *text)
+					}
+				}
+
+				if leaveForm {
+					if formShown {
+						return "leaving the login form", nil
+					}
+					return "", nil
+				}
+				return unmetLoginCondition(ctx, check, successURL)
+			}()
+			switch {
+			case errors.Is(err, failed):
+				return err
+			case err == nil && unmet == "":
+				return nil
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case time.Now().After(deadline) && err != nil:
+				return err
+			case time.Now().After(deadline) && leaveForm:
+				return fmt.Errorf("%w: still on the login form after %s", failed, loginVerifyWindow)
+			case time.Now().After(deadline):
+				return fmt.Errorf("%w: %s not met within %s", failed, unmet, loginVerifyWindow)
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(loginPollInterval):
+			}
+		}
+	}), nil
+}
+
+// unmetLoginCondition names the first success condition that does not hold
+// yet, or returns "" when all of them do.
+func unmetLoginCondition(ctx context.Context, check taskstypes.LoginCheck, successURL *regexp.Regexp) (string, error) {
+	if successURL != nil {
+		var location string
+		if err := chromedp.Location(&location).Do(ctx); err != nil {
+			return "", err
+		}
+		if !successURL.MatchString(location) {
+			return "success_url", nil
+		}
+	}
+	if check.SuccessSelector != "" {
+		present, err := evaluateSelector(ctx, presentScript, check.SuccessSelector)
+		if err != nil {
+			return "", err
+		}
+		if !present {
+			return "success_selector", nil
+		}
+	}
+	if check.SuccessCookie != "" {
+		cookies, err := network.GetCookies().Do(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !hasCookie(cookies, check.SuccessCookie) {
+			return "success_cookie", nil
+		}
+	}
+	return "", nil
+}
+
+func hasCookie(cookies []*network.Cookie, name string) bool {
+	for _, c := range cookies {
+		if c.Name == name && c.Value != "" {
+			return true
+		}
+	}
+	return false
+}
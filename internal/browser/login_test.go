@@ -0,0 +1,44 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasCookie(t *testing.T) {
+	cookies := []*network.Cookie{{Name: "session", Value: "abc"}, {Name: "pending", Value: ""}}
+
+	assert.True(t, hasCookie(cookies, "session"))
+	assert.False(t, hasCookie(cookies, "pending"), "an empty cookie is not a login")
+	assert.False(t, hasCookie(cookies, "remember_me"))
+}
+
+func TestLoginForm(t *testing.T) {
+	form, err := loginForm(taskstypes.Action{Type: taskstypes.ActionLogin})
+	require.NoError(t, err)
+	assert.Equal(t, defaultUsernameSelector, form.UsernameSelector)
+	assert.Equal(t, defaultSubmitSelector, form.SubmitSelector)
+	assert.Empty(t, form.NextSelector, "one step unless asked for")
+
+	form, err = loginForm(taskstypes.Action{Type: taskstypes.ActionLogin, Login: &taskstypes.LoginForm{
+		Preset:         "microsoft",
+		SubmitSelector: "#custom-submit",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "input[name='loginfmt']", form.UsernameSelector)
+	assert.Equal(t, "#idSIButton9", form.NextSelector)
+	assert.Equal(t, "#custom-submit", form.SubmitSelector, "selectors set on the action override the preset")
+
+	_, err = loginForm(taskstypes.Action{Type: taskstypes.ActionLogin, Login: &taskstypes.LoginForm{Preset: "myspace"}})
+	assert.ErrorContains(t, err, "myspace")
+}
+
+func TestFillLoginForm(t *testing.T) {
+	creds := &taskstypes.Credentials{Username: "ops", Password: "hunter2"}
+	assert.Len(t, fillLoginForm(taskstypes.LoginForm{UsernameSelector: "#u", PasswordSelector: "#p", SubmitSelector: "#s"}, creds), 6)
+	assert.Len(t, fillLoginForm(loginPresets["google"], creds), 8, "two-step pages click next before the password")
+}
@@ -0,0 +1,449 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+const (
+	maxNetworkEntries      = 1000
+	defaultMaxBodyBytes    = 64 * 1024
+	networkStreamInterval  = time.Second
+	networkStreamBatchSize = 50
+)
+
+// NetworkEntry is one recorded request and its response.
+type NetworkEntry struct {
+	URL             string            `json:"url"`
+	Method          string            `json:"method"`
+	ResourceType    string            `json:"resource_type"`
+	Status          int64             `json:"status,omitempty"`
+	StatusText      string            `json:"status_text,omitempty"`
+	MIMEType        string            `json:"mime_type,omitempty"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	StartedAt       time.Time         `json:"started_at"`
+	DurationMs      float64           `json:"duration_ms"`
+	EncodedBytes    int64             `json:"encoded_bytes"`
+	Failed          bool              `json:"failed,omitempty"`
+	ErrorText       string            `json:"error_text,omitempty"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+	BodyBase64      bool              `json:"body_base64,omitempty"` // ResponseBody is base64 binary data
+	BodyTruncated   bool              `json:"body_truncated,omitempty"`
+	BodyBytes       int64             `json:"body_bytes,omitempty"`   // Full response body size when truncated or skipped
+	BodySkipped     string            `json:"body_skipped,omitempty"` // Why the body was left out: no_rule or too_large
+	Action          int               `json:"action"`                 // Index of the action running when the request started
+	Protocol        string            `json:"protocol,omitempty"`     // e.g. http/1.1, h2
+	RemoteIP        string            `json:"remote_ip,omitempty"`
+	Timing          *NetworkTiming    `json:"timing,omitempty"`
+}
+
+// NetworkTiming splits a request's time into phases, in milliseconds. Phases
+// that did not happen, such as DNS on a reused connection, are -1.
+type NetworkTiming struct {
+	BlockedMs float64 `json:"blocked_ms"` // Queued before the first network phase
+	DNSMs     float64 `json:"dns_ms"`
+	ConnectMs float64 `json:"connect_ms"` // Includes SSL
+	SSLMs     float64 `json:"ssl_ms"`
+	SendMs    float64 `json:"send_ms"`
+	WaitMs    float64 `json:"wait_ms"` // Until the response headers arrived
+}
+
+// Reasons a response body was not recorded.
+const (
+	bodySkippedNoRule   = "no_rule"
+	bodySkippedTooLarge = "too_large"
+)
+
+// bodyRule is a compiled taskstypes.BodyRule.
+type bodyRule struct {
+	pattern  *regexp.Regexp
+	mimes    []string
+	maxSize  int64
+	maxBytes int
+}
+
+// matches reports whether the rule covers a response.
+func (b *bodyRule) matches(url, mime string) bool {
+	if b.pattern != nil && !b.pattern.MatchString(url) {
+		return false
+	}
+	if len(b.mimes) == 0 {
+		return true
+	}
+	mime = strings.ToLower(strings.TrimSpace(strings.Split(mime, ";")[0]))
+	for _, want := range b.mimes {
+		if want == "*" || want == "*/*" || want == mime {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(want, "*"); ok && strings.HasPrefix(mime, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// networkRecorder collects requests matching a NetworkCapture filter.
+type networkRecorder struct {
+	opts    taskstypes.NetworkCapture
+	pattern *regexp.Regexp
+	types   map[string]bool
+	rules   []*bodyRule     // Empty records every body when opts.Bodies is set
+	ctx     context.Context // Browser context used to fetch bodies
+
+	mu       sync.Mutex
+	action   int
+	inflight map[network.RequestID]*pendingRequest
+	entries  []NetworkEntry
+	pending  []NetworkEntry // Completed but not yet streamed
+	bodies   sync.WaitGroup
+}
+
+type pendingRequest struct {
+	entry   NetworkEntry
+	started time.Time // Monotonic CDP timestamp
+	hasPost bool
+}
+
+func newNetworkRecorder(ctx context.Context, opts taskstypes.NetworkCapture) (*networkRecorder, error) {
+	r := &networkRecorder{
+		opts:     opts,
+		ctx:      ctx,
+		inflight: make(map[network.RequestID]*pendingRequest),
+	}
+	if opts.URLPattern != "" {
+		pattern, err := regexp.Compile(opts.URLPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network url_pattern: %w", err)
+		}
+		r.pattern = pattern
+	}
+	if len(opts.ResourceTypes) > 0 {
+		r.types = make(map[string]bool, len(opts.ResourceTypes))
+		for _, t := range opts.ResourceTypes {
+			r.types[strings.ToLower(t)] = true
+		}
+	}
+	if r.opts.MaxBodyBytes <= 0 {
+		r.opts.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	for i, rule := range opts.BodyRules {
+		compiled := &bodyRule{maxSize: rule.MaxSize, maxBytes: rule.MaxBodyBytes}
+		if rule.URLPattern != "" {
+			pattern, err := regexp.Compile(rule.URLPattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network body_rules[%d].url_pattern: %w", i, err)
+			}
+			compiled.pattern = pattern
+		}
+		for _, mime := range rule.MIMETypes {
+			compiled.mimes = append(compiled.mimes, strings.ToLower(mime))
+		}
+		if compiled.maxBytes <= 0 {
+			compiled.maxBytes = r.opts.MaxBodyBytes
+		}
+		r.rules = append(r.rules, compiled)
+		r.opts.Bodies = true
+	}
+	return r, nil
+}
+
+func (r *networkRecorder) beforeAction(index int) {
+	r.mu.Lock()
+	r.action = index
+	r.mu.Unlock()
+}
+
+func (r *networkRecorder) wants(url string, resourceType network.ResourceType) bool {
+	if r.pattern != nil && !r.pattern.MatchString(url) {
+		return false
+	}
+	return r.types == nil || r.types[strings.ToLower(string(resourceType))]
+}
+
+// bodyRuleFor returns the rule deciding whether entry's body is recorded, or
+// nil if no rule covers it.
+func (r *networkRecorder) bodyRuleFor(entry NetworkEntry) *bodyRule {
+	if len(r.rules) == 0 {
+		return &bodyRule{maxBytes: r.opts.MaxBodyBytes}
+	}
+	for _, rule := range r.rules {
+		if rule.matches(entry.URL, entry.MIMEType) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (r *networkRecorder) handleEvent(ev interface{}) {
+	switch ev := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if ev.RedirectResponse != nil {
+			// A redirect reuses the request ID; close out the previous hop
+			if req := r.inflight[ev.RequestID]; req != nil {
+				delete(r.inflight, ev.RequestID)
+				applyResponse(&req.entry, ev.RedirectResponse)
+				req.entry.DurationMs = elapsedMs(req.started, ev.Timestamp)
+				r.completeLocked(req.entry)
+			}
+		}
+		if ev.Request == nil || !r.wants(ev.Request.URL, ev.Type) {
+			return
+		}
+		entry := NetworkEntry{
+			URL:            ev.Request.URL,
+			Method:         ev.Request.Method,
+			ResourceType:   strings.ToLower(string(ev.Type)),
+			RequestHeaders: flattenHeaders(ev.Request.Headers),
+			Action:         r.action,
+		}
+		if ev.WallTime != nil {
+			entry.StartedAt = ev.WallTime.Time().UTC()
+		}
+		req := &pendingRequest{entry: entry, hasPost: ev.Request.HasPostData}
+		if ev.Timestamp != nil {
+			req.started = ev.Timestamp.Time()
+		}
+		r.inflight[ev.RequestID] = req
+	case *network.EventResponseReceived:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if req := r.inflight[ev.RequestID]; req != nil {
+			applyResponse(&req.entry, ev.Response)
+		}
+	case *network.EventLoadingFinished:
+		r.mu.Lock()
+		req := r.inflight[ev.RequestID]
+		delete(r.inflight, ev.RequestID)
+		r.mu.Unlock()
+		if req == nil {
+			return
+		}
+		req.entry.EncodedBytes = int64(ev.EncodedDataLength)
+		req.entry.DurationMs = elapsedMs(req.started, ev.Timestamp)
+		if !r.opts.Bodies {
+			r.complete(req.entry)
+			return
+		}
+		rule := r.bodyRuleFor(req.entry)
+		if rule == nil {
+			req.entry.BodySkipped = bodySkippedNoRule
+			r.complete(req.entry)
+			return
+		}
+		if rule.maxSize > 0 && req.entry.EncodedBytes > rule.maxSize {
+			// Skip the fetch so large bodies never reach memory
+			req.entry.BodySkipped = bodySkippedTooLarge
+			req.entry.BodyBytes = req.entry.EncodedBytes
+			r.complete(req.entry)
+			return
+		}
+		// CDP commands cannot be issued from the event handler, so fetch bodies separately
+		r.bodies.Add(1)
+		go func() {
+			defer r.bodies.Done()
+			r.fetchBodies(ev.RequestID, req, rule)
+			r.complete(req.entry)
+		}()
+	case *network.EventLoadingFailed:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		req := r.inflight[ev.RequestID]
+		delete(r.inflight, ev.RequestID)
+		if req == nil {
+			return
+		}
+		req.entry.Failed = true
+		req.entry.ErrorText = ev.ErrorText
+		req.entry.DurationMs = elapsedMs(req.started, ev.Timestamp)
+		r.completeLocked(req.entry)
+	}
+}
+
+// fetchBodies reads the request and response bodies of a finished request
+// under rule's limits. Bodies are best-effort: Chrome evicts them under
+// memory pressure.
+func (r *networkRecorder) fetchBodies(id network.RequestID, req *pendingRequest, rule *bodyRule) {
+	_ = chromedp.Run(r.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		if req.hasPost {
+			if post, err := network.GetRequestPostData(id).Do(ctx); err == nil {
+				req.entry.RequestBody, _ = truncateBody(post, rule.maxBytes)
+			}
+		}
+		body, err := network.GetResponseBody(id).Do(ctx)
+		if err != nil {
+			return err
+		}
+		applyBody(&req.entry, body, rule)
+		return nil
+	}))
+}
+
+// applyBody records a fetched response body on entry, or marks it skipped or
+// truncated with its full size.
+func applyBody(entry *NetworkEntry, body []byte, rule *bodyRule) {
+	if rule.maxSize > 0 && int64(len(body)) > rule.maxSize {
+		entry.BodySkipped = bodySkippedTooLarge
+		entry.BodyBytes = int64(len(body))
+		return
+	}
+	if len(body) > rule.maxBytes {
+		entry.BodyBytes = int64(len(body))
+	}
+	if isTextMIME(entry.MIMEType) {
+		entry.ResponseBody, entry.BodyTruncated = truncateBody(string(body), rule.maxBytes)
+	} else {
+		if len(body) > rule.maxBytes {
+			body, entry.BodyTruncated = body[:rule.maxBytes], true
+		}
+		entry.ResponseBody = base64.StdEncoding.EncodeToString(body)
+		entry.BodyBase64 = true
+	}
+}
+
+func (r *networkRecorder) complete(entry NetworkEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completeLocked(entry)
+}
+
+func (r *networkRecorder) completeLocked(entry NetworkEntry) {
+	if len(r.entries) >= maxNetworkEntries {
+		return
+	}
+	r.entries = append(r.entries, entry)
+	if r.opts.Stream {
+		r.pending = append(r.pending, entry)
+	}
+}
+
+// takePending returns entries that have not been streamed yet.
+func (r *networkRecorder) takePending() []NetworkEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	batch := r.pending
+	r.pending = nil
+	return batch
+}
+
+// stream posts completed entries to the sink in batches until ctx is done,
+// then flushes what is left.
+func (r *networkRecorder) stream(ctx context.Context, task *taskstypes.Task, sink tasks.EventSink) {
+	ticker := time.NewTicker(networkStreamInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			r.flush(task, sink)
+			return
+		case <-ticker.C:
+			r.flush(task, sink)
+		}
+	}
+}
+
+func (r *networkRecorder) flush(task *taskstypes.Task, sink tasks.EventSink) {
+	batch := r.takePending()
+	for len(batch) > 0 {
+		n := min(len(batch), networkStreamBatchSize)
+		sink(task, "network", batch[:n])
+		batch = batch[n:]
+	}
+}
+
+// report waits for outstanding body fetches and returns every entry.
+func (r *networkRecorder) report() []NetworkEntry {
+	r.bodies.Wait()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]NetworkEntry{}, r.entries...)
+}
+
+func applyResponse(entry *NetworkEntry, resp *network.Response) {
+	if resp == nil {
+		return
+	}
+	entry.Status = resp.Status
+	entry.StatusText = resp.StatusText
+	entry.MIMEType = resp.MimeType
+	entry.ResponseHeaders = flattenHeaders(resp.Headers)
+	entry.Protocol = resp.Protocol
+	entry.RemoteIP = resp.RemoteIPAddress
+	entry.Timing = networkTiming(resp.Timing)
+}
+
+// networkTiming converts Chrome's offsets from the request start into phase durations.
+func networkTiming(t *network.ResourceTiming) *NetworkTiming {
+	if t == nil {
+		return nil
+	}
+	phase := func(start, end float64) float64 {
+		if start < 0 || end < start {
+			return -1
+		}
+		return end - start
+	}
+	timing := &NetworkTiming{
+		BlockedMs: -1,
+		DNSMs:     phase(t.DNSStart, t.DNSEnd),
+		ConnectMs: phase(t.ConnectStart, t.ConnectEnd),
+		SSLMs:     phase(t.SslStart, t.SslEnd),
+		SendMs:    max(t.SendEnd-t.SendStart, 0),
+		WaitMs:    max(t.ReceiveHeadersEnd-t.SendEnd, 0),
+	}
+	for _, start := range []float64{t.DNSStart, t.ConnectStart, t.SendStart} {
+		if start >= 0 {
+			timing.BlockedMs = start
+			break
+		}
+	}
+	return timing
+}
+
+func flattenHeaders(headers network.Headers) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	flat := make(map[string]string, len(headers))
+	for name, value := range headers {
+		flat[name] = fmt.Sprint(value)
+	}
+	return flat
+}
+
+func elapsedMs(started time.Time, ts *cdp.MonotonicTime) float64 {
+	if started.IsZero() || ts == nil {
+		return 0
+	}
+	return float64(ts.Time().Sub(started).Microseconds()) / 1000
+}
+
+func isTextMIME(mime string) bool {
+	mime = strings.ToLower(mime)
+	return strings.HasPrefix(mime, "text/") ||
+		strings.Contains(mime, "json") ||
+		strings.Contains(mime, "xml") ||
+		strings.Contains(mime, "javascript") ||
+		strings.Contains(mime, "x-www-form-urlencoded")
+}
+
+func truncateBody(body string, limit int) (string, bool) {
+	if len(body) <= limit {
+		return body, false
+	}
+	return body[:limit], true
+}
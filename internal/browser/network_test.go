@@ -0,0 +1,185 @@
+package browser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func monotonic(t time.Time) *cdp.MonotonicTime {
+	ts := cdp.MonotonicTime(t)
+	return &ts
+}
+
+func TestNetworkRecorder_Filters(t *testing.T) {
+	r, err := newNetworkRecorder(context.Background(), taskstypes.NetworkCapture{
+		URLPattern:    "/api/",
+		ResourceTypes: []string{"XHR", "fetch"},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, r.wants("https://example.com/api/items", network.ResourceTypeXHR))
+	assert.True(t, r.wants("https://example.com/api/items", network.ResourceTypeFetch))
+	assert.False(t, r.wants("https://example.com/api/items", network.ResourceTypeImage))
+	assert.False(t, r.wants("https://example.com/static/app.js", network.ResourceTypeXHR))
+
+	_, err = newNetworkRecorder(context.Background(), taskstypes.NetworkCapture{URLPattern: "("})
+	assert.Error(t, err)
+}
+
+func TestNetworkRecorder_RecordsRequests(t *testing.T) {
+	r, err := newNetworkRecorder(context.Background(), taskstypes.NetworkCapture{})
+	require.NoError(t, err)
+	start := time.Now()
+	r.beforeAction(2)
+
+	r.handleEvent(&network.EventRequestWillBeSent{
+		RequestID: "1",
+		Type:      network.ResourceTypeDocument,
+		Request:   &network.Request{URL: "http://example.com/", Method: "GET"},
+		Timestamp: monotonic(start),
+	})
+	// The redirect closes out the first hop under the same request ID
+	r.handleEvent(&network.EventRequestWillBeSent{
+		RequestID:        "1",
+		Type:             network.ResourceTypeDocument,
+		Request:          &network.Request{URL: "https://example.com/", Method: "GET"},
+		RedirectResponse: &network.Response{Status: 301, Headers: network.Headers{"Location": "https://example.com/"}},
+		Timestamp:        monotonic(start.Add(20 * time.Millisecond)),
+	})
+	r.handleEvent(&network.EventResponseReceived{
+		RequestID: "1",
+		Response:  &network.Response{Status: 200, StatusText: "OK", MimeType: "text/html"},
+	})
+	r.handleEvent(&network.EventLoadingFinished{
+		RequestID:         "1",
+		EncodedDataLength: 1234,
+		Timestamp:         monotonic(start.Add(120 * time.Millisecond)),
+	})
+
+	r.handleEvent(&network.EventRequestWillBeSent{
+		RequestID: "2",
+		Type:      network.ResourceTypeFetch,
+		Request:   &network.Request{URL: "https://example.com/api", Method: "POST"},
+		Timestamp: monotonic(start),
+	})
+	r.handleEvent(&network.EventLoadingFailed{
+		RequestID: "2",
+		ErrorText: "net::ERR_CONNECTION_REFUSED",
+		Timestamp: monotonic(start.Add(5 * time.Millisecond)),
+	})
+
+	entries := r.report()
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "http://example.com/", entries[0].URL)
+	assert.Equal(t, int64(301), entries[0].Status)
+	assert.Equal(t, "https://example.com/", entries[0].ResponseHeaders["Location"])
+
+	assert.Equal(t, "https://example.com/", entries[1].URL)
+	assert.False(t, entries[1].Failed)
+	assert.Equal(t, int64(200), entries[1].Status)
+	assert.Equal(t, "text/html", entries[1].MIMEType)
+	assert.Equal(t, int64(1234), entries[1].EncodedBytes)
+	assert.InDelta(t, 100, entries[1].DurationMs, 0.01)
+	assert.Equal(t, "document", entries[1].ResourceType)
+	assert.Equal(t, 2, entries[1].Action)
+
+	assert.True(t, entries[2].Failed)
+	assert.Equal(t, "POST", entries[2].Method)
+	assert.Equal(t, "net::ERR_CONNECTION_REFUSED", entries[2].ErrorText)
+	assert.InDelta(t, 5, entries[2].DurationMs, 0.01)
+}
+
+func TestNetworkRecorder_FlushBatches(t *testing.T) {
+	r, err := newNetworkRecorder(context.Background(), taskstypes.NetworkCapture{Stream: true})
+	require.NoError(t, err)
+	for i := 0; i < networkStreamBatchSize+5; i++ {
+		r.complete(NetworkEntry{URL: "https://example.com/"})
+	}
+
+	var batches []int
+	sink := func(task *taskstypes.Task, event string, data interface{}) {
+		assert.Equal(t, "network", event)
+		batches = append(batches, len(data.([]NetworkEntry)))
+	}
+	task := &taskstypes.Task{}
+	r.flush(task, sink)
+	assert.Equal(t, []int{networkStreamBatchSize, 5}, batches)
+
+	r.flush(task, sink)
+	assert.Len(t, batches, 2, "flushed entries are not sent twice")
+	assert.Len(t, r.report(), networkStreamBatchSize+5, "streamed entries stay in the report")
+}
+
+func TestTruncateBody(t *testing.T) {
+	body, truncated := truncateBody("hello", 10)
+	assert.Equal(t, "hello", body)
+	assert.False(t, truncated)
+
+	body, truncated = truncateBody("hello world", 5)
+	assert.Equal(t, "hello", body)
+	assert.True(t, truncated)
+}
+
+func TestIsTextMIME(t *testing.T) {
+	assert.True(t, isTextMIME("text/html"))
+	assert.True(t, isTextMIME("application/json"))
+	assert.True(t, isTextMIME("application/problem+json"))
+	assert.True(t, isTextMIME("Application/JavaScript"))
+	assert.False(t, isTextMIME("image/png"))
+	assert.False(t, isTextMIME("application/octet-stream"))
+}
+
+func TestNetworkRecorder_BodyRules(t *testing.T) {
+	r, err := newNetworkRecorder(context.Background(), taskstypes.NetworkCapture{
+		BodyRules: []taskstypes.BodyRule{
+			{URLPattern: "/api/", MIMETypes: []string{"application/json"}, MaxSize: 100, MaxBodyBytes: 10},
+			{MIMETypes: []string{"text/*"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, r.opts.Bodies, "rules imply bodies")
+
+	api := r.bodyRuleFor(NetworkEntry{URL: "https://example.com/api/items", MIMEType: "application/json"})
+	require.NotNil(t, api)
+	assert.Equal(t, int64(100), api.maxSize)
+	text := r.bodyRuleFor(NetworkEntry{URL: "https://example.com/", MIMEType: "text/html; charset=utf-8"})
+	require.NotNil(t, text)
+	assert.Equal(t, defaultMaxBodyBytes, text.maxBytes, "rules without a limit use the capture's")
+	assert.Nil(t, r.bodyRuleFor(NetworkEntry{URL: "https://example.com/logo.png", MIMEType: "image/png"}))
+
+	// Bodies no rule covers, or that are known to be too large, are never fetched
+	r.handleEvent(&network.EventRequestWillBeSent{RequestID: "1", Type: network.ResourceTypeImage, Request: &network.Request{URL: "https://example.com/logo.png", Method: "GET"}})
+	r.handleEvent(&network.EventResponseReceived{RequestID: "1", Response: &network.Response{Status: 200, MimeType: "image/png"}})
+	r.handleEvent(&network.EventLoadingFinished{RequestID: "1", EncodedDataLength: 5000})
+	r.handleEvent(&network.EventRequestWillBeSent{RequestID: "2", Type: network.ResourceTypeFetch, Request: &network.Request{URL: "https://example.com/api/export", Method: "GET"}})
+	r.handleEvent(&network.EventResponseReceived{RequestID: "2", Response: &network.Response{Status: 200, MimeType: "application/json"}})
+	r.handleEvent(&network.EventLoadingFinished{RequestID: "2", EncodedDataLength: 5000})
+	entries := r.report()
+	require.Len(t, entries, 2)
+	assert.Equal(t, bodySkippedNoRule, entries[0].BodySkipped)
+	assert.Equal(t, bodySkippedTooLarge, entries[1].BodySkipped)
+	assert.Equal(t, int64(5000), entries[1].BodyBytes)
+
+	var entry NetworkEntry
+	entry.MIMEType = "application/json"
+	applyBody(&entry, []byte(`{"items":[1,2,3,4,5,6]}`), api)
+	assert.Equal(t, `{"items":[`, entry.ResponseBody)
+	assert.True(t, entry.BodyTruncated)
+	assert.Equal(t, int64(23), entry.BodyBytes, "truncated bodies keep their full size")
+
+	entry = NetworkEntry{MIMEType: "application/json"}
+	applyBody(&entry, make([]byte, 101), api)
+	assert.Empty(t, entry.ResponseBody)
+	assert.Equal(t, bodySkippedTooLarge, entry.BodySkipped)
+
+	_, err = newNetworkRecorder(context.Background(), taskstypes.NetworkCapture{BodyRules: []taskstypes.BodyRule{{URLPattern: "("}}})
+	assert.Error(t, err)
+}
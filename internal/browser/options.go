@@ -0,0 +1,126 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+
+	cdpbrowser "github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// applyTaskOptions configures the browser context for a task before its first
+// action. The returned restore func undoes the settings, which matters for
+// named sessions whose page outlives the task.
+func (m *Manager) applyTaskOptions(ctx context.Context, opts taskstypes.TaskOptions) (func(), error) {
+	var undo []chromedp.Action
+	restore := func() {
+		// Undo in reverse so later settings are removed first
+		for i := len(undo) - 1; i >= 0; i-- {
+			if err := chromedp.Run(ctx, undo[i]); err != nil {
+				m.logger.WarnContext(ctx, "Failed to restore task options", "error", err)
+			}
+		}
+	}
+
+	if opts.ScriptsDisabled() {
+		if err := chromedp.Run(ctx, emulation.SetScriptExecutionDisabled(true)); err != nil {
+			return restore, fmt.Errorf("failed to disable JavaScript: %w", err)
+		}
+		undo = append(undo, emulation.SetScriptExecutionDisabled(false))
+	}
+
+	if opts.UserAgent != "" || opts.AcceptLanguage != "" {
+		var defaultUA string
+		if err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+			_, _, _, defaultUA, _, err = cdpbrowser.GetVersion().Do(ctx)
+			return err
+		})); err != nil {
+			return restore, fmt.Errorf("failed to read browser user agent: %w", err)
+		}
+		if err := chromedp.Run(ctx, taskUserAgent(opts, defaultUA)); err != nil {
+			return restore, fmt.Errorf("failed to set user agent: %w", err)
+		}
+		undo = append(undo, emulation.SetUserAgentOverride(defaultUA))
+	}
+
+	if opts.Locale != "" {
+		if err := chromedp.Run(ctx, emulation.SetLocaleOverride().WithLocale(opts.Locale)); err != nil {
+			return restore, fmt.Errorf("failed to set locale %q: %w", opts.Locale, err)
+		}
+		undo = append(undo, emulation.SetLocaleOverride())
+	}
+
+	if opts.Timezone != "" {
+		if err := chromedp.Run(ctx, emulation.SetTimezoneOverride(opts.Timezone)); err != nil {
+			return restore, fmt.Errorf("failed to set timezone %q: %w", opts.Timezone, err)
+		}
+		undo = append(undo, emulation.SetTimezoneOverride(""))
+	}
+
+	if opts.Clock != nil {
+		remove, err := installClock(ctx, *opts.Clock)
+		if err != nil {
+			return restore, fmt.Errorf("failed to set page clock: %w", err)
+		}
+		undo = append(undo, remove)
+	}
+
+	if opts.RandomSeed != nil {
+		remove, err := addPageScript(ctx, randomScript(*opts.RandomSeed))
+		if err != nil {
+			return restore, fmt.Errorf("failed to seed page randomness: %w", err)
+		}
+		undo = append(undo, remove)
+	}
+
+	if len(opts.Headers) > 0 {
+		headers := make(network.Headers, len(opts.Headers))
+		for name, value := range opts.Headers {
+			headers[name] = value
+		}
+		if err := chromedp.Run(ctx, network.SetExtraHTTPHeaders(headers)); err != nil {
+			return restore, fmt.Errorf("failed to set extra HTTP headers: %w", err)
+		}
+		undo = append(undo, network.SetExtraHTTPHeaders(network.Headers{}))
+	}
+
+	return restore, nil
+}
+
+// addPageScript runs source in the current document and in every document
+// the page loads afterwards, before the document's own scripts. The returned
+// action stops it running in new documents; pages already loaded keep its
+// effects until they navigate.
+func addPageScript(ctx context.Context, source string) (chromedp.Action, error) {
+	var id page.ScriptIdentifier
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+		id, err = page.AddScriptToEvaluateOnNewDocument(source).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, err
+	}
+	// Also run it in the current document, e.g. a session's open page
+	if err := chromedp.Run(ctx, chromedp.Evaluate(source, nil)); err != nil {
+		return nil, err
+	}
+	return page.RemoveScriptToEvaluateOnNewDocument(id), nil
+}
+
+// taskUserAgent returns the user agent override for a task's options. The
+// browser's own user agent is kept when only the language is overridden.
+func taskUserAgent(opts taskstypes.TaskOptions, defaultUA string) *emulation.SetUserAgentOverrideParams {
+	ua := opts.UserAgent
+	if ua == "" {
+		ua = defaultUA
+	}
+	override := emulation.SetUserAgentOverride(ua)
+	if opts.AcceptLanguage != "" {
+		override = override.WithAcceptLanguage(opts.AcceptLanguage)
+	}
+	return override
+}
@@ -0,0 +1,23 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskUserAgent(t *testing.T) {
+	const defaultUA = "Mozilla/5.0 HeadlessChrome/134.0"
+
+	override := taskUserAgent(taskstypes.TaskOptions{}, defaultUA)
+	assert.Equal(t, defaultUA, override.UserAgent)
+	assert.Empty(t, override.AcceptLanguage)
+
+	override = taskUserAgent(taskstypes.TaskOptions{AcceptLanguage: "de-DE,de;q=0.9"}, defaultUA)
+	assert.Equal(t, defaultUA, override.UserAgent, "language only keeps the browser's user agent")
+	assert.Equal(t, "de-DE,de;q=0.9", override.AcceptLanguage)
+
+	override = taskUserAgent(taskstypes.TaskOptions{UserAgent: "Custom/1.0"}, defaultUA)
+	assert.Equal(t, "Custom/1.0", override.UserAgent)
+}
@@ -0,0 +1,138 @@
+package browser
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+const largestResourcesPerPage = 5
+
+// TypeWeight is the transferred size and request count for one resource type.
+type TypeWeight struct {
+	Bytes    int64 `json:"bytes"`
+	Requests int   `json:"requests"`
+}
+
+// ResourceWeight is a single loaded resource.
+type ResourceWeight struct {
+	URL   string `json:"url"`
+	Type  string `json:"type"`
+	Bytes int64  `json:"bytes"`
+}
+
+// PageWeight is the page-weight audit for one navigation.
+type PageWeight struct {
+	URL        string                `json:"url"`
+	TotalBytes int64                 `json:"total_bytes"`
+	Requests   int                   `json:"requests"`
+	ByType     map[string]TypeWeight `json:"by_type"`
+	Largest    []ResourceWeight      `json:"largest"`
+
+	resources []ResourceWeight
+}
+
+// pageWeightRecorder attributes network transfer sizes to the navigation
+// that was current when each response arrived.
+type pageWeightRecorder struct {
+	mu      sync.Mutex
+	pages   []*PageWeight
+	pending map[network.RequestID]pendingResource
+}
+
+type pendingResource struct {
+	page     *PageWeight
+	resource ResourceWeight
+}
+
+func newPageWeightRecorder() *pageWeightRecorder {
+	return &pageWeightRecorder{pending: make(map[network.RequestID]pendingResource)}
+}
+
+// startPage begins a new report; later responses count toward it.
+func (r *pageWeightRecorder) startPage(url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pages = append(r.pages, &PageWeight{URL: url, ByType: make(map[string]TypeWeight)})
+}
+
+// handleEvent is registered with chromedp.ListenTarget.
+func (r *pageWeightRecorder) handleEvent(ev interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *network.EventResponseReceived:
+		if len(r.pages) == 0 || ev.Response == nil {
+			return
+		}
+		r.pending[ev.RequestID] = pendingResource{
+			page:     r.pages[len(r.pages)-1],
+			resource: ResourceWeight{URL: ev.Response.URL, Type: resourceCategory(ev.Type)},
+		}
+	case *network.EventLoadingFinished:
+		p, ok := r.pending[ev.RequestID]
+		if !ok {
+			return
+		}
+		delete(r.pending, ev.RequestID)
+		p.resource.Bytes = int64(ev.EncodedDataLength)
+		p.page.add(p.resource)
+	case *network.EventLoadingFailed:
+		delete(r.pending, ev.RequestID)
+	}
+}
+
+func (p *PageWeight) add(res ResourceWeight) {
+	p.TotalBytes += res.Bytes
+	p.Requests++
+	tw := p.ByType[res.Type]
+	tw.Bytes += res.Bytes
+	tw.Requests++
+	p.ByType[res.Type] = tw
+	p.resources = append(p.resources, res)
+}
+
+// report returns the finished audits with their largest resources filled in.
+func (r *pageWeightRecorder) report() []PageWeight {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]PageWeight, 0, len(r.pages))
+	for _, p := range r.pages {
+		page := *p
+		largest := append([]ResourceWeight(nil), p.resources...)
+		sort.SliceStable(largest, func(i, j int) bool { return largest[i].Bytes > largest[j].Bytes })
+		if len(largest) > largestResourcesPerPage {
+			largest = largest[:largestResourcesPerPage]
+		}
+		page.Largest = largest
+		page.resources = nil
+		out = append(out, page)
+	}
+	return out
+}
+
+// resourceCategory maps CDP resource types onto the buckets performance
+// teams usually audit.
+func resourceCategory(t network.ResourceType) string {
+	switch t {
+	case network.ResourceTypeScript:
+		return "script"
+	case network.ResourceTypeStylesheet:
+		return "css"
+	case network.ResourceTypeImage:
+		return "image"
+	case network.ResourceTypeFont:
+		return "font"
+	case network.ResourceTypeXHR, network.ResourceTypeFetch:
+		return "xhr"
+	case network.ResourceTypeDocument:
+		return "document"
+	case network.ResourceTypeMedia:
+		return "media"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,57 @@
+package browser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadResource(r *pageWeightRecorder, id string, url string, typ network.ResourceType, bytes float64) {
+	r.handleEvent(&network.EventResponseReceived{
+		RequestID: network.RequestID(id),
+		Type:      typ,
+		Response:  &network.Response{URL: url},
+	})
+	r.handleEvent(&network.EventLoadingFinished{RequestID: network.RequestID(id), EncodedDataLength: bytes})
+}
+
+func TestPageWeightRecorder(t *testing.T) {
+	r := newPageWeightRecorder()
+
+	// Responses before the first navigation are ignored
+	loadResource(r, "0", "https://stray/", network.ResourceTypeScript, 99)
+
+	r.startPage("https://example.com")
+	loadResource(r, "1", "https://example.com/", network.ResourceTypeDocument, 1000)
+	loadResource(r, "2", "https://example.com/app.js", network.ResourceTypeScript, 5000)
+	loadResource(r, "3", "https://example.com/site.css", network.ResourceTypeStylesheet, 800)
+	loadResource(r, "4", "https://api.example.com/data", network.ResourceTypeFetch, 200)
+	loadResource(r, "5", "https://api.example.com/more", network.ResourceTypeXHR, 300)
+	for i := 0; i < 6; i++ {
+		loadResource(r, fmt.Sprintf("img%d", i), fmt.Sprintf("https://cdn.example.com/%d.png", i), network.ResourceTypeImage, float64(100*(i+1)))
+	}
+
+	// Started on page one, finished after navigating: still counts toward page one
+	r.handleEvent(&network.EventResponseReceived{RequestID: "late", Type: network.ResourceTypeFont, Response: &network.Response{URL: "https://example.com/f.woff2"}})
+	r.startPage("https://example.com/next")
+	r.handleEvent(&network.EventLoadingFinished{RequestID: "late", EncodedDataLength: 400})
+	loadResource(r, "6", "https://example.com/next", network.ResourceTypeDocument, 50)
+
+	report := r.report()
+	require.Len(t, report, 2)
+
+	first := report[0]
+	assert.Equal(t, "https://example.com", first.URL)
+	assert.Equal(t, 12, first.Requests)
+	assert.Equal(t, int64(1000+5000+800+200+300+2100+400), first.TotalBytes)
+	assert.Equal(t, TypeWeight{Bytes: 500, Requests: 2}, first.ByType["xhr"])
+	assert.Equal(t, TypeWeight{Bytes: 2100, Requests: 6}, first.ByType["image"])
+	assert.Equal(t, TypeWeight{Bytes: 400, Requests: 1}, first.ByType["font"])
+	require.Len(t, first.Largest, largestResourcesPerPage)
+	assert.Equal(t, "https://example.com/app.js", first.Largest[0].URL)
+
+	assert.Equal(t, int64(50), report[1].TotalBytes)
+}
@@ -0,0 +1,236 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// ErrPasswordChangeFailed is returned when a change_password action's verification fails.
+var ErrPasswordChangeFailed = errors.New("password change failed")
+
+const (
+	defaultSubmitSelector          = "button[type='submit'], input[type='submit']"
+	defaultCurrentPasswordSelector = "input[autocomplete='current-password']"
+	defaultNewPasswordSelector     = "input[autocomplete='new-password']"
+)
+
+// PasswordRotation records the outcome of a change_password action.
+type PasswordRotation struct {
+	Action         int       `json:"action"`
+	Username       string    `json:"username,omitempty"`
+	Submitted      bool      `json:"submitted"`                 // The form was sent; the site may have changed the password even if Rotated is false
+	Rotated        bool      `json:"rotated"`                   // Submitted and verified
+	SessionUpdated bool      `json:"session_updated,omitempty"` // The session's re-login credentials now use the new password
+	SecretsUpdated []string  `json:"secrets_updated,omitempty"` // credentials_ref secrets that now hold the new password
+	Error          string    `json:"error,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// changePasswordAction fills a change-password form with the task's current
+// and new passwords, submits it, and checks action.Verify. On success the
+// new password is written back to the task's credentials_ref and to the
+// session's login reference, and the task's session, if it re-logs in with
+// the same username, switches to the new password so later re-logins keep
+// working. The outcome goes to result.custom_data.password_rotations.
+func (m *Manager) changePasswordAction(task *taskstypes.Task, index int, action taskstypes.Action, creds *taskstypes.Credentials, result *taskstypes.TaskResult) (chromedp.Action, error) {
+	if creds == nil || creds.Password == "" || creds.NewPassword == "" {
+		return nil, fmt.Errorf("change_password requires the current and new password in credentials")
+	}
+	if creds.NewPassword == creds.Password {
+		return nil, fmt.Errorf("change_password requires a new password different from the current one")
+	}
+
+	var form taskstypes.PasswordForm
+	if action.Password != nil {
+		form = *action.Password
+	}
+	currentSel := form.CurrentSelector
+	if currentSel == "" {
+		currentSel = defaultCurrentPasswordSelector
+	}
+	newSel := form.NewSelector
+	if newSel == "" {
+		newSel = defaultNewPasswordSelector
+	}
+	submitSel := action.SelectorOrDefault(defaultSubmitSelector)
+
+	fill := chromedp.Tasks{
+		chromedp.WaitVisible(currentSel, chromedp.ByQuery),
+		chromedp.SendKeys(currentSel, creds.Password, chromedp.ByQuery),
+		chromedp.WaitVisible(newSel, chromedp.ByQuery),
+	}
+	if form.ConfirmSelector == "" {
+		// Forms usually mark both the new and the confirmation field as new-password
+		fill = append(fill, sendKeysAll(newSel, creds.NewPassword))
+	} else {
+		fill = append(fill,
+			chromedp.SendKeys(newSel, creds.NewPassword, chromedp.ByQuery),
+			chromedp.SendKeys(form.ConfirmSelector, creds.NewPassword, chromedp.ByQuery),
+		)
+	}
+	fill = append(fill, chromedp.WaitVisible(submitSel, chromedp.ByQuery))
+
+	var verify chromedp.Action
+	if action.Verify != nil {
+		var err error
+		if verify, err = verifyLoginAction(*action.Verify, "", ErrPasswordChangeFailed); err != nil {
+			return nil, err
+		}
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		rotation := PasswordRotation{Action: index, Username: creds.Username, At: time.Now().UTC()}
+		err := fill.Do(ctx)
+		if err == nil {
+			err = chromedp.Click(submitSel, chromedp.ByQuery).Do(ctx)
+			rotation.Submitted = err == nil
+		}
+		if err == nil && verify != nil {
+			err = verify.Do(ctx)
+		}
+		if err != nil {
+			rotation.Error = err.Error()
+			recordPasswordRotation(result, rotation)
+			return err
+		}
+
+		rotation.Rotated = true
+		// The site already accepted the new password, so failures from here on
+		// are reported but the task still succeeds
+		var failures []error
+		for _, ref := range m.rotationRefs(task) {
+			stored, err := m.storeRotatedPassword(ctx, ref, creds)
+			if err != nil {
+				m.logger.WarnContext(ctx, "Password changed but its secret was not updated", "credentials_ref", ref, "error", err)
+				failures = append(failures, err)
+			} else if stored {
+				rotation.SecretsUpdated = append(rotation.SecretsUpdated, ref)
+			}
+		}
+		updated, err := m.rotateSessionPassword(task, creds, rotation.SecretsUpdated)
+		if err != nil {
+			m.logger.WarnContext(ctx, "Password changed but session was not updated", "session", task.Session, "error", err)
+			failures = append(failures, err)
+		}
+		rotation.SessionUpdated = updated
+		if len(failures) > 0 {
+			rotation.Error = errors.Join(failures...).Error()
+		}
+		recordPasswordRotation(result, rotation)
+		return nil
+	}), nil
+}
+
+// sendKeysAll types value into every element matching selector.
+func sendKeysAll(selector, value string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var nodes []*cdp.Node
+		if err := chromedp.Nodes(selector, &nodes, chromedp.ByQueryAll).Do(ctx); err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			if err := chromedp.SendKeys([]cdp.NodeID{node.NodeID}, value, chromedp.ByNodeID).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// rotationRefs returns the credentials references a password change is
+// written back to: the task's own and its session login's.
+func (m *Manager) rotationRefs(task *taskstypes.Task) []string {
+	var refs []string
+	if task.CredentialsRef != "" {
+		refs = append(refs, task.CredentialsRef)
+	}
+	if task.Session == "" {
+		return refs
+	}
+	m.sessionsMu.Lock()
+	sess := m.sessions[task.Session]
+	m.sessionsMu.Unlock()
+	if sess == nil {
+		return refs
+	}
+	sess.mu.Lock()
+	login := sess.opts.Login
+	sess.mu.Unlock()
+	if login != nil && login.CredentialsRef != "" && login.CredentialsRef != task.CredentialsRef {
+		refs = append(refs, login.CredentialsRef)
+	}
+	return refs
+}
+
+// storeRotatedPassword writes the new password to the secret at ref when it
+// holds the same user's old password, and reports whether the secret now
+// holds the new one. Secrets of other users are left alone.
+func (m *Manager) storeRotatedPassword(ctx context.Context, ref string, creds *taskstypes.Credentials) (bool, error) {
+	current, err := m.credentials.Resolve(ctx, ref)
+	if err != nil {
+		return false, err
+	}
+	if current.Username != creds.Username {
+		return false, nil
+	}
+	if current.Password == creds.NewPassword {
+		return true, nil
+	}
+	if err := m.credentials.Rotate(ctx, ref, creds.Username, creds.Password, creds.NewPassword); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rotateSessionPassword swaps the task session's login credentials for the
+// new password when they belong to the same user. The login settings are
+// replaced as a whole, so a concurrent re-login sees either the old or the
+// new credentials, never a mix. A login that reads its credentials from a
+// secret is updated when stored lists that secret, as re-logins read it fresh.
+func (m *Manager) rotateSessionPassword(task *taskstypes.Task, creds *taskstypes.Credentials, stored []string) (bool, error) {
+	if task.Session == "" {
+		return false, nil
+	}
+	m.sessionsMu.Lock()
+	sess := m.sessions[task.Session]
+	m.sessionsMu.Unlock()
+	if sess == nil {
+		return false, nil
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	login := sess.opts.Login
+	if login == nil {
+		return false, nil
+	}
+	if login.CredentialsRef != "" {
+		return slices.Contains(stored, login.CredentialsRef), nil
+	}
+	current, err := m.taskCredentials(context.Background(), &taskstypes.Task{Credentials: login.Credentials, SealedCreds: login.SealedCreds})
+	if err != nil {
+		return false, err
+	}
+	if current == nil || current.Username != creds.Username {
+		return false, nil
+	}
+
+	rotated := *login
+	rotated.Credentials = &taskstypes.Credentials{Username: creds.Username, Password: creds.NewPassword}
+	rotated.SealedCreds = nil // Held only in executor memory, like decrypted credentials
+	sess.opts.Login = &rotated
+	return true, nil
+}
+
+// recordPasswordRotation appends an outcome to result.custom_data.password_rotations.
+func recordPasswordRotation(result *taskstypes.TaskResult, rotation PasswordRotation) {
+	rotations, _ := result.CustomData["password_rotations"].([]PasswordRotation)
+	setCustomData(result, "password_rotations", append(rotations, rotation))
+}
@@ -0,0 +1,112 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/secrets"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangePasswordAction_RequiresPasswords(t *testing.T) {
+	m := &Manager{}
+	action := taskstypes.Action{Type: taskstypes.ActionChangePass}
+	result := &taskstypes.TaskResult{}
+
+	_, err := m.changePasswordAction(&taskstypes.Task{}, 0, action, nil, result)
+	assert.Error(t, err)
+	_, err = m.changePasswordAction(&taskstypes.Task{}, 0, action, &taskstypes.Credentials{Username: "ops", Password: "old"}, result)
+	assert.Error(t, err, "no new password")
+	_, err = m.changePasswordAction(&taskstypes.Task{}, 0, action, &taskstypes.Credentials{Username: "ops", Password: "same", NewPassword: "same"}, result)
+	assert.Error(t, err, "new password must differ")
+
+	_, err = m.changePasswordAction(&taskstypes.Task{}, 0, action, &taskstypes.Credentials{Username: "ops", Password: "old", NewPassword: "new"}, result)
+	assert.NoError(t, err)
+
+	action.Verify = &taskstypes.LoginCheck{SuccessURL: "("}
+	_, err = m.changePasswordAction(&taskstypes.Task{}, 0, action, &taskstypes.Credentials{Username: "ops", Password: "old", NewPassword: "new"}, result)
+	assert.Error(t, err, "invalid verify pattern")
+}
+
+func TestRotateSessionPassword(t *testing.T) {
+	login := &taskstypes.SessionLogin{Template: "crm-login", Credentials: &taskstypes.Credentials{Username: "ops", Password: "old"}}
+	m := &Manager{sessions: map[string]*session{
+		"crm":   {name: "crm", opts: taskstypes.SessionOptions{Login: login}},
+		"plain": {name: "plain"},
+	}}
+	creds := &taskstypes.Credentials{Username: "ops", Password: "old", NewPassword: "new"}
+
+	updated, err := m.rotateSessionPassword(&taskstypes.Task{Session: "crm"}, creds, nil)
+	require.NoError(t, err)
+	assert.True(t, updated)
+	rotated := m.sessionLogin(&taskstypes.Task{Session: "crm"})
+	require.NotNil(t, rotated)
+	assert.Equal(t, "new", rotated.Credentials.Password)
+	assert.Equal(t, "crm-login", rotated.Template)
+	assert.Equal(t, "old", login.Credentials.Password, "the previous login settings are replaced, not modified")
+
+	updated, err = m.rotateSessionPassword(&taskstypes.Task{Session: "crm"}, &taskstypes.Credentials{Username: "someone-else", NewPassword: "x"}, nil)
+	require.NoError(t, err)
+	assert.False(t, updated, "only the session's own user is rotated")
+
+	updated, err = m.rotateSessionPassword(&taskstypes.Task{Session: "plain"}, creds, nil)
+	require.NoError(t, err)
+	assert.False(t, updated)
+	updated, err = m.rotateSessionPassword(&taskstypes.Task{}, creds, nil)
+	require.NoError(t, err)
+	assert.False(t, updated)
+}
+
+func TestStoreRotatedPassword(t *testing.T) {
+	secretData := map[string]map[string]interface{}{
+		"ops":   {"username": "ops", "password": "old", "new_password": "new"},
+		"other": {"username": "someone-else", "password": "theirs"},
+	}
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")
+		if r.Method == http.MethodPost {
+			var write struct{ Data map[string]interface{} }
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&write))
+			secretData[name] = write.Data
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": secretData[name], "metadata": map[string]int{"version": 1}}})
+	}))
+	defer vault.Close()
+	resolver, err := secrets.NewResolver(config.CredentialSourcesConfig{Vault: config.VaultConfig{Address: vault.URL, Token: "s.token"}}, nil)
+	require.NoError(t, err)
+
+	login := &taskstypes.SessionLogin{Template: "crm-login", CredentialsRef: "vault:other"}
+	m := &Manager{credentials: resolver, sessions: map[string]*session{
+		"crm": {name: "crm", opts: taskstypes.SessionOptions{Login: login}},
+	}}
+	task := &taskstypes.Task{Session: "crm", CredentialsRef: "vault:ops"}
+	assert.Equal(t, []string{"vault:ops", "vault:other"}, m.rotationRefs(task))
+
+	creds := &taskstypes.Credentials{Username: "ops", Password: "old", NewPassword: "new"}
+	stored, err := m.storeRotatedPassword(context.Background(), "vault:ops", creds)
+	require.NoError(t, err)
+	assert.True(t, stored)
+	assert.Equal(t, map[string]interface{}{"username": "ops", "password": "new"}, secretData["ops"])
+	stored, err = m.storeRotatedPassword(context.Background(), "vault:ops", creds)
+	require.NoError(t, err)
+	assert.True(t, stored, "a secret that already holds the new password counts as stored")
+
+	stored, err = m.storeRotatedPassword(context.Background(), "vault:other", creds)
+	require.NoError(t, err)
+	assert.False(t, stored, "another user's secret is left alone")
+	assert.Equal(t, "theirs", secretData["other"]["password"])
+
+	updated, err := m.rotateSessionPassword(task, creds, []string{"vault:ops"})
+	require.NoError(t, err)
+	assert.False(t, updated, "the session logs in with a secret that was not rotated")
+	updated, err = m.rotateSessionPassword(task, creds, []string{"vault:other"})
+	require.NoError(t, err)
+	assert.True(t, updated)
+}
@@ -0,0 +1,382 @@
+package browser
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"golang.org/x/sync/semaphore"
+)
+
+// poolHealthTimeout bounds one health check of an idle browser.
+const poolHealthTimeout = 5 * time.Second
+
+// slotRetryInterval is how often a task waiting for a browser slot looks
+// for an idle pooled browser to close in its favour.
+const slotRetryInterval = 100 * time.Millisecond
+
+// warmBrowser is a started browser process kept by the pool. Tasks run in
+// their own browser context inside it, so they share no cookies or storage.
+type warmBrowser struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	started  time.Time
+	lastUsed time.Time
+	uses     int
+}
+
+// browserPool keeps Size browsers started and idle, replacing those that
+// crash, fail a health check, or reach their age, use, or idle limit. Every
+// pooled browser holds one of the browser.maxSessions slots from slots,
+// which a task that takes it uses, so the pool never adds to the number of
+// browser processes; browsers are only started while slots are free.
+type browserPool struct {
+	cfg    config.PoolConfig
+	slots  *semaphore.Weighted
+	start  func() (*warmBrowser, error)
+	check  func(context.Context) error // Health check run on a browser's context
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	idle     []*warmBrowser // Most recently used last
+	inUse    int
+	starting int
+	closed   bool
+
+	wake chan struct{}
+	done chan struct{}
+
+	hits, misses, recycled, unhealthy atomic.Int64
+}
+
+func newBrowserPool(cfg config.PoolConfig, slots *semaphore.Weighted, start func() (*warmBrowser, error), check func(context.Context) error, logger *slog.Logger) *browserPool {
+	p := &browserPool{
+		cfg:    cfg,
+		slots:  slots,
+		start:  start,
+		check:  check,
+		logger: logger,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go p.maintain()
+	return p
+}
+
+// get takes a warm browser for a task, with its slot, or returns nil when
+// none is ready and the task should take a slot and start its own.
+func (p *browserPool) get() *warmBrowser {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.signal()
+	now := time.Now()
+	for len(p.idle) > 0 {
+		b := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if reason := p.expired(b, now); reason != "" {
+			p.discard(b, reason)
+			continue
+		}
+		p.inUse++
+		p.hits.Add(1)
+		return b
+	}
+	p.misses.Add(1)
+	return nil
+}
+
+// put returns a browser, with its slot, once its task is done.
+func (p *browserPool) put(b *warmBrowser) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.signal()
+	p.inUse--
+	b.uses++
+	b.lastUsed = time.Now()
+	if p.closed {
+		p.stop(b)
+		return
+	}
+	if reason := p.expired(b, b.lastUsed); reason != "" {
+		p.discard(b, reason)
+		return
+	}
+	p.idle = append(p.idle, b)
+}
+
+// expired says why a browser should no longer be used, or "" if it can be.
+func (p *browserPool) expired(b *warmBrowser, now time.Time) string {
+	switch {
+	case b.ctx.Err() != nil:
+		return "crashed"
+	case p.cfg.MaxUses > 0 && b.uses >= p.cfg.MaxUses:
+		return "max_uses"
+	case p.cfg.MaxAge > 0 && now.Sub(b.started) >= p.cfg.MaxAge:
+		return "max_age"
+	case p.cfg.IdleTimeout > 0 && now.Sub(b.lastUsed) >= p.cfg.IdleTimeout:
+		return "idle"
+	}
+	return ""
+}
+
+// discard closes a browser taken out of the pool. p.mu must be held.
+func (p *browserPool) discard(b *warmBrowser, reason string) {
+	if reason == "crashed" || reason == "unhealthy" {
+		p.unhealthy.Add(1)
+		p.logger.Warn("Replacing pooled browser", "reason", reason, "uses", b.uses)
+	} else {
+		p.recycled.Add(1)
+		p.logger.Debug("Recycling pooled browser", "reason", reason, "uses", b.uses)
+	}
+	go p.stop(b)
+}
+
+// stop closes a browser taken out of the pool and frees its slot once its
+// process has exited.
+func (p *browserPool) stop(b *warmBrowser) {
+	b.cancel() // Waits for the process to exit
+	p.slots.Release(1)
+}
+
+// reclaim closes the least recently used idle browser, so its slot can go
+// to a task or session that cannot use the pool. It reports false when no
+// browser is idle.
+func (p *browserPool) reclaim() bool {
+	p.mu.Lock()
+	if len(p.idle) == 0 {
+		p.mu.Unlock()
+		return false
+	}
+	b := p.idle[0]
+	p.idle = p.idle[1:]
+	p.recycled.Add(1)
+	p.mu.Unlock()
+	p.logger.Debug("Recycling pooled browser", "reason", "slot_needed", "uses", b.uses)
+	p.stop(b)
+	return true
+}
+
+// signal asks the maintainer to refill the pool.
+func (p *browserPool) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// maintain refills the pool when asked, and health checks idle browsers
+// every HealthCheckInterval.
+func (p *browserPool) maintain() {
+	interval := p.cfg.HealthCheckInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	p.refill()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-p.wake:
+		case <-ticker.C:
+			p.checkIdle()
+		}
+		p.refill()
+	}
+}
+
+// checkIdle replaces idle browsers that are past a limit or do not answer,
+// and closes those beyond Size that were returned after a burst of tasks.
+func (p *browserPool) checkIdle() {
+	p.mu.Lock()
+	candidates := append([]*warmBrowser(nil), p.idle...)
+	p.mu.Unlock()
+
+	now := time.Now()
+	for _, b := range candidates {
+		reason := p.expired(b, now)
+		if reason == "" {
+			ctx, cancel := context.WithTimeout(b.ctx, poolHealthTimeout)
+			if err := p.check(ctx); err != nil {
+				reason = "unhealthy"
+			}
+			cancel()
+		}
+		if reason == "" {
+			continue
+		}
+		p.mu.Lock()
+		// A task may have taken it in the meantime; it is checked again on return
+		for i, idle := range p.idle {
+			if idle == b {
+				p.idle = append(p.idle[:i], p.idle[i+1:]...)
+				p.discard(b, reason)
+				break
+			}
+		}
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.idle) > p.cfg.Size {
+		b := p.idle[0] // Least recently used
+		p.idle = p.idle[1:]
+		p.discard(b, "surplus")
+	}
+}
+
+// refill starts browsers until Size are idle or starting, as long as slots
+// are free. Tasks waiting for a slot go first.
+func (p *browserPool) refill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for !p.closed && len(p.idle)+p.starting < p.cfg.Size && p.slots.TryAcquire(1) {
+		p.starting++
+		go func() {
+			b, err := p.start()
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.starting--
+			if err != nil {
+				p.slots.Release(1)
+				// Retried on the next health check rather than in a tight loop
+				p.logger.Error("Failed to start pooled browser", "error", err)
+				return
+			}
+			if p.closed {
+				go p.stop(b)
+				return
+			}
+			b.lastUsed = time.Now()
+			p.idle = append([]*warmBrowser{b}, p.idle...) // Fresh browsers are used last
+		}()
+	}
+}
+
+// close stops the maintainer and closes the idle browsers. Browsers in use
+// are closed as their tasks return them.
+func (p *browserPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.done)
+	for _, b := range p.idle {
+		go p.stop(b)
+	}
+	p.idle = nil
+}
+
+func (p *browserPool) stats() tasks.BrowserPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return tasks.BrowserPoolStats{
+		Idle:      len(p.idle),
+		InUse:     p.inUse,
+		Starting:  p.starting,
+		Hits:      p.hits.Load(),
+		Misses:    p.misses.Load(),
+		Recycled:  p.recycled.Load(),
+		Unhealthy: p.unhealthy.Load(),
+	}
+}
+
+// BrowserPoolStats implements tasks.BrowserPoolStatsProvider.
+func (m *Manager) BrowserPoolStats() (tasks.BrowserPoolStats, bool) {
+	if m.pool == nil {
+		return tasks.BrowserPoolStats{}, false
+	}
+	return m.pool.stats(), true
+}
+
+// acquireSlot takes one of the browser.maxSessions slots, closing idle
+// pooled browsers to free one if need be.
+func (m *Manager) acquireSlot(ctx context.Context) error {
+	for {
+		if m.tryAcquireSlot() {
+			return nil
+		}
+		// Browsers returned to the pool while this waits are reclaimed on the next try
+		wait, cancel := context.WithTimeout(ctx, slotRetryInterval)
+		err := m.sem.Acquire(wait, 1)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// tryAcquireSlot takes a free slot without waiting, closing idle pooled
+// browsers to free one if need be.
+func (m *Manager) tryAcquireSlot() bool {
+	for !m.sem.TryAcquire(1) {
+		if m.pool == nil || !m.pool.reclaim() {
+			return false
+		}
+	}
+	return true
+}
+
+// releaseSlot frees a slot taken with acquireSlot or tryAcquireSlot, and
+// lets the pool refill with it if no task is waiting.
+func (m *Manager) releaseSlot() {
+	m.sem.Release(1)
+	if m.pool != nil {
+		m.pool.signal()
+	}
+}
+
+// startWarmBrowser launches a browser for the pool on the default allocator.
+func (m *Manager) startWarmBrowser() (*warmBrowser, error) {
+	ctx, cancel := chromedp.NewContext(m.allocatorCtx,
+		chromedp.WithLogf(logging.Printf(context.Background(), m.logger, slog.LevelDebug)),
+		chromedp.WithErrorf(logging.Printf(context.Background(), m.logger, slog.LevelWarn)),
+	)
+	// The first Run starts the browser and binds it to ctx, so it has no deadline
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &warmBrowser{ctx: ctx, cancel: cancel, started: time.Now()}, nil
+}
+
+// browserHealthy asks a browser for its version, which fails once its
+// process has crashed or stopped responding.
+func browserHealthy(ctx context.Context) error {
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, _, _, _, _, err := browser.GetVersion().Do(ctx)
+		return err
+	}))
+}
+
+// usesPool reports whether a task can run on a warm browser: one that is not
+// on a session and starts the browser with the default flags.
+func (m *Manager) usesPool(task *taskstypes.Task, proxy taskstypes.ProxySettings) bool {
+	return m.pool != nil && task.Session == "" && task.Options.Profile == nil && m.defaultProxyServer(proxy)
+}
+
+// browserContextID returns the browser context a pooled task's page runs
+// in, or "" for the default context. Browser-wide commands such as download
+// settings need it to apply to the task's context.
+func browserContextID(ctx context.Context) cdp.BrowserContextID {
+	if c := chromedp.FromContext(ctx); c != nil {
+		return c.BrowserContextID
+	}
+	return ""
+}
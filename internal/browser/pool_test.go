@@ -0,0 +1,123 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+)
+
+// fakeBrowsers starts warm browsers that are just cancellable contexts.
+func fakeBrowsers(started *atomic.Int64) func() (*warmBrowser, error) {
+	return func() (*warmBrowser, error) {
+		started.Add(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		return &warmBrowser{ctx: ctx, cancel: cancel, started: time.Now()}, nil
+	}
+}
+
+func waitIdle(t *testing.T, p *browserPool, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return p.stats().Idle == n }, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestBrowserPool(t *testing.T) {
+	var started atomic.Int64
+	var unhealthy atomic.Bool
+	check := func(context.Context) error {
+		if unhealthy.Load() {
+			return errors.New("no response")
+		}
+		return nil
+	}
+	p := newBrowserPool(config.PoolConfig{Size: 2, MaxUses: 2, HealthCheckInterval: 20 * time.Millisecond}, semaphore.NewWeighted(4), fakeBrowsers(&started), check, logging.Discard())
+	defer p.close()
+	waitIdle(t, p, 2)
+
+	b := p.get()
+	require.NotNil(t, b)
+	assert.Equal(t, 1, p.stats().InUse)
+	waitIdle(t, p, 2) // Refilled while b is in use
+
+	p.put(b)
+	assert.Same(t, b, p.get(), "the most recently used browser is reused first")
+	p.put(b)
+	assert.Eventually(t, func() bool { return b.ctx.Err() != nil }, time.Second, 5*time.Millisecond, "a browser is replaced after max_uses tasks")
+	waitIdle(t, p, 2)
+
+	// A browser that crashed while idle is not handed out
+	crashed := p.get()
+	require.NotNil(t, crashed)
+	p.put(crashed)
+	crashed.cancel()
+	next := p.get()
+	require.NotNil(t, next)
+	assert.NotSame(t, crashed, next)
+	assert.Equal(t, int64(1), p.stats().Unhealthy)
+	p.put(next)
+	waitIdle(t, p, 2) // Browsers returned beyond size are closed
+
+	// Browsers that fail their health check are replaced
+	before := started.Load()
+	unhealthy.Store(true)
+	require.Eventually(t, func() bool { return p.stats().Unhealthy >= 3 }, 2*time.Second, 5*time.Millisecond)
+	unhealthy.Store(false)
+	assert.Greater(t, started.Load(), before)
+
+	stats := p.stats()
+	assert.Equal(t, int64(4), stats.Hits)
+	assert.Zero(t, stats.Misses)
+	assert.Positive(t, stats.Recycled)
+}
+
+func TestBrowserPool_Slots(t *testing.T) {
+	var started atomic.Int64
+	slots := semaphore.NewWeighted(2)
+	p := newBrowserPool(config.PoolConfig{Size: 3, HealthCheckInterval: time.Hour}, slots, fakeBrowsers(&started), func(context.Context) error { return nil }, logging.Discard())
+	defer p.close()
+	m := &Manager{sem: slots, pool: p}
+
+	waitIdle(t, p, 2)
+	assert.Equal(t, int64(2), started.Load(), "the pool only starts browsers while slots are free")
+	assert.False(t, slots.TryAcquire(1))
+
+	// A browser taken from the pool brings its slot
+	b := p.get()
+	require.NotNil(t, b)
+	p.put(b)
+
+	// Tasks and sessions that cannot use the pool get the slot of an idle browser
+	require.True(t, m.tryAcquireSlot())
+	assert.Equal(t, 1, p.stats().Idle)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, m.acquireSlot(ctx))
+	assert.Zero(t, p.stats().Idle)
+	assert.False(t, m.tryAcquireSlot(), "every slot is taken")
+
+	// Freed slots go to the pool again
+	m.releaseSlot()
+	m.releaseSlot()
+	waitIdle(t, p, 2)
+}
+
+func TestBrowserPool_Expired(t *testing.T) {
+	p := &browserPool{cfg: config.PoolConfig{MaxAge: time.Hour, IdleTimeout: time.Minute, MaxUses: 3}}
+	now := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.Empty(t, p.expired(&warmBrowser{ctx: ctx, started: now, lastUsed: now}, now))
+	assert.Equal(t, "max_uses", p.expired(&warmBrowser{ctx: ctx, started: now, lastUsed: now, uses: 3}, now))
+	assert.Equal(t, "max_age", p.expired(&warmBrowser{ctx: ctx, started: now.Add(-2 * time.Hour), lastUsed: now}, now))
+	assert.Equal(t, "idle", p.expired(&warmBrowser{ctx: ctx, started: now, lastUsed: now.Add(-2 * time.Minute)}, now))
+	cancel()
+	assert.Equal(t, "crashed", p.expired(&warmBrowser{ctx: ctx, started: now, lastUsed: now}, now))
+}
@@ -0,0 +1,428 @@
+package browser
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// Compile-time check to ensure Manager supports profile snapshots
+var _ tasks.ProfileExecutor = (*Manager)(nil)
+var _ tasks.KeyringReceiver = (*Manager)(nil)
+
+// maxProfileBytes bounds a compressed profile snapshot. Caches are left out,
+// so a logged-in profile is usually a few megabytes.
+const maxProfileBytes = 256 << 20
+
+// profileSkipDirs are caches and crash data Chrome rebuilds on its own, left
+// out of snapshots to keep them small.
+var profileSkipDirs = map[string]bool{
+	"Cache":                          true,
+	"Code Cache":                     true,
+	"GPUCache":                       true,
+	"GrShaderCache":                  true,
+	"ShaderCache":                    true,
+	"GraphiteDawnCache":              true,
+	"DawnCache":                      true,
+	"CacheStorage":                   true,
+	"Crashpad":                       true,
+	"BrowserMetrics":                 true,
+	"component_crx_cache":            true,
+	"optimization_guide_model_store": true,
+}
+
+// SetKeyring implements tasks.KeyringReceiver. Profile snapshots and
+// artifacts are sealed with it, as they hold cookies and page contents.
+func (m *Manager) SetKeyring(keyring *encryption.Keyring) {
+	m.keyring = keyring
+}
+
+// profileDir returns the directory profile snapshots are kept in.
+func (m *Manager) profileDir() string {
+	if m.cfg.ProfileDir == "" {
+		return "profiles"
+	}
+	return m.cfg.ProfileDir
+}
+
+// profilePath returns where a snapshot is saved, with ".enc" when sealed.
+func (m *Manager) profilePath(name string, sealed bool) string {
+	path := filepath.Join(m.profileDir(), name+".tar.gz")
+	if sealed {
+		path += ".enc"
+	}
+	return path
+}
+
+// profileMetaPath returns the path of a snapshot's ProfileInfo, kept next to
+// it in plaintext so profiles can be listed without decrypting them.
+func (m *Manager) profileMetaPath(name string) string {
+	return filepath.Join(m.profileDir(), name+".json")
+}
+
+// profileAllocator returns an allocator whose browsers use userDataDir as
+// their profile instead of a throwaway guest profile.
+func (m *Manager) profileAllocator(proxy taskstypes.ProxySettings, userDataDir string) (context.Context, context.CancelFunc) {
+	opts := append(append([]chromedp.ExecAllocatorOption{}, m.execOpts...), proxyFlags(proxy)...)
+	opts = append(opts, chromedp.Flag("guest", false), chromedp.UserDataDir(userDataDir))
+	return chromedp.NewExecAllocator(m.allocatorCtx, opts...)
+}
+
+// restoreProfile extracts the named snapshot into a new temporary directory
+// for a browser to use. A profile that was never saved starts empty. The
+// caller removes the directory.
+func (m *Manager) restoreProfile(name string) (string, error) {
+	if err := taskstypes.ValidateProfileName(name); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "goscry-profile-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	data, err := m.readProfile(name)
+	if errors.Is(err, tasks.ErrProfileNotFound) {
+		return dir, nil
+	}
+	if err == nil {
+		err = extractProfile(data, dir)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// readProfile loads a snapshot, decrypting it if it was sealed.
+func (m *Manager) readProfile(name string) ([]byte, error) {
+	data, err := os.ReadFile(m.profilePath(name, true))
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = os.ReadFile(m.profilePath(name, false))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", tasks.ErrProfileNotFound, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read profile %s: %w", name, err)
+		}
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile %s: %w", name, err)
+	}
+	if m.keyring == nil {
+		return nil, fmt.Errorf("profile %s is encrypted but no keyring is configured", name)
+	}
+	plaintext, err := m.keyring.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt profile %s: %w", name, err)
+	}
+	return plaintext, nil
+}
+
+// saveProfile snapshots the user-data-dir of a closed browser as the named
+// profile, replacing any previous snapshot.
+func (m *Manager) saveProfile(task *taskstypes.Task, name, dir string) (taskstypes.ProfileInfo, error) {
+	data, err := archiveProfile(dir)
+	if err != nil {
+		return taskstypes.ProfileInfo{}, err
+	}
+	if len(data) > maxProfileBytes {
+		return taskstypes.ProfileInfo{}, fmt.Errorf("profile %s is %d bytes, over the %d byte limit", name, len(data), maxProfileBytes)
+	}
+	sealed := m.keyring != nil
+	if sealed {
+		if data, err = m.keyring.Seal(task.Tenant, data); err != nil {
+			return taskstypes.ProfileInfo{}, fmt.Errorf("failed to encrypt profile %s: %w", name, err)
+		}
+	}
+
+	m.profilesMu.Lock()
+	defer m.profilesMu.Unlock()
+	// Keep what the profile was created with
+	info, _ := m.readProfileMeta(name)
+	now := time.Now().UTC()
+	info.Name = name
+	info.Size = int64(len(data))
+	info.Encrypted = sealed
+	info.SavedAt = &now
+	info.TaskID = task.ID.String()
+	if info.Owner == "" {
+		// A profile no one created belongs to the tenant that first saves it
+		info.Owner = tasks.TaskTenant(task)
+	}
+	meta, err := json.Marshal(info)
+	if err != nil {
+		return taskstypes.ProfileInfo{}, err
+	}
+	if err := os.MkdirAll(m.profileDir(), 0o700); err != nil {
+		return taskstypes.ProfileInfo{}, fmt.Errorf("failed to prepare profile directory: %w", err)
+	}
+	if err := writeFileAtomic(m.profilePath(name, sealed), data); err != nil {
+		return taskstypes.ProfileInfo{}, fmt.Errorf("failed to save profile %s: %w", name, err)
+	}
+	// Drop a snapshot left in the other form, e.g. from before encryption was enabled
+	os.Remove(m.profilePath(name, !sealed))
+	if err := writeFileAtomic(m.profileMetaPath(name), meta); err != nil {
+		return taskstypes.ProfileInfo{}, fmt.Errorf("failed to save profile %s: %w", name, err)
+	}
+	return info, nil
+}
+
+// CreateProfile implements tasks.ProfileExecutor. The profile starts empty;
+// the first task that saves it fills it in.
+func (m *Manager) CreateProfile(name, owner, description string, autoSave bool) (taskstypes.ProfileInfo, error) {
+	if err := taskstypes.ValidateProfileName(name); err != nil {
+		return taskstypes.ProfileInfo{}, err
+	}
+	m.profilesMu.Lock()
+	defer m.profilesMu.Unlock()
+	for _, path := range []string{m.profilePath(name, true), m.profilePath(name, false), m.profileMetaPath(name)} {
+		if _, err := os.Stat(path); err == nil {
+			return taskstypes.ProfileInfo{}, fmt.Errorf("%w: %s", tasks.ErrProfileExists, name)
+		}
+	}
+
+	now := time.Now().UTC()
+	info := taskstypes.ProfileInfo{Name: name, Description: description, AutoSave: autoSave, CreatedAt: &now, Owner: owner}
+	meta, err := json.Marshal(info)
+	if err != nil {
+		return taskstypes.ProfileInfo{}, err
+	}
+	if err := os.MkdirAll(m.profileDir(), 0o700); err != nil {
+		return taskstypes.ProfileInfo{}, fmt.Errorf("failed to prepare profile directory: %w", err)
+	}
+	if err := writeFileAtomic(m.profileMetaPath(name), meta); err != nil {
+		return taskstypes.ProfileInfo{}, fmt.Errorf("failed to create profile %s: %w", name, err)
+	}
+	return info, nil
+}
+
+// readProfileMeta reads a profile's ProfileInfo, reporting false if it has
+// none or it cannot be read.
+func (m *Manager) readProfileMeta(name string) (taskstypes.ProfileInfo, bool) {
+	var info taskstypes.ProfileInfo
+	data, err := os.ReadFile(m.profileMetaPath(name))
+	if err != nil || json.Unmarshal(data, &info) != nil {
+		return taskstypes.ProfileInfo{}, false
+	}
+	return info, true
+}
+
+// profileAutoSave reports whether the profile was created with auto_save.
+func (m *Manager) profileAutoSave(name string) bool {
+	if taskstypes.ValidateProfileName(name) != nil {
+		return false
+	}
+	info, _ := m.readProfileMeta(name)
+	return info.AutoSave
+}
+
+// ListProfiles implements tasks.ProfileExecutor.
+func (m *Manager) ListProfiles() ([]taskstypes.ProfileInfo, error) {
+	entries, err := os.ReadDir(m.profileDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return []taskstypes.ProfileInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	byName := make(map[string]taskstypes.ProfileInfo)
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok && taskstypes.ValidateProfileName(name) == nil {
+			// A profile created through the API that no task has saved yet
+			if meta, ok := m.readProfileMeta(name); ok && meta.CreatedAt != nil {
+				if _, saved := byName[name]; !saved {
+					meta.Size, meta.Encrypted, meta.SavedAt, meta.TaskID = 0, false, nil, ""
+					byName[name] = meta
+				}
+			}
+			continue
+		}
+		name, sealed := strings.TrimSuffix(entry.Name(), ".enc"), strings.HasSuffix(entry.Name(), ".enc")
+		name, ok := strings.CutSuffix(name, ".tar.gz")
+		if !ok || taskstypes.ValidateProfileName(name) != nil {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		savedAt := stat.ModTime().UTC()
+		info := taskstypes.ProfileInfo{Name: name, Size: stat.Size(), Encrypted: sealed, SavedAt: &savedAt}
+		if meta, ok := m.readProfileMeta(name); ok {
+			info.Description, info.AutoSave, info.CreatedAt, info.Owner = meta.Description, meta.AutoSave, meta.CreatedAt, meta.Owner
+			if meta.Encrypted == sealed && meta.SavedAt != nil {
+				info = meta
+			}
+		}
+		byName[name] = info
+	}
+	profiles := make([]taskstypes.ProfileInfo, 0, len(byName))
+	for _, info := range byName {
+		profiles = append(profiles, info)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// DeleteProfile implements tasks.ProfileExecutor. Sessions already started
+// from the profile keep their copy.
+func (m *Manager) DeleteProfile(name string) error {
+	if taskstypes.ValidateProfileName(name) != nil {
+		return fmt.Errorf("%w: %s", tasks.ErrProfileNotFound, name)
+	}
+	m.profilesMu.Lock()
+	defer m.profilesMu.Unlock()
+	found := false
+	for _, path := range []string{m.profilePath(name, true), m.profilePath(name, false), m.profileMetaPath(name)} {
+		err := os.Remove(path)
+		if err == nil {
+			found = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete profile %s: %w", name, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", tasks.ErrProfileNotFound, name)
+	}
+	return nil
+}
+
+// archiveProfile packs a user-data-dir into a gzipped tarball, leaving out
+// caches, lock files, and anything that is not a regular file.
+func archiveProfile(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if d.IsDir() {
+			if profileSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), "Singleton") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return addProfileFile(tw, path, filepath.ToSlash(rel))
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive profile: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func addProfileFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0o600, Size: stat.Size(), ModTime: stat.ModTime(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, stat.Size())
+	return err
+}
+
+// extractProfile unpacks a snapshot made by archiveProfile into dir. Entries
+// that would land outside dir are rejected.
+func extractProfile(data []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid profile snapshot: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid profile snapshot: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid profile snapshot: entry %q is outside the profile", header.Name)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return fmt.Errorf("failed to restore profile: %w", err)
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to restore profile: %w", err)
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore profile: %w", err)
+		}
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
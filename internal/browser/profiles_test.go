@@ -0,0 +1,181 @@
+package browser
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeProfileFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+}
+
+func TestArchiveProfile_RoundTrip(t *testing.T) {
+	src := t.TempDir()
+	writeProfileFiles(t, src, map[string]string{
+		"Local State":                 `{"profile":{}}`,
+		"Default/Cookies":             "cookie db",
+		"Default/Local Storage/x.log": "storage",
+		"Default/Cache/Cache_Data/f":  "cached response",
+		"Default/Code Cache/js/a":     "compiled",
+		"SingletonLock":               "lock",
+	})
+
+	data, err := archiveProfile(src)
+	require.NoError(t, err)
+
+	dst := t.TempDir()
+	require.NoError(t, extractProfile(data, dst))
+	cookies, err := os.ReadFile(filepath.Join(dst, "Default", "Cookies"))
+	require.NoError(t, err)
+	assert.Equal(t, "cookie db", string(cookies))
+	assert.FileExists(t, filepath.Join(dst, "Local State"))
+	assert.FileExists(t, filepath.Join(dst, "Default", "Local Storage", "x.log"))
+	assert.NoDirExists(t, filepath.Join(dst, "Default", "Cache"), "caches are left out")
+	assert.NoDirExists(t, filepath.Join(dst, "Default", "Code Cache"))
+	assert.NoFileExists(t, filepath.Join(dst, "SingletonLock"), "lock files are left out")
+}
+
+func TestExtractProfile_RejectsEscapingEntries(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../escape", Mode: 0o600, Size: 1, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "profile")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	assert.Error(t, extractProfile(buf.Bytes(), dir))
+	assert.NoFileExists(t, filepath.Join(parent, "escape"))
+}
+
+func TestProfiles_SaveRestoreListDelete(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	keyring, err := encryption.NewKeyring(config.EncryptionConfig{
+		Enabled: true,
+		Keys:    map[string]string{encryption.DefaultTenant: base64.StdEncoding.EncodeToString(key)},
+	})
+	require.NoError(t, err)
+
+	for _, kr := range []*encryption.Keyring{nil, keyring} {
+		m := &Manager{cfg: &config.BrowserConfig{ProfileDir: t.TempDir()}, keyring: kr}
+		task := &taskstypes.Task{ID: uuid.New()}
+
+		// A profile that was never saved starts empty
+		dir, err := m.restoreProfile("acme-sso")
+		require.NoError(t, err)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		writeProfileFiles(t, dir, map[string]string{"Default/Cookies": "session=abc"})
+		info, err := m.saveProfile(task, "acme-sso", dir)
+		require.NoError(t, err)
+		os.RemoveAll(dir)
+		assert.Equal(t, kr != nil, info.Encrypted)
+		assert.Equal(t, task.ID.String(), info.TaskID)
+		assert.FileExists(t, m.profilePath("acme-sso", kr != nil))
+
+		raw, err := os.ReadFile(m.profilePath("acme-sso", kr != nil))
+		require.NoError(t, err)
+		if kr != nil {
+			_, err := gzip.NewReader(bytes.NewReader(raw))
+			assert.Error(t, err, "sealed snapshots are not readable as plain archives")
+		}
+
+		restored, err := m.restoreProfile("acme-sso")
+		require.NoError(t, err)
+		cookies, err := os.ReadFile(filepath.Join(restored, "Default", "Cookies"))
+		require.NoError(t, err)
+		assert.Equal(t, "session=abc", string(cookies))
+		os.RemoveAll(restored)
+
+		list, err := m.ListProfiles()
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, info.Name, list[0].Name)
+		assert.Equal(t, info.Size, list[0].Size)
+		assert.Equal(t, info.TaskID, list[0].TaskID)
+
+		require.NoError(t, m.DeleteProfile("acme-sso"))
+		assert.ErrorIs(t, m.DeleteProfile("acme-sso"), tasks.ErrProfileNotFound)
+		list, err = m.ListProfiles()
+		require.NoError(t, err)
+		assert.Empty(t, list)
+	}
+
+	_, err = (&Manager{cfg: &config.BrowserConfig{}}).restoreProfile("../etc")
+	assert.Error(t, err)
+}
+
+func TestProfiles_Create(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{ProfileDir: t.TempDir()}}
+
+	created, err := m.CreateProfile("acme-sso", "acme", "Acme staff login", true)
+	require.NoError(t, err)
+	assert.NotNil(t, created.CreatedAt)
+	assert.Nil(t, created.SavedAt, "a created profile has not been saved yet")
+	_, err = m.CreateProfile("acme-sso", "acme", "", false)
+	assert.ErrorIs(t, err, tasks.ErrProfileExists)
+	assert.True(t, m.profileAutoSave("acme-sso"))
+
+	list, err := m.ListProfiles()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Acme staff login", list[0].Description)
+	assert.Equal(t, "acme", list[0].Owner)
+	assert.Zero(t, list[0].Size)
+
+	// Saving keeps what the profile was created with
+	dir, err := m.restoreProfile("acme-sso")
+	require.NoError(t, err)
+	writeProfileFiles(t, dir, map[string]string{"Default/Cookies": "session=abc"})
+	info, err := m.saveProfile(&taskstypes.Task{ID: uuid.New(), Tenant: "globex"}, "acme-sso", dir)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", info.Owner, "saving does not change the owner")
+	fresh, err := m.saveProfile(&taskstypes.Task{ID: uuid.New(), Tenant: "globex"}, "globex-sso", dir)
+	require.NoError(t, err)
+	assert.Equal(t, "globex", fresh.Owner, "a profile no one created belongs to the tenant that saves it")
+	require.NoError(t, m.DeleteProfile("globex-sso"))
+	os.RemoveAll(dir)
+	assert.Equal(t, "Acme staff login", info.Description)
+	assert.True(t, info.AutoSave)
+	assert.Equal(t, created.CreatedAt.Unix(), info.CreatedAt.Unix())
+	assert.NotNil(t, info.SavedAt)
+
+	require.NoError(t, m.DeleteProfile("acme-sso"))
+	_, err = m.CreateProfile("unsaved", "acme", "", false)
+	require.NoError(t, err)
+	require.NoError(t, m.DeleteProfile("unsaved"), "profiles that were never saved can be deleted")
+	list, err = m.ListProfiles()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	assert.False(t, m.profileAutoSave("unsaved"))
+
+	_, err = m.CreateProfile("../etc", "acme", "", false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// proxyFlags returns the allocator options that route a browser through proxy.
+func proxyFlags(proxy taskstypes.ProxySettings) []chromedp.ExecAllocatorOption {
+	if proxy.Server == "" {
+		return nil
+	}
+	opts := []chromedp.ExecAllocatorOption{chromedp.ProxyServer(proxy.Server)}
+	if proxy.Bypass != "" {
+		opts = append(opts, chromedp.Flag("proxy-bypass-list", proxy.Bypass))
+	}
+	return opts
+}
+
+// configProxy returns browser.proxy as task proxy settings.
+func configProxy(cfg *config.BrowserConfig) taskstypes.ProxySettings {
+	return taskstypes.ProxySettings{
+		Server:   cfg.Proxy.Server,
+		Username: cfg.Proxy.Username,
+		Password: cfg.Proxy.Password,
+		Bypass:   cfg.Proxy.BypassList,
+	}
+}
+
+// taskProxy returns the proxy a task's browser uses: its own server and
+// credentials, its own credentials for the configured server, or
+// browser.proxy unchanged.
+func (m *Manager) taskProxy(task *taskstypes.Task) (taskstypes.ProxySettings, error) {
+	proxy := configProxy(m.cfg)
+	override := task.Options.Proxy
+	if override == nil {
+		return proxy, nil
+	}
+	if err := override.Validate(); err != nil {
+		return proxy, err
+	}
+	if override.Server != "" {
+		if task.Session != "" {
+			return proxy, fmt.Errorf("a task on session %s cannot change the proxy server; the session's browser is already running", task.Session)
+		}
+		return *override, nil
+	}
+	if proxy.Server == "" {
+		return proxy, fmt.Errorf("proxy credentials given but no proxy server is set in the task or browser.proxy")
+	}
+	proxy.Username, proxy.Password = override.Username, override.Password
+	return proxy, nil
+}
+
+// defaultProxyServer reports whether a task's browser can use the flags of
+// browser.proxy, so it starts from the default allocator.
+func (m *Manager) defaultProxyServer(proxy taskstypes.ProxySettings) bool {
+	return proxy.Server == "" || (proxy.Server == m.cfg.Proxy.Server && proxy.Bypass == m.cfg.Proxy.BypassList)
+}
+
+// taskAllocator returns the allocator a task's browser starts from. Tasks with
+// their own proxy server get a separate allocator carrying the proxy flags.
+func (m *Manager) taskAllocator(proxy taskstypes.ProxySettings) (context.Context, context.CancelFunc) {
+	if m.defaultProxyServer(proxy) {
+		return m.allocatorCtx, func() {}
+	}
+	opts := append(append([]chromedp.ExecAllocatorOption{}, m.execOpts...), proxyFlags(proxy)...)
+	return chromedp.NewExecAllocator(m.allocatorCtx, opts...)
+}
+
+// proxyAuth answers proxy authentication challenges with fixed credentials.
+// Answering them requires Fetch interception, which pauses every request;
+// unless another interceptor (first-party-only mode) resolves paused
+// requests, proxyAuth continues them unchanged.
+type proxyAuth struct {
+	username, password string
+	continueRequests   bool
+
+	mu       sync.Mutex
+	answered map[fetch.RequestID]bool
+}
+
+func newProxyAuth(proxy taskstypes.ProxySettings, continueRequests bool) *proxyAuth {
+	return &proxyAuth{
+		username:         proxy.Username,
+		password:         proxy.Password,
+		continueRequests: continueRequests,
+		answered:         make(map[fetch.RequestID]bool),
+	}
+}
+
+// response decides how to answer a challenge. Credentials are offered once
+// per request; if the proxy rejects them the request fails instead of looping.
+func (a *proxyAuth) response(ev *fetch.EventAuthRequired) *fetch.AuthChallengeResponse {
+	if ev.AuthChallenge == nil || ev.AuthChallenge.Source != fetch.AuthChallengeSourceProxy {
+		return &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseDefault}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.answered[ev.RequestID] {
+		return &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseCancelAuth}
+	}
+	a.answered[ev.RequestID] = true
+	return &fetch.AuthChallengeResponse{
+		Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+		Username: a.username,
+		Password: a.password,
+	}
+}
+
+// install enables auth handling on the browser context. Like the first-party
+// filter, it resolves requests from goroutines since listeners must not block.
+func (a *proxyAuth) install(ctx context.Context) error {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *fetch.EventAuthRequired:
+			go func() {
+				_ = chromedp.Run(ctx, fetch.ContinueWithAuth(ev.RequestID, a.response(ev)))
+			}()
+		case *fetch.EventRequestPaused:
+			if a.continueRequests {
+				go func() {
+					_ = chromedp.Run(ctx, fetch.ContinueRequest(ev.RequestID))
+				}()
+			}
+		}
+	})
+	return chromedp.Run(ctx, fetch.Enable().WithHandleAuthRequests(true))
+}
+
+// uninstall stops interception, restoring a named session's page to normal.
+func (a *proxyAuth) uninstall(ctx context.Context) error {
+	return chromedp.Run(ctx, fetch.Disable())
+}
+
+// withProxyAuth runs fn with proxy challenges answered, if the proxy needs
+// credentials. ContinueRequests is false when a request filter installed
+// earlier resolves paused requests itself.
+func withProxyAuth(ctx context.Context, proxy taskstypes.ProxySettings, continueRequests bool, fn func() error) error {
+	if proxy.Username == "" {
+		return fn()
+	}
+	listenCtx, stopListening := context.WithCancel(ctx)
+	defer stopListening()
+	auth := newProxyAuth(proxy, continueRequests)
+	if err := auth.install(listenCtx); err != nil {
+		return fmt.Errorf("failed to enable proxy authentication: %w", err)
+	}
+	defer auth.uninstall(ctx)
+	return fn()
+}
@@ -0,0 +1,77 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskProxy(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{Proxy: config.ProxyConfig{
+		Server: "http://pool.local:3128", Username: "pool", Password: "pool-pass",
+	}}}
+
+	proxy, err := m.taskProxy(&taskstypes.Task{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://pool.local:3128", proxy.Server)
+	assert.Equal(t, "pool", proxy.Username)
+
+	// Credentials only: the configured server with the task's login
+	proxy, err = m.taskProxy(&taskstypes.Task{Options: taskstypes.TaskOptions{
+		Proxy: &taskstypes.ProxySettings{Username: "session-42", Password: "p"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "http://pool.local:3128", proxy.Server)
+	assert.Equal(t, "session-42", proxy.Username)
+	assert.Equal(t, "p", proxy.Password)
+
+	// A server replaces the configured proxy entirely
+	proxy, err = m.taskProxy(&taskstypes.Task{Options: taskstypes.TaskOptions{
+		Proxy: &taskstypes.ProxySettings{Server: "socks5://10.0.0.2:1080"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, taskstypes.ProxySettings{Server: "socks5://10.0.0.2:1080"}, proxy)
+
+	_, err = m.taskProxy(&taskstypes.Task{Session: "crm", Options: taskstypes.TaskOptions{
+		Proxy: &taskstypes.ProxySettings{Server: "http://other.local:8080"},
+	}})
+	assert.Error(t, err, "sessions keep their browser's proxy")
+
+	_, err = (&Manager{cfg: &config.BrowserConfig{}}).taskProxy(&taskstypes.Task{Options: taskstypes.TaskOptions{
+		Proxy: &taskstypes.ProxySettings{Username: "u", Password: "p"},
+	}})
+	assert.Error(t, err, "credentials without any proxy server")
+}
+
+func TestProxyFlags(t *testing.T) {
+	assert.Empty(t, proxyFlags(taskstypes.ProxySettings{}))
+	assert.Len(t, proxyFlags(taskstypes.ProxySettings{Server: "http://pool.local:3128"}), 1)
+	assert.Len(t, proxyFlags(taskstypes.ProxySettings{Server: "http://pool.local:3128", Bypass: "localhost"}), 2)
+}
+
+func TestProxyAuth_Response(t *testing.T) {
+	auth := newProxyAuth(taskstypes.ProxySettings{Username: "u", Password: "p"}, true)
+	proxyChallenge := &fetch.EventAuthRequired{
+		RequestID:     "1",
+		AuthChallenge: &fetch.AuthChallenge{Source: fetch.AuthChallengeSourceProxy},
+	}
+
+	resp := auth.response(proxyChallenge)
+	assert.Equal(t, fetch.AuthChallengeResponseResponseProvideCredentials, resp.Response)
+	assert.Equal(t, "u", resp.Username)
+	assert.Equal(t, "p", resp.Password)
+
+	// A second challenge for the same request means the credentials were rejected
+	resp = auth.response(proxyChallenge)
+	assert.Equal(t, fetch.AuthChallengeResponseResponseCancelAuth, resp.Response)
+
+	resp = auth.response(&fetch.EventAuthRequired{
+		RequestID:     "2",
+		AuthChallenge: &fetch.AuthChallenge{Source: fetch.AuthChallengeSourceServer},
+	})
+	assert.Equal(t, fetch.AuthChallengeResponseResponseDefault, resp.Response, "site logins are left to the page")
+}
@@ -0,0 +1,59 @@
+package browser
+
+import "fmt"
+
+// randomSource replaces Math.random, crypto.getRandomValues, and
+// crypto.randomUUID with an sfc32 generator seeded from the task's seed, so
+// every document the task loads draws the same sequence on every run. The
+// real getRandomValues still runs first to keep its argument checks.
+const randomSource = `(() => {
+	if (window.__goscryRandom) return;
+	let s = %d >>> 0;
+	const splitmix = () => {
+		s = (s + 0x9e3779b9) | 0;
+		let z = s;
+		z = Math.imul(z ^ (z >>> 16), 0x85ebca6b);
+		z = Math.imul(z ^ (z >>> 13), 0xc2b2ae35);
+		return (z ^ (z >>> 16)) >>> 0;
+	};
+	let a = splitmix(), b = splitmix(), c = splitmix(), d = splitmix();
+	const next = () => {
+		const t = (((a + b) | 0) + d) | 0;
+		d = (d + 1) | 0;
+		a = b ^ (b >>> 9);
+		b = (c + (c << 3)) | 0;
+		c = ((c << 21) | (c >>> 11)) + t | 0;
+		return t >>> 0;
+	};
+
+	Math.random = () => next() / 4294967296;
+
+	if (window.crypto && crypto.getRandomValues) {
+		const getRandomValues = crypto.getRandomValues.bind(crypto);
+		crypto.getRandomValues = (array) => {
+			getRandomValues(array);
+			const bytes = new Uint8Array(array.buffer, array.byteOffset, array.byteLength);
+			for (let i = 0; i < bytes.length; i += 4) {
+				let v = next();
+				for (let j = 0; j < 4 && i + j < bytes.length; j++, v >>>= 8) bytes[i + j] = v & 0xff;
+			}
+			return array;
+		};
+		if (crypto.randomUUID) {
+			crypto.randomUUID = () => {
+				const b = crypto.getRandomValues(new Uint8Array(16));
+				b[6] = (b[6] & 0x0f) | 0x40;
+				b[8] = (b[8] & 0x3f) | 0x80;
+				const h = Array.from(b, (x) => x.toString(16).padStart(2, '0')).join('');
+				return h.slice(0, 8) + '-' + h.slice(8, 12) + '-' + h.slice(12, 16) + '-' + h.slice(16, 20) + '-' + h.slice(20);
+			};
+		}
+	}
+
+	Object.defineProperty(window, '__goscryRandom', { value: true });
+})();`
+
+// randomScript returns the page script that seeds randomness with seed.
+func randomScript(seed uint32) string {
+	return fmt.Sprintf(randomSource, seed)
+}
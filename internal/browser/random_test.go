@@ -0,0 +1,13 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomScript(t *testing.T) {
+	assert.Contains(t, randomScript(42), "let s = 42 >>> 0;")
+	assert.Equal(t, randomScript(7), randomScript(7), "the same seed gives the same script")
+	assert.NotEqual(t, randomScript(7), randomScript(8))
+}
@@ -0,0 +1,136 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/auth"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// defaultLoginPattern matches the paths most sites redirect to when a login expires.
+var defaultLoginPattern = regexp.MustCompile(`(?i)/(login|log-in|signin|sign-in|sign_in|logon|auth|sso)(/|\?|#|$)`)
+
+// ReloginEvent records a session signing back in during a task.
+type ReloginEvent struct {
+	Action   int       `json:"action"` // Action that landed on the login page and was retried
+	URL      string    `json:"url"`    // Login page that was detected
+	Template string    `json:"template"`
+	Version  int       `json:"version"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// loginPattern compiles a session login's URL pattern, or returns the default.
+func loginPattern(login taskstypes.SessionLogin) (*regexp.Regexp, error) {
+	if login.Match == "" {
+		return defaultLoginPattern, nil
+	}
+	return regexp.Compile(login.Match)
+}
+
+// sessionLogin returns the re-login settings of the task's session. Tasks
+// that run the login template themselves never trigger a re-login.
+func (m *Manager) sessionLogin(task *taskstypes.Task) *taskstypes.SessionLogin {
+	if task.Session == "" {
+		return nil
+	}
+	m.sessionsMu.Lock()
+	sess := m.sessions[task.Session]
+	m.sessionsMu.Unlock()
+	if sess == nil {
+		return nil
+	}
+	sess.mu.Lock()
+	login := sess.opts.Login
+	sess.mu.Unlock()
+	if login == nil || task.TemplateName == login.Template {
+		return nil
+	}
+	return login
+}
+
+// reloginAndRetry checks whether an action left the page on a login screen.
+// If so it runs the session's login template and retries the action once.
+// actionErr is returned unchanged when no re-login was needed.
+func (m *Manager) reloginAndRetry(ctx context.Context, index int, action taskstypes.Action, login *taskstypes.SessionLogin, result *taskstypes.TaskResult, actionErr error, retry func() error) error {
+	switch action.Type {
+	case taskstypes.ActionLogin, taskstypes.ActionChangePass, taskstypes.ActionSwitchTab, taskstypes.ActionCloseTab, taskstypes.ActionAdvanceClock:
+		// Logins are expected to land on login pages, a tab switch may
+		// deliberately move to another site's sign-in popup, and advancing
+		// the clock twice would skip past what the page should show
+		return actionErr
+	}
+	if ctx.Err() != nil {
+		return actionErr
+	}
+	pattern, err := loginPattern(*login)
+	if err != nil {
+		return actionErr
+	}
+	if target, ok := navigationURL(action); ok && pattern.MatchString(target) {
+		return actionErr // The task went to the login page on purpose
+	}
+
+	var location string
+	if err := runWithTimeout(ctx, chromedp.Location(&location), m.cfg.ActionTimeout); err != nil || !pattern.MatchString(location) {
+		return actionErr
+	}
+
+	m.logger.InfoContext(ctx, "Session login expired, running login template", "url", location, "template", login.Template)
+	event := ReloginEvent{Action: index, URL: location, Template: login.Template, At: time.Now().UTC()}
+	version, err := m.relogin(ctx, *login)
+	event.Version = version
+	if err != nil {
+		event.Error = err.Error()
+		recordRelogin(result, event)
+		return fmt.Errorf("session login expired and re-login failed: %w", err)
+	}
+	event.Success = true
+	recordRelogin(result, event)
+	return retry()
+}
+
+// relogin runs the login template with the session's credentials and a fresh
+// TOTP code, returning the template version it ran.
+func (m *Manager) relogin(ctx context.Context, login taskstypes.SessionLogin) (int, error) {
+	if m.templates == nil {
+		return 0, fmt.Errorf("templates are not available to the browser executor")
+	}
+	tmpl, err := m.templates.Get(login.Template, login.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	creds, err := m.taskCredentials(ctx, &taskstypes.Task{Credentials: login.Credentials, SealedCreds: login.SealedCreds, CredentialsRef: login.CredentialsRef})
+	if err != nil {
+		return tmpl.Version, err
+	}
+	var code string
+	if login.TOTPSecret != "" {
+		if code, err = auth.GenerateTOTP(login.TOTPSecret); err != nil {
+			return tmpl.Version, err
+		}
+	}
+
+	for i, action := range tmpl.Actions {
+		chromedpAction, err := GenerateActionSequence(action, creds, code)
+		if err != nil {
+			return tmpl.Version, fmt.Errorf("login action %d: %w", i, err)
+		}
+		if err := runWithTimeout(ctx, chromedpAction, m.actionTimeout(action)); err != nil {
+			return tmpl.Version, fmt.Errorf("login action %d (%s): %w", i, action.Type, err)
+		}
+	}
+	return tmpl.Version, nil
+}
+
+// recordRelogin appends an event to result.custom_data.relogins.
+func recordRelogin(result *taskstypes.TaskResult, event ReloginEvent) {
+	events, _ := result.CustomData["relogins"].([]ReloginEvent)
+	setCustomData(result, "relogins", append(events, event))
+}
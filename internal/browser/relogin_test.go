@@ -0,0 +1,50 @@
+package browser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginPattern(t *testing.T) {
+	pattern, err := loginPattern(taskstypes.SessionLogin{})
+	assert.NoError(t, err)
+	assert.True(t, pattern.MatchString("https://app.example.com/login?next=%2Fdashboard"))
+	assert.True(t, pattern.MatchString("https://id.example.com/Sign-In"))
+	assert.True(t, pattern.MatchString("https://example.com/auth/"))
+	assert.False(t, pattern.MatchString("https://example.com/blog/login-tips"))
+	assert.False(t, pattern.MatchString("https://example.com/dashboard"))
+
+	custom, err := loginPattern(taskstypes.SessionLogin{Match: `/portal/session-expired`})
+	assert.NoError(t, err)
+	assert.True(t, custom.MatchString("https://example.com/portal/session-expired"))
+	assert.False(t, custom.MatchString("https://example.com/login"))
+}
+
+func TestSessionLogin(t *testing.T) {
+	login := &taskstypes.SessionLogin{Template: "crm-login"}
+	m := &Manager{sessions: map[string]*session{
+		"crm":   {name: "crm", opts: taskstypes.SessionOptions{Login: login}},
+		"plain": {name: "plain"},
+	}}
+
+	assert.Same(t, login, m.sessionLogin(&taskstypes.Task{Session: "crm"}))
+	assert.Nil(t, m.sessionLogin(&taskstypes.Task{Session: "crm", TemplateName: "crm-login"}), "the login template itself never re-logs in")
+	assert.Nil(t, m.sessionLogin(&taskstypes.Task{Session: "plain"}))
+	assert.Nil(t, m.sessionLogin(&taskstypes.Task{}))
+}
+
+func TestRelogin_MissingTemplate(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{}, logger: logging.Discard()}
+	_, err := m.relogin(context.Background(), taskstypes.SessionLogin{Template: "crm-login"})
+	assert.Error(t, err, "no template store")
+
+	m.SetTemplates(tasks.NewTemplateStore())
+	_, err = m.relogin(context.Background(), taskstypes.SessionLogin{Template: "crm-login"})
+	assert.ErrorIs(t, err, tasks.ErrTemplateNotFound)
+}
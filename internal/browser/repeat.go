@@ -0,0 +1,100 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/dom"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// pageSettleDelay is how long a repeating action waits after clicking Next
+// when no wait_for selector is given.
+const pageSettleDelay = time.Second
+
+// nextEnabledScript reports whether the next-page element exists and is not
+// disabled, which is how most listings mark the last page.
+const nextEnabledScript = `(function(sel) {
+	const el = document.querySelector(sel);
+	return !!el && !el.disabled && el.getAttribute('aria-disabled') !== 'true';
+})(%s)`
+
+// presentScript reports whether any element matches the selector.
+const presentScript = `document.querySelector(%s) !== null`
+
+// maxPages returns how many pages a repeating action may visit.
+func (m *Manager) maxPages(spec taskstypes.RepeatSpec) int {
+	limit := m.cfg.MaxPages
+	if spec.MaxPages > 0 && (limit <= 0 || spec.MaxPages < limit) {
+		return spec.MaxPages
+	}
+	if limit <= 0 {
+		return 1
+	}
+	return limit
+}
+
+// repeatAction runs action once per page, clicking spec.Next between runs.
+// The action's timeout covers the whole loop.
+func (m *Manager) repeatAction(action chromedp.Action, spec taskstypes.RepeatSpec) (chromedp.Action, error) {
+	if spec.Next == "" {
+		return nil, fmt.Errorf("repeat requires a next selector")
+	}
+	limit := m.maxPages(spec)
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for page := 1; ; page++ {
+			if err := action.Do(ctx); err != nil {
+				return fmt.Errorf("page %d: %w", page, err)
+			}
+			if page >= limit {
+				return nil
+			}
+			if spec.StopWhen != "" {
+				stop, err := evaluateSelector(ctx, presentScript, spec.StopWhen)
+				if err != nil {
+					return err
+				}
+				if stop {
+					return nil
+				}
+			}
+			hasNext, err := evaluateSelector(ctx, nextEnabledScript, spec.Next)
+			if err != nil {
+				return err
+			}
+			if !hasNext {
+				return nil
+			}
+
+			if err := dom.ClickAction(spec.Next).Do(ctx); err != nil {
+				return fmt.Errorf("failed to open page %d: %w", page+1, err)
+			}
+			var settle chromedp.Action = chromedp.Sleep(pageSettleDelay)
+			if spec.WaitFor != "" {
+				settle = chromedp.WaitVisible(spec.WaitFor, chromedp.ByQuery)
+			}
+			if err := settle.Do(ctx); err != nil {
+				return fmt.Errorf("page %d did not load: %w", page+1, err)
+			}
+		}
+	}), nil
+}
+
+// evaluateSelector runs a boolean check script with the selector as its argument.
+func evaluateSelector(ctx context.Context, script, selector string) (bool, error) {
+	var ok bool
+	if err := chromedp.Evaluate(fmt.Sprintf(script, jsString(selector)), &ok).Do(ctx); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// jsString quotes s as a JavaScript string literal.
+func jsString(s string) string {
+	quoted, _ := json.Marshal(s) // Marshaling a string cannot fail
+	return string(quoted)
+}
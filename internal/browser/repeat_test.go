@@ -0,0 +1,36 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxPages(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{MaxPages: 20}}
+
+	assert.Equal(t, 20, m.maxPages(taskstypes.RepeatSpec{}))
+	assert.Equal(t, 5, m.maxPages(taskstypes.RepeatSpec{MaxPages: 5}))
+	assert.Equal(t, 20, m.maxPages(taskstypes.RepeatSpec{MaxPages: 500}), "config caps the action")
+
+	m.cfg.MaxPages = 0
+	assert.Equal(t, 5, m.maxPages(taskstypes.RepeatSpec{MaxPages: 5}))
+	assert.Equal(t, 1, m.maxPages(taskstypes.RepeatSpec{}))
+}
+
+func TestRepeatAction_RequiresNext(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{MaxPages: 20}}
+	_, err := m.repeatAction(nil, taskstypes.RepeatSpec{})
+	assert.Error(t, err)
+}
+
+func TestAppendPage(t *testing.T) {
+	var pages []interface{}
+	pages = appendPage(pages, []interface{}{"a", "b"})
+	pages = appendPage(pages, []interface{}{"c"})
+	pages = appendPage(pages, map[string]interface{}{"title": "d"})
+
+	assert.Equal(t, []interface{}{"a", "b", "c", map[string]interface{}{"title": "d"}}, pages)
+}
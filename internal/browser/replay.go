@@ -0,0 +1,249 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+)
+
+// maxUnmatchedURLsReported caps the URLs listed in a replay report.
+const maxUnmatchedURLsReported = 50
+
+// ReplayReport summarizes a replayed run, in result.custom_data.replay.
+type ReplayReport struct {
+	Source        string   `json:"source"`   // "task:<id>", "har", or "network"
+	Recorded      int      `json:"recorded"` // Responses available to replay
+	Served        int      `json:"served"`
+	Unmatched     int      `json:"unmatched"` // Requests with no recorded response
+	UnmatchedURLs []string `json:"unmatched_urls,omitempty"`
+}
+
+// replayResponse is a recorded response, decoded and ready to fulfill a request with.
+type replayResponse struct {
+	status     int64
+	statusText string
+	headers    []*fetch.HeaderEntry
+	body       []byte
+	failed     bool // The request failed or never got a response
+}
+
+// replayer answers paused requests with recorded responses. Requests
+// recorded several times get their responses in order, and the last one
+// is repeated once they run out.
+type replayer struct {
+	source      string
+	ignoreQuery bool
+	passThrough bool
+	guard       *urlGuard // Checks requests passed through to the network; nil without a URL policy
+
+	mu            sync.Mutex
+	responses     map[string][]*replayResponse
+	recorded      int
+	served        int
+	unmatched     int
+	unmatchedURLs []string
+	unmatchedSeen map[string]bool
+}
+
+func newReplayer(source string, har harFile, opts taskstypes.ReplayOptions) (*replayer, error) {
+	r := &replayer{
+		source:        source,
+		ignoreQuery:   opts.IgnoreQuery,
+		passThrough:   opts.Unmatched == taskstypes.ReplayUnmatchedContinue,
+		responses:     make(map[string][]*replayResponse),
+		unmatchedSeen: make(map[string]bool),
+	}
+	for i, entry := range har.Log.Entries {
+		resp, err := replayResponseFor(entry)
+		if err != nil {
+			return nil, fmt.Errorf("replay entry %d (%s): %w", i, entry.Request.URL, err)
+		}
+		key := r.key(entry.Request.Method, entry.Request.URL)
+		r.responses[key] = append(r.responses[key], resp)
+		r.recorded++
+	}
+	if r.recorded == 0 {
+		return nil, fmt.Errorf("replay source %s has no entries", source)
+	}
+	return r, nil
+}
+
+// replayResponseFor decodes a HAR entry's response.
+func replayResponseFor(entry harEntry) (*replayResponse, error) {
+	res := entry.Response
+	resp := &replayResponse{status: res.Status, statusText: res.StatusText}
+	if res.Status < 100 {
+		// Failed requests are exported with status 0
+		resp.failed = true
+		return resp, nil
+	}
+	for _, h := range res.Headers {
+		switch strings.ToLower(h.Name) {
+		case "content-encoding", "content-length", "transfer-encoding":
+			// The recorded body is already decoded, and Chrome sets the length
+			continue
+		}
+		if strings.HasPrefix(h.Name, ":") {
+			// HTTP/2 pseudo-headers in archives exported by browsers
+			continue
+		}
+		resp.headers = append(resp.headers, &fetch.HeaderEntry{Name: h.Name, Value: h.Value})
+	}
+	if res.Content.Encoding == "base64" {
+		body, err := base64.StdEncoding.DecodeString(res.Content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 body: %w", err)
+		}
+		resp.body = body
+	} else {
+		resp.body = []byte(res.Content.Text)
+	}
+	return resp, nil
+}
+
+// key identifies a request by method and URL, without the fragment and,
+// with ignoreQuery, without the query string.
+func (r *replayer) key(method, rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		u.Fragment, u.RawFragment = "", ""
+		if r.ignoreQuery {
+			u.RawQuery, u.ForceQuery = "", false
+		}
+		rawURL = u.String()
+	}
+	return strings.ToUpper(method) + " " + rawURL
+}
+
+// next returns the response for a request, or nil if none was recorded.
+func (r *replayer) next(method, rawURL string) *replayResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := r.key(method, rawURL)
+	queue := r.responses[key]
+	if len(queue) == 0 {
+		r.unmatched++
+		if !r.unmatchedSeen[key] && len(r.unmatchedURLs) < maxUnmatchedURLsReported {
+			r.unmatchedSeen[key] = true
+			r.unmatchedURLs = append(r.unmatchedURLs, rawURL)
+		}
+		return nil
+	}
+	resp := queue[0]
+	if len(queue) > 1 {
+		r.responses[key] = queue[1:]
+	}
+	r.served++
+	return resp
+}
+
+// resolve decides how a paused request is answered.
+func (r *replayer) resolve(paused *fetch.EventRequestPaused) chromedp.Action {
+	resp := r.next(paused.Request.Method, paused.Request.URL)
+	switch {
+	case resp == nil && r.passThrough:
+		return fetch.ContinueRequest(paused.RequestID)
+	case resp == nil:
+		return fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient)
+	case resp.failed:
+		return fetch.FailRequest(paused.RequestID, network.ErrorReasonFailed)
+	}
+	fulfill := fetch.FulfillRequest(paused.RequestID, resp.status).
+		WithResponseHeaders(resp.headers).
+		WithBody(base64.StdEncoding.EncodeToString(resp.body))
+	if resp.statusText != "" {
+		fulfill = fulfill.WithResponsePhrase(resp.statusText)
+	}
+	return fulfill
+}
+
+// install enables request interception on the browser context. Requests are
+// resolved from a goroutine because listeners must not block on CDP calls.
+func (r *replayer) install(ctx context.Context) error {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		go func() {
+			action := r.resolve(paused)
+			if _, passes := action.(*fetch.ContinueRequestParams); passes && !r.guard.allows(ctx, paused) {
+				action = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient)
+			}
+			// Errors here mean the target went away; nothing left to resolve
+			_ = chromedp.Run(ctx, action)
+		}()
+	})
+	return chromedp.Run(ctx, fetch.Enable())
+}
+
+// uninstall stops interception, restoring a named session's page to normal.
+func (r *replayer) uninstall(ctx context.Context) error {
+	return chromedp.Run(ctx, fetch.Disable())
+}
+
+func (r *replayer) report() ReplayReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ReplayReport{
+		Source:        r.source,
+		Recorded:      r.recorded,
+		Served:        r.served,
+		Unmatched:     r.unmatched,
+		UnmatchedURLs: append([]string(nil), r.unmatchedURLs...),
+	}
+}
+
+// loadReplay reads the recording a task replays: the HAR archive of the task
+// in TaskID, an inline HAR log, or inline network capture entries.
+func (m *Manager) loadReplay(ctx context.Context, opts taskstypes.ReplayOptions) (*replayer, error) {
+	switch {
+	case opts.TaskID != "":
+		id, err := uuid.Parse(opts.TaskID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replay task ID %q", opts.TaskID)
+		}
+		rc, _, err := m.artifactStore().Get(ctx, id, harArtifact)
+		if errors.Is(err, artifacts.ErrNotFound) {
+			return nil, fmt.Errorf("task %s has no HAR archive to replay; run it with the har option", id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HAR archive of task %s: %w", id, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HAR archive of task %s: %w", id, err)
+		}
+		var har harFile
+		if err := json.Unmarshal(data, &har); err != nil {
+			return nil, fmt.Errorf("invalid HAR archive of task %s: %w", id, err)
+		}
+		return newReplayer("task:"+id.String(), har, opts)
+	case len(opts.HAR) > 0:
+		var har harFile
+		if err := json.Unmarshal(opts.HAR, &har); err != nil {
+			return nil, fmt.Errorf("invalid replay HAR: %w", err)
+		}
+		return newReplayer("har", har, opts)
+	case len(opts.Network) > 0:
+		var entries []NetworkEntry
+		if err := json.Unmarshal(opts.Network, &entries); err != nil {
+			return nil, fmt.Errorf("invalid replay network entries: %w", err)
+		}
+		return newReplayer("network", buildHAR(entries), opts)
+	}
+	return nil, fmt.Errorf("replay needs exactly one of task_id, har, or network")
+}
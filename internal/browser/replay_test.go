@@ -0,0 +1,84 @@
+package browser
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pausedRequest(method, url string) *fetch.EventRequestPaused {
+	return &fetch.EventRequestPaused{RequestID: "r1", Request: &network.Request{Method: method, URL: url}}
+}
+
+func TestReplayer_ServesRecordedResponses(t *testing.T) {
+	har := buildHAR([]NetworkEntry{
+		{Method: "GET", URL: "https://example.com/", Status: 200, StatusText: "OK", ResponseBody: "<h1>Home</h1>",
+			ResponseHeaders: map[string]string{"Content-Type": "text/html", "Content-Encoding": "gzip", "Content-Length": "13"}},
+		{Method: "GET", URL: "https://example.com/api/count", Status: 200, ResponseBody: `{"n":1}`},
+		{Method: "GET", URL: "https://example.com/api/count", Status: 200, ResponseBody: `{"n":2}`},
+		{Method: "GET", URL: "https://example.com/logo.png", Status: 200, ResponseBody: base64.StdEncoding.EncodeToString([]byte{0x89, 'P', 'N', 'G'}), BodyBase64: true},
+		{Method: "GET", URL: "https://cdn.example.net/app.js", Failed: true, ErrorText: "net::ERR_NAME_NOT_RESOLVED"},
+	})
+	r, err := newReplayer("har", har, taskstypes.ReplayOptions{})
+	require.NoError(t, err)
+
+	action := r.resolve(pausedRequest("GET", "https://example.com/#top"))
+	fulfill, ok := action.(*fetch.FulfillRequestParams)
+	require.True(t, ok, "recorded responses are fulfilled")
+	assert.Equal(t, int64(200), fulfill.ResponseCode)
+	assert.Equal(t, "OK", fulfill.ResponsePhrase)
+	assert.Equal(t, []*fetch.HeaderEntry{{Name: "Content-Type", Value: "text/html"}}, fulfill.ResponseHeaders, "encoding and length headers are dropped")
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("<h1>Home</h1>")), fulfill.Body)
+
+	// Repeated requests get the recorded responses in order, then the last again
+	for _, want := range []string{`{"n":1}`, `{"n":2}`, `{"n":2}`} {
+		fulfill := r.resolve(pausedRequest("GET", "https://example.com/api/count")).(*fetch.FulfillRequestParams)
+		body, _ := base64.StdEncoding.DecodeString(fulfill.Body)
+		assert.Equal(t, want, string(body))
+	}
+
+	fulfill = r.resolve(pausedRequest("GET", "https://example.com/logo.png")).(*fetch.FulfillRequestParams)
+	body, _ := base64.StdEncoding.DecodeString(fulfill.Body)
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, body, "base64 bodies are decoded")
+
+	failed, ok := r.resolve(pausedRequest("GET", "https://cdn.example.net/app.js")).(*fetch.FailRequestParams)
+	require.True(t, ok, "recorded failures fail again")
+	assert.Equal(t, network.ErrorReasonFailed, failed.ErrorReason)
+
+	blocked, ok := r.resolve(pausedRequest("POST", "https://example.com/api/count")).(*fetch.FailRequestParams)
+	require.True(t, ok, "unmatched requests fail by default")
+	assert.Equal(t, network.ErrorReasonBlockedByClient, blocked.ErrorReason)
+
+	report := r.report()
+	assert.Equal(t, ReplayReport{Source: "har", Recorded: 5, Served: 6, Unmatched: 1, UnmatchedURLs: []string{"https://example.com/api/count"}}, report)
+}
+
+func TestReplayer_IgnoreQueryAndPassThrough(t *testing.T) {
+	entries, err := json.Marshal([]NetworkEntry{{Method: "GET", URL: "https://example.com/feed?_=1700000000", Status: 200, ResponseBody: "[]"}})
+	require.NoError(t, err)
+	var recorded []NetworkEntry
+	require.NoError(t, json.Unmarshal(entries, &recorded), "the network capture format is what replay reads")
+	r, err := newReplayer("network", buildHAR(recorded), taskstypes.ReplayOptions{IgnoreQuery: true, Unmatched: taskstypes.ReplayUnmatchedContinue})
+	require.NoError(t, err)
+
+	_, ok := r.resolve(pausedRequest("GET", "https://example.com/feed?_=1800000000")).(*fetch.FulfillRequestParams)
+	assert.True(t, ok, "the query string is ignored")
+	_, ok = r.resolve(pausedRequest("GET", "https://example.com/other")).(*fetch.ContinueRequestParams)
+	assert.True(t, ok, "unmatched requests go to the network")
+}
+
+func TestNewReplayer_Errors(t *testing.T) {
+	_, err := newReplayer("har", harFile{}, taskstypes.ReplayOptions{})
+	assert.Error(t, err, "an empty recording cannot be replayed")
+
+	var har harFile
+	require.NoError(t, json.Unmarshal([]byte(`{"log":{"entries":[{"request":{"method":"GET","url":"https://example.com/"},"response":{"status":200,"content":{"text":"%%%","encoding":"base64"}}}]}}`), &har))
+	_, err = newReplayer("har", har, taskstypes.ReplayOptions{})
+	assert.Error(t, err)
+}
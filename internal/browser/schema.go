@@ -0,0 +1,395 @@
+package browser
+
+import (
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// jsonSchemaDialect is the JSON Schema version ActionSchema is written in.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches the non-negative durations time.ParseDuration
+// accepts, such as "500ms" or "1m30s".
+const durationPattern = `^(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h))+)$`
+
+// actionSpec describes the fields one action type uses and which of them
+// it requires. It must agree with the checks GenerateActionSequence and the
+// Manager's action builders make; schema_test.go holds them to it.
+type actionSpec struct {
+	description string
+	properties  map[string]interface{}
+	required    []string
+	rules       map[string]interface{} // Further keywords, e.g. anyOf for "one of these fields"
+	example     taskstypes.Action
+}
+
+func selectorProperty(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "minLength": 1, "description": description}
+}
+
+func stringProperty(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+func refProperty(def, description string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/$defs/" + def, "description": description}
+}
+
+var actionSpecs = map[taskstypes.ActionType]actionSpec{
+	taskstypes.ActionNavigate: {
+		description: "Navigates to a URL and waits for the page to load.",
+		properties: map[string]interface{}{
+			"value": refProperty("URL", "URL to load"),
+			"wait_until": map[string]interface{}{
+				"enum":        []string{taskstypes.WaitUntilLoad, taskstypes.WaitUntilDOMContentLoaded, taskstypes.WaitUntilNetworkIdle, taskstypes.WaitUntilSelector},
+				"default":     taskstypes.WaitUntilLoad,
+				"description": "When navigation counts as done; selector waits for selector to be visible",
+			},
+			"selector": selectorProperty("Element to wait for with wait_until selector"),
+		},
+		required: []string{"value"},
+		rules: map[string]interface{}{
+			"if": map[string]interface{}{
+				"properties": map[string]interface{}{"wait_until": map[string]interface{}{"const": taskstypes.WaitUntilSelector}},
+				"required":   []string{"wait_until"},
+			},
+			"then": map[string]interface{}{"required": []string{"selector"}},
+		},
+		example: taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://example.com"},
+	},
+	taskstypes.ActionWaitVisible: {
+		description: "Waits for an element to become visible.",
+		properties:  map[string]interface{}{"selector": selectorProperty("Element to wait for")},
+		required:    []string{"selector"},
+		example:     taskstypes.Action{Type: taskstypes.ActionWaitVisible, Selector: "#content"},
+	},
+	taskstypes.ActionWaitHidden: {
+		description: "Waits for an element to become hidden.",
+		properties:  map[string]interface{}{"selector": selectorProperty("Element to wait for")},
+		required:    []string{"selector"},
+		example:     taskstypes.Action{Type: taskstypes.ActionWaitHidden, Selector: ".spinner"},
+	},
+	taskstypes.ActionWaitDelay: {
+		description: "Pauses for a duration.",
+		properties:  map[string]interface{}{"value": refProperty("Duration", "How long to pause")},
+		required:    []string{"value"},
+		example:     taskstypes.Action{Type: taskstypes.ActionWaitDelay, Value: "2s"},
+	},
+	taskstypes.ActionClick: {
+		description: "Waits for an element to be visible and clicks it.",
+		properties:  map[string]interface{}{"selector": selectorProperty("Element to click")},
+		required:    []string{"selector"},
+		example:     taskstypes.Action{Type: taskstypes.ActionClick, Selector: "#submit"},
+	},
+	taskstypes.ActionInput: {
+		description: "Types text into an element.",
+		properties: map[string]interface{}{
+			"selector": selectorProperty("Element to type into"),
+			"value":    stringProperty("Text to type; {{task.tfa_code}} types the 2FA code"),
+		},
+		required: []string{"selector"},
+		example:  taskstypes.Action{Type: taskstypes.ActionInput, Selector: "#search", Value: "goscry"},
+	},
+	taskstypes.ActionSelect: {
+		description: "Selects an option of a <select> element by its value.",
+		properties: map[string]interface{}{
+			"selector": selectorProperty("The <select> element"),
+			"value":    stringProperty("Value attribute of the option to select"),
+		},
+		required: []string{"selector"},
+		example:  taskstypes.Action{Type: taskstypes.ActionSelect, Selector: "#country", Value: "NL"},
+	},
+	taskstypes.ActionScroll: {
+		description: "Scrolls to the top or bottom of the page, or an element into view.",
+		properties: map[string]interface{}{
+			"value":    map[string]interface{}{"enum": []string{"top", "bottom"}, "description": "Where to scroll; takes precedence over selector"},
+			"selector": selectorProperty("Element to scroll into view"),
+		},
+		rules: map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"required": []string{"value"}},
+				map[string]interface{}{"required": []string{"selector"}},
+			},
+		},
+		example: taskstypes.Action{Type: taskstypes.ActionScroll, Value: "bottom"},
+	},
+	taskstypes.ActionScreenshot: {
+		description: "Captures the full page, an element, or a region and saves it as an artifact.",
+		properties: map[string]interface{}{
+			"value": map[string]interface{}{
+				"type":        "string",
+				"pattern":     `^(100|[1-9]?[0-9])$`,
+				"description": "JPEG quality from 0 to 100; 100 saves a PNG",
+			},
+			"selector": selectorProperty("Element to capture"),
+			"clip":     refProperty("ClipRect", "Region of the page to capture"),
+		},
+		rules: map[string]interface{}{
+			"not": map[string]interface{}{"required": []string{"selector", "clip"}},
+		},
+		example: taskstypes.Action{Type: taskstypes.ActionScreenshot},
+	},
+	taskstypes.ActionGetDOM: {
+		description: "Returns the content of an element, by default the body.",
+		properties: map[string]interface{}{
+			"selector": selectorProperty("Element to read; defaults to body"),
+			"format": map[string]interface{}{
+				"enum":    []string{"text_content", "full_html", "simplified_html"},
+				"default": "text_content",
+			},
+		},
+		example: taskstypes.Action{Type: taskstypes.ActionGetDOM, Format: "simplified_html"},
+	},
+	taskstypes.ActionRunScript: {
+		description: "Runs JavaScript in the page and returns its result.",
+		properties: map[string]interface{}{
+			"value": map[string]interface{}{"type": "string", "minLength": 1, "description": "Script to run"},
+		},
+		required: []string{"value"},
+		example:  taskstypes.Action{Type: taskstypes.ActionRunScript, Value: "document.title"},
+	},
+	taskstypes.ActionLogin: {
+		description: "Logs in with the task's credentials.",
+		properties: map[string]interface{}{
+			"login":  refProperty("LoginForm", "Fields of the login form"),
+			"verify": refProperty("LoginCheck", "How to tell the login worked"),
+		},
+		example: taskstypes.Action{Type: taskstypes.ActionLogin, Login: &taskstypes.LoginForm{Preset: "github"}},
+	},
+	taskstypes.ActionDownload: {
+		description: "Clicks an element, or loads a URL, and captures the file it downloads.",
+		properties: map[string]interface{}{
+			"selector": selectorProperty("Element to click"),
+			"value":    refProperty("URL", "URL to download when there is no selector"),
+			"format":   map[string]interface{}{"enum": []string{"base64"}, "description": "Return the file inline instead of saving it"},
+		},
+		rules: map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"required": []string{"selector"}},
+				map[string]interface{}{"required": []string{"value"}},
+			},
+		},
+		example: taskstypes.Action{Type: taskstypes.ActionDownload, Selector: "a.export"},
+	},
+	taskstypes.ActionSecurity: {
+		description: "Loads a URL and reports its TLS details and security headers.",
+		properties:  map[string]interface{}{"value": refProperty("URL", "URL to check")},
+		required:    []string{"value"},
+		example:     taskstypes.Action{Type: taskstypes.ActionSecurity, Value: "https://example.com"},
+	},
+	taskstypes.ActionCloaking: {
+		description: "Loads a URL as several clients and reports whether they were served different pages.",
+		properties:  map[string]interface{}{"value": refProperty("URL", "URL to check")},
+		required:    []string{"value"},
+		example:     taskstypes.Action{Type: taskstypes.ActionCloaking, Value: "https://example.com"},
+	},
+	taskstypes.ActionExtract: {
+		description: "Reads fields from the page into structured data.",
+		properties: map[string]interface{}{
+			"selector": selectorProperty("Container element; returns one object per match"),
+			"value":    stringProperty("Key to save the data under; defaults to action_<index>"),
+			"fields": map[string]interface{}{
+				"type":                 "object",
+				"minProperties":        1,
+				"additionalProperties": map[string]interface{}{"$ref": "#/$defs/ExtractField"},
+				"description":          "Output fields by name",
+			},
+			"virtual": refProperty("VirtualList", "Scroll through a virtualized list, reading rows matching selector as they mount"),
+		},
+		rules: map[string]interface{}{
+			"dependentRequired": map[string]interface{}{"virtual": []string{"selector"}},
+		},
+		required: []string{"fields"},
+		example: taskstypes.Action{Type: taskstypes.ActionExtract, Fields: map[string]taskstypes.ExtractField{
+			"title": {Selector: "h1"},
+		}},
+	},
+	taskstypes.ActionChangePass: {
+		description: "Changes the password of the logged-in account to the credentials' new_password.",
+		properties: map[string]interface{}{
+			"selector": selectorProperty("Submit button"),
+			"password": refProperty("PasswordForm", "Fields of the change-password form"),
+			"verify":   refProperty("LoginCheck", "How to tell the change worked"),
+		},
+		example: taskstypes.Action{Type: taskstypes.ActionChangePass},
+	},
+	taskstypes.ActionSwitchTab: {
+		description: "Makes a tab or popup the one later actions run on.",
+		properties: map[string]interface{}{
+			"value": stringProperty("Tab index or text in the tab's URL; defaults to the newest other tab"),
+		},
+		example: taskstypes.Action{Type: taskstypes.ActionSwitchTab},
+	},
+	taskstypes.ActionCloseTab: {
+		description: "Closes a tab, by default the current one.",
+		properties: map[string]interface{}{
+			"value": stringProperty("Tab index or text in the tab's URL"),
+		},
+		example: taskstypes.Action{Type: taskstypes.ActionCloseTab},
+	},
+	taskstypes.ActionAdvanceClock: {
+		description: "Moves the page clock set by the clock option forward.",
+		properties:  map[string]interface{}{"value": refProperty("Duration", "How far to move the clock")},
+		required:    []string{"value"},
+		example:     taskstypes.Action{Type: taskstypes.ActionAdvanceClock, Value: "90s"},
+	},
+	taskstypes.ActionFindText: {
+		description: "Finds text in the visible page and returns the match count, the text around each match, and the elements containing them.",
+		properties: map[string]interface{}{
+			"value":    map[string]interface{}{"type": "string", "minLength": 1, "description": "Text, or a regular expression with find.regex, to find"},
+			"selector": selectorProperty("Element to search within; defaults to body"),
+			"find":     refProperty("TextSearch", "How to match the text"),
+		},
+		required: []string{"value"},
+		example:  taskstypes.Action{Type: taskstypes.ActionFindText, Value: "Order confirmed"},
+	},
+}
+
+// sharedDefinitions are the schemas action fields refer to.
+func sharedDefinitions() map[string]interface{} {
+	return map[string]interface{}{
+		"URL": map[string]interface{}{
+			"type": "string",
+			"anyOf": []interface{}{
+				map[string]interface{}{"format": "uri"},
+				map[string]interface{}{"pattern": `\{\{`}, // Resolved from earlier action output at run time
+			},
+		},
+		"Duration": map[string]interface{}{"type": "string", "pattern": durationPattern},
+		"Condition": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"selector": selectorProperty("Element to check"),
+				"state": map[string]interface{}{
+					"enum":    []string{taskstypes.ConditionPresent, taskstypes.ConditionAbsent, taskstypes.ConditionVisible, taskstypes.ConditionHidden},
+					"default": taskstypes.ConditionPresent,
+				},
+			},
+			"required": []string{"selector"},
+		},
+		"RepeatSpec": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"next":      selectorProperty("Next-page link or button"),
+				"stop_when": stringProperty("Stop once this selector is present"),
+				"wait_for":  stringProperty("Selector to wait for after each click"),
+				"max_pages": map[string]interface{}{"type": "integer", "minimum": 0},
+			},
+			"required": []string{"next"},
+		},
+		"LoginCheck": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"success_url":       map[string]interface{}{"type": "string", "format": "regex"},
+				"success_selector":  stringProperty("Element shown only when logged in"),
+				"success_cookie":    stringProperty("Cookie set by a successful login"),
+				"failure_selectors": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"failure_text":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "minLength": 1}},
+			},
+		},
+		"LoginForm": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"preset":            map[string]interface{}{"enum": loginPresetNames(), "description": "Well-known sign-in page"},
+				"username_selector": stringProperty("Username or email field"),
+				"password_selector": stringProperty("Password field"),
+				"submit_selector":   stringProperty("Sign-in button"),
+				"next_selector":     stringProperty("Button clicked after the username when the password is asked for next"),
+			},
+		},
+		"PasswordForm": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"current_selector": stringProperty("Current password field"),
+				"new_selector":     stringProperty("New password field"),
+				"confirm_selector": stringProperty("Confirm password field"),
+			},
+		},
+		"ClipRect": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"x":      map[string]interface{}{"type": "number", "minimum": 0},
+				"y":      map[string]interface{}{"type": "number", "minimum": 0},
+				"width":  map[string]interface{}{"type": "number", "exclusiveMinimum": 0},
+				"height": map[string]interface{}{"type": "number", "exclusiveMinimum": 0},
+			},
+			"required": []string{"x", "y", "width", "height"},
+		},
+		"VirtualList": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"container":   stringProperty("Scrolling element; defaults to the page"),
+				"key":         stringProperty("Field that identifies a row; defaults to comparing whole rows"),
+				"step":        map[string]interface{}{"type": "integer", "minimum": 0, "description": "Pixels per scroll; defaults to 80% of the container's height"},
+				"settle":      refProperty("Duration", "Wait after each scroll; defaults to 250ms"),
+				"max_scrolls": map[string]interface{}{"type": "integer", "minimum": 0, "default": defaultVirtualMaxScrolls},
+				"max_rows":    map[string]interface{}{"type": "integer", "minimum": 0},
+			},
+		},
+		"TextSearch": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"regex":          map[string]interface{}{"type": "boolean", "description": "Value is a JavaScript regular expression"},
+				"case_sensitive": map[string]interface{}{"type": "boolean", "default": false},
+				"context":        map[string]interface{}{"type": "integer", "minimum": 0, "maximum": maxFindContext, "default": defaultFindContext},
+				"max_matches":    map[string]interface{}{"type": "integer", "minimum": 0, "default": defaultFindMaxMatches},
+			},
+		},
+		"ExtractField": map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "string", "description": "Selector whose text is read"},
+				map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"selector":  stringProperty("Element relative to the container; empty reads the container"),
+						"attribute": stringProperty("Attribute to read instead of the text"),
+						"all":       map[string]interface{}{"type": "boolean", "description": "Return every match as an array"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// actionTypeSchema is the schema of one action type. Fields every action
+// accepts, such as timeout and if, are included.
+func actionTypeSchema(actionType taskstypes.ActionType, spec actionSpec) map[string]interface{} {
+	properties := map[string]interface{}{
+		"type":    map[string]interface{}{"const": actionType},
+		"timeout": refProperty("Duration", "Overrides browser.actionTimeout"),
+		"if":      refProperty("Condition", "Run only when the condition holds"),
+		"else":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#"}, "description": "Run instead when if does not hold"},
+		"repeat":  refProperty("RepeatSpec", "Run once per page of a paginated listing"),
+	}
+	for name, property := range spec.properties {
+		properties[name] = property
+	}
+	schema := map[string]interface{}{
+		"type":        "object",
+		"description": spec.description,
+		"properties":  properties,
+		"required":    append([]string{"type"}, spec.required...),
+		"examples":    []interface{}{spec.example},
+	}
+	for keyword, rule := range spec.rules {
+		schema[keyword] = rule
+	}
+	return schema
+}
+
+// ActionSchema returns a JSON Schema that a task action validates against,
+// with the schema of each action type under $defs/<type>. It checks what can
+// be checked without a browser; a valid action can still fail when it runs.
+func ActionSchema() map[string]interface{} {
+	defs := sharedDefinitions()
+	oneOf := make([]interface{}, 0, len(taskstypes.ActionTypes))
+	for _, actionType := range taskstypes.ActionTypes {
+		defs[string(actionType)] = actionTypeSchema(actionType, actionSpecs[actionType])
+		oneOf = append(oneOf, map[string]interface{}{"$ref": "#/$defs/" + string(actionType)})
+	}
+	return map[string]interface{}{
+		"$schema": jsonSchemaDialect,
+		"title":   "GoScry task action",
+		"oneOf":   oneOf,
+		"$defs":   defs,
+	}
+}
@@ -0,0 +1,134 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/examples"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildAction builds an action the way runAction does, far enough to run
+// its parameter checks.
+func buildAction(m *Manager, action taskstypes.Action) error {
+	task := &taskstypes.Task{}
+	result := &taskstypes.TaskResult{}
+	creds := &taskstypes.Credentials{Username: "user", Password: "old", NewPassword: "new"}
+	var err error
+	switch action.Type {
+	case taskstypes.ActionDownload:
+		_, err = m.downloadAction(task, action, result)
+	case taskstypes.ActionScreenshot:
+		_, err = m.screenshotAction(task, 0, action, result)
+	case taskstypes.ActionSecurity:
+		_, err = m.securityReportAction(action, result)
+	case taskstypes.ActionCloaking:
+		_, err = m.cloakingCheckAction(action, task.Options, result)
+	case taskstypes.ActionExtract:
+		_, err = m.extractAction(0, action, result)
+	case taskstypes.ActionChangePass:
+		_, err = m.changePasswordAction(task, 0, action, creds, result)
+	case taskstypes.ActionSwitchTab, taskstypes.ActionCloseTab:
+		// Any value is accepted; an unmatched one fails when the action runs
+	case taskstypes.ActionGetDOM, taskstypes.ActionRunScript, taskstypes.ActionFindText:
+		var out interface{}
+		_, err = GenerateOutputAction(action, &out)
+	default:
+		_, err = GenerateActionSequence(action, creds, "")
+	}
+	return err
+}
+
+func TestActionSchema_CoversEveryField(t *testing.T) {
+	schema := ActionSchema()
+	defs := schema["$defs"].(map[string]interface{})
+	assert.Len(t, schema["oneOf"], len(taskstypes.ActionTypes))
+	assert.Len(t, actionSpecs, len(taskstypes.ActionTypes), "every spec should be for a listed action type")
+
+	described := map[string]bool{}
+	for _, actionType := range taskstypes.ActionTypes {
+		def, ok := defs[string(actionType)].(map[string]interface{})
+		require.True(t, ok, "no schema for %s", actionType)
+		for name := range def["properties"].(map[string]interface{}) {
+			described[name] = true
+		}
+	}
+
+	fields := reflect.TypeOf(taskstypes.Action{})
+	for i := 0; i < fields.NumField(); i++ {
+		name := strings.Split(fields.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			name = strings.ToLower(fields.Field(i).Name) // Timeout has its own wire form
+		}
+		assert.True(t, described[name], "action field %q is in no action schema", name)
+	}
+
+	_, err := json.Marshal(schema)
+	assert.NoError(t, err)
+}
+
+func TestActionSchema_MatchesValidation(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{}}
+	for _, actionType := range taskstypes.ActionTypes {
+		spec := actionSpecs[actionType]
+		t.Run(string(actionType), func(t *testing.T) {
+			require.Equal(t, actionType, spec.example.Type)
+			require.NoError(t, buildAction(m, spec.example), "example should be valid")
+
+			for _, field := range spec.required {
+				data, err := json.Marshal(spec.example)
+				require.NoError(t, err)
+				var wire map[string]interface{}
+				require.NoError(t, json.Unmarshal(data, &wire))
+				delete(wire, field)
+				data, err = json.Marshal(wire)
+				require.NoError(t, err)
+				var action taskstypes.Action
+				require.NoError(t, json.Unmarshal(data, &action))
+				assert.Error(t, buildAction(m, action), "action without required %q should be rejected", field)
+			}
+		})
+	}
+
+	// Rules beyond required fields
+	invalid := []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://example.com", WaitUntil: taskstypes.WaitUntilSelector},
+		{Type: taskstypes.ActionNavigate, Value: "https://example.com", WaitUntil: "idle"},
+		{Type: taskstypes.ActionScroll},
+		{Type: taskstypes.ActionDownload},
+		{Type: taskstypes.ActionDownload, Selector: "a", Format: "zip"},
+		{Type: taskstypes.ActionScreenshot, Selector: "#chart", Clip: &taskstypes.ClipRect{Width: 10, Height: 10}},
+		{Type: taskstypes.ActionScreenshot, Value: "101"},
+		{Type: taskstypes.ActionWaitDelay, Value: "soon"},
+		{Type: taskstypes.ActionAdvanceClock, Value: "-1s"},
+		{Type: taskstypes.ActionLogin, Login: &taskstypes.LoginForm{Preset: "myspace"}},
+		{Type: taskstypes.ActionFindText, Value: "Total", Find: &taskstypes.TextSearch{Context: 5000}},
+		{Type: taskstypes.ActionExtract, Fields: map[string]taskstypes.ExtractField{"name": {}}, Virtual: &taskstypes.VirtualList{}},
+		{Type: taskstypes.ActionExtract, Selector: ".row", Fields: map[string]taskstypes.ExtractField{"name": {}}, Virtual: &taskstypes.VirtualList{Key: "id"}},
+		{Type: taskstypes.ActionExtract, Selector: ".row", Fields: map[string]taskstypes.ExtractField{"name": {}}, Virtual: &taskstypes.VirtualList{Settle: "soon"}},
+		{Type: taskstypes.ActionFindText, Value: "Total", Find: &taskstypes.TextSearch{MaxMatches: -1}},
+	}
+	for _, action := range invalid {
+		assert.Error(t, buildAction(m, action), "%+v should be rejected", action)
+	}
+}
+
+func TestExamples_PassValidation(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{}}
+	for _, example := range examples.All() {
+		for i, action := range example.Actions {
+			assert.NoError(t, buildAction(m, action), "%s action %d", example.Name, i)
+			if action.Repeat != nil {
+				_, err := m.repeatAction(chromedp.ActionFunc(func(context.Context) error { return nil }), *action.Repeat)
+				assert.NoError(t, err, "%s action %d", example.Name, i)
+			}
+		}
+	}
+}
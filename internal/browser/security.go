@@ -0,0 +1,188 @@
+package browser
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// securityHeaders are the response headers a posture check looks for.
+var securityHeaders = []string{
+	"content-security-policy",
+	"strict-transport-security",
+	"x-frame-options",
+	"x-content-type-options",
+	"referrer-policy",
+	"permissions-policy",
+}
+
+// SecurityReport describes the TLS connection and security headers of a
+// page's main document response.
+type SecurityReport struct {
+	URL            string            `json:"url"`
+	Status         int64             `json:"status"`
+	Protocol       string            `json:"protocol,omitempty"` // e.g. "h2"
+	TLS            *TLSDetails       `json:"tls,omitempty"`      // Nil for plain HTTP
+	Headers        map[string]string `json:"headers"`            // Security headers that were present
+	MissingHeaders []string          `json:"missing_headers"`
+}
+
+// TLSDetails is the negotiated connection and certificate chain.
+type TLSDetails struct {
+	Protocol                string            `json:"protocol"` // e.g. "TLS 1.3"
+	KeyExchange             string            `json:"key_exchange,omitempty"`
+	Cipher                  string            `json:"cipher"`
+	Subject                 string            `json:"subject"`
+	Issuer                  string            `json:"issuer"`
+	SANs                    []string          `json:"sans"`
+	ValidFrom               time.Time         `json:"valid_from"`
+	ValidTo                 time.Time         `json:"valid_to"`
+	DaysRemaining           int               `json:"days_remaining"`
+	CertificateTransparency string            `json:"certificate_transparency"`
+	Chain                   []CertificateInfo `json:"chain,omitempty"` // Leaf first
+}
+
+// CertificateInfo summarizes one certificate in the served chain.
+type CertificateInfo struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+}
+
+// securityReportAction navigates to Value and reports the main document's TLS
+// details and security headers in result.custom_data.security_reports.
+func (m *Manager) securityReportAction(action taskstypes.Action, result *taskstypes.TaskResult) (chromedp.Action, error) {
+	if action.Value == "" {
+		return nil, fmt.Errorf("security_report action requires a URL value")
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		tree, err := page.GetFrameTree().Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get main frame: %w", err)
+		}
+		mainFrame := tree.Frame.ID
+
+		listenCtx, stopListening := context.WithCancel(ctx)
+		defer stopListening()
+
+		var mu sync.Mutex
+		var mainResponse *network.Response
+		chromedp.ListenTarget(listenCtx, func(ev interface{}) {
+			if ev, ok := ev.(*network.EventResponseReceived); ok &&
+				ev.Type == network.ResourceTypeDocument && ev.FrameID == mainFrame {
+				mu.Lock()
+				mainResponse = ev.Response
+				mu.Unlock()
+			}
+		})
+
+		if err := chromedp.Navigate(action.Value).Do(ctx); err != nil {
+			return err
+		}
+		stopListening()
+
+		mu.Lock()
+		resp := mainResponse
+		mu.Unlock()
+		if resp == nil {
+			return fmt.Errorf("no main document response received for %s", action.Value)
+		}
+
+		report := buildSecurityReport(resp, time.Now())
+		if report.TLS != nil {
+			report.TLS.Chain = certificateChain(ctx, resp.URL)
+		}
+
+		reports, _ := result.CustomData["security_reports"].([]SecurityReport)
+		setCustomData(result, "security_reports", append(reports, report))
+		return nil
+	}), nil
+}
+
+func buildSecurityReport(resp *network.Response, now time.Time) SecurityReport {
+	report := SecurityReport{
+		URL:      resp.URL,
+		Status:   resp.Status,
+		Protocol: resp.Protocol,
+		Headers:  make(map[string]string),
+	}
+
+	present := make(map[string]string, len(resp.Headers))
+	for name, value := range resp.Headers {
+		present[strings.ToLower(name)] = fmt.Sprint(value)
+	}
+	for _, name := range securityHeaders {
+		if value, ok := present[name]; ok {
+			report.Headers[name] = value
+		} else {
+			report.MissingHeaders = append(report.MissingHeaders, name)
+		}
+	}
+
+	if sd := resp.SecurityDetails; sd != nil {
+		details := &TLSDetails{
+			Protocol:                sd.Protocol,
+			KeyExchange:             sd.KeyExchange,
+			Cipher:                  sd.Cipher,
+			Subject:                 sd.SubjectName,
+			Issuer:                  sd.Issuer,
+			SANs:                    sd.SanList,
+			CertificateTransparency: sd.CertificateTransparencyCompliance.String(),
+		}
+		if sd.ValidFrom != nil {
+			details.ValidFrom = sd.ValidFrom.Time().UTC()
+		}
+		if sd.ValidTo != nil {
+			details.ValidTo = sd.ValidTo.Time().UTC()
+			details.DaysRemaining = int(details.ValidTo.Sub(now).Hours() / 24)
+		}
+		report.TLS = details
+	}
+	return report
+}
+
+// certificateChain fetches the served certificate chain for the URL's origin.
+// The chain is best-effort: the report is still useful without it.
+func certificateChain(ctx context.Context, rawURL string) []CertificateInfo {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	names, err := network.GetCertificate(u.Scheme + "://" + u.Host).Do(ctx)
+	if err != nil {
+		return nil
+	}
+
+	chain := make([]CertificateInfo, 0, len(names))
+	for _, encoded := range names {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			continue
+		}
+		chain = append(chain, CertificateInfo{
+			Subject:            cert.Subject.String(),
+			Issuer:             cert.Issuer.String(),
+			NotBefore:          cert.NotBefore.UTC(),
+			NotAfter:           cert.NotAfter.UTC(),
+			SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		})
+	}
+	return chain
+}
@@ -0,0 +1,49 @@
+package browser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSecurityReport(t *testing.T) {
+	now := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	validTo := cdp.TimeSinceEpoch(now.Add(30 * 24 * time.Hour))
+
+	report := buildSecurityReport(&network.Response{
+		URL:      "https://example.com/",
+		Status:   200,
+		Protocol: "h2",
+		Headers: network.Headers{
+			"Strict-Transport-Security": "max-age=63072000",
+			"x-frame-options":           "DENY",
+		},
+		SecurityDetails: &network.SecurityDetails{
+			Protocol:                          "TLS 1.3",
+			Cipher:                            "AES_128_GCM",
+			SubjectName:                       "example.com",
+			Issuer:                            "Example CA",
+			SanList:                           []string{"example.com", "www.example.com"},
+			ValidTo:                           &validTo,
+			CertificateTransparencyCompliance: network.CertificateTransparencyComplianceCompliant,
+		},
+	}, now)
+
+	assert.Equal(t, "max-age=63072000", report.Headers["strict-transport-security"])
+	assert.Equal(t, "DENY", report.Headers["x-frame-options"])
+	assert.Contains(t, report.MissingHeaders, "content-security-policy")
+	assert.NotContains(t, report.MissingHeaders, "x-frame-options")
+
+	require.NotNil(t, report.TLS)
+	assert.Equal(t, "TLS 1.3", report.TLS.Protocol)
+	assert.Equal(t, 30, report.TLS.DaysRemaining)
+	assert.Equal(t, "compliant", report.TLS.CertificateTransparency)
+
+	plain := buildSecurityReport(&network.Response{URL: "http://example.com/", Status: 200}, now)
+	assert.Nil(t, plain.TLS)
+	assert.Len(t, plain.MissingHeaders, len(securityHeaders))
+}
@@ -0,0 +1,385 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// Compile-time check to ensure Manager supports named sessions
+var _ tasks.SessionExecutor = (*Manager)(nil)
+
+// session is a long-lived browser context shared by the tasks that name it.
+// Tasks on the same session run one at a time so they never fight over the page.
+type session struct {
+	name      string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	createdAt time.Time
+	opts      taskstypes.SessionOptions // opts.Login is swapped under mu when a password is rotated
+
+	run sync.Mutex // Held for the duration of each task on this session
+
+	mu              sync.Mutex // Guards the fields below
+	lastUsedAt      time.Time
+	taskCount       int
+	busy            bool
+	lastKeepAliveAt time.Time
+	keepAliveErr    error
+	cdpRevoke       func() // Set while the session is lent out over CDP
+}
+
+func (s *session) info() taskstypes.SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := taskstypes.SessionInfo{
+		Name:       s.name,
+		CreatedAt:  s.createdAt,
+		LastUsedAt: s.lastUsedAt,
+		TaskCount:  s.taskCount,
+		Busy:       s.busy,
+	}
+	if s.opts.MaxLifetime > 0 {
+		expires := s.createdAt.Add(s.opts.MaxLifetime)
+		info.ExpiresAt = &expires
+	}
+	if s.opts.KeepAlive > 0 {
+		info.KeepAlive = s.opts.KeepAlive.String()
+	}
+	if !s.lastKeepAliveAt.IsZero() {
+		last := s.lastKeepAliveAt
+		info.LastKeepAliveAt = &last
+	}
+	if s.keepAliveErr != nil {
+		info.KeepAliveError = s.keepAliveErr.Error()
+	}
+	if s.opts.Login != nil {
+		info.LoginTemplate = s.opts.Login.Template
+	}
+	info.Profile = s.opts.Profile
+	info.Owner = s.opts.Owner
+	info.CDPLeased = s.cdpRevoke != nil
+	return info
+}
+
+// revokeCDP asks the holder of a CDP lease on the session, if any, to hand it back.
+func (s *session) revokeCDP() {
+	s.mu.Lock()
+	revoke := s.cdpRevoke
+	s.mu.Unlock()
+	if revoke != nil {
+		revoke()
+	}
+}
+
+// CreateSession starts a browser context that persists cookies, storage, and
+// page state across every task that references it by name. Each session holds
+// one of the browser.maxSessions slots until it is closed or reaches its
+// MaxLifetime.
+func (m *Manager) CreateSession(name string, opts taskstypes.SessionOptions) (*taskstypes.SessionInfo, error) {
+	if name == "" {
+		return nil, fmt.Errorf("session name is required")
+	}
+
+	m.sessionsMu.Lock()
+	if _, exists := m.sessions[name]; exists {
+		m.sessionsMu.Unlock()
+		return nil, fmt.Errorf("%w: %s", tasks.ErrSessionExists, name)
+	}
+	// Reserve the name while the browser starts
+	m.sessions[name] = nil
+	m.sessionsMu.Unlock()
+
+	sess, err := m.startSession(name, opts)
+
+	m.sessionsMu.Lock()
+	defer m.sessionsMu.Unlock()
+	if err != nil {
+		delete(m.sessions, name)
+		return nil, err
+	}
+	m.sessions[name] = sess
+	if opts.KeepAlive > 0 || opts.MaxLifetime > 0 {
+		go m.maintainSession(sess)
+	}
+	info := sess.info()
+	return &info, nil
+}
+
+func (m *Manager) startSession(name string, opts taskstypes.SessionOptions) (*session, error) {
+	if !m.tryAcquireSlot() {
+		return nil, fmt.Errorf("no browser slots available for a new session (max %d)", m.cfg.MaxSessions)
+	}
+
+	allocatorCtx, cancelAllocator := m.allocatorCtx, func() {}
+	if opts.Profile != "" {
+		// The session gets its own browser on a copy of the profile, removed when it closes
+		dir, err := m.restoreProfile(opts.Profile)
+		if err != nil {
+			m.releaseSlot()
+			return nil, fmt.Errorf("failed to restore profile for session %s: %w", name, err)
+		}
+		var cancel context.CancelFunc
+		allocatorCtx, cancel = m.profileAllocator(configProxy(m.cfg), dir)
+		cancelAllocator = func() {
+			cancel()
+			os.RemoveAll(dir)
+		}
+	}
+
+	logCtx := logging.WithAttrs(context.Background(), slog.String("session", name))
+	ctx, cancelBrowser := chromedp.NewContext(
+		allocatorCtx,
+		chromedp.WithLogf(logging.Printf(logCtx, m.logger, slog.LevelDebug)),
+		chromedp.WithErrorf(logging.Printf(logCtx, m.logger, slog.LevelWarn)),
+	)
+	cancel := func() {
+		cancelBrowser()
+		cancelAllocator()
+	}
+	// Run with no actions to launch the browser now, so failures surface at creation time
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		m.releaseSlot()
+		return nil, fmt.Errorf("failed to start browser for session %s: %w", name, err)
+	}
+
+	now := time.Now().UTC()
+	return &session{
+		name:       name,
+		ctx:        ctx,
+		cancel:     cancel,
+		createdAt:  now,
+		opts:       opts,
+		lastUsedAt: now,
+	}, nil
+}
+
+// GetSession returns information about a named session.
+func (m *Manager) GetSession(name string) (*taskstypes.SessionInfo, error) {
+	m.sessionsMu.Lock()
+	sess := m.sessions[name]
+	m.sessionsMu.Unlock()
+
+	if sess == nil {
+		return nil, fmt.Errorf("%w: %s", tasks.ErrSessionNotFound, name)
+	}
+	info := sess.info()
+	return &info, nil
+}
+
+// ListSessions returns all open sessions sorted by name.
+func (m *Manager) ListSessions() []taskstypes.SessionInfo {
+	m.sessionsMu.Lock()
+	defer m.sessionsMu.Unlock()
+
+	infos := make([]taskstypes.SessionInfo, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		if sess != nil {
+			infos = append(infos, sess.info())
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// CloseSession waits for any running task on the session to finish, then
+// closes its browser and frees its slot.
+func (m *Manager) CloseSession(name string) error {
+	m.sessionsMu.Lock()
+	sess := m.sessions[name]
+	if sess == nil {
+		m.sessionsMu.Unlock()
+		return fmt.Errorf("%w: %s", tasks.ErrSessionNotFound, name)
+	}
+	delete(m.sessions, name)
+	m.sessionsMu.Unlock()
+
+	m.stopSession(sess)
+	return nil
+}
+
+// stopSession waits for the session's running task or CDP lease, then closes
+// its browser and frees its slot. The session must already be removed from m.sessions.
+func (m *Manager) stopSession(sess *session) {
+	sess.revokeCDP()
+	sess.run.Lock()
+	defer sess.run.Unlock()
+	sess.cancel()
+	m.releaseSlot()
+}
+
+// maintainSession pings the session every KeepAlive interval, skipping pings
+// while a task is running, and closes it when MaxLifetime is reached.
+func (m *Manager) maintainSession(sess *session) {
+	var expired <-chan time.Time
+	if sess.opts.MaxLifetime > 0 {
+		timer := time.NewTimer(time.Until(sess.createdAt.Add(sess.opts.MaxLifetime)))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var ping <-chan time.Time
+	var pingTimer *time.Timer
+	if sess.opts.KeepAlive > 0 {
+		pingTimer = time.NewTimer(keepAliveDelay(sess.opts))
+		defer pingTimer.Stop()
+		ping = pingTimer.C
+	}
+
+	for {
+		select {
+		case <-sess.ctx.Done():
+			return
+		case <-expired:
+			m.expireSession(sess)
+			return
+		case <-ping:
+			m.keepAlive(sess)
+			pingTimer.Reset(keepAliveDelay(sess.opts))
+		}
+	}
+}
+
+// keepAliveDelay is the keep-alive interval plus random jitter, so sessions
+// created together do not ping in lockstep.
+func keepAliveDelay(opts taskstypes.SessionOptions) time.Duration {
+	delay := opts.KeepAlive
+	if opts.KeepAliveJitter > 0 {
+		delay += rand.N(opts.KeepAliveJitter + 1)
+	}
+	return delay
+}
+
+// keepAlive reloads the session's page, or loads KeepAliveURL, so the site
+// sees activity. A session busy with a task is already active and is skipped.
+// Pings are held to security.urlPolicy as tasks are, including redirects and
+// the requests the page makes.
+func (m *Manager) keepAlive(sess *session) {
+	if !sess.run.TryLock() {
+		return
+	}
+	defer sess.run.Unlock()
+
+	ctx := sess.ctx
+	if timeout := m.cfg.ActionTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := m.ping(ctx, sess)
+	if err != nil {
+		m.logger.Warn("Keep-alive failed", "session", sess.name, "error", err)
+	}
+
+	sess.mu.Lock()
+	sess.lastKeepAliveAt = time.Now().UTC()
+	sess.keepAliveErr = err
+	sess.mu.Unlock()
+}
+
+// ping loads the session's KeepAliveURL, or reloads its page.
+func (m *Manager) ping(ctx context.Context, sess *session) error {
+	target := sess.opts.KeepAliveURL
+	var action chromedp.Action = chromedp.Reload()
+	if target != "" {
+		if err := m.urlPolicy.CheckResolved(ctx, target, true); err != nil {
+			return err
+		}
+		action = chromedp.Navigate(target)
+	}
+
+	guard := newURLGuard(m.urlPolicy)
+	if guard != nil {
+		listenCtx, stopListening := context.WithCancel(ctx)
+		defer stopListening()
+		if err := guard.install(listenCtx); err != nil {
+			return fmt.Errorf("failed to enforce the URL policy: %w", err)
+		}
+		defer func() {
+			// On the session's context, since ctx may have timed out
+			if err := guard.uninstall(sess.ctx); err != nil {
+				m.logger.Warn("Failed to disable request interception", "session", sess.name, "error", err)
+			}
+		}()
+	}
+	err := withProxyAuth(ctx, configProxy(m.cfg), guard == nil, func() error {
+		return chromedp.Run(ctx, action)
+	})
+	if blocked := guard.blockedNavigation(); blocked != nil {
+		return blocked
+	}
+	return err
+}
+
+// expireSession closes a session that reached its MaxLifetime, unless it was
+// already closed or replaced by a new session with the same name.
+func (m *Manager) expireSession(sess *session) {
+	m.sessionsMu.Lock()
+	if m.sessions[sess.name] != sess {
+		m.sessionsMu.Unlock()
+		return
+	}
+	delete(m.sessions, sess.name)
+	m.sessionsMu.Unlock()
+
+	m.logger.Info("Session reached its max lifetime, closing", "session", sess.name, "max_lifetime", sess.opts.MaxLifetime)
+	m.stopSession(sess)
+}
+
+// acquireSession locks a session for exclusive use by one task.
+// The returned release func must be called when the task finishes.
+func (m *Manager) acquireSession(name string) (*session, func(), error) {
+	m.sessionsMu.Lock()
+	sess := m.sessions[name]
+	m.sessionsMu.Unlock()
+	if sess == nil {
+		return nil, nil, fmt.Errorf("%w: %s", tasks.ErrSessionNotFound, name)
+	}
+
+	sess.run.Lock()
+	if sess.ctx.Err() != nil {
+		sess.run.Unlock()
+		return nil, nil, fmt.Errorf("session %s is closed", name)
+	}
+
+	sess.mu.Lock()
+	sess.busy = true
+	sess.taskCount++
+	sess.mu.Unlock()
+
+	release := func() {
+		sess.mu.Lock()
+		sess.busy = false
+		sess.lastUsedAt = time.Now().UTC()
+		sess.mu.Unlock()
+		sess.run.Unlock()
+	}
+	return sess, release, nil
+}
+
+// closeAllSessions cancels every session without waiting for running tasks.
+// It is used during shutdown, where those tasks are torn down with the allocator.
+func (m *Manager) closeAllSessions() {
+	m.sessionsMu.Lock()
+	defer m.sessionsMu.Unlock()
+
+	for name, sess := range m.sessions {
+		if sess != nil {
+			sess.cancel()
+			m.releaseSlot()
+		}
+		delete(m.sessions, name)
+	}
+}
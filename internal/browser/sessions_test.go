@@ -0,0 +1,36 @@
+package browser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepAliveDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, keepAliveDelay(taskstypes.SessionOptions{KeepAlive: time.Minute}))
+
+	opts := taskstypes.SessionOptions{KeepAlive: time.Minute, KeepAliveJitter: 10 * time.Second}
+	for i := 0; i < 50; i++ {
+		delay := keepAliveDelay(opts)
+		assert.GreaterOrEqual(t, delay, time.Minute)
+		assert.LessOrEqual(t, delay, time.Minute+10*time.Second)
+	}
+}
+
+func TestSessionInfo_Lifetime(t *testing.T) {
+	created := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	sess := &session{
+		name:      "crm",
+		createdAt: created,
+		opts:      taskstypes.SessionOptions{KeepAlive: 5 * time.Minute, MaxLifetime: 8 * time.Hour},
+	}
+
+	info := sess.info()
+	if assert.NotNil(t, info.ExpiresAt) {
+		assert.Equal(t, created.Add(8*time.Hour), *info.ExpiresAt)
+	}
+	assert.Equal(t, "5m0s", info.KeepAlive)
+	assert.Nil(t, info.LastKeepAliveAt)
+}
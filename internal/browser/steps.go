@@ -0,0 +1,159 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+const (
+	// stepScreenshotQuality keeps step captures small; they are for seeing
+	// what the page looked like, not for reading fine print.
+	stepScreenshotQuality = 50
+	// stepCaptureTimeout bounds a step capture, so a page that stopped
+	// responding does not hold up the task.
+	stepCaptureTimeout = 5 * time.Second
+)
+
+// highlightID marks the overlay drawn around a step's target element.
+const highlightID = "__goscry_highlight"
+
+// highlightScript outlines the first element matching the selector with a
+// fixed overlay that ignores the pointer, and returns the element's box in
+// the viewport, or null when it is missing, has no size, or is out of view.
+// The page is not scrolled, so what the capture shows is what the page
+// looked like.
+const highlightScript = `(function(sel, id) {
+	const el = document.querySelector(sel);
+	if (!el) return null;
+	const rect = el.getBoundingClientRect();
+	if (rect.width <= 0 || rect.height <= 0) return null;
+	if (rect.bottom < 0 || rect.right < 0 || rect.top > window.innerHeight || rect.left > window.innerWidth) return null;
+	const box = document.createElement('div');
+	box.id = id;
+	box.style.cssText = 'position:fixed;pointer-events:none;z-index:2147483647;box-sizing:border-box;' +
+		'border:3px solid #ff2d55;background:rgba(255,45,85,0.15);' +
+		'left:' + (rect.left - 3) + 'px;top:' + (rect.top - 3) + 'px;' +
+		'width:' + (rect.width + 6) + 'px;height:' + (rect.height + 6) + 'px';
+	document.documentElement.appendChild(box);
+	return {x: rect.left, y: rect.top, width: rect.width, height: rect.height};
+})(%s, %s)`
+
+// stepTarget returns the selector of the element an action works on, or ""
+// for actions without one. A login's target is its username field.
+func stepTarget(action taskstypes.Action) string {
+	if action.Type == taskstypes.ActionLogin {
+		form, err := loginForm(action)
+		if err != nil {
+			return ""
+		}
+		return form.UsernameSelector
+	}
+	return failedSelector(action)
+}
+
+// Step is one action's entry in result.custom_data.steps, written when a
+// task has the screenshot_each_step option.
+type Step struct {
+	Action          int                   `json:"action"`
+	Type            taskstypes.ActionType `json:"type"`
+	StartedAt       time.Time             `json:"started_at"`
+	DurationMs      int64                 `json:"duration_ms"`
+	URL             string                `json:"url,omitempty"` // The page after the action
+	Error           string                `json:"error,omitempty"`
+	Screenshot      string                `json:"screenshot,omitempty"` // Artifact captured after the action, e.g. "step-3.jpg"
+	ScreenshotError string                `json:"screenshot_error,omitempty"`
+	Highlight       *taskstypes.ClipRect  `json:"highlight,omitempty"` // The target element outlined in the screenshot, in viewport CSS pixels
+}
+
+// recordStep adds an action's outcome to result.custom_data.steps with a
+// JPEG of the active tab's viewport as it was left, whether the action
+// succeeded or not. A failed capture is noted in the step but does not fail
+// the task.
+func (m *Manager) recordStep(ctx context.Context, task *taskstypes.Task, tabs *tabSet, index int, action taskstypes.Action, started time.Time, actionErr error, result *taskstypes.TaskResult) {
+	step := Step{Action: index, Type: action.Type, StartedAt: started.UTC(), DurationMs: time.Since(started).Milliseconds()}
+	if actionErr != nil {
+		step.Error = actionErr.Error()
+	}
+	defer func() {
+		steps, _ := result.CustomData["steps"].([]Step)
+		setCustomData(result, "steps", append(steps, step))
+	}()
+	if ctx.Err() != nil {
+		// The task was stopped; its page can no longer be captured
+		return
+	}
+
+	tabCtx, leaveTab := tabs.on(ctx)
+	defer leaveTab()
+	captureCtx, cancel := context.WithTimeout(tabCtx, stepCaptureTimeout)
+	defer cancel()
+	if err := chromedp.Run(captureCtx, chromedp.Location(&step.URL)); err != nil {
+		step.ScreenshotError = err.Error()
+		return
+	}
+	var target string
+	if task.Options.HighlightTarget {
+		target = stepTarget(action)
+	}
+	name, highlight, err := m.captureStep(captureCtx, task, index, target)
+	step.Highlight = highlight
+	if err != nil {
+		step.ScreenshotError = err.Error()
+		return
+	}
+	step.Screenshot = name
+}
+
+// captureStep saves the viewport as "step-<index>.jpg" in the task's
+// artifact store and returns the artifact's name. With a target selector,
+// the element is outlined for the capture and its box returned; the outline
+// is removed again afterwards.
+func (m *Manager) captureStep(ctx context.Context, task *taskstypes.Task, index int, target string) (string, *taskstypes.ClipRect, error) {
+	release, err := m.acquireArtifactSlot(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	defer release()
+
+	var highlight *taskstypes.ClipRect
+	if target != "" {
+		// A target that cannot be outlined still gets a plain capture
+		script := fmt.Sprintf(highlightScript, jsString(target), jsString(highlightID))
+		if err := chromedp.Run(ctx, chromedp.Evaluate(script, &highlight)); err != nil {
+			highlight = nil
+		}
+		defer func() {
+			remove := fmt.Sprintf(`document.getElementById(%s)?.remove()`, jsString(highlightID))
+			chromedp.Run(ctx, chromedp.Evaluate(remove, nil))
+		}()
+	}
+
+	params := page.CaptureScreenshot().
+		WithFormat(page.CaptureScreenshotFormatJpeg).
+		WithQuality(stepScreenshotQuality).
+		WithFromSurface(true)
+	var shot struct {
+		Data string `json:"data"`
+	}
+	capture := chromedp.ActionFunc(func(ctx context.Context) error {
+		return cdp.Execute(ctx, page.CommandCaptureScreenshot, params, &shot)
+	})
+	if err := chromedp.Run(ctx, capture); err != nil {
+		return "", nil, fmt.Errorf("failed to capture step screenshot: %w", err)
+	}
+	if size := decodedSize(shot.Data); m.overArtifactLimit(size) {
+		return "", nil, m.rejectArtifact("step screenshot", size)
+	}
+	obj, err := m.putBase64(ctx, task, fmt.Sprintf("step-%d.jpg", index), shot.Data)
+	if err != nil {
+		return "", nil, err
+	}
+	m.recordArtifact(obj.Size)
+	return obj.Name, highlight, nil
+}
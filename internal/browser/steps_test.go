@@ -0,0 +1,41 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordStep(t *testing.T) {
+	m := &Manager{cfg: &config.BrowserConfig{}}
+	task := &taskstypes.Task{}
+	result := &taskstypes.TaskResult{}
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	started := time.Now().Add(-1500 * time.Millisecond)
+	m.recordStep(stopped, task, nil, 0, taskstypes.Action{Type: taskstypes.ActionNavigate}, started, nil, result)
+	m.recordStep(stopped, task, nil, 1, taskstypes.Action{Type: taskstypes.ActionClick}, started, errors.New("no such element"), result)
+
+	steps, ok := result.CustomData["steps"].([]Step)
+	require.True(t, ok)
+	require.Len(t, steps, 2)
+	assert.Equal(t, taskstypes.ActionNavigate, steps[0].Type)
+	assert.GreaterOrEqual(t, steps[0].DurationMs, int64(1500))
+	assert.Equal(t, 1, steps[1].Action)
+	assert.Equal(t, "no such element", steps[1].Error, "failed actions get a step too")
+	assert.Empty(t, steps[1].Screenshot, "a stopped task's page is not captured")
+}
+
+func TestStepTarget(t *testing.T) {
+	assert.Equal(t, "#buy", stepTarget(taskstypes.Action{Type: taskstypes.ActionClick, Selector: "#buy"}))
+	assert.Empty(t, stepTarget(taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://example.com"}))
+	assert.Equal(t, "#login_field", stepTarget(taskstypes.Action{Type: taskstypes.ActionLogin, Login: &taskstypes.LoginForm{Preset: "github"}}),
+		"a login is outlined at its username field")
+}
@@ -0,0 +1,320 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// TabInfo describes a tab in result.custom_data.tabs.
+type TabInfo struct {
+	Index    int    `json:"index"` // 0 is the task's own page
+	URL      string `json:"url"`
+	OpenedBy int    `json:"opened_by_action,omitempty"` // Action running when the tab opened
+	Closed   bool   `json:"closed,omitempty"`
+}
+
+type tab struct {
+	id     target.ID
+	info   TabInfo
+	ctx    context.Context
+	cancel context.CancelFunc
+	ready  chan struct{} // Closed once the tab is attached and set up
+	err    error         // Why the tab could not be attached or set up; set before ready is closed
+}
+
+// tabSet tracks the task's page and the tabs and popups it opens, such as
+// OAuth consent or payment windows, and which of them actions run on.
+type tabSet struct {
+	browserCtx context.Context
+	attached   func(context.Context) error         // Sets up each tab as soon as it is attached; a tab it fails for is closed
+	checkURL   func(context.Context, string) error // Refuses switching to a tab whose URL it returns an error for; nil allows any
+	run        func(context.Context, ...chromedp.Action) error
+
+	mu      sync.Mutex
+	tabs    []*tab
+	active  int
+	action  int
+	changed chan struct{} // Closed and replaced whenever a tab opens, closes, or navigates
+}
+
+func newTabSet(browserCtx context.Context) *tabSet {
+	main := &tab{ctx: browserCtx, ready: make(chan struct{})}
+	close(main.ready)
+	if c := chromedp.FromContext(browserCtx); c != nil && c.Target != nil {
+		main.id = c.Target.TargetID
+	}
+	return &tabSet{browserCtx: browserCtx, tabs: []*tab{main}, changed: make(chan struct{}), run: chromedp.Run}
+}
+
+// install starts tracking tabs opened from the task's page, or from tabs it opened.
+func (t *tabSet) install(ctx context.Context) {
+	chromedp.ListenTarget(ctx, t.handleEvent)
+}
+
+func (t *tabSet) handleEvent(ev interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *target.EventTargetCreated:
+		info := ev.TargetInfo
+		if info.Type != "page" || info.OpenerID == "" || t.find(info.OpenerID) == nil || t.find(info.TargetID) != nil {
+			return
+		}
+		tab := &tab{id: info.TargetID, info: TabInfo{Index: len(t.tabs), URL: info.URL, OpenedBy: t.action}, ready: make(chan struct{})}
+		tab.ctx, tab.cancel = chromedp.NewContext(t.browserCtx, chromedp.WithTargetID(tab.id))
+		t.tabs = append(t.tabs, tab)
+		// Attached right away rather than when switched to, so dialogs and
+		// request interception apply to what the tab loads before that.
+		// Commands cannot be run from the event listener.
+		go t.attach(tab)
+	case *target.EventTargetInfoChanged:
+		tab := t.find(ev.TargetInfo.TargetID)
+		if tab == nil || tab.info.URL == ev.TargetInfo.URL {
+			return
+		}
+		tab.info.URL = ev.TargetInfo.URL
+	case *target.EventTargetDestroyed:
+		t.markClosed(t.find(ev.TargetID))
+	default:
+		return
+	}
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// attach attaches to a new tab and sets it up with t.attached. A tab that
+// cannot be set up is closed, so it never runs without the task's request
+// interception.
+func (t *tabSet) attach(tab *tab) {
+	defer close(tab.ready)
+	// The first Run attaches to the tab and binds it to tab.ctx, so it must
+	// not use an action's deadline
+	err := t.run(tab.ctx)
+	if err == nil && t.attached != nil {
+		err = t.attached(tab.ctx)
+	}
+	if err == nil {
+		return
+	}
+	tab.err = err
+	if c := chromedp.FromContext(t.browserCtx); c != nil && c.Browser != nil {
+		_ = target.CloseTarget(tab.id).Do(cdp.WithExecutor(t.browserCtx, c.Browser))
+	}
+	t.mu.Lock()
+	t.markClosed(tab)
+	t.mu.Unlock()
+}
+
+// find returns the tracked tab with id, if any. t.mu must be held.
+func (t *tabSet) find(id target.ID) *tab {
+	for _, tab := range t.tabs {
+		if tab.id == id {
+			return tab
+		}
+	}
+	return nil
+}
+
+// markClosed records that a tab is gone. Actions return to the task's page
+// when the active tab closes, as a popup does once a sign-in completes.
+// t.mu must be held.
+func (t *tabSet) markClosed(tab *tab) {
+	if tab == nil || tab.info.Index == 0 || tab.info.Closed {
+		return
+	}
+	tab.info.Closed = true
+	if tab.cancel != nil {
+		tab.cancel()
+	}
+	if t.active == tab.info.Index {
+		t.active = 0
+	}
+}
+
+// beforeAction notes which action is running so new tabs can be attributed to it.
+func (t *tabSet) beforeAction(index int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.action = index
+}
+
+// on returns a context for running an action on the active tab that also
+// ends when ctx does. A nil tabSet runs on ctx.
+func (t *tabSet) on(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t == nil {
+		return ctx, func() {}
+	}
+	t.mu.Lock()
+	active, tabCtx := t.active, t.tabs[t.active].ctx
+	t.mu.Unlock()
+	if active == 0 {
+		return ctx, func() {}
+	}
+
+	runCtx, cancel := context.WithCancel(tabCtx)
+	stop := context.AfterFunc(ctx, cancel)
+	return runCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// match returns the open tab value selects, or nil if there is none yet.
+// An empty value is the newest open tab other than the active one, a number
+// is a tab index, and anything else is matched against tab URLs.
+// t.mu must be held.
+func (t *tabSet) match(value string) *tab {
+	if value == "" {
+		for i := len(t.tabs) - 1; i >= 0; i-- {
+			if tab := t.tabs[i]; !tab.info.Closed && i != t.active {
+				return tab
+			}
+		}
+		return nil
+	}
+	if index, err := strconv.Atoi(value); err == nil {
+		if index >= 0 && index < len(t.tabs) && !t.tabs[index].info.Closed {
+			return t.tabs[index]
+		}
+		return nil
+	}
+	for _, tab := range t.tabs {
+		if !tab.info.Closed && strings.Contains(tab.info.URL, value) {
+			return tab
+		}
+	}
+	return nil
+}
+
+// waitFor blocks until a tab matching value is open, since a popup usually
+// opens shortly after the click that triggers it.
+func (t *tabSet) waitFor(ctx context.Context, value string) (*tab, error) {
+	for {
+		t.mu.Lock()
+		tab, changed := t.match(value), t.changed
+		t.mu.Unlock()
+		if tab != nil {
+			return tab, nil
+		}
+		select {
+		case <-ctx.Done():
+			if value == "" {
+				return nil, fmt.Errorf("no new tab opened: %w", ctx.Err())
+			}
+			return nil, fmt.Errorf("no open tab matches %q: %w", value, ctx.Err())
+		case <-changed:
+		}
+	}
+}
+
+// switchAction waits for the tab Value selects and makes it the one
+// following actions run on.
+func (t *tabSet) switchAction(action taskstypes.Action, result *taskstypes.TaskResult) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		tab, err := t.waitFor(ctx, action.Value)
+		if err != nil {
+			return err
+		}
+		if t.checkURL != nil {
+			t.mu.Lock()
+			url, index := tab.info.URL, tab.info.Index
+			t.mu.Unlock()
+			if err := t.checkURL(ctx, url); err != nil {
+				return fmt.Errorf("cannot switch to tab %d: %w", index, err)
+			}
+		}
+
+		select {
+		case <-tab.ready:
+		case <-ctx.Done():
+			return fmt.Errorf("tab %d is not ready: %w", tab.info.Index, ctx.Err())
+		}
+		if tab.err != nil {
+			return fmt.Errorf("failed to set up tab %d: %w", tab.info.Index, tab.err)
+		}
+		if err := t.run(tab.ctx, page.BringToFront()); err != nil {
+			return fmt.Errorf("failed to switch to tab %d: %w", tab.info.Index, err)
+		}
+
+		t.mu.Lock()
+		if !tab.info.Closed {
+			t.active = tab.info.Index
+		}
+		t.mu.Unlock()
+		setCustomData(result, "tabs", t.report())
+		return nil
+	})
+}
+
+// closeAction closes the tab Value selects, or the active tab without a
+// Value. The task's own page cannot be closed.
+func (t *tabSet) closeAction(action taskstypes.Action, result *taskstypes.TaskResult) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		t.mu.Lock()
+		tab := t.tabs[t.active]
+		if action.Value != "" {
+			tab = t.match(action.Value)
+		}
+		t.mu.Unlock()
+		if tab == nil {
+			return fmt.Errorf("no open tab matches %q", action.Value)
+		}
+		if tab.info.Index == 0 {
+			return fmt.Errorf("close_tab cannot close the task's own page")
+		}
+
+		c := chromedp.FromContext(t.browserCtx)
+		if err := target.CloseTarget(tab.id).Do(cdp.WithExecutor(ctx, c.Browser)); err != nil {
+			return fmt.Errorf("failed to close tab %d: %w", tab.info.Index, err)
+		}
+		t.mu.Lock()
+		t.markClosed(tab)
+		t.mu.Unlock()
+		setCustomData(result, "tabs", t.report())
+		return nil
+	})
+}
+
+// closeAll closes the tabs the task opened, so they do not outlive it in a
+// shared session browser.
+func (t *tabSet) closeAll(ctx context.Context) {
+	t.mu.Lock()
+	var open []target.ID
+	for _, tab := range t.tabs[1:] {
+		if !tab.info.Closed {
+			open = append(open, tab.id)
+		}
+		t.markClosed(tab)
+	}
+	t.mu.Unlock()
+
+	if c := chromedp.FromContext(t.browserCtx); c != nil && c.Browser != nil {
+		for _, id := range open {
+			_ = target.CloseTarget(id).Do(cdp.WithExecutor(ctx, c.Browser))
+		}
+	}
+}
+
+// report lists the tabs seen so far. It is nil while only the task's page exists.
+func (t *tabSet) report() []TabInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.tabs) < 2 {
+		return nil
+	}
+	tabs := make([]TabInfo, len(t.tabs))
+	for i, tab := range t.tabs {
+		tabs[i] = tab.info
+	}
+	return tabs
+}
@@ -0,0 +1,127 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTabSet() *tabSet {
+	tabs := newTabSet(context.Background())
+	tabs.tabs[0].id = "main"
+	tabs.run = func(context.Context, ...chromedp.Action) error { return nil }
+	return tabs
+}
+
+func opened(id, opener target.ID, url string) *target.EventTargetCreated {
+	return &target.EventTargetCreated{TargetInfo: &target.Info{TargetID: id, OpenerID: opener, Type: "page", URL: url}}
+}
+
+func TestTabSet_TracksOpenedTabs(t *testing.T) {
+	tabs := testTabSet()
+	assert.Nil(t, tabs.report())
+
+	tabs.beforeAction(2)
+	tabs.handleEvent(opened("popup", "main", "about:blank"))
+	tabs.handleEvent(opened("unrelated", "", "https://other.example/"))
+	tabs.handleEvent(&target.EventTargetCreated{TargetInfo: &target.Info{TargetID: "worker", OpenerID: "main", Type: "service_worker"}})
+	tabs.handleEvent(&target.EventTargetInfoChanged{TargetInfo: &target.Info{TargetID: "popup", URL: "https://accounts.example/consent"}})
+	tabs.beforeAction(4)
+	tabs.handleEvent(opened("nested", "popup", "https://pay.example/"))
+
+	assert.Equal(t, []TabInfo{
+		{Index: 0},
+		{Index: 1, URL: "https://accounts.example/consent", OpenedBy: 2},
+		{Index: 2, URL: "https://pay.example/", OpenedBy: 4},
+	}, tabs.report())
+
+	tabs.mu.Lock()
+	assert.Equal(t, target.ID("nested"), tabs.match("").id)
+	assert.Equal(t, target.ID("popup"), tabs.match("1").id)
+	assert.Equal(t, target.ID("popup"), tabs.match("accounts.example").id)
+	assert.Nil(t, tabs.match("7"))
+	assert.Nil(t, tabs.match("checkout"))
+	tabs.mu.Unlock()
+
+	// A popup closing itself sends actions back to the task's page
+	tabs.active = 2
+	tabs.handleEvent(&target.EventTargetDestroyed{TargetID: "nested"})
+	assert.Equal(t, 0, tabs.active)
+	assert.True(t, tabs.report()[2].Closed)
+	tabs.mu.Lock()
+	assert.Equal(t, target.ID("popup"), tabs.match("").id)
+	assert.Nil(t, tabs.match("2"))
+	tabs.mu.Unlock()
+}
+
+func TestTabSet_WaitFor(t *testing.T) {
+	tabs := testTabSet()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		tabs.handleEvent(opened("popup", "main", "https://accounts.example/"))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tab, err := tabs.waitFor(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, target.ID("popup"), tab.id)
+
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = tabs.waitFor(short, "checkout")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTabSet_SetsUpTabsWhenOpened(t *testing.T) {
+	tabs := testTabSet()
+	setUp := make(chan struct{}, 2)
+	var fail atomic.Bool
+	tabs.attached = func(context.Context) error {
+		setUp <- struct{}{}
+		if fail.Load() {
+			return errors.New("fetch domain unavailable")
+		}
+		return nil
+	}
+
+	tabs.handleEvent(opened("popup", "main", "https://accounts.example/"))
+	select {
+	case <-setUp:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the tab was not set up before being switched to")
+	}
+	result := &taskstypes.TaskResult{}
+	require.NoError(t, tabs.switchAction(taskstypes.Action{Type: taskstypes.ActionSwitchTab}, result).Do(context.Background()))
+	assert.Equal(t, 1, tabs.active)
+
+	// A tab whose requests cannot be intercepted is closed rather than left unguarded
+	fail.Store(true)
+	tabs.handleEvent(opened("pay", "main", "https://pay.example/"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := tabs.switchAction(taskstypes.Action{Type: taskstypes.ActionSwitchTab, Value: "2"}, result).Do(ctx)
+	assert.Error(t, err)
+	assert.True(t, tabs.report()[2].Closed)
+	assert.Equal(t, 1, tabs.active)
+}
+
+func TestTabSet_OnMainTab(t *testing.T) {
+	ctx := context.Background()
+	var tabs *tabSet
+	got, done := tabs.on(ctx)
+	assert.Equal(t, ctx, got)
+	done()
+
+	got, done = testTabSet().on(ctx)
+	assert.Equal(t, ctx, got)
+	done()
+}
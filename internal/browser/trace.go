@@ -0,0 +1,637 @@
+package browser
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Screenshot sizes for screencast frames
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	cdpruntime "github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// A trace is a zip archive in the Playwright trace format
+// (https://playwright.dev/docs/trace-viewer), so a task run can be opened in
+// the Playwright Trace Viewer: "trace.trace" holds the actions, DOM snapshots,
+// screenshots, and console messages as JSON lines, "trace.network" the
+// requests, and "resources/" the screenshots and response bodies they refer to.
+const (
+	traceArtifact = "trace.zip"
+	// traceVersion is the trace format version written; the viewer upgrades older ones
+	traceVersion = 7
+	tracePageID  = "page@1"
+	traceFrameID = "frame@1"
+	// maxTraceSnapshots bounds the DOM snapshots and screenshots in one trace;
+	// later actions are still recorded, without them
+	maxTraceSnapshots      = 200
+	traceCaptureTimeout    = 5 * time.Second
+	traceScreenshotQuality = 50
+)
+
+// TraceInfo describes the trace saved for a task, in result.custom_data.trace.
+type TraceInfo struct {
+	Name      string `json:"name"`
+	Path      string `json:"path,omitempty"` // Set by the local backend
+	Size      int64  `json:"size"`
+	Actions   int    `json:"actions"`
+	Snapshots int    `json:"snapshots"`
+}
+
+// traceAPI names an action the way Playwright names the API call closest to it,
+// so the viewer shows its selector or URL. Other actions appear as "goscry.<type>".
+type traceAPI struct {
+	name, class, method string
+}
+
+var traceAPIs = map[taskstypes.ActionType]traceAPI{
+	taskstypes.ActionNavigate:    {"page.goto", "Frame", "goto"},
+	taskstypes.ActionClick:       {"locator.click", "Frame", "click"},
+	taskstypes.ActionInput:       {"locator.type", "Frame", "type"},
+	taskstypes.ActionSelect:      {"locator.selectOption", "Frame", "selectOption"},
+	taskstypes.ActionWaitVisible: {"locator.waitFor", "Frame", "waitForSelector"},
+	taskstypes.ActionWaitHidden:  {"locator.waitFor", "Frame", "waitForSelector"},
+	taskstypes.ActionWaitDelay:   {"page.waitForTimeout", "Frame", "waitForTimeout"},
+	taskstypes.ActionScreenshot:  {"page.screenshot", "Page", "screenshot"},
+	taskstypes.ActionRunScript:   {"page.evaluate", "Frame", "evaluateExpression"},
+}
+
+// Trace events, as Playwright's trace viewer reads them.
+type traceContextOptions struct {
+	Version       int      `json:"version"`
+	Type          string   `json:"type"`
+	Origin        string   `json:"origin"`
+	BrowserName   string   `json:"browserName"`
+	Platform      string   `json:"platform"`
+	WallTime      float64  `json:"wallTime"`
+	MonotonicTime float64  `json:"monotonicTime"`
+	SDKLanguage   string   `json:"sdkLanguage"`
+	Title         string   `json:"title"`
+	ContextID     string   `json:"contextId"`
+	Options       struct{} `json:"options"`
+}
+
+type traceBefore struct {
+	Type           string            `json:"type"`
+	CallID         string            `json:"callId"`
+	StartTime      float64           `json:"startTime"`
+	APIName        string            `json:"apiName"`
+	Class          string            `json:"class"`
+	Method         string            `json:"method"`
+	Params         map[string]string `json:"params"`
+	PageID         string            `json:"pageId"`
+	BeforeSnapshot string            `json:"beforeSnapshot,omitempty"`
+}
+
+type traceAfter struct {
+	Type          string      `json:"type"`
+	CallID        string      `json:"callId"`
+	EndTime       float64     `json:"endTime"`
+	AfterSnapshot string      `json:"afterSnapshot,omitempty"`
+	Error         *traceError `json:"error,omitempty"`
+}
+
+type traceError struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+type traceScreencastFrame struct {
+	Type      string  `json:"type"`
+	PageID    string  `json:"pageId"`
+	SHA1      string  `json:"sha1"` // Resource name
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Timestamp float64 `json:"timestamp"`
+}
+
+type traceConsole struct {
+	Type        string        `json:"type"`
+	Time        float64       `json:"time"`
+	PageID      string        `json:"pageId"`
+	MessageType string        `json:"messageType"`
+	Text        string        `json:"text"`
+	Args        []struct{}    `json:"args"`
+	Location    traceLocation `json:"location"`
+}
+
+type traceLocation struct {
+	URL          string `json:"url"`
+	LineNumber   int64  `json:"lineNumber"`
+	ColumnNumber int64  `json:"columnNumber"`
+}
+
+type tracePageError struct {
+	Type   string  `json:"type"`
+	Time   float64 `json:"time"`
+	Class  string  `json:"class"`
+	Method string  `json:"method"`
+	PageID string  `json:"pageId"`
+	Params struct {
+		Error struct {
+			Error traceError `json:"error"`
+		} `json:"error"`
+	} `json:"params"`
+}
+
+type traceFrameSnapshot struct {
+	Type     string `json:"type"`
+	Snapshot struct {
+		SnapshotName      string          `json:"snapshotName"`
+		CallID            string          `json:"callId"`
+		PageID            string          `json:"pageId"`
+		FrameID           string          `json:"frameId"`
+		FrameURL          string          `json:"frameUrl"`
+		Timestamp         float64         `json:"timestamp"`
+		WallTime          float64         `json:"wallTime"`
+		CollectionTime    float64         `json:"collectionTime"`
+		Doctype           string          `json:"doctype,omitempty"`
+		HTML              json.RawMessage `json:"html"`
+		ResourceOverrides []struct{}      `json:"resourceOverrides"`
+		Viewport          traceViewport   `json:"viewport"`
+		IsMainFrame       bool            `json:"isMainFrame"`
+	} `json:"snapshot"`
+}
+
+type traceViewport struct {
+	Width  int64 `json:"width"`
+	Height int64 `json:"height"`
+}
+
+type traceResourceSnapshot struct {
+	Type     string        `json:"type"`
+	Snapshot traceResource `json:"snapshot"`
+}
+
+// traceResource is a HAR entry with the fields Playwright adds to tie it to
+// the page and timeline.
+type traceResource struct {
+	harEntry
+	PageRef       string  `json:"pageref"`
+	FrameRef      string  `json:"_frameref"`
+	MonotonicTime float64 `json:"_monotonicTime"`
+}
+
+// tracePage is the page's state captured before an action: its DOM in
+// Playwright's snapshot form and a screenshot.
+type tracePage struct {
+	URL        string
+	Doctype    string
+	HTML       json.RawMessage
+	Nodes      int // Elements and text nodes in HTML, which later snapshots can refer to
+	Viewport   traceViewport
+	Screenshot []byte // JPEG
+	At         time.Time
+}
+
+// traceSnapshotScript serializes the document as nested
+// [tagName, attributes, ...children] arrays, with text nodes as strings.
+// Scripts and event handlers are dropped so the snapshot stays inert,
+// same-origin stylesheets are inlined, and form state is kept in the
+// attributes the viewer restores. Password values are left out.
+const traceSnapshotScript = `(() => {
+	const skip = new Set(['SCRIPT', 'NOSCRIPT', 'TEMPLATE']);
+	const visit = (node) => {
+		if (node.nodeType === Node.TEXT_NODE) return node.nodeValue;
+		if (node.nodeType !== Node.ELEMENT_NODE || skip.has(node.nodeName)) return null;
+		const name = node.nodeName;
+		if (name === 'LINK' && node.sheet && /stylesheet/i.test(node.rel)) {
+			try {
+				return ['STYLE', {}, Array.from(node.sheet.cssRules, (rule) => rule.cssText).join('\n')];
+			} catch (e) {} // Cross-origin sheets keep their link
+		}
+		const attrs = {};
+		for (const attr of node.attributes) {
+			if (!/^on/i.test(attr.name)) attrs[attr.name] = attr.value;
+		}
+		if (name === 'IFRAME' || name === 'FRAME') delete attrs.src;
+		if (name === 'INPUT' || name === 'TEXTAREA') {
+			attrs.__playwright_value_ = node.type === 'password' ? '' : String(node.value);
+			if (node.checked) attrs.__playwright_checked_ = 'true';
+		}
+		if (name === 'OPTION' && node.selected) attrs.__playwright_selected_ = 'true';
+		if (node.scrollTop) attrs.__playwright_scroll_top_ = String(node.scrollTop);
+		if (node.scrollLeft) attrs.__playwright_scroll_left_ = String(node.scrollLeft);
+		const out = [name, attrs];
+		for (const child of node.childNodes) {
+			const value = visit(child);
+			if (value !== null) out.push(value);
+		}
+		return out;
+	};
+	return {
+		url: location.href,
+		doctype: document.doctype ? document.doctype.name : '',
+		html: visit(document.documentElement),
+		width: window.innerWidth,
+		height: window.innerHeight,
+	};
+})()`
+
+// captureTracePage snapshots the page for a trace, within traceCaptureTimeout.
+func captureTracePage(ctx context.Context) (*tracePage, error) {
+	ctx, cancel := context.WithTimeout(ctx, traceCaptureTimeout)
+	defer cancel()
+
+	var snapshot struct {
+		URL     string          `json:"url"`
+		Doctype string          `json:"doctype"`
+		HTML    json.RawMessage `json:"html"`
+		Width   int64           `json:"width"`
+		Height  int64           `json:"height"`
+	}
+	var shot []byte
+	err := chromedp.Run(ctx,
+		chromedp.Evaluate(traceSnapshotScript, &snapshot),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			shot, err = page.CaptureScreenshot().
+				WithFormat(page.CaptureScreenshotFormatJpeg).
+				WithQuality(traceScreenshotQuality).
+				Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture trace snapshot: %w", err)
+	}
+	var html interface{}
+	if err := json.Unmarshal(snapshot.HTML, &html); err != nil {
+		return nil, fmt.Errorf("failed to decode trace snapshot: %w", err)
+	}
+	return &tracePage{
+		URL:        snapshot.URL,
+		Doctype:    snapshot.Doctype,
+		HTML:       snapshot.HTML,
+		Nodes:      traceNodeCount(html),
+		Viewport:   traceViewport{Width: snapshot.Width, Height: snapshot.Height},
+		Screenshot: shot,
+		At:         time.Now(),
+	}, nil
+}
+
+// traceNodeCount counts a snapshot's nodes in the order the viewer numbers
+// them for references: children before their parent.
+func traceNodeCount(node interface{}) int {
+	switch node := node.(type) {
+	case string:
+		return 1
+	case []interface{}:
+		if len(node) < 2 {
+			return 0
+		}
+		if _, ok := node[0].(string); !ok {
+			return 0 // A reference to an earlier snapshot
+		}
+		count := 1
+		for _, child := range node[2:] {
+			count += traceNodeCount(child)
+		}
+		return count
+	}
+	return 0
+}
+
+// traceRecorder collects a task's actions, page snapshots, and console
+// output for a Playwright trace. Snapshots follow the task's first tab.
+type traceRecorder struct {
+	mu        sync.Mutex
+	start     time.Time
+	title     string
+	events    []interface{}
+	resources map[string][]byte
+	calls     int
+	open      *traceCall
+	snapshots int
+	lastNodes int // Nodes in the last full snapshot, 0 if there is none to refer to
+}
+
+type traceCall struct {
+	id     string
+	action int
+}
+
+func newTraceRecorder(task *taskstypes.Task, start time.Time) *traceRecorder {
+	r := &traceRecorder{
+		start:     start,
+		title:     "GoScry task " + task.ID.String(),
+		resources: make(map[string][]byte),
+	}
+	r.events = append(r.events, traceContextOptions{
+		Version:     traceVersion,
+		Type:        "context-options",
+		Origin:      "library",
+		BrowserName: "chromium",
+		Platform:    runtime.GOOS,
+		WallTime:    float64(start.UnixMilli()),
+		SDKLanguage: "javascript",
+		Title:       r.title,
+		ContextID:   "context@" + task.ID.String(),
+	})
+	return r
+}
+
+// install records console messages and uncaught exceptions.
+func (r *traceRecorder) install(ctx context.Context) {
+	chromedp.ListenTarget(ctx, r.handleEvent)
+}
+
+func (r *traceRecorder) handleEvent(ev interface{}) {
+	switch ev := ev.(type) {
+	case *cdpruntime.EventConsoleAPICalled:
+		texts := make([]string, 0, len(ev.Args))
+		for _, arg := range ev.Args {
+			texts = append(texts, remoteObjectText(arg))
+		}
+		event := traceConsole{
+			Type:        "console",
+			PageID:      tracePageID,
+			MessageType: string(ev.Type),
+			Text:        truncateText(strings.Join(texts, " "), maxConsoleText),
+			Args:        []struct{}{},
+		}
+		if ev.StackTrace != nil && len(ev.StackTrace.CallFrames) > 0 {
+			frame := ev.StackTrace.CallFrames[0]
+			event.Location = traceLocation{URL: frame.URL, LineNumber: frame.LineNumber + 1, ColumnNumber: frame.ColumnNumber + 1}
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		event.Time = r.since(time.Now())
+		r.events = append(r.events, event)
+	case *cdpruntime.EventExceptionThrown:
+		details := ev.ExceptionDetails
+		if details == nil {
+			return
+		}
+		text := details.Text
+		if details.Exception != nil && details.Exception.Description != "" {
+			text = details.Exception.Description
+		}
+		event := tracePageError{Type: "event", Class: "BrowserContext", Method: "pageError", PageID: tracePageID}
+		event.Params.Error.Error = traceError{Name: "Error", Message: truncateText(text, maxConsoleText)}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		event.Time = r.since(time.Now())
+		r.events = append(r.events, event)
+	}
+}
+
+// since returns t on the trace's clock: milliseconds since the task started.
+func (r *traceRecorder) since(t time.Time) float64 {
+	return float64(t.Sub(r.start).Microseconds()) / 1000
+}
+
+// wantsSnapshot reports whether the next action should still be snapshotted.
+func (r *traceRecorder) wantsSnapshot() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshots < maxTraceSnapshots
+}
+
+// beforeAction ends the previous action and starts the next. The page,
+// captured between them, is the previous action's "after" and this one's
+// "before" snapshot; nil records the actions without one.
+func (r *traceRecorder) beforeAction(index int, action taskstypes.Action, pg *tracePage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.since(time.Now())
+	r.addScreencastFrame(pg)
+
+	reuse := false
+	if r.open != nil {
+		after := r.addSnapshot(r.open.id, "after@"+r.open.id, pg, false)
+		r.events = append(r.events, traceAfter{Type: "after", CallID: r.open.id, EndTime: now, AfterSnapshot: after})
+		reuse = after != ""
+	}
+
+	r.calls++
+	call := &traceCall{id: fmt.Sprintf("call@%d", r.calls), action: index}
+	api, ok := traceAPIs[action.Type]
+	if !ok {
+		api = traceAPI{name: "goscry." + string(action.Type), class: "GoScry", method: string(action.Type)}
+	}
+	// Typed values are left out, as they may be credentials
+	params := map[string]string{"action": fmt.Sprint(index)}
+	if action.Selector != "" {
+		params["selector"] = action.Selector
+	}
+	switch action.Type {
+	case taskstypes.ActionNavigate, taskstypes.ActionSecurity:
+		params["url"] = action.Value
+	case taskstypes.ActionWaitVisible:
+		params["state"] = "visible"
+	case taskstypes.ActionWaitHidden:
+		params["state"] = "hidden"
+	case taskstypes.ActionWaitDelay:
+		params["timeout"] = action.Value
+	}
+	r.events = append(r.events, traceBefore{
+		Type:           "before",
+		CallID:         call.id,
+		StartTime:      now,
+		APIName:        api.name,
+		Class:          api.class,
+		Method:         api.method,
+		Params:         params,
+		PageID:         tracePageID,
+		BeforeSnapshot: r.addSnapshot(call.id, "before@"+call.id, pg, reuse),
+	})
+	r.open = call
+}
+
+// finish ends the last action, with the task's error if it failed there.
+func (r *traceRecorder) finish(result *taskstypes.TaskResult, pg *tracePage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.open == nil {
+		return
+	}
+	r.addScreencastFrame(pg)
+	after := traceAfter{
+		Type:          "after",
+		CallID:        r.open.id,
+		EndTime:       r.since(time.Now()),
+		AfterSnapshot: r.addSnapshot(r.open.id, "after@"+r.open.id, pg, false),
+	}
+	if !result.Success && result.FailedAction != nil && result.FailedAction.Index == r.open.action {
+		name := "Error"
+		if result.ErrorCode != "" {
+			name = string(result.ErrorCode)
+		}
+		after.Error = &traceError{Name: name, Message: result.Error}
+	}
+	r.events = append(r.events, after)
+	r.open = nil
+}
+
+// addScreencastFrame adds the page's screenshot to the filmstrip.
+func (r *traceRecorder) addScreencastFrame(pg *tracePage) {
+	if pg == nil || len(pg.Screenshot) == 0 {
+		return
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(pg.Screenshot))
+	if err != nil {
+		return
+	}
+	name := fmt.Sprintf("%s-%d.jpeg", tracePageID, len(r.resources))
+	r.resources[name] = pg.Screenshot
+	r.events = append(r.events, traceScreencastFrame{
+		Type:      "screencast-frame",
+		PageID:    tracePageID,
+		SHA1:      name,
+		Width:     config.Width,
+		Height:    config.Height,
+		Timestamp: r.since(pg.At),
+	})
+}
+
+// addSnapshot adds a DOM snapshot and returns its name, or "" when there is
+// no page or the limit is reached. With reuse, it refers to the snapshot
+// added just before it instead of repeating the document.
+func (r *traceRecorder) addSnapshot(callID, name string, pg *tracePage, reuse bool) string {
+	if pg == nil || r.snapshots >= maxTraceSnapshots {
+		return ""
+	}
+	r.snapshots++
+	event := traceFrameSnapshot{Type: "frame-snapshot"}
+	s := &event.Snapshot
+	s.SnapshotName = name
+	s.CallID = callID
+	s.PageID = tracePageID
+	s.FrameID = traceFrameID
+	s.FrameURL = pg.URL
+	s.Timestamp = r.since(pg.At)
+	s.WallTime = float64(pg.At.UnixMilli())
+	s.Doctype = pg.Doctype
+	s.HTML = pg.HTML
+	s.ResourceOverrides = []struct{}{}
+	s.Viewport = pg.Viewport
+	s.IsMainFrame = true
+	if reuse && r.lastNodes > 0 {
+		// [[snapshots back, node index]]: the previous snapshot's root
+		s.HTML = json.RawMessage(fmt.Sprintf("[[1,%d]]", r.lastNodes-1))
+	} else {
+		r.lastNodes = pg.Nodes
+	}
+	r.events = append(r.events, event)
+	return name
+}
+
+// counts returns the actions and DOM snapshots recorded.
+func (r *traceRecorder) counts() (actions, snapshots int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls, r.snapshots
+}
+
+// archive builds the trace zip, with the network entries recorded alongside.
+func (r *traceRecorder) archive(entries []NetworkEntry) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var network bytes.Buffer
+	encoder := json.NewEncoder(&network)
+	for _, e := range entries {
+		resource := traceResource{
+			harEntry:      harEntryFor(e),
+			PageRef:       tracePageID,
+			FrameRef:      traceFrameID,
+			MonotonicTime: r.since(e.StartedAt),
+		}
+		if body := traceBody(e); body != nil {
+			sum := sha1.Sum(body)
+			name := hex.EncodeToString(sum[:])
+			r.resources[name] = body
+			resource.Response.Content.SHA1 = name
+			resource.Response.Content.Text, resource.Response.Content.Encoding = "", ""
+		}
+		if err := encoder.Encode(traceResourceSnapshot{Type: "resource-snapshot", Snapshot: resource}); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name string, data []byte, method uint16) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: r.start})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	var events bytes.Buffer
+	encoder = json.NewEncoder(&events)
+	for _, event := range r.events {
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+	}
+	if err := write("trace.trace", events.Bytes(), zip.Deflate); err != nil {
+		return nil, err
+	}
+	if err := write("trace.network", network.Bytes(), zip.Deflate); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(r.resources))
+	for name := range r.resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := write("resources/"+name, r.resources[name], zip.Deflate); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// traceBody returns a recorded response body for the viewer to render
+// snapshots with. Truncated bodies are left out, as they would render broken.
+func traceBody(e NetworkEntry) []byte {
+	if e.ResponseBody == "" || e.BodyTruncated {
+		return nil
+	}
+	if !e.BodyBase64 {
+		return []byte(e.ResponseBody)
+	}
+	body, err := base64.StdEncoding.DecodeString(e.ResponseBody)
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// saveTrace saves the task's trace in the artifact store as "trace.zip".
+func (m *Manager) saveTrace(ctx context.Context, task *taskstypes.Task, recorder *traceRecorder, entries []NetworkEntry) (TraceInfo, error) {
+	data, err := recorder.archive(entries)
+	if err != nil {
+		return TraceInfo{}, fmt.Errorf("failed to build trace: %w", err)
+	}
+	if size := int64(len(data)); m.overArtifactLimit(size) {
+		return TraceInfo{}, m.rejectArtifact("trace", size)
+	}
+	obj, err := m.artifactStore().Put(encryption.WithTenant(ctx, task.Tenant), task.ID, traceArtifact, bytes.NewReader(data))
+	if err != nil {
+		return TraceInfo{}, fmt.Errorf("failed to save trace: %w", err)
+	}
+	m.recordArtifact(obj.Size)
+	actions, snapshots := recorder.counts()
+	return TraceInfo{Name: obj.Name, Path: obj.Path, Size: obj.Size, Actions: actions, Snapshots: snapshots}, nil
+}
@@ -0,0 +1,181 @@
+package browser
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTracePage(t *testing.T, url, html string, at time.Time) *tracePage {
+	t.Helper()
+	var shot bytes.Buffer
+	require.NoError(t, jpeg.Encode(&shot, image.NewRGBA(image.Rect(0, 0, 8, 6)), nil))
+	var decoded interface{}
+	require.NoError(t, json.Unmarshal([]byte(html), &decoded))
+	return &tracePage{
+		URL: url, Doctype: "html", HTML: json.RawMessage(html), Nodes: traceNodeCount(decoded),
+		Viewport: traceViewport{Width: 800, Height: 600}, Screenshot: shot.Bytes(), At: at,
+	}
+}
+
+// readTrace unpacks a trace archive into its events and resource names.
+func readTrace(t *testing.T, data []byte) (events, network []map[string]interface{}, resources map[string][]byte) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	resources = make(map[string][]byte)
+	lines := func(r io.Reader) []map[string]interface{} {
+		var out []map[string]interface{}
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var event map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+			out = append(out, event)
+		}
+		return out
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		switch f.Name {
+		case "trace.trace":
+			events = lines(rc)
+		case "trace.network":
+			network = lines(rc)
+		default:
+			body, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			resources[f.Name] = body
+		}
+		rc.Close()
+	}
+	return events, network, resources
+}
+
+func TestTraceNodeCount(t *testing.T) {
+	var html interface{}
+	require.NoError(t, json.Unmarshal([]byte(`["HTML",{},["BODY",{"class":"x"},"hi",["P",{}]],[[1,3]]]`), &html))
+	assert.Equal(t, 4, traceNodeCount(html), "elements and text count; references to other snapshots don't")
+}
+
+func TestTraceRecorder(t *testing.T) {
+	start := time.Now()
+	task := &taskstypes.Task{ID: uuid.New()}
+	r := newTraceRecorder(task, start)
+
+	first := testTracePage(t, "https://example.com/", `["HTML",{},["BODY",{},"Home"]]`, start.Add(10*time.Millisecond))
+	r.beforeAction(0, taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://example.com/"}, first)
+	r.handleEvent(&runtime.EventConsoleAPICalled{
+		Type: runtime.APITypeWarning,
+		Args: []*runtime.RemoteObject{{Type: runtime.TypeString, Value: []byte(`"low stock"`)}, {Type: runtime.TypeNumber, Value: []byte("3")}},
+	})
+	second := testTracePage(t, "https://example.com/", `["HTML",{},["BODY",{},["A",{"id":"buy"},"Buy"]]]`, start.Add(20*time.Millisecond))
+	r.beforeAction(1, taskstypes.Action{Type: taskstypes.ActionInput, Selector: "#card", Value: "4111 1111 1111 1111"}, second)
+	r.handleEvent(&runtime.EventExceptionThrown{ExceptionDetails: &runtime.ExceptionDetails{Text: "Uncaught", Exception: &runtime.RemoteObject{Description: "TypeError: x is undefined"}}})
+	r.finish(&taskstypes.TaskResult{
+		Error: "no element matches #card", ErrorCode: taskstypes.ErrorSelectorNotFound,
+		FailedAction: &taskstypes.FailedAction{Index: 1},
+	}, nil)
+
+	data, err := r.archive([]NetworkEntry{
+		{URL: "https://example.com/app.css", Method: "GET", Status: 200, MIMEType: "text/css", StartedAt: start.Add(15 * time.Millisecond), ResponseBody: "a{}"},
+		{URL: "https://example.com/big.js", Method: "GET", Status: 200, StartedAt: start.Add(16 * time.Millisecond), ResponseBody: "var", BodyTruncated: true},
+	})
+	require.NoError(t, err)
+	events, network, resources := readTrace(t, data)
+
+	require.NotEmpty(t, events)
+	assert.Equal(t, "context-options", events[0]["type"])
+	assert.EqualValues(t, traceVersion, events[0]["version"])
+
+	byType := map[string][]map[string]interface{}{}
+	for _, event := range events {
+		byType[event["type"].(string)] = append(byType[event["type"].(string)], event)
+	}
+	before := byType["before"]
+	require.Len(t, before, 2)
+	assert.Equal(t, "page.goto", before[0]["apiName"])
+	assert.Equal(t, map[string]interface{}{"action": "0", "url": "https://example.com/"}, before[0]["params"])
+	assert.Equal(t, "locator.type", before[1]["apiName"])
+	assert.Equal(t, map[string]interface{}{"action": "1", "selector": "#card"}, before[1]["params"], "typed values are not traced")
+
+	after := byType["after"]
+	require.Len(t, after, 2)
+	assert.Nil(t, after[0]["error"])
+	assert.Equal(t, map[string]interface{}{"name": "SELECTOR_NOT_FOUND", "message": "no element matches #card"}, after[1]["error"])
+	assert.Nil(t, after[1]["afterSnapshot"], "no page was captured at the end")
+
+	// The first action's after snapshot is the full document, and the
+	// second action's before snapshot refers to it
+	snapshots := byType["frame-snapshot"]
+	require.Len(t, snapshots, 3)
+	names := []interface{}{}
+	for _, s := range snapshots {
+		names = append(names, s["snapshot"].(map[string]interface{})["snapshotName"])
+	}
+	assert.Equal(t, []interface{}{before[0]["beforeSnapshot"], after[0]["afterSnapshot"], before[1]["beforeSnapshot"]}, names)
+	assert.Equal(t, []interface{}{[]interface{}{1.0, 3.0}}, snapshots[2]["snapshot"].(map[string]interface{})["html"])
+
+	frames := byType["screencast-frame"]
+	require.Len(t, frames, 2)
+	assert.EqualValues(t, 8, frames[0]["width"])
+	assert.Contains(t, resources, "resources/"+frames[1]["sha1"].(string))
+
+	require.Len(t, byType["console"], 1)
+	assert.Equal(t, "low stock 3", byType["console"][0]["text"])
+	assert.Equal(t, "warning", byType["console"][0]["messageType"])
+	require.Len(t, byType["event"], 1)
+	assert.Equal(t, "pageError", byType["event"][0]["method"])
+
+	require.Len(t, network, 2)
+	css := network[0]["snapshot"].(map[string]interface{})
+	assert.Equal(t, "https://example.com/app.css", css["request"].(map[string]interface{})["url"])
+	assert.InDelta(t, 15, css["_monotonicTime"], 0.01)
+	sha := css["response"].(map[string]interface{})["content"].(map[string]interface{})["_sha1"].(string)
+	assert.Equal(t, []byte("a{}"), resources["resources/"+sha])
+	truncated := network[1]["snapshot"].(map[string]interface{})["response"].(map[string]interface{})["content"].(map[string]interface{})
+	assert.NotContains(t, truncated, "_sha1", "truncated bodies would render broken")
+
+	actions, snapshotCount := r.counts()
+	assert.Equal(t, 2, actions)
+	assert.Equal(t, 3, snapshotCount)
+}
+
+func TestTraceRecorder_SnapshotLimit(t *testing.T) {
+	start := time.Now()
+	r := newTraceRecorder(&taskstypes.Task{ID: uuid.New()}, start)
+	pg := testTracePage(t, "https://example.com/", `["HTML",{}]`, start)
+	for i := 0; r.wantsSnapshot(); i++ {
+		r.beforeAction(i, taskstypes.Action{Type: taskstypes.ActionWaitDelay, Value: "1ms"}, pg)
+	}
+	r.beforeAction(1000, taskstypes.Action{Type: taskstypes.ActionScroll}, pg)
+	r.finish(&taskstypes.TaskResult{Success: true}, pg)
+
+	actions, snapshots := r.counts()
+	assert.Equal(t, maxTraceSnapshots, snapshots)
+	assert.Greater(t, actions, maxTraceSnapshots/2)
+	data, err := r.archive(nil)
+	require.NoError(t, err)
+	events, _, _ := readTrace(t, data)
+	var last map[string]interface{}
+	for _, event := range events {
+		if event["type"] == "before" {
+			last = event
+		}
+	}
+	assert.Equal(t, "goscry.scroll", last["apiName"], "actions past the limit are still traced")
+	assert.Empty(t, last["beforeSnapshot"])
+}
@@ -0,0 +1,167 @@
+package browser
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// SetURLPolicy implements tasks.URLPolicyReceiver. Tasks check their
+// navigate actions against the policy before loading them, and every
+// request their pages make, including redirects, is paused and checked.
+func (m *Manager) SetURLPolicy(policy *taskstypes.URLPolicy) {
+	m.urlPolicy = policy
+}
+
+// urlGuard fails requests security.urlPolicy does not allow. Host lookups are
+// cached for the task, since a page requests the same hosts many times.
+type urlGuard struct {
+	policy *taskstypes.URLPolicy
+
+	mu         sync.Mutex
+	checked    map[string]error // By host, for any request
+	count      int
+	hosts      map[string]struct{}
+	blocked    []string
+	navigation error // Why a page load was refused since the last action started
+}
+
+// newURLGuard returns a guard for policy, or nil if it restricts nothing.
+func newURLGuard(policy *taskstypes.URLPolicy) *urlGuard {
+	if !policy.Active() {
+		return nil
+	}
+	return &urlGuard{policy: policy, checked: make(map[string]error), hosts: make(map[string]struct{})}
+}
+
+// allows reports whether a paused request may proceed, recording it if not.
+// A nil guard allows everything.
+func (g *urlGuard) allows(ctx context.Context, paused *fetch.EventRequestPaused) bool {
+	if g == nil {
+		return true
+	}
+	u, parseErr := url.Parse(paused.Request.URL)
+	if parseErr != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return true
+	}
+	// Frames are page loads too; a redirect is paused again as its own request.
+	// Only page loads are held to the allowed domains, which needs no lookup.
+	navigation := paused.ResourceType == network.ResourceTypeDocument
+	var err error
+	if navigation {
+		err = g.policy.Check(paused.Request.URL, true)
+	}
+	if err == nil {
+		host := strings.ToLower(u.Host)
+		g.mu.Lock()
+		var ok bool
+		err, ok = g.checked[host]
+		g.mu.Unlock()
+		if !ok {
+			err = g.policy.CheckResolved(ctx, paused.Request.URL, false)
+			g.mu.Lock()
+			g.checked[host] = err
+			g.mu.Unlock()
+		}
+	}
+	if err == nil {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.count++
+	g.hosts[u.Hostname()] = struct{}{}
+	if len(g.blocked) < maxBlockedURLsReported {
+		g.blocked = append(g.blocked, paused.Request.URL)
+	}
+	if navigation && g.navigation == nil {
+		g.navigation = err
+	}
+	return false
+}
+
+// install pauses every request on the browser context and fails those the
+// policy refuses. It is only used when neither the first-party filter nor
+// replay is, since they resolve paused requests themselves and consult the
+// guard first.
+func (g *urlGuard) install(ctx context.Context) error {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		go func() {
+			var action chromedp.Action = fetch.ContinueRequest(paused.RequestID)
+			if !g.allows(ctx, paused) {
+				action = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient)
+			}
+			// Errors here mean the target went away; nothing left to resolve
+			_ = chromedp.Run(ctx, action)
+		}()
+	})
+	return chromedp.Run(ctx, fetch.Enable())
+}
+
+// uninstall stops interception, restoring a named session's page to normal.
+func (g *urlGuard) uninstall(ctx context.Context) error {
+	return chromedp.Run(ctx, fetch.Disable())
+}
+
+// beforeAction forgets refused page loads of earlier actions.
+func (g *urlGuard) beforeAction() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.navigation = nil
+}
+
+// blockedNavigation returns why a page load was refused during the current
+// action, which is then the reason the action failed. A nil guard has none.
+func (g *urlGuard) blockedNavigation() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.navigation
+}
+
+func (g *urlGuard) report() BlockedRequests {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	hosts := make([]string, 0, len(g.hosts))
+	for host := range g.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return BlockedRequests{Count: g.count, Hosts: hosts, URLs: append([]string(nil), g.blocked...)}
+}
+
+// EnforceURLPolicy holds every request made on browserCtx to policy until
+// the context ends, for browsers started outside a task. A nil or empty
+// policy installs nothing.
+func EnforceURLPolicy(browserCtx context.Context, policy *taskstypes.URLPolicy) error {
+	guard := newURLGuard(policy)
+	if guard == nil {
+		return nil
+	}
+	return guard.install(browserCtx)
+}
+
+// checkNavigation refuses a URL an action is about to load when the policy
+// does not allow it, resolving its host first.
+func (m *Manager) checkNavigation(ctx context.Context, action taskstypes.Action) error {
+	target, ok := navigationURL(action)
+	if !ok || !m.urlPolicy.Active() {
+		return nil
+	}
+	return m.urlPolicy.CheckResolved(ctx, target, true)
+}
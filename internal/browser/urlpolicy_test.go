@@ -0,0 +1,79 @@
+package browser
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pausedResource(url string, resourceType network.ResourceType) *fetch.EventRequestPaused {
+	return &fetch.EventRequestPaused{Request: &network.Request{URL: url}, ResourceType: resourceType}
+}
+
+func TestURLGuard_Allows(t *testing.T) {
+	lookups := 0
+	guard := newURLGuard(&taskstypes.URLPolicy{
+		AllowedDomains:       []string{"*.example.com"},
+		BlockPrivateNetworks: true,
+		LookupHost: func(_ context.Context, host string) ([]netip.Addr, error) {
+			lookups++
+			if host == "rebind.example.com" {
+				return []netip.Addr{netip.MustParseAddr("10.0.0.7")}, nil
+			}
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+		},
+	})
+	require.NotNil(t, guard)
+	ctx := context.Background()
+
+	assert.True(t, guard.allows(ctx, pausedResource("https://www.example.com/", network.ResourceTypeDocument)))
+	assert.True(t, guard.allows(ctx, pausedResource("https://www.example.com/app.js", network.ResourceTypeScript)))
+	assert.True(t, guard.allows(ctx, pausedResource("https://cdn.other.net/lib.js", network.ResourceTypeScript)), "resources may come from other domains")
+	assert.True(t, guard.allows(ctx, pausedResource("data:image/png;base64,AAAA", network.ResourceTypeImage)))
+	assert.Nil(t, guard.blockedNavigation())
+
+	// A redirect to a metadata endpoint is paused as a new document request
+	assert.False(t, guard.allows(ctx, pausedResource("http://169.254.169.254/latest/meta-data/", network.ResourceTypeDocument)))
+	assert.ErrorIs(t, guard.blockedNavigation(), taskstypes.ErrURLBlocked)
+	assert.False(t, guard.allows(ctx, pausedResource("https://other.net/", network.ResourceTypeDocument)))
+	assert.False(t, guard.allows(ctx, pausedResource("https://rebind.example.com/", network.ResourceTypeXHR)))
+	assert.False(t, guard.allows(ctx, pausedResource("https://rebind.example.com/admin", network.ResourceTypeXHR)))
+
+	guard.beforeAction()
+	assert.Nil(t, guard.blockedNavigation(), "only the current action's page loads count")
+
+	report := guard.report()
+	assert.Equal(t, 4, report.Count)
+	assert.Equal(t, []string{"169.254.169.254", "other.net", "rebind.example.com"}, report.Hosts)
+	assert.Equal(t, 3, lookups, "hosts are resolved once per task")
+
+	var none *urlGuard
+	assert.True(t, none.allows(ctx, pausedResource("http://127.0.0.1/", network.ResourceTypeDocument)))
+	assert.Nil(t, newURLGuard(nil))
+	assert.Nil(t, newURLGuard(&taskstypes.URLPolicy{}))
+}
+
+func TestManager_CheckNavigation(t *testing.T) {
+	m := &Manager{}
+	navigate := taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "http://127.0.0.1:8080/"}
+	assert.NoError(t, m.checkNavigation(context.Background(), navigate), "no policy")
+
+	m.SetURLPolicy(&taskstypes.URLPolicy{BlockPrivateNetworks: true})
+	err := m.checkNavigation(context.Background(), navigate)
+	assert.ErrorIs(t, err, taskstypes.ErrURLBlocked)
+	assert.Equal(t, taskstypes.ErrorURLBlocked, classifyActionError(context.Background(), navigate, err))
+	assert.NoError(t, m.checkNavigation(context.Background(), taskstypes.Action{Type: taskstypes.ActionClick, Selector: "#go"}))
+
+	cloaking := taskstypes.Action{Type: taskstypes.ActionCloaking, Value: "http://127.0.0.1:8080/"}
+	assert.ErrorIs(t, m.checkNavigation(context.Background(), cloaking), taskstypes.ErrURLBlocked)
+	download := taskstypes.Action{Type: taskstypes.ActionDownload, Value: "http://127.0.0.1:8080/export.csv"}
+	assert.ErrorIs(t, m.checkNavigation(context.Background(), download), taskstypes.ErrURLBlocked)
+	download.Selector = "#export"
+	assert.NoError(t, m.checkNavigation(context.Background(), download), "a download with a selector loads nothing itself")
+}
@@ -0,0 +1,127 @@
+package browser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// referencePattern matches {{actions.<index>.result[.path]}} and
+// {{extracted.<name>[.path]}}. Other placeholders, such as
+// {{task.tfa_code}}, are left for whatever resolves them.
+var referencePattern = regexp.MustCompile(`\{\{\s*((?:actions\.\d+\.result|extracted\.[A-Za-z0-9_-]+)(?:\.[A-Za-z0-9_-]+)*)\s*\}\}`)
+
+// actionOutputs holds what earlier actions of a task produced, so later
+// actions can reference it in their Value or Selector.
+type actionOutputs struct {
+	mu      sync.Mutex
+	results map[int]interface{} // get_dom, run_script, and find_text output by action index
+}
+
+func newActionOutputs() *actionOutputs {
+	return &actionOutputs{results: make(map[int]interface{})}
+}
+
+func (o *actionOutputs) set(index int, value interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.results[index] = value
+}
+
+// resolve fills references in action's Value and Selector from earlier
+// action results and from result.custom_data.extracted.
+func (o *actionOutputs) resolve(action taskstypes.Action, result *taskstypes.TaskResult) (taskstypes.Action, error) {
+	var err error
+	if action.Value, err = o.interpolate(action.Value, result); err != nil {
+		return action, err
+	}
+	if action.Selector, err = o.interpolate(action.Selector, result); err != nil {
+		return action, err
+	}
+	return action, nil
+}
+
+func (o *actionOutputs) interpolate(s string, result *taskstypes.TaskResult) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var firstErr error
+	out := referencePattern.ReplaceAllStringFunc(s, func(match string) string {
+		path := strings.Split(referencePattern.FindStringSubmatch(match)[1], ".")
+		value, err := o.lookup(path, result)
+		if err == nil {
+			var text string
+			if text, err = referenceText(value); err == nil {
+				return text
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("cannot resolve %s: %w", match, err)
+		}
+		return match
+	})
+	return out, firstErr
+}
+
+// lookup finds the value a reference path names.
+func (o *actionOutputs) lookup(path []string, result *taskstypes.TaskResult) (interface{}, error) {
+	var value interface{}
+	switch path[0] {
+	case "actions":
+		index, _ := strconv.Atoi(path[1])
+		o.mu.Lock()
+		v, ok := o.results[index]
+		o.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("action %d has not produced a result", index)
+		}
+		value, path = v, path[3:]
+	case "extracted":
+		extracted, _ := result.CustomData["extracted"].(map[string]interface{})
+		v, ok := extracted[path[1]]
+		if !ok {
+			return nil, fmt.Errorf("no extract action has saved %q", path[1])
+		}
+		value, path = v, path[2:]
+	}
+	for _, key := range path {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("no field %q", key)
+			}
+			value = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("no element %q in a list of %d", key, len(node))
+			}
+			value = node[i]
+		default:
+			return nil, fmt.Errorf("cannot look up %q in a %T", key, value)
+		}
+	}
+	return value, nil
+}
+
+// referenceText formats a referenced value for use in a Value or Selector:
+// strings as they are, null as empty, and anything else as JSON.
+func referenceText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
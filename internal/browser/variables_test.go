@@ -0,0 +1,43 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionOutputs_Resolve(t *testing.T) {
+	outputs := newActionOutputs()
+	outputs.set(0, "ORD-1234")
+	outputs.set(1, map[string]interface{}{"total": 42.5, "items": []interface{}{"a", "b"}, "meta": nil})
+	result := &taskstypes.TaskResult{}
+	setCustomData(result, "extracted", map[string]interface{}{
+		"rows": []interface{}{map[string]interface{}{"id": "row-7"}},
+	})
+
+	action, err := outputs.resolve(taskstypes.Action{
+		Type:     taskstypes.ActionInput,
+		Selector: "#{{ extracted.rows.0.id }} input",
+		Value:    "Order {{actions.0.result}}: {{actions.1.result.total}} ({{actions.1.result.items}}){{actions.1.result.meta}}",
+	}, result)
+	require.NoError(t, err)
+	assert.Equal(t, "#row-7 input", action.Selector)
+	assert.Equal(t, `Order ORD-1234: 42.5 (["a","b"])`, action.Value)
+
+	action, err = outputs.resolve(taskstypes.Action{Type: taskstypes.ActionInput, Selector: "#otp", Value: "{{task.tfa_code}}"}, result)
+	require.NoError(t, err)
+	assert.Equal(t, "{{task.tfa_code}}", action.Value, "other placeholders are left alone")
+
+	for _, value := range []string{
+		"{{actions.5.result}}",
+		"{{actions.1.result.missing}}",
+		"{{actions.1.result.items.2}}",
+		"{{actions.0.result.length}}",
+		"{{extracted.prices}}",
+	} {
+		_, err := outputs.resolve(taskstypes.Action{Type: taskstypes.ActionInput, Value: value}, result)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,137 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+const (
+	defaultVirtualSettle     = 250 * time.Millisecond
+	defaultVirtualMaxScrolls = 200
+)
+
+// virtualScrollScript scrolls an element, or the page, down by step pixels
+// (80% of its height when step is zero), or back to the top when step is
+// negative, and returns how far it moved. Zero means the end was reached.
+const virtualScrollScript = `(function(sel, step) {
+	const el = sel ? document.querySelector(sel) : (document.scrollingElement || document.documentElement);
+	if (!el) throw new Error('no element matches ' + sel);
+	const before = el.scrollTop;
+	el.scrollTop = step < 0 ? 0 : before + (step || Math.max(1, Math.floor(el.clientHeight * 0.8)));
+	return el.scrollTop - before;
+})(%s, %d)`
+
+// virtualReport describes one scroll-through of a virtualized list, in
+// result.custom_data.virtual_lists under the extract's key.
+type virtualReport struct {
+	Rows       int  `json:"rows"`
+	Duplicates int  `json:"duplicates"` // Rows read again after scrolling and dropped
+	Scrolls    int  `json:"scrolls"`
+	ReachedEnd bool `json:"reached_end"` // False when max_scrolls or max_rows stopped the scroll first
+}
+
+// add folds in the report of an earlier page of a repeating extract.
+func (r *virtualReport) add(earlier virtualReport) {
+	r.Rows += earlier.Rows
+	r.Duplicates += earlier.Duplicates
+	r.Scrolls += earlier.Scrolls
+}
+
+// virtualScroll is a checked VirtualList.
+type virtualScroll struct {
+	taskstypes.VirtualList
+	settle time.Duration
+}
+
+func newVirtualScroll(action taskstypes.Action) (*virtualScroll, error) {
+	spec := *action.Virtual
+	if action.Selector == "" {
+		return nil, fmt.Errorf("extract with virtual requires a row selector")
+	}
+	if spec.Key != "" {
+		if _, ok := action.Fields[spec.Key]; !ok {
+			return nil, fmt.Errorf("virtual key %q is not one of the extract's fields", spec.Key)
+		}
+	}
+	if spec.Step < 0 || spec.MaxScrolls < 0 || spec.MaxRows < 0 {
+		return nil, fmt.Errorf("virtual step, max_scrolls, and max_rows cannot be negative")
+	}
+	if spec.MaxScrolls == 0 {
+		spec.MaxScrolls = defaultVirtualMaxScrolls
+	}
+	v := &virtualScroll{VirtualList: spec, settle: defaultVirtualSettle}
+	if spec.Settle != "" {
+		settle, err := time.ParseDuration(spec.Settle)
+		if err != nil || settle < 0 {
+			return nil, fmt.Errorf("invalid virtual settle duration '%s'", spec.Settle)
+		}
+		v.settle = settle
+	}
+	return v, nil
+}
+
+// harvest scrolls the list from the top to the end, running the extract
+// script after each scroll and keeping the rows not seen before, in the
+// order they were first seen.
+func (v *virtualScroll) harvest(ctx context.Context, script string) ([]interface{}, virtualReport, error) {
+	var report virtualReport
+	rows := []interface{}{}
+	seen := make(map[string]bool)
+	for scrolls := 0; ; scrolls++ {
+		var moved float64
+		step := v.Step
+		if scrolls == 0 {
+			step = -1
+		}
+		if err := chromedp.Evaluate(fmt.Sprintf(virtualScrollScript, jsString(v.Container), step), &moved).Do(ctx); err != nil {
+			return nil, report, err
+		}
+		if scrolls > 0 {
+			if moved == 0 {
+				report.ReachedEnd = true
+				break
+			}
+			report.Scrolls = scrolls
+		}
+		if err := chromedp.Sleep(v.settle).Do(ctx); err != nil {
+			return nil, report, err
+		}
+
+		var page []interface{}
+		if err := chromedp.Evaluate(script, &page).Do(ctx); err != nil {
+			return nil, report, err
+		}
+		for _, row := range page {
+			key := v.rowKey(row)
+			if seen[key] {
+				report.Duplicates++
+				continue
+			}
+			seen[key] = true
+			rows = append(rows, row)
+		}
+		if v.MaxRows > 0 && len(rows) >= v.MaxRows {
+			rows = rows[:v.MaxRows]
+			break
+		}
+		if scrolls >= v.MaxScrolls {
+			break
+		}
+	}
+	report.Rows = len(rows)
+	return rows, report, nil
+}
+
+// rowKey identifies a row by its key field, or by all of its fields.
+func (v *virtualScroll) rowKey(row interface{}) string {
+	if fields, ok := row.(map[string]interface{}); ok && v.Key != "" && fields[v.Key] != nil {
+		row = fields[v.Key]
+	}
+	key, _ := json.Marshal(row) // Decoded JSON always marshals
+	return string(key)
+}
@@ -8,35 +8,381 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Browser  BrowserConfig  `mapstructure:"browser"`
-	Log      LogConfig      `mapstructure:"log"`
-	Security SecurityConfig `mapstructure:"security"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Browser   BrowserConfig   `mapstructure:"browser"`
+	Log       LogConfig       `mapstructure:"log"`
+	Security  SecurityConfig  `mapstructure:"security"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Queue     QueueConfig     `mapstructure:"queue"`
+	Callback  CallbackConfig  `mapstructure:"callback"`
+	TwoFactor TwoFactorConfig `mapstructure:"twoFactor"`
+	Hooks     []HookConfig    `mapstructure:"hooks"`
+}
+
+// TwoFactorConfig lets tasks answer 2FA prompts without a client providing
+// the code.
+type TwoFactorConfig struct {
+	Email EmailInboxConfig `mapstructure:"email"`
+	SMS   SMSWebhookConfig `mapstructure:"sms"`
+}
+
+// SMSWebhookConfig enables POST /api/v1/2fa/sms, where an SMS provider
+// forwards inbound messages so their codes reach the tasks waiting for them.
+// The endpoint is served once either credential is set.
+type SMSWebhookConfig struct {
+	TwilioAuthToken string `mapstructure:"twilioAuthToken"` // Checks X-Twilio-Signature; set via GOSCRY_TWOFACTOR_SMS_TWILIOAUTHTOKEN
+	Token           string `mapstructure:"token"`           // Shared token in X-Hook-Token or ?token=, for other providers
+	PublicURL       string `mapstructure:"publicURL"`       // The URL configured at Twilio, when a proxy changes what the server sees
+	CodePattern     string `mapstructure:"codePattern"`     // Regular expression for the code; its first group if it has one
+}
+
+// EmailInboxConfig is an IMAP mailbox that receives 2FA codes. Tasks with
+// the "email" provider read their code from it instead of waiting for one
+// through the API.
+type EmailInboxConfig struct {
+	Addr         string        `mapstructure:"addr"`      // IMAP server as host:port, e.g. "imap.example.com:993"; empty turns polling off
+	Plaintext    bool          `mapstructure:"plaintext"` // Connect without TLS; only for servers on localhost
+	Username     string        `mapstructure:"username"`
+	Password     string        `mapstructure:"password"`     // Set via GOSCRY_TWOFACTOR_EMAIL_PASSWORD
+	Mailbox      string        `mapstructure:"mailbox"`      // Read-only; the default is INBOX
+	From         string        `mapstructure:"from"`         // Only messages whose sender contains this
+	CodePattern  string        `mapstructure:"codePattern"`  // Regular expression for the code; its first group if it has one
+	PollInterval time.Duration `mapstructure:"pollInterval"` // How often the mailbox is checked while a task waits
+	Lookback     time.Duration `mapstructure:"lookback"`     // Messages received this long before the prompt appeared still count
+}
+
+// HookConfig binds an inbound webhook, POST /hooks/<name>, to a template, so
+// other systems can start a task without an API client. Each request must
+// carry the hook's token, its signature, or both when both are set.
+type HookConfig struct {
+	Name      string            `mapstructure:"name"`
+	Template  string            `mapstructure:"template"`
+	Version   int               `mapstructure:"version"`   // Zero runs the latest version
+	Token     string            `mapstructure:"token"`     // Sent as X-Hook-Token or ?token=
+	Secret    string            `mapstructure:"secret"`    // Body signed as for callbacks, in X-GoScry-Signature-256 or X-Hub-Signature-256
+	Variables map[string]string `mapstructure:"variables"` // Template variable -> payload field, e.g. "lead.email"
+
+	ReferenceField string            `mapstructure:"referenceField"` // Payload field used as the task's reference_id
+	Tags           map[string]string `mapstructure:"tags"`           // Added to every task, with hook=<name>
+	CallbackURL    string            `mapstructure:"callbackURL"`
+	Session        string            `mapstructure:"session"`
+	Priority       string            `mapstructure:"priority"` // high, normal (default), or low
+}
+
+// CallbackConfig controls how task results and streamed events are delivered
+// to callback URLs.
+type CallbackConfig struct {
+	MaxAttempts    int           `mapstructure:"maxAttempts"`    // Including the first; 1 turns retries off
+	InitialBackoff time.Duration `mapstructure:"initialBackoff"` // Wait before the first retry, doubled for each one after
+	MaxBackoff     time.Duration `mapstructure:"maxBackoff"`     // Longest wait between attempts, including a receiver's Retry-After
+	Secret         string        `mapstructure:"secret"`         // Signs each delivery with HMAC-SHA256; empty sends them unsigned
+	DeadLetterFile string        `mapstructure:"deadLetterFile"` // Deliveries that failed every attempt are appended here as JSON lines; empty only logs them
+	Timeout        time.Duration `mapstructure:"timeout"`        // Per attempt; zero uses 10s
+	// ArtifactURLExpiry is how long the presigned artifact URLs in result
+	// callbacks stay valid. Only stores that can presign URLs, such as S3,
+	// list artifacts; zero leaves them out.
+	ArtifactURLExpiry time.Duration `mapstructure:"artifactURLExpiry"`
+	// MaxPayloadBytes caps the size of result callbacks. Larger ones drop
+	// their bulkiest fields until they fit; zero sends them whole.
+	MaxPayloadBytes int  `mapstructure:"maxPayloadBytes"`
+	OmitActions     bool `mapstructure:"omitActions"` // Leave the actions array out of result callbacks unless a task's callback_fields lists it
+
+	Auth    CallbackAuthConfig    `mapstructure:"auth"`
+	Breaker CallbackBreakerConfig `mapstructure:"breaker"`
+}
+
+// CallbackBreakerConfig stops sending to a callback endpoint that keeps
+// failing. Its deliveries are held and sent in order once it recovers.
+type CallbackBreakerConfig struct {
+	Failures    int           `mapstructure:"failures"`    // Failed attempts in a row that open the breaker; zero turns it off
+	Cooldown    time.Duration `mapstructure:"cooldown"`    // Wait before probing an open endpoint, doubled after each failed probe
+	MaxCooldown time.Duration `mapstructure:"maxCooldown"` // Longest wait between probes
+	MaxHeld     int           `mapstructure:"maxHeld"`     // Deliveries held per endpoint; the oldest go to the dead-letter log past this
+}
+
+// CallbackAuthConfig is the credential sent with callback deliveries to the
+// listed hosts. Callback URLs come from API callers, so it is never sent
+// anywhere else.
+type CallbackAuthConfig struct {
+	Type     string   `mapstructure:"type"`     // "none" (default), "basic", "bearer", or "header"
+	Hosts    []string `mapstructure:"hosts"`    // Callback hosts that receive it, exact or "*.example.com"; required with a credential
+	Username string   `mapstructure:"username"` // For basic
+	Password string   `mapstructure:"password"` // For basic
+	Token    string   `mapstructure:"token"`    // For bearer, sent as "Authorization: Bearer <token>"
+	Header   string   `mapstructure:"header"`   // For header, e.g. "X-Api-Key"
+	Value    string   `mapstructure:"value"`    // For header
+}
+
+// QueueConfig sizes the worker pool that runs tasks and the queue of tasks
+// waiting for a worker.
+type QueueConfig struct {
+	Workers int `mapstructure:"workers"` // Tasks run at once; zero uses browser.maxSessions
+	Size    int `mapstructure:"size"`    // Tasks that may wait for a worker before submissions are rejected
+
+	DomainHealth DomainHealthConfig `mapstructure:"domainHealth"`
+}
+
+// DomainHealthConfig scores target domains by how often their recent tasks
+// failed to load, and can hold back tasks for a domain that is failing hard.
+type DomainHealthConfig struct {
+	Window      time.Duration `mapstructure:"window"`      // How far back outcomes count
+	MinSamples  int           `mapstructure:"minSamples"`  // Outcomes in the window before a domain can trip
+	FailureRate float64       `mapstructure:"failureRate"` // Share of failures, 0 to 1, that trips a domain
+	Cooldown    time.Duration `mapstructure:"cooldown"`    // How long a tripped domain is held back before one task probes it
+	Action      string        `mapstructure:"action"`      // "none" (default) only scores, "delay" holds new tasks, "reject" refuses them
 }
 
 type ServerConfig struct {
-	Port         int           `mapstructure:"port"`
-	ReadTimeout  time.Duration `mapstructure:"readTimeout"`
-	WriteTimeout time.Duration `mapstructure:"writeTimeout"`
-	IdleTimeout  time.Duration `mapstructure:"idleTimeout"`
+	Port         int               `mapstructure:"port"`
+	ReadTimeout  time.Duration     `mapstructure:"readTimeout"`
+	WriteTimeout time.Duration     `mapstructure:"writeTimeout"`
+	IdleTimeout  time.Duration     `mapstructure:"idleTimeout"`
+	Compression  CompressionConfig `mapstructure:"compression"`
+	UI           UIConfig          `mapstructure:"ui"`
+
+	WebDriver WebDriverConfig `mapstructure:"webdriver"`
+	CDPProxy  CDPProxyConfig  `mapstructure:"cdpProxy"`
+}
+
+// UIConfig controls the embedded web UI served under /ui/.
+type UIConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// WebDriverConfig controls the W3C WebDriver endpoint served under /wd/hub.
+type WebDriverConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// CDPProxyConfig controls lending sessions to outside DevTools clients through /cdp/.
+type CDPProxyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// CompressionConfig controls gzip, deflate, and brotli compression of JSON and text responses.
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Level   int  `mapstructure:"level"` // 1 (fastest) to 9 (smallest); brotli uses the same value as its quality
 }
 
 type BrowserConfig struct {
-	ExecutablePath  string        `mapstructure:"executablePath"`
-	Headless        bool          `mapstructure:"headless"`
-	UserDataDir     string        `mapstructure:"userDataDir"`
-	ActionTimeout   time.Duration `mapstructure:"actionTimeout"`
-	ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout"`
-	MaxSessions     int           `mapstructure:"maxSessions"`
+	ExecutablePath  string         `mapstructure:"executablePath"`
+	Headless        bool           `mapstructure:"headless"`
+	UserDataDir     string         `mapstructure:"userDataDir"`
+	ActionTimeout   time.Duration  `mapstructure:"actionTimeout"`
+	ShutdownTimeout time.Duration  `mapstructure:"shutdownTimeout"`
+	MaxSessions     int            `mapstructure:"maxSessions"`
+	DownloadDir     string         `mapstructure:"downloadDir"` // Where downloads are written while in progress; finished ones move to the artifact store
+	MaxPages        int            `mapstructure:"maxPages"`    // Upper bound on pages a repeating action visits
+	ProfileDir      string         `mapstructure:"profileDir"`  // Where browser profile snapshots are kept
+	Proxy           ProxyConfig    `mapstructure:"proxy"`
+	Artifacts       ArtifactConfig `mapstructure:"artifacts"`
+	DOMWorkers      int            `mapstructure:"domWorkers"` // Goroutines for DOM simplification and AST building; zero uses GOMAXPROCS
+	DOMCache        DOMCacheConfig `mapstructure:"domCache"`
+	Pool            PoolConfig     `mapstructure:"pool"`
+
+	Chaos ChaosConfig `mapstructure:"chaos"`
+}
+
+// PoolConfig keeps browsers started ahead of tasks, so a task does not wait
+// for Chrome to launch. Each task gets its own isolated browser context in a
+// warm browser. Tasks on a session, with a profile, or with their own proxy
+// server start their own browser as before.
+type PoolConfig struct {
+	Size                int           `mapstructure:"size"`                // Idle browsers kept ready; zero turns the pool off
+	MaxAge              time.Duration `mapstructure:"maxAge"`              // Replace a browser this long after it started; zero keeps it
+	MaxUses             int           `mapstructure:"maxUses"`             // Replace a browser after this many tasks; zero keeps it
+	IdleTimeout         time.Duration `mapstructure:"idleTimeout"`         // Replace a browser left unused this long; zero keeps it
+	HealthCheckInterval time.Duration `mapstructure:"healthCheckInterval"` // How often idle browsers are checked and the pool refilled
+}
+
+// ChaosConfig injects faults into task execution so clients can test their
+// retry and timeout handling against a GoScry instance. Rates are the share
+// of eligible actions affected, from 0 to 1. Never enable it in production.
+type ChaosConfig struct {
+	Enabled               bool          `mapstructure:"enabled"`
+	Seed                  int64         `mapstructure:"seed"`                  // Makes the faults repeatable; zero seeds from the clock
+	LatencyRate           float64       `mapstructure:"latencyRate"`           // Actions delayed before they run
+	MaxLatency            time.Duration `mapstructure:"maxLatency"`            // Delays are picked up to this
+	NavigationFailureRate float64       `mapstructure:"navigationFailureRate"` // Navigations that fail as if the connection was reset
+	SlowSelectorRate      float64       `mapstructure:"slowSelectorRate"`      // Actions on a selector whose element shows up late
+	SelectorDelay         time.Duration `mapstructure:"selectorDelay"`         // How late it shows up
+}
+
+// DOMCacheConfig sizes the cache of simplified DOM output, keyed by a hash of
+// the page's HTML.
+type DOMCacheConfig struct {
+	MaxEntries int   `mapstructure:"maxEntries"` // Zero turns the cache off
+	MaxBytes   int64 `mapstructure:"maxBytes"`   // Total size of cached output
+}
+
+// ArtifactConfig limits how screenshots and downloads are captured and stored.
+type ArtifactConfig struct {
+	Backend       string   `mapstructure:"backend"`       // "local" or "s3"
+	Dir           string   `mapstructure:"dir"`           // Local backend root; each task's files go in a subdirectory named by its ID
+	S3            S3Config `mapstructure:"s3"`            // Used by the s3 backend
+	MaxBytes      int64    `mapstructure:"maxBytes"`      // Per artifact; larger ones fail the action
+	MaxConcurrent int      `mapstructure:"maxConcurrent"` // Captures in progress at once across tasks
+}
+
+// S3Config points the s3 artifact backend at a bucket on AWS S3 or any
+// S3-compatible service, such as MinIO or GCS with HMAC keys.
+type S3Config struct {
+	Endpoint        string `mapstructure:"endpoint"` // e.g. "http://minio:9000"; empty uses AWS in Region
+	Region          string `mapstructure:"region"`   // Signing region; "auto" for GCS
+	Bucket          string `mapstructure:"bucket"`
+	Prefix          string `mapstructure:"prefix"`          // Prepended to every key, e.g. "goscry/"
+	AccessKeyID     string `mapstructure:"accessKeyId"`     // Falls back to AWS_ACCESS_KEY_ID
+	SecretAccessKey string `mapstructure:"secretAccessKey"` // Falls back to AWS_SECRET_ACCESS_KEY
+	SessionToken    string `mapstructure:"sessionToken"`    // Temporary credentials; falls back to AWS_SESSION_TOKEN
+	PathStyle       bool   `mapstructure:"pathStyle"`       // Address the bucket as <endpoint>/<bucket>, as MinIO needs
+}
+
+// ProxyConfig routes browser traffic through an HTTP(S) or SOCKS proxy.
+type ProxyConfig struct {
+	Server     string `mapstructure:"server"`   // e.g. "http://proxy.internal:3128"; empty connects directly
+	Username   string `mapstructure:"username"` // Answered on proxy auth challenges (HTTP proxies only)
+	Password   string `mapstructure:"password"`
+	BypassList string `mapstructure:"bypassList"` // Hosts that skip the proxy, e.g. "localhost;*.internal"
 }
 
 type LogConfig struct {
-	Level string `mapstructure:"level"` // debug, info, warn, error
+	Level  string `mapstructure:"level"`  // debug, info, warn, error
+	Format string `mapstructure:"format"` // text (default) or json
+}
+
+// StorageConfig selects where task history is persisted.
+type StorageConfig struct {
+	Driver  string        `mapstructure:"driver"` // memory, sqlite
+	Path    string        `mapstructure:"path"`   // Database file for the sqlite driver
+	Archive ArchiveConfig `mapstructure:"archive"`
+}
+
+// ArchiveConfig moves the Data of finished tasks' results out of the task
+// store once they reach a given age, keeping the store and responses small.
+type ArchiveConfig struct {
+	After time.Duration `mapstructure:"after"` // Age after completion; zero keeps results in the store
+	Dir   string        `mapstructure:"dir"`   // Where archived result data is written
 }
 
 type SecurityConfig struct {
-	AllowedOrigins []string `mapstructure:"allowedOrigins"`
-	ApiKey         string   `mapstructure:"apiKey"` // Example, use more robust auth
+	AllowedOrigins []string         `mapstructure:"allowedOrigins"`
+	AllowedCIDRs   []string         `mapstructure:"allowedCIDRs"`   // Empty allows any client address
+	DeniedCIDRs    []string         `mapstructure:"deniedCIDRs"`    // Checked before AllowedCIDRs
+	TrustedProxies []string         `mapstructure:"trustedProxies"` // Peers whose X-Forwarded-For/X-Real-IP are honored
+	ApiKey         string           `mapstructure:"apiKey"`         // Legacy single key, treated as an admin key
+	ApiKeys        []APIKeyConfig   `mapstructure:"apiKeys"`
+	JWT            JWTConfig        `mapstructure:"jwt"`
+	HMAC           HMACConfig       `mapstructure:"hmac"`
+	Encryption     EncryptionConfig `mapstructure:"encryption"`
+	CredentialKey  string           `mapstructure:"credentialKey"` // RSA private key PEM for encrypted task credentials; generated per process if empty
+
+	CredentialSources CredentialSourcesConfig `mapstructure:"credentialSources"`
+
+	AllowedURLSchemes []string        `mapstructure:"allowedURLSchemes"` // Schemes navigate actions may use
+	URLPolicy         URLPolicyConfig `mapstructure:"urlPolicy"`
+
+	RateLimit RateLimitConfig `mapstructure:"rateLimit"`
+}
+
+// CredentialSourcesConfig configures where credential references such as
+// "vault:myapp/login" are resolved. A source is only used once configured.
+type CredentialSourcesConfig struct {
+	Vault VaultConfig             `mapstructure:"vault"`
+	AWS   AWSSecretsManagerConfig `mapstructure:"aws"`
+	File  string                  `mapstructure:"file"` // JSON file of named encrypted credentials, for "file:<name>"
+}
+
+// VaultConfig reads "vault:<path>" from a HashiCorp Vault KV version 2 engine.
+type VaultConfig struct {
+	Address   string `mapstructure:"address"`   // e.g. "https://vault.internal:8200"; empty disables Vault
+	Token     string `mapstructure:"token"`     // Falls back to VAULT_TOKEN
+	Mount     string `mapstructure:"mount"`     // KV engine mount path
+	Namespace string `mapstructure:"namespace"` // Vault Enterprise namespace
+}
+
+// AWSSecretsManagerConfig reads "aws:<secret id>" from AWS Secrets Manager.
+type AWSSecretsManagerConfig struct {
+	Region          string `mapstructure:"region"`          // Empty disables Secrets Manager
+	Endpoint        string `mapstructure:"endpoint"`        // Empty uses the region's public endpoint
+	AccessKeyID     string `mapstructure:"accessKeyId"`     // Falls back to AWS_ACCESS_KEY_ID
+	SecretAccessKey string `mapstructure:"secretAccessKey"` // Falls back to AWS_SECRET_ACCESS_KEY
+	SessionToken    string `mapstructure:"sessionToken"`    // Temporary credentials; falls back to AWS_SESSION_TOKEN
+}
+
+// URLPolicyConfig limits the hosts tasks may reach. It is enforced when
+// tasks are submitted, before each navigation, and on every request the
+// browser makes, including redirects.
+type URLPolicyConfig struct {
+	AllowedDomains       []string `mapstructure:"allowedDomains"`       // Pages may only be loaded from these; "*.example.com" includes subdomains. Empty allows any
+	BlockedDomains       []string `mapstructure:"blockedDomains"`       // Never reached, by pages or the resources they load
+	BlockPrivateNetworks bool     `mapstructure:"blockPrivateNetworks"` // Loopback, private, link-local, and cloud metadata addresses
+}
+
+// RateLimitConfig throttles API and WebDriver callers before their requests
+// reach the browser pool. Limits read like "10/s", "600/m", or "1000/h";
+// empty is unlimited, and a zero burst allows one second's worth at once.
+type RateLimitConfig struct {
+	PerIP          string `mapstructure:"perIP"` // Every client address, checked before credentials
+	PerIPBurst     int    `mapstructure:"perIPBurst"`
+	PerClient      string `mapstructure:"perClient"` // Each JWT subject, signing client, and API key without its own rateLimit
+	PerClientBurst int    `mapstructure:"perClientBurst"`
+}
+
+// APIKeyConfig is a named API key with a role (viewer, submitter, admin) and
+// an optional rate limit.
+type APIKeyConfig struct {
+	Name string `mapstructure:"name"`
+	Key  string `mapstructure:"key"`
+	Role string `mapstructure:"role"`
+	// Tenant names the security.encryption key the key's tasks are sealed
+	// with. Empty uses the key's name; tenants without a key of their own
+	// use the default key.
+	Tenant string `mapstructure:"tenant"`
+	// CredentialRefs are the credentials_ref prefixes the key may use, such
+	// as "vault:team-a/"; "*" allows any. Empty allows none.
+	CredentialRefs []string `mapstructure:"credentialRefs"`
+
+	RateLimit string `mapstructure:"rateLimit"` // e.g. "10/s", "600/m", or "1000/h"; empty is unlimited
+	Burst     int    `mapstructure:"burst"`     // Requests allowed at once; zero uses one second's worth, at least 1
+}
+
+// HMACConfig enables signed requests for server-to-server callers that cannot
+// send static keys. Each client signs the timestamp, method, path, and body hash.
+type HMACConfig struct {
+	Clients      []HMACClientConfig `mapstructure:"clients"`
+	ReplayWindow time.Duration      `mapstructure:"replayWindow"` // Max clock skew; signatures are single-use within it
+}
+
+// HMACClientConfig is a signing key ID with its shared secret and role.
+type HMACClientConfig struct {
+	KeyID          string   `mapstructure:"keyId"`
+	Secret         string   `mapstructure:"secret"`
+	Role           string   `mapstructure:"role"`
+	Tenant         string   `mapstructure:"tenant"`         // Encryption tenant of the client's tasks; empty uses the key ID
+	CredentialRefs []string `mapstructure:"credentialRefs"` // credentials_ref prefixes the client may use; "*" allows any, empty none
+}
+
+// JWTConfig enables HS256 bearer tokens whose role claim maps to an API role.
+type JWTConfig struct {
+	Secret      string `mapstructure:"secret"`      // Empty disables JWT authentication
+	RoleClaim   string `mapstructure:"roleClaim"`   // Defaults to "role"
+	TenantClaim string `mapstructure:"tenantClaim"` // Encryption tenant of the caller's tasks; defaults to "tenant", then the subject
+	// CredentialRefs maps a role to the credentials_ref prefixes its tokens
+	// may use; "*" allows any. Roles not listed may use none.
+	CredentialRefs map[string][]string `mapstructure:"credentialRefs"`
+}
+
+// EncryptionConfig controls envelope encryption of task results and artifacts at rest.
+type EncryptionConfig struct {
+	Enabled bool              `mapstructure:"enabled"`
+	Keys    map[string]string `mapstructure:"keys"` // tenant -> base64-encoded 32-byte key; "default" is the fallback
+	// RetiredKeys are earlier keys of each tenant, kept to open data sealed
+	// before the key was replaced. Nothing new is sealed with them.
+	RetiredKeys map[string][]string `mapstructure:"retiredKeys"`
+	// RequireTenantKeys refuses to seal data for a tenant without its own
+	// key instead of using the default key.
+	RequireTenantKeys bool `mapstructure:"requireTenantKeys"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -46,6 +392,11 @@ func LoadConfig(path string) (*Config, error) {
 	v.SetDefault("server.readTimeout", "15s")
 	v.SetDefault("server.writeTimeout", "15s")
 	v.SetDefault("server.idleTimeout", "60s")
+	v.SetDefault("server.compression.enabled", true)
+	v.SetDefault("server.compression.level", 5)
+	v.SetDefault("server.ui.enabled", false)
+	v.SetDefault("server.webdriver.enabled", false)
+	v.SetDefault("server.cdpProxy.enabled", false)
 
 	v.SetDefault("browser.executablePath", "") // Attempt auto-detect if empty
 	v.SetDefault("browser.headless", true)
@@ -53,11 +404,114 @@ func LoadConfig(path string) (*Config, error) {
 	v.SetDefault("browser.actionTimeout", "30s")
 	v.SetDefault("browser.shutdownTimeout", "10s")
 	v.SetDefault("browser.maxSessions", 10) // Max concurrent browser sessions
+	v.SetDefault("browser.downloadDir", "downloads")
+	v.SetDefault("browser.profileDir", "profiles")
+	v.SetDefault("browser.maxPages", 20)
+	v.SetDefault("browser.proxy.server", "") // Empty connects directly
+	v.SetDefault("browser.proxy.username", "")
+	v.SetDefault("browser.proxy.password", "")
+	v.SetDefault("browser.proxy.bypassList", "")
+	v.SetDefault("browser.artifacts.backend", "local")
+	v.SetDefault("browser.artifacts.dir", "artifacts")
+	v.SetDefault("browser.artifacts.s3.endpoint", "")
+	v.SetDefault("browser.artifacts.s3.region", "us-east-1")
+	v.SetDefault("browser.artifacts.s3.bucket", "")
+	v.SetDefault("browser.artifacts.s3.prefix", "")
+	v.SetDefault("browser.artifacts.s3.accessKeyId", "")
+	v.SetDefault("browser.artifacts.s3.secretAccessKey", "")
+	v.SetDefault("browser.artifacts.s3.sessionToken", "")
+	v.SetDefault("browser.artifacts.s3.pathStyle", false)
+	v.SetDefault("browser.artifacts.maxBytes", 50<<20)
+	v.SetDefault("browser.artifacts.maxConcurrent", 4)
+	v.SetDefault("browser.domWorkers", 0)
+	v.SetDefault("browser.domCache.maxEntries", 256)
+	v.SetDefault("browser.domCache.maxBytes", 64<<20)
+	v.SetDefault("browser.pool.size", 0)
+	v.SetDefault("browser.pool.maxAge", "30m")
+	v.SetDefault("browser.pool.maxUses", 100)
+	v.SetDefault("browser.pool.idleTimeout", "10m")
+	v.SetDefault("browser.pool.healthCheckInterval", "30s")
+	v.SetDefault("browser.chaos.enabled", false)
+	v.SetDefault("browser.chaos.seed", 0)
+	v.SetDefault("browser.chaos.latencyRate", 0.0)
+	v.SetDefault("browser.chaos.maxLatency", "2s")
+	v.SetDefault("browser.chaos.navigationFailureRate", 0.0)
+	v.SetDefault("browser.chaos.slowSelectorRate", 0.0)
+	v.SetDefault("browser.chaos.selectorDelay", "5s")
+
+	v.SetDefault("callback.maxAttempts", 5)
+	v.SetDefault("callback.initialBackoff", "1s")
+	v.SetDefault("callback.maxBackoff", "1m")
+	v.SetDefault("callback.secret", "") // Set via GOSCRY_CALLBACK_SECRET
+	v.SetDefault("callback.deadLetterFile", "")
+	v.SetDefault("callback.timeout", "10s")
+	v.SetDefault("callback.artifactURLExpiry", "1h")
+	v.SetDefault("callback.maxPayloadBytes", 0)
+	v.SetDefault("callback.omitActions", false)
+	v.SetDefault("callback.auth.type", "none")
+	v.SetDefault("callback.auth.hosts", []string{})
+	v.SetDefault("callback.auth.username", "")
+	v.SetDefault("callback.auth.password", "") // Set via GOSCRY_CALLBACK_AUTH_PASSWORD
+	v.SetDefault("callback.auth.token", "")    // Set via GOSCRY_CALLBACK_AUTH_TOKEN
+	v.SetDefault("callback.auth.header", "")
+	v.SetDefault("callback.auth.value", "") // Set via GOSCRY_CALLBACK_AUTH_VALUE
+	v.SetDefault("callback.breaker.failures", 5)
+	v.SetDefault("callback.breaker.cooldown", "30s")
+	v.SetDefault("callback.breaker.maxCooldown", "10m")
+	v.SetDefault("callback.breaker.maxHeld", 1000)
+	v.SetDefault("twoFactor.email.addr", "")
+	v.SetDefault("twoFactor.email.plaintext", false)
+	v.SetDefault("twoFactor.email.username", "")
+	v.SetDefault("twoFactor.email.password", "") // Set via GOSCRY_TWOFACTOR_EMAIL_PASSWORD
+	v.SetDefault("twoFactor.email.mailbox", "INBOX")
+	v.SetDefault("twoFactor.email.from", "")
+	v.SetDefault("twoFactor.email.codePattern", `\b(\d{6})\b`)
+	v.SetDefault("twoFactor.email.pollInterval", "5s")
+	v.SetDefault("twoFactor.email.lookback", "2m")
+	v.SetDefault("twoFactor.sms.twilioAuthToken", "") // Set via GOSCRY_TWOFACTOR_SMS_TWILIOAUTHTOKEN
+	v.SetDefault("twoFactor.sms.token", "")
+	v.SetDefault("twoFactor.sms.publicURL", "")
+	v.SetDefault("twoFactor.sms.codePattern", `\b(\d{4,8})\b`)
 
 	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", "text")
 
 	v.SetDefault("security.allowedOrigins", []string{"*"}) // Be more specific in production
 	v.SetDefault("security.apiKey", "")                    // Should be set via env or secure means
+	v.SetDefault("security.jwt.secret", "")
+	v.SetDefault("security.jwt.roleClaim", "role")
+	v.SetDefault("security.jwt.tenantClaim", "tenant")
+	v.SetDefault("security.hmac.replayWindow", "5m")
+	v.SetDefault("security.credentialKey", "")
+	v.SetDefault("security.credentialSources.vault.address", "")
+	v.SetDefault("security.credentialSources.vault.token", "")
+	v.SetDefault("security.credentialSources.vault.mount", "secret")
+	v.SetDefault("security.credentialSources.vault.namespace", "")
+	v.SetDefault("security.credentialSources.aws.region", "")
+	v.SetDefault("security.credentialSources.aws.endpoint", "")
+	v.SetDefault("security.credentialSources.file", "")
+	v.SetDefault("security.allowedURLSchemes", []string{"http", "https"})
+	v.SetDefault("security.urlPolicy.allowedDomains", []string{})
+	v.SetDefault("security.urlPolicy.blockedDomains", []string{})
+	v.SetDefault("security.urlPolicy.blockPrivateNetworks", true)
+	v.SetDefault("security.rateLimit.perIP", "")
+	v.SetDefault("security.rateLimit.perIPBurst", 0)
+	v.SetDefault("security.rateLimit.perClient", "")
+	v.SetDefault("security.rateLimit.perClientBurst", 0)
+	v.SetDefault("security.encryption.enabled", false)
+
+	v.SetDefault("storage.driver", "memory") // memory or sqlite
+	v.SetDefault("storage.path", "goscry.db")
+	v.SetDefault("storage.archive.after", "0s") // Results stay in the store
+	v.SetDefault("storage.archive.dir", "artifacts/results")
+
+	v.SetDefault("queue.workers", 0) // Same as browser.maxSessions
+	v.SetDefault("queue.size", 1000)
+	v.SetDefault("queue.domainHealth.window", "5m")
+	v.SetDefault("queue.domainHealth.minSamples", 5)
+	v.SetDefault("queue.domainHealth.failureRate", 0.5)
+	v.SetDefault("queue.domainHealth.cooldown", "1m")
+	v.SetDefault("queue.domainHealth.action", "none")
 
 	if path != "" {
 		v.SetConfigFile(path)
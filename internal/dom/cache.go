@@ -0,0 +1,132 @@
+package dom
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// CacheStats are process-lifetime counters for a Cache.
+type CacheStats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// cacheKey identifies an output by the kind of processing and a SHA-256 of
+// the raw HTML it was produced from.
+type cacheKey struct {
+	kind string
+	sum  [sha256.Size]byte
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	value string
+}
+
+// Cache keeps the output of DOM post-processing keyed by a hash of the raw
+// HTML, so repeated conversions of identical pages, common in monitors, skip
+// the CPU-heavy pass. The least recently used outputs are evicted once
+// maxEntries or maxBytes is exceeded. A nil *Cache caches nothing.
+type Cache struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	order   *list.List // Most recently used first
+	stats   CacheStats
+}
+
+// NewCache returns a cache holding up to maxEntries outputs and maxBytes of
+// output in total; zero maxBytes only limits the number of entries.
+func NewCache(maxEntries int, maxBytes int64) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		entries:    make(map[cacheKey]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func newCacheKey(kind, raw string) cacheKey {
+	return cacheKey{kind: kind, sum: sha256.Sum256([]byte(raw))}
+}
+
+func (c *Cache) get(key cacheKey) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return "", false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).value, true
+}
+
+func (c *Cache) put(key cacheKey, value string) {
+	if c == nil || c.maxEntries <= 0 {
+		return
+	}
+	size := int64(len(value))
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		// Identical input gives identical output; just mark it used
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+	c.stats.Entries++
+	c.stats.Bytes += size
+	for c.stats.Entries > c.maxEntries || (c.maxBytes > 0 && c.stats.Bytes > c.maxBytes) {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.stats.Entries--
+		c.stats.Bytes -= int64(len(entry.value))
+		c.stats.Evictions++
+	}
+}
+
+// Stats returns the cache's size and hit counters.
+func (c *Cache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+var (
+	defaultCacheMu sync.RWMutex
+	defaultCache   *Cache
+)
+
+// DefaultCache returns the cache DOM actions use, or nil if caching is off.
+func DefaultCache() *Cache {
+	defaultCacheMu.RLock()
+	defer defaultCacheMu.RUnlock()
+	return defaultCache
+}
+
+// SetDefaultCache replaces the shared cache, for example to apply
+// browser.domCache at startup; nil turns caching off.
+func SetDefaultCache(c *Cache) {
+	defaultCacheMu.Lock()
+	defaultCache = c
+	defaultCacheMu.Unlock()
+}
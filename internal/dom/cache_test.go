@@ -0,0 +1,60 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCache_HitsAndEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache(2, 0)
+	a, b, d := newCacheKey("simplified", "<p>a</p>"), newCacheKey("simplified", "<p>b</p>"), newCacheKey("simplified", "<p>d</p>")
+	c.put(a, "a")
+	c.put(b, "b")
+	if v, ok := c.get(a); !ok || v != "a" {
+		t.Fatalf("get(a) = %q, %v; want a, true", v, ok)
+	}
+	c.put(d, "d") // Evicts b, which was used longest ago
+	if _, ok := c.get(b); ok {
+		t.Fatalf("b should have been evicted")
+	}
+	if _, ok := c.get(a); !ok {
+		t.Fatalf("a should still be cached")
+	}
+	if _, ok := c.get(newCacheKey("ast", "<p>a</p>")); ok {
+		t.Fatalf("outputs of other kinds must not share entries")
+	}
+
+	stats := c.Stats()
+	want := CacheStats{Entries: 2, Bytes: 2, Hits: 2, Misses: 2, Evictions: 1}
+	if stats != want {
+		t.Fatalf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestCache_MaxBytes(t *testing.T) {
+	c := NewCache(100, 10)
+	c.put(newCacheKey("simplified", "big"), strings.Repeat("x", 11))
+	if stats := c.Stats(); stats.Entries != 0 {
+		t.Fatalf("outputs larger than maxBytes should not be cached, got %+v", stats)
+	}
+	for i, raw := range []string{"1", "2", "3"} {
+		c.put(newCacheKey("simplified", raw), strings.Repeat("x", 4))
+		if stats := c.Stats(); stats.Bytes > 10 {
+			t.Fatalf("after put %d: %d bytes cached, limit is 10", i, stats.Bytes)
+		}
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.Evictions != 1 {
+		t.Fatalf("Stats() = %+v, want 2 entries and 1 eviction", stats)
+	}
+}
+
+func TestCache_Nil(t *testing.T) {
+	var c *Cache
+	c.put(newCacheKey("simplified", "x"), "x")
+	if _, ok := c.get(newCacheKey("simplified", "x")); ok {
+		t.Fatalf("a nil cache should never hit")
+	}
+	if stats := c.Stats(); stats != (CacheStats{}) {
+		t.Fatalf("Stats() = %+v, want zero", stats)
+	}
+}
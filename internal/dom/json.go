@@ -0,0 +1,80 @@
+package dom
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// WriteJSON writes node as the same JSON json.Marshal produces, one node at a
+// time, so a large AST is sent as it is encoded instead of first being built
+// into a single buffer.
+func WriteJSON(w io.Writer, node *DomNode) error {
+	bw := bufio.NewWriterSize(w, 32<<10)
+	if err := writeNodeJSON(bw, node); err != nil {
+		return err
+	}
+	if err := bw.WriteByte('\n'); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeNodeJSON(w *bufio.Writer, node *DomNode) error {
+	w.WriteString(`{"nodeType":`)
+	if err := writeJSONValue(w, node.NodeType); err != nil {
+		return err
+	}
+	if node.TagName != "" {
+		w.WriteString(`,"tagName":`)
+		if err := writeJSONValue(w, node.TagName); err != nil {
+			return err
+		}
+	}
+	if node.ID != "" {
+		w.WriteString(`,"id":`)
+		if err := writeJSONValue(w, node.ID); err != nil {
+			return err
+		}
+	}
+	if len(node.Classes) > 0 {
+		w.WriteString(`,"classes":`)
+		if err := writeJSONValue(w, node.Classes); err != nil {
+			return err
+		}
+	}
+	if len(node.Attributes) > 0 {
+		w.WriteString(`,"attributes":`)
+		if err := writeJSONValue(w, node.Attributes); err != nil {
+			return err
+		}
+	}
+	if node.TextContent != "" {
+		w.WriteString(`,"textContent":`)
+		if err := writeJSONValue(w, node.TextContent); err != nil {
+			return err
+		}
+	}
+	if len(node.Children) > 0 {
+		w.WriteString(`,"children":[`)
+		for i := range node.Children {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeNodeJSON(w, &node.Children[i]); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	}
+	return w.WriteByte('}')
+}
+
+func writeJSONValue(w *bufio.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,27 @@
+package dom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	root, err := GetDomAST(context.Background(), benchmarkPage(50)+`<p title="a &quot;b&quot; <c>">x &amp; y</p>`, "")
+	if err != nil {
+		t.Fatalf("GetDomAST returned error: %v", err)
+	}
+
+	want, err := json.Marshal(root)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, root); err != nil {
+		t.Fatalf("WriteJSON returned error: %v", err)
+	}
+	if got := bytes.TrimSuffix(buf.Bytes(), []byte("\n")); !bytes.Equal(got, want) {
+		t.Errorf("WriteJSON output differs from json.Marshal\n got: %.300s\nwant: %.300s", got, want)
+	}
+}
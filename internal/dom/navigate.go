@@ -0,0 +1,93 @@
+package dom
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// lifecycleEvents maps wait strategies to the Page.lifecycleEvent name that ends them.
+var lifecycleEvents = map[string]string{
+	taskstypes.WaitUntilDOMContentLoaded: "DOMContentLoaded",
+	taskstypes.WaitUntilNetworkIdle:      "networkIdle",
+	taskstypes.WaitUntilSelector:         "DOMContentLoaded",
+}
+
+// ValidWaitUntil reports whether waitUntil names a navigation wait strategy.
+func ValidWaitUntil(waitUntil string) bool {
+	_, ok := lifecycleEvents[waitUntil]
+	return ok || waitUntil == "" || waitUntil == taskstypes.WaitUntilLoad
+}
+
+// NavigateWaitAction navigates to url and returns once the page reaches the
+// waitUntil state. "load" (or empty) behaves like NavigateAction; "selector"
+// waits for DOMContentLoaded and then for selector to become visible.
+func NavigateWaitAction(url, waitUntil, selector string) chromedp.Action {
+	if waitUntil == "" || waitUntil == taskstypes.WaitUntilLoad {
+		return NavigateAction(url)
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		event, ok := lifecycleEvents[waitUntil]
+		if !ok {
+			return fmt.Errorf("unknown wait_until %q", waitUntil)
+		}
+		if waitUntil == taskstypes.WaitUntilSelector && selector == "" {
+			return fmt.Errorf("wait_until selector requires a selector")
+		}
+
+		if err := page.SetLifecycleEventsEnabled(true).Do(ctx); err != nil {
+			return err
+		}
+
+		// Lifecycle events can arrive before Navigate returns the loader ID,
+		// so collect them from the start.
+		listenCtx, stopListening := context.WithCancel(ctx)
+		defer stopListening()
+		events := make(chan *page.EventLifecycleEvent, 64)
+		chromedp.ListenTarget(listenCtx, func(ev interface{}) {
+			if ev, ok := ev.(*page.EventLifecycleEvent); ok && ev.Name == event {
+				select {
+				case events <- ev:
+				default:
+				}
+			}
+		})
+
+		frameID, loaderID, errorText, err := page.Navigate(url).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if errorText != "" {
+			return fmt.Errorf("page load error %s", errorText)
+		}
+		// Same-document navigations (e.g. to a #fragment) have no loader and no lifecycle
+		if loaderID != "" {
+			if err := waitLifecycle(ctx, events, frameID, loaderID); err != nil {
+				return err
+			}
+		}
+
+		if waitUntil == taskstypes.WaitUntilSelector {
+			return chromedp.WaitVisible(selector, chromedp.ByQuery).Do(ctx)
+		}
+		return nil
+	})
+}
+
+func waitLifecycle(ctx context.Context, events <-chan *page.EventLifecycleEvent, frameID cdp.FrameID, loaderID cdp.LoaderID) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-events:
+			if ev.FrameID == frameID && ev.LoaderID == loaderID {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package dom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidWaitUntil(t *testing.T) {
+	for _, w := range []string{"", "load", "domcontentloaded", "networkidle", "selector"} {
+		assert.True(t, ValidWaitUntil(w), w)
+	}
+	assert.False(t, ValidWaitUntil("networkidle2"))
+	assert.False(t, ValidWaitUntil("LOAD"))
+}
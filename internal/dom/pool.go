@@ -0,0 +1,121 @@
+package dom
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrPoolClosed is returned when work is submitted to a closed Pool.
+var ErrPoolClosed = errors.New("DOM worker pool closed")
+
+// Pool runs CPU-heavy DOM post-processing, such as simplification and AST
+// building, on a fixed set of worker goroutines. Keeping it off the
+// goroutines that drive the browser means a burst of large pages queues here
+// instead of competing with action execution.
+type Pool struct {
+	jobs chan func()
+	wg   sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewPool starts a pool with the given number of workers; zero or less uses GOMAXPROCS.
+func NewPool(workers int) *Pool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	p := &Pool{jobs: make(chan func())} // Unbuffered: submitters wait for a free worker
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// Do runs fn on a worker and returns its error. It waits for a free worker
+// and for fn to finish, either of which ends early if ctx is done; fn should
+// watch ctx itself to stop promptly.
+func (p *Pool) Do(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	job := func() {
+		if err := ctx.Err(); err != nil {
+			done <- err
+			return
+		}
+		done <- fn()
+	}
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPoolClosed
+	}
+	select {
+	case p.jobs <- job:
+	case <-ctx.Done():
+		p.mu.RUnlock()
+		return ctx.Err()
+	}
+	p.mu.RUnlock()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting work and waits for running jobs to finish.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+var (
+	defaultPoolMu sync.RWMutex
+	defaultPool   = NewPool(0)
+)
+
+// DefaultPool returns the pool DOM actions post-process on.
+func DefaultPool() *Pool {
+	defaultPoolMu.RLock()
+	defer defaultPoolMu.RUnlock()
+	return defaultPool
+}
+
+// SetDefaultPool replaces the shared pool, for example to apply
+// browser.domWorkers at startup. The previous pool finishes its queued work
+// and is closed.
+func SetDefaultPool(p *Pool) {
+	defaultPoolMu.Lock()
+	old := defaultPool
+	defaultPool = p
+	defaultPoolMu.Unlock()
+	if old != p {
+		go old.Close()
+	}
+}
+
+// postProcess runs fn on the default pool, retrying once if the pool was
+// replaced between looking it up and submitting.
+func postProcess(ctx context.Context, fn func() error) error {
+	err := DefaultPool().Do(ctx, fn)
+	if errors.Is(err, ErrPoolClosed) {
+		err = DefaultPool().Do(ctx, fn)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package dom
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool_BoundsConcurrency(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.Do(context.Background(), func() error {
+				n := running.Add(1)
+				for {
+					old := peak.Load()
+					if n <= old || peak.CompareAndSwap(old, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Do returned error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrency = %d, want 2", got)
+	}
+}
+
+func TestPool_ReturnsErrorsAndHonorsContext(t *testing.T) {
+	p := NewPool(1)
+	defer p.Close()
+
+	boom := errors.New("boom")
+	if err := p.Do(context.Background(), func() error { return boom }); !errors.Is(err, boom) {
+		t.Errorf("Do error = %v, want %v", err, boom)
+	}
+
+	// Occupy the only worker, then give up waiting for it
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go p.Do(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ran := false
+	err := p.Do(ctx, func() error { ran = true; return nil })
+	close(release)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do error = %v, want deadline exceeded", err)
+	}
+	if ran {
+		t.Error("job ran after its context ended")
+	}
+}
+
+func TestPool_Closed(t *testing.T) {
+	p := NewPool(1)
+	p.Close()
+	if err := p.Do(context.Background(), func() error { return nil }); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Do error = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestSetDefaultPool(t *testing.T) {
+	old := DefaultPool()
+	replacement := NewPool(1)
+	SetDefaultPool(replacement)
+	defer SetDefaultPool(NewPool(0))
+
+	if DefaultPool() != replacement {
+		t.Fatal("default pool was not replaced")
+	}
+	if err := postProcess(context.Background(), func() error { return nil }); err != nil {
+		t.Errorf("postProcess returned error: %v", err)
+	}
+	// The replaced pool is closed once its work drains
+	deadline := time.Now().Add(time.Second)
+	for old.Do(context.Background(), func() error { return nil }) != ErrPoolClosed {
+		if time.Now().After(deadline) {
+			t.Fatal("previous default pool was not closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
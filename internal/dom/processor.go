@@ -1,10 +1,8 @@
 package dom
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"strings"
 	"time"
 
@@ -25,115 +23,47 @@ func GetOuterHTMLAction(selector string, res *string) chromedp.Action {
 	return chromedp.OuterHTML(selector, res, chromedp.ByQuery)
 }
 
+// GetSimplifiedDOM strips scripts, styles, comments, and presentational
+// markup from htmlContent, keeping structural elements, text, and the
+// attributes useful for locating elements.
 func GetSimplifiedDOM(htmlContent string) (string, error) {
-	doc, err := html.Parse(strings.NewReader(htmlContent))
-	if err != nil {
-		return "", err
-	}
+	buf := getBuffer()
+	defer putBuffer(buf)
 
-	var buf bytes.Buffer
-	err = simplifyNode(&buf, doc)
-	if err != nil {
+	if err := simplifyTokens(buf, html.NewTokenizer(strings.NewReader(htmlContent))); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
 }
 
-func simplifyNode(w io.Writer, n *html.Node) error {
-	switch n.Type {
-	case html.ErrorNode:
-		return nil
-	case html.DocumentNode:
-		// Process children
-	case html.DoctypeNode:
-		if _, err := io.WriteString(w, "<!DOCTYPE "+n.Data+">"); err != nil {
-			return err
-		}
-	case html.CommentNode:
-		return nil
-	case html.TextNode:
-		trimmed := strings.TrimSpace(n.Data)
-		if trimmed != "" {
-			if _, err := io.WriteString(w, html.EscapeString(trimmed)+" "); err != nil {
-				return err
-			}
-		}
-		return nil
-	case html.ElementNode:
-		if n.Data == "script" || n.Data == "style" || n.Data == "noscript" || n.Data == "meta" || n.Data == "link" {
-			return nil
-		}
-
-		allowedTags := map[string]bool{
-			"html": true, "head": true, "body": true, "title": true,
-			"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
-			"p": true, "div": true, "span": true, "br": false, "hr": false,
-			"ul": true, "ol": true, "li": true,
-			"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "th": true, "td": true,
-			"a": true, "button": true, "input": false, "textarea": true, "select": true, "option": true, "label": true,
-			"form": true, "img": false, "pre": true, "code": true, "strong": true, "em": true, "b": true, "i": true,
-		}
-		if !allowedTags[n.Data] {
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if err := simplifyNode(w, c); err != nil {
-					return err
-				}
-			}
-			return nil
-		}
-
-		if _, err := io.WriteString(w, "<"+n.Data); err != nil {
+// GetSimplifiedDOMAction fetches the outer HTML of selector and simplifies it
+// on the DOM worker pool, unless the default cache already holds the
+// simplified form of identical HTML.
+func GetSimplifiedDOMAction(selector string, res *string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var raw string
+		if err := chromedp.OuterHTML(selector, &raw, chromedp.ByQuery).Do(ctx); err != nil {
 			return err
 		}
-
-		allowedAttrs := map[string]bool{
-			"href": true, "src": true, "alt": true, "title": true,
-			"id": true, "class": true,
-			"type": true, "value": true, "placeholder": true, "name": true,
-			"selected": true, "checked": true, "disabled": true, "readonly": true,
-			"aria-label": true, "aria-hidden": true, "role": true,
-		}
-
-		for _, a := range n.Attr {
-			if allowedAttrs[a.Key] {
-				val := strings.TrimSpace(a.Val)
-				if val != "" || a.Key == "value" || a.Key == "selected" || a.Key == "checked" || a.Key == "disabled" || a.Key == "readonly" {
-					if _, err := io.WriteString(w, " "+a.Key+"=\""+html.EscapeString(val)+"\""); err != nil {
-						return err
-					}
-				}
+		cache := DefaultCache()
+		var key cacheKey
+		if cache != nil {
+			key = newCacheKey("simplified", raw)
+			if simplified, ok := cache.get(key); ok {
+				*res = simplified
+				return nil
 			}
 		}
-
-		if _, err := io.WriteString(w, ">"); err != nil {
-			return err
-		}
-	}
-
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		if err := simplifyNode(w, c); err != nil {
-			return err
-		}
-	}
-
-	if n.Type == html.ElementNode {
-		allowedTags := map[string]bool{
-			"html": true, "head": true, "body": true, "title": true,
-			"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
-			"p": true, "div": true, "span": true, "br": false, "hr": false,
-			"ul": true, "ol": true, "li": true,
-			"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "th": true, "td": true,
-			"a": true, "button": true, "input": false, "textarea": true, "select": true, "option": true, "label": true,
-			"form": true, "img": false, "pre": true, "code": true, "strong": true, "em": true, "b": true, "i": true,
-		}
-		if allowed, ok := allowedTags[n.Data]; ok && allowed {
-			if _, err := io.WriteString(w, "</"+n.Data+">"); err != nil {
+		return postProcess(ctx, func() error {
+			simplified, err := GetSimplifiedDOM(raw)
+			if err != nil {
 				return err
 			}
-		}
-	}
-
-	return nil
+			cache.put(key, simplified)
+			*res = simplified
+			return nil
+		})
+	})
 }
 
 func TypeAction(selector string, text string) chromedp.Action {
@@ -193,7 +123,8 @@ func GetNodeIDs(selector string, nodeIDs *[]cdp.NodeID) chromedp.Action {
 func IsElementPresentAction(selector string, isPresent *bool) chromedp.Action {
 	return chromedp.ActionFunc(func(ctx context.Context) error {
 		var nodes []*cdp.Node
-		err := chromedp.Nodes(selector, &nodes, chromedp.ByQuery).Do(ctx)
+		// AtLeast(0) returns right away instead of waiting for a match
+		err := chromedp.Nodes(selector, &nodes, chromedp.ByQuery, chromedp.AtLeast(0)).Do(ctx)
 		if err != nil {
 			if ctx.Err() != nil {
 				return ctx.Err() // Context cancelled is a real error
@@ -226,217 +157,81 @@ func GetDomAST(ctx context.Context, htmlContent, parentSelector string) (*DomNod
 		return nil, fmt.Errorf("empty HTML content")
 	}
 
-	doc, err := html.Parse(strings.NewReader(htmlContent))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
-	}
-
-	// If parentSelector is empty, start from document root
+	z := html.NewTokenizer(strings.NewReader(htmlContent))
 	if parentSelector == "" {
-		root := &DomNode{
-			NodeType: "document",
-			Children: []DomNode{},
-		}
-		
-		// Process the HTML document
-		// Process children of the HTML node directly
-		for c := doc.FirstChild; c != nil; c = c.NextSibling {
-			processNode(c, root)
+		root, err := buildAST(ctx, z, DomNode{NodeType: "document"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse HTML: %w", err)
 		}
 		return root, nil
 	}
 
-	// Otherwise, find the parent node and process from there
-	var parentNode *html.Node
-	var findParent func(*html.Node)
-	
-	findParent = func(n *html.Node) {
-		if n.Type == html.ElementNode {
-			// Build a selector for this node to compare
-			var id, classes string
-			for _, attr := range n.Attr {
-				if attr.Key == "id" {
-					id = attr.Val
-				}
-				if attr.Key == "class" {
-					classes = attr.Val
-				}
-			}
-			
-			// Simple matching based on tag and ID
-			if strings.HasPrefix(parentSelector, n.Data) {
-				if id != "" && strings.Contains(parentSelector, "#"+id) {
-					parentNode = n
-					return
-				} else if classes != "" {
-					// Check if any class in the selector matches
-					for _, class := range strings.Fields(classes) {
-						if strings.Contains(parentSelector, "."+class) {
-							parentNode = n
-							return
-						}
-					}
-				} else if parentSelector == n.Data {
-					parentNode = n
-					return
-				}
-			}
-			
-			// Add improved class selector matching (e.g., div.class-name)
-			if len(strings.Split(parentSelector, ".")) > 1 {
-				parts := strings.Split(parentSelector, ".")
-				tagName := parts[0]
-				className := parts[1]
-				
-				// Check if tag name matches and class contains the specified class
-				if n.Data == tagName && classes != "" {
-					for _, class := range strings.Fields(classes) {
-						if class == className || strings.Contains(class, className) {
-							parentNode = n
-							return
-						}
-					}
-				}
-			}
-		}
-		
-		// Recursively check children
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			if parentNode == nil {
-				findParent(c)
-			}
-		}
+	parent, found, tt, err := findParentElement(ctx, z, parentSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
-	
-	findParent(doc)
-	
-	if parentNode == nil {
+	if !found {
 		return nil, fmt.Errorf("parent selector '%s' not found", parentSelector)
 	}
-	
-	// Build AST from the found parent node
-	root := &DomNode{
-		NodeType: "element",
-		TagName:  parentNode.Data,
-		Children: []DomNode{},
+	if tt == html.SelfClosingTagToken || voidElements[parent.TagName] {
+		return &parent, nil
 	}
-	
-	// Process attributes
-	processAttributes(parentNode, root)
-	
-	// Process children
-	for c := parentNode.FirstChild; c != nil; c = c.NextSibling {
-		processNode(c, root)
+	root, err := buildAST(ctx, z, parent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
-	
 	return root, nil
 }
 
-// processNode recursively processes HTML nodes and builds the DOM AST
-func processNode(n *html.Node, parent *DomNode) {
-	switch n.Type {
-	case html.ElementNode:
-		node := DomNode{
-			NodeType:   "element",
-			TagName:    n.Data,
-			Attributes: make(map[string]string),
-			Children:   []DomNode{},
-		}
-		
-		// Process attributes
-		processAttributes(n, &node)
-		
-		// Process children
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			processNode(c, &node)
-		}
-		
-		parent.Children = append(parent.Children, node)
-		
-	case html.TextNode:
-		// Ignore whitespace-only text nodes
-		trimmed := strings.TrimSpace(n.Data)
-		if trimmed != "" {
-			node := DomNode{
-				NodeType:    "text",
-				TextContent: trimmed,
+// GetDomASTAction returns a chromedp action that fetches the DOM AST. With a
+// parent selector, only that element's HTML is fetched and it is the AST's root.
+func GetDomASTAction(parentSelector string, result *DomNode) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if parentSelector == "" {
+			var html string
+			if err := chromedp.OuterHTML("html", &html).Do(ctx); err != nil {
+				return err
 			}
-			parent.Children = append(parent.Children, node)
-		}
-		
-	case html.CommentNode:
-		// Optionally include comments
-		node := DomNode{
-			NodeType:    "comment",
-			TextContent: n.Data,
+			return postProcess(ctx, func() error {
+				ast, err := GetDomAST(ctx, html, "")
+				if err != nil {
+					return err
+				}
+				*result = *ast
+				return nil
+			})
 		}
-		parent.Children = append(parent.Children, node)
-	}
-}
 
-// processAttributes extracts attributes from an HTML node
-func processAttributes(n *html.Node, node *DomNode) {
-	for _, attr := range n.Attr {
-		node.Attributes[attr.Key] = attr.Val
-		
-		// Extract ID and classes for easier access
-		if attr.Key == "id" {
-			node.ID = attr.Val
-		} else if attr.Key == "class" {
-			node.Classes = strings.Fields(attr.Val)
+		// Check if the element exists first
+		var exists bool
+		if err := chromedp.Evaluate(fmt.Sprintf(`document.querySelector(%q) !== null`, parentSelector), &exists).Do(ctx); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("parent selector '%s' not found", parentSelector)
 		}
-	}
-}
 
-// GetDomASTAction returns a chromedp action that fetches the DOM AST
-func GetDomASTAction(parentSelector string, result *DomNode) chromedp.Action {
-	return chromedp.ActionFunc(func(ctx context.Context) error {
-		var html string
-		
-		// First get the HTML content
-		if err := chromedp.OuterHTML("html", &html).Do(ctx); err != nil {
-			return err
+		// Get the HTML for that specific element
+		var parentHTML string
+		if err := chromedp.OuterHTML(parentSelector, &parentHTML, chromedp.ByQuery).Do(ctx); err != nil {
+			return fmt.Errorf("error getting parent element: %w", err)
 		}
-		
-		// If there's a parent selector, try to get that element's HTML directly using chromedp
-		if parentSelector != "" {
-			var parentHTML string
-			var exists bool
-			
-			// Check if the element exists first
-			if err := chromedp.Evaluate(fmt.Sprintf(`document.querySelector("%s") !== null`, parentSelector), &exists).Do(ctx); err != nil {
-				return err
-			}
-			
-			if !exists {
-				return fmt.Errorf("parent selector '%s' not found", parentSelector)
-			}
-			
-			// Get the HTML for that specific element
-			if err := chromedp.OuterHTML(parentSelector, &parentHTML).Do(ctx); err != nil {
-				return fmt.Errorf("error getting parent element: %w", err)
-			}
-			
-			// Generate AST from the parent HTML
+
+		return postProcess(ctx, func() error {
 			ast, err := GetDomAST(ctx, parentHTML, "")
 			if err != nil {
 				return err
 			}
-			
-			// Copy the result
+			// The fragment is the element itself; unwrap the document around it
+			for _, child := range ast.Children {
+				if child.NodeType == "element" {
+					*result = child
+					return nil
+				}
+			}
 			*result = *ast
 			return nil
-		}
-		
-		// If no parent selector, process the full HTML
-		ast, err := GetDomAST(ctx, html, "")
-		if err != nil {
-			return err
-		}
-		
-		// Copy the result
-		*result = *ast
-		return nil
+		})
 	})
 }
 
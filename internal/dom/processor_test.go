@@ -2,7 +2,9 @@ package dom
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -130,3 +132,145 @@ func TestChromedpWorks(t *testing.T) {
 		t.Error("Screenshot seems invalid or too small")
 	}
 }
+
+func TestGetSimplifiedDOM(t *testing.T) {
+	input := `<!DOCTYPE html><html><head><title>Shop</title><meta charset="utf-8"><link rel="stylesheet" href="a.css">` +
+		`<style>body { color: red }</style><script>if (a < b) { document.write("<p>x</p>") }</script></head>` +
+		`<body><!-- banner --><section class=" main " data-track="1"><h1 id="t" style="x">Deals &amp; offers</h1>` +
+		`<p>Only <em>today</em><br>while "stock" lasts</p><input type="checkbox" checked><img src="a.png" alt="">` +
+		`<noscript><p>Enable JS</p></noscript><div/></section></body></html>`
+
+	got, err := GetSimplifiedDOM(input)
+	if err != nil {
+		t.Fatalf("GetSimplifiedDOM returned error: %v", err)
+	}
+	want := `<!DOCTYPE html><html><head><title>Shop </title></head>` +
+		`<body><h1 id="t">Deals &amp; offers </h1>` +
+		`<p>Only <em>today </em><br>while &#34;stock&#34; lasts </p><input type="checkbox" checked=""><img src="a.png">` +
+		`<div></div></body></html>`
+	if got != want {
+		t.Errorf("GetSimplifiedDOM mismatch\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestGetSimplifiedDOM_ClosesOpenElements(t *testing.T) {
+	got, err := GetSimplifiedDOM(`<div><ul><li>one<li>two</ul><p>unclosed</div></span>`)
+	if err != nil {
+		t.Fatalf("GetSimplifiedDOM returned error: %v", err)
+	}
+	want := `<div><ul><li>one <li>two </li></li></ul><p>unclosed </p></div>`
+	if got != want {
+		t.Errorf("GetSimplifiedDOM mismatch\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestGetDomAST(t *testing.T) {
+	input := `<html><body><div id="main" class="container wide"><h1>Title</h1><!-- note -->` +
+		`<img src="a.png"><p>One<br>Two</p></div><div class="other">Else</div></body></html>`
+
+	root, err := GetDomAST(context.Background(), input, "")
+	if err != nil {
+		t.Fatalf("GetDomAST returned error: %v", err)
+	}
+	if root.NodeType != "document" || len(root.Children) != 1 || root.Children[0].TagName != "html" {
+		t.Fatalf("unexpected document root: %+v", root)
+	}
+	body := root.Children[0].Children[0]
+	main := body.Children[0]
+	if main.ID != "main" || len(main.Classes) != 2 || main.Attributes["class"] != "container wide" {
+		t.Errorf("unexpected attributes on main: %+v", main)
+	}
+	var kinds []string
+	for _, child := range main.Children {
+		kinds = append(kinds, child.NodeType+":"+child.TagName+child.TextContent)
+	}
+	want := []string{"element:h1", "comment: note ", "element:img", "element:p"}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Errorf("main children = %v, want %v", kinds, want)
+	}
+	p := main.Children[3]
+	if len(p.Children) != 3 || p.Children[1].TagName != "br" || p.Children[2].TextContent != "Two" {
+		t.Errorf("void br should not swallow following text: %+v", p.Children)
+	}
+
+	scoped, err := GetDomAST(context.Background(), input, "div.other")
+	if err != nil {
+		t.Fatalf("GetDomAST with selector returned error: %v", err)
+	}
+	if scoped.TagName != "div" || len(scoped.Children) != 1 || scoped.Children[0].TextContent != "Else" {
+		t.Errorf("unexpected scoped AST: %+v", scoped)
+	}
+
+	if _, err := GetDomAST(context.Background(), input, "#missing"); err == nil {
+		t.Error("expected an error for a selector that matches nothing")
+	}
+	if _, err := GetDomAST(context.Background(), "", ""); err == nil {
+		t.Error("expected an error for empty HTML")
+	}
+}
+
+// benchmarkPage returns a product listing page with n items, roughly the
+// shape and markup density of the large pages tasks fetch.
+func benchmarkPage(n int) string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><head><title>Catalog</title><meta charset="utf-8">` +
+		`<style>.item { margin: 0 }</style><script>window.dataLayer = [];</script></head><body>` +
+		`<nav class="top"><ul><li><a href="/">Home</a></li><li><a href="/deals">Deals</a></li></ul></nav>` +
+		`<main id="results" class="grid">`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `<div class="item card" data-sku="sku-%d" data-track='{"pos":%d}'>`+
+			`<a href="/p/%d"><img src="/img/%d.jpg" alt="Item %d" loading="lazy"></a>`+
+			`<h2 class="title"><span>Item %d</span></h2><p class="price">$%d.99 <em>incl. tax</em></p>`+
+			`<button type="button" aria-label="Add item %d to cart" disabled>Add</button><!-- item %d --></div>`,
+			i, i, i, i, i, i, i%500, i, i)
+	}
+	b.WriteString(`</main><footer><p>&copy; Example &amp; Co</p></footer></body></html>`)
+	return b.String()
+}
+
+func BenchmarkGetSimplifiedDOM(b *testing.B) {
+	page := benchmarkPage(5000)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetSimplifiedDOM(page); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetDomAST(b *testing.B) {
+	page := benchmarkPage(5000)
+	for _, bm := range []struct{ name, selector string }{
+		{"Document", ""},
+		{"ParentSelector", "main#results"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetBytes(int64(len(page)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := GetDomAST(context.Background(), page, bm.selector); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPool_Do(b *testing.B) {
+	page := benchmarkPage(100)
+	p := NewPool(0)
+	defer p.Close()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			err := p.Do(context.Background(), func() error {
+				_, err := GetSimplifiedDOM(page)
+				return err
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
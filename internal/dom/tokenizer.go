@@ -0,0 +1,381 @@
+package dom
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Documents are processed as a token stream rather than a parsed tree, so a
+// multi-megabyte page is never held as millions of html.Node values. Tag and
+// attribute names are compared without copying, and output is written to
+// pooled buffers.
+//
+// The input is expected to be serialized DOM as Chrome produces it, where
+// every non-void element has an explicit end tag. Elements left open are
+// closed when an enclosing element ends or at the end of the input.
+
+// maxPooledBuffer keeps one very large document from pinning its buffer in the pool.
+const maxPooledBuffer = 8 << 20
+
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// ctxCheckInterval is how many tokens are processed between context checks.
+const ctxCheckInterval = 4096
+
+// keptTag is an element GetSimplifiedDOM writes out. The interned name
+// avoids allocating a string for every tag.
+type keptTag struct {
+	name      string
+	container bool // Written with a closing tag; otherwise void
+}
+
+// simplifyTags lists the elements GetSimplifiedDOM keeps. Other elements are
+// dropped but their content is kept.
+var simplifyTags = func() map[string]keptTag {
+	tags := make(map[string]keptTag)
+	for _, name := range []string{
+		"html", "head", "body", "title",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+		"p", "div", "span",
+		"ul", "ol", "li",
+		"table", "thead", "tbody", "tfoot", "tr", "th", "td",
+		"a", "button", "textarea", "select", "option", "label",
+		"form", "pre", "code", "strong", "em", "b", "i",
+	} {
+		tags[name] = keptTag{name: name, container: true}
+	}
+	for _, name := range []string{"br", "hr", "input", "img"} {
+		tags[name] = keptTag{name: name}
+	}
+	return tags
+}()
+
+// simplifyAttrs lists the attributes GetSimplifiedDOM keeps. Those mapped to
+// true are kept even when empty.
+var simplifyAttrs = map[string]bool{
+	"href": false, "src": false, "alt": false, "title": false,
+	"id": false, "class": false,
+	"type": false, "value": true, "placeholder": false, "name": false,
+	"selected": true, "checked": true, "disabled": true, "readonly": true,
+	"aria-label": false, "aria-hidden": false, "role": false,
+}
+
+// voidElements never have content or an end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// simplifyTokens writes the simplified form of the document z reads to buf.
+func simplifyTokens(buf *bytes.Buffer, z *html.Tokenizer) error {
+	var open []string // Kept container elements awaiting their end tag
+	var skip string   // Element whose content is being dropped
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return err
+			}
+			for i := len(open) - 1; i >= 0; i-- {
+				writeEndTag(buf, open[i])
+			}
+			return nil
+
+		case html.DoctypeToken:
+			if skip == "" {
+				buf.WriteString("<!DOCTYPE ")
+				buf.Write(z.Text())
+				buf.WriteByte('>')
+			}
+
+		case html.TextToken:
+			if skip == "" {
+				if text := bytes.TrimSpace(z.Text()); len(text) > 0 {
+					escapeTo(buf, text)
+					buf.WriteByte(' ')
+				}
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if skip != "" {
+				continue
+			}
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "script", "style", "noscript":
+				if tt == html.StartTagToken {
+					skip = simplifySkipName(name)
+				}
+				continue
+			}
+			tag, ok := simplifyTags[string(name)]
+			if !ok {
+				continue
+			}
+			buf.WriteByte('<')
+			buf.WriteString(tag.name)
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				keepEmpty, ok := simplifyAttrs[string(key)]
+				if !ok {
+					continue
+				}
+				if val = bytes.TrimSpace(val); len(val) > 0 || keepEmpty {
+					buf.WriteByte(' ')
+					buf.Write(key)
+					buf.WriteString(`="`)
+					escapeTo(buf, val)
+					buf.WriteByte('"')
+				}
+			}
+			buf.WriteByte('>')
+			if tag.container {
+				if tt == html.SelfClosingTagToken {
+					writeEndTag(buf, tag.name)
+				} else {
+					open = append(open, tag.name)
+				}
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if skip != "" {
+				if string(name) == skip {
+					skip = ""
+				}
+				continue
+			}
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == string(name) {
+					for j := len(open) - 1; j >= i; j-- {
+						writeEndTag(buf, open[j])
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+}
+
+// simplifySkipName returns the interned name of an element whose content is dropped.
+func simplifySkipName(name []byte) string {
+	switch string(name) {
+	case "script":
+		return "script"
+	case "style":
+		return "style"
+	default:
+		return "noscript"
+	}
+}
+
+func writeEndTag(buf *bytes.Buffer, name string) {
+	buf.WriteString("</")
+	buf.WriteString(name)
+	buf.WriteByte('>')
+}
+
+// escapeTo writes s to buf escaped the same way as html.EscapeString.
+func escapeTo(buf *bytes.Buffer, s []byte) {
+	last := 0
+	for i, c := range s {
+		var esc string
+		switch c {
+		case '&':
+			esc = "&amp;"
+		case '\'':
+			esc = "&#39;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '"':
+			esc = "&#34;"
+		default:
+			continue
+		}
+		buf.Write(s[last:i])
+		buf.WriteString(esc)
+		last = i + 1
+	}
+	buf.Write(s[last:])
+}
+
+// buildAST adds the nodes z reads to root until root's end tag or the end
+// of the input. Nodes are kept on a stack until their end tag and only then
+// appended to their parent, so no pointers into Children are held while it grows.
+func buildAST(ctx context.Context, z *html.Tokenizer, root DomNode) (*DomNode, error) {
+	names := make(interner)
+	stack := []DomNode{root}
+	closeTo := func(depth int) {
+		for len(stack) > depth+1 {
+			node := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			parent := &stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	for n := 1; ; n++ {
+		if n%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			closeTo(0)
+			return &stack[0], nil
+
+		case html.TextToken:
+			if text := bytes.TrimSpace(z.Text()); len(text) > 0 {
+				appendChild(stack, DomNode{NodeType: "text", TextContent: string(text)})
+			}
+
+		case html.CommentToken:
+			appendChild(stack, DomNode{NodeType: "comment", TextContent: string(z.Text())})
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			node := elementNode(z, names)
+			if tt == html.SelfClosingTagToken || voidElements[node.TagName] {
+				appendChild(stack, node)
+			} else {
+				stack = append(stack, node)
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].NodeType == "element" && stack[i].TagName == string(name) {
+					if i == 0 {
+						closeTo(0)
+						return &stack[0], nil
+					}
+					closeTo(i)
+					closeTo(i - 1)
+					break
+				}
+			}
+		}
+	}
+}
+
+func appendChild(stack []DomNode, child DomNode) {
+	parent := &stack[len(stack)-1]
+	parent.Children = append(parent.Children, child)
+}
+
+// interner shares one string per distinct tag or attribute name, which
+// repeat on every element of a large page.
+type interner map[string]string
+
+func (in interner) get(b []byte) string {
+	if s, ok := in[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	in[s] = s
+	return s
+}
+
+// elementNode reads the current start tag and its attributes.
+func elementNode(z *html.Tokenizer, names interner) DomNode {
+	name, hasAttr := z.TagName()
+	node := DomNode{NodeType: "element", TagName: names.get(name)}
+	for hasAttr {
+		var key, val []byte
+		key, val, hasAttr = z.TagAttr()
+		if node.Attributes == nil {
+			node.Attributes = make(map[string]string)
+		}
+		k, v := names.get(key), string(val)
+		node.Attributes[k] = v
+		switch k {
+		case "id":
+			node.ID = v
+		case "class":
+			node.Classes = strings.Fields(v)
+		}
+	}
+	return node
+}
+
+// findParentElement advances z to the first element matching parentSelector
+// and returns it, reporting whether it was found.
+func findParentElement(ctx context.Context, z *html.Tokenizer, parentSelector string) (DomNode, bool, html.TokenType, error) {
+	names := make(interner)
+	for n := 1; ; n++ {
+		if n%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return DomNode{}, false, 0, err
+			}
+		}
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return DomNode{}, false, tt, err
+			}
+			return DomNode{}, false, tt, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			node := elementNode(z, names)
+			if matchesParentSelector(parentSelector, node) {
+				return node, true, tt, nil
+			}
+		}
+	}
+}
+
+// matchesParentSelector applies GetDomAST's simple tag, #id, and .class matching.
+func matchesParentSelector(selector string, node DomNode) bool {
+	tag := node.TagName
+	classes := node.Attributes["class"]
+	if strings.HasPrefix(selector, tag) {
+		if node.ID != "" && strings.Contains(selector, "#"+node.ID) {
+			return true
+		} else if classes != "" {
+			for _, class := range node.Classes {
+				if strings.Contains(selector, "."+class) {
+					return true
+				}
+			}
+		} else if selector == tag {
+			return true
+		}
+	}
+
+	// Tag and class, e.g. div.class-name
+	if parts := strings.Split(selector, "."); len(parts) > 1 && tag == parts[0] && classes != "" {
+		for _, class := range node.Classes {
+			if class == parts[1] || strings.Contains(class, parts[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// CredentialAlgorithm names the scheme clients use to seal credentials:
+// a random AES-256-GCM key encrypts the credentials JSON and is itself
+// encrypted to the server's RSA public key with OAEP and SHA-256.
+const CredentialAlgorithm = "RSA-OAEP-256+A256GCM"
+
+const generatedKeyBits = 2048
+
+// ErrCredentialKeyMismatch is returned when sealed credentials were encrypted
+// to a different public key, typically one published before a restart.
+var ErrCredentialKeyMismatch = errors.New("credentials were sealed with an unknown key")
+
+// sealedPayload is the plaintext format inside SealedCredentials.
+type sealedPayload struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	NewPassword string `json:"new_password,omitempty"` // Only for change_password tasks
+}
+
+// CredentialKey is the RSA key pair clients encrypt task credentials to.
+// Only the executor holds it, so plaintext passwords never cross proxies or
+// appear in request logs.
+type CredentialKey struct {
+	path string
+
+	once  sync.Once
+	err   error
+	key   *rsa.PrivateKey
+	keyID string
+	pem   []byte
+}
+
+// NewCredentialKey loads the RSA private key from a PEM file (PKCS#1 or PKCS#8).
+// With an empty path a key is generated on first use; it lives only as long
+// as the process, so clients must refetch the public key after a restart.
+func NewCredentialKey(privateKeyFile string) *CredentialKey {
+	return &CredentialKey{path: privateKeyFile}
+}
+
+func (k *CredentialKey) load() error {
+	k.once.Do(func() {
+		if k.path == "" {
+			k.key, k.err = rsa.GenerateKey(rand.Reader, generatedKeyBits)
+		} else {
+			k.key, k.err = readRSAPrivateKey(k.path)
+		}
+		if k.err != nil {
+			return
+		}
+
+		der, err := x509.MarshalPKIXPublicKey(&k.key.PublicKey)
+		if err != nil {
+			k.err = err
+			return
+		}
+		sum := sha256.Sum256(der)
+		k.keyID = hex.EncodeToString(sum[:8])
+		k.pem = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	})
+	return k.err
+}
+
+func readRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("credential key %s is not PEM encoded", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credential key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("credential key %s is not an RSA key", path)
+	}
+	return key, nil
+}
+
+// PublicKey returns the key ID and PEM-encoded public key to publish to clients.
+func (k *CredentialKey) PublicKey() (keyID string, publicKeyPEM []byte, err error) {
+	if err := k.load(); err != nil {
+		return "", nil, err
+	}
+	return k.keyID, k.pem, nil
+}
+
+// Check verifies that sealed credentials target this key without decrypting them.
+func (k *CredentialKey) Check(sealed *taskstypes.SealedCredentials) error {
+	if err := k.load(); err != nil {
+		return err
+	}
+	if sealed.KeyID != k.keyID {
+		return fmt.Errorf("%w: %q", ErrCredentialKeyMismatch, sealed.KeyID)
+	}
+	return nil
+}
+
+// Open decrypts sealed credentials.
+func (k *CredentialKey) Open(sealed *taskstypes.SealedCredentials) (*taskstypes.Credentials, error) {
+	if err := k.Check(sealed); err != nil {
+		return nil, err
+	}
+
+	dek, err := rsa.DecryptOAEP(sha256.New(), nil, k.key, sealed.EncryptedKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential key: %w", err)
+	}
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, sealed.Nonce, sealed.Ciphertext, []byte(sealed.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials: %w", err)
+	}
+
+	var payload sealedPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, fmt.Errorf("invalid credentials payload: %w", err)
+	}
+	return &taskstypes.Credentials{Username: payload.Username, Password: payload.Password, NewPassword: payload.NewPassword}, nil
+}
+
+// SealCredentials encrypts credentials to a published public key. It is the
+// reference implementation of the client side of CredentialAlgorithm.
+func SealCredentials(keyID string, publicKeyPEM []byte, username, password string) (*taskstypes.SealedCredentials, error) {
+	return seal(keyID, publicKeyPEM, sealedPayload{Username: username, Password: password})
+}
+
+// SealPasswordChange is SealCredentials with the new password for a
+// change_password action.
+func SealPasswordChange(keyID string, publicKeyPEM []byte, username, password, newPassword string) (*taskstypes.SealedCredentials, error) {
+	return seal(keyID, publicKeyPEM, sealedPayload{Username: username, Password: password, NewPassword: newPassword})
+}
+
+func seal(keyID string, publicKeyPEM []byte, payload sealedPayload) (*taskstypes.SealedCredentials, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("public key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	pub, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an RSA key")
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, dek, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt credential key: %w", err)
+	}
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &taskstypes.SealedCredentials{
+		KeyID:        keyID,
+		EncryptedKey: encryptedKey,
+		Nonce:        nonce,
+		Ciphertext:   gcm.Seal(nil, nonce, plaintext, []byte(keyID)),
+	}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AES key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,78 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialKey_RoundTrip(t *testing.T) {
+	key := NewCredentialKey("")
+	keyID, publicKey, err := key.PublicKey()
+	require.NoError(t, err)
+	assert.NotEmpty(t, keyID)
+
+	sealed, err := SealCredentials(keyID, publicKey, "alice", "hunter2")
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed.Ciphertext), "hunter2")
+
+	creds, err := key.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", creds.Username)
+	assert.Equal(t, "hunter2", creds.Password)
+
+	sealed.Ciphertext[0] ^= 0xff
+	_, err = key.Open(sealed)
+	assert.Error(t, err)
+}
+
+func TestCredentialKey_PasswordChange(t *testing.T) {
+	key := NewCredentialKey("")
+	keyID, publicKey, err := key.PublicKey()
+	require.NoError(t, err)
+
+	sealed, err := SealPasswordChange(keyID, publicKey, "alice", "hunter2", "correct horse")
+	require.NoError(t, err)
+	creds, err := key.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", creds.Password)
+	assert.Equal(t, "correct horse", creds.NewPassword)
+}
+
+func TestCredentialKey_Mismatch(t *testing.T) {
+	other := NewCredentialKey("")
+	keyID, publicKey, err := other.PublicKey()
+	require.NoError(t, err)
+	sealed, err := SealCredentials(keyID, publicKey, "alice", "hunter2")
+	require.NoError(t, err)
+
+	_, err = NewCredentialKey("").Open(sealed)
+	assert.ErrorIs(t, err, ErrCredentialKeyMismatch)
+}
+
+func TestCredentialKey_FromFile(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "credentials.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	// Two loads of the same file publish the same key, so it survives restarts
+	id1, _, err := NewCredentialKey(path).PublicKey()
+	require.NoError(t, err)
+	id2, _, err := NewCredentialKey(path).PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, id1, id2)
+
+	_, _, err = NewCredentialKey(filepath.Join(t.TempDir(), "missing.pem")).PublicKey()
+	assert.Error(t, err)
+}
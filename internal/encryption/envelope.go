@@ -0,0 +1,282 @@
+// Package encryption provides envelope encryption for data GoScry keeps at rest,
+// such as task results and artifacts that routinely contain scraped PII.
+//
+// Each sealed payload gets a fresh random data key (DEK). The payload is
+// encrypted with the DEK using AES-256-GCM and the DEK itself is wrapped with
+// the tenant's key-encryption key (KEK). Only the wrapped DEK is stored next to
+// the ciphertext, so rotating or revoking a tenant key never requires touching
+// other tenants' data. The envelope names the KEK by tenant and fingerprint,
+// so a replaced key can still open what it sealed while it is kept as a
+// retired key.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/copyleftdev/goscry/internal/config"
+)
+
+// DefaultTenant is the key used when a caller does not specify a tenant or the
+// tenant has no dedicated key configured.
+const DefaultTenant = "default"
+
+const envelopeVersion = 1
+
+// ErrNoKey is returned when no key is available for a tenant.
+var ErrNoKey = errors.New("no encryption key configured for tenant")
+
+// Envelope is the serialized form of a sealed payload.
+type Envelope struct {
+	Version    int    `json:"v"`
+	KeyID      string `json:"kid"`
+	WrappedKey []byte `json:"wk"`
+	Nonce      []byte `json:"n"`
+	Ciphertext []byte `json:"ct"`
+}
+
+// Keyring holds the per-tenant key-encryption keys.
+type Keyring struct {
+	keys    map[string]tenantKEK // The key each tenant seals with
+	byID    map[string][]byte    // Current and retired keys by key ID
+	byOwner map[string][][]byte  // Current and retired keys of each tenant, for envelopes from before key IDs had fingerprints
+
+	requireTenantKeys bool
+	logger            *slog.Logger
+	warned            sync.Map // Tenants already warned about sealing with the default key
+}
+
+type tenantKEK struct {
+	id  string
+	key []byte
+}
+
+// KeyID names a tenant's key by its fingerprint, such as "acme/1f3a9c0d5e7b2468",
+// so envelopes record which version of the tenant's key sealed them.
+func KeyID(tenant string, key []byte) string {
+	sum := sha256.Sum256(key)
+	return tenant + "/" + hex.EncodeToString(sum[:8])
+}
+
+// NewKeyring builds a keyring from the security.encryption config section.
+// It returns nil, nil when encryption is disabled so callers can treat a nil
+// *Keyring as "store plaintext".
+func NewKeyring(cfg config.EncryptionConfig) (*Keyring, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if len(cfg.Keys) == 0 {
+		return nil, fmt.Errorf("encryption enabled but no keys configured")
+	}
+
+	k := &Keyring{
+		keys:              make(map[string]tenantKEK, len(cfg.Keys)),
+		byID:              make(map[string][]byte),
+		byOwner:           make(map[string][][]byte),
+		requireTenantKeys: cfg.RequireTenantKeys,
+	}
+	for tenant, encoded := range cfg.Keys {
+		key, err := decodeKey(tenant, encoded)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[tenant] = tenantKEK{id: KeyID(tenant, key), key: key}
+		k.add(tenant, key)
+	}
+	for tenant, retired := range cfg.RetiredKeys {
+		for _, encoded := range retired {
+			key, err := decodeKey(tenant, encoded)
+			if err != nil {
+				return nil, fmt.Errorf("retired %w", err)
+			}
+			k.add(tenant, key)
+		}
+	}
+	return k, nil
+}
+
+func decodeKey(tenant, encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("key for tenant %q is not valid base64: %w", tenant, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key for tenant %q must be 32 bytes, got %d", tenant, len(key))
+	}
+	return key, nil
+}
+
+func (k *Keyring) add(tenant string, key []byte) {
+	k.byID[KeyID(tenant, key)] = key
+	k.byOwner[tenant] = append(k.byOwner[tenant], key)
+}
+
+// SetLogger sets where sealing with the default key for want of a
+// tenant's own is reported.
+func (k *Keyring) SetLogger(logger *slog.Logger) {
+	k.logger = logger
+}
+
+// keyFor returns the KEK for tenant. A tenant without its own key uses the
+// default key, which is logged once per tenant, unless tenant keys are required.
+func (k *Keyring) keyFor(tenant string) (tenantKEK, error) {
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	if kek, ok := k.keys[tenant]; ok {
+		return kek, nil
+	}
+	kek, ok := k.keys[DefaultTenant]
+	if !ok || k.requireTenantKeys {
+		return tenantKEK{}, fmt.Errorf("%w: %s", ErrNoKey, tenant)
+	}
+	if _, warned := k.warned.LoadOrStore(tenant, true); !warned && k.logger != nil {
+		k.logger.Warn("Tenant has no encryption key, sealing its data with the default key", "tenant", tenant)
+	}
+	return kek, nil
+}
+
+type tenantKey struct{}
+
+// WithTenant returns ctx carrying the tenant whose key seals data written under it.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant WithTenant attached to ctx, or
+// DefaultTenant if there is none.
+func TenantFromContext(ctx context.Context) string {
+	if tenant, _ := ctx.Value(tenantKey{}).(string); tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// Seal encrypts plaintext for tenant and returns the serialized envelope.
+func (k *Keyring) Seal(tenant string, plaintext []byte) ([]byte, error) {
+	kek, err := k.keyFor(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err := gcmSeal(kek.key, dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	sealed, err := gcmSeal(dek, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt payload: %w", err)
+	}
+
+	return json.Marshal(Envelope{
+		Version:    envelopeVersion,
+		KeyID:      kek.id,
+		WrappedKey: wrapped,
+		Nonce:      sealed[:12],
+		Ciphertext: sealed[12:],
+	})
+}
+
+// Open decrypts an envelope produced by Seal with the current or a retired
+// key. Envelopes that name only a tenant, as older versions wrote them, are
+// tried with each of its keys.
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+	if env.Version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", env.Version)
+	}
+
+	candidates := k.byOwner[env.KeyID]
+	if kek, ok := k.byID[env.KeyID]; ok {
+		candidates = [][]byte{kek}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoKey, env.KeyID)
+	}
+
+	var dek []byte
+	var err error
+	for _, kek := range candidates {
+		if dek, err = gcmOpen(kek, env.WrappedKey); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	plaintext, err := gcmOpen(dek, append(append([]byte{}, env.Nonce...), env.Ciphertext...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+	return plaintext, nil
+}
+
+// SealJSON marshals v and seals the result for tenant.
+func (k *Keyring) SealJSON(tenant string, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return k.Seal(tenant, data)
+}
+
+// OpenJSON opens an envelope and unmarshals the plaintext into v.
+func (k *Keyring) OpenJSON(data []byte, v interface{}) error {
+	plaintext, err := k.Open(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, v)
+}
+
+// gcmSeal encrypts plaintext with key and returns nonce||ciphertext.
+func gcmSeal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// gcmOpen decrypts nonce||ciphertext produced by gcmSeal.
+func gcmOpen(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
@@ -0,0 +1,155 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(t *testing.T) string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestNewKeyring_Disabled(t *testing.T) {
+	kr, err := NewKeyring(config.EncryptionConfig{Enabled: false})
+	assert.NoError(t, err)
+	assert.Nil(t, kr)
+}
+
+func TestNewKeyring_InvalidKey(t *testing.T) {
+	_, err := NewKeyring(config.EncryptionConfig{
+		Enabled: true,
+		Keys:    map[string]string{"default": base64.StdEncoding.EncodeToString([]byte("too-short"))},
+	})
+	assert.Error(t, err)
+}
+
+func TestKeyring_SealOpen(t *testing.T) {
+	kr, err := NewKeyring(config.EncryptionConfig{
+		Enabled: true,
+		Keys: map[string]string{
+			"default": testKey(t),
+			"acme":    testKey(t),
+		},
+	})
+	require.NoError(t, err)
+
+	plaintext := []byte(`{"email":"user@example.com"}`)
+
+	sealed, err := kr.Seal("acme", plaintext)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(sealed, plaintext))
+
+	opened, err := kr.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+
+	// Unknown tenants fall back to the default key
+	sealed, err = kr.Seal("unknown", plaintext)
+	require.NoError(t, err)
+	opened, err = kr.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+}
+
+func TestKeyring_OpenWithWrongKey(t *testing.T) {
+	cfg := config.EncryptionConfig{Enabled: true, Keys: map[string]string{"default": testKey(t)}}
+	kr1, err := NewKeyring(cfg)
+	require.NoError(t, err)
+
+	cfg.Keys = map[string]string{"default": testKey(t)}
+	kr2, err := NewKeyring(cfg)
+	require.NoError(t, err)
+
+	sealed, err := kr1.Seal("", []byte("secret"))
+	require.NoError(t, err)
+
+	_, err = kr2.Open(sealed)
+	assert.Error(t, err)
+}
+
+func TestKeyring_RetiredKeys(t *testing.T) {
+	oldKey, newKey := testKey(t), testKey(t)
+	old, err := NewKeyring(config.EncryptionConfig{Enabled: true, Keys: map[string]string{"acme": oldKey}})
+	require.NoError(t, err)
+	sealedBefore, err := old.Seal("acme", []byte("before"))
+	require.NoError(t, err)
+	legacy, err := json.Marshal(func() Envelope {
+		var env Envelope
+		require.NoError(t, json.Unmarshal(sealedBefore, &env))
+		env.KeyID = "acme" // As envelopes were written before key IDs had fingerprints
+		return env
+	}())
+	require.NoError(t, err)
+
+	rotated, err := NewKeyring(config.EncryptionConfig{
+		Enabled:     true,
+		Keys:        map[string]string{"acme": newKey},
+		RetiredKeys: map[string][]string{"acme": {oldKey}},
+	})
+	require.NoError(t, err)
+	for _, sealed := range [][]byte{sealedBefore, legacy} {
+		opened, err := rotated.Open(sealed)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("before"), opened)
+	}
+
+	sealedAfter, err := rotated.Seal("acme", []byte("after"))
+	require.NoError(t, err)
+	var env Envelope
+	require.NoError(t, json.Unmarshal(sealedAfter, &env))
+	decoded, _ := base64.StdEncoding.DecodeString(newKey)
+	assert.Equal(t, KeyID("acme", decoded), env.KeyID)
+	_, err = old.Open(sealedAfter)
+	assert.ErrorIs(t, err, ErrNoKey, "the old keyring does not have the new key")
+
+	withoutRetired, err := NewKeyring(config.EncryptionConfig{Enabled: true, Keys: map[string]string{"acme": newKey}})
+	require.NoError(t, err)
+	_, err = withoutRetired.Open(sealedBefore)
+	assert.ErrorIs(t, err, ErrNoKey)
+	_, err = withoutRetired.Open(legacy)
+	assert.Error(t, err)
+}
+
+func TestKeyring_RequireTenantKeys(t *testing.T) {
+	kr, err := NewKeyring(config.EncryptionConfig{
+		Enabled: true, RequireTenantKeys: true,
+		Keys: map[string]string{"default": testKey(t), "acme": testKey(t)},
+	})
+	require.NoError(t, err)
+	_, err = kr.Seal("acme", []byte("secret"))
+	assert.NoError(t, err)
+	_, err = kr.Seal("", []byte("secret"))
+	assert.NoError(t, err, "data without a tenant still uses the default key")
+	_, err = kr.Seal("unknown", []byte("secret"))
+	assert.ErrorIs(t, err, ErrNoKey)
+}
+
+func TestTenantFromContext(t *testing.T) {
+	assert.Equal(t, DefaultTenant, TenantFromContext(context.Background()))
+	assert.Equal(t, DefaultTenant, TenantFromContext(WithTenant(context.Background(), "")))
+	assert.Equal(t, "acme", TenantFromContext(WithTenant(context.Background(), "acme")))
+}
+
+func TestKeyring_SealJSON(t *testing.T) {
+	kr, err := NewKeyring(config.EncryptionConfig{Enabled: true, Keys: map[string]string{"default": testKey(t)}})
+	require.NoError(t, err)
+
+	in := map[string]string{"title": "Example Domain"}
+	sealed, err := kr.SealJSON("", in)
+	require.NoError(t, err)
+
+	var out map[string]string
+	require.NoError(t, kr.OpenJSON(sealed, &out))
+	assert.Equal(t, in, out)
+}
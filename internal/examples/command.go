@@ -0,0 +1,189 @@
+package examples
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+const usage = `usage: goscry examples list
+       goscry examples run [flags] <name>`
+
+// Command runs "goscry examples". "list" prints the gallery; "run" submits
+// an example to a running server, waits for it to finish, and prints the
+// task as JSON. It returns an error if the task did not complete.
+func Command(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "list":
+		return list(stdout)
+	case "run":
+		return run(ctx, args[1:], stdout)
+	}
+	return fmt.Errorf("unknown examples command %q\n%s", args[0], usage)
+}
+
+func list(stdout io.Writer) error {
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, example := range gallery {
+		fmt.Fprintf(w, "%s\t%s\n", example.Name, example.Title)
+	}
+	return w.Flush()
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("examples run", flag.ContinueOnError)
+	server := flags.String("server", envOr("GOSCRY_SERVER", "http://localhost:8080"), "GoScry server URL")
+	apiKey := flags.String("key", os.Getenv("GOSCRY_API_KEY"), "API key or JWT")
+	username := flags.String("username", os.Getenv("GOSCRY_EXAMPLE_USERNAME"), "username for examples that log in; the password is read from GOSCRY_EXAMPLE_PASSWORD")
+	timeout := flags.Duration("timeout", 5*time.Minute, "how long to wait for the task")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+	example, ok := Get(flags.Arg(0))
+	if !ok {
+		return fmt.Errorf("no example named %q; see goscry examples list", flags.Arg(0))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	c := &client{base: strings.TrimSuffix(*server, "/") + "/api/v1", key: *apiKey}
+
+	req := map[string]interface{}{}
+	if example.Credentials {
+		password := os.Getenv("GOSCRY_EXAMPLE_PASSWORD")
+		if *username == "" || password == "" {
+			return fmt.Errorf("example %s logs in; set -username and GOSCRY_EXAMPLE_PASSWORD", example.Name)
+		}
+		sealed, err := c.sealCredentials(ctx, *username, password)
+		if err != nil {
+			return err
+		}
+		req["encrypted_credentials"] = sealed
+	}
+	var submitted struct {
+		TaskID   string   `json:"task_id"`
+		Warnings []string `json:"warnings"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/examples/"+url.PathEscape(example.Name)+"/run", req, &submitted); err != nil {
+		return err
+	}
+
+	task, err := c.wait(ctx, submitted.TaskID)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, string(out))
+	if task.Status != taskstypes.StatusCompleted {
+		return fmt.Errorf("task %s %s", task.ID, task.Status)
+	}
+	return nil
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// client calls the GoScry API.
+type client struct {
+	base string
+	key  string
+}
+
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.key != "" {
+		req.Header.Set("X-API-Key", c.key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, apiErr.Error)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// sealCredentials encrypts the credentials to the server's credential key,
+// the only way the API accepts them.
+func (c *client) sealCredentials(ctx context.Context, username, password string) (*taskstypes.SealedCredentials, error) {
+	var key struct {
+		KeyID     string `json:"key_id"`
+		PublicKey string `json:"public_key"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/credentials/key", nil, &key); err != nil {
+		return nil, err
+	}
+	return encryption.SealCredentials(key.KeyID, []byte(key.PublicKey), username, password)
+}
+
+// wait polls the task until it finishes, holding each request open until
+// its status changes.
+func (c *client) wait(ctx context.Context, taskID string) (*taskstypes.Task, error) {
+	var status taskstypes.TaskStatus
+	for {
+		path := "/tasks/" + url.PathEscape(taskID) + "?full=true"
+		if status != "" {
+			path += "&wait=30s&status=" + url.QueryEscape(string(status))
+		}
+		var task taskstypes.Task
+		if err := c.do(ctx, http.MethodGet, path, nil, &task); err != nil {
+			return nil, err
+		}
+		if task.Status.IsTerminal() {
+			return &task, nil
+		}
+		status = task.Status
+	}
+}
@@ -0,0 +1,79 @@
+// Package examples holds the gallery of runnable example tasks served at
+// /api/v1/examples and run by "goscry examples run <name>".
+package examples
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// Example is a ready-made task definition.
+type Example struct {
+	Name        string              `json:"name"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Credentials bool                `json:"credentials,omitempty"` // Must be run with credentials, e.g. for a login action
+	Actions     []taskstypes.Action `json:"actions"`
+}
+
+//go:embed recipes/*.json
+var recipeFiles embed.FS
+
+// gallery is every example, sorted by name.
+var gallery = mustLoad()
+
+func mustLoad() []Example {
+	examples, err := load()
+	if err != nil {
+		panic(err) // The recipes are embedded at build time and checked by the tests
+	}
+	return examples
+}
+
+func load() ([]Example, error) {
+	entries, err := recipeFiles.ReadDir("recipes")
+	if err != nil {
+		return nil, err
+	}
+	examples := make([]Example, 0, len(entries))
+	for _, entry := range entries {
+		data, err := recipeFiles.ReadFile(path.Join("recipes", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var example Example
+		if err := json.Unmarshal(data, &example); err != nil {
+			return nil, fmt.Errorf("example %s: %w", entry.Name(), err)
+		}
+		if want := strings.TrimSuffix(entry.Name(), ".json"); example.Name != want {
+			return nil, fmt.Errorf("example %s is named %q", entry.Name(), example.Name)
+		}
+		if len(example.Actions) == 0 {
+			return nil, fmt.Errorf("example %s has no actions", example.Name)
+		}
+		examples = append(examples, example)
+	}
+	sort.Slice(examples, func(i, j int) bool { return examples[i].Name < examples[j].Name })
+	return examples, nil
+}
+
+// All returns every example, sorted by name.
+func All() []Example {
+	return append([]Example(nil), gallery...)
+}
+
+// Get returns the example with the given name.
+func Get(name string) (Example, bool) {
+	for _, example := range gallery {
+		if example.Name == name {
+			return example, true
+		}
+	}
+	return Example{}, false
+}
@@ -0,0 +1,86 @@
+package examples
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGallery(t *testing.T) {
+	examples, err := load()
+	require.NoError(t, err)
+
+	var names []string
+	for _, example := range examples {
+		names = append(names, example.Name)
+		assert.NotEmpty(t, example.Title, example.Name)
+		assert.NotEmpty(t, example.Description, example.Name)
+		for i, action := range example.Actions {
+			if action.Type == taskstypes.ActionNavigate || action.Type == taskstypes.ActionDownload {
+				_, warnings, err := taskstypes.NormalizeURL(action.Value, taskstypes.DefaultURLSchemes)
+				assert.NoError(t, err, "%s action %d", example.Name, i)
+				assert.Empty(t, warnings, "%s action %d", example.Name, i)
+			}
+		}
+	}
+	assert.Equal(t, []string{"crawl", "login-scrape", "monitor", "pdf-capture"}, names)
+
+	example, ok := Get("login-scrape")
+	require.True(t, ok)
+	assert.True(t, example.Credentials)
+	_, ok = Get("missing")
+	assert.False(t, ok)
+}
+
+func TestCommand_List(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Command(context.Background(), []string{"list"}, &out))
+	assert.Contains(t, out.String(), "monitor")
+	assert.Contains(t, out.String(), "Monitor a product page")
+
+	assert.Error(t, Command(context.Background(), nil, &out))
+	assert.Error(t, Command(context.Background(), []string{"publish"}, &out))
+}
+
+func TestCommand_Run(t *testing.T) {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/examples/monitor/run":
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"task_id": "7d444840-9dc0-11d1-b245-5ffdce74fad2"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/tasks/7d444840-9dc0-11d1-b245-5ffdce74fad2":
+			polls++
+			status := taskstypes.StatusRunning
+			if polls > 1 {
+				assert.Equal(t, "running", r.URL.Query().Get("status"))
+				status = taskstypes.StatusCompleted
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "7d444840-9dc0-11d1-b245-5ffdce74fad2", "status": status})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	err := Command(context.Background(), []string{"run", "-server", srv.URL, "-key", "secret", "monitor"}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 2, polls)
+	assert.Contains(t, out.String(), `"status": "completed"`)
+
+	err = Command(context.Background(), []string{"run", "-server", srv.URL, "missing"}, &out)
+	assert.ErrorContains(t, err, "no example named")
+
+	t.Setenv("GOSCRY_EXAMPLE_PASSWORD", "")
+	err = Command(context.Background(), []string{"run", "-server", srv.URL, "-username", "ops", "login-scrape"}, &out)
+	assert.ErrorContains(t, err, "GOSCRY_EXAMPLE_PASSWORD")
+}
@@ -0,0 +1,325 @@
+package importer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// curlValueFlags are the cURL options that take a value, by long name, with
+// their short forms mapped to the same names.
+var curlValueFlags = map[string]string{
+	"-X": "--request", "-H": "--header", "-d": "--data", "-b": "--cookie", "-A": "--user-agent",
+	"-e": "--referer", "-u": "--user", "-x": "--proxy", "-o": "--output", "-m": "--max-time",
+	"-w": "--write-out", "-F": "--form", "-T": "--upload-file", "-U": "--proxy-user", "-c": "--cookie-jar",
+	"--request": "", "--header": "", "--data": "", "--data-raw": "", "--data-binary": "", "--data-ascii": "",
+	"--data-urlencode": "", "--json": "", "--cookie": "", "--user-agent": "", "--referer": "", "--user": "",
+	"--proxy": "", "--url": "", "--output": "", "--max-time": "", "--connect-timeout": "", "--retry": "",
+	"--write-out": "", "--form": "", "--upload-file": "", "--proxy-user": "", "--cookie-jar": "",
+	"--resolve": "", "--cacert": "", "--cert": "", "--key": "",
+}
+
+// curlIgnoredFlags change how cURL itself behaves and have no browser equivalent worth a warning.
+var curlIgnoredFlags = map[string]bool{
+	"-s": true, "-S": true, "-L": true, "-i": true, "-v": true, "-f": true, "-#": true,
+	"--silent": true, "--show-error": true, "--location": true, "--include": true, "--verbose": true,
+	"--fail": true, "--compressed": true, "--http1.1": true, "--http2": true, "--http2-prior-knowledge": true,
+	"--progress-bar": true, "--no-progress-meter": true, "--globoff": true, "-g": true,
+	"--output": true, "--write-out": true, "--max-time": true, "--connect-timeout": true, "--retry": true,
+	"--cookie-jar": true,
+}
+
+// Curl converts a cURL command, such as one copied from a browser's network
+// panel, into a task that makes the same request.
+func Curl(command string) (*Result, error) {
+	args, err := shellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, errors.New("not a curl command")
+	}
+
+	res := &Result{}
+	var r request
+	var data []string
+	get, head := false, false
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := arg, "", false
+		switch {
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			if r.URL != "" {
+				return nil, fmt.Errorf("curl command has more than one URL (%s and %s)", r.URL, arg)
+			}
+			r.URL = arg
+			continue
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue = strings.Cut(arg, "=")
+		case len(arg) > 2:
+			// Short options may be combined (-sSL) or carry their value (-XPOST)
+			name = arg[:2]
+			if _, takesValue := curlValueFlags[name]; takesValue {
+				value, hasValue = arg[2:], true
+			} else {
+				args = slices.Insert(args, i+1, "-"+arg[2:])
+			}
+		}
+		if long := curlValueFlags[name]; long != "" {
+			name = long
+		}
+		if _, takesValue := curlValueFlags[name]; takesValue && !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("curl option %s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "--url":
+			r.URL = value
+		case "--request":
+			r.Method = value
+		case "--header":
+			if header, v, ok := strings.Cut(value, ":"); ok {
+				r.Headers = append(r.Headers, [2]string{strings.TrimSpace(header), strings.TrimSpace(v)})
+			} else {
+				res.warnf("ignored curl header %q", value)
+			}
+		case "--data", "--data-ascii", "--data-binary":
+			if strings.HasPrefix(value, "@") {
+				res.warnf("curl %s reads %s, which cannot be imported; add the body to the run_script action", name, value)
+				continue
+			}
+			if name != "--data-binary" {
+				value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+			}
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, value)
+		case "--data-urlencode":
+			data = append(data, curlURLEncode(value))
+		case "--json":
+			data = append(data, value)
+			r.Headers = append(r.Headers, [2]string{"Content-Type", "application/json"}, [2]string{"Accept", "application/json"})
+		case "--cookie":
+			if strings.Contains(value, "=") {
+				r.Headers = append(r.Headers, [2]string{"Cookie", value})
+			} else {
+				res.warnf("curl --cookie %s reads a cookie file, which cannot be imported", value)
+			}
+		case "--user-agent":
+			r.Headers = append(r.Headers, [2]string{"User-Agent", value})
+		case "--referer":
+			r.Headers = append(r.Headers, [2]string{"Referer", value})
+		case "--user":
+			r.Headers = append(r.Headers, [2]string{"Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(value))})
+			res.warnf("curl --user is imported as an Authorization header in options.headers; keep the task definition private")
+		case "--proxy":
+			proxy, err := curlProxy(value)
+			if err != nil {
+				return nil, err
+			}
+			res.Options.Proxy = proxy
+		case "--get", "-G":
+			get = true
+		case "--head", "-I":
+			head = true
+		case "--insecure", "-k":
+			res.warnf("curl --insecure is ignored; the browser still checks certificates")
+		case "--form":
+			res.warnf("curl --form is not supported; multipart bodies must be built in a run_script action")
+		default:
+			if !curlIgnoredFlags[name] {
+				res.warnf("ignored curl option %s", name)
+			}
+		}
+	}
+	if r.URL == "" {
+		return nil, errors.New("curl command has no URL")
+	}
+	if !strings.Contains(r.URL, "://") {
+		r.URL = "http://" + r.URL
+	}
+
+	body := strings.Join(data, "&")
+	switch {
+	case get && body != "":
+		sep := "?"
+		if strings.Contains(r.URL, "?") {
+			sep = "&"
+		}
+		r.URL += sep + body
+	case body != "":
+		r.Body = body
+		if r.Method == "" {
+			r.Method = "POST"
+		}
+		if !hasHeader(r.Headers, "Content-Type") {
+			r.Headers = append(r.Headers, [2]string{"Content-Type", "application/x-www-form-urlencoded"})
+		}
+	}
+	if head && r.Method == "" {
+		r.Method = "HEAD"
+	}
+	if err := res.apply(r); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func hasHeader(headers [][2]string, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h[0], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// curlURLEncode applies curl --data-urlencode to value: "content",
+// "=content", or "name=content", encoding only the content.
+func curlURLEncode(value string) string {
+	name, content, ok := strings.Cut(value, "=")
+	if !ok {
+		return url.QueryEscape(value)
+	}
+	if name == "" {
+		return url.QueryEscape(content)
+	}
+	return name + "=" + url.QueryEscape(content)
+}
+
+// curlProxy reads curl --proxy. A password in the proxy URL is left out,
+// since it would be dropped from the task definition anyway.
+func curlProxy(value string) (*taskstypes.ProxySettings, error) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid curl --proxy %q", value)
+	}
+	proxy := &taskstypes.ProxySettings{Server: u.Scheme + "://" + u.Host}
+	if u.User != nil {
+		proxy.Username = u.User.Username()
+	}
+	return proxy, nil
+}
+
+// shellWords splits a POSIX shell command line the way bash would for the
+// commands browsers produce with "Copy as cURL": quotes, $'...' strings,
+// backslash escapes, and line continuations.
+func shellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '\n' || s[i+1] == '\r'):
+			i++
+			if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case c == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated ' in command")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := ansiCString(s[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New(`unterminated " in command`)
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// ansiCString decodes the body of a bash $'...' string from s into word and
+// returns how many bytes it used, including the closing quote.
+func ansiCString(s string, word *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			word.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			word.WriteByte('\n')
+		case 't':
+			word.WriteByte('\t')
+		case 'r':
+			word.WriteByte('\r')
+		case 'x', 'u', 'U':
+			digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+			j := i + 1
+			for j < len(s) && j < i+1+digits && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+				j++
+			}
+			n, err := strconv.ParseUint(s[i+1:j], 16, 32)
+			if err != nil {
+				return 0, fmt.Errorf("invalid escape \\%s in $'...' string", s[i:j])
+			}
+			if s[i] == 'x' {
+				word.WriteByte(byte(n))
+			} else {
+				word.WriteRune(rune(n))
+			}
+			i = j - 1
+		default:
+			// \\, \', \", and anything else stand for the character itself
+			word.WriteByte(s[i])
+		}
+	}
+	return 0, errors.New("unterminated $' in command")
+}
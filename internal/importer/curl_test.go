@@ -0,0 +1,72 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellWords(t *testing.T) {
+	words, err := shellWords(`curl 'https://a.example/x?q=1' \
+  -H "X-Token: \"quoted\" \$HOME" --data-raw $'{"a":"it\'s\\né"}' plain\ word`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"curl", "https://a.example/x?q=1", "-H", `X-Token: "quoted" $HOME`, "--data-raw", `{"a":"it's\né"}`, "plain word"}, words)
+
+	_, err = shellWords(`curl 'https://a.example`)
+	assert.Error(t, err)
+}
+
+func TestCurl_Get(t *testing.T) {
+	// As copied from Chrome's network panel
+	res, err := Curl(`curl 'https://shop.example/orders?page=2' \
+  -H 'accept: text/html' \
+  -H 'accept-language: de-DE,de;q=0.9' \
+  -b 'sid=abc; theme=dark' \
+  -H 'sec-ch-ua-mobile: ?0' \
+  -H 'user-agent: Mozilla/5.0 Test' \
+  -H 'X-Tenant: acme' \
+  --compressed`)
+	require.NoError(t, err)
+	assert.Equal(t, []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://shop.example/orders?page=2"}}, res.Actions)
+	assert.Equal(t, "Mozilla/5.0 Test", res.Options.UserAgent)
+	assert.Equal(t, "de-DE,de;q=0.9", res.Options.AcceptLanguage)
+	assert.Equal(t, map[string]string{"Cookie": "sid=abc; theme=dark", "X-Tenant": "acme"}, res.Options.Headers)
+	assert.Empty(t, res.Warnings)
+}
+
+func TestCurl_Post(t *testing.T) {
+	res, err := Curl(`curl -sSL -XPUT https://api.example/items/7 -H 'Content-Type: application/json' -d '{"name":"x"}' -u ops:secret -k`)
+	require.NoError(t, err)
+	require.Len(t, res.Actions, 2)
+	assert.Equal(t, taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://api.example/"}, res.Actions[0])
+	assert.Equal(t, taskstypes.ActionRunScript, res.Actions[1].Type)
+	assert.Equal(t, `(() => { const xhr = new XMLHttpRequest(); xhr.open("PUT", "https://api.example/items/7", false); `+
+		`xhr.setRequestHeader("Content-Type", "application/json"); xhr.send("{\"name\":\"x\"}"); `+
+		`return { status: xhr.status, body: xhr.responseText }; })()`, res.Actions[1].Value)
+	assert.Equal(t, map[string]string{"Authorization": "Basic b3BzOnNlY3JldA=="}, res.Options.Headers)
+	assert.Len(t, res.Warnings, 3, "--user, --insecure, and the XMLHttpRequest")
+}
+
+func TestCurl_Data(t *testing.T) {
+	res, err := Curl(`curl example.com/search -G -d q=go --data-urlencode 'tag=a&b'`)
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/search?q=go&tag=a%26b", res.Actions[0].Value)
+
+	res, err = Curl(`curl https://example.com/login -d user=ops -d pass=x`)
+	require.NoError(t, err)
+	assert.Contains(t, res.Actions[1].Value, `xhr.open("POST"`)
+	assert.Contains(t, res.Actions[1].Value, `"application/x-www-form-urlencoded"`)
+	assert.Contains(t, res.Actions[1].Value, `xhr.send("user=ops&pass=x")`)
+
+	res, err = Curl(`curl -x http://me:pw@proxy.internal:3128 --frobnicate https://example.com/`)
+	require.NoError(t, err)
+	assert.Equal(t, &taskstypes.ProxySettings{Server: "http://proxy.internal:3128", Username: "me"}, res.Options.Proxy)
+	assert.Equal(t, []string{"ignored curl option --frobnicate"}, res.Warnings)
+
+	for _, bad := range []string{`wget https://example.com/`, `curl -s`, `curl ftp://example.com/f`, `curl -H`} {
+		_, err := Curl(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,131 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// harEntry is the part of a HAR 1.2 entry the importer reads.
+type harEntry struct {
+	Request struct {
+		Method   string `json:"method"`
+		URL      string `json:"url"`
+		Headers  []struct{ Name, Value string }
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+	} `json:"response"`
+	ResourceType string `json:"_resourceType"` // Set by Chrome DevTools exports
+}
+
+// document reports whether the entry loaded a page rather than a resource of one.
+func (e harEntry) document() bool {
+	if e.ResourceType != "" {
+		return e.ResourceType == "document"
+	}
+	return strings.HasPrefix(e.Response.Content.MimeType, "text/html")
+}
+
+func (e harEntry) redirect() bool {
+	switch e.Response.Status {
+	case 301, 302, 303, 307, 308:
+		return true
+	}
+	return false
+}
+
+func (e harEntry) request() request {
+	r := request{Method: e.Request.Method, URL: e.Request.URL}
+	for _, h := range e.Request.Headers {
+		r.Headers = append(r.Headers, [2]string{h.Name, h.Value})
+	}
+	if pd := e.Request.PostData; pd != nil {
+		r.Body = pd.Text
+		if pd.MimeType != "" && !hasHeader(r.Headers, "Content-Type") {
+			r.Headers = append(r.Headers, [2]string{"Content-Type", pd.MimeType})
+		}
+	}
+	return r
+}
+
+// HAR converts a HAR capture into a task. A whole archive becomes one
+// navigation per page load, leaving out the resources each page fetches by
+// itself and the hops of redirects; a single entry ({"request": ...})
+// becomes that request, as with Curl.
+func HAR(data []byte) (*Result, error) {
+	var doc struct {
+		Log *struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+		Request *json.RawMessage `json:"request"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+
+	res := &Result{}
+	switch {
+	case doc.Request != nil:
+		var entry harEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("invalid HAR entry: %w", err)
+		}
+		if err := res.applyHAR(entry); err != nil {
+			return nil, err
+		}
+	case doc.Log != nil:
+		skipped, redirected := 0, false
+		for _, entry := range doc.Log.Entries {
+			if !entry.document() {
+				skipped++
+				continue
+			}
+			// The browser follows a redirect itself, so its target is not loaded again
+			if redirected {
+				redirected = entry.redirect()
+				continue
+			}
+			redirected = entry.redirect()
+			if err := res.applyHAR(entry); err != nil {
+				return nil, err
+			}
+		}
+		if len(res.Actions) == 0 {
+			return nil, errors.New("HAR has no page loads to import")
+		}
+		if skipped > 0 {
+			res.warnf("left out %d requests for page resources, which the pages load themselves", skipped)
+		}
+	default:
+		return nil, errors.New(`HAR must be an archive ({"log": ...}) or a single entry ({"request": ...})`)
+	}
+	return res, nil
+}
+
+// applyHAR imports one entry. Captured cookies are left out: replaying them
+// as a fixed header would override the cookies the task's own pages set.
+func (res *Result) applyHAR(entry harEntry) error {
+	r := entry.request()
+	headers := r.Headers[:0]
+	for _, h := range r.Headers {
+		if strings.EqualFold(h[0], "Cookie") {
+			if !res.cookiesDropped {
+				res.cookiesDropped = true
+				res.warnf("cookies in the HAR are not imported; run the task in a logged-in session or profile instead")
+			}
+			continue
+		}
+		headers = append(headers, h)
+	}
+	r.Headers = headers
+	return res.apply(r)
+}
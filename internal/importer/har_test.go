@@ -0,0 +1,53 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testHAR = `{"log": {"version": "1.2", "entries": [
+  {"_resourceType": "document", "request": {"method": "GET", "url": "https://shop.example/",
+    "headers": [{"name": ":authority", "value": "shop.example"}, {"name": "user-agent", "value": "UA/1"}, {"name": "cookie", "value": "sid=1"}]},
+    "response": {"status": 302, "content": {"mimeType": "text/html"}}},
+  {"_resourceType": "document", "request": {"method": "GET", "url": "https://shop.example/home", "headers": []},
+    "response": {"status": 200, "content": {"mimeType": "text/html"}}},
+  {"_resourceType": "stylesheet", "request": {"method": "GET", "url": "https://shop.example/app.css", "headers": []},
+    "response": {"status": 200, "content": {"mimeType": "text/css"}}},
+  {"request": {"method": "GET", "url": "https://cdn.example/logo.png", "headers": []},
+    "response": {"status": 200, "content": {"mimeType": "image/png"}}},
+  {"request": {"method": "POST", "url": "https://shop.example/cart", "headers": [{"name": "Cookie", "value": "sid=2"}],
+    "postData": {"mimeType": "application/x-www-form-urlencoded", "text": "sku=42"}},
+    "response": {"status": 200, "content": {"mimeType": "text/html; charset=utf-8"}}},
+  {"_resourceType": "document", "request": {"method": "GET", "url": "https://shop.example/cart", "headers": []},
+    "response": {"status": 304, "content": {"mimeType": ""}}}
+]}}`
+
+func TestHAR_Archive(t *testing.T) {
+	res, err := HAR([]byte(testHAR))
+	require.NoError(t, err)
+	require.Len(t, res.Actions, 4)
+	assert.Equal(t, taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://shop.example/"}, res.Actions[0], "the redirect target is not loaded again")
+	assert.Equal(t, taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://shop.example/"}, res.Actions[1], "the form post is sent from its origin")
+	assert.Equal(t, taskstypes.ActionRunScript, res.Actions[2].Type)
+	assert.Contains(t, res.Actions[2].Value, `xhr.send("sku=42")`)
+	assert.Equal(t, taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "https://shop.example/cart"}, res.Actions[3])
+	assert.Equal(t, "UA/1", res.Options.UserAgent)
+	assert.Empty(t, res.Options.Headers, "cookies are not replayed")
+	assert.Contains(t, res.Warnings, "left out 2 requests for page resources, which the pages load themselves")
+	assert.Len(t, res.Warnings, 3, "cookies once, the XMLHttpRequest, and the resources")
+}
+
+func TestHAR_Entry(t *testing.T) {
+	res, err := HAR([]byte(`{"request": {"method": "GET", "url": "https://api.example/me", "headers": [{"name": "Authorization", "value": "Bearer t"}]}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://api.example/me"}}, res.Actions)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer t"}, res.Options.Headers)
+
+	for _, bad := range []string{`{}`, `[1]`, `{"log": {"entries": []}}`, `{"request": {"url": "/relative"}}`} {
+		_, err := HAR([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,177 @@
+// Package importer converts requests and scripts written for other tools
+// (cURL commands, HAR captures, and Playwright or Puppeteer scripts) into
+// GoScry action lists, so ad-hoc scripts can become managed tasks.
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// Format names what an import source was written for.
+type Format string
+
+const (
+	FormatCurl       Format = "curl"
+	FormatHAR        Format = "har"
+	FormatPlaywright Format = "playwright"
+	FormatPuppeteer  Format = "puppeteer"
+)
+
+// Result is an imported task: the actions and options to submit, and notes
+// on anything that could not be carried over exactly.
+type Result struct {
+	Actions  []taskstypes.Action    `json:"actions"`
+	Options  taskstypes.TaskOptions `json:"options"`
+	Warnings []string               `json:"warnings,omitempty"`
+
+	cookiesDropped bool
+}
+
+// Import converts source written in format. An empty format is detected
+// from the source.
+func Import(format Format, source string) (*Result, error) {
+	if format == "" {
+		format = Detect(source)
+	}
+	switch Format(strings.ToLower(string(format))) {
+	case FormatCurl:
+		return Curl(source)
+	case FormatHAR:
+		return HAR([]byte(source))
+	case FormatPlaywright, FormatPuppeteer:
+		return Script(source)
+	}
+	return nil, fmt.Errorf("unknown import format %q (expected curl, har, playwright, or puppeteer)", format)
+}
+
+// Detect guesses the format of source: a command starting with curl, a JSON
+// document (HAR), or otherwise a script.
+func Detect(source string) Format {
+	trimmed := strings.TrimSpace(source)
+	switch {
+	case strings.HasPrefix(trimmed, "curl ") || strings.HasPrefix(trimmed, "curl\t"):
+		return FormatCurl
+	case json.Valid([]byte(trimmed)):
+		return FormatHAR
+	}
+	return FormatPlaywright
+}
+
+func (res *Result) warnf(format string, args ...interface{}) {
+	res.Warnings = append(res.Warnings, fmt.Sprintf(format, args...))
+}
+
+// request is one HTTP request taken from a cURL command or HAR entry.
+type request struct {
+	Method  string
+	URL     string
+	Headers [][2]string // In order, names as written
+	Body    string
+}
+
+// browserHeaders are set by the browser itself; importing them would pin
+// stale or wrong values on every request the task makes.
+var browserHeaders = map[string]bool{
+	"host": true, "content-length": true, "connection": true, "keep-alive": true,
+	"accept-encoding": true, "transfer-encoding": true, "te": true, "upgrade-insecure-requests": true,
+	"pragma": true, "cache-control": true, "priority": true, "origin": true, "dnt": true,
+}
+
+// apply appends actions that make r from the browser. A GET becomes a
+// navigation; other requests are sent with a synchronous XMLHttpRequest from
+// the target's origin, since a page cannot navigate with a body.
+func (res *Result) apply(r request) error {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid request URL %q", r.URL)
+	}
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	var scoped [][2]string // Headers that only make sense on this request
+	for _, h := range r.Headers {
+		name := strings.ToLower(h[0])
+		switch {
+		case browserHeaders[name] || strings.HasPrefix(name, "sec-") || strings.HasPrefix(name, ":"):
+		case name == "user-agent":
+			res.setOption("user_agent", &res.Options.UserAgent, h[1])
+		case name == "accept-language":
+			res.setOption("accept_language", &res.Options.AcceptLanguage, h[1])
+		case name == "content-type" || name == "accept":
+			scoped = append(scoped, h)
+		default:
+			res.setHeader(h[0], h[1])
+		}
+	}
+
+	if method == "GET" && r.Body == "" {
+		res.Actions = append(res.Actions, taskstypes.Action{Type: taskstypes.ActionNavigate, Value: u.String()})
+		return nil
+	}
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+	res.Actions = append(res.Actions,
+		taskstypes.Action{Type: taskstypes.ActionNavigate, Value: origin},
+		taskstypes.Action{Type: taskstypes.ActionRunScript, Value: xhrScript(method, u.String(), scoped, r.Body)},
+	)
+	res.warnf("%s %s is sent with a synchronous XMLHttpRequest from %s; its status and body are the run_script result", method, u.String(), origin)
+	return nil
+}
+
+// setOption fills a task option from a header, warning when two requests disagree.
+func (res *Result) setOption(name string, field *string, value string) {
+	if *field != "" && *field != value {
+		res.warnf("requests use different %s values; keeping %q", name, *field)
+		return
+	}
+	*field = value
+}
+
+// setHeader adds a header sent with every request of the task.
+func (res *Result) setHeader(name, value string) {
+	if res.Options.Headers == nil {
+		res.Options.Headers = make(map[string]string)
+	}
+	for existing, v := range res.Options.Headers {
+		if strings.EqualFold(existing, name) {
+			if v != value {
+				res.warnf("requests send different %s headers; keeping the first", existing)
+			}
+			return
+		}
+	}
+	res.Options.Headers[name] = value
+}
+
+// xhrScript is a run_script value that sends a request and returns its
+// status and body. run_script does not await promises, so it cannot use fetch.
+func xhrScript(method, target string, headers [][2]string, body string) string {
+	var b strings.Builder
+	b.WriteString("(() => { const xhr = new XMLHttpRequest(); ")
+	fmt.Fprintf(&b, "xhr.open(%s, %s, false); ", jsString(method), jsString(target))
+	for _, h := range headers {
+		fmt.Fprintf(&b, "xhr.setRequestHeader(%s, %s); ", jsString(h[0]), jsString(h[1]))
+	}
+	if body != "" {
+		fmt.Fprintf(&b, "xhr.send(%s); ", jsString(body))
+	} else {
+		b.WriteString("xhr.send(); ")
+	}
+	b.WriteString("return { status: xhr.status, body: xhr.responseText }; })()")
+	return b.String()
+}
+
+// jsString quotes s as a JavaScript string literal.
+func jsString(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
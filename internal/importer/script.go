@@ -0,0 +1,596 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp/kb"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// scriptKeys maps Playwright and Puppeteer key names to what a type action sends.
+var scriptKeys = map[string]string{
+	"Enter": kb.Enter, "Tab": kb.Tab, "Escape": kb.Escape, "Backspace": kb.Backspace,
+	"Delete": kb.Delete, "ArrowUp": kb.ArrowUp, "ArrowDown": kb.ArrowDown, "ArrowLeft": kb.ArrowLeft,
+	"ArrowRight": kb.ArrowRight, "Home": kb.Home, "End": kb.End, "PageUp": kb.PageUp,
+	"PageDown": kb.PageDown, "Space": " ",
+}
+
+// jsCall is one step of a call chain such as page.locator('#q').fill('x').
+type jsCall struct {
+	name   string
+	args   []string // Source text of each argument
+	called bool     // False for a property access such as page.keyboard
+}
+
+// scriptImport carries the state of one Script conversion.
+type scriptImport struct {
+	res  *Result
+	line int
+}
+
+func (s *scriptImport) add(action taskstypes.Action) {
+	s.res.Actions = append(s.res.Actions, action)
+}
+
+func (s *scriptImport) warnf(format string, args ...interface{}) {
+	s.res.warnf("line %d: %s", s.line, fmt.Sprintf(format, args...))
+}
+
+// Script converts a Playwright or Puppeteer script into a task. It reads the
+// calls made on a variable named page in the order they appear and maps the
+// common ones (goto, click, fill, type, press, selectOption, waitForSelector,
+// evaluate, screenshot, and their locator forms) to actions. Control flow is
+// not followed, so loops are imported once and both branches of an if are
+// imported in sequence.
+func Script(src string) (*Result, error) {
+	code := blankComments(src)
+	s := &scriptImport{res: &Result{}}
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipString(code, i)
+		case isIdentStart(c):
+			start := i
+			for i < len(code) && isIdentPart(code[i]) {
+				i++
+			}
+			if code[start:i] != "page" || (start > 0 && prevNonSpace(code, start) == '.') {
+				continue
+			}
+			calls, end := parseChain(code, i)
+			if len(calls) > 0 {
+				s.line = strings.Count(code[:start], "\n") + 1
+				s.chain(calls)
+				i = end
+			}
+		default:
+			i++
+		}
+	}
+	if len(s.res.Actions) == 0 {
+		return nil, errors.New("script has no page calls to import (the importer reads calls on a variable named page)")
+	}
+	return s.res, nil
+}
+
+// chain imports one call chain that starts on page.
+func (s *scriptImport) chain(calls []jsCall) {
+	first := calls[0]
+	switch {
+	case first.name == "keyboard" && !first.called && len(calls) > 1:
+		s.keyboard(calls[1])
+		return
+	case first.name == "mouse" && !first.called:
+		s.warnf("page.mouse is not supported; use click actions on selectors")
+		return
+	case !first.called:
+		return
+	}
+
+	// Locator calls narrow down an element that a later call acts on
+	selector, located := "", false
+	for len(calls) > 0 {
+		sel, ok, isLocator := s.locator(calls[0])
+		if !isLocator {
+			break
+		}
+		if !ok {
+			return
+		}
+		if sel != "" {
+			selector = strings.TrimSpace(selector + " " + sel)
+		}
+		located = true
+		calls = calls[1:]
+	}
+	if len(calls) == 0 || !calls[0].called {
+		if located {
+			s.warnf("calls on a locator kept in a variable are not imported; call them on page directly")
+		}
+		return
+	}
+
+	call := calls[0]
+	args := call.args
+	if !located && takesSelector[call.name] {
+		if len(args) == 0 {
+			s.warnf("page.%s has no selector", call.name)
+			return
+		}
+		sel, ok := s.selectorArg(args[0])
+		if !ok {
+			return
+		}
+		selector, args = sel, args[1:]
+	}
+	s.action(call.name, selector, located, args)
+}
+
+// takesSelector lists the page methods whose first argument is a selector.
+var takesSelector = map[string]bool{
+	"click": true, "dblclick": true, "check": true, "uncheck": true, "tap": true, "fill": true,
+	"type": true, "press": true, "selectOption": true, "select": true, "waitForSelector": true,
+	"hover": true, "focus": true, "textContent": true, "innerText": true, "innerHTML": true,
+	"$eval": true,
+}
+
+// action imports a method that acts on the page, or on selector when the
+// call had one.
+func (s *scriptImport) action(name, selector string, located bool, args []string) {
+	switch name {
+	case "goto":
+		if len(args) == 0 {
+			s.warnf("page.goto has no URL")
+			return
+		}
+		target, ok := s.stringArg(args[0])
+		if !ok {
+			return
+		}
+		action := taskstypes.Action{Type: taskstypes.ActionNavigate, Value: target}
+		if len(args) > 1 {
+			switch waitUntil, _ := jsOption(args[1], "waitUntil"); waitUntil {
+			case "networkidle", "networkidle0", "networkidle2":
+				action.WaitUntil = taskstypes.WaitUntilNetworkIdle
+			case "domcontentloaded":
+				action.WaitUntil = taskstypes.WaitUntilDOMContentLoaded
+			}
+		}
+		s.add(action)
+	case "click", "check", "tap":
+		s.add(taskstypes.Action{Type: taskstypes.ActionClick, Selector: selector})
+	case "dblclick", "uncheck":
+		s.add(taskstypes.Action{Type: taskstypes.ActionClick, Selector: selector})
+		s.warnf("%s is imported as a single click", name)
+	case "fill", "type", "pressSequentially":
+		if len(args) == 0 {
+			s.warnf("%s has no text", name)
+			return
+		}
+		if text, ok := s.stringArg(args[0]); ok {
+			s.add(taskstypes.Action{Type: taskstypes.ActionInput, Selector: selector, Value: text})
+		}
+	case "press":
+		if len(args) == 0 {
+			s.warnf("press has no key")
+			return
+		}
+		if key, ok := s.key(args[0]); ok {
+			s.add(taskstypes.Action{Type: taskstypes.ActionInput, Selector: selector, Value: key})
+		}
+	case "selectOption", "select":
+		if len(args) != 1 {
+			s.warnf("%s with several values is not supported", name)
+			return
+		}
+		if value, ok := s.stringArg(args[0]); ok {
+			s.add(taskstypes.Action{Type: taskstypes.ActionSelect, Selector: selector, Value: value})
+		}
+	case "waitForSelector", "waitFor":
+		action := taskstypes.Action{Type: taskstypes.ActionWaitVisible, Selector: selector}
+		if len(args) > 0 {
+			state, _ := jsOption(args[0], "state")
+			hidden, _ := jsOption(args[0], "hidden")
+			if state == "hidden" || state == "detached" || hidden == "true" {
+				action.Type = taskstypes.ActionWaitHidden
+			}
+			if ms, ok := jsOption(args[0], "timeout"); ok {
+				if n, err := strconv.Atoi(ms); err == nil && n > 0 {
+					action.Timeout = time.Duration(n) * time.Millisecond
+				}
+			}
+		}
+		if action.Selector == "" {
+			s.warnf("%s has no selector", name)
+			return
+		}
+		s.add(action)
+	case "waitForTimeout":
+		if len(args) == 0 {
+			s.warnf("waitForTimeout has no duration")
+			return
+		}
+		ms, err := strconv.Atoi(strings.ReplaceAll(args[0], "_", ""))
+		if err != nil || ms < 0 {
+			s.warnf("waitForTimeout(%s) is not a number of milliseconds", args[0])
+			return
+		}
+		s.add(taskstypes.Action{Type: taskstypes.ActionWaitDelay, Value: (time.Duration(ms) * time.Millisecond).String()})
+	case "screenshot":
+		s.add(taskstypes.Action{Type: taskstypes.ActionScreenshot})
+		if located {
+			s.warnf("element screenshots are imported as screenshots of the page")
+		}
+	case "content":
+		s.add(taskstypes.Action{Type: taskstypes.ActionGetDOM, Format: "full_html", Selector: "html"})
+	case "textContent", "innerText":
+		s.add(taskstypes.Action{Type: taskstypes.ActionGetDOM, Format: "text_content", Selector: selector})
+	case "innerHTML":
+		s.add(taskstypes.Action{Type: taskstypes.ActionGetDOM, Format: "full_html", Selector: selector})
+	case "title":
+		s.add(taskstypes.Action{Type: taskstypes.ActionRunScript, Value: "document.title"})
+	case "url":
+		s.add(taskstypes.Action{Type: taskstypes.ActionRunScript, Value: "location.href"})
+	case "evaluate", "$eval":
+		s.evaluate(name, selector, located || name == "$eval", args)
+	case "setUserAgent":
+		if len(args) > 0 {
+			if ua, ok := s.stringArg(args[0]); ok {
+				s.res.setOption("user_agent", &s.res.Options.UserAgent, ua)
+			}
+		}
+	case "setExtraHTTPHeaders":
+		if len(args) == 0 {
+			return
+		}
+		for _, m := range objectStringPairs.FindAllStringSubmatch(args[0], -1) {
+			value, _ := jsStringLiteral(m[2])
+			s.res.setHeader(strings.Trim(m[1], `'"`), value)
+		}
+	case "close", "bringToFront":
+	case "waitForNavigation", "waitForLoadState", "waitForURL", "waitForNetworkIdle":
+		s.warnf("%s is not imported; navigate actions wait for the page to load, and a wait_visible action can wait for the next page", name)
+	default:
+		s.warnf("page.%s has no GoScry equivalent and was skipped", name)
+	}
+}
+
+// evaluate imports a script run in the page, on the element at selector
+// when element is set.
+func (s *scriptImport) evaluate(name, selector string, element bool, args []string) {
+	if len(args) == 0 {
+		s.warnf("%s has no function", name)
+		return
+	}
+	if len(args) > 1 {
+		s.warnf("%s arguments are not supported; inline them in the script", name)
+		return
+	}
+	fn := args[0]
+	if literal, ok := jsStringLiteral(fn); ok {
+		if element {
+			s.warnf("%s with a string expression is not supported", name)
+			return
+		}
+		s.add(taskstypes.Action{Type: taskstypes.ActionRunScript, Value: literal})
+		return
+	}
+	if strings.HasPrefix(fn, "async") {
+		s.warnf("%s runs an async function; run_script does not wait for promises", name)
+	}
+	script := "(" + fn + ")()"
+	if element {
+		script = "(" + fn + ")(document.querySelector(" + jsString(selector) + "))"
+	}
+	s.add(taskstypes.Action{Type: taskstypes.ActionRunScript, Value: script})
+}
+
+// keyboard imports page.keyboard calls, which go to the focused element.
+func (s *scriptImport) keyboard(call jsCall) {
+	if len(call.args) == 0 {
+		return
+	}
+	switch call.name {
+	case "press":
+		if key, ok := s.key(call.args[0]); ok {
+			s.add(taskstypes.Action{Type: taskstypes.ActionInput, Selector: ":focus", Value: key})
+		}
+	case "type", "insertText", "sendCharacter":
+		if text, ok := s.stringArg(call.args[0]); ok {
+			s.add(taskstypes.Action{Type: taskstypes.ActionInput, Selector: ":focus", Value: text})
+		}
+	default:
+		s.warnf("page.keyboard.%s is not supported", call.name)
+	}
+}
+
+// locator reports whether call narrows down an element and, if so, the CSS
+// selector it adds. ok is false when the locator cannot be imported.
+func (s *scriptImport) locator(call jsCall) (selector string, ok, isLocator bool) {
+	if !call.called {
+		return "", false, false
+	}
+	attribute := map[string]string{"getByTestId": "data-testid", "getByPlaceholder": "placeholder", "getByAltText": "alt", "getByTitle": "title"}
+	switch call.name {
+	case "locator", "$", "$$":
+		if len(call.args) != 1 {
+			s.warnf("%s with options is not supported", call.name)
+			return "", false, true
+		}
+		selector, ok = s.selectorArg(call.args[0])
+		return selector, ok, true
+	case "getByTestId", "getByPlaceholder", "getByAltText", "getByTitle":
+		if len(call.args) != 1 {
+			s.warnf("%s with options is not supported", call.name)
+			return "", false, true
+		}
+		value, ok := s.stringArg(call.args[0])
+		if !ok {
+			return "", false, true
+		}
+		return "[" + attribute[call.name] + "=" + jsString(value) + "]", true, true
+	case "first":
+		return "", true, true // Actions already use the first match
+	case "getByRole", "getByText", "getByLabel", "nth", "last", "filter", "frameLocator":
+		s.warnf("%s has no CSS equivalent; replace it with a selector", call.name)
+		return "", false, true
+	}
+	return "", false, false
+}
+
+// selectorArg reads a selector argument and converts it to CSS.
+func (s *scriptImport) selectorArg(arg string) (string, bool) {
+	selector, ok := s.stringArg(arg)
+	if !ok {
+		return "", false
+	}
+	selector = strings.TrimPrefix(selector, "css=")
+	if id, ok := strings.CutPrefix(selector, "id="); ok {
+		return "#" + id, true
+	}
+	if testID, ok := strings.CutPrefix(selector, "data-testid="); ok {
+		return "[data-testid=" + jsString(testID) + "]", true
+	}
+	css := !strings.Contains(selector, ">>") && !strings.Contains(selector, ":has-text(") && !strings.Contains(selector, ":text(") && !strings.Contains(selector, "::-p-")
+	for _, engine := range []string{"text=", "xpath=", "role=", "//", "text/", "xpath/", "aria/", "pierce/"} {
+		css = css && !strings.HasPrefix(selector, engine)
+	}
+	if !css {
+		s.warnf("selector %q is not CSS; replace it with a CSS selector", selector)
+		return "", false
+	}
+	return selector, true
+}
+
+// stringArg reads an argument that must be a string literal.
+func (s *scriptImport) stringArg(arg string) (string, bool) {
+	value, ok := jsStringLiteral(arg)
+	if !ok {
+		s.warnf("%s is not a string literal; replace it with its value", arg)
+	}
+	return value, ok
+}
+
+// key reads a key name argument of press.
+func (s *scriptImport) key(arg string) (string, bool) {
+	name, ok := s.stringArg(arg)
+	if !ok {
+		return "", false
+	}
+	if key, ok := scriptKeys[name]; ok {
+		return key, true
+	}
+	if len([]rune(name)) == 1 {
+		return name, true
+	}
+	s.warnf("key %q is not supported", name)
+	return "", false
+}
+
+// objectStringPairs matches the string-valued properties of an object literal.
+var objectStringPairs = regexp.MustCompile(`(['"]?[\w-]+['"]?)\s*:\s*(('(?:[^'\\]|\\.)*')|("(?:[^"\\]|\\.)*"))`)
+
+// jsOption reads a simple property of an object literal argument, such as
+// waitUntil in { waitUntil: 'networkidle' }.
+func jsOption(object, key string) (string, bool) {
+	re := regexp.MustCompile(`(?:^|[{,\s])['"]?` + regexp.QuoteMeta(key) + `['"]?\s*:\s*([^,}]+)`)
+	m := re.FindStringSubmatch(object)
+	if m == nil {
+		return "", false
+	}
+	value := strings.TrimSpace(m[1])
+	if literal, ok := jsStringLiteral(value); ok {
+		return literal, true
+	}
+	return strings.ReplaceAll(value, "_", ""), true
+}
+
+// jsStringLiteral decodes a quoted JavaScript string, or a template literal
+// without substitutions.
+func jsStringLiteral(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != s[len(s)-1] || strings.IndexByte(`'"`+"`", s[0]) < 0 {
+		return "", false
+	}
+	body := s[1 : len(s)-1]
+	if s[0] == '`' && strings.Contains(body, "${") {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c == s[0] {
+			return "", false // Two literals joined, e.g. 'a' + 'b'
+		}
+		if c != '\\' || i+1 >= len(body) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch body[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'x', 'u':
+			digits := 2
+			if body[i] == 'u' {
+				digits = 4
+			}
+			if i+digits >= len(body) {
+				return "", false
+			}
+			n, err := strconv.ParseUint(body[i+1:i+1+digits], 16, 32)
+			if err != nil {
+				return "", false
+			}
+			b.WriteRune(rune(n))
+			i += digits
+		case '\n':
+		default:
+			b.WriteByte(body[i])
+		}
+	}
+	return b.String(), true
+}
+
+// parseChain reads the .name and .name(args) steps that follow position i.
+func parseChain(code string, i int) ([]jsCall, int) {
+	var calls []jsCall
+	for {
+		j := skipSpace(code, i)
+		if strings.HasPrefix(code[j:], "?.") {
+			j++
+		}
+		if j >= len(code) || code[j] != '.' {
+			return calls, i
+		}
+		j = skipSpace(code, j+1)
+		start := j
+		for j < len(code) && isIdentPart(code[j]) {
+			j++
+		}
+		if j == start {
+			return calls, i
+		}
+		call := jsCall{name: code[start:j]}
+		i = j
+		if k := skipSpace(code, j); k < len(code) && code[k] == '(' {
+			args, end, ok := parseArgs(code, k)
+			if !ok {
+				return calls, i
+			}
+			call.args, call.called, i = args, true, end
+		}
+		calls = append(calls, call)
+	}
+}
+
+// parseArgs splits the argument list opening at code[open] and returns the
+// position after its closing parenthesis.
+func parseArgs(code string, open int) ([]string, int, bool) {
+	var args []string
+	depth, start := 0, open+1
+	for i := open; i < len(code); i++ {
+		switch c := code[i]; c {
+		case '\'', '"', '`':
+			i = skipString(code, i) - 1
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				if arg := strings.TrimSpace(code[start:i]); arg != "" {
+					args = append(args, arg)
+				}
+				return args, i + 1, true
+			}
+		case ',':
+			if depth == 1 {
+				args = append(args, strings.TrimSpace(code[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return nil, len(code), false
+}
+
+// skipString returns the position after the string literal starting at i.
+func skipString(code string, i int) int {
+	quote := code[i]
+	for i++; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			if quote != '`' {
+				return i
+			}
+		}
+	}
+	return len(code)
+}
+
+// blankComments replaces comments with spaces, keeping line breaks so
+// positions still map to the same lines.
+func blankComments(src string) string {
+	code := []byte(src)
+	for i := 0; i < len(code); i++ {
+		switch {
+		case code[i] == '\'' || code[i] == '"' || code[i] == '`':
+			i = skipString(src, i) - 1
+		case code[i] == '/' && i+1 < len(code) && code[i+1] == '/':
+			for ; i < len(code) && code[i] != '\n'; i++ {
+				code[i] = ' '
+			}
+		case code[i] == '/' && i+1 < len(code) && code[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 2
+			} else {
+				end += 2
+			}
+			for j := i; j < i+2+end && j < len(code); j++ {
+				if code[j] != '\n' {
+					code[j] = ' '
+				}
+			}
+			i += 1 + end
+		}
+	}
+	return string(code)
+}
+
+func skipSpace(code string, i int) int {
+	for i < len(code) && strings.IndexByte(" \t\r\n", code[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+func prevNonSpace(code string, i int) byte {
+	for i--; i >= 0; i-- {
+		if strings.IndexByte(" \t\r\n", code[i]) < 0 {
+			return code[i]
+		}
+	}
+	return 0
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
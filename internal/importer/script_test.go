@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp/kb"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScript_Playwright(t *testing.T) {
+	res, err := Script(`import { test } from '@playwright/test';
+
+test('checkout', async ({ page }) => {
+  await page.goto('https://shop.example/', { waitUntil: 'networkidle' });
+  // await page.click('#commented-out');
+  await page.getByTestId('search').fill("running shoes");
+  await page.locator('form.search').locator('button[type=submit]').click();
+  await page.waitForSelector('.results', { timeout: 10_000 });
+  await page.locator('.spinner').waitFor({ state: 'hidden' });
+  await page.selectOption('#size', '42');
+  await page.fill('#coupon', 'it\'s // not a comment');
+  await page.keyboard.press('Enter');
+  await page.waitForTimeout(1500);
+  const total = await page.textContent('#total');
+  const count = await page.$eval('.items', el => el.children.length);
+  await page.getByRole('button', { name: 'Pay' }).click();
+  await page.hover('#menu');
+  await page.screenshot({ path: 'done.png', fullPage: true });
+  /* await page.goto('https://never.example/'); */
+});`)
+	require.NoError(t, err)
+	assert.Equal(t, []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://shop.example/", WaitUntil: taskstypes.WaitUntilNetworkIdle},
+		{Type: taskstypes.ActionInput, Selector: `[data-testid="search"]`, Value: "running shoes"},
+		{Type: taskstypes.ActionClick, Selector: "form.search button[type=submit]"},
+		{Type: taskstypes.ActionWaitVisible, Selector: ".results", Timeout: 10 * time.Second},
+		{Type: taskstypes.ActionWaitHidden, Selector: ".spinner"},
+		{Type: taskstypes.ActionSelect, Selector: "#size", Value: "42"},
+		{Type: taskstypes.ActionInput, Selector: "#coupon", Value: "it's // not a comment"},
+		{Type: taskstypes.ActionInput, Selector: ":focus", Value: kb.Enter},
+		{Type: taskstypes.ActionWaitDelay, Value: "1.5s"},
+		{Type: taskstypes.ActionGetDOM, Format: "text_content", Selector: "#total"},
+		{Type: taskstypes.ActionRunScript, Value: `(el => el.children.length)(document.querySelector(".items"))`},
+		{Type: taskstypes.ActionScreenshot},
+	}, res.Actions)
+	assert.Equal(t, []string{
+		"line 16: getByRole has no CSS equivalent; replace it with a selector",
+		"line 17: page.hover has no GoScry equivalent and was skipped",
+	}, res.Warnings)
+}
+
+func TestScript_Puppeteer(t *testing.T) {
+	res, err := Script(`const puppeteer = require('puppeteer');
+(async () => {
+  const browser = await puppeteer.launch();
+  const page = await browser.newPage();
+  await page.setUserAgent("Bot/2");
+  await page.setExtraHTTPHeaders({ 'X-Tenant': 'acme', "x-debug": "1" });
+  await page.goto(` + "`https://app.example/login`" + `);
+  await page.type('#user', 'ops');
+  await page.type('#pass', process.env.PASSWORD);
+  await page.click('text=Sign in');
+  await page.waitForSelector('#dashboard', { visible: true });
+  const title = await page.evaluate(() => document.title);
+  await page.goto(` + "`${base}/reports`" + `);
+  await browser.close();
+})();`)
+	require.NoError(t, err)
+	assert.Equal(t, []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://app.example/login"},
+		{Type: taskstypes.ActionInput, Selector: "#user", Value: "ops"},
+		{Type: taskstypes.ActionWaitVisible, Selector: "#dashboard"},
+		{Type: taskstypes.ActionRunScript, Value: "(() => document.title)()"},
+	}, res.Actions)
+	assert.Equal(t, "Bot/2", res.Options.UserAgent)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme", "x-debug": "1"}, res.Options.Headers)
+	assert.Equal(t, []string{
+		"line 9: process.env.PASSWORD is not a string literal; replace it with its value",
+		`line 10: selector "text=Sign in" is not CSS; replace it with a CSS selector`,
+		"line 13: `${base}/reports` is not a string literal; replace it with its value",
+	}, res.Warnings)
+}
+
+func TestScript_NoPage(t *testing.T) {
+	_, err := Script(`const tab = await browser.newPage(); await tab.goto('https://example.com/');`)
+	assert.Error(t, err)
+}
+
+func TestJSStringLiteral(t *testing.T) {
+	for in, want := range map[string]string{`'a\'b'`: "a'b", `"é\n"`: "é\n", "`x`": "x", `'\x41'`: "A"} {
+		got, ok := jsStringLiteral(in)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{`'a' + 'b'`, "`${x}`", `name`, `'open`} {
+		_, ok := jsStringLiteral(in)
+		assert.False(t, ok, in)
+	}
+}
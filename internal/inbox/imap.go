@@ -0,0 +1,217 @@
+package inbox
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapConn is just enough of an IMAP4rev1 client (RFC 3501) to log in,
+// search a mailbox, and fetch messages from it without changing any flags.
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// imapLine is one response line, with the literals it carried spliced out.
+type imapLine struct {
+	text     string   // The line, each literal replaced by its {size} marker
+	literals [][]byte // In the order they appeared
+}
+
+var (
+	imapLiteral      = regexp.MustCompile(`\{(\d+)\}$`)
+	imapFetchUID     = regexp.MustCompile(`\bUID (\d+)`)
+	imapInternalDate = regexp.MustCompile(`\bINTERNALDATE "([^"]+)"`)
+)
+
+// imapDateTime is the layout of INTERNALDATE.
+const imapDateTime = "_2-Jan-2006 15:04:05 -0700"
+
+// maxIMAPLiteral bounds a fetched message, so a huge one cannot exhaust memory.
+const maxIMAPLiteral = 10 << 20
+
+func dialIMAP(ctx context.Context, addr string, plaintext bool) (*imapConn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if !plaintext {
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting.text, "* OK") && !strings.HasPrefix(greeting.text, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected IMAP greeting %q", greeting.text)
+	}
+	return c, nil
+}
+
+func (c *imapConn) Close() error {
+	return c.conn.Close()
+}
+
+// command sends a command and returns its untagged responses, or an error
+// unless the server completed it with OK.
+func (c *imapConn) command(format string, args ...interface{}) ([]imapLine, error) {
+	c.tag++
+	tag := "g" + strconv.Itoa(c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+	var untagged []imapLine
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if rest, ok := strings.CutPrefix(line.text, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return nil, fmt.Errorf("IMAP %s failed: %s", strings.Fields(format)[0], rest)
+			}
+			return untagged, nil
+		}
+		if strings.HasPrefix(line.text, "* ") {
+			untagged = append(untagged, line)
+		}
+	}
+}
+
+// readLine reads a response line and any literals it continues into.
+func (c *imapConn) readLine() (imapLine, error) {
+	var line imapLine
+	for {
+		text, err := c.r.ReadString('\n')
+		if err != nil {
+			return line, err
+		}
+		text = strings.TrimRight(text, "\r\n")
+		line.text += text
+		m := imapLiteral.FindStringSubmatch(text)
+		if m == nil {
+			return line, nil
+		}
+		size, err := strconv.Atoi(m[1])
+		if err != nil || size > maxIMAPLiteral {
+			return line, fmt.Errorf("IMAP literal of %s bytes is too large", m[1])
+		}
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return line, err
+		}
+		line.literals = append(line.literals, literal)
+	}
+}
+
+func (c *imapConn) login(username, password string) error {
+	_, err := c.command("LOGIN %s %s", imapQuote(username), imapQuote(password))
+	return err
+}
+
+// examine opens a mailbox read-only, so nothing is marked as read.
+func (c *imapConn) examine(mailbox string) error {
+	_, err := c.command("EXAMINE %s", imapQuote(mailbox))
+	return err
+}
+
+// search returns the UIDs of messages matching criteria.
+func (c *imapConn) search(criteria string) ([]uint32, error) {
+	lines, err := c.command("UID SEARCH %s", criteria)
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, line := range lines {
+		fields, ok := strings.CutPrefix(line.text, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(fields) {
+			if uid, err := strconv.ParseUint(field, 10, 32); err == nil {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// receivedAt returns when each message arrived in the mailbox.
+func (c *imapConn) receivedAt(uids []uint32) (map[uint32]time.Time, error) {
+	lines, err := c.command("UID FETCH %s (UID INTERNALDATE)", imapSet(uids))
+	if err != nil {
+		return nil, err
+	}
+	dates := make(map[uint32]time.Time, len(uids))
+	for _, line := range lines {
+		uid, date := imapFetchUID.FindStringSubmatch(line.text), imapInternalDate.FindStringSubmatch(line.text)
+		if uid == nil || date == nil {
+			continue
+		}
+		n, err := strconv.ParseUint(uid[1], 10, 32)
+		if err != nil {
+			continue
+		}
+		at, err := time.Parse(imapDateTime, date[1])
+		if err != nil {
+			return nil, fmt.Errorf("bad INTERNALDATE %q: %w", date[1], err)
+		}
+		dates[uint32(n)] = at
+	}
+	return dates, nil
+}
+
+// message fetches a whole message without setting its \Seen flag.
+func (c *imapConn) message(uid uint32) ([]byte, error) {
+	lines, err := c.command("UID FETCH %d (BODY.PEEK[])", uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if len(line.literals) > 0 {
+			return line.literals[0], nil
+		}
+	}
+	return nil, errors.New("IMAP FETCH returned no message")
+}
+
+func (c *imapConn) logout() {
+	c.command("LOGOUT")
+}
+
+// imapQuote returns s as an IMAP quoted string.
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// imapSet joins UIDs into a sequence set such as "3,7,12".
+func imapSet(uids []uint32) string {
+	parts := make([]string, len(uids))
+	for i, uid := range uids {
+		parts[i] = strconv.FormatUint(uint64(uid), 10)
+	}
+	return strings.Join(parts, ",")
+}
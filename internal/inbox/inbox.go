@@ -0,0 +1,251 @@
+// Package inbox reads 2FA codes from an IMAP mailbox, so tasks using email
+// 2FA can continue without a client providing the code.
+package inbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+)
+
+const (
+	defaultMailbox      = "INBOX"
+	defaultCodePattern  = `\b(\d{6})\b`
+	defaultPollInterval = 5 * time.Second
+	defaultLookback     = 2 * time.Minute
+	maxCandidates       = 10 // Newest messages read per check
+)
+
+// Inbox finds 2FA codes in the messages of one mailbox. Each message's code
+// is handed out once.
+type Inbox struct {
+	cfg      config.EmailInboxConfig
+	pattern  *regexp.Regexp
+	interval time.Duration
+	lookback time.Duration
+
+	mu   sync.Mutex
+	used map[uint32]bool // UIDs whose code was already returned
+}
+
+// New checks twoFactor.email. It returns nil, and no error, when no IMAP
+// server is configured.
+func New(cfg config.EmailInboxConfig) (*Inbox, error) {
+	if cfg.Addr == "" {
+		return nil, nil
+	}
+	if cfg.Username == "" {
+		return nil, errors.New("twoFactor.email needs a username")
+	}
+	if strings.ContainsAny(cfg.Username+cfg.Password+cfg.Mailbox+cfg.From, "\r\n") {
+		return nil, errors.New("twoFactor.email settings cannot contain line breaks")
+	}
+	if cfg.Mailbox == "" {
+		cfg.Mailbox = defaultMailbox
+	}
+	if cfg.CodePattern == "" {
+		cfg.CodePattern = defaultCodePattern
+	}
+	pattern, err := regexp.Compile(cfg.CodePattern)
+	if err != nil {
+		return nil, fmt.Errorf("twoFactor.email.codePattern: %w", err)
+	}
+	in := &Inbox{cfg: cfg, pattern: pattern, interval: defaultPollInterval, lookback: defaultLookback, used: make(map[uint32]bool)}
+	if cfg.PollInterval > 0 {
+		in.interval = cfg.PollInterval
+	}
+	if cfg.Lookback > 0 {
+		in.lookback = cfg.Lookback
+	}
+	return in, nil
+}
+
+// PollInterval is how often a waiting task should call Code.
+func (in *Inbox) PollInterval() time.Duration {
+	return in.interval
+}
+
+// Code checks the mailbox once for a code sent to recipient, or to anyone
+// when it is empty, in a message received since the prompt appeared (less
+// the configured lookback). It returns "" when there is none yet.
+func (in *Inbox) Code(ctx context.Context, recipient string, promptedAt time.Time) (string, error) {
+	if strings.ContainsAny(recipient, "\r\n") {
+		return "", errors.New("recipient cannot contain line breaks")
+	}
+	since := promptedAt.Add(-in.lookback)
+
+	c, err := dialIMAP(ctx, in.cfg.Addr, in.cfg.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to %s: %w", in.cfg.Addr, err)
+	}
+	defer c.Close()
+	defer c.logout()
+	if err := c.login(in.cfg.Username, in.cfg.Password); err != nil {
+		return "", err
+	}
+	if err := c.examine(in.cfg.Mailbox); err != nil {
+		return "", err
+	}
+
+	// SINCE matches whole days in the server's time zone, so ask from the
+	// day before and check the exact time below
+	criteria := "SINCE " + since.Add(-24*time.Hour).Format("2-Jan-2006")
+	if in.cfg.From != "" {
+		criteria += " FROM " + imapQuote(in.cfg.From)
+	}
+	if recipient != "" {
+		criteria += " TO " + imapQuote(recipient)
+	}
+	uids, err := c.search(criteria)
+	if err != nil || len(uids) == 0 {
+		return "", err
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] > uids[j] }) // Newest first
+	uids = in.unused(uids)
+	if len(uids) > maxCandidates {
+		uids = uids[:maxCandidates]
+	}
+	if len(uids) == 0 {
+		return "", nil
+	}
+	received, err := c.receivedAt(uids)
+	if err != nil {
+		return "", err
+	}
+
+	for _, uid := range uids {
+		if at, ok := received[uid]; !ok || at.Before(since) {
+			continue
+		}
+		raw, err := c.message(uid)
+		if err != nil {
+			return "", err
+		}
+		if code := in.match(messageText(raw)); code != "" && in.claim(uid) {
+			return code, nil
+		}
+	}
+	return "", nil
+}
+
+// unused drops UIDs whose code was already handed out.
+func (in *Inbox) unused(uids []uint32) []uint32 {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	kept := uids[:0]
+	for _, uid := range uids {
+		if !in.used[uid] {
+			kept = append(kept, uid)
+		}
+	}
+	return kept
+}
+
+// claim marks a message's code as handed out, reporting false if another
+// task got there first.
+func (in *Inbox) claim(uid uint32) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.used[uid] {
+		return false
+	}
+	in.used[uid] = true
+	return true
+}
+
+// match returns the code in text: the pattern's first group if it has one,
+// or the whole match.
+func (in *Inbox) match(text string) string {
+	m := in.pattern.FindStringSubmatch(text)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return m[1]
+	}
+	return m[0]
+}
+
+var (
+	htmlHidden = regexp.MustCompile(`(?is)<(style|script|head)\b.*?</(style|script|head)>`)
+	htmlTag    = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// messageText returns a message's subject and body text. Plain text parts
+// are preferred; HTML parts are used, without their markup, when there are
+// none.
+func messageText(raw []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return string(raw)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	var plain, rich []string
+	collectParts(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body, &plain, &rich, 0)
+	body := plain
+	if len(body) == 0 {
+		for _, part := range rich {
+			body = append(body, html.UnescapeString(htmlTag.ReplaceAllString(htmlHidden.ReplaceAllString(part, " "), " ")))
+		}
+	}
+	return subject + "\n" + strings.Join(body, "\n")
+}
+
+// collectParts decodes a body, descending into multipart ones, and sorts its
+// text into plain and HTML parts.
+func collectParts(contentType, encoding string, body io.Reader, plain, rich *[]string, depth int) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth > 5 || params["boundary"] == "" {
+			return
+		}
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := parts.NextRawPart()
+			if err != nil {
+				return
+			}
+			collectParts(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part, plain, rich, depth+1)
+		}
+	}
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body) // Skips line breaks
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxIMAPLiteral))
+	if err != nil && len(data) == 0 {
+		return
+	}
+	if mediaType == "text/html" {
+		*rich = append(*rich, string(data))
+	} else {
+		*plain = append(*plain, string(data))
+	}
+}
@@ -0,0 +1,155 @@
+package inbox
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMessage struct {
+	uid      uint32
+	received time.Time
+	to       string
+	raw      string
+}
+
+// fakeIMAP serves just the commands Inbox sends, for one mailbox.
+type fakeIMAP struct {
+	mu       sync.Mutex
+	messages []fakeMessage
+	searches []string
+	peeked   bool // Only BODY.PEEK was used
+}
+
+func (s *fakeIMAP) serve(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s *fakeIMAP) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK fake IMAP ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, command, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		s.mu.Lock()
+		switch {
+		case strings.HasPrefix(command, "LOGIN "):
+			if command != `LOGIN "codes@example.com" "app \"pass\""` {
+				fmt.Fprintf(conn, "%s NO [AUTHENTICATIONFAILED] Invalid credentials\r\n", tag)
+				s.mu.Unlock()
+				continue
+			}
+		case strings.HasPrefix(command, "EXAMINE "):
+			fmt.Fprint(conn, "* 2 EXISTS\r\n")
+		case strings.HasPrefix(command, "UID SEARCH "):
+			s.searches = append(s.searches, command)
+			var uids []string
+			for _, m := range s.messages {
+				if !strings.Contains(command, " TO ") || strings.Contains(command, `TO "`+m.to+`"`) {
+					uids = append(uids, fmt.Sprint(m.uid))
+				}
+			}
+			fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+		case strings.HasSuffix(command, "(UID INTERNALDATE)"):
+			for i, m := range s.messages {
+				fmt.Fprintf(conn, "* %d FETCH (UID %d INTERNALDATE \"%s\")\r\n", i+1, m.uid, m.received.Format(imapDateTime))
+			}
+		case strings.HasSuffix(command, "(BODY.PEEK[])"):
+			s.peeked = true
+			for i, m := range s.messages {
+				if strings.HasPrefix(command, fmt.Sprintf("UID FETCH %d ", m.uid)) {
+					fmt.Fprintf(conn, "* %d FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", i+1, m.uid, len(m.raw), m.raw)
+				}
+			}
+		case command == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+			s.mu.Unlock()
+			return
+		}
+		fmt.Fprintf(conn, "%s OK done\r\n", tag)
+		s.mu.Unlock()
+	}
+}
+
+func TestInboxCode(t *testing.T) {
+	prompted := time.Now().Add(-time.Minute).Truncate(time.Second)
+	server := &fakeIMAP{messages: []fakeMessage{
+		{uid: 3, received: prompted.Add(-time.Hour), to: "ada@example.com", raw: "Subject: Your code\r\n\r\nYour code is 111111\r\n"},
+		{uid: 7, received: prompted.Add(10 * time.Second), to: "ada@example.com", raw: "Subject: =?UTF-8?Q?Sign-in_code?=\r\n" +
+			"Content-Type: multipart/alternative; boundary=b1\r\n\r\n" +
+			"--b1\r\nContent-Type: text/html\r\n\r\n<style>p{color:#123456}</style><p>Use <b>999999</b></p>\r\n" +
+			"--b1\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nUse 4827=\r\n19 to sign in\r\n" +
+			"--b1--\r\n"},
+		{uid: 9, received: prompted.Add(20 * time.Second), to: "bob@example.com", raw: "Subject: Code\r\n" +
+			"Content-Type: text/html\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+			"PHN0eWxlPi5jIHtjb2xvcjogIzAwMDAwMH08L3N0eWxlPjxwPkNvZGU6IDxiPjU1\r\nNTU1NTwvYj48L3A+\r\n"},
+	}}
+	addr := server.serve(t)
+
+	in, err := New(config.EmailInboxConfig{Addr: addr, Plaintext: true, Username: "codes@example.com", Password: `app "pass"`, From: "no-reply@bank.example"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	code, err := in.Code(ctx, "ada@example.com", prompted)
+	require.NoError(t, err)
+	assert.Equal(t, "482719", code, "plain text is preferred over HTML, and older messages are ignored")
+	assert.Contains(t, server.searches[0], `FROM "no-reply@bank.example" TO "ada@example.com"`)
+	assert.True(t, server.peeked, "messages are not marked as read")
+
+	code, err = in.Code(ctx, "ada@example.com", prompted)
+	require.NoError(t, err)
+	assert.Empty(t, code, "each message's code is used once")
+
+	code, err = in.Code(ctx, "bob@example.com", prompted)
+	require.NoError(t, err)
+	assert.Equal(t, "555555", code, "HTML is read without its styles and markup")
+
+	in.cfg.Password = "wrong"
+	_, err = in.Code(ctx, "", prompted)
+	assert.ErrorContains(t, err, "Invalid credentials")
+}
+
+func TestNew(t *testing.T) {
+	in, err := New(config.EmailInboxConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, in, "polling is off without an address")
+
+	in, err = New(config.EmailInboxConfig{Addr: "imap.example.com:993", Username: "u", CodePattern: `code: ([A-Z0-9]{8})`})
+	require.NoError(t, err)
+	assert.Equal(t, "K7Q2ZX9M", in.match("Your code: K7Q2ZX9M"))
+	assert.Equal(t, defaultPollInterval, in.PollInterval())
+
+	for _, cfg := range []config.EmailInboxConfig{
+		{Addr: "imap.example.com:993"},
+		{Addr: "imap.example.com:993", Username: "u", CodePattern: "("},
+		{Addr: "imap.example.com:993", Username: "u", Mailbox: "INBOX\r\nA1 DELETE INBOX"},
+	} {
+		_, err := New(cfg)
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
@@ -2,7 +2,7 @@ package internal
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 	"testing"
 	"time"
@@ -27,7 +27,7 @@ func TestGoScryIntegrationWorkflow(t *testing.T) {
 	mockBrowser.SimulateTwoFactorAuth(true)
 
 	// Create a test logger
-	testLogger := log.New(os.Stderr, "TEST: ", log.LstdFlags)
+	testLogger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 
 	// Create a minimal config
 	cfg := &config.Config{
@@ -0,0 +1,132 @@
+// Package logging builds the structured logger used across GoScry and
+// carries per-request and per-task fields through contexts, so every line
+// logged while handling a request or running a task can be correlated.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/config"
+)
+
+// Field names added to log lines from a context.
+const (
+	TaskIDKey    = "task_id"
+	ActionKey    = "action" // Index of the action being run
+	RequestIDKey = "request_id"
+)
+
+// New returns a logger writing to w at the level and in the format cfg sets.
+// An unknown level logs at info and says so.
+func New(cfg config.LogConfig, w io.Writer) *slog.Logger {
+	level, levelErr := ParseLevel(cfg.Level)
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "json") {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	logger := slog.New(&contextHandler{Handler: handler})
+	if levelErr != nil {
+		logger.Warn("Invalid log.level, logging at info", "error", levelErr)
+	}
+	return logger
+}
+
+// Discard returns a logger that drops everything, for tests and tools.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// ParseLevel reads a log.level setting: debug, info (or empty), warn, or error.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q (expected debug, info, warn, or error)", s)
+}
+
+type contextKey struct{}
+
+// WithAttrs returns a context whose log lines carry attrs in addition to
+// those ctx already carries. Only loggers from New add them, and only when
+// called with a ...Context method such as InfoContext.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	for _, attr := range existing {
+		if !hasKey(attrs, attr.Key) {
+			combined = append(combined, attr) // A nested action replaces its parent's index
+		}
+	}
+	return context.WithValue(ctx, contextKey{}, append(combined, attrs...))
+}
+
+// WithTask tags ctx's log lines with a task ID.
+func WithTask(ctx context.Context, taskID fmt.Stringer) context.Context {
+	return WithAttrs(ctx, slog.String(TaskIDKey, taskID.String()))
+}
+
+// WithAction tags ctx's log lines with the index of the action being run.
+func WithAction(ctx context.Context, index int) context.Context {
+	return WithAttrs(ctx, slog.Int(ActionKey, index))
+}
+
+// WithRequestID tags ctx's log lines with an HTTP request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return WithAttrs(ctx, slog.String(RequestIDKey, requestID))
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler adds the attributes stored by WithAttrs to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// Printf adapts logger to the printf-style callbacks some libraries take,
+// such as chromedp.WithLogf.
+func Printf(ctx context.Context, logger *slog.Logger, level slog.Level) func(format string, args ...interface{}) {
+	return func(format string, args ...interface{}) {
+		if logger.Enabled(ctx, level) {
+			logger.Log(ctx, level, fmt.Sprintf(format, args...))
+		}
+	}
+}
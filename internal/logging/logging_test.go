@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.LogConfig{Level: "warn"}, &buf)
+	logger.Info("hidden")
+	logger.Warn("shown")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+
+	buf.Reset()
+	logger = New(config.LogConfig{Level: "debug"}, &buf)
+	logger.Debug("details")
+	assert.Contains(t, buf.String(), "details")
+
+	buf.Reset()
+	logger = New(config.LogConfig{Level: "verbose"}, &buf)
+	assert.Contains(t, buf.String(), "Invalid log.level")
+	logger.Debug("hidden")
+	logger.Info("shown")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+}
+
+func TestNew_ContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.LogConfig{Format: "json"}, &buf)
+
+	taskID := uuid.New()
+	ctx := WithRequestID(context.Background(), "host/abc-000001")
+	ctx = WithTask(ctx, taskID)
+	ctx = WithAction(WithAction(ctx, 1), 3) // A nested action replaces the outer index
+	logger.InfoContext(ctx, "Clicked", "selector", "#go")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "Clicked", line["msg"])
+	assert.Equal(t, taskID.String(), line[TaskIDKey])
+	assert.Equal(t, float64(3), line[ActionKey])
+	assert.Equal(t, "host/abc-000001", line[RequestIDKey])
+	assert.Equal(t, "#go", line["selector"])
+	assert.Equal(t, 1, strings.Count(buf.String(), `"action"`))
+
+	buf.Reset()
+	logger.With("component", "browser").InfoContext(WithRequestID(context.Background(), ""), "Started")
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "browser", line["component"])
+	assert.NotContains(t, buf.String(), RequestIDKey)
+}
+
+func TestPrintf(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.LogConfig{}, &buf)
+	ctx := WithTask(context.Background(), uuid.New())
+
+	Printf(ctx, logger, slog.LevelDebug)("unhandled %s", "event")
+	assert.Empty(t, buf.String(), "debug lines are dropped at info")
+	Printf(ctx, logger, slog.LevelInfo)("dialog %q dismissed", "Leave?")
+	assert.Contains(t, buf.String(), `msg="dialog \"Leave?\" dismissed"`)
+	assert.Contains(t, buf.String(), TaskIDKey+"=")
+}
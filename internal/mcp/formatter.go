@@ -46,12 +46,20 @@ func FormatDOMContent(taskID string, domData interface{}, mimeType string, sourc
 	return marshalMessage(msg)
 }
 
-func Format2FARequest(taskID string, promptDetails string, sourceURI string) ([]byte, error) {
+// Format2FARequest asks the client for a 2FA code. Entries in custom, such
+// as where to send the code, are added to the metadata alongside
+// interaction_required.
+func Format2FARequest(taskID string, promptDetails string, sourceURI string, custom map[string]interface{}) ([]byte, error) {
 	msg := NewBaseMessage(taskID)
 	msg.Context.Metadata.SourceURI = sourceURI
 	msg.Context.Metadata.Custom = map[string]interface{}{
 		"interaction_required": "2fa",
 	}
+	for key, value := range custom {
+		if key != "interaction_required" {
+			msg.Context.Metadata.Custom[key] = value
+		}
+	}
 	msg.Context.Content = Content{
 		MIMEType: "text/plain",
 		Data:     "Two-factor authentication code required: " + promptDetails,
@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/awssig"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+)
+
+// awsSource reads secrets from AWS Secrets Manager. Each secret's
+// SecretString must be a JSON object with the credential fields.
+type awsSource struct {
+	endpoint string
+	region   string
+	creds    awssig.Credentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+func newAWSSource(cfg config.AWSSecretsManagerConfig) (*awsSource, error) {
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + cfg.Region + ".amazonaws.com"
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid security.credentialSources.aws.endpoint %q", cfg.Endpoint)
+	}
+	source := &awsSource{
+		endpoint: endpoint,
+		region:   cfg.Region,
+		creds: awssig.Credentials{
+			AccessKeyID:     firstNonEmpty(cfg.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID")),
+			SecretAccessKey: firstNonEmpty(cfg.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+			SessionToken:    firstNonEmpty(cfg.SessionToken, os.Getenv("AWS_SESSION_TOKEN")),
+		},
+		client: &http.Client{Timeout: lookupTimeout},
+		now:    time.Now,
+	}
+	if source.creds.AccessKeyID == "" || source.creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("security.credentialSources.aws needs an access key ID and secret access key")
+	}
+	return source, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (a *awsSource) lookup(ctx context.Context, path string) (*taskstypes.Credentials, error) {
+	fields, err := a.read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return credentialsFromFields(fields)
+}
+
+// rotate writes the new password as a new version of the secret. Secrets
+// Manager has no check-and-set, so the secret is checked just before the
+// write; a change made between the two is overwritten.
+func (a *awsSource) rotate(ctx context.Context, path, username, oldPassword, newPassword string) error {
+	fields, err := a.read(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := checkRotation(fields, username, oldPassword); err != nil {
+		return err
+	}
+	secret, err := json.Marshal(rotatedFields(fields, newPassword))
+	if err != nil {
+		return err
+	}
+	return a.call(ctx, "PutSecretValue", map[string]string{
+		"SecretId":           path,
+		"SecretString":       string(secret),
+		"ClientRequestToken": uuid.NewString(),
+	}, &struct{}{})
+}
+
+// read returns the fields of the secret's current version.
+func (a *awsSource) read(ctx context.Context, path string) (map[string]interface{}, error) {
+	var body struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := a.call(ctx, "GetSecretValue", map[string]string{"SecretId": path}, &body); err != nil {
+		return nil, err
+	}
+	if body.SecretString == nil {
+		return nil, fmt.Errorf("secret has no SecretString; binary secrets are not supported")
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(*body.SecretString), &fields); err != nil {
+		return nil, fmt.Errorf("secret is not a JSON object of credential fields")
+	}
+	return fields, nil
+}
+
+// call sends a signed Secrets Manager request for action and decodes the
+// response into out.
+func (a *awsSource) call(ctx context.Context, action string, input map[string]string, out interface{}) error {
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager."+action)
+	awssig.Sign(req, a.creds, a.region, "secretsmanager", awssig.PayloadHash(payload), a.now())
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &failure)
+		// Types may be prefixed with a namespace, e.g. "com.amazonaws...#ResourceNotFoundException"
+		if strings.HasSuffix(failure.Type, "ResourceNotFoundException") {
+			return ErrNotFound
+		}
+		return fmt.Errorf("secrets manager returned %s: %s %s", resp.Status, failure.Type, failure.Message)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid secrets manager response: %w", err)
+	}
+	return nil
+}
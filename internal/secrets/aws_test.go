@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSSource(t *testing.T) {
+	secretsManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240102/eu-west-1/secretsmanager/aws4_request, "))
+		var req struct{ SecretId string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch req.SecretId {
+		case "prod/crm":
+			secret, _ := json.Marshal(`{"username": "ada", "password": "hunter2", "new_password": "correct horse"}`)
+			w.Write([]byte(`{"Name": "prod/crm", "SecretString": ` + string(secret) + `}`))
+		case "prod/binary":
+			w.Write([]byte(`{"Name": "prod/binary", "SecretBinary": "AAEC"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "ResourceNotFoundException", "message": "Secrets Manager can't find the specified secret."}`))
+		}
+	}))
+	defer secretsManager.Close()
+
+	source, err := newAWSSource(config.AWSSecretsManagerConfig{
+		Region: "eu-west-1", Endpoint: secretsManager.URL,
+		AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+	source.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	creds, err := source.lookup(context.Background(), "prod/crm")
+	require.NoError(t, err)
+	assert.Equal(t, &taskstypes.Credentials{Username: "ada", Password: "hunter2", NewPassword: "correct horse"}, creds)
+	_, err = source.lookup(context.Background(), "prod/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = source.lookup(context.Background(), "prod/binary")
+	assert.ErrorContains(t, err, "SecretString")
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	_, err = newAWSSource(config.AWSSecretsManagerConfig{Region: "eu-west-1"})
+	assert.Error(t, err, "credentials are required")
+}
+
+func TestAWSSource_Rotate(t *testing.T) {
+	secret := `{"username": "ada", "password": "hunter2", "new_password": "correct horse"}`
+	secretsManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ SecretId, SecretString, ClientRequestToken string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "prod/crm", req.SecretId)
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			body, _ := json.Marshal(map[string]string{"Name": req.SecretId, "SecretString": secret})
+			w.Write(body)
+		case "secretsmanager.PutSecretValue":
+			assert.NotEmpty(t, req.ClientRequestToken)
+			secret = req.SecretString
+			w.Write([]byte(`{"Name": "prod/crm", "VersionId": "v2"}`))
+		default:
+			t.Errorf("unexpected action %s", r.Header.Get("X-Amz-Target"))
+		}
+	}))
+	defer secretsManager.Close()
+
+	resolver, err := NewResolver(config.CredentialSourcesConfig{AWS: config.AWSSecretsManagerConfig{
+		Region: "eu-west-1", Endpoint: secretsManager.URL, AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret",
+	}}, nil)
+	require.NoError(t, err)
+	require.NoError(t, resolver.Rotate(context.Background(), "aws:prod/crm", "ada", "hunter2", "correct horse"))
+	assert.JSONEq(t, `{"username": "ada", "password": "correct horse"}`, secret)
+
+	err = resolver.Rotate(context.Background(), "aws:prod/crm", "grace", "correct horse", "another")
+	assert.ErrorIs(t, err, ErrStaleSecret, "the secret belongs to another user")
+}
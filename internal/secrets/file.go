@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// fileSource reads a JSON object mapping names to credentials sealed to the
+// server's credential key, in the encrypted_credentials format. The file is
+// read on every lookup, so edits apply without a restart.
+type fileSource struct {
+	path string
+	key  *encryption.CredentialKey
+}
+
+func newFileSource(path string, key *encryption.CredentialKey) (*fileSource, error) {
+	if key == nil {
+		return nil, fmt.Errorf("security.credentialSources.file needs a credential key")
+	}
+	source := &fileSource{path: path, key: key}
+	if _, err := source.read(); err != nil {
+		return nil, fmt.Errorf("security.credentialSources.file: %w", err)
+	}
+	return source, nil
+}
+
+func (f *fileSource) read() (map[string]*taskstypes.SealedCredentials, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]*taskstypes.SealedCredentials
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid credentials file %s: %w", f.path, err)
+	}
+	return entries, nil
+}
+
+func (f *fileSource) lookup(_ context.Context, path string) (*taskstypes.Credentials, error) {
+	entries, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	sealed := entries[path]
+	if sealed == nil {
+		return nil, ErrNotFound
+	}
+	return f.key.Open(sealed)
+}
@@ -0,0 +1,227 @@
+// Package secrets resolves credential references such as "vault:myapp/login"
+// to task credentials when a task runs, so passwords are kept out of task
+// requests, request logs, the task store, and callbacks.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// Sources a reference can name before its colon.
+const (
+	SourceVault = "vault" // HashiCorp Vault KV version 2
+	SourceAWS   = "aws"   // AWS Secrets Manager
+	SourceFile  = "file"  // Local file of encrypted credentials
+)
+
+var (
+	// ErrInvalidReference is returned for references that are malformed or
+	// name a source that is not configured.
+	ErrInvalidReference = errors.New("invalid credentials reference")
+	// ErrNotFound is returned when a source has no secret at the path.
+	ErrNotFound = errors.New("credentials not found")
+	// ErrReferenceNotAllowed is returned for references outside a caller's
+	// allowed prefixes.
+	ErrReferenceNotAllowed = errors.New("credentials reference not allowed")
+	// ErrStaleSecret is returned by Rotate when the secret no longer holds
+	// the username and password the rotation started from.
+	ErrStaleSecret = errors.New("secret changed since it was read")
+	// ErrRotationUnsupported is returned by Rotate for sources that cannot
+	// store a new password.
+	ErrRotationUnsupported = errors.New("source cannot store rotated passwords")
+)
+
+// AllowAll, as an allowed prefix, lets a caller use any reference.
+const AllowAll = "*"
+
+// source looks up the credentials stored at a path.
+type source interface {
+	lookup(ctx context.Context, path string) (*taskstypes.Credentials, error)
+}
+
+// rotator is implemented by sources that can store a rotated password.
+type rotator interface {
+	rotate(ctx context.Context, path, username, oldPassword, newPassword string) error
+}
+
+// Resolver looks references up in the configured sources. A nil Resolver
+// has no sources.
+type Resolver struct {
+	sources map[string]source
+}
+
+// NewResolver builds a resolver for the sources set in cfg. File references
+// hold credentials sealed to key, as clients seal encrypted_credentials.
+func NewResolver(cfg config.CredentialSourcesConfig, key *encryption.CredentialKey) (*Resolver, error) {
+	r := &Resolver{sources: make(map[string]source)}
+	if cfg.Vault.Address != "" {
+		vault, err := newVaultSource(cfg.Vault)
+		if err != nil {
+			return nil, err
+		}
+		r.sources[SourceVault] = vault
+	}
+	if cfg.AWS.Region != "" {
+		aws, err := newAWSSource(cfg.AWS)
+		if err != nil {
+			return nil, err
+		}
+		r.sources[SourceAWS] = aws
+	}
+	if cfg.File != "" {
+		file, err := newFileSource(cfg.File, key)
+		if err != nil {
+			return nil, err
+		}
+		r.sources[SourceFile] = file
+	}
+	return r, nil
+}
+
+// ParseReference splits a reference such as "vault:myapp/login" into its
+// source and slash-separated path.
+func ParseReference(ref string) (sourceName, path string, err error) {
+	sourceName, path, ok := strings.Cut(ref, ":")
+	if !ok {
+		return "", "", fmt.Errorf("%w %q: expected <source>:<path>, e.g. vault:myapp/login", ErrInvalidReference, ref)
+	}
+	switch sourceName {
+	case SourceVault, SourceAWS, SourceFile:
+	default:
+		return "", "", fmt.Errorf("%w %q: unknown source %q (use %s, %s, or %s)", ErrInvalidReference, ref, sourceName, SourceVault, SourceAWS, SourceFile)
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.TrimSpace(segment) != segment {
+			return "", "", fmt.Errorf("%w %q: invalid path", ErrInvalidReference, ref)
+		}
+	}
+	return sourceName, path, nil
+}
+
+// CheckAllowed reports whether ref falls under one of allowed, such as
+// "vault:team-a/" or "file:", so a caller can only have secrets it was
+// given typed into pages. Prefixes match whole path segments: "vault:team-a"
+// allows "vault:team-a/login" but not "vault:team-ab/login". No prefixes
+// allow no references.
+func CheckAllowed(ref string, allowed []string) error {
+	for _, prefix := range allowed {
+		if prefix == AllowAll || ref == prefix {
+			return nil
+		}
+		if prefix == "" || !strings.HasPrefix(ref, prefix) {
+			continue
+		}
+		if strings.HasSuffix(prefix, ":") || strings.HasSuffix(prefix, "/") || ref[len(prefix)] == '/' {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q is not under the caller's credentialRefs", ErrReferenceNotAllowed, ref)
+}
+
+// Check reports whether ref is well formed and its source is configured,
+// without looking it up.
+func (r *Resolver) Check(ref string) error {
+	sourceName, _, err := ParseReference(ref)
+	if err != nil {
+		return err
+	}
+	if r == nil || r.sources[sourceName] == nil {
+		return fmt.Errorf("%w %q: %s references are not configured (security.credentialSources.%s)", ErrInvalidReference, ref, sourceName, sourceName)
+	}
+	return nil
+}
+
+// Resolve looks ref up in its source. The credentials are read fresh each
+// time, so rotated secrets apply to the next task.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (*taskstypes.Credentials, error) {
+	if err := r.Check(ref); err != nil {
+		return nil, err
+	}
+	sourceName, path, _ := ParseReference(ref)
+	creds, err := r.sources[sourceName].lookup(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve credentials %s: %w", ref, err)
+	}
+	return creds, nil
+}
+
+// Rotate stores newPassword as the password of ref's secret, provided it
+// still holds username and oldPassword. Its new_password field is removed,
+// as it is now in use, and other fields are kept. Vault and AWS references
+// can be rotated; file references return ErrRotationUnsupported.
+func (r *Resolver) Rotate(ctx context.Context, ref, username, oldPassword, newPassword string) error {
+	if err := r.Check(ref); err != nil {
+		return err
+	}
+	sourceName, path, _ := ParseReference(ref)
+	source, ok := r.sources[sourceName].(rotator)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRotationUnsupported, sourceName)
+	}
+	if err := source.rotate(ctx, path, username, oldPassword, newPassword); err != nil {
+		return fmt.Errorf("failed to rotate credentials %s: %w", ref, err)
+	}
+	return nil
+}
+
+// checkRotation reports whether a secret's fields still hold the
+// credentials a rotation started from.
+func checkRotation(fields map[string]interface{}, username, oldPassword string) error {
+	creds, err := credentialsFromFields(fields)
+	if err != nil {
+		return err
+	}
+	if creds.Username != username || creds.Password != oldPassword {
+		return ErrStaleSecret
+	}
+	return nil
+}
+
+// rotatedFields returns a copy of fields with password set to newPassword
+// and new_password removed.
+func rotatedFields(fields map[string]interface{}, newPassword string) map[string]interface{} {
+	rotated := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		rotated[name] = value
+	}
+	rotated["password"] = newPassword
+	delete(rotated, "new_password")
+	return rotated
+}
+
+// credentialsFromFields reads username, password, and new_password from a
+// secret's key-value data. A secret without a password is an error.
+func credentialsFromFields(fields map[string]interface{}) (*taskstypes.Credentials, error) {
+	text := func(name string) (string, error) {
+		switch v := fields[name].(type) {
+		case nil:
+			return "", nil
+		case string:
+			return v, nil
+		default:
+			return "", fmt.Errorf("secret field %q is not a string", name)
+		}
+	}
+	var creds taskstypes.Credentials
+	var err error
+	if creds.Username, err = text("username"); err != nil {
+		return nil, err
+	}
+	if creds.Password, err = text("password"); err != nil {
+		return nil, err
+	}
+	if creds.NewPassword, err = text("new_password"); err != nil {
+		return nil, err
+	}
+	if creds.Password == "" {
+		return nil, fmt.Errorf("secret has no password field")
+	}
+	return &creds, nil
+}
@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	source, path, err := ParseReference("vault:myapp/login")
+	require.NoError(t, err)
+	assert.Equal(t, SourceVault, source)
+	assert.Equal(t, "myapp/login", path)
+
+	for _, ref := range []string{"", "myapp/login", "ssm:myapp", "vault:", "vault:/myapp", "vault:myapp/", "vault:myapp/../other", "file: crm"} {
+		_, _, err := ParseReference(ref)
+		assert.ErrorIs(t, err, ErrInvalidReference, ref)
+	}
+}
+
+func TestCheckAllowed(t *testing.T) {
+	allowed := []string{"vault:team-a", "aws:prod/crm/", "file:"}
+	for _, ref := range []string{"vault:team-a", "vault:team-a/login", "aws:prod/crm/login", "file:anything"} {
+		assert.NoError(t, CheckAllowed(ref, allowed), ref)
+	}
+	for _, ref := range []string{"vault:team-ab/login", "vault:team-b/login", "aws:prod/crm", "aws:prod/billing/login"} {
+		assert.ErrorIs(t, CheckAllowed(ref, allowed), ErrReferenceNotAllowed, ref)
+	}
+	assert.ErrorIs(t, CheckAllowed("vault:team-a/login", nil), ErrReferenceNotAllowed, "no prefixes allow nothing")
+	assert.NoError(t, CheckAllowed("vault:team-b/login", []string{AllowAll}))
+}
+
+func TestResolver_File(t *testing.T) {
+	key := encryption.NewCredentialKey("")
+	keyID, publicKey, err := key.PublicKey()
+	require.NoError(t, err)
+	sealed, err := encryption.SealCredentials(keyID, publicKey, "ada", "hunter2")
+	require.NoError(t, err)
+	data, err := json.Marshal(map[string]*taskstypes.SealedCredentials{"crm": sealed})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	resolver, err := NewResolver(config.CredentialSourcesConfig{File: path}, key)
+	require.NoError(t, err)
+	creds, err := resolver.Resolve(context.Background(), "file:crm")
+	require.NoError(t, err)
+	assert.Equal(t, &taskstypes.Credentials{Username: "ada", Password: "hunter2"}, creds)
+
+	_, err = resolver.Resolve(context.Background(), "file:billing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, resolver.Rotate(context.Background(), "file:crm", "ada", "hunter2", "x"), ErrRotationUnsupported)
+	assert.ErrorIs(t, resolver.Check("vault:crm"), ErrInvalidReference, "vault is not configured")
+	assert.ErrorIs(t, (*Resolver)(nil).Check("file:crm"), ErrInvalidReference)
+
+	_, err = NewResolver(config.CredentialSourcesConfig{File: filepath.Join(t.TempDir(), "missing.json")}, key)
+	assert.Error(t, err, "an unreadable file is reported at startup")
+}
@@ -0,0 +1,159 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// lookupTimeout bounds each request to a secret store.
+const lookupTimeout = 10 * time.Second
+
+// vaultSource reads secrets from a Vault KV version 2 engine over its HTTP API.
+type vaultSource struct {
+	address   *url.URL
+	token     string
+	mount     string
+	namespace string
+	client    *http.Client
+}
+
+func newVaultSource(cfg config.VaultConfig) (*vaultSource, error) {
+	address, err := url.Parse(strings.TrimSuffix(cfg.Address, "/"))
+	if err != nil || (address.Scheme != "http" && address.Scheme != "https") || address.Host == "" {
+		return nil, fmt.Errorf("invalid security.credentialSources.vault.address %q", cfg.Address)
+	}
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("security.credentialSources.vault needs a token")
+	}
+	mount := strings.Trim(cfg.Mount, "/")
+	if mount == "" {
+		mount = "secret"
+	}
+	return &vaultSource{
+		address:   address,
+		token:     token,
+		mount:     mount,
+		namespace: cfg.Namespace,
+		client:    &http.Client{Timeout: lookupTimeout},
+	}, nil
+}
+
+func (v *vaultSource) lookup(ctx context.Context, path string) (*taskstypes.Credentials, error) {
+	fields, _, err := v.read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return credentialsFromFields(fields)
+}
+
+// rotate writes the new password with a check-and-set on the version the
+// old one was read from, so a concurrent change to the secret is not lost.
+func (v *vaultSource) rotate(ctx context.Context, path, username, oldPassword, newPassword string) error {
+	fields, version, err := v.read(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := checkRotation(fields, username, oldPassword); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"options": map[string]int{"cas": version},
+		"data":    rotatedFields(fields, newPassword),
+	})
+	if err != nil {
+		return err
+	}
+	var body vaultResponse
+	status, err := v.do(ctx, http.MethodPost, path, payload, &body)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusBadRequest && strings.Contains(strings.Join(body.Errors, " "), "check-and-set") {
+		return ErrStaleSecret
+	}
+	return body.err(status)
+}
+
+// vaultResponse is the part of a KV version 2 response read here.
+type vaultResponse struct {
+	Data struct {
+		Data     map[string]interface{} `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (b *vaultResponse) err(status int) error {
+	if status/100 == 2 {
+		return nil
+	}
+	if len(b.Errors) > 0 {
+		return fmt.Errorf("vault returned %d %s: %s", status, http.StatusText(status), strings.Join(b.Errors, "; "))
+	}
+	return fmt.Errorf("vault returned %d %s", status, http.StatusText(status))
+}
+
+// read returns the fields of the secret's current version and its number.
+func (v *vaultSource) read(ctx context.Context, path string) (map[string]interface{}, int, error) {
+	var body vaultResponse
+	status, err := v.do(ctx, http.MethodGet, path, nil, &body)
+	switch {
+	case status == http.StatusNotFound:
+		return nil, 0, ErrNotFound
+	case err != nil:
+		return nil, 0, err
+	case status/100 != 2:
+		return nil, 0, body.err(status)
+	case body.Data.Data == nil:
+		// Deleted or destroyed versions have no data
+		return nil, 0, ErrNotFound
+	}
+	return body.Data.Data, body.Data.Metadata.Version, nil
+}
+
+// do sends a request for the secret at path and decodes the response into
+// body. Error responses are small JSON too; a body that is not JSON is
+// reported by status alone.
+func (v *vaultSource) do(ctx context.Context, method, path string, payload []byte, body *vaultResponse) (int, error) {
+	u := *v.address
+	u.Path += "/v1/" + v.mount + "/data/" + path
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(body)
+	if decodeErr != nil && resp.StatusCode/100 == 2 && method == http.MethodGet {
+		return resp.StatusCode, fmt.Errorf("invalid vault response: %w", decodeErr)
+	}
+	return resp.StatusCode, nil
+}
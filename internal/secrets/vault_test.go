@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultSource(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		assert.Equal(t, "team-a", r.Header.Get("X-Vault-Namespace"))
+		switch r.URL.Path {
+		case "/v1/kv/data/myapp/login":
+			w.Write([]byte(`{"data": {"data": {"username": "ada", "password": "hunter2"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/data/myapp/no-password":
+			w.Write([]byte(`{"data": {"data": {"username": "ada"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer vault.Close()
+
+	resolver, err := NewResolver(config.CredentialSourcesConfig{Vault: config.VaultConfig{
+		Address: vault.URL, Token: "s.token", Mount: "kv", Namespace: "team-a",
+	}}, nil)
+	require.NoError(t, err)
+
+	creds, err := resolver.Resolve(context.Background(), "vault:myapp/login")
+	require.NoError(t, err)
+	assert.Equal(t, &taskstypes.Credentials{Username: "ada", Password: "hunter2"}, creds)
+	_, err = resolver.Resolve(context.Background(), "vault:myapp/other")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = resolver.Resolve(context.Background(), "vault:myapp/no-password")
+	assert.ErrorContains(t, err, "no password")
+
+	denied, err := newVaultSource(config.VaultConfig{Address: vault.URL, Token: "wrong", Namespace: "team-a"})
+	require.NoError(t, err)
+	_, err = denied.lookup(context.Background(), "myapp/login")
+	assert.ErrorContains(t, err, "permission denied")
+
+	t.Setenv("VAULT_TOKEN", "")
+	_, err = newVaultSource(config.VaultConfig{Address: vault.URL})
+	assert.Error(t, err, "a token is required")
+	_, err = newVaultSource(config.VaultConfig{Address: "vault.internal:8200", Token: "x"})
+	assert.Error(t, err, "the address needs a scheme")
+}
+
+func TestVaultSource_Rotate(t *testing.T) {
+	secret := map[string]interface{}{"username": "ada", "password": "hunter2", "new_password": "correct horse", "url": "https://crm.example"}
+	version := 3
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/secret/data/myapp/login", r.URL.Path)
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": secret, "metadata": map[string]int{"version": version}}})
+			return
+		}
+		var write struct {
+			Options struct{ CAS int }
+			Data    map[string]interface{}
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&write))
+		if write.Options.CAS != version {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors": ["check-and-set parameter did not match the current version"]}`))
+			return
+		}
+		secret, version = write.Data, version+1
+		w.Write([]byte(`{"data": {"version": 4}}`))
+	}))
+	defer vault.Close()
+
+	resolver, err := NewResolver(config.CredentialSourcesConfig{Vault: config.VaultConfig{Address: vault.URL, Token: "s.token"}}, nil)
+	require.NoError(t, err)
+	require.NoError(t, resolver.Rotate(context.Background(), "vault:myapp/login", "ada", "hunter2", "correct horse"))
+	assert.Equal(t, map[string]interface{}{"username": "ada", "password": "correct horse", "url": "https://crm.example"}, secret)
+	assert.Equal(t, 4, version)
+
+	err = resolver.Rotate(context.Background(), "vault:myapp/login", "ada", "hunter2", "another")
+	assert.ErrorIs(t, err, ErrStaleSecret, "the secret no longer holds the old password")
+	assert.Equal(t, "correct horse", secret["password"])
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// HandleListTaskArtifacts lists the screenshots, downloads, and HAR archive
+// saved for a task. A task still running may gain more.
+func (h *APIHandler) HandleListTaskArtifacts(w http.ResponseWriter, r *http.Request) {
+	task, ok := h.artifactTask(w, r)
+	if !ok {
+		return
+	}
+	objects, err := h.taskManager.Artifacts().List(r.Context(), task.ID)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to list artifacts: %v", err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, objects)
+}
+
+// HandleGetTaskArtifact downloads one of a task's artifacts by name.
+func (h *APIHandler) HandleGetTaskArtifact(w http.ResponseWriter, r *http.Request) {
+	task, ok := h.artifactTask(w, r)
+	if !ok {
+		return
+	}
+	name := chi.URLParam(r, "name")
+	h.serveArtifact(w, r, task.ID, name, name)
+}
+
+// artifactTask looks up the task named in the URL, responding with an error
+// if there is none.
+func (h *APIHandler) artifactTask(w http.ResponseWriter, r *http.Request) (*taskstypes.Task, bool) {
+	taskID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid task ID format")
+		return nil, false
+	}
+	task, err := h.callerTask(r, taskID)
+	if err != nil {
+		if errors.Is(err, tasks.ErrTaskNotFound) {
+			h.respondError(w, r, http.StatusNotFound, "Task not found")
+		} else {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to get task: %v", err)
+		}
+		return nil, false
+	}
+	return task, true
+}
+
+// serveArtifact streams a task's artifact as an attachment called filename.
+// Backends whose readers can seek also serve range and conditional requests.
+func (h *APIHandler) serveArtifact(w http.ResponseWriter, r *http.Request, taskID uuid.UUID, name, filename string) {
+	body, obj, err := h.taskManager.Artifacts().Get(r.Context(), taskID, name)
+	if err != nil {
+		if errors.Is(err, artifacts.ErrNotFound) {
+			h.respondError(w, r, http.StatusNotFound, "Artifact %s not found", name)
+		} else {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to read artifact: %v", err)
+		}
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", obj.ModifiedAt, seeker)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to send artifact", logging.TaskIDKey, taskID, "artifact", name, "error", err)
+	}
+}
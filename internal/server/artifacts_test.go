@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskArtifactEndpoints(t *testing.T) {
+	logger := logging.Discard()
+	cfg := &config.Config{Browser: config.BrowserConfig{Artifacts: config.ArtifactConfig{Dir: t.TempDir()}}}
+	manager := tasks.NewManager(cfg, mocks.NewMockBrowserExecutor(), logger)
+	h := NewAPIHandler(manager, logger)
+	router := chi.NewRouter()
+	router.Get("/tasks/{taskID}/artifacts", h.HandleListTaskArtifacts)
+	router.Get("/tasks/{taskID}/artifacts/{name}", h.HandleGetTaskArtifact)
+
+	task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, CreatedAt: time.Now()}
+	require.NoError(t, manager.SubmitTask(task))
+	_, err := manager.WaitTask(context.Background(), task.ID)
+	require.NoError(t, err)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	base := "/tasks/" + task.ID.String() + "/artifacts"
+
+	rec := get(base)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	_, err = manager.Artifacts().Put(context.Background(), task.ID, "screenshot-0.png", strings.NewReader("png data"))
+	require.NoError(t, err)
+	_, err = manager.Artifacts().Put(context.Background(), task.ID, "download-report.csv", strings.NewReader("a,b\n"))
+	require.NoError(t, err)
+
+	rec = get(base)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list []artifacts.Object
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list, 2)
+	assert.Equal(t, "download-report.csv", list[0].Name)
+	assert.Equal(t, "screenshot-0.png", list[1].Name)
+	assert.Equal(t, int64(8), list[1].Size)
+
+	rec = get(base + "/screenshot-0.png")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "screenshot-0.png")
+	assert.Equal(t, "png data", rec.Body.String())
+
+	// Range requests are served by the local backend
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, base+"/screenshot-0.png", nil)
+	req.Header.Set("Range", "bytes=0-2")
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "png", rec.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get(base+"/missing.png").Code)
+	assert.Equal(t, http.StatusNotFound, get(base+"/..%2F..%2Fsecret").Code)
+	assert.Equal(t, http.StatusNotFound, get("/tasks/"+uuid.NewString()+"/artifacts").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/tasks/not-a-uuid/artifacts").Code)
+}
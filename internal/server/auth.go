@@ -0,0 +1,408 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/secrets"
+)
+
+// Role controls which API routes a caller may use. Roles are ordered:
+// each role is allowed everything the roles below it are.
+type Role int
+
+const (
+	RoleNone      Role = iota
+	RoleViewer         // Read task status, results, templates, and stats
+	RoleSubmitter      // Viewer + submit tasks, provide 2FA codes, run templates
+	RoleAdmin          // Submitter + manage templates and other server configuration
+)
+
+var roleNames = map[string]Role{
+	"viewer":    RoleViewer,
+	"submitter": RoleSubmitter,
+	"admin":     RoleAdmin,
+}
+
+// roleAliases are accepted in config alongside the role names.
+var roleAliases = map[string]Role{
+	"read-only":   RoleViewer,
+	"submit-only": RoleSubmitter,
+}
+
+func (r Role) String() string {
+	for name, role := range roleNames {
+		if role == r {
+			return name
+		}
+	}
+	return "none"
+}
+
+// ParseRole converts a configured role name, or its alias read-only or
+// submit-only, into a Role.
+func ParseRole(name string) (Role, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if role, ok := roleNames[key]; ok {
+		return role, nil
+	}
+	if role, ok := roleAliases[key]; ok {
+		return role, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q (expected viewer, submitter, or admin)", name)
+}
+
+// Principal identifies an authenticated caller.
+type Principal struct {
+	Name           string
+	Role           Role
+	Tenant         string   // Whose encryption key seals the caller's tasks; empty uses the default key
+	CredentialRefs []string // credentials_ref prefixes the caller may use; see secrets.CheckAllowed
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the caller attached by Authenticate, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// requestTenant returns the encryption tenant of the caller r authenticated
+// as, or "" for requests without one, such as webhooks.
+func requestTenant(r *http.Request) string {
+	p, _ := PrincipalFromContext(r.Context())
+	return p.Tenant
+}
+
+// readableTenant returns the tenant whose tasks the caller r authenticated
+// as may read, or "" when it may read every tenant's, as admins may.
+func readableTenant(r *http.Request) string {
+	p, _ := PrincipalFromContext(r.Context())
+	if p.Role >= RoleAdmin {
+		return ""
+	}
+	if p.Tenant == "" {
+		return encryption.DefaultTenant
+	}
+	return p.Tenant
+}
+
+// ownerTenant returns the tenant the sessions and profiles the caller r
+// creates belong to.
+func ownerTenant(r *http.Request) string {
+	if tenant := requestTenant(r); tenant != "" {
+		return tenant
+	}
+	return encryption.DefaultTenant
+}
+
+// mayUse reports whether the caller r may see and use a session or profile
+// owned by owner. Admins may use any; profiles saved before owners were
+// recorded belong to the default tenant.
+func mayUse(r *http.Request, owner string) bool {
+	if owner == "" {
+		owner = encryption.DefaultTenant
+	}
+	tenant := readableTenant(r)
+	return tenant == "" || tenant == owner
+}
+
+// checkCredentialsRef rejects a credentials_ref the caller r authenticated
+// as may not use, so it cannot have other callers' secrets typed into a
+// page it controls.
+func checkCredentialsRef(r *http.Request, ref string) error {
+	if ref == "" {
+		return nil
+	}
+	p, _ := PrincipalFromContext(r.Context())
+	return secrets.CheckAllowed(ref, p.CredentialRefs)
+}
+
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// apiKeyEntry is a configured key with its resolved role.
+type apiKeyEntry struct {
+	name   string
+	key    string
+	role   Role
+	tenant string
+	refs   []string
+	limit  *tokenBucket // nil when the key is not rate limited
+}
+
+// Authenticator resolves callers from API keys, HS256 JWTs, or HMAC-signed requests.
+type Authenticator struct {
+	keys        []apiKeyEntry
+	jwtSecret   []byte
+	roleClaim   string
+	tenantClaim string
+	jwtRefs     map[Role][]string // security.jwt.credentialRefs
+	signer      *requestSigner
+
+	ips     *clientLimiter // security.rateLimit.perIP
+	clients *clientLimiter // security.rateLimit.perClient, for callers without a limit of their own
+	open    bool           // No credentials are configured; only the address limit applies
+}
+
+// NewAuthenticator builds an authenticator from the security config. The legacy
+// security.apiKey is treated as an admin key that may use any credentials
+// reference. It returns nil when no credentials or rate limits are
+// configured, meaning the API is open and every caller is an admin.
+func NewAuthenticator(cfg config.SecurityConfig) (*Authenticator, error) {
+	a := &Authenticator{roleClaim: cfg.JWT.RoleClaim, tenantClaim: cfg.JWT.TenantClaim}
+	if a.roleClaim == "" {
+		a.roleClaim = "role"
+	}
+	if a.tenantClaim == "" {
+		a.tenantClaim = "tenant"
+	}
+	var err error
+	if a.ips, err = newClientLimiter(cfg.RateLimit.PerIP, cfg.RateLimit.PerIPBurst); err != nil {
+		return nil, fmt.Errorf("security.rateLimit.perIP: %w", err)
+	}
+	if a.clients, err = newClientLimiter(cfg.RateLimit.PerClient, cfg.RateLimit.PerClientBurst); err != nil {
+		return nil, fmt.Errorf("security.rateLimit.perClient: %w", err)
+	}
+
+	if cfg.ApiKey != "" {
+		a.keys = append(a.keys, apiKeyEntry{name: "default", key: cfg.ApiKey, role: RoleAdmin, tenant: encryption.DefaultTenant, refs: []string{secrets.AllowAll}})
+	}
+	for i, k := range cfg.ApiKeys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("security.apiKeys[%d] has an empty key", i)
+		}
+		role, err := ParseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("security.apiKeys[%d]: %w", i, err)
+		}
+		name := k.Name
+		if name == "" {
+			name = fmt.Sprintf("key-%d", i)
+		}
+		tenant := k.Tenant
+		if tenant == "" {
+			tenant = name
+		}
+		entry := apiKeyEntry{name: name, key: k.Key, role: role, tenant: tenant, refs: k.CredentialRefs}
+		if k.RateLimit != "" {
+			rate, err := parseRateLimit(k.RateLimit)
+			if err != nil {
+				return nil, fmt.Errorf("security.apiKeys[%d]: %w", i, err)
+			}
+			entry.limit = newTokenBucket(rate, k.Burst)
+		}
+		a.keys = append(a.keys, entry)
+	}
+	if cfg.JWT.Secret != "" {
+		a.jwtSecret = []byte(cfg.JWT.Secret)
+	}
+	for name, refs := range cfg.JWT.CredentialRefs {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("security.jwt.credentialRefs: %w", err)
+		}
+		if a.jwtRefs == nil {
+			a.jwtRefs = make(map[Role][]string)
+		}
+		a.jwtRefs[role] = refs
+	}
+
+	signer, err := newRequestSigner(cfg.HMAC)
+	if err != nil {
+		return nil, err
+	}
+	a.signer = signer
+
+	if len(a.keys) == 0 && a.jwtSecret == nil && a.signer == nil {
+		if a.ips == nil {
+			return nil, nil
+		}
+		a.open = true
+	}
+	return a, nil
+}
+
+// anonymous is every caller of an API without credentials.
+var anonymous = Principal{Name: "anonymous", Role: RoleAdmin, CredentialRefs: []string{secrets.AllowAll}}
+
+// Authenticate attaches the caller's Principal to the request context.
+// Credentials come from X-API-Key, a Bearer token, or the password of HTTP
+// Basic auth, which is how WebDriver clients send them.
+// Requests without credentials are rejected with 401, invalid ones with 403,
+// and those over their address's or caller's rate limit with 429 and
+// Retry-After. A nil authenticator admits every caller as an admin.
+func Authenticate(a *Authenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			// Allow pre-flight OPTIONS requests without auth
+			if r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if a == nil {
+				next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), anonymous)))
+				return
+			}
+
+			// The address limit comes first so floods of bad credentials are throttled too
+			if !allowAddress(w, r, a.ips) {
+				return
+			}
+			if a.open {
+				next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), anonymous)))
+				return
+			}
+
+			if r.Header.Get(HeaderSignature) != "" {
+				if a.signer == nil {
+					http.Error(w, http.StatusText(http.StatusForbidden)+": signed requests are not enabled", http.StatusForbidden)
+					return
+				}
+				principal, err := a.signer.verify(r)
+				if err != nil {
+					http.Error(w, http.StatusText(http.StatusForbidden)+": "+err.Error(), http.StatusForbidden)
+					return
+				}
+				if limit := a.clients.bucket("hmac:" + principal.Name); limit != nil {
+					if ok, wait := limit.take(); !ok {
+						tooManyRequests(w, wait, "rate limit for %q exceeded", principal.Name)
+						return
+					}
+				}
+				next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+				return
+			}
+
+			credential := r.Header.Get("X-API-Key")
+			if credential == "" {
+				// Check Authorization header as Bearer token as alternative
+				authHeader := r.Header.Get("Authorization")
+				if strings.HasPrefix(authHeader, "Bearer ") {
+					credential = strings.TrimPrefix(authHeader, "Bearer ")
+				} else if _, password, ok := r.BasicAuth(); ok {
+					credential = password
+				}
+			}
+			if credential == "" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized)+": API key required", http.StatusUnauthorized)
+				return
+			}
+
+			principal, limit, err := a.resolve(credential)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden)+": "+err.Error(), http.StatusForbidden)
+				return
+			}
+			if limit != nil {
+				if ok, wait := limit.take(); !ok {
+					tooManyRequests(w, wait, "rate limit for %q exceeded", principal.Name)
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireRole rejects callers whose role is below min with 403.
+// It must run after Authenticate.
+func RequireRole(min Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok || principal.Role < min {
+				http.Error(w, fmt.Sprintf("%s: %s role required", http.StatusText(http.StatusForbidden), min), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// resolve returns the caller a credential identifies, with the rate limiter
+// that applies to it if any: the API key's own, or else the per-client default.
+func (a *Authenticator) resolve(credential string) (Principal, *tokenBucket, error) {
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(credential), []byte(k.key)) == 1 {
+			limit := k.limit
+			if limit == nil {
+				limit = a.clients.bucket("key:" + k.name)
+			}
+			return Principal{Name: k.name, Role: k.role, Tenant: k.tenant, CredentialRefs: k.refs}, limit, nil
+		}
+	}
+	if a.jwtSecret != nil && strings.Count(credential, ".") == 2 {
+		principal, err := a.verifyJWT(credential)
+		if err != nil {
+			return principal, nil, err
+		}
+		return principal, a.clients.bucket("jwt:" + principal.Name), nil
+	}
+	return Principal{}, nil, fmt.Errorf("invalid API key")
+}
+
+// verifyJWT validates an HS256 token and maps its role claim to a Principal.
+func (a *Authenticator) verifyJWT(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Principal{}, fmt.Errorf("invalid token header")
+	}
+
+	mac := hmac.New(sha256.New, a.jwtSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+		return Principal{}, fmt.Errorf("invalid token signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("invalid token claims")
+	}
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return Principal{}, fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return Principal{}, fmt.Errorf("token not yet valid")
+	}
+
+	roleName, _ := claims[a.roleClaim].(string)
+	role, err := ParseRole(roleName)
+	if err != nil {
+		return Principal{}, fmt.Errorf("token has no valid %s claim", a.roleClaim)
+	}
+	subject, _ := claims["sub"].(string)
+	tenant, _ := claims[a.tenantClaim].(string)
+	if tenant == "" {
+		tenant = subject
+	}
+	return Principal{Name: subject, Role: role, Tenant: tenant, CredentialRefs: a.jwtRefs[role]}, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
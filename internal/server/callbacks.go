@@ -0,0 +1,15 @@
+package server
+
+import "net/http"
+
+// HandleListDeadLetters returns the most recent callback deliveries that
+// failed every attempt, newest first.
+func (h *APIHandler) HandleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.taskManager.DeadLetters())
+}
+
+// HandleListCallbackEndpoints returns the circuit breaker state of callback
+// endpoints with recent failures, most failures first.
+func (h *APIHandler) HandleListCallbackEndpoints(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.taskManager.CallbackEndpoints())
+}
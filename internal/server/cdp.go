@@ -0,0 +1,299 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/go-chi/chi/v5"
+)
+
+// cdpPath is where leased session browsers are proxied; the lease token follows it.
+const cdpPath = "/cdp/"
+
+const (
+	defaultCDPLeaseTTL = 10 * time.Minute
+	maxCDPLeaseTTL     = time.Hour
+)
+
+// CDPLeaseRequest asks to lend a session's browser to an outside CDP client.
+type CDPLeaseRequest struct {
+	TTL string `json:"ttl,omitempty"` // e.g. "15m"; defaults to 10m, at most 1h
+}
+
+// CDPLeaseResponse tells a CDP client where to connect. Both URLs carry the
+// lease token, which is the only credential the proxy checks.
+type CDPLeaseResponse struct {
+	Session    string    `json:"session"`
+	BrowserURL string    `json:"browser_url"` // DevTools HTTP endpoint, e.g. for puppeteer.connect({browserURL})
+	WSURL      string    `json:"ws_url"`      // Browser WebSocket, e.g. for puppeteer.connect({browserWSEndpoint})
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// cdpLease is a session browser currently lent out through the proxy.
+type cdpLease struct {
+	session   string
+	token     string
+	endpoint  string
+	expiresAt time.Time
+	ctx       context.Context // Done when the lease ends; proxied connections close with it
+	end       context.CancelFunc
+}
+
+// cdpLeases tracks the CDP leases handed out by the API.
+type cdpLeases struct {
+	mu        sync.Mutex
+	byToken   map[string]*cdpLease
+	bySession map[string]*cdpLease
+}
+
+func newCDPLeases() *cdpLeases {
+	return &cdpLeases{byToken: make(map[string]*cdpLease), bySession: make(map[string]*cdpLease)}
+}
+
+// start serves lease under a new token until it expires, is ended, or the
+// session revokes it, then hands the session back.
+func (l *cdpLeases) start(session string, lease *tasks.CDPLease, ttl time.Duration) (*cdpLease, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ttl)
+	cl := &cdpLease{
+		session:   session,
+		token:     base64.RawURLEncoding.EncodeToString(token),
+		endpoint:  lease.Endpoint,
+		expiresAt: time.Now().UTC().Add(ttl),
+		ctx:       ctx,
+		end:       cancel,
+	}
+
+	l.mu.Lock()
+	l.byToken[cl.token] = cl
+	l.bySession[session] = cl
+	l.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-lease.Revoked:
+			cancel()
+		}
+		l.mu.Lock()
+		delete(l.byToken, cl.token)
+		if l.bySession[session] == cl {
+			delete(l.bySession, session)
+		}
+		l.mu.Unlock()
+		lease.Release()
+	}()
+	return cl, nil
+}
+
+// lookup returns the live lease for token, or nil.
+func (l *cdpLeases) lookup(token string) *cdpLease {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cl := l.byToken[token]
+	if cl == nil || cl.ctx.Err() != nil {
+		return nil
+	}
+	return cl
+}
+
+// end ends the session's lease, reporting whether it had one.
+func (l *cdpLeases) end(session string) bool {
+	l.mu.Lock()
+	cl := l.bySession[session]
+	l.mu.Unlock()
+	if cl == nil {
+		return false
+	}
+	cl.end()
+	return true
+}
+
+// HandleLeaseSessionCDP lends a session's browser to an outside CDP client,
+// such as a Puppeteer script or Lighthouse, through the /cdp/ proxy. Tasks on
+// the session wait until the lease is ended or expires.
+func (h *APIHandler) HandleLeaseSessionCDP(w http.ResponseWriter, r *http.Request) {
+	var req CDPLeaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	ttl := defaultCDPLeaseTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 || ttl > maxCDPLeaseTTL {
+			h.respondError(w, r, http.StatusBadRequest, "ttl must be a positive duration of at most %s", maxCDPLeaseTTL)
+			return
+		}
+	}
+
+	cdp, err := h.taskManager.CDP()
+	if err != nil {
+		h.respondError(w, r, http.StatusNotImplemented, "%v", err)
+		return
+	}
+	name := chi.URLParam(r, "name")
+	if !h.callerMayUseSession(w, r, name) {
+		return
+	}
+	lease, err := cdp.LeaseCDP(name)
+	if err != nil {
+		h.respondSessionError(w, r, err)
+		return
+	}
+	cl, err := h.cdp.start(name, lease, ttl)
+	if err != nil {
+		lease.Release()
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to create CDP lease: %v", err)
+		return
+	}
+
+	wsPath, err := cdpBrowserPath(r.Context(), cl.endpoint)
+	if err != nil {
+		cl.end()
+		h.respondError(w, r, http.StatusBadGateway, "Session browser did not answer over CDP: %v", err)
+		return
+	}
+	httpBase, wsBase := cdpPublicURLs(r, cl.token)
+	h.logger.InfoContext(r.Context(), "Session lent out over CDP", "session", name, "expires_at", cl.expiresAt)
+	h.respondJSON(w, http.StatusCreated, CDPLeaseResponse{
+		Session:    name,
+		BrowserURL: httpBase,
+		WSURL:      wsBase + wsPath,
+		ExpiresAt:  cl.expiresAt,
+	})
+}
+
+// HandleEndSessionCDP hands a leased session back to tasks and closes the
+// outside client's connections to it.
+func (h *APIHandler) HandleEndSessionCDP(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !h.callerMayUseSession(w, r, name) {
+		return
+	}
+	if !h.cdp.end(name) {
+		h.respondError(w, r, http.StatusNotFound, "Session %s is not lent out over CDP", name)
+		return
+	}
+	h.logger.InfoContext(r.Context(), "CDP lease ended", "session", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleCDPProxy forwards DevTools HTTP and WebSocket traffic for the lease
+// named by the path's token to the session's browser.
+func (h *APIHandler) HandleCDPProxy(w http.ResponseWriter, r *http.Request) {
+	cl := h.cdp.lookup(chi.URLParam(r, "token"))
+	if cl == nil {
+		h.respondError(w, r, http.StatusNotFound, "Unknown or expired CDP lease")
+		return
+	}
+
+	// A CDP connection outlives the request timeout and lasts until the lease ends
+	ctx, stop := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer stop()
+	defer context.AfterFunc(cl.ctx, stop)()
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	httpBase, wsBase := cdpPublicURLs(r, cl.token)
+	publicHost := strings.TrimPrefix(strings.TrimPrefix(httpBase, "https://"), "http://")
+	prefix := cdpPath + cl.token
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host = cl.endpoint
+			pr.Out.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(pr.In.URL.Path, prefix), "/")
+			pr.Out.URL.RawPath = ""
+			// Chrome rejects Host headers other than an IP or localhost, and
+			// WebSocket upgrades that carry an Origin
+			pr.Out.Host = cl.endpoint
+			for _, header := range []string{"Origin", "Authorization", "X-API-Key", "Cookie"} {
+				pr.Out.Header.Del(header)
+			}
+		},
+		// Point the URLs in /json responses back through the proxy
+		ModifyResponse: func(resp *http.Response) error {
+			if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+				return nil
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return err
+			}
+			body = bytes.ReplaceAll(body, []byte("ws://"+cl.endpoint), []byte(wsBase))
+			body = bytes.ReplaceAll(body, []byte(cl.endpoint), []byte(publicHost))
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			resp.ContentLength = int64(len(body))
+			resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			return nil
+		},
+		ErrorLog: slog.NewLogLogger(h.logger.Handler(), slog.LevelDebug),
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			h.respondError(w, r, http.StatusBadGateway, "CDP proxy error: %v", err)
+		},
+	}
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// cdpPublicURLs returns the proxy's HTTP and WebSocket base URLs for token as
+// the client reached this server.
+func cdpPublicURLs(r *http.Request, token string) (httpBase, wsBase string) {
+	secure := r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+	base := r.Host + cdpPath + token
+	if secure {
+		return "https://" + base, "wss://" + base
+	}
+	return "http://" + base, "ws://" + base
+}
+
+// cdpBrowserPath asks the browser at endpoint for the path of its
+// browser-level DevTools WebSocket.
+func cdpBrowserPath(ctx context.Context, endpoint string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+endpoint+"/json/version", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("/json/version returned %s", resp.Status)
+	}
+	var version struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(version.WebSocketDebuggerURL)
+	if err != nil || u.Path == "" {
+		return "", fmt.Errorf("invalid webSocketDebuggerUrl %q", version.WebSocketDebuggerURL)
+	}
+	return u.Path, nil
+}
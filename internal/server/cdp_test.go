@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// cdpExecutor lends out sessions whose browser is a fake DevTools server.
+type cdpExecutor struct {
+	*wdExecutor
+	endpoint string
+
+	leaseMu  sync.Mutex
+	revoke   chan struct{}
+	released chan struct{}
+}
+
+var _ tasks.CDPExecutor = (*cdpExecutor)(nil)
+
+func (e *cdpExecutor) LeaseCDP(name string) (*tasks.CDPLease, error) {
+	if _, err := e.GetSession(name); err != nil {
+		return nil, err
+	}
+	e.leaseMu.Lock()
+	defer e.leaseMu.Unlock()
+	if e.revoke != nil {
+		return nil, tasks.ErrSessionBusy
+	}
+	revoke, released := make(chan struct{}), make(chan struct{})
+	e.revoke, e.released = revoke, released
+	var once sync.Once
+	return &tasks.CDPLease{Endpoint: e.endpoint, Revoked: revoke, Release: func() {
+		once.Do(func() {
+			e.leaseMu.Lock()
+			e.revoke = nil
+			e.leaseMu.Unlock()
+			close(released)
+		})
+	}}, nil
+}
+
+// newFakeDevTools serves /json/version and an echoing browser WebSocket the
+// way Chrome does, failing requests Chrome would refuse.
+func newFakeDevTools(t *testing.T) *httptest.Server {
+	var endpoint string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json/version", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, endpoint, r.Host)
+		assert.Empty(t, r.Header.Get("X-API-Key"), "GoScry credentials are not forwarded")
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		json.NewEncoder(w).Encode(map[string]string{
+			"Browser":              "HeadlessChrome/135.0.0.0",
+			"webSocketDebuggerUrl": "ws://" + endpoint + "/devtools/browser/4f1c",
+		})
+	})
+	mux.Handle("/devtools/browser/4f1c", websocket.Server{Handler: func(ws *websocket.Conn) {
+		if ws.Request().Header.Get("Origin") != "" {
+			return
+		}
+		io.Copy(ws, ws)
+	}})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	endpoint = strings.TrimPrefix(srv.URL, "http://")
+	return srv
+}
+
+func TestCDPProxy(t *testing.T) {
+	devtools := newFakeDevTools(t)
+	cfg := &config.Config{Browser: config.BrowserConfig{Artifacts: config.ArtifactConfig{Dir: t.TempDir()}}}
+	cfg.Server.CDPProxy.Enabled = true
+	cfg.Server.Compression = config.CompressionConfig{Enabled: true, Level: 5}
+	// The fake executor's sessions have no owner, so belong to the default tenant
+	cfg.Security.ApiKeys = []config.APIKeyConfig{{Key: "submit-key", Role: "submitter", Tenant: "default"}}
+	executor := &cdpExecutor{
+		wdExecutor: &wdExecutor{store: artifacts.NewLocalStore(cfg.Browser.Artifacts.Dir), sessions: map[string]bool{"shop": true}},
+		endpoint:   strings.TrimPrefix(devtools.URL, "http://"),
+	}
+	logger := logging.Discard()
+	srv := httptest.NewServer(NewServer(cfg, tasks.NewManager(cfg, executor, logger), logger).httpServer.Handler)
+	defer srv.Close()
+
+	api := func(method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("X-API-Key", "submit-key")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	lease := func() CDPLeaseResponse {
+		resp := api(http.MethodPost, "/api/v1/sessions/shop/cdp", `{"ttl":"5m"}`)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var out CDPLeaseResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out
+	}
+
+	out := lease()
+	assert.Equal(t, "shop", out.Session)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), out.ExpiresAt, time.Minute)
+	base := strings.TrimPrefix(srv.URL, "http://") + cdpPath
+	require.True(t, strings.HasPrefix(out.BrowserURL, "http://"+base), out.BrowserURL)
+	assert.Equal(t, "ws://"+strings.TrimPrefix(out.BrowserURL, "http://")+"/devtools/browser/4f1c", out.WSURL)
+
+	resp := api(http.MethodPost, "/api/v1/sessions/shop/cdp", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode, "a session is lent to one client at a time")
+	resp = api(http.MethodPost, "/api/v1/sessions/cart/cdp", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = api(http.MethodPost, "/api/v1/sessions/shop/cdp", `{"ttl":"3h"}`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// The DevTools HTTP endpoint answers without API credentials and points back through the proxy
+	resp, err := http.Get(out.BrowserURL + "/json/version")
+	require.NoError(t, err)
+	var version map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&version))
+	resp.Body.Close()
+	assert.Equal(t, out.WSURL, version["webSocketDebuggerUrl"])
+
+	ws, err := websocket.Dial(out.WSURL, "", "http://localhost/")
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, websocket.Message.Send(ws, `{"id":1,"method":"Browser.getVersion"}`))
+	var echo string
+	require.NoError(t, websocket.Message.Receive(ws, &echo))
+	assert.Equal(t, `{"id":1,"method":"Browser.getVersion"}`, echo)
+
+	// Ending the lease hands the session back and drops the client
+	resp = api(http.MethodDelete, "/api/v1/sessions/shop/cdp", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	select {
+	case <-executor.released:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease was not released")
+	}
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	assert.Error(t, websocket.Message.Receive(ws, &echo))
+	resp, err = http.Get(out.BrowserURL + "/json/version")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = api(http.MethodDelete, "/api/v1/sessions/shop/cdp", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Closing the session revokes a lease
+	out = lease()
+	executor.leaseMu.Lock()
+	close(executor.revoke)
+	executor.leaseMu.Unlock()
+	select {
+	case <-executor.released:
+	case <-time.After(5 * time.Second):
+		t.Fatal("revoked lease was not released")
+	}
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(out.BrowserURL + "/json/version")
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusNotFound
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// compressedTypes are the response content types worth compressing. Images
+// and downloads are already compressed or served elsewhere.
+var compressedTypes = []string{"application/json", "text/*"}
+
+// Compress encodes JSON and text responses with brotli, gzip, or deflate,
+// whichever the client accepts first in that order. Large DOM and AST
+// responses typically shrink by 10x or more. Responses are compressed as they
+// are written, so streamed bodies stay streamed.
+func Compress(cfg config.CompressionConfig) func(http.Handler) http.Handler {
+	level := cfg.Level
+	if level < 1 || level > 9 {
+		level = 5
+	}
+	compressor := middleware.NewCompressor(level, compressedTypes...)
+	// Registered last, so it takes precedence over gzip and deflate
+	compressor.SetEncoder("br", func(w io.Writer, level int) io.Writer {
+		return brotli.NewWriterLevel(w, level)
+	})
+	return compressor.Handler
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	body := `{"html":"` + strings.Repeat("<div class=\"item\">x</div>", 2000) + `"}`
+	h := Compress(config.CompressionConfig{Enabled: true, Level: 5})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/image" {
+			w.Header().Set("Content-Type", "image/png")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		io.WriteString(w, body)
+	}))
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/", "gzip, deflate, br")
+	assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	assert.Less(t, rec.Body.Len(), len(body)/10)
+	decoded, err := io.ReadAll(brotli.NewReader(rec.Body))
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+
+	rec = get("/", "gzip")
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	decoded, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+
+	rec = get("/", "")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, rec.Body.String())
+
+	rec = get("/image", "gzip, br")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, rec.Body.String())
+}
@@ -0,0 +1,9 @@
+package server
+
+import "net/http"
+
+// HandleDomainHealth scores the target domains of recent tasks, least
+// healthy first, with the state of each domain's circuit breaker.
+func (h *APIHandler) HandleDomainHealth(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.taskManager.DomainHealth())
+}
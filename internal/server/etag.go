@@ -0,0 +1,61 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// contentETag returns a weak ETag over the JSON that write produces. It is
+// weak because Compress may re-encode the body without changing the ETag.
+func contentETag(write func(io.Writer) error) (string, error) {
+	h := sha256.New()
+	if err := write(h); err != nil {
+		return "", err
+	}
+	return `W/"` + base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:18]) + `"`, nil
+}
+
+// etagMatches reports whether the request's If-None-Match lists etag, using
+// the weak comparison RFC 9110 specifies for If-None-Match.
+func etagMatches(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// respondConditional sends the JSON body write produces with an ETag, or
+// 304 Not Modified with no body when the client already has it. The body is
+// hashed as it is encoded, so it is never held in memory whole.
+func (h *APIHandler) respondConditional(w http.ResponseWriter, r *http.Request, write func(io.Writer) error) {
+	etag, err := contentETag(write)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to encode response: %v", err)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache") // Clients may keep it but must revalidate
+	if etagMatches(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.respondStream(w, http.StatusOK, write)
+}
+
+// jsonWriter returns a write function for respondConditional that encodes v with encoding/json.
+func jsonWriter(v interface{}) func(io.Writer) error {
+	return func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtagMatches(t *testing.T) {
+	const etag = `W/"abc"`
+	cases := map[string]bool{
+		"":                 false,
+		`W/"abc"`:          true,
+		`"abc"`:            true,
+		`"xyz", W/"abc"`:   true,
+		"*":                true,
+		`"abcd"`:           false,
+		`W/"xyz",  "def" `: false,
+	}
+	for header, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set("If-None-Match", header)
+		}
+		assert.Equal(t, want, etagMatches(req, etag), header)
+	}
+}
+
+func TestRespondConditional(t *testing.T) {
+	h := &APIHandler{logger: logging.Discard()}
+	payload := map[string]string{"status": "completed", "data": "<html>...</html>"}
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		h.respondConditional(rec, req, jsonWriter(payload))
+		return rec
+	}
+
+	first := get("")
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.Regexp(t, `^W/"[A-Za-z0-9_-]+"$`, etag)
+	assert.JSONEq(t, `{"status":"completed","data":"<html>...</html>"}`, first.Body.String())
+
+	cached := get(etag)
+	assert.Equal(t, http.StatusNotModified, cached.Code)
+	assert.Equal(t, etag, cached.Header().Get("ETag"))
+	assert.Empty(t, cached.Body.String())
+
+	payload["status"] = "failed"
+	changed := get(etag)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.NotEqual(t, etag, changed.Header().Get("ETag"))
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/copyleftdev/goscry/internal/examples"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+)
+
+// RunExampleRequest submits a task from an example. The example supplies
+// the actions; the rest is as for a task.
+type RunExampleRequest struct {
+	Credentials          *taskstypes.Credentials       `json:"credentials,omitempty"`
+	EncryptedCredentials *taskstypes.SealedCredentials `json:"encrypted_credentials,omitempty"`
+	CredentialsRef       string                        `json:"credentials_ref,omitempty"`
+	TwoFactorAuth        taskstypes.TwoFactorAuthInfo  `json:"two_factor_auth"`
+	CallbackURL          string                        `json:"callback_url,omitempty"`
+	CallbackFields       []string                      `json:"callback_fields,omitempty"`
+	TFAWebhook           string                        `json:"tfa_webhook,omitempty"`
+	Session              string                        `json:"session,omitempty"`
+	Options              taskstypes.TaskOptions        `json:"options"`
+	Tags                 map[string]string             `json:"tags,omitempty"`
+	ReferenceID          string                        `json:"reference_id,omitempty"`
+	Priority             taskstypes.TaskPriority       `json:"priority,omitempty"`
+}
+
+// HandleListExamples returns the example gallery.
+func (h *APIHandler) HandleListExamples(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, examples.All())
+}
+
+// HandleGetExample returns one example.
+func (h *APIHandler) HandleGetExample(w http.ResponseWriter, r *http.Request) {
+	example, ok := examples.Get(chi.URLParam(r, "name"))
+	if !ok {
+		h.respondError(w, r, http.StatusNotFound, "Example not found")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, example)
+}
+
+// HandleRunExample submits a task with an example's actions.
+func (h *APIHandler) HandleRunExample(w http.ResponseWriter, r *http.Request) {
+	example, ok := examples.Get(chi.URLParam(r, "name"))
+	if !ok {
+		h.respondError(w, r, http.StatusNotFound, "Example not found")
+		return
+	}
+	var req RunExampleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := checkCredentialSources(req.Credentials, req.EncryptedCredentials, req.CredentialsRef); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	if err := checkCredentialsRef(r, req.CredentialsRef); err != nil {
+		h.respondError(w, r, http.StatusForbidden, "%v", err)
+		return
+	}
+	if err := validateLabels(req.Tags, req.ReferenceID); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	if err := req.Priority.Validate(); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	if example.Credentials && req.Credentials == nil && req.EncryptedCredentials == nil && req.CredentialsRef == "" {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: example %s needs credentials", example.Name)
+		return
+	}
+
+	task := newTask(r, SubmitTaskRequest{
+		Actions:              example.Actions,
+		Credentials:          req.Credentials,
+		EncryptedCredentials: req.EncryptedCredentials,
+		CredentialsRef:       req.CredentialsRef,
+		TwoFactorAuth:        req.TwoFactorAuth,
+		CallbackURL:          req.CallbackURL,
+		CallbackFields:       req.CallbackFields,
+		TFAWebhook:           req.TFAWebhook,
+		Session:              req.Session,
+		Options:              req.Options,
+		Tags:                 req.Tags,
+		ReferenceID:          req.ReferenceID,
+		Priority:             req.Priority,
+	})
+	if err := checkTaskResources(r, h.taskManager, task); err != nil {
+		h.respondSubmitError(w, r, err)
+		return
+	}
+	if err := h.taskManager.SubmitTask(task); err != nil {
+		h.respondSubmitError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusAccepted, SubmitTaskResponse{TaskID: task.ID.String(), Warnings: task.Warnings})
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/examples"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExampleHandlers(t *testing.T) {
+	logger := logging.Discard()
+	h := NewAPIHandler(tasks.NewManager(&config.Config{}, mocks.NewMockBrowserExecutor(), logger), logger)
+	router := chi.NewRouter()
+	router.Get("/examples", h.HandleListExamples)
+	router.Get("/examples/{name}", h.HandleGetExample)
+	router.Post("/examples/{name}/run", h.HandleRunExample)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/examples", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list []examples.Example
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list, len(examples.All()))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/examples/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Every example that needs no credentials is accepted as it is
+	for _, example := range list {
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/examples/"+example.Name+"/run", strings.NewReader(`{}`)))
+		if example.Credentials {
+			assert.Equal(t, http.StatusBadRequest, rec.Code, example.Name)
+			assert.Contains(t, rec.Body.String(), "needs credentials")
+			continue
+		}
+		require.Equal(t, http.StatusAccepted, rec.Code, example.Name)
+		var resp SubmitTaskResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Empty(t, resp.Warnings, example.Name)
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// maxFieldPaths bounds how many paths one ?fields= parameter may list.
+const maxFieldPaths = 50
+
+// parseFields parses a ?fields= value such as "status,result.message" into
+// dotted paths. An empty value selects everything and returns nil.
+func parseFields(raw string) ([][]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var paths [][]string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		path := strings.Split(field, ".")
+		for _, part := range path {
+			if part == "" {
+				return nil, fmt.Errorf("invalid field %q", field)
+			}
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) > maxFieldPaths {
+		return nil, fmt.Errorf("too many fields (at most %d)", maxFieldPaths)
+	}
+	return paths, nil
+}
+
+// selectFields returns the parts of v's JSON form named by paths, keeping
+// their nesting. Paths apply to every element of an array, so they work the
+// same on one task and on a list of tasks. Fields that are absent are left
+// out. With no paths v is returned unchanged.
+func selectFields(v interface{}, paths [][]string) (interface{}, error) {
+	if len(paths) == 0 {
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // Keep large integers such as byte counts exact
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return projectFields(generic, paths), nil
+}
+
+func projectFields(value interface{}, paths [][]string) interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, elem := range value {
+			out[i] = projectFields(elem, paths)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{})
+		whole := make(map[string]bool)
+		nested := make(map[string][][]string)
+		for _, path := range paths {
+			child, ok := value[path[0]]
+			if !ok {
+				continue
+			}
+			if len(path) == 1 {
+				whole[path[0]] = true
+				out[path[0]] = child
+			} else if !whole[path[0]] {
+				nested[path[0]] = append(nested[path[0]], path[1:])
+			}
+		}
+		for name, subpaths := range nested {
+			if !whole[name] {
+				out[name] = projectFields(value[name], subpaths)
+			}
+		}
+		return out
+	default:
+		// A scalar or null where an object was expected is kept as it is
+		return value
+	}
+}
+
+// parseFull parses ?full=, which asks for result data in task responses.
+func parseFull(raw string) (bool, error) {
+	if raw == "" {
+		return false, nil
+	}
+	full, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid full: %s", raw)
+	}
+	return full, nil
+}
+
+// compactResult drops result data from a task snapshot, leaving its summary.
+func compactResult(task *taskstypes.Task) {
+	if task.Result == nil || task.Result.Data == nil {
+		return
+	}
+	if task.Result.Summary == nil {
+		task.Result.Summary = taskstypes.SummarizeData(task.Result.Data)
+	}
+	task.Result.Data = nil
+}
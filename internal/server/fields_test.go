@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFields(t *testing.T) {
+	paths, err := parseFields(" status, result.message ,,")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"status"}, {"result", "message"}}, paths)
+
+	paths, err = parseFields("")
+	require.NoError(t, err)
+	assert.Nil(t, paths)
+
+	for _, raw := range []string{"result.", ".status", "result..message", strings.Repeat("a,", maxFieldPaths+1)} {
+		_, err := parseFields(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	task := &taskstypes.Task{
+		ID:        uuid.MustParse("7f1c6c4e-3a59-4a0e-9a53-1d7c2b0f2f10"),
+		Status:    taskstypes.StatusCompleted,
+		Actions:   []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}},
+		CreatedAt: time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC),
+		Result: &taskstypes.TaskResult{
+			Success:    true,
+			Message:    "Task completed successfully",
+			Data:       "<html>...</html>",
+			CustomData: map[string]interface{}{"bytes": int64(9007199254740993)},
+		},
+	}
+
+	selected, err := selectFields(task, [][]string{{"status"}, {"result", "message"}, {"result", "custom_data", "bytes"}, {"missing"}})
+	require.NoError(t, err)
+	data, err := json.Marshal(selected)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"completed","result":{"message":"Task completed successfully","custom_data":{"bytes":9007199254740993}}}`, string(data))
+	assert.Contains(t, string(data), "9007199254740993", "large integers must stay exact")
+
+	// A whole field wins over paths inside it, and paths apply to each list element
+	selected, err = selectFields([]*taskstypes.Task{task, task}, [][]string{{"result", "message"}, {"result"}, {"actions", "type"}})
+	require.NoError(t, err)
+	list := selected.([]interface{})
+	require.Len(t, list, 2)
+	first := list[0].(map[string]interface{})
+	assert.Equal(t, "<html>...</html>", first["result"].(map[string]interface{})["data"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"type": "navigate"}}, first["actions"])
+
+	unchanged, err := selectFields(task, nil)
+	require.NoError(t, err)
+	assert.Same(t, task, unchanged)
+}
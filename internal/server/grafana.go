@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/tasks"
+)
+
+// minGrafanaStep keeps dashboards from bucketing task history finer than it is useful.
+const minGrafanaStep = time.Minute
+
+// GrafanaQueryRequest is the body Grafana's JSON datasource plugin posts to /query.
+type GrafanaQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs    int64                `json:"intervalMs"`
+	MaxDataPoints int                  `json:"maxDataPoints"`
+	Targets       []GrafanaQueryTarget `json:"targets"`
+}
+
+// GrafanaQueryTarget is one metric on a panel. Payload may narrow it to a
+// template or domain, e.g. {"template": "login"}.
+type GrafanaQueryTarget struct {
+	Target  string          `json:"target"`
+	RefID   string          `json:"refId"`
+	Hide    bool            `json:"hide,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// GrafanaSeries is a time series in the plugin's response format, with
+// datapoints as [value, unix milliseconds] pairs.
+type GrafanaSeries struct {
+	Target     string       `json:"target"`
+	RefID      string       `json:"refId,omitempty"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// GrafanaMetric is an entry in the plugin's metric picker.
+type GrafanaMetric struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// HandleGrafanaHealth answers the plugin's "Save & test" connection check.
+func (h *APIHandler) HandleGrafanaHealth(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// HandleGrafanaMetrics lists the metrics that can be queried.
+func (h *APIHandler) HandleGrafanaMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := make([]GrafanaMetric, 0, len(tasks.SeriesMetrics))
+	for _, metric := range tasks.SeriesMetrics {
+		metrics = append(metrics, GrafanaMetric{Label: metric, Value: metric})
+	}
+	h.respondJSON(w, http.StatusOK, metrics)
+}
+
+// HandleGrafanaSearch lists metric names for older plugin versions that call /search.
+func (h *APIHandler) HandleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, tasks.SeriesMetrics)
+}
+
+// HandleGrafanaQuery returns a time series for each visible target over the
+// requested range, bucketed by the panel's interval.
+func (h *APIHandler) HandleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var req GrafanaQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if !req.Range.To.After(req.Range.From) {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid range: from must be before to")
+		return
+	}
+
+	step := max(time.Duration(req.IntervalMs)*time.Millisecond, minGrafanaStep)
+	if req.MaxDataPoints > 0 {
+		step = max(step, req.Range.To.Sub(req.Range.From)/time.Duration(req.MaxDataPoints))
+	}
+
+	series := make([]GrafanaSeries, 0, len(req.Targets))
+	for _, target := range req.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+		var filter struct {
+			Template string `json:"template"`
+			Domain   string `json:"domain"`
+		}
+		if len(target.Payload) > 0 && string(target.Payload) != "null" {
+			if err := json.Unmarshal(target.Payload, &filter); err != nil {
+				h.respondError(w, r, http.StatusBadRequest, "Invalid payload for %s: %v", target.Target, err)
+				return
+			}
+		}
+		points, err := h.taskManager.Series(target.Target, req.Range.From, req.Range.To, step, tasks.SeriesFilter(filter))
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, tasks.ErrSeriesHistory) {
+				status = http.StatusInternalServerError
+			}
+			h.respondError(w, r, status, "Failed to query %s: %v", target.Target, err)
+			return
+		}
+		datapoints := make([][2]float64, len(points))
+		for i, p := range points {
+			datapoints[i] = [2]float64{p.Value, float64(p.Time.UnixMilli())}
+		}
+		series = append(series, GrafanaSeries{Target: target.Target, RefID: target.RefID, Datapoints: datapoints})
+	}
+	h.respondJSON(w, http.StatusOK, series)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleGrafanaQuery(t *testing.T) {
+	logger := logging.Discard()
+	manager := tasks.NewManager(nil, mocks.NewMockBrowserExecutor(), logger)
+	h := NewAPIHandler(manager, logger)
+
+	task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, CreatedAt: time.Now()}
+	require.NoError(t, manager.SubmitTask(task))
+	_, err := manager.WaitTask(context.Background(), task.ID)
+	require.NoError(t, err)
+
+	query := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.HandleGrafanaQuery(rec, httptest.NewRequest(http.MethodPost, "/grafana/query", strings.NewReader(body)))
+		return rec
+	}
+	now := time.Now().UTC()
+	body := `{"range": {"from": "` + now.Add(-time.Hour).Format(time.RFC3339) + `", "to": "` + now.Add(time.Minute).Format(time.RFC3339) + `"},
+		"intervalMs": 1000, "maxDataPoints": 10,
+		"targets": [{"target": "tasks_completed", "refId": "A"}, {"target": "tasks", "refId": "B", "hide": true}]}`
+
+	rec := query(body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var series []GrafanaSeries
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &series))
+	require.Len(t, series, 1, "hidden targets are skipped")
+	assert.Equal(t, "tasks_completed", series[0].Target)
+	assert.Equal(t, "A", series[0].RefID)
+	assert.LessOrEqual(t, len(series[0].Datapoints), 11, "maxDataPoints widens the step")
+	var total float64
+	for _, point := range series[0].Datapoints {
+		total += point[0]
+	}
+	assert.Equal(t, 1.0, total)
+
+	assert.Equal(t, http.StatusBadRequest, query(strings.Replace(body, "tasks_completed", "bogus", 1)).Code)
+	assert.Equal(t, http.StatusBadRequest, query(`{"range": {}}`).Code)
+
+	rec = httptest.NewRecorder()
+	h.HandleGrafanaMetrics(rec, httptest.NewRequest(http.MethodPost, "/grafana/metrics", nil))
+	var metrics []GrafanaMetric
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &metrics))
+	assert.Len(t, metrics, len(tasks.SeriesMetrics))
+}
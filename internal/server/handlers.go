@@ -5,12 +5,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/chromedp/cdproto/emulation"
 	"github.com/chromedp/chromedp"
+	"github.com/copyleftdev/goscry/internal/browser"
 	"github.com/copyleftdev/goscry/internal/dom"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/importer"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/secrets"
 	"github.com/copyleftdev/goscry/internal/tasks"
 	"github.com/copyleftdev/goscry/internal/taskstypes"
 	"github.com/go-chi/chi/v5"
@@ -19,108 +28,637 @@ import (
 
 type APIHandler struct {
 	taskManager *tasks.Manager
-	logger      *log.Logger
+	logger      *slog.Logger
+	cdp         *cdpLeases
+	hooks       map[string]*hook // Inbound webhooks by name
+	sms         *smsWebhook      // Inbound SMS codes; nil unless twoFactor.sms is set
 }
 
-func NewAPIHandler(tm *tasks.Manager, logger *log.Logger) *APIHandler {
+func NewAPIHandler(tm *tasks.Manager, logger *slog.Logger) *APIHandler {
 	return &APIHandler{
 		taskManager: tm,
 		logger:      logger,
+		cdp:         newCDPLeases(),
 	}
 }
 
 type SubmitTaskRequest struct {
-	Actions       []taskstypes.Action          `json:"actions"`
-	Credentials   *taskstypes.Credentials      `json:"credentials,omitempty"` // Sent in request, handled securely
-	TwoFactorAuth taskstypes.TwoFactorAuthInfo `json:"two_factor_auth"`
-	CallbackURL   string                       `json:"callback_url,omitempty"`
+	Actions              []taskstypes.Action           `json:"actions"`
+	Credentials          *taskstypes.Credentials       `json:"credentials,omitempty"`           // Sent in request, handled securely
+	EncryptedCredentials *taskstypes.SealedCredentials `json:"encrypted_credentials,omitempty"` // Sealed to GET /credentials/key; only the executor decrypts them
+	CredentialsRef       string                        `json:"credentials_ref,omitempty"`       // e.g. "vault:myapp/login"; looked up by the executor when the task runs
+	TwoFactorAuth        taskstypes.TwoFactorAuthInfo  `json:"two_factor_auth"`
+	CallbackURL          string                        `json:"callback_url,omitempty"`
+	CallbackFields       []string                      `json:"callback_fields,omitempty"` // Top-level fields the result callback carries, e.g. ["status", "result"]; empty sends all
+	TFAWebhook           string                        `json:"tfa_webhook,omitempty"`     // Where 2FA code requests are posted; defaults to callback_url
+	Session              string                        `json:"session,omitempty"`         // Named session to run in; see /sessions
+	Options              taskstypes.TaskOptions        `json:"options"`
+	Tags                 map[string]string             `json:"tags,omitempty"`         // Returned with the task and in callbacks; filter with ?tag=key:value
+	ReferenceID          string                        `json:"reference_id,omitempty"` // The caller's own job ID; filter with ?reference_id=
+	Priority             taskstypes.TaskPriority       `json:"priority,omitempty"`     // high, normal (default), or low
+}
+
+// CredentialKeyResponse publishes the key clients seal task credentials to.
+type CredentialKeyResponse struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"` // PEM-encoded SubjectPublicKeyInfo
 }
 
 type SubmitTaskResponse struct {
-	TaskID string `json:"task_id"`
+	TaskID   string   `json:"task_id"`
+	Warnings []string `json:"warnings,omitempty"` // Problems fixed up at submission
 }
 
 type Provide2FACodeRequest struct {
 	Code string `json:"code"`
 }
 
+type EstimateTaskRequest struct {
+	Actions []taskstypes.Action `json:"actions"`
+}
+
+// maxBulkStatusIDs bounds how many tasks one bulk status request may ask for.
+const maxBulkStatusIDs = 100
+
+type BulkStatusRequest struct {
+	TaskIDs []string `json:"task_ids"`
+}
+
+// BulkStatusResponse lists the requested tasks in request order. IDs that are
+// not known are listed in NotFound instead.
+type BulkStatusResponse struct {
+	Tasks    []interface{} `json:"tasks"`
+	NotFound []string      `json:"not_found,omitempty"`
+}
+
 type GetDomASTRequest struct {
 	URL            string `json:"url"`
 	ParentSelector string `json:"parent_selector,omitempty"`
+	JavaScript     *bool  `json:"javascript,omitempty"` // false parses the server-rendered DOM with page scripts disabled
+	WaitUntil      string `json:"wait_until,omitempty"` // load, domcontentloaded, networkidle (default), or selector
+	WaitSelector   string `json:"wait_selector,omitempty"`
+}
+
+// newTask builds a pending task from a submission request. The task belongs
+// to the encryption tenant of the caller r authenticated as.
+func newTask(r *http.Request, req SubmitTaskRequest) *taskstypes.Task {
+	return &taskstypes.Task{
+		ID:             uuid.New(),
+		Status:         taskstypes.StatusPending,
+		Actions:        req.Actions,
+		Credentials:    req.Credentials,
+		SealedCreds:    req.EncryptedCredentials,
+		CredentialsRef: req.CredentialsRef,
+		TwoFactorAuth:  req.TwoFactorAuth,
+		CallbackURL:    req.CallbackURL,
+		CallbackFields: req.CallbackFields,
+		TFAWebhook:     req.TFAWebhook,
+		Session:        req.Session,
+		Options:        req.Options,
+		Tags:           req.Tags,
+		ReferenceID:    req.ReferenceID,
+		Priority:       req.Priority,
+		Tenant:         requestTenant(r),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		TfaCodeChan:    make(chan string, 1), // Buffered channel for 2FA code
+	}
+}
+
+// checkCredentialSources rejects requests that give credentials more than one way.
+func checkCredentialSources(creds *taskstypes.Credentials, sealed *taskstypes.SealedCredentials, ref string) error {
+	given := 0
+	for _, set := range []bool{creds != nil, sealed != nil, ref != ""} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return fmt.Errorf("provide only one of credentials, encrypted_credentials, or credentials_ref")
+	}
+	return nil
+}
+
+// validateLabels checks the tags and reference ID a client attached to a task.
+func validateLabels(tags map[string]string, referenceID string) error {
+	if err := taskstypes.ValidateTags(tags); err != nil {
+		return err
+	}
+	return taskstypes.ValidateReferenceID(referenceID)
 }
 
 func (h *APIHandler) HandleSubmitTask(w http.ResponseWriter, r *http.Request) {
 	var req SubmitTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid request body: %v", err)
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
 		return
 	}
 	defer r.Body.Close()
 
-	// Create a task ID
-	task := &taskstypes.Task{
-		ID:            uuid.New(),
-		Status:        taskstypes.StatusPending,
-		Actions:       req.Actions,
-		Credentials:   req.Credentials,
-		TwoFactorAuth: req.TwoFactorAuth,
-		CallbackURL:   req.CallbackURL,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
-		TfaCodeChan:   make(chan string, 1), // Buffered channel for 2FA code
+	if err := checkCredentialSources(req.Credentials, req.EncryptedCredentials, req.CredentialsRef); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	if err := checkCredentialsRef(r, req.CredentialsRef); err != nil {
+		h.respondError(w, r, http.StatusForbidden, "%v", err)
+		return
+	}
+
+	if err := validateLabels(req.Tags, req.ReferenceID); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	if err := req.Priority.Validate(); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+
+	task := newTask(r, req)
+	if err := checkTaskResources(r, h.taskManager, task); err != nil {
+		h.respondSubmitError(w, r, err)
+		return
+	}
+	// Ties the request to the task's own log lines
+	h.logger.InfoContext(r.Context(), "Submitting task", logging.TaskIDKey, task.ID, "actions", len(task.Actions))
+
+	if r.URL.Query().Get("wait") == "true" {
+		h.runTaskSync(w, r, task)
+		return
 	}
 
 	// Queue the task
 	err := h.taskManager.SubmitTask(task)
 	if err != nil {
-		h.respondError(w, http.StatusInternalServerError, "Failed to submit task: %v", err)
+		h.respondSubmitError(w, r, err)
 		return
 	}
 
 	resp := SubmitTaskResponse{
-		TaskID: task.ID.String(),
+		TaskID:   task.ID.String(),
+		Warnings: task.Warnings,
 	}
 	h.respondJSON(w, http.StatusAccepted, resp)
 }
 
+// runTaskSync runs a task for the length of the request and responds with the
+// finished task. The task is cancelled if the client disconnects or the
+// request times out first.
+func (h *APIHandler) runTaskSync(w http.ResponseWriter, r *http.Request, task *taskstypes.Task) {
+	if err := h.taskManager.SubmitTaskContext(r.Context(), task); err != nil {
+		h.respondSubmitError(w, r, err)
+		return
+	}
+	finished, err := h.taskManager.WaitTask(r.Context(), task.ID)
+	if err != nil {
+		h.logger.InfoContext(r.Context(), "Stopped waiting for task", logging.TaskIDKey, task.ID, "error", err)
+		return // The client is gone; the task was cancelled with the request
+	}
+	h.respondJSON(w, http.StatusOK, finished)
+}
+
+// callerTask returns the task with id if the caller r may read it. Tasks of
+// other tenants are reported as not found, so their IDs are not confirmed.
+func (h *APIHandler) callerTask(r *http.Request, id uuid.UUID) (*taskstypes.Task, error) {
+	task, err := h.taskManager.GetTaskStatus(id)
+	if err != nil {
+		return nil, err
+	}
+	if tenant := readableTenant(r); tenant != "" && tasks.TaskTenant(task) != tenant {
+		return nil, fmt.Errorf("%w: %s", tasks.ErrTaskNotFound, id)
+	}
+	return task, nil
+}
+
+// HandleCancelTask stops a running task. It responds once cancellation has
+// been requested; the task reaches status cancelled shortly after.
+func (h *APIHandler) HandleCancelTask(w http.ResponseWriter, r *http.Request) {
+	taskID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid task ID format")
+		return
+	}
+
+	if _, err := h.callerTask(r, taskID); err != nil {
+		if errors.Is(err, tasks.ErrTaskNotFound) {
+			h.respondError(w, r, http.StatusNotFound, "Task not found")
+		} else {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to get task: %v", err)
+		}
+		return
+	}
+	switch err := h.taskManager.CancelTask(taskID); {
+	case err == nil:
+		h.respondJSON(w, http.StatusAccepted, map[string]string{"status": "cancellation requested"})
+	case errors.Is(err, tasks.ErrTaskNotFound):
+		h.respondError(w, r, http.StatusNotFound, "Task not found")
+	case errors.Is(err, tasks.ErrTaskFinished):
+		h.respondError(w, r, http.StatusConflict, "Task is not running")
+	default:
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to cancel task: %v", err)
+	}
+}
+
+// HandleGetCredentialKey returns the public key for encrypted_credentials.
+// The key changes on restart unless security.credentialKey is configured.
+func (h *APIHandler) HandleGetCredentialKey(w http.ResponseWriter, r *http.Request) {
+	keyID, publicKey, err := h.taskManager.CredentialKey().PublicKey()
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Credential key unavailable: %v", err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, CredentialKeyResponse{
+		KeyID:     keyID,
+		Algorithm: encryption.CredentialAlgorithm,
+		PublicKey: string(publicKey),
+	})
+}
+
+// HandleEstimateTask returns the predicted duration and resource cost of an action list
+// without executing it.
+func (h *APIHandler) HandleEstimateTask(w http.ResponseWriter, r *http.Request) {
+	var req EstimateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	if len(req.Actions) == 0 {
+		h.respondError(w, r, http.StatusBadRequest, "At least one action is required")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, h.taskManager.EstimateTask(req.Actions))
+}
+
+// ImportTaskRequest carries a cURL command, HAR, or Playwright/Puppeteer
+// script to convert into actions.
+type ImportTaskRequest struct {
+	Format importer.Format `json:"format,omitempty"` // curl, har, playwright, or puppeteer; detected when empty
+	Source json.RawMessage `json:"source"`           // The source as a string; a HAR may also be inlined as JSON
+}
+
+// HandleImportTask converts a request or script written for another tool into
+// actions and options that can be submitted as a task. Nothing is run.
+func (h *APIHandler) HandleImportTask(w http.ResponseWriter, r *http.Request) {
+	var req ImportTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	if len(req.Source) == 0 {
+		h.respondError(w, r, http.StatusBadRequest, "source is required")
+		return
+	}
+	source := string(req.Source)
+	if req.Source[0] == '"' {
+		if err := json.Unmarshal(req.Source, &source); err != nil {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid source: %v", err)
+			return
+		}
+	}
+	result, err := importer.Import(req.Format, source)
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Import failed: %v", err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// maxStatusWait caps ?wait= on task status below the router's 60 second
+// request timeout.
+const maxStatusWait = 50 * time.Second
+
+// HandleGetTaskStatus returns a task. Its result data is summarized unless
+// ?full=true, which also loads data moved to the result archive.
+// With ?wait=<duration> it long-polls: the response is held until the task's
+// status changes from ?status= (by default its status when the request
+// arrived) or the wait elapses.
 func (h *APIHandler) HandleGetTaskStatus(w http.ResponseWriter, r *http.Request) {
 	taskIDStr := chi.URLParam(r, "taskID")
 	taskID, err := uuid.Parse(taskIDStr)
 	if err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid task ID format")
+		h.respondError(w, r, http.StatusBadRequest, "Invalid task ID format")
+		return
+	}
+
+	q := r.URL.Query()
+	fields, err := parseFields(q.Get("fields"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid fields: %v", err)
+		return
+	}
+	full, err := parseFull(q.Get("full"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
 		return
 	}
+	var wait time.Duration
+	if raw := q.Get("wait"); raw != "" {
+		wait, err = time.ParseDuration(raw)
+		if err != nil || wait < 0 || wait > maxStatusWait {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid wait: %s (expected a duration up to %s)", raw, maxStatusWait)
+			return
+		}
+	}
 
-	task, err := h.taskManager.GetTaskStatus(taskID)
+	task, err := h.callerTask(r, taskID)
 	if err != nil {
-		// Check for not found error based on error message
-		if errors.Is(err, fmt.Errorf("task not found")) || 
-		   err.Error() == "task not found" {
-			h.respondError(w, http.StatusNotFound, "Task not found")
+		if errors.Is(err, tasks.ErrTaskNotFound) {
+			h.respondError(w, r, http.StatusNotFound, "Task not found")
 		} else {
-			h.respondError(w, http.StatusInternalServerError, "Failed to get task: %v", err)
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to get task: %v", err)
 		}
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, task)
+	if wait > 0 && !task.Status.IsTerminal() {
+		from := task.Status
+		if known := q.Get("status"); known != "" {
+			from = taskstypes.TaskStatus(known)
+		}
+		// Keep server.writeTimeout from cutting off the held response
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 15*time.Second))
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		task, err = h.taskManager.WaitTaskStatus(ctx, taskID, from)
+		cancel()
+		if err != nil {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to get task: %v", err)
+			return
+		}
+	}
+
+	if !full {
+		compactResult(task)
+	} else if task.Result != nil && task.Result.Summary != nil && task.Result.Summary.Archived {
+		data, err := h.taskManager.ArchivedResultData(taskID)
+		if err != nil {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to load archived result: %v", err)
+			return
+		}
+		task.Result.Data = data
+	}
+
+	payload, err := selectFields(task, fields)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to select fields: %v", err)
+		return
+	}
+
+	// Pollers send If-None-Match to skip re-downloading an unchanged result
+	h.respondConditional(w, r, jsonWriter(payload))
+}
+
+// HandleBulkTaskStatus returns the status of up to maxBulkStatusIDs tasks in
+// one response. ?fields= and ?full= apply to each task as they do for a
+// single task, except that archived result data is not loaded.
+func (h *APIHandler) HandleBulkTaskStatus(w http.ResponseWriter, r *http.Request) {
+	var req BulkStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	if len(req.TaskIDs) == 0 {
+		h.respondError(w, r, http.StatusBadRequest, "At least one task ID is required")
+		return
+	}
+	if len(req.TaskIDs) > maxBulkStatusIDs {
+		h.respondError(w, r, http.StatusBadRequest, "Too many task IDs: %d (at most %d)", len(req.TaskIDs), maxBulkStatusIDs)
+		return
+	}
+	fields, err := parseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid fields: %v", err)
+		return
+	}
+	full, err := parseFull(r.URL.Query().Get("full"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(req.TaskIDs))
+	seen := make(map[uuid.UUID]bool, len(req.TaskIDs))
+	for _, raw := range req.TaskIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid task ID format: %s", raw)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	resp := BulkStatusResponse{Tasks: make([]interface{}, 0, len(ids))}
+	for _, id := range ids {
+		task, err := h.callerTask(r, id)
+		if errors.Is(err, tasks.ErrTaskNotFound) {
+			resp.NotFound = append(resp.NotFound, id.String())
+			continue
+		} else if err != nil {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to get task %s: %v", id, err)
+			return
+		}
+		if !full {
+			compactResult(task)
+		}
+		selected, err := selectFields(task, fields)
+		if err != nil {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to select fields: %v", err)
+			return
+		}
+		resp.Tasks = append(resp.Tasks, selected)
+	}
+	h.respondJSON(w, http.StatusOK, resp)
+}
+
+// HandleGetTaskHAR downloads the HAR archive of a task submitted with the
+// "har" option. The archive is written to the artifact store when the task finishes.
+func (h *APIHandler) HandleGetTaskHAR(w http.ResponseWriter, r *http.Request) {
+	h.serveTaskRecording(w, r, "har", "HAR archive", ".har", func(o taskstypes.TaskOptions) bool { return o.HAR })
+}
+
+// HandleGetTaskTrace downloads the Playwright trace of a task submitted with
+// the "trace" option, for the Playwright Trace Viewer. The trace is written to
+// the artifact store when the task finishes.
+func (h *APIHandler) HandleGetTaskTrace(w http.ResponseWriter, r *http.Request) {
+	h.serveTaskRecording(w, r, "trace", "trace", ".zip", func(o taskstypes.TaskOptions) bool { return o.Trace })
+}
+
+// serveTaskRecording serves the artifact a task option records for the whole
+// run, named in result.custom_data[option] once the task finishes.
+func (h *APIHandler) serveTaskRecording(w http.ResponseWriter, r *http.Request, option, label, ext string, enabled func(taskstypes.TaskOptions) bool) {
+	taskID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid task ID format")
+		return
+	}
+	task, err := h.callerTask(r, taskID)
+	if err != nil {
+		if errors.Is(err, tasks.ErrTaskNotFound) {
+			h.respondError(w, r, http.StatusNotFound, "Task not found")
+		} else {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to get task: %v", err)
+		}
+		return
+	}
+	if !enabled(task.Options) {
+		h.respondError(w, r, http.StatusNotFound, "Task was not submitted with the %s option", option)
+		return
+	}
+	if !task.Status.IsTerminal() {
+		h.respondError(w, r, http.StatusConflict, "The %s is written when the task finishes (status: %s)", label, task.Status)
+		return
+	}
+
+	// Custom data is typed while the task is in memory and generic once loaded from the store
+	var info struct {
+		Name string `json:"name"`
+	}
+	if task.Result != nil {
+		if raw, err := json.Marshal(task.Result.CustomData[option]); err == nil {
+			_ = json.Unmarshal(raw, &info)
+		}
+	}
+	if info.Name == "" {
+		h.respondError(w, r, http.StatusNotFound, "Task has no %s", label)
+		return
+	}
+	h.serveArtifact(w, r, taskID, info.Name, taskID.String()+ext)
+}
+
+// HandleListTasks returns persisted tasks, newest first.
+// Supports ?status=, ?template=, ?since=<duration>, ?limit= and ?offset= filters,
+// ?fields= to return only some fields of each task, and ?full=true to include
+// result data that has not been archived.
+func (h *APIHandler) HandleListTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := tasks.ListFilter{
+		Status:      taskstypes.TaskStatus(q.Get("status")),
+		Template:    q.Get("template"),
+		ReferenceID: q.Get("reference_id"),
+		Tenant:      readableTenant(r),
+	}
+	for _, raw := range q["tag"] {
+		key, value, ok := strings.Cut(raw, ":")
+		if !ok || key == "" {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid tag filter %q (use key:value)", raw)
+			return
+		}
+		if filter.Tags == nil {
+			filter.Tags = make(map[string]string)
+		}
+		filter.Tags[key] = value
+	}
+
+	if raw := q.Get("since"); raw != "" {
+		window, err := time.ParseDuration(raw)
+		if err != nil || window <= 0 {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid since duration: %s", raw)
+			return
+		}
+		filter.Since = time.Now().Add(-window)
+	}
+	fields, err := parseFields(q.Get("fields"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid fields: %v", err)
+		return
+	}
+	full, err := parseFull(q.Get("full"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	for name, dst := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if raw := q.Get(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				h.respondError(w, r, http.StatusBadRequest, "Invalid %s: %s", name, raw)
+				return
+			}
+			*dst = n
+		}
+	}
+
+	list, err := h.taskManager.ListTasks(filter)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to list tasks: %v", err)
+		return
+	}
+	if !full {
+		for _, task := range list {
+			compactResult(task)
+		}
+	}
+	payload, err := selectFields(list, fields)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to select fields: %v", err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, payload)
+}
+
+// HandleGetStats returns aggregate statistics over task history.
+// The optional "since" query parameter restricts the window (e.g. ?since=24h).
+func (h *APIHandler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		window, err := time.ParseDuration(raw)
+		if err != nil || window <= 0 {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid since duration: %s", raw)
+			return
+		}
+		since = time.Now().Add(-window)
+	}
+
+	h.respondJSON(w, http.StatusOK, h.taskManager.Stats(since))
+}
+
+// HandleGetActionSchema returns the JSON Schema task actions are checked
+// against, so clients can validate payloads before submitting them.
+func (h *APIHandler) HandleGetActionSchema(w http.ResponseWriter, r *http.Request) {
+	h.respondConditional(w, r, jsonWriter(browser.ActionSchema()))
 }
 
 // HandleGetDomAST handles requests to get a DOM AST from a URL with optional parent selector
 func (h *APIHandler) HandleGetDomAST(w http.ResponseWriter, r *http.Request) {
 	var req GetDomASTRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid request body: %v", err)
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
 		return
 	}
 	defer r.Body.Close()
 
 	if req.URL == "" {
-		h.respondError(w, http.StatusBadRequest, "URL is required")
+		h.respondError(w, r, http.StatusBadRequest, "URL is required")
+		return
+	}
+	if req.WaitUntil == "" {
+		req.WaitUntil = taskstypes.WaitUntilNetworkIdle
+	}
+	if !dom.ValidWaitUntil(req.WaitUntil) {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid wait_until: %s", req.WaitUntil)
+		return
+	}
+	if req.WaitUntil == taskstypes.WaitUntilSelector && req.WaitSelector == "" {
+		h.respondError(w, r, http.StatusBadRequest, "wait_selector is required when wait_until is selector")
+		return
+	}
+
+	policy := h.taskManager.URLPolicy()
+	if err := policy.CheckResolved(r.Context(), req.URL, true); err != nil {
+		h.respondError(w, r, http.StatusUnprocessableEntity, "%v", err)
 		return
 	}
 
-	h.logger.Printf("Processing DOM AST request for URL: %s, parent selector: %s", req.URL, req.ParentSelector)
+	h.logger.DebugContext(r.Context(), "Processing DOM AST request", "url", req.URL, "parent_selector", req.ParentSelector)
 
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -146,61 +684,70 @@ func (h *APIHandler) HandleGetDomAST(w http.ResponseWriter, r *http.Request) {
 	// Initialize result
 	var domAST dom.DomNode
 
+	// Redirects and the page's own requests are held to the URL policy too
+	if err := browser.EnforceURLPolicy(browserCtx, policy); err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to enforce the URL policy: %v", err)
+		return
+	}
+
 	// Run the DOM AST action
+	scriptsDisabled := taskstypes.TaskOptions{JavaScript: req.JavaScript}.ScriptsDisabled()
 	err := chromedp.Run(browserCtx,
-		chromedp.Navigate(req.URL),
-		chromedp.Sleep(5*time.Second), // Increased wait time to ensure page loads fully
+		emulation.SetScriptExecutionDisabled(scriptsDisabled),
+		dom.NavigateWaitAction(req.URL, req.WaitUntil, req.WaitSelector),
 		dom.GetDomASTAction(req.ParentSelector, &domAST),
 	)
 
 	if err != nil {
-		h.respondError(w, http.StatusInternalServerError, "Failed to get DOM AST: %v", err)
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to get DOM AST: %v", err)
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, domAST)
+	// Large pages produce multi-megabyte ASTs; stream them out as they are
+	// encoded, or skip them when the client's If-None-Match is current
+	h.respondConditional(w, r, func(w io.Writer) error {
+		return dom.WriteJSON(w, &domAST)
+	})
 }
 
 func (h *APIHandler) HandleProvide2FACode(w http.ResponseWriter, r *http.Request) {
 	taskIDStr := chi.URLParam(r, "taskID")
 	taskID, err := uuid.Parse(taskIDStr)
 	if err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid task ID format")
+		h.respondError(w, r, http.StatusBadRequest, "Invalid task ID format")
 		return
 	}
 
 	var req Provide2FACodeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid request body: %v", err)
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
 		return
 	}
 	defer r.Body.Close()
 
 	if req.Code == "" {
-		h.respondError(w, http.StatusBadRequest, "2FA code is required")
+		h.respondError(w, r, http.StatusBadRequest, "2FA code is required")
 		return
 	}
 
-	task, err := h.taskManager.GetTaskStatus(taskID)
+	task, err := h.callerTask(r, taskID)
 	if err != nil {
-		// Check for not found error based on error message
-		if errors.Is(err, fmt.Errorf("task not found")) || 
-		   err.Error() == "task not found" {
-			h.respondError(w, http.StatusNotFound, "Task not found")
+		if errors.Is(err, tasks.ErrTaskNotFound) {
+			h.respondError(w, r, http.StatusNotFound, "Task not found")
 		} else {
-			h.respondError(w, http.StatusInternalServerError, "Failed to get task: %v", err)
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to get task: %v", err)
 		}
 		return
 	}
 
 	if string(task.Status) != string(tasks.StatusWaitingFor2FA) {
-		h.respondError(w, http.StatusBadRequest, "Task is not waiting for 2FA")
+		h.respondError(w, r, http.StatusBadRequest, "Task is not waiting for 2FA")
 		return
 	}
 
 	err = h.taskManager.Provide2FACode(taskID, req.Code)
 	if err != nil {
-		h.respondError(w, http.StatusInternalServerError, "Failed to provide 2FA code: %v", err)
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to provide 2FA code: %v", err)
 		return
 	}
 
@@ -212,7 +759,7 @@ func (h *APIHandler) HandleProvide2FACode(w http.ResponseWriter, r *http.Request
 func (h *APIHandler) respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
-		h.logger.Printf("Error marshalling JSON response: %v", err)
+		h.logger.Error("Failed to marshal JSON response", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error": "Internal Server Error"}`))
 		return
@@ -223,13 +770,56 @@ func (h *APIHandler) respondJSON(w http.ResponseWriter, status int, payload inte
 	w.Write(response)
 }
 
-func (h *APIHandler) respondError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+// respondStream writes a JSON body produced by write without buffering it
+// first, so large responses go out with chunked transfer encoding. Errors
+// after the status is sent can only be logged.
+func (h *APIHandler) respondStream(w http.ResponseWriter, status int, write func(io.Writer) error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := write(w); err != nil {
+		h.logger.Warn("Failed to stream JSON response", "error", err)
+	}
+}
+
+// respondSubmitError maps task submission failures, such as unknown sessions or credentials sealed to a stale key, to a status.
+func (h *APIHandler) respondSubmitError(w http.ResponseWriter, r *http.Request, err error) {
+	var unhealthy *tasks.DomainUnhealthyError
+	switch {
+	case errors.Is(err, tasks.ErrSessionNotFound), errors.Is(err, tasks.ErrSessionsUnsupported),
+		errors.Is(err, tasks.ErrSealedCredentialsUnsupported), errors.Is(err, encryption.ErrCredentialKeyMismatch),
+		errors.Is(err, tasks.ErrCredentialsRefUnsupported), errors.Is(err, secrets.ErrInvalidReference),
+		errors.Is(err, tasks.ErrInvalidCallbackFields), errors.Is(err, tasks.ErrInvalidTOTPSecret),
+		errors.Is(err, tasks.ErrInvalidOptions):
+		h.respondError(w, r, http.StatusBadRequest, "Failed to submit task: %v", err)
+	case errors.Is(err, tasks.ErrInvalidURL):
+		h.respondError(w, r, http.StatusUnprocessableEntity, "Failed to submit task: %v", err)
+	case errors.Is(err, errProfileNotOwned):
+		h.respondError(w, r, http.StatusForbidden, "Failed to submit task: %v", err)
+	case errors.Is(err, tasks.ErrQueueFull):
+		h.respondError(w, r, http.StatusTooManyRequests, "Failed to submit task: %v", err)
+	case errors.Is(err, tasks.ErrShuttingDown), errors.Is(err, tasks.ErrInvalidEncryption):
+		h.respondError(w, r, http.StatusServiceUnavailable, "Failed to submit task: %v", err)
+	case errors.As(err, &unhealthy):
+		w.Header().Set("Retry-After", retryAfterSeconds(unhealthy.RetryAfter))
+		h.respondError(w, r, http.StatusServiceUnavailable, "Failed to submit task: %v", err)
+	default:
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to submit task: %v", err)
+	}
+}
+
+// respondError logs server errors at error level and client errors at info,
+// since the latter are the caller's to fix.
+func (h *APIHandler) respondError(w http.ResponseWriter, r *http.Request, status int, format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
-	h.logger.Printf("Error response: %s", message)
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	h.logger.Log(r.Context(), level, "Error response", "status", status, "error", message)
 
 	response, err := json.Marshal(map[string]string{"error": message})
 	if err != nil {
-		h.logger.Printf("Error marshalling error response: %v", err)
+		h.logger.ErrorContext(r.Context(), "Failed to marshal error response", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error": "Internal Server Error"}`))
 		return
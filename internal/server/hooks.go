@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+)
+
+// hooksPath is where inbound webhooks are served; the hook's name follows it.
+const hooksPath = "/hooks/"
+
+const (
+	// HeaderHookToken carries a hook's token; ?token= works too.
+	HeaderHookToken = "X-Hook-Token"
+	// headerHubSignature is GitHub's name for the same body signature as
+	// tasks.CallbackSignatureHeader, which many senders already produce.
+	headerHubSignature = "X-Hub-Signature-256"
+
+	maxHookBody = 1 << 20
+)
+
+// hook is a configured webhook, checked at startup.
+type hook struct {
+	config.HookConfig
+	priority taskstypes.TaskPriority
+}
+
+// newHooks checks hook configuration and indexes it by name.
+func newHooks(cfgs []config.HookConfig) (map[string]*hook, error) {
+	hooks := make(map[string]*hook, len(cfgs))
+	for i, cfg := range cfgs {
+		switch {
+		case cfg.Name == "" || strings.ContainsAny(cfg.Name, "/?#"):
+			return nil, fmt.Errorf("hooks[%d]: name %q is empty or not a path segment", i, cfg.Name)
+		case hooks[cfg.Name] != nil:
+			return nil, fmt.Errorf("hooks[%d]: duplicate name %q", i, cfg.Name)
+		case cfg.Template == "":
+			return nil, fmt.Errorf("hook %s: template is required", cfg.Name)
+		case cfg.Token == "" && cfg.Secret == "":
+			return nil, fmt.Errorf("hook %s: set a token or secret, or anyone could start its tasks", cfg.Name)
+		}
+		h := &hook{HookConfig: cfg, priority: taskstypes.TaskPriority(cfg.Priority)}
+		if err := h.priority.Validate(); err != nil {
+			return nil, fmt.Errorf("hook %s: %w", cfg.Name, err)
+		}
+		if err := taskstypes.ValidateTags(cfg.Tags); err != nil {
+			return nil, fmt.Errorf("hook %s: %w", cfg.Name, err)
+		}
+		hooks[cfg.Name] = h
+	}
+	return hooks, nil
+}
+
+// authorized reports whether a request carries every credential the hook requires.
+func (h *hook) authorized(r *http.Request, body []byte) bool {
+	if h.Token != "" {
+		token := r.Header.Get(HeaderHookToken)
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+			return false
+		}
+	}
+	if h.Secret != "" {
+		signature := r.Header.Get(tasks.CallbackSignatureHeader)
+		if signature == "" {
+			signature = r.Header.Get(headerHubSignature)
+		}
+		if !tasks.VerifyCallback(h.Secret, body, signature) {
+			return false
+		}
+	}
+	return true
+}
+
+// HandleHook runs the template bound to a webhook, filling its variables
+// from the request's JSON or form payload.
+func (h *APIHandler) HandleHook(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	hk := h.hooks[name]
+	if hk == nil {
+		h.respondError(w, r, http.StatusNotFound, "Unknown hook %s", name)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondError(w, r, http.StatusRequestEntityTooLarge, "Payload is larger than %d bytes", maxHookBody)
+			return
+		}
+		h.respondError(w, r, http.StatusBadRequest, "Failed to read payload: %v", err)
+		return
+	}
+	if !hk.authorized(r, body) {
+		h.respondError(w, r, http.StatusUnauthorized, "Missing or invalid hook token or signature")
+		return
+	}
+	payload, err := parseHookPayload(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid payload: %v", err)
+		return
+	}
+
+	vars := make(map[string]string, len(hk.Variables))
+	for variable, field := range hk.Variables {
+		if vars[variable], err = payloadField(payload, field); err != nil {
+			h.respondError(w, r, http.StatusUnprocessableEntity, "Payload has no %s for variable %s: %v", field, variable, err)
+			return
+		}
+	}
+	var referenceID string
+	if hk.ReferenceField != "" {
+		if referenceID, err = payloadField(payload, hk.ReferenceField); err != nil {
+			h.respondError(w, r, http.StatusUnprocessableEntity, "Payload has no %s for the reference ID: %v", hk.ReferenceField, err)
+			return
+		}
+		if err := taskstypes.ValidateReferenceID(referenceID); err != nil {
+			h.respondError(w, r, http.StatusUnprocessableEntity, "Invalid reference ID from %s: %v", hk.ReferenceField, err)
+			return
+		}
+	}
+
+	tmpl, canary, err := h.taskManager.Templates().ForRun(hk.Template, hk.Version)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Hook %s cannot run template %s: %v", name, hk.Template, err)
+		return
+	}
+	actions, err := tasks.ApplyVariables(tmpl.Actions, vars)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Hook %s does not map every variable of template %s: %v", name, hk.Template, err)
+		return
+	}
+
+	tags := map[string]string{"hook": name}
+	for key, value := range hk.Tags {
+		tags[key] = value
+	}
+	task := newTask(r, SubmitTaskRequest{
+		Actions:     actions,
+		CallbackURL: hk.CallbackURL,
+		Session:     hk.Session,
+		Tags:        tags,
+		ReferenceID: referenceID,
+		Priority:    hk.priority,
+	})
+	task.TemplateName = tmpl.Name
+	task.TemplateVersion = tmpl.Version
+	task.Canary = canary
+
+	if err := h.taskManager.SubmitTask(task); err != nil {
+		h.respondSubmitError(w, r, err)
+		return
+	}
+	h.logger.InfoContext(r.Context(), "Hook started task", "hook", name, "task_id", task.ID)
+	h.respondJSON(w, http.StatusAccepted, SubmitTaskResponse{TaskID: task.ID.String(), Warnings: task.Warnings})
+}
+
+// parseHookPayload decodes a form or JSON body. An empty body is an empty object.
+func parseHookPayload(contentType string, body []byte) (interface{}, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		form := make(map[string]interface{}, len(values))
+		for key := range values {
+			form[key] = values.Get(key)
+		}
+		return form, nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // Keep large IDs exact
+	var payload interface{}
+	if err := dec.Decode(&payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// payloadField reads a dot-separated path such as "lead.email" or
+// "alerts.0.labels.instance" from a payload as text. Objects and arrays
+// are returned as JSON.
+func payloadField(payload interface{}, path string) (string, error) {
+	value := payload
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return "", fmt.Errorf("no field %q", key)
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return "", fmt.Errorf("no element %q in an array of %d", key, len(v))
+			}
+			value = v[index]
+		default:
+			return "", fmt.Errorf("%q is not inside an object or array", key)
+		}
+	}
+	return fieldText(value)
+}
+
+// fieldText renders a decoded payload value as text: strings as they are,
+// numbers and booleans as written, null as empty, and anything else as JSON.
+func fieldText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	text, err := json.Marshal(value)
+	return string(text), err
+}
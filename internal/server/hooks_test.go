@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleHook(t *testing.T) {
+	cfg := &config.Config{Hooks: []config.HookConfig{
+		{
+			Name: "crm-lead", Template: "lookup-lead", Token: "s3cret",
+			Variables:      map[string]string{"email": "lead.email", "company": "lead.company.name"},
+			ReferenceField: "id",
+			Tags:           map[string]string{"source": "crm"},
+			Priority:       "high",
+		},
+		{Name: "alert", Template: "lookup-lead", Secret: "signing-key", Variables: map[string]string{"email": "email", "company": "labels.0"}},
+	}}
+	logger := logging.Discard()
+	manager := tasks.NewManager(cfg, mocks.NewMockBrowserExecutor(), logger)
+	_, err := manager.Templates().Put("lookup-lead", "", []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://crm.example/search?q={{vars.email}}"},
+		{Type: taskstypes.ActionWaitVisible, Selector: "[data-company='{{vars.company}}']"},
+	})
+	require.NoError(t, err)
+	handler := NewServer(cfg, manager, logger).httpServer.Handler
+
+	post := func(path, contentType, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	submitted := func(rec *httptest.ResponseRecorder) *taskstypes.Task {
+		require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+		var resp SubmitTaskResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		task, err := manager.GetTaskStatus(uuid.MustParse(resp.TaskID))
+		require.NoError(t, err)
+		return task
+	}
+
+	lead := `{"id": 90071992547409931, "lead": {"email": "ada@example.com", "company": {"name": "Analytical"}}}`
+	task := submitted(post("/hooks/crm-lead", "application/json", lead, map[string]string{HeaderHookToken: "s3cret"}))
+	assert.Equal(t, "https://crm.example/search?q=ada@example.com", task.Actions[0].Value)
+	assert.Equal(t, "[data-company='Analytical']", task.Actions[1].Selector)
+	assert.Equal(t, "90071992547409931", task.ReferenceID, "numbers are kept exact")
+	assert.Equal(t, map[string]string{"hook": "crm-lead", "source": "crm"}, task.Tags)
+	assert.Equal(t, taskstypes.PriorityHigh, task.Priority)
+	assert.Equal(t, "lookup-lead", task.TemplateName)
+
+	// The token may also be given in the query, for senders that cannot set headers
+	submitted(post("/hooks/crm-lead?token=s3cret", "application/json", lead, nil))
+	assert.Equal(t, http.StatusUnauthorized, post("/hooks/crm-lead?token=wrong", "application/json", lead, nil).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, post("/hooks/crm-lead", "application/json", `{"id": 1, "lead": {}}`, map[string]string{HeaderHookToken: "s3cret"}).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/hooks/crm-lead", "application/json", `{`, map[string]string{HeaderHookToken: "s3cret"}).Code)
+	assert.Equal(t, http.StatusNotFound, post("/hooks/unknown", "application/json", lead, map[string]string{HeaderHookToken: "s3cret"}).Code)
+
+	// Signed hooks accept the callback signature header or GitHub's
+	alert := "email=ops%40example.com&labels=x"
+	rec := post("/hooks/alert", "application/x-www-form-urlencoded", alert, map[string]string{headerHubSignature: tasks.SignCallback("signing-key", []byte(alert))})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "form values are not arrays")
+	body := `{"email": "ops@example.com", "labels": ["Initech"]}`
+	task = submitted(post("/hooks/alert", "application/json", body, map[string]string{tasks.CallbackSignatureHeader: tasks.SignCallback("signing-key", []byte(body))}))
+	assert.Equal(t, "[data-company='Initech']", task.Actions[1].Selector)
+	assert.Equal(t, http.StatusUnauthorized, post("/hooks/alert", "application/json", body, map[string]string{tasks.CallbackSignatureHeader: tasks.SignCallback("other", []byte(body))}).Code)
+}
+
+func TestNewHooks(t *testing.T) {
+	for _, hooks := range [][]config.HookConfig{
+		{{Name: "open", Template: "t"}},
+		{{Name: "a/b", Template: "t", Token: "x"}},
+		{{Name: "a", Template: "t", Token: "x"}, {Name: "a", Template: "u", Token: "y"}},
+		{{Name: "a", Token: "x"}},
+		{{Name: "a", Template: "t", Token: "x", Priority: "urgent"}},
+	} {
+		_, err := newHooks(hooks)
+		assert.Error(t, err, "%+v", hooks)
+	}
+
+	hooks, err := newHooks([]config.HookConfig{{Name: "a", Template: "t", Secret: "x"}})
+	require.NoError(t, err)
+	assert.Contains(t, hooks, "a")
+}
+
+func TestPayloadField(t *testing.T) {
+	payload, err := parseHookPayload("application/json; charset=utf-8", []byte(`{"a": {"b": [1.5, true, null, {"c": "d"}]}}`))
+	require.NoError(t, err)
+	for path, want := range map[string]string{"a.b.0": "1.5", "a.b.1": "true", "a.b.2": "", "a.b.3": `{"c":"d"}`, "a.b.3.c": "d"} {
+		got, err := payloadField(payload, path)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, got, path)
+	}
+	for _, path := range []string{"a.x", "a.b.9", "a.b.0.z"} {
+		_, err := payloadField(payload, path)
+		assert.Error(t, err, path)
+	}
+
+	form, err := parseHookPayload("application/x-www-form-urlencoded", []byte("From=%2B15550100&Body=Code+123"))
+	require.NoError(t, err)
+	got, err := payloadField(form, "Body")
+	require.NoError(t, err)
+	assert.Equal(t, "Code 123", got)
+}
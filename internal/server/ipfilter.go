@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseCIDRs parses a list of CIDRs or bare IPs (treated as /32 or /128).
+func ParseCIDRs(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// TrustedRealIP replaces chi's RealIP: X-Forwarded-For and X-Real-IP are only
+// honored when the direct peer is a trusted proxy, so clients cannot spoof their
+// address to get past IP rules. For X-Forwarded-For the client is the rightmost
+// address that is not itself a trusted proxy.
+func TrustedRealIP(trustedProxies []*net.IPNet) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			peer := remoteIP(r.RemoteAddr)
+			if peer != nil && containsIP(trustedProxies, peer) {
+				if client := forwardedClient(r, trustedProxies); client != nil {
+					r.RemoteAddr = client.String()
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func forwardedClient(r *http.Request, trustedProxies []*net.IPNet) net.IP {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return nil
+			}
+			if !containsIP(trustedProxies, ip) {
+				return ip
+			}
+		}
+		return nil
+	}
+	return net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+}
+
+// IPFilter rejects requests whose client address matches a denied range, or
+// does not match any allowed range when an allowlist is configured.
+// Deny rules take precedence over allow rules.
+func IPFilter(allowed, denied []*net.IPNet) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ip := remoteIP(r.RemoteAddr)
+			if ip == nil ||
+				containsIP(denied, ip) ||
+				(len(allowed) > 0 && !containsIP(allowed, ip)) {
+				http.Error(w, http.StatusText(http.StatusForbidden)+": client address not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPFilter(t *testing.T) {
+	allowed, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.10"})
+	require.NoError(t, err)
+	denied, err := ParseCIDRs([]string{"10.0.66.0/24"})
+	require.NoError(t, err)
+
+	h := IPFilter(allowed, denied)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	cases := map[string]int{
+		"10.1.2.3:5000":     http.StatusOK,
+		"192.168.1.10:5000": http.StatusOK,
+		"192.168.1.11:5000": http.StatusForbidden,
+		"10.0.66.7:5000":    http.StatusForbidden,
+	}
+	for addr, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Code, addr)
+	}
+}
+
+func TestTrustedRealIP(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"172.16.0.0/12"})
+	require.NoError(t, err)
+
+	var seen string
+	h := TrustedRealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.RemoteAddr
+	}))
+
+	// Forwarded headers from an untrusted peer are ignored
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "203.0.113.5:1234", seen)
+
+	// Through a trusted proxy, the rightmost untrusted hop is the client
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "172.16.0.2:1234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 198.51.100.7, 172.16.0.9")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "198.51.100.7", seen)
+}
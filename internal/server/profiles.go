@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+)
+
+// errProfileNotOwned is returned when a task or session names a profile
+// another tenant owns.
+var errProfileNotOwned = errors.New("profile belongs to another tenant")
+
+// CreateProfileRequest is the body of POST /api/v1/profiles.
+type CreateProfileRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	AutoSave    bool   `json:"auto_save,omitempty"` // Save the profile after every successful task that uses it
+}
+
+// HandleCreateProfile creates an empty named profile for tasks and sessions
+// to reference, so its cookies and logins persist across tasks and restarts.
+func (h *APIHandler) HandleCreateProfile(w http.ResponseWriter, r *http.Request) {
+	var req CreateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if err := taskstypes.ValidateProfileName(req.Name); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	profiles, err := h.taskManager.Profiles()
+	if err != nil {
+		h.respondError(w, r, http.StatusNotImplemented, "%v", err)
+		return
+	}
+	info, err := profiles.CreateProfile(req.Name, ownerTenant(r), req.Description, req.AutoSave)
+	if err != nil {
+		if errors.Is(err, tasks.ErrProfileExists) {
+			h.respondError(w, r, http.StatusConflict, "%v", err)
+		} else {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to create profile: %v", err)
+		}
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, info)
+}
+
+// HandleGetProfile returns one profile.
+func (h *APIHandler) HandleGetProfile(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.taskManager.Profiles()
+	if err != nil {
+		h.respondError(w, r, http.StatusNotImplemented, "%v", err)
+		return
+	}
+	info, err := callerProfile(r, profiles, chi.URLParam(r, "name"))
+	if err != nil {
+		h.respondProfileError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, info)
+}
+
+// HandleListProfiles returns the browser profiles the caller may use,
+// created or saved.
+func (h *APIHandler) HandleListProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.taskManager.Profiles()
+	if err != nil {
+		h.respondError(w, r, http.StatusNotImplemented, "%v", err)
+		return
+	}
+	all, err := profiles.ListProfiles()
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to list profiles: %v", err)
+		return
+	}
+	list := []taskstypes.ProfileInfo{}
+	for _, info := range all {
+		if mayUse(r, info.Owner) {
+			list = append(list, info)
+		}
+	}
+	h.respondJSON(w, http.StatusOK, list)
+}
+
+// HandleDeleteProfile deletes a profile snapshot, e.g. to force the next
+// task that uses it to log in from scratch.
+func (h *APIHandler) HandleDeleteProfile(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.taskManager.Profiles()
+	if err != nil {
+		h.respondError(w, r, http.StatusNotImplemented, "%v", err)
+		return
+	}
+	name := chi.URLParam(r, "name")
+	if _, err := callerProfile(r, profiles, name); err != nil {
+		h.respondProfileError(w, r, err)
+		return
+	}
+	if err := profiles.DeleteProfile(name); err != nil {
+		h.respondProfileError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// callerProfile returns the named profile if the caller r may use it.
+// Other tenants' profiles are reported as not found.
+func callerProfile(r *http.Request, profiles tasks.ProfileExecutor, name string) (taskstypes.ProfileInfo, error) {
+	list, err := profiles.ListProfiles()
+	if err != nil {
+		return taskstypes.ProfileInfo{}, err
+	}
+	for _, info := range list {
+		if info.Name == name && mayUse(r, info.Owner) {
+			return info, nil
+		}
+	}
+	return taskstypes.ProfileInfo{}, fmt.Errorf("%w: %s", tasks.ErrProfileNotFound, name)
+}
+
+// checkProfile rejects a profile the caller r may not start a task or
+// session from. A profile that does not exist yet is allowed; the task
+// that first saves it becomes its owner.
+func checkProfile(r *http.Request, tm *tasks.Manager, name string) error {
+	if name == "" {
+		return nil
+	}
+	profiles, err := tm.Profiles()
+	if err != nil {
+		// Reported when the task runs, as for any task naming a profile
+		return nil
+	}
+	list, err := profiles.ListProfiles()
+	if err != nil {
+		return err
+	}
+	for _, info := range list {
+		if info.Name == name && !mayUse(r, info.Owner) {
+			return fmt.Errorf("%w: %s", errProfileNotOwned, name)
+		}
+	}
+	return nil
+}
+
+func (h *APIHandler) respondProfileError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, tasks.ErrProfileNotFound):
+		h.respondError(w, r, http.StatusNotFound, "%v", err)
+	case errors.Is(err, errProfileNotOwned):
+		h.respondError(w, r, http.StatusForbidden, "%v", err)
+	default:
+		h.respondError(w, r, http.StatusInternalServerError, "Profile error: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket allows bursts of up to burst requests, refilled at rate per second.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = max(1, int(math.Ceil(rate)))
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), now: time.Now}
+}
+
+// take spends a token if one is left, or reports how long until the next one.
+func (b *tokenBucket) take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// parseRateLimit reads a limit such as "10/s", "600/m", or "1000/h" and
+// returns it in requests per second.
+func parseRateLimit(limit string) (float64, error) {
+	count, unit, ok := strings.Cut(strings.ReplaceAll(limit, " ", ""), "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if !ok || err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid rate limit %q (expected e.g. 10/s, 600/m, or 1000/h)", limit)
+	}
+	switch strings.ToLower(unit) {
+	case "s", "sec", "second":
+		return n, nil
+	case "m", "min", "minute":
+		return n / 60, nil
+	case "h", "hour":
+		return n / 3600, nil
+	}
+	return 0, fmt.Errorf("invalid rate limit %q (unit must be s, m, or h)", limit)
+}
+
+// retryAfterSeconds formats a wait for the Retry-After header, rounding up.
+func retryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(wait.Seconds()))))
+}
+
+// full reports whether the bucket has refilled to its burst by now.
+func (b *tokenBucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last.IsZero() || b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// limiterSweepInterval is how often a clientLimiter drops the buckets of
+// clients that have gone quiet.
+const limiterSweepInterval = time.Minute
+
+// clientLimiter keeps a token bucket per client, such as an address or a
+// caller without a limit of its own. Buckets that have refilled are dropped,
+// so only recently active clients are kept.
+type clientLimiter struct {
+	rate  float64
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// newClientLimiter returns nil for an empty limit, meaning unlimited.
+func newClientLimiter(limit string, burst int) (*clientLimiter, error) {
+	if limit == "" {
+		return nil, nil
+	}
+	rate, err := parseRateLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	return &clientLimiter{rate: rate, burst: burst, now: time.Now, buckets: make(map[string]*tokenBucket)}, nil
+}
+
+// bucket returns client's bucket, or nil when l is nil.
+func (l *clientLimiter) bucket(client string) *tokenBucket {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) >= limiterSweepInterval {
+		for key, b := range l.buckets {
+			if b.full(now) {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+	b := l.buckets[client]
+	if b == nil {
+		b = newTokenBucket(l.rate, l.burst)
+		b.now = l.now
+		l.buckets[client] = b
+	}
+	return b
+}
+
+// clientAddress is the rate limit key for a request's address. IPv6 clients
+// are grouped by /64, since one host can usually pick any address in it.
+func clientAddress(r *http.Request) string {
+	ip := remoteIP(r.RemoteAddr)
+	if ip == nil {
+		return r.RemoteAddr
+	}
+	if ip.To4() == nil {
+		return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+	}
+	return ip.String()
+}
+
+// allowAddress spends a token from the bucket of r's address, or answers 429
+// and reports false when it has none left. A nil limiter allows everything.
+func allowAddress(w http.ResponseWriter, r *http.Request, limiter *clientLimiter) bool {
+	limit := limiter.bucket(clientAddress(r))
+	if limit == nil {
+		return true
+	}
+	if ok, wait := limit.take(); !ok {
+		tooManyRequests(w, wait, "rate limit for address %s exceeded", clientAddress(r))
+		return false
+	}
+	return true
+}
+
+// LimitAddresses applies security.rateLimit.perIP to routes whose credential
+// the handler checks itself, such as webhooks and the CDP proxy, so they are
+// not open to floods of guesses. It shares its buckets with Authenticate.
+func LimitAddresses(a *Authenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a != nil && !allowAddress(w, r, a.ips) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tooManyRequests answers 429 with a Retry-After of wait.
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, format string, args ...interface{}) {
+	w.Header().Set("Retry-After", retryAfterSeconds(wait))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests)+": "+fmt.Sprintf(format, args...), http.StatusTooManyRequests)
+}
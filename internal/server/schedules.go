@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/go-chi/chi/v5"
+)
+
+// SyncSchedulesRequest is the complete desired set of schedules. Schedules
+// not listed are deleted, so an empty list removes them all.
+type SyncSchedulesRequest struct {
+	Schedules []tasks.ScheduleSpec `json:"schedules"`
+}
+
+// HandleListSchedules returns every schedule with its run state.
+func (h *APIHandler) HandleListSchedules(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.taskManager.Schedules().List())
+}
+
+// HandleGetSchedule returns one schedule.
+func (h *APIHandler) HandleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	sched, err := h.taskManager.Schedules().Get(chi.URLParam(r, "name"))
+	if err != nil {
+		if errors.Is(err, tasks.ErrScheduleNotFound) {
+			h.respondError(w, r, http.StatusNotFound, "%v", err)
+		} else {
+			h.respondError(w, r, http.StatusInternalServerError, "%v", err)
+		}
+		return
+	}
+	h.respondJSON(w, http.StatusOK, sched)
+}
+
+// HandleSyncSchedules converges the schedules on the desired set in the body,
+// creating, updating, and deleting as needed, so they can be managed as code.
+// With ?dry_run=true it reports what would change without changing anything.
+func (h *APIHandler) HandleSyncSchedules(w http.ResponseWriter, r *http.Request) {
+	var req SyncSchedulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if req.Schedules == nil {
+		// A missing list is more likely a mistake than a request to delete everything
+		h.respondError(w, r, http.StatusBadRequest, "schedules is required; send [] to delete all schedules")
+		return
+	}
+
+	var dryRun bool
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid dry_run: %s", raw)
+			return
+		}
+	}
+
+	tenant := requestTenant(r)
+	for i := range req.Schedules {
+		req.Schedules[i].Tenant = tenant
+	}
+	result, err := h.taskManager.Schedules().Sync(req.Schedules, h.taskManager.Templates(), dryRun)
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid schedules: %v", err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleSyncSchedules(t *testing.T) {
+	logger := logging.Discard()
+	h := NewAPIHandler(tasks.NewManager(nil, mocks.NewMockBrowserExecutor(), logger), logger)
+
+	put := func(query, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.HandleSyncSchedules(rec, httptest.NewRequest(http.MethodPut, "/schedules"+query, strings.NewReader(body)))
+		return rec
+	}
+	desired := `{"schedules": [{"name": "prices", "every": "1h", "actions": [{"type": "navigate", "value": "https://example.com"}]}]}`
+
+	rec := put("?dry_run=true", desired)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var result tasks.ScheduleSyncResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{"prices"}, result.Created)
+
+	rec = put("", desired)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = put("", desired)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, []string{"prices"}, result.Unchanged)
+
+	assert.Equal(t, http.StatusBadRequest, put("", `{}`).Code, "a missing list does not delete everything")
+	assert.Equal(t, http.StatusBadRequest, put("", `{"schedules": [{"name": "x", "every": "1s", "actions": []}]}`).Code)
+
+	// The tenant comes from the caller, not the body
+	req := httptest.NewRequest(http.MethodPut, "/schedules", strings.NewReader(
+		`{"schedules": [{"name": "prices", "every": "1h", "tenant": "other", "actions": [{"type": "navigate", "value": "https://example.com"}]}]}`))
+	req = req.WithContext(withPrincipal(req.Context(), Principal{Name: "ci", Role: RoleAdmin, Tenant: "acme"}))
+	rec = httptest.NewRecorder()
+	h.HandleSyncSchedules(rec, req)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, []string{"prices"}, result.Updated)
+	assert.Equal(t, "acme", result.Schedules[0].Tenant)
+
+	rec = put("", `{"schedules": []}`)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, []string{"prices"}, result.Deleted)
+}
@@ -3,61 +3,193 @@ package server
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
-	"github.com/copyleftdev/goscry/internal/config"
-	"github.com/copyleftdev/goscry/internal/tasks"
 )
 
 type Server struct {
 	httpServer  *http.Server
 	cfg         *config.Config
 	taskManager *tasks.Manager
-	logger      *log.Logger
+	logger      *slog.Logger
 }
 
-func NewServer(cfg *config.Config, tm *tasks.Manager, logger *log.Logger) *Server {
+func NewServer(cfg *config.Config, tm *tasks.Manager, logger *slog.Logger) *Server {
 	apiHandler := NewAPIHandler(tm, logger)
 	router := chi.NewRouter()
 
 	// --- Middleware Setup ---
+	// IP rules: client address is only taken from forwarding headers set by trusted proxies
+	trustedProxies, allowedCIDRs, deniedCIDRs, err := parseIPRules(cfg.Security)
+	if err != nil {
+		// Fail closed: deny every address rather than silently opening the API
+		logger.Error("Invalid IP rule configuration, rejecting all API requests", "error", err)
+		trustedProxies, allowedCIDRs = nil, nil
+		deniedCIDRs, _ = ParseCIDRs([]string{"0.0.0.0/0", "::/0"})
+	}
+
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
-	// Use custom logger adapting stdlib logger or replace with structured logger middleware
+	router.Use(TrustedRealIP(trustedProxies))
+	// Tags each request's log lines with its ID
 	router.Use(RequestLogger(logger))
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.Timeout(60 * time.Second)) // Request timeout
+	if cfg.Server.Compression.Enabled {
+		router.Use(Compress(cfg.Server.Compression))
+	}
 
 	// CORS Configuration
 	corsOptions := cors.Options{
 		AllowedOrigins:   cfg.Security.AllowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-Key"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-Key", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: true, // Be careful with this in production
 		MaxAge:           300,  // Maximum value not ignored by any major browsers
 	}
 	router.Use(cors.Handler(corsOptions))
 
-	// API Key Authentication Middleware (Simple Example)
-	if cfg.Security.ApiKey != "" {
-		router.Use(APIKeyAuth(cfg.Security.ApiKey))
+	// Authentication: resolves API keys / JWTs into a role checked per route below
+	authenticator, err := NewAuthenticator(cfg.Security)
+	if err != nil {
+		// Fail closed: an authenticator without credentials rejects every request
+		logger.Error("Invalid security configuration, rejecting all API requests", "error", err)
+		authenticator = &Authenticator{}
 	}
 
 	// --- Route Definitions ---
 	router.Route("/api/v1", func(r chi.Router) {
-		r.Post("/tasks", apiHandler.HandleSubmitTask)
-		r.Get("/tasks/{taskID}", apiHandler.HandleGetTaskStatus)
-		r.Post("/tasks/{taskID}/2fa", apiHandler.HandleProvide2FACode)
-		r.Post("/dom/ast", apiHandler.HandleGetDomAST)
+		r.Use(IPFilter(allowedCIDRs, deniedCIDRs))
+		r.Use(Authenticate(authenticator))
+
+		// Read-only routes
+		r.Group(func(r chi.Router) {
+			r.Use(RequireRole(RoleViewer))
+			r.Get("/tasks", apiHandler.HandleListTasks)
+			r.Get("/tasks/{taskID}", apiHandler.HandleGetTaskStatus)
+			r.Get("/tasks/{taskID}/har", apiHandler.HandleGetTaskHAR)
+			r.Get("/tasks/{taskID}/trace", apiHandler.HandleGetTaskTrace)
+			r.Get("/tasks/{taskID}/artifacts", apiHandler.HandleListTaskArtifacts)
+			r.Get("/tasks/{taskID}/artifacts/{name}", apiHandler.HandleGetTaskArtifact)
+			r.Post("/tasks/status", apiHandler.HandleBulkTaskStatus) // Read-only despite POST
+			r.Get("/stats", apiHandler.HandleGetStats)
+			r.Get("/domains/health", apiHandler.HandleDomainHealth)
+			r.Get("/schema/actions", apiHandler.HandleGetActionSchema)
+			r.Get("/examples", apiHandler.HandleListExamples)
+			r.Get("/examples/{name}", apiHandler.HandleGetExample)
+			r.Get("/grafana", apiHandler.HandleGrafanaHealth)
+			r.Post("/grafana/metrics", apiHandler.HandleGrafanaMetrics) // Grafana JSON datasource; read-only despite POST
+			r.Post("/grafana/search", apiHandler.HandleGrafanaSearch)
+			r.Post("/grafana/query", apiHandler.HandleGrafanaQuery)
+			r.Get("/templates", apiHandler.HandleListTemplates)
+			r.Get("/templates/{name}", apiHandler.HandleGetTemplate)
+			r.Get("/templates/{name}/versions", apiHandler.HandleListTemplateVersions)
+			r.Get("/templates/{name}/diff", apiHandler.HandleDiffTemplate)
+			r.Get("/templates/{name}/flakiness", apiHandler.HandleGetTemplateFlakiness)
+			r.Get("/templates/{name}/canary", apiHandler.HandleGetTemplateCanary)
+			r.Get("/schedules", apiHandler.HandleListSchedules)
+			r.Get("/schedules/{name}", apiHandler.HandleGetSchedule)
+			r.Get("/sessions", apiHandler.HandleListSessions)
+			r.Get("/sessions/{name}", apiHandler.HandleGetSession)
+			r.Get("/profiles", apiHandler.HandleListProfiles)
+			r.Get("/profiles/{name}", apiHandler.HandleGetProfile)
+			r.Get("/credentials/key", apiHandler.HandleGetCredentialKey)
+			r.Get("/simple/runs", apiHandler.HandleListSimpleRuns)
+			r.Get("/simple/runs/{taskID}", apiHandler.HandleGetSimpleRun)
+		})
+
+		// Routes that start or steer browser work
+		r.Group(func(r chi.Router) {
+			r.Use(RequireRole(RoleSubmitter))
+			r.Post("/tasks", apiHandler.HandleSubmitTask)
+			r.Post("/tasks/estimate", apiHandler.HandleEstimateTask)
+			r.Post("/tasks/import", apiHandler.HandleImportTask)
+			r.Post("/tasks/{taskID}/2fa", apiHandler.HandleProvide2FACode)
+			r.Post("/tasks/{taskID}/cancel", apiHandler.HandleCancelTask)
+			r.Post("/dom/ast", apiHandler.HandleGetDomAST)
+			r.Post("/templates/{name}/run", apiHandler.HandleRunTemplate)
+			r.Post("/examples/{name}/run", apiHandler.HandleRunExample)
+			r.Post("/simple/run", apiHandler.HandleSimpleRun)
+			r.Post("/sessions", apiHandler.HandleCreateSession)
+			r.Delete("/sessions/{name}", apiHandler.HandleCloseSession)
+			if cfg.Server.CDPProxy.Enabled {
+				r.Post("/sessions/{name}/cdp", apiHandler.HandleLeaseSessionCDP)
+				r.Delete("/sessions/{name}/cdp", apiHandler.HandleEndSessionCDP)
+			}
+			r.Post("/profiles", apiHandler.HandleCreateProfile)
+			r.Delete("/profiles/{name}", apiHandler.HandleDeleteProfile)
+		})
+
+		// Management routes
+		r.Group(func(r chi.Router) {
+			r.Use(RequireRole(RoleAdmin))
+			r.Put("/templates/{name}", apiHandler.HandlePutTemplate)
+			r.Post("/templates/{name}/rollback", apiHandler.HandleRollbackTemplate)
+			r.Post("/templates/{name}/canary/promote", apiHandler.HandlePromoteTemplateCanary)
+			r.Post("/templates/{name}/canary/abort", apiHandler.HandleAbortTemplateCanary)
+			r.Put("/schedules", apiHandler.HandleSyncSchedules)
+			r.Get("/callbacks/dead-letters", apiHandler.HandleListDeadLetters)
+			r.Get("/callbacks/endpoints", apiHandler.HandleListCallbackEndpoints)
+		})
 	})
 
+	// Web UI: static pages that use the API above with the user's own credentials
+	if cfg.Server.UI.Enabled {
+		router.With(IPFilter(allowedCIDRs, deniedCIDRs)).Handle(uiPath+"*", UIHandler())
+		router.Get(strings.TrimSuffix(uiPath, "/"), func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, uiPath, http.StatusMovedPermanently)
+		})
+	}
+
+	// WebDriver endpoint: Selenium clients drive sessions with the same credentials as the API
+	if cfg.Server.WebDriver.Enabled {
+		router.Route(webDriverPath, func(r chi.Router) {
+			r.Use(IPFilter(allowedCIDRs, deniedCIDRs))
+			r.Use(Authenticate(authenticator))
+			r.Use(RequireRole(RoleSubmitter))
+			r.Mount("/", WebDriverHandler(tm, logger))
+		})
+	}
+
+	// CDP proxy: the lease token in the path is the credential, so outside
+	// DevTools clients can connect without GoScry's API keys
+	if cfg.Server.CDPProxy.Enabled {
+		router.With(IPFilter(allowedCIDRs, deniedCIDRs), LimitAddresses(authenticator)).Handle(cdpPath+"{token}/*", http.HandlerFunc(apiHandler.HandleCDPProxy))
+	}
+
+	// Inbound webhooks: each hook's token or signature is its credential, so
+	// other systems can start its template without API keys
+	if len(cfg.Hooks) > 0 {
+		hooks, err := newHooks(cfg.Hooks)
+		if err != nil {
+			logger.Error("Invalid hooks configuration, inbound webhooks are disabled", "error", err)
+		} else {
+			apiHandler.hooks = hooks
+			router.With(IPFilter(allowedCIDRs, deniedCIDRs), LimitAddresses(authenticator)).Post(hooksPath+"{name}", apiHandler.HandleHook)
+		}
+	}
+
+	// Inbound SMS: the Twilio signature or shared token is the credential,
+	// since providers cannot send API keys
+	sms, err := newSMSWebhook(cfg.TwoFactor.SMS)
+	if err != nil {
+		logger.Error("Invalid twoFactor.sms configuration, SMS codes cannot be received", "error", err)
+	} else if sms != nil {
+		apiHandler.sms = sms
+		router.With(IPFilter(allowedCIDRs, deniedCIDRs), LimitAddresses(authenticator)).Post(smsPath, apiHandler.HandleSMSCode)
+	}
+
 	// Health check endpoint
 	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -71,7 +203,7 @@ func NewServer(cfg *config.Config, tm *tasks.Manager, logger *log.Logger) *Serve
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
-		ErrorLog:     logger, // Use the same logger for server errors
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError), // Use the same logger for server errors
 	}
 
 	return &Server{
@@ -82,8 +214,26 @@ func NewServer(cfg *config.Config, tm *tasks.Manager, logger *log.Logger) *Serve
 	}
 }
 
+func parseIPRules(cfg config.SecurityConfig) (trusted, allowed, denied []*net.IPNet, err error) {
+	if trusted, err = ParseCIDRs(cfg.TrustedProxies); err != nil {
+		return nil, nil, nil, fmt.Errorf("security.trustedProxies: %w", err)
+	}
+	if allowed, err = ParseCIDRs(cfg.AllowedCIDRs); err != nil {
+		return nil, nil, nil, fmt.Errorf("security.allowedCIDRs: %w", err)
+	}
+	if denied, err = ParseCIDRs(cfg.DeniedCIDRs); err != nil {
+		return nil, nil, nil, fmt.Errorf("security.deniedCIDRs: %w", err)
+	}
+	return trusted, allowed, denied, nil
+}
+
+// Start serves the API until Shutdown. It fails at once if the task manager
+// did not start, e.g. because its encryption config is invalid.
 func (s *Server) Start() error {
-	s.logger.Printf("Starting GoScry server on %s", s.httpServer.Addr)
+	if err := s.taskManager.Err(); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	s.logger.Info("Starting GoScry server", "addr", s.httpServer.Addr)
 	err := s.httpServer.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("failed to start server: %w", err)
@@ -92,28 +242,34 @@ func (s *Server) Start() error {
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
-	s.logger.Println("Shutting down server...")
+	s.logger.Info("Shutting down server")
 	err := s.httpServer.Shutdown(ctx)
 	if err != nil {
 		return fmt.Errorf("server shutdown failed: %w", err)
 	}
-	s.logger.Println("Server gracefully stopped.")
+	s.logger.Info("Server gracefully stopped")
 	return nil
 }
 
 // --- Custom Middleware ---
 
-// RequestLogger adapts stdlib logger for basic request logging
-func RequestLogger(logger *log.Logger) func(next http.Handler) http.Handler {
+// RequestLogger logs each request once it is served and tags every line
+// logged while serving it with the ID set by middleware.RequestID.
+func RequestLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(logging.WithRequestID(r.Context(), middleware.GetReqID(r.Context())))
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 			start := time.Now()
 			defer func() {
-				logger.Printf(
-					"\"%s %s %s\" from %s - %d %dB in %v",
-					r.Method, r.RequestURI, r.Proto, r.RemoteAddr,
-					ww.Status(), ww.BytesWritten(), time.Since(start),
+				logger.InfoContext(r.Context(), "Request served",
+					"method", r.Method,
+					"uri", loggedURI(r),
+					"proto", r.Proto,
+					"remote", r.RemoteAddr,
+					"status", ww.Status(),
+					"bytes", ww.BytesWritten(),
+					"duration", time.Since(start),
 				)
 			}()
 			next.ServeHTTP(ww, r)
@@ -122,35 +278,35 @@ func RequestLogger(logger *log.Logger) func(next http.Handler) http.Handler {
 	}
 }
 
-// APIKeyAuth provides simple API Key authentication
-func APIKeyAuth(validKey string) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		fn := func(w http.ResponseWriter, r *http.Request) {
-			// Allow pre-flight OPTIONS requests without auth
-			if r.Method == "OPTIONS" {
-				next.ServeHTTP(w, r)
-				return
-			}
+// redacted replaces credentials in logged request URIs.
+const redacted = "REDACTED"
 
-			apiKey := r.Header.Get("X-API-Key")
-			if apiKey == "" {
-				// Check Authorization header as Bearer token as alternative
-				authHeader := r.Header.Get("Authorization")
-				if strings.HasPrefix(authHeader, "Bearer ") {
-					apiKey = strings.TrimPrefix(authHeader, "Bearer ")
-				}
-			}
-
-			if apiKey == "" {
-				http.Error(w, http.StatusText(http.StatusUnauthorized)+": API key required", http.StatusUnauthorized)
-				return
-			}
-			if apiKey != validKey {
-				http.Error(w, http.StatusText(http.StatusForbidden)+": Invalid API key", http.StatusForbidden)
-				return
-			}
-			next.ServeHTTP(w, r)
+// loggedURI is the request URI with credentials carried in it replaced, so
+// log readers cannot reuse them: the lease token of a CDP proxy path, and
+// the token query parameter webhooks accept.
+func loggedURI(r *http.Request) string {
+	uri, query, hasQuery := strings.Cut(r.RequestURI, "?")
+	if rest, ok := strings.CutPrefix(uri, cdpPath); ok {
+		_, after, nested := strings.Cut(rest, "/")
+		uri = cdpPath + redacted
+		if nested {
+			uri += "/" + after
 		}
-		return http.HandlerFunc(fn)
 	}
+	if !hasQuery {
+		return uri
+	}
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		name, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(name); err == nil && name == "token" {
+			params[i] = "token=" + redacted
+		}
+	}
+	return uri + "?" + strings.Join(params, "&")
+}
+
+// APIKeyAuth provides simple API Key authentication with a single admin key.
+func APIKeyAuth(validKey string) func(next http.Handler) http.Handler {
+	return Authenticate(&Authenticator{keys: []apiKeyEntry{{name: "default", key: validKey, role: RoleAdmin}}})
 }
@@ -0,0 +1,288 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateSessionRequest names a new browser session.
+type CreateSessionRequest struct {
+	Name        string               `json:"name"`
+	KeepAlive   *KeepAliveRequest    `json:"keep_alive,omitempty"`
+	MaxLifetime string               `json:"max_lifetime,omitempty"` // e.g. "8h"; empty keeps the session until closed
+	Login       *SessionLoginRequest `json:"login,omitempty"`
+	Profile     string               `json:"profile,omitempty"` // Profile snapshot to start from, see options.profile
+}
+
+// SessionLoginRequest names the template a session runs to sign back in when
+// a task lands on a login page.
+type SessionLoginRequest struct {
+	Template             string                        `json:"template"`
+	Version              int                           `json:"version,omitempty"` // Zero uses the latest version at re-login time
+	Match                string                        `json:"match,omitempty"`   // Regexp for login page URLs
+	Username             string                        `json:"username,omitempty"`
+	Password             string                        `json:"password,omitempty"`
+	EncryptedCredentials *taskstypes.SealedCredentials `json:"encrypted_credentials,omitempty"`
+	CredentialsRef       string                        `json:"credentials_ref,omitempty"` // e.g. "vault:crm/login", looked up at each re-login
+	TOTPSecret           string                        `json:"totp_secret,omitempty"`     // Fills {{task.tfa_code}} in the template
+}
+
+// KeepAliveRequest enables periodic activity on an idle session.
+type KeepAliveRequest struct {
+	Interval string `json:"interval"`         // e.g. "5m"
+	Jitter   string `json:"jitter,omitempty"` // Random extra delay per ping, e.g. "30s"
+	URL      string `json:"url,omitempty"`    // Page to load; empty reloads the current page
+}
+
+// minKeepAliveInterval keeps keep-alive pings from hammering the target site.
+const minKeepAliveInterval = 10 * time.Second
+
+// keepAliveURLSchemes are the schemes keep_alive.url may use.
+var keepAliveURLSchemes = []string{"http", "https"}
+
+// sessionOptions parses the lifetime settings of a create request.
+func (req CreateSessionRequest) sessionOptions() (taskstypes.SessionOptions, error) {
+	var opts taskstypes.SessionOptions
+	var err error
+	if req.MaxLifetime != "" {
+		if opts.MaxLifetime, err = time.ParseDuration(req.MaxLifetime); err != nil || opts.MaxLifetime <= 0 {
+			return opts, fmt.Errorf("invalid max_lifetime %q", req.MaxLifetime)
+		}
+	}
+	if ka := req.KeepAlive; ka != nil {
+		if opts.KeepAlive, err = time.ParseDuration(ka.Interval); err != nil || opts.KeepAlive < minKeepAliveInterval {
+			return opts, fmt.Errorf("keep_alive.interval must be a duration of at least %s", minKeepAliveInterval)
+		}
+		if ka.Jitter != "" {
+			if opts.KeepAliveJitter, err = time.ParseDuration(ka.Jitter); err != nil || opts.KeepAliveJitter < 0 {
+				return opts, fmt.Errorf("invalid keep_alive.jitter %q", ka.Jitter)
+			}
+		}
+		if ka.URL != "" {
+			// Pings run outside any task, so only web pages are allowed
+			if opts.KeepAliveURL, _, err = taskstypes.NormalizeURL(ka.URL, keepAliveURLSchemes); err != nil {
+				return opts, fmt.Errorf("invalid keep_alive.url: %v", err)
+			}
+		}
+	}
+	if req.Profile != "" {
+		if err := taskstypes.ValidateProfileName(req.Profile); err != nil {
+			return opts, err
+		}
+		opts.Profile = req.Profile
+	}
+	if login := req.Login; login != nil {
+		if login.Template == "" {
+			return opts, fmt.Errorf("login.template is required")
+		}
+		if login.Match != "" {
+			if _, err := regexp.Compile(login.Match); err != nil {
+				return opts, fmt.Errorf("invalid login.match: %v", err)
+			}
+		}
+		if login.Password != "" && login.EncryptedCredentials != nil {
+			return opts, fmt.Errorf("provide either login.password or login.encrypted_credentials, not both")
+		}
+		if login.CredentialsRef != "" && (login.Username != "" || login.Password != "" || login.EncryptedCredentials != nil) {
+			return opts, fmt.Errorf("login.credentials_ref cannot be combined with other login credentials")
+		}
+		opts.Login = &taskstypes.SessionLogin{
+			Template:       login.Template,
+			Version:        login.Version,
+			Match:          login.Match,
+			SealedCreds:    login.EncryptedCredentials,
+			CredentialsRef: login.CredentialsRef,
+			TOTPSecret:     login.TOTPSecret,
+		}
+		if login.Username != "" || login.Password != "" {
+			opts.Login.Credentials = &taskstypes.Credentials{Username: login.Username, Password: login.Password}
+		}
+	}
+	return opts, nil
+}
+
+// HandleCreateSession starts a named browser session that later tasks can reuse.
+func (h *APIHandler) HandleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req CreateSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	if req.Name == "" {
+		h.respondError(w, r, http.StatusBadRequest, "Session name is required")
+		return
+	}
+
+	opts, err := req.sessionOptions()
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "%v", err)
+		return
+	}
+	opts.Owner = ownerTenant(r)
+	if err := checkProfile(r, h.taskManager, opts.Profile); err != nil {
+		h.respondProfileError(w, r, err)
+		return
+	}
+	if opts.KeepAliveURL != "" {
+		if err := h.taskManager.URLPolicy().CheckResolved(r.Context(), opts.KeepAliveURL, true); err != nil {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid keep_alive.url: %v", err)
+			return
+		}
+	}
+	if login := opts.Login; login != nil {
+		if _, err := h.taskManager.Templates().Get(login.Template, login.Version); err != nil {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid login template: %v", err)
+			return
+		}
+		if login.SealedCreds != nil {
+			if err := h.taskManager.CredentialKey().Check(login.SealedCreds); err != nil {
+				h.respondError(w, r, http.StatusBadRequest, "%v", err)
+				return
+			}
+		}
+		if login.CredentialsRef != "" {
+			if err := h.taskManager.CheckCredentialsRef(login.CredentialsRef); err != nil {
+				h.respondError(w, r, http.StatusBadRequest, "%v", err)
+				return
+			}
+			if err := checkCredentialsRef(r, login.CredentialsRef); err != nil {
+				h.respondError(w, r, http.StatusForbidden, "%v", err)
+				return
+			}
+		}
+	}
+
+	sessions, ok := h.sessions(w, r)
+	if !ok {
+		return
+	}
+	info, err := sessions.CreateSession(req.Name, opts)
+	if err != nil {
+		h.respondSessionError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, info)
+}
+
+// HandleListSessions returns the open browser sessions the caller may use.
+func (h *APIHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, ok := h.sessions(w, r)
+	if !ok {
+		return
+	}
+	list := []taskstypes.SessionInfo{}
+	for _, info := range sessions.ListSessions() {
+		if mayUse(r, info.Owner) {
+			list = append(list, info)
+		}
+	}
+	h.respondJSON(w, http.StatusOK, list)
+}
+
+// HandleGetSession returns a single browser session.
+func (h *APIHandler) HandleGetSession(w http.ResponseWriter, r *http.Request) {
+	sessions, ok := h.sessions(w, r)
+	if !ok {
+		return
+	}
+	info, err := callerSession(r, sessions, chi.URLParam(r, "name"))
+	if err != nil {
+		h.respondSessionError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, info)
+}
+
+// HandleCloseSession closes a browser session once its running task, if any, finishes.
+func (h *APIHandler) HandleCloseSession(w http.ResponseWriter, r *http.Request) {
+	sessions, ok := h.sessions(w, r)
+	if !ok {
+		return
+	}
+	name := chi.URLParam(r, "name")
+	if _, err := callerSession(r, sessions, name); err != nil {
+		h.respondSessionError(w, r, err)
+		return
+	}
+	if err := sessions.CloseSession(name); err != nil {
+		h.respondSessionError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIHandler) sessions(w http.ResponseWriter, r *http.Request) (tasks.SessionExecutor, bool) {
+	sessions, err := h.taskManager.Sessions()
+	if err != nil {
+		h.respondError(w, r, http.StatusNotImplemented, "%v", err)
+		return nil, false
+	}
+	return sessions, true
+}
+
+// callerSession returns the named session if the caller r may use it.
+// Other tenants' sessions are reported as not found.
+func callerSession(r *http.Request, sessions tasks.SessionExecutor, name string) (*taskstypes.SessionInfo, error) {
+	info, err := sessions.GetSession(name)
+	if err != nil {
+		return nil, err
+	}
+	if !mayUse(r, info.Owner) {
+		return nil, fmt.Errorf("%w: %s", tasks.ErrSessionNotFound, name)
+	}
+	return info, nil
+}
+
+// callerMayUseSession responds with an error and returns false unless the
+// named session exists and the caller r may use it.
+func (h *APIHandler) callerMayUseSession(w http.ResponseWriter, r *http.Request, name string) bool {
+	sessions, ok := h.sessions(w, r)
+	if !ok {
+		return false
+	}
+	if _, err := callerSession(r, sessions, name); err != nil {
+		h.respondSessionError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// checkTaskResources rejects a task that names a session or profile the
+// caller r may not use.
+func checkTaskResources(r *http.Request, tm *tasks.Manager, task *taskstypes.Task) error {
+	if task.Session != "" {
+		// Without session support SubmitTask reports the task's session itself
+		if sessions, err := tm.Sessions(); err == nil {
+			if _, err := callerSession(r, sessions, task.Session); err != nil {
+				return err
+			}
+		}
+	}
+	if profile := task.Options.Profile; profile != nil {
+		return checkProfile(r, tm, profile.Name)
+	}
+	return nil
+}
+
+func (h *APIHandler) respondSessionError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, tasks.ErrSessionNotFound):
+		h.respondError(w, r, http.StatusNotFound, "%v", err)
+	case errors.Is(err, tasks.ErrSessionExists), errors.Is(err, tasks.ErrSessionBusy):
+		h.respondError(w, r, http.StatusConflict, "%v", err)
+	case errors.Is(err, tasks.ErrSessionsUnsupported), errors.Is(err, tasks.ErrCDPUnsupported):
+		h.respondError(w, r, http.StatusNotImplemented, "%v", err)
+	default:
+		h.respondError(w, r, http.StatusInternalServerError, "Session error: %v", err)
+	}
+}
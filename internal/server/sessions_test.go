@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateSessionRequest_SessionOptions(t *testing.T) {
+	opts, err := CreateSessionRequest{Name: "crm"}.sessionOptions()
+	require.NoError(t, err)
+	assert.Equal(t, taskstypes.SessionOptions{}, opts)
+
+	opts, err = CreateSessionRequest{
+		Name:        "crm",
+		MaxLifetime: "8h",
+		KeepAlive:   &KeepAliveRequest{Interval: "5m", Jitter: "30s", URL: "https://crm.example.com/ping"},
+	}.sessionOptions()
+	require.NoError(t, err)
+	assert.Equal(t, taskstypes.SessionOptions{
+		KeepAlive:       5 * time.Minute,
+		KeepAliveJitter: 30 * time.Second,
+		KeepAliveURL:    "https://crm.example.com/ping",
+		MaxLifetime:     8 * time.Hour,
+	}, opts)
+
+	for _, req := range []CreateSessionRequest{
+		{MaxLifetime: "forever"},
+		{MaxLifetime: "-1h"},
+		{KeepAlive: &KeepAliveRequest{}},
+		{KeepAlive: &KeepAliveRequest{Interval: "1s"}},
+		{KeepAlive: &KeepAliveRequest{Interval: "1m", Jitter: "-5s"}},
+		{KeepAlive: &KeepAliveRequest{Interval: "1m", URL: "file:///etc/passwd"}},
+		{KeepAlive: &KeepAliveRequest{Interval: "1m", URL: "javascript:alert(1)"}},
+		{KeepAlive: &KeepAliveRequest{Interval: "1m", URL: "crm.example.com/ping"}},
+	} {
+		_, err := req.sessionOptions()
+		assert.Error(t, err, "%+v", req)
+	}
+}
+
+func TestCreateSessionRequest_Login(t *testing.T) {
+	opts, err := CreateSessionRequest{
+		Name:  "crm",
+		Login: &SessionLoginRequest{Template: "crm-login", Username: "ops", Password: "hunter2", TOTPSecret: "JBSWY3DPEHPK3PXP"},
+	}.sessionOptions()
+	require.NoError(t, err)
+	require.NotNil(t, opts.Login)
+	assert.Equal(t, "crm-login", opts.Login.Template)
+	assert.Equal(t, &taskstypes.Credentials{Username: "ops", Password: "hunter2"}, opts.Login.Credentials)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", opts.Login.TOTPSecret)
+
+	for _, login := range []*SessionLoginRequest{
+		{},
+		{Template: "crm-login", Match: "(unclosed"},
+		{Template: "crm-login", Password: "x", EncryptedCredentials: &taskstypes.SealedCredentials{}},
+	} {
+		_, err := CreateSessionRequest{Name: "crm", Login: login}.sessionOptions()
+		assert.Error(t, err, "%+v", login)
+	}
+}
+
+func TestCreateSessionRequest_Profile(t *testing.T) {
+	opts, err := CreateSessionRequest{Name: "crm", Profile: "crm-sso"}.sessionOptions()
+	require.NoError(t, err)
+	assert.Equal(t, "crm-sso", opts.Profile)
+
+	_, err = CreateSessionRequest{Name: "crm", Profile: "../crm"}.sessionOptions()
+	assert.Error(t, err)
+}
+
+func TestHandleCreateSession_KeepAliveURLPolicy(t *testing.T) {
+	logger := logging.Discard()
+	cfg := &config.Config{Security: config.SecurityConfig{URLPolicy: config.URLPolicyConfig{BlockPrivateNetworks: true}}}
+	h := NewAPIHandler(tasks.NewManager(cfg, mocks.NewMockBrowserExecutor(), logger), logger)
+
+	rec := httptest.NewRecorder()
+	body := `{"name": "crm", "keep_alive": {"interval": "5m", "url": "http://169.254.169.254/latest/meta-data/"}}`
+	h.HandleCreateSession(rec, httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "private network")
+}
+
+// ownedExecutor keeps sessions and profiles with their owners, and refuses
+// every CDP lease as busy.
+type ownedExecutor struct {
+	mu       sync.Mutex
+	sessions map[string]taskstypes.SessionInfo
+	profiles map[string]taskstypes.ProfileInfo
+}
+
+func (e *ownedExecutor) ExecuteTask(ctx context.Context, task *taskstypes.Task) (*taskstypes.TaskResult, error) {
+	return &taskstypes.TaskResult{Success: true}, nil
+}
+
+func (e *ownedExecutor) Shutdown(ctx context.Context) error { return nil }
+
+func (e *ownedExecutor) CreateSession(name string, opts taskstypes.SessionOptions) (*taskstypes.SessionInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	info := taskstypes.SessionInfo{Name: name, Owner: opts.Owner, Profile: opts.Profile}
+	e.sessions[name] = info
+	return &info, nil
+}
+
+func (e *ownedExecutor) GetSession(name string) (*taskstypes.SessionInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	info, ok := e.sessions[name]
+	if !ok {
+		return nil, tasks.ErrSessionNotFound
+	}
+	return &info, nil
+}
+
+func (e *ownedExecutor) ListSessions() []taskstypes.SessionInfo {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var list []taskstypes.SessionInfo
+	for _, info := range e.sessions {
+		list = append(list, info)
+	}
+	return list
+}
+
+func (e *ownedExecutor) CloseSession(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.sessions, name)
+	return nil
+}
+
+func (e *ownedExecutor) LeaseCDP(name string) (*tasks.CDPLease, error) {
+	return nil, tasks.ErrSessionBusy
+}
+
+func (e *ownedExecutor) CreateProfile(name, owner, description string, autoSave bool) (taskstypes.ProfileInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	info := taskstypes.ProfileInfo{Name: name, Owner: owner}
+	e.profiles[name] = info
+	return info, nil
+}
+
+func (e *ownedExecutor) ListProfiles() ([]taskstypes.ProfileInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var list []taskstypes.ProfileInfo
+	for _, info := range e.profiles {
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+func (e *ownedExecutor) DeleteProfile(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.profiles, name)
+	return nil
+}
+
+func TestSessionsAndProfiles_OwnerScoped(t *testing.T) {
+	logger := logging.Discard()
+	executor := &ownedExecutor{sessions: make(map[string]taskstypes.SessionInfo), profiles: make(map[string]taskstypes.ProfileInfo)}
+	h := NewAPIHandler(tasks.NewManager(nil, executor, logger), logger)
+	router := chi.NewRouter()
+	router.Post("/sessions", h.HandleCreateSession)
+	router.Get("/sessions", h.HandleListSessions)
+	router.Get("/sessions/{name}", h.HandleGetSession)
+	router.Delete("/sessions/{name}", h.HandleCloseSession)
+	router.Post("/sessions/{name}/cdp", h.HandleLeaseSessionCDP)
+	router.Delete("/sessions/{name}/cdp", h.HandleEndSessionCDP)
+	router.Post("/profiles", h.HandleCreateProfile)
+	router.Get("/profiles", h.HandleListProfiles)
+	router.Get("/profiles/{name}", h.HandleGetProfile)
+	router.Delete("/profiles/{name}", h.HandleDeleteProfile)
+	router.Post("/tasks", h.HandleSubmitTask)
+
+	as := func(p Principal, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req.WithContext(withPrincipal(req.Context(), p)))
+		return rec
+	}
+	acme := Principal{Name: "acme-ci", Role: RoleSubmitter, Tenant: "acme"}
+	other := Principal{Name: "globex-ci", Role: RoleSubmitter, Tenant: "globex"}
+	admin := Principal{Name: "ops", Role: RoleAdmin, Tenant: "globex"}
+
+	require.Equal(t, http.StatusCreated, as(acme, http.MethodPost, "/profiles", `{"name": "acme-sso"}`).Code)
+	require.Equal(t, http.StatusCreated, as(acme, http.MethodPost, "/sessions", `{"name": "crm", "profile": "acme-sso"}`).Code)
+	assert.Equal(t, "acme", executor.sessions["crm"].Owner)
+	assert.Equal(t, "acme", executor.profiles["acme-sso"].Owner)
+
+	for _, path := range []string{"/sessions/crm", "/profiles/acme-sso"} {
+		assert.Equal(t, http.StatusOK, as(acme, http.MethodGet, path, "").Code, path)
+		assert.Equal(t, http.StatusNotFound, as(other, http.MethodGet, path, "").Code, "%s of another tenant", path)
+		assert.Equal(t, http.StatusOK, as(admin, http.MethodGet, path, "").Code, path)
+		assert.Equal(t, http.StatusNotFound, as(other, http.MethodDelete, path, "").Code, "%s of another tenant", path)
+	}
+	for _, path := range []string{"/sessions", "/profiles"} {
+		assert.JSONEq(t, `[]`, as(other, http.MethodGet, path, "").Body.String(), path)
+		assert.Contains(t, as(acme, http.MethodGet, path, "").Body.String(), `"owner":"acme"`, path)
+	}
+
+	assert.Equal(t, http.StatusNotFound, as(other, http.MethodPost, "/sessions/crm/cdp", "").Code)
+	assert.Equal(t, http.StatusNotFound, as(other, http.MethodDelete, "/sessions/crm/cdp", "").Code)
+	assert.Equal(t, http.StatusConflict, as(acme, http.MethodPost, "/sessions/crm/cdp", "").Code, "the owner gets as far as the lease")
+
+	navigate := `{"actions": [{"type": "navigate", "value": "https://example.com"}]`
+	assert.Equal(t, http.StatusBadRequest, as(other, http.MethodPost, "/tasks", navigate+`, "session": "crm"}`).Code)
+	assert.Equal(t, http.StatusForbidden, as(other, http.MethodPost, "/tasks", navigate+`, "options": {"profile": {"name": "acme-sso"}}}`).Code)
+	assert.Equal(t, http.StatusForbidden, as(other, http.MethodPost, "/sessions", `{"name": "spy", "profile": "acme-sso"}`).Code)
+	assert.Equal(t, http.StatusAccepted, as(acme, http.MethodPost, "/tasks", navigate+`, "session": "crm"}`).Code)
+	assert.Equal(t, http.StatusAccepted, as(other, http.MethodPost, "/tasks", navigate+`, "options": {"profile": {"name": "globex-sso", "save": true}}}`).Code,
+		"a profile that does not exist yet can be used")
+
+	assert.Equal(t, http.StatusNoContent, as(acme, http.MethodDelete, "/sessions/crm", "").Code)
+	assert.Equal(t, http.StatusNoContent, as(admin, http.MethodDelete, "/profiles/acme-sso", "").Code)
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+)
+
+// Headers carried by HMAC-signed requests.
+const (
+	HeaderKeyID     = "X-GoScry-Key-Id"
+	HeaderTimestamp = "X-GoScry-Timestamp" // Unix seconds
+	HeaderSignature = "X-GoScry-Signature" // Hex HMAC-SHA256 of the canonical request
+)
+
+const (
+	defaultReplayWindow = 5 * time.Minute
+	maxSignedBodyBytes  = 10 << 20
+)
+
+// Signature computes the hex HMAC-SHA256 a client must send for a request.
+// The signed string is the timestamp, method, request URI (path and query),
+// and hex SHA-256 of the body, separated by newlines.
+func Signature(secret, timestamp, method, requestURI string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", timestamp, method, requestURI, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type hmacClient struct {
+	keyID  string
+	secret string
+	role   Role
+	tenant string
+	refs   []string
+}
+
+// requestSigner verifies signed requests and remembers recent signatures so
+// each one can be used only once within the replay window.
+type requestSigner struct {
+	clients map[string]hmacClient
+	window  time.Duration
+	now     func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // signature -> expiry
+	lastSweep time.Time
+}
+
+func newRequestSigner(cfg config.HMACConfig) (*requestSigner, error) {
+	if len(cfg.Clients) == 0 {
+		return nil, nil
+	}
+	s := &requestSigner{
+		clients: make(map[string]hmacClient, len(cfg.Clients)),
+		window:  cfg.ReplayWindow,
+		now:     time.Now,
+		seen:    make(map[string]time.Time),
+	}
+	if s.window <= 0 {
+		s.window = defaultReplayWindow
+	}
+	for i, c := range cfg.Clients {
+		if c.KeyID == "" || c.Secret == "" {
+			return nil, fmt.Errorf("security.hmac.clients[%d] needs both keyId and secret", i)
+		}
+		if _, dup := s.clients[c.KeyID]; dup {
+			return nil, fmt.Errorf("security.hmac.clients[%d]: duplicate keyId %q", i, c.KeyID)
+		}
+		role, err := ParseRole(c.Role)
+		if err != nil {
+			return nil, fmt.Errorf("security.hmac.clients[%d]: %w", i, err)
+		}
+		tenant := c.Tenant
+		if tenant == "" {
+			tenant = c.KeyID
+		}
+		s.clients[c.KeyID] = hmacClient{keyID: c.KeyID, secret: c.Secret, role: role, tenant: tenant, refs: c.CredentialRefs}
+	}
+	return s, nil
+}
+
+// verify checks the signature headers against the request and restores the
+// body for downstream handlers.
+func (s *requestSigner) verify(r *http.Request) (Principal, error) {
+	client, ok := s.clients[r.Header.Get(HeaderKeyID)]
+	if !ok {
+		return Principal{}, fmt.Errorf("unknown signing key")
+	}
+
+	timestamp := r.Header.Get(HeaderTimestamp)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return Principal{}, fmt.Errorf("invalid %s header", HeaderTimestamp)
+	}
+	now := s.now()
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > s.window {
+		return Principal{}, fmt.Errorf("request timestamp outside the %s replay window", s.window)
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBodyBytes+1))
+		r.Body.Close()
+		if err != nil {
+			return Principal{}, fmt.Errorf("failed to read request body")
+		}
+		if len(body) > maxSignedBodyBytes {
+			return Principal{}, fmt.Errorf("signed request body too large")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := Signature(client.secret, timestamp, r.Method, r.URL.RequestURI(), body)
+	signature := r.Header.Get(HeaderSignature)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return Principal{}, fmt.Errorf("invalid request signature")
+	}
+
+	if !s.markSeen(expected, now) {
+		return Principal{}, fmt.Errorf("request signature already used")
+	}
+	return Principal{Name: client.keyID, Role: client.role, Tenant: client.tenant, CredentialRefs: client.refs}, nil
+}
+
+// markSeen records a signature, returning false if it was already used.
+// Entries expire after twice the window, covering timestamps on either side
+// of now, and are swept out once per window rather than on every request.
+func (s *requestSigner) markSeen(signature string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= s.window {
+		for sig, expiry := range s.seen {
+			if now.After(expiry) {
+				delete(s.seen, sig)
+			}
+		}
+		s.lastSweep = now
+	}
+	if expiry, used := s.seen[signature]; used && !now.After(expiry) {
+		return false
+	}
+	s.seen[signature] = now.Add(2 * s.window)
+	return true
+}
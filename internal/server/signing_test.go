@@ -0,0 +1,101 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedRequest(keyID, secret string, ts time.Time, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks?dry=1", strings.NewReader(body))
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	req.Header.Set(HeaderKeyID, keyID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Signature(secret, timestamp, req.Method, req.URL.RequestURI(), []byte(body)))
+	return req
+}
+
+func TestAuthenticate_HMAC(t *testing.T) {
+	a, err := NewAuthenticator(config.SecurityConfig{
+		HMAC: config.HMACConfig{
+			ReplayWindow: time.Minute,
+			Clients:      []config.HMACClientConfig{{KeyID: "billing", Secret: "s3cret", Role: "submitter", CredentialRefs: []string{"vault:billing/"}}},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, a)
+
+	var gotBody string
+	h := Authenticate(a)(RequireRole(RoleSubmitter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		p, _ := PrincipalFromContext(r.Context())
+		assert.Equal(t, "billing", p.Name)
+		assert.Equal(t, "billing", p.Tenant)
+		assert.Equal(t, []string{"vault:billing/"}, p.CredentialRefs)
+	})))
+
+	serve := func(req *http.Request) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	now := time.Now()
+	body := `{"actions":[]}`
+
+	req := signedRequest("billing", "s3cret", now, body)
+	assert.Equal(t, http.StatusOK, serve(req))
+	assert.Equal(t, body, gotBody, "body must be readable after verification")
+
+	// Exact replay of the same signed request
+	assert.Equal(t, http.StatusForbidden, serve(signedRequest("billing", "s3cret", now, body)))
+
+	assert.Equal(t, http.StatusForbidden, serve(signedRequest("billing", "s3cret", now.Add(-2*time.Minute), body)), "stale timestamp")
+	assert.Equal(t, http.StatusForbidden, serve(signedRequest("billing", "wrong", now.Add(time.Second), body)), "bad secret")
+	assert.Equal(t, http.StatusForbidden, serve(signedRequest("unknown", "s3cret", now.Add(2*time.Second), body)), "unknown key")
+
+	tampered := signedRequest("billing", "s3cret", now.Add(3*time.Second), body)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"actions":[{"type":"navigate"}]}`))
+	assert.Equal(t, http.StatusForbidden, serve(tampered), "tampered body")
+
+	assert.Equal(t, http.StatusOK, serve(signedRequest("billing", "s3cret", now.Add(4*time.Second), body)))
+}
+
+func TestNewAuthenticator_HMACValidation(t *testing.T) {
+	_, err := NewAuthenticator(config.SecurityConfig{
+		HMAC: config.HMACConfig{Clients: []config.HMACClientConfig{{KeyID: "x", Role: "viewer"}}},
+	})
+	assert.Error(t, err)
+
+	_, err = NewAuthenticator(config.SecurityConfig{
+		HMAC: config.HMACConfig{Clients: []config.HMACClientConfig{{KeyID: "x", Secret: "y", Role: "root"}}},
+	})
+	assert.Error(t, err)
+}
+
+func TestRequestSigner_MarkSeen(t *testing.T) {
+	s := &requestSigner{window: time.Minute, seen: make(map[string]time.Time)}
+	now := time.Now()
+
+	assert.True(t, s.markSeen("a", now))
+	assert.False(t, s.markSeen("a", now.Add(time.Second)))
+	assert.True(t, s.markSeen("b", now.Add(30*time.Second)))
+	assert.Len(t, s.seen, 2)
+
+	// "a" has expired and a window has passed since the first sweep
+	assert.True(t, s.markSeen("c", now.Add(2*time.Minute+time.Second)))
+	assert.Len(t, s.seen, 2, "the sweep dropped the expired signature")
+	assert.True(t, s.markSeen("d", now.Add(2*time.Minute+40*time.Second)))
+	assert.Len(t, s.seen, 3, "no sweep within a window of the last one")
+	assert.True(t, s.markSeen("b", now.Add(2*time.Minute+40*time.Second)), "an expired signature is not a replay before it is swept")
+	assert.False(t, s.markSeen("c", now.Add(2*time.Minute+50*time.Second)))
+}
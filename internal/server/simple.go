@@ -0,0 +1,403 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// simpleRunsPath is where simplified runs are polled, relative to the API root.
+const simpleRunsPath = "/api/v1/simple/runs/"
+
+// Prefixes of flat /simple/run fields that set a template variable or a tag,
+// for clients that cannot send nested objects.
+const (
+	simpleVarPrefix = "var_"
+	simpleTagPrefix = "tag_"
+)
+
+// simpleFormats are the get_dom formats a simplified run may return.
+var simpleFormats = map[string]bool{"": true, "text_content": true, "simplified_html": true, "full_html": true}
+
+// SimpleRunRequest is a task described with flat fields, for Zapier, n8n, and
+// other low-code tools that cannot build an action list. Either URL or
+// Template is required.
+type SimpleRunRequest struct {
+	// URL is loaded, WaitFor is waited for, Click is clicked, and the text
+	// (or Format) of Selector, or of the whole page, is the result.
+	URL      string
+	WaitFor  string
+	Click    string
+	Selector string
+	Format   string
+
+	// Template runs a saved template instead, with Variables filling its
+	// {{vars.<name>}} placeholders.
+	Template        string
+	TemplateVersion int
+	Variables       map[string]string
+
+	CallbackURL string
+	ReferenceID string
+	Priority    taskstypes.TaskPriority
+	Tags        map[string]string
+}
+
+// SimpleRunResponse says where to poll a submitted run.
+type SimpleRunResponse struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	PollURL string `json:"poll_url"`
+}
+
+// SimpleRun is a task's state with every field always present, so low-code
+// tools can map fields before the first run finishes. Times are RFC 3339 or
+// empty, and Result is the output as text, with structured data as JSON.
+type SimpleRun struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	Done        bool   `json:"done"`
+	Success     bool   `json:"success"`
+	Result      string `json:"result"`
+	Error       string `json:"error"`
+	ErrorCode   string `json:"error_code"`
+	Template    string `json:"template"`
+	ReferenceID string `json:"reference_id"`
+	CreatedAt   string `json:"created_at"`
+	StartedAt   string `json:"started_at"`
+	FinishedAt  string `json:"finished_at"`
+	PollURL     string `json:"poll_url"`
+}
+
+// parseSimpleRunRequest reads a JSON or form body of flat fields. Numbers and
+// booleans are accepted wherever text is, since low-code tools often send them.
+func parseSimpleRunRequest(contentType string, body []byte) (SimpleRunRequest, error) {
+	var req SimpleRunRequest
+	payload, err := parseHookPayload(contentType, body)
+	if err != nil {
+		return req, err
+	}
+	fields, ok := payload.(map[string]interface{})
+	if !ok {
+		return req, fmt.Errorf("expected an object of fields")
+	}
+
+	var unknown []string
+	for key, raw := range fields {
+		if key == "variables" {
+			vars, ok := raw.(map[string]interface{})
+			if !ok {
+				return req, fmt.Errorf("variables must be an object")
+			}
+			for name, value := range vars {
+				if err := req.setVariable(name, value); err != nil {
+					return req, err
+				}
+			}
+			continue
+		}
+		value, err := fieldText(raw)
+		if err != nil {
+			return req, fmt.Errorf("%s: %w", key, err)
+		}
+		switch {
+		case key == "url":
+			req.URL = value
+		case key == "wait_for":
+			req.WaitFor = value
+		case key == "click":
+			req.Click = value
+		case key == "selector":
+			req.Selector = value
+		case key == "format":
+			req.Format = value
+		case key == "template":
+			req.Template = value
+		case key == "template_version":
+			if value != "" {
+				if req.TemplateVersion, err = strconv.Atoi(value); err != nil || req.TemplateVersion < 0 {
+					return req, fmt.Errorf("invalid template_version %q", value)
+				}
+			}
+		case key == "callback_url":
+			req.CallbackURL = value
+		case key == "reference_id":
+			req.ReferenceID = value
+		case key == "priority":
+			req.Priority = taskstypes.TaskPriority(value)
+		case strings.HasPrefix(key, simpleVarPrefix) && len(key) > len(simpleVarPrefix):
+			if err := req.setVariable(strings.TrimPrefix(key, simpleVarPrefix), raw); err != nil {
+				return req, err
+			}
+		case strings.HasPrefix(key, simpleTagPrefix) && len(key) > len(simpleTagPrefix):
+			if req.Tags == nil {
+				req.Tags = make(map[string]string)
+			}
+			req.Tags[strings.TrimPrefix(key, simpleTagPrefix)] = value
+		default:
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return req, fmt.Errorf("unknown fields: %s", strings.Join(unknown, ", "))
+	}
+	return req, nil
+}
+
+func (req *SimpleRunRequest) setVariable(name string, raw interface{}) error {
+	value, err := fieldText(raw)
+	if err != nil {
+		return fmt.Errorf("variable %s: %w", name, err)
+	}
+	if req.Variables == nil {
+		req.Variables = make(map[string]string)
+	}
+	req.Variables[name] = value
+	return nil
+}
+
+// pageActions builds the actions of a URL run.
+func (req SimpleRunRequest) pageActions() ([]taskstypes.Action, error) {
+	if !simpleFormats[req.Format] {
+		return nil, fmt.Errorf("format must be text_content, simplified_html, or full_html, got %q", req.Format)
+	}
+	actions := []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: req.URL}}
+	if req.WaitFor != "" {
+		actions = append(actions, taskstypes.Action{Type: taskstypes.ActionWaitVisible, Selector: req.WaitFor})
+	}
+	if req.Click != "" {
+		actions = append(actions, taskstypes.Action{Type: taskstypes.ActionClick, Selector: req.Click})
+	}
+	return append(actions, taskstypes.Action{Type: taskstypes.ActionGetDOM, Selector: req.Selector, Format: req.Format}), nil
+}
+
+// HandleSimpleRun submits a task described with flat fields and returns
+// where to poll it.
+func (h *APIHandler) HandleSimpleRun(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHookBody))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Failed to read request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	req, err := parseSimpleRunRequest(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	if err := validateLabels(req.Tags, req.ReferenceID); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	if err := req.Priority.Validate(); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+
+	var actions []taskstypes.Action
+	var tmpl *tasks.Template
+	var canary string
+	switch {
+	case (req.URL == "") == (req.Template == ""):
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: set either url or template")
+		return
+	case req.Template != "":
+		if req.WaitFor != "" || req.Click != "" || req.Selector != "" || req.Format != "" {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid request: wait_for, click, selector, and format only apply to url runs")
+			return
+		}
+		if tmpl, canary, err = h.taskManager.Templates().ForRun(req.Template, req.TemplateVersion); err != nil {
+			h.respondTemplateError(w, r, err)
+			return
+		}
+		if actions, err = tasks.ApplyVariables(tmpl.Actions, req.Variables); err != nil {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+			return
+		}
+	default:
+		if len(req.Variables) > 0 || req.TemplateVersion != 0 {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid request: variables and template_version only apply to template runs")
+			return
+		}
+		if actions, err = req.pageActions(); err != nil {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+			return
+		}
+	}
+
+	task := newTask(r, SubmitTaskRequest{
+		Actions:     actions,
+		CallbackURL: req.CallbackURL,
+		Tags:        req.Tags,
+		ReferenceID: req.ReferenceID,
+		Priority:    req.Priority,
+	})
+	if tmpl != nil {
+		task.TemplateName = tmpl.Name
+		task.TemplateVersion = tmpl.Version
+		task.Canary = canary
+	}
+	if err := h.taskManager.SubmitTask(task); err != nil {
+		h.respondSubmitError(w, r, err)
+		return
+	}
+
+	pollURL := simplePollURL(r, task.ID)
+	w.Header().Set("Location", pollURL)
+	h.respondJSON(w, http.StatusAccepted, SimpleRunResponse{ID: task.ID.String(), Status: string(task.Status), PollURL: pollURL})
+}
+
+// HandleGetSimpleRun returns one run in the simplified shape. With ?wait=
+// it holds the response until the run finishes, up to maxStatusWait.
+func (h *APIHandler) HandleGetSimpleRun(w http.ResponseWriter, r *http.Request) {
+	taskID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid run ID format")
+		return
+	}
+	var wait time.Duration
+	if raw := r.URL.Query().Get("wait"); raw != "" {
+		wait, err = time.ParseDuration(raw)
+		if err != nil || wait < 0 || wait > maxStatusWait {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid wait: %s (expected a duration up to %s)", raw, maxStatusWait)
+			return
+		}
+	}
+
+	task, err := h.callerTask(r, taskID)
+	if err != nil {
+		if errors.Is(err, tasks.ErrTaskNotFound) {
+			h.respondError(w, r, http.StatusNotFound, "Run not found")
+		} else {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to get run: %v", err)
+		}
+		return
+	}
+	// Wait through every status change until the run is done or time is up
+	if wait > 0 {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 15*time.Second))
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		for !task.Status.IsTerminal() {
+			from := task.Status
+			if task, err = h.taskManager.WaitTaskStatus(ctx, taskID, from); err != nil || task.Status == from {
+				break
+			}
+		}
+		cancel()
+		if err != nil {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to get run: %v", err)
+			return
+		}
+	}
+
+	run, err := h.simpleRun(r, task)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to load run result: %v", err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, run)
+}
+
+// HandleListSimpleRuns lists runs newest first as a bare array, the shape
+// polling triggers in Zapier and n8n expect. It takes the status, template,
+// reference_id, and limit filters of GET /tasks.
+func (h *APIHandler) HandleListSimpleRuns(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := tasks.ListFilter{
+		Status:      taskstypes.TaskStatus(q.Get("status")),
+		Template:    q.Get("template"),
+		ReferenceID: q.Get("reference_id"),
+		Tenant:      readableTenant(r),
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid limit: %s", raw)
+			return
+		}
+		filter.Limit = n
+	}
+
+	list, err := h.taskManager.ListTasks(filter)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, "Failed to list runs: %v", err)
+		return
+	}
+	runs := make([]SimpleRun, 0, len(list))
+	for _, task := range list {
+		run, err := h.simpleRun(r, task)
+		if err != nil {
+			h.respondError(w, r, http.StatusInternalServerError, "Failed to load run result: %v", err)
+			return
+		}
+		runs = append(runs, run)
+	}
+	h.respondJSON(w, http.StatusOK, runs)
+}
+
+// simpleRun flattens a task, loading its result if it was archived.
+func (h *APIHandler) simpleRun(r *http.Request, task *taskstypes.Task) (SimpleRun, error) {
+	run := SimpleRun{
+		ID:          task.ID.String(),
+		Status:      string(task.Status),
+		Done:        task.Status.IsTerminal(),
+		Template:    task.TemplateName,
+		ReferenceID: task.ReferenceID,
+		CreatedAt:   simpleTime(&task.CreatedAt),
+		StartedAt:   simpleTime(task.StartedAt),
+		FinishedAt:  simpleTime(task.CompletedAt),
+		PollURL:     simplePollURL(r, task.ID),
+	}
+	result := task.Result
+	if result == nil {
+		return run, nil
+	}
+	run.Success = result.Success
+	run.Error = result.Error
+	run.ErrorCode = string(result.ErrorCode)
+
+	data := result.Data
+	if result.Summary != nil && result.Summary.Archived {
+		raw, err := h.taskManager.ArchivedResultData(task.ID)
+		if err != nil {
+			return run, err
+		}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return run, err
+		}
+	}
+	var err error
+	run.Result, err = fieldText(data)
+	return run, err
+}
+
+func simpleTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// simplePollURL returns a run's absolute status URL as the client reached
+// this server, since low-code tools fetch URLs from responses as given.
+func simplePollURL(r *http.Request, id uuid.UUID) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + simpleRunsPath + id.String()
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimpleRun(t *testing.T) {
+	cfg := &config.Config{}
+	logger := logging.Discard()
+	manager := tasks.NewManager(cfg, mocks.NewMockBrowserExecutor(), logger)
+	_, err := manager.Templates().Put("lookup-lead", "", []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://crm.example/search?q={{vars.email}}"},
+	})
+	require.NoError(t, err)
+	handler := NewServer(cfg, manager, logger).httpServer.Handler
+
+	run := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://goscry.example/api/v1/simple/run", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+
+	rec := run("application/x-www-form-urlencoded", "url=https://shop.example/item&wait_for=.price&selector=.price&tag_source=zapier&reference_id=row-7")
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var resp SimpleRunResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "http://goscry.example/api/v1/simple/runs/"+resp.ID, resp.PollURL)
+	assert.Equal(t, resp.PollURL, rec.Header().Get("Location"))
+
+	task, err := manager.GetTaskStatus(uuid.MustParse(resp.ID))
+	require.NoError(t, err)
+	assert.Equal(t, []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://shop.example/item"},
+		{Type: taskstypes.ActionWaitVisible, Selector: ".price"},
+		{Type: taskstypes.ActionGetDOM, Selector: ".price"},
+	}, task.Actions)
+	assert.Equal(t, map[string]string{"source": "zapier"}, task.Tags)
+
+	// Every field is present even before the run finishes
+	rec = get(resp.PollURL + "?wait=5s")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &fields))
+	for _, name := range []string{"id", "status", "done", "success", "result", "error", "error_code", "template", "reference_id", "created_at", "started_at", "finished_at", "poll_url"} {
+		assert.Contains(t, fields, name)
+	}
+	var got SimpleRun
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.True(t, got.Done)
+	assert.True(t, got.Success)
+	assert.Equal(t, "Mock execution of task "+resp.ID, got.Result)
+	assert.Equal(t, "row-7", got.ReferenceID)
+
+	// Templates take variables flat or as an object, with numbers as text
+	rec = run("application/json", `{"template": "lookup-lead", "var_email": 42}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	task, err = manager.GetTaskStatus(uuid.MustParse(resp.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "https://crm.example/search?q=42", task.Actions[0].Value)
+	assert.Equal(t, "lookup-lead", task.TemplateName)
+	assert.Equal(t, http.StatusAccepted, run("application/json", `{"template": "lookup-lead", "variables": {"email": "a@b.example"}}`).Code)
+
+	for _, body := range []string{
+		`{}`,
+		`{"url": "https://a.example", "template": "lookup-lead"}`,
+		`{"template": "lookup-lead"}`,
+		`{"template": "lookup-lead", "var_email": "x", "selector": "p"}`,
+		`{"url": "https://a.example", "var_email": "x"}`,
+		`{"url": "https://a.example", "format": "pdf"}`,
+		`{"url": "https://a.example", "colour": "blue"}`,
+		`["https://a.example"]`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, run("application/json", body).Code, body)
+	}
+	assert.Equal(t, http.StatusNotFound, run("application/json", `{"template": "missing"}`).Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/simple/runs/"+uuid.NewString()).Code)
+
+	// The list is a bare array, newest first, for polling triggers
+	rec = get("/api/v1/simple/runs?template=lookup-lead&limit=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var runs []SimpleRun
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &runs))
+	require.Len(t, runs, 1)
+	assert.Equal(t, "lookup-lead", runs[0].Template)
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// smsPath is where SMS providers forward inbound messages.
+const smsPath = "/api/v1/2fa/sms"
+
+const (
+	headerTwilioSignature = "X-Twilio-Signature"
+	defaultSMSCodePattern = `\b(\d{4,8})\b`
+)
+
+// smsWebhook is the checked twoFactor.sms config.
+type smsWebhook struct {
+	config.SMSWebhookConfig
+	pattern *regexp.Regexp
+}
+
+// newSMSWebhook checks twoFactor.sms. It returns nil, and no error, when
+// neither credential is set.
+func newSMSWebhook(cfg config.SMSWebhookConfig) (*smsWebhook, error) {
+	if cfg.TwilioAuthToken == "" && cfg.Token == "" {
+		return nil, nil
+	}
+	if cfg.CodePattern == "" {
+		cfg.CodePattern = defaultSMSCodePattern
+	}
+	pattern, err := regexp.Compile(cfg.CodePattern)
+	if err != nil {
+		return nil, fmt.Errorf("twoFactor.sms.codePattern: %w", err)
+	}
+	return &smsWebhook{SMSWebhookConfig: cfg, pattern: pattern}, nil
+}
+
+// smsMessage is an inbound SMS, as Twilio's form fields or a JSON body.
+type smsMessage struct {
+	To   string `json:"to"`
+	From string `json:"from"`
+	Body string `json:"body"`
+}
+
+// SMSCodeResponse names the task an SMS code was given to.
+type SMSCodeResponse struct {
+	TaskID string `json:"task_id"`
+}
+
+// authorized reports whether a request carries a valid Twilio signature or
+// the shared token.
+func (s *smsWebhook) authorized(r *http.Request, form url.Values) bool {
+	if s.Token != "" {
+		token := r.Header.Get(HeaderHookToken)
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1 {
+			return true
+		}
+	}
+	if s.TwilioAuthToken != "" && form != nil {
+		signature := r.Header.Get(headerTwilioSignature)
+		return signature != "" && hmac.Equal([]byte(TwilioSignature(s.TwilioAuthToken, s.requestURL(r), form)), []byte(signature))
+	}
+	return false
+}
+
+// requestURL is the URL Twilio signed: publicURL with the request's query,
+// or the URL as the client reached this server.
+func (s *smsWebhook) requestURL(r *http.Request) string {
+	if s.PublicURL != "" {
+		if r.URL.RawQuery != "" && !strings.Contains(s.PublicURL, "?") {
+			return s.PublicURL + "?" + r.URL.RawQuery
+		}
+		return s.PublicURL
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// code returns the code in a message: the pattern's first group if it has
+// one, or the whole match.
+func (s *smsWebhook) code(body string) string {
+	m := s.pattern.FindStringSubmatch(body)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return m[1]
+	}
+	return m[0]
+}
+
+// TwilioSignature returns the X-Twilio-Signature of a form POST to rawURL:
+// the base64 HMAC-SHA1, keyed with the account's auth token, of the URL
+// followed by each parameter's name and value in name order.
+func TwilioSignature(authToken, rawURL string, form url.Values) string {
+	names := make([]string, 0, len(form))
+	for name := range form {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(rawURL)
+	for _, name := range names {
+		values := append([]string(nil), form[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			b.WriteString(name)
+			b.WriteString(value)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// HandleSMSCode takes an inbound SMS forwarded by a provider and gives the
+// code in it to the task waiting for a code sent to that phone number.
+// Twilio posts its form fields (To, From, Body) and gets empty TwiML back, so
+// no reply is texted; other providers post {"to", "from", "body"} as JSON.
+func (h *APIHandler) HandleSMSCode(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondError(w, r, http.StatusRequestEntityTooLarge, "Payload is larger than %d bytes", maxHookBody)
+			return
+		}
+		h.respondError(w, r, http.StatusBadRequest, "Failed to read payload: %v", err)
+		return
+	}
+
+	var msg smsMessage
+	var form url.Values
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		if form, err = url.ParseQuery(string(body)); err != nil {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid form: %v", err)
+			return
+		}
+		msg = smsMessage{To: form.Get("To"), From: form.Get("From"), Body: form.Get("Body")}
+	}
+	if !h.sms.authorized(r, form) {
+		h.respondError(w, r, http.StatusUnauthorized, "Missing or invalid token or Twilio signature")
+		return
+	}
+	if form == nil {
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&msg); err != nil {
+			h.respondError(w, r, http.StatusBadRequest, "Invalid JSON: %v", err)
+			return
+		}
+	}
+	if msg.To == "" {
+		h.respondError(w, r, http.StatusBadRequest, "The message has no recipient number")
+		return
+	}
+
+	code := h.sms.code(msg.Body)
+	if code == "" {
+		h.respondError(w, r, http.StatusUnprocessableEntity, "No code found in the message from %s", msg.From)
+		return
+	}
+	taskID, err := h.taskManager.RouteTFACode(taskstypes.TFAProviderSMS, msg.To, code)
+	switch {
+	case errors.Is(err, tasks.ErrNoTaskWaiting):
+		h.respondError(w, r, http.StatusNotFound, "No task is waiting for a code sent to %s", msg.To)
+		return
+	case err != nil:
+		h.respondError(w, r, http.StatusConflict, "Failed to provide the code to task %s: %v", taskID, err)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "SMS code routed to task", "task_id", taskID)
+	if form != nil {
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Response></Response>`))
+		return
+	}
+	h.respondJSON(w, http.StatusOK, SMSCodeResponse{TaskID: taskID.String()})
+}
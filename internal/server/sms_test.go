@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwilioSignature(t *testing.T) {
+	// The example from Twilio's webhook security documentation
+	form := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	assert.Equal(t, "0/KCTR6DLpKmkAf8muzZqo1nDgQ=", TwilioSignature("12345", "https://mycompany.com/myapp.php?foo=1&bar=2", form))
+}
+
+func TestHandleSMSCode(t *testing.T) {
+	cfg := &config.Config{TwoFactor: config.TwoFactorConfig{SMS: config.SMSWebhookConfig{TwilioAuthToken: "twilio-token", Token: "s3cret"}}}
+	logger := logging.Discard()
+	executor := mocks.NewMockBrowserExecutor()
+	codes := make(chan string, 1)
+	executor.SetExecuteHook(func(ctx context.Context, task *taskstypes.Task) {
+		task.UpdateStatus(taskstypes.StatusWaitingFor2FA)
+		code, err := task.WaitForTFACode(ctx)
+		if err == nil {
+			codes <- code
+		}
+	})
+	manager := tasks.NewManager(cfg, executor, logger)
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+	handler := NewServer(cfg, manager, logger).httpServer.Handler
+
+	task := newTask(httptest.NewRequest(http.MethodPost, "/api/v1/tasks", nil), SubmitTaskRequest{
+		Actions:       []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://bank.example/login"}},
+		TwoFactorAuth: taskstypes.TwoFactorAuthInfo{Expected: true, Provider: taskstypes.TFAProviderSMS, PhoneNumber: "+1 (555) 010-0000"},
+	})
+	require.NoError(t, manager.SubmitTask(task))
+	require.Eventually(t, func() bool { return task.CurrentStatus() == taskstypes.StatusWaitingFor2FA }, 5*time.Second, 10*time.Millisecond)
+
+	post := func(contentType, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://goscry.example"+smsPath, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		for name := range header {
+			req.Header.Set(name, header.Get(name))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	withToken := http.Header{HeaderHookToken: {"s3cret"}}
+
+	assert.Equal(t, http.StatusUnauthorized, post("application/json", `{"to": "+15550100000", "body": "Code 482719"}`, nil).Code)
+	assert.Equal(t, http.StatusNotFound, post("application/json", `{"to": "+15550109999", "body": "Code 482719"}`, withToken).Code,
+		"codes are routed by the number they were sent to")
+	assert.Equal(t, http.StatusUnprocessableEntity, post("application/json", `{"to": "+15550100000", "body": "Reply STOP to opt out"}`, withToken).Code)
+
+	form := url.Values{"To": {"+15550100000"}, "From": {"+15550001111"}, "Body": {"Your Bank code is 482719. Do not share it."}, "MessageSid": {"SM123"}}
+	forged := http.Header{headerTwilioSignature: {TwilioSignature("wrong-token", "http://goscry.example"+smsPath, form)}}
+	assert.Equal(t, http.StatusUnauthorized, post("application/x-www-form-urlencoded", form.Encode(), forged).Code)
+
+	signed := http.Header{headerTwilioSignature: {TwilioSignature("twilio-token", "http://goscry.example"+smsPath, form)}}
+	rec := post("application/x-www-form-urlencoded", form.Encode(), signed)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/xml", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "<Response></Response>")
+
+	select {
+	case code := <-codes:
+		assert.Equal(t, "482719", code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the code did not reach the task")
+	}
+}
+
+func TestHandleSMSCode_TokenNotLogged(t *testing.T) {
+	cfg := &config.Config{TwoFactor: config.TwoFactorConfig{SMS: config.SMSWebhookConfig{Token: "s3cret"}}}
+	var buf bytes.Buffer
+	logger := logging.New(config.LogConfig{Format: "json"}, &buf)
+	manager := tasks.NewManager(cfg, mocks.NewMockBrowserExecutor(), logger)
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+	handler := NewServer(cfg, manager, logger).httpServer.Handler
+
+	req := httptest.NewRequest(http.MethodPost, smsPath+"?token=s3cret", strings.NewReader(`{"to": "+15550100000", "body": "Code 482719"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusNotFound, rec.Code, "the query token is accepted")
+	assert.Contains(t, buf.String(), "token=REDACTED")
+	assert.NotContains(t, buf.String(), "s3cret")
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+)
+
+type PutTemplateRequest struct {
+	Description string              `json:"description,omitempty"`
+	Actions     []taskstypes.Action `json:"actions"`
+	CanaryRuns  int                 `json:"canary_runs,omitempty"` // Compare with the version in use over this many runs of each before promoting
+}
+
+type RollbackTemplateRequest struct {
+	Version int `json:"version"`
+}
+
+// RunTemplateRequest submits a task from a template. Version 0 runs the latest version.
+type RunTemplateRequest struct {
+	Version              int                           `json:"version,omitempty"`
+	Credentials          *taskstypes.Credentials       `json:"credentials,omitempty"`
+	EncryptedCredentials *taskstypes.SealedCredentials `json:"encrypted_credentials,omitempty"`
+	CredentialsRef       string                        `json:"credentials_ref,omitempty"`
+	TwoFactorAuth        taskstypes.TwoFactorAuthInfo  `json:"two_factor_auth"`
+	CallbackURL          string                        `json:"callback_url,omitempty"`
+	CallbackFields       []string                      `json:"callback_fields,omitempty"`
+	TFAWebhook           string                        `json:"tfa_webhook,omitempty"`
+	Session              string                        `json:"session,omitempty"`
+	Options              taskstypes.TaskOptions        `json:"options"`
+	Tags                 map[string]string             `json:"tags,omitempty"`
+	ReferenceID          string                        `json:"reference_id,omitempty"`
+	Priority             taskstypes.TaskPriority       `json:"priority,omitempty"`
+
+	Variables map[string]string `json:"variables,omitempty"` // Fill {{vars.<name>}} in the template's actions
+}
+
+// HandleListTemplates returns the latest version of every template.
+func (h *APIHandler) HandleListTemplates(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.taskManager.Templates().List())
+}
+
+// HandlePutTemplate stores a new immutable version of a template, or starts
+// a canary of it with canary_runs.
+func (h *APIHandler) HandlePutTemplate(w http.ResponseWriter, r *http.Request) {
+	var req PutTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	var tmpl *tasks.Template
+	var err error
+	switch {
+	case req.CanaryRuns < 0:
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: canary_runs cannot be negative")
+		return
+	case req.CanaryRuns > 0:
+		tmpl, err = h.taskManager.Templates().PutCanary(chi.URLParam(r, "name"), req.Description, req.Actions, req.CanaryRuns)
+	default:
+		tmpl, err = h.taskManager.Templates().Put(chi.URLParam(r, "name"), req.Description, req.Actions)
+	}
+	if errors.Is(err, tasks.ErrCanaryRunning) {
+		h.respondError(w, r, http.StatusConflict, "%v", err)
+		return
+	}
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Failed to store template: %v", err)
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, tmpl)
+}
+
+// HandleGetTemplate returns the latest template, or a pinned one via ?version=N.
+func (h *APIHandler) HandleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	version, ok := h.versionParam(w, r, "version")
+	if !ok {
+		return
+	}
+	tmpl, err := h.taskManager.Templates().Get(chi.URLParam(r, "name"), version)
+	if err != nil {
+		h.respondTemplateError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, tmpl)
+}
+
+// HandleListTemplateVersions returns the full version history of a template.
+func (h *APIHandler) HandleListTemplateVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.taskManager.Templates().Versions(chi.URLParam(r, "name"))
+	if err != nil {
+		h.respondTemplateError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, versions)
+}
+
+// HandleDiffTemplate compares two versions (?from=N&to=M, to defaults to latest).
+func (h *APIHandler) HandleDiffTemplate(w http.ResponseWriter, r *http.Request) {
+	from, ok := h.versionParam(w, r, "from")
+	if !ok {
+		return
+	}
+	to, ok := h.versionParam(w, r, "to")
+	if !ok {
+		return
+	}
+	if from == 0 {
+		h.respondError(w, r, http.StatusBadRequest, "from version is required")
+		return
+	}
+
+	diff, err := h.taskManager.Templates().Diff(chi.URLParam(r, "name"), from, to)
+	if err != nil {
+		h.respondTemplateError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, diff)
+}
+
+// HandleGetTemplateFlakiness reports which actions a template's runs fail
+// on, with hints for selectors that are likely broken or flaky.
+func (h *APIHandler) HandleGetTemplateFlakiness(w http.ResponseWriter, r *http.Request) {
+	report, err := h.taskManager.TemplateFlakiness(chi.URLParam(r, "name"))
+	if err != nil {
+		h.respondTemplateError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, report)
+}
+
+// HandleGetTemplateCanary returns the template's running or last canary.
+func (h *APIHandler) HandleGetTemplateCanary(w http.ResponseWriter, r *http.Request) {
+	canary, err := h.taskManager.Templates().Canary(chi.URLParam(r, "name"))
+	if err != nil {
+		h.respondTemplateError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, canary)
+}
+
+// HandlePromoteTemplateCanary puts a canary's candidate in use without
+// waiting for its runs.
+func (h *APIHandler) HandlePromoteTemplateCanary(w http.ResponseWriter, r *http.Request) {
+	h.endCanary(w, r, true)
+}
+
+// HandleAbortTemplateCanary rejects a canary's candidate, restoring the
+// baseline as a new version.
+func (h *APIHandler) HandleAbortTemplateCanary(w http.ResponseWriter, r *http.Request) {
+	h.endCanary(w, r, false)
+}
+
+func (h *APIHandler) endCanary(w http.ResponseWriter, r *http.Request, promote bool) {
+	canary, err := h.taskManager.Templates().EndCanary(chi.URLParam(r, "name"), promote)
+	if err != nil {
+		h.respondTemplateError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, canary)
+}
+
+// HandleRollbackTemplate restores an earlier version as the new latest version.
+func (h *APIHandler) HandleRollbackTemplate(w http.ResponseWriter, r *http.Request) {
+	var req RollbackTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	if req.Version <= 0 {
+		h.respondError(w, r, http.StatusBadRequest, "version to roll back to is required")
+		return
+	}
+
+	tmpl, err := h.taskManager.Templates().Rollback(chi.URLParam(r, "name"), req.Version)
+	if err != nil {
+		h.respondTemplateError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, tmpl)
+}
+
+// HandleRunTemplate submits a new task using a template's actions.
+func (h *APIHandler) HandleRunTemplate(w http.ResponseWriter, r *http.Request) {
+	var req RunTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request body: %v", err)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := checkCredentialSources(req.Credentials, req.EncryptedCredentials, req.CredentialsRef); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	if err := checkCredentialsRef(r, req.CredentialsRef); err != nil {
+		h.respondError(w, r, http.StatusForbidden, "%v", err)
+		return
+	}
+	if err := validateLabels(req.Tags, req.ReferenceID); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+	if err := req.Priority.Validate(); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+
+	tmpl, canary, err := h.taskManager.Templates().ForRun(chi.URLParam(r, "name"), req.Version)
+	if err != nil {
+		h.respondTemplateError(w, r, err)
+		return
+	}
+
+	actions, err := tasks.ApplyVariables(tmpl.Actions, req.Variables)
+	if err != nil {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid request: %v", err)
+		return
+	}
+
+	task := newTask(r, SubmitTaskRequest{
+		Actions:              actions,
+		Credentials:          req.Credentials,
+		EncryptedCredentials: req.EncryptedCredentials,
+		CredentialsRef:       req.CredentialsRef,
+		TwoFactorAuth:        req.TwoFactorAuth,
+		CallbackURL:          req.CallbackURL,
+		CallbackFields:       req.CallbackFields,
+		TFAWebhook:           req.TFAWebhook,
+		Session:              req.Session,
+		Options:              req.Options,
+		Tags:                 req.Tags,
+		ReferenceID:          req.ReferenceID,
+		Priority:             req.Priority,
+	})
+	task.TemplateName = tmpl.Name
+	task.TemplateVersion = tmpl.Version
+	task.Canary = canary
+
+	if err := checkTaskResources(r, h.taskManager, task); err != nil {
+		h.respondSubmitError(w, r, err)
+		return
+	}
+	if err := h.taskManager.SubmitTask(task); err != nil {
+		h.respondSubmitError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusAccepted, SubmitTaskResponse{TaskID: task.ID.String(), Warnings: task.Warnings})
+}
+
+// versionParam parses an optional positive integer query parameter.
+func (h *APIHandler) versionParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(raw)
+	if err != nil || version <= 0 {
+		h.respondError(w, r, http.StatusBadRequest, "Invalid %s version: %s", name, raw)
+		return 0, false
+	}
+	return version, true
+}
+
+func (h *APIHandler) respondTemplateError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, tasks.ErrTemplateNotFound), errors.Is(err, tasks.ErrNoCanary):
+		h.respondError(w, r, http.StatusNotFound, "%v", err)
+		return
+	case errors.Is(err, tasks.ErrCanaryRunning):
+		h.respondError(w, r, http.StatusConflict, "%v", err)
+		return
+	}
+	h.respondError(w, r, http.StatusInternalServerError, "%v", err)
+}
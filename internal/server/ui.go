@@ -0,0 +1,35 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiPath is where the web UI is served when server.ui.enabled is set.
+const uiPath = "/ui/"
+
+//go:embed ui
+var uiFiles embed.FS
+
+// uiContentSecurityPolicy keeps the UI to its own scripts and the API, and
+// lets it show screenshots it fetched as blobs.
+const uiContentSecurityPolicy = "default-src 'self'; img-src 'self' blob:; object-src 'none'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// UIHandler serves the embedded web UI. Its pages hold no task data: they
+// call the API with the key or token the user signs in with, so the API's
+// authentication and roles apply to everything the UI shows or does.
+func UIHandler() http.Handler {
+	files, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err) // The directory is embedded at build time
+	}
+	fileServer := http.StripPrefix(uiPath, http.FileServer(http.FS(files)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", uiContentSecurityPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Cache-Control", "no-cache") // Pick up a new UI after an upgrade
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,589 @@
+// GoScry web UI: builds action lists from /api/v1/schema/actions, submits
+// them, follows running tasks, and browses task history. Everything goes
+// through the public API with the key the user signs in with.
+(function () {
+  'use strict';
+
+  const API = '/api/v1';
+  const CREDENTIAL_KEY = 'goscry.credential';
+  const COMMON_FIELDS = ['timeout', 'if', 'else', 'repeat'];
+  const TERMINAL = ['completed', 'failed', 'cancelled'];
+  const HISTORY_PAGE = 25;
+
+  const $ = (id) => document.getElementById(id);
+  const state = {
+    schema: null,
+    actions: [],
+    jsonMode: false,
+    taskID: null,
+    pollTimer: null,
+    artifactURLs: {},
+    historyOffset: 0,
+  };
+
+  // --- API ---
+
+  class APIError extends Error {
+    constructor(status, message) {
+      super(message);
+      this.status = status;
+    }
+  }
+
+  async function api(method, path, body) {
+    const headers = { Authorization: 'Bearer ' + sessionStorage.getItem(CREDENTIAL_KEY) };
+    const init = { method, headers };
+    if (body !== undefined) {
+      headers['Content-Type'] = 'application/json';
+      init.body = JSON.stringify(body);
+    }
+    const resp = await fetch(API + path, init);
+    if (resp.status === 401 || resp.status === 403) {
+      if (resp.status === 401) signOut();
+      throw new APIError(resp.status, (await resp.text()).trim());
+    }
+    if (!resp.ok) {
+      const text = await resp.text();
+      let message = text;
+      try { message = JSON.parse(text).error || text; } catch (e) { /* plain text error */ }
+      throw new APIError(resp.status, message.trim());
+    }
+    return resp;
+  }
+
+  async function apiJSON(method, path, body) {
+    return (await api(method, path, body)).json();
+  }
+
+  function notify(message, info) {
+    const notice = $('notice');
+    notice.textContent = message;
+    notice.classList.toggle('info', !!info);
+    notice.hidden = !message;
+  }
+
+  function failed(err) {
+    notify(err.message || String(err));
+  }
+
+  // --- Schema checks ---
+
+  function resolve(schema) {
+    while (schema && schema.$ref) {
+      schema = schema.$ref === '#' ? state.schema : state.schema.$defs[schema.$ref.replace('#/$defs/', '')];
+    }
+    return schema;
+  }
+
+  function typeOf(value) {
+    if (Array.isArray(value)) return 'array';
+    if (value === null) return 'null';
+    if (typeof value === 'number') return Number.isInteger(value) ? 'integer' : 'number';
+    return typeof value;
+  }
+
+  function isURL(value) {
+    try { return !!new URL(value).protocol; } catch (e) { return false; }
+  }
+
+  // check returns the problems with value against schema, supporting the
+  // keywords the action schema uses.
+  function check(schema, value, path) {
+    schema = resolve(schema);
+    if (!schema) return [];
+    if (schema === state.schema && value && state.schema.$defs[value.type]) {
+      // Check against the action's own type for clearer messages than oneOf's
+      return check(state.schema.$defs[value.type], value, path);
+    }
+    const problems = [];
+    const kind = typeOf(value);
+    if (schema.type && schema.type !== kind && !(schema.type === 'number' && kind === 'integer')) {
+      return [path + ' should be a ' + schema.type];
+    }
+    if ('const' in schema && value !== schema.const) problems.push(path + ' should be ' + schema.const);
+    if (schema.enum && !schema.enum.includes(value)) problems.push(path + ' should be one of ' + schema.enum.join(', '));
+    if (kind === 'string') {
+      if (schema.minLength && value.length < schema.minLength) problems.push(path + ' should not be empty');
+      if (schema.pattern && !new RegExp(schema.pattern, 'u').test(value)) problems.push(path + ' has an invalid format');
+      if (schema.format === 'uri' && !isURL(value)) problems.push(path + ' should be a URL');
+    }
+    if (kind === 'integer' || kind === 'number') {
+      if ('minimum' in schema && value < schema.minimum) problems.push(path + ' should be at least ' + schema.minimum);
+      if ('exclusiveMinimum' in schema && value <= schema.exclusiveMinimum) problems.push(path + ' should be more than ' + schema.exclusiveMinimum);
+    }
+    if (kind === 'object') {
+      for (const name of schema.required || []) {
+        if (!(name in value)) problems.push(path + '.' + name + ' is required');
+      }
+      if (schema.minProperties && Object.keys(value).length < schema.minProperties) problems.push(path + ' needs at least ' + schema.minProperties + ' entry');
+      for (const [name, field] of Object.entries(value)) {
+        const property = (schema.properties || {})[name] || (typeof schema.additionalProperties === 'object' ? schema.additionalProperties : null);
+        if (property) problems.push(...check(property, field, path + '.' + name));
+      }
+    }
+    if (kind === 'array' && schema.items) {
+      value.forEach((item, i) => problems.push(...check(schema.items, item, path + '[' + i + ']')));
+    }
+    if (schema.anyOf && !schema.anyOf.some((s) => check(s, value, path).length === 0)) {
+      problems.push(path + ' needs one of: ' + schema.anyOf.map(describe).join('; '));
+    }
+    if (schema.oneOf && schema.oneOf.filter((s) => check(s, value, path).length === 0).length !== 1) {
+      problems.push(path + ' does not match any allowed form');
+    }
+    if (schema.not && check(schema.not, value, path).length === 0) {
+      problems.push(path + ' cannot have ' + describe(schema.not));
+    }
+    if (schema.if && schema.then && check(schema.if, value, path).length === 0) {
+      problems.push(...check(schema.then, value, path));
+    }
+    return problems;
+  }
+
+  function describe(schema) {
+    schema = resolve(schema);
+    if (schema.required) return schema.required.join(' and ');
+    if (schema.format) return 'a ' + schema.format;
+    if (schema.pattern) return 'a value matching ' + schema.pattern;
+    return schema.type || 'a valid value';
+  }
+
+  // --- Builder ---
+
+  function actionTypes() {
+    return state.schema.oneOf.map((ref) => ref.$ref.replace('#/$defs/', ''));
+  }
+
+  function newAction(type) {
+    return JSON.parse(JSON.stringify(state.schema.$defs[type].examples[0]));
+  }
+
+  function fieldNames(def) {
+    const own = Object.keys(def.properties).filter((name) => name !== 'type' && !COMMON_FIELDS.includes(name));
+    return own.concat(COMMON_FIELDS);
+  }
+
+  function isText(property) {
+    return property.type === 'string' && !property.enum;
+  }
+
+  function renderField(action, name, def) {
+    const property = resolve(def.properties[name]);
+    const label = document.createElement('label');
+    const caption = document.createElement('span');
+    caption.textContent = name;
+    if ((def.required || []).includes(name)) caption.className = 'required';
+    label.appendChild(caption);
+
+    let input;
+    if (property.enum) {
+      input = document.createElement('select');
+      input.appendChild(new Option(property.default ? '(' + property.default + ')' : '', ''));
+      property.enum.forEach((v) => input.appendChild(new Option(v, v)));
+      input.value = action[name] || '';
+      input.addEventListener('change', () => setField(action, name, input.value));
+    } else if (isText(property) && !(action.type === 'run_script' && name === 'value')) {
+      input = document.createElement('input');
+      input.type = 'text';
+      input.value = action[name] || '';
+      input.addEventListener('input', () => setField(action, name, input.value));
+    } else {
+      // Scripts as text; objects and lists as JSON
+      input = document.createElement('textarea');
+      input.rows = 3;
+      input.spellcheck = false;
+      const raw = isText(property);
+      input.value = name in action ? (raw ? action[name] : JSON.stringify(action[name], null, 2)) : '';
+      input.addEventListener('input', () => {
+        if (raw) return setField(action, name, input.value);
+        try {
+          setField(action, name, input.value.trim() ? JSON.parse(input.value) : '');
+          input.setCustomValidity('');
+        } catch (e) {
+          input.setCustomValidity('Invalid JSON');
+        }
+        input.reportValidity();
+      });
+    }
+    label.appendChild(input);
+
+    const fragment = document.createDocumentFragment();
+    fragment.appendChild(label);
+    const description = def.properties[name].description || property.description;
+    if (description) {
+      const hint = document.createElement('span');
+      hint.className = 'hint';
+      hint.textContent = description;
+      fragment.appendChild(hint);
+    }
+    return fragment;
+  }
+
+  function setField(action, name, value) {
+    if (value === '') {
+      delete action[name];
+    } else {
+      action[name] = value;
+    }
+    showProblems([]);
+  }
+
+  function renderActions() {
+    const list = $('actions');
+    list.replaceChildren();
+    state.actions.forEach((action, index) => {
+      const item = $('action-template').content.firstElementChild.cloneNode(true);
+      const def = state.schema.$defs[action.type];
+      const select = item.querySelector('.action-type');
+      actionTypes().forEach((type) => select.appendChild(new Option(type, type)));
+      select.value = action.type;
+      select.addEventListener('change', () => {
+        state.actions[index] = newAction(select.value);
+        renderActions();
+      });
+      item.querySelector('.action-description').textContent = def ? def.description : 'Unknown action type';
+      item.querySelector('.move-up').addEventListener('click', () => moveAction(index, -1));
+      item.querySelector('.move-down').addEventListener('click', () => moveAction(index, 1));
+      item.querySelector('.remove').addEventListener('click', () => {
+        state.actions.splice(index, 1);
+        renderActions();
+      });
+      if (def) {
+        const fields = item.querySelector('.action-fields');
+        fieldNames(def).forEach((name) => fields.appendChild(renderField(action, name, def)));
+      }
+      list.appendChild(item);
+    });
+  }
+
+  function moveAction(index, by) {
+    const to = index + by;
+    if (to < 0 || to >= state.actions.length) return;
+    const [action] = state.actions.splice(index, 1);
+    state.actions.splice(to, 0, action);
+    renderActions();
+  }
+
+  function toggleJSON() {
+    const editor = $('actions-json');
+    if (state.jsonMode) {
+      try {
+        const actions = JSON.parse(editor.value);
+        if (!Array.isArray(actions)) throw new Error('expected a list of actions');
+        state.actions = actions;
+      } catch (e) {
+        return showProblems(['Actions JSON: ' + e.message]);
+      }
+    } else {
+      editor.value = JSON.stringify(state.actions, null, 2);
+    }
+    state.jsonMode = !state.jsonMode;
+    editor.hidden = !state.jsonMode;
+    $('actions').hidden = state.jsonMode;
+    $('add-action').hidden = state.jsonMode;
+    $('toggle-json').textContent = state.jsonMode ? 'Edit as form' : 'Edit as JSON';
+    renderActions();
+    showProblems([]);
+  }
+
+  function showProblems(problems) {
+    const list = $('problems');
+    list.replaceChildren(...problems.map((p) => {
+      const li = document.createElement('li');
+      li.textContent = p;
+      return li;
+    }));
+  }
+
+  async function submitTask() {
+    let actions = state.actions;
+    let request;
+    try {
+      if (state.jsonMode) actions = JSON.parse($('actions-json').value);
+      request = JSON.parse($('request-extra').value || '{}');
+    } catch (e) {
+      return showProblems(['Invalid JSON: ' + e.message]);
+    }
+    if (!Array.isArray(actions) || actions.length === 0) return showProblems(['Add at least one action']);
+    const problems = [];
+    actions.forEach((action, i) => problems.push(...check(state.schema, action, 'actions[' + i + ']')));
+    if (problems.length) return showProblems(problems);
+
+    request.actions = actions;
+    try {
+      // Warnings are kept on the task, which shows them
+      const resp = await apiJSON('POST', '/tasks', request);
+      location.hash = '#/tasks/' + resp.task_id;
+    } catch (err) {
+      showProblems([err.message]);
+    }
+  }
+
+  // --- Task view ---
+
+  function formatTime(value) {
+    return value ? new Date(value).toLocaleString() : '';
+  }
+
+  function summarize(action) {
+    const parts = [action.type];
+    if (action.selector) parts.push(action.selector);
+    if (action.value && action.type !== 'run_script') parts.push(action.value);
+    return parts.join(' ');
+  }
+
+  function renderTask(task) {
+    $('task-id').textContent = task.id;
+    const status = $('task-status');
+    status.textContent = task.status + (task.result && task.result.error_code ? ' (' + task.result.error_code + ')' : '');
+    status.className = 'status-' + task.status;
+    $('task-created').textContent = formatTime(task.created_at);
+    const end = task.completed_at ? new Date(task.completed_at) : new Date();
+    $('task-duration').textContent = task.started_at ? ((end - new Date(task.started_at)) / 1000).toFixed(1) + 's' : '';
+
+    $('task-warnings').replaceChildren(...(task.warnings || []).map((w) => {
+      const li = document.createElement('li');
+      li.textContent = w;
+      return li;
+    }));
+    const terminal = TERMINAL.includes(task.status);
+    $('tfa-form').hidden = task.status !== 'waiting_for_2fa';
+    $('cancel-task').hidden = terminal;
+
+    const failedIndex = task.result && task.result.failed_action ? task.result.failed_action.index : -1;
+    $('task-actions').replaceChildren(...task.actions.map((action, i) => {
+      const li = document.createElement('li');
+      li.textContent = summarize(action);
+      if (i === failedIndex) {
+        li.className = 'failed';
+      } else if (task.status === 'completed' || i < task.current_action) {
+        li.className = 'done';
+      } else if (i === task.current_action && !terminal && task.status !== 'pending') {
+        li.className = 'current';
+      }
+      return li;
+    }));
+
+    const result = task.result;
+    $('task-result').hidden = !result;
+    if (result) {
+      $('task-message').textContent = [result.message, result.error].filter(Boolean).join(': ');
+      const output = {};
+      if (result.data !== undefined && result.data !== null) output.data = result.data;
+      if (result.custom_data) output.custom_data = result.custom_data;
+      $('task-output').textContent = JSON.stringify(output, null, 2);
+    }
+  }
+
+  async function renderArtifacts(taskID) {
+    const objects = await apiJSON('GET', '/tasks/' + taskID + '/artifacts');
+    if (taskID !== state.taskID) return;
+    $('no-artifacts').hidden = objects.length > 0;
+    const list = $('artifacts');
+    for (const object of objects) {
+      if (list.querySelector('[data-name="' + CSS.escape(object.name) + '"]')) continue;
+      const li = document.createElement('li');
+      li.dataset.name = object.name;
+      const figure = document.createElement('figure');
+      const caption = document.createElement('figcaption');
+      const link = document.createElement('a');
+      link.href = '#';
+      link.textContent = object.name + ' (' + Math.ceil(object.size / 1024) + ' KB)';
+      link.addEventListener('click', (e) => {
+        e.preventDefault();
+        downloadArtifact(taskID, object.name);
+      });
+      caption.appendChild(link);
+      if (object.content_type.startsWith('image/')) {
+        const img = document.createElement('img');
+        img.alt = object.name;
+        artifactURL(taskID, object.name).then((url) => { img.src = url; }, failed);
+        figure.appendChild(img);
+      }
+      figure.appendChild(caption);
+      li.appendChild(figure);
+      list.appendChild(li);
+    }
+  }
+
+  // artifactURL fetches an artifact with the API credential, which an <img>
+  // or <a> cannot send, and returns a blob URL for it.
+  async function artifactURL(taskID, name) {
+    const key = taskID + '/' + name;
+    if (!state.artifactURLs[key]) {
+      const resp = await api('GET', '/tasks/' + taskID + '/artifacts/' + encodeURIComponent(name));
+      state.artifactURLs[key] = URL.createObjectURL(await resp.blob());
+    }
+    return state.artifactURLs[key];
+  }
+
+  async function downloadArtifact(taskID, name) {
+    try {
+      const link = document.createElement('a');
+      link.href = await artifactURL(taskID, name);
+      link.download = name;
+      link.click();
+    } catch (err) {
+      failed(err);
+    }
+  }
+
+  function stopPolling() {
+    clearTimeout(state.pollTimer);
+    state.pollTimer = null;
+  }
+
+  async function showTask(taskID) {
+    stopPolling();
+    if (taskID !== state.taskID) {
+      Object.values(state.artifactURLs).forEach(URL.revokeObjectURL);
+      state.artifactURLs = {};
+      $('artifacts').replaceChildren();
+      $('no-artifacts').hidden = false;
+    }
+    state.taskID = taskID;
+    let status = '';
+    const poll = async () => {
+      try {
+        // Returns at once when the status changes, otherwise after two
+        // seconds with the latest progress
+        const query = status && !TERMINAL.includes(status) ? '&wait=2s&status=' + status : '';
+        const task = await apiJSON('GET', '/tasks/' + taskID + '?full=true' + query);
+        if (taskID !== state.taskID) return;
+        renderTask(task);
+        await renderArtifacts(taskID);
+        status = task.status;
+        if (!TERMINAL.includes(status) && taskID === state.taskID) {
+          state.pollTimer = setTimeout(poll, 250);
+        }
+      } catch (err) {
+        failed(err);
+      }
+    };
+    await poll();
+  }
+
+  async function sendTFACode(e) {
+    e.preventDefault();
+    try {
+      await api('POST', '/tasks/' + state.taskID + '/2fa', { code: $('tfa-code').value });
+      $('tfa-code').value = '';
+      notify('Code sent', true);
+    } catch (err) {
+      failed(err);
+    }
+  }
+
+  async function cancelTask() {
+    try {
+      await api('POST', '/tasks/' + state.taskID + '/cancel');
+      notify('Cancellation requested', true);
+    } catch (err) {
+      failed(err);
+    }
+  }
+
+  // --- History ---
+
+  async function showHistory() {
+    const params = new URLSearchParams({
+      limit: HISTORY_PAGE,
+      offset: state.historyOffset,
+      fields: 'id,status,actions,created_at,template_name',
+    });
+    if ($('history-status').value) params.set('status', $('history-status').value);
+    try {
+      const list = await apiJSON('GET', '/tasks?' + params);
+      $('history-rows').replaceChildren(...list.map((task) => {
+        const row = document.createElement('tr');
+        const cells = [task.id.slice(0, 8), task.status, (task.actions || []).length, formatTime(task.created_at), task.template_name || ''];
+        cells.forEach((text, i) => {
+          const cell = document.createElement('td');
+          cell.textContent = text;
+          if (i === 1) cell.className = 'status-' + task.status;
+          row.appendChild(cell);
+        });
+        row.addEventListener('click', () => { location.hash = '#/tasks/' + task.id; });
+        return row;
+      }));
+      $('history-prev').disabled = state.historyOffset === 0;
+      $('history-next').disabled = list.length < HISTORY_PAGE;
+    } catch (err) {
+      failed(err);
+    }
+  }
+
+  // --- Navigation ---
+
+  function show(section) {
+    ['sign-in', 'build', 'task', 'history'].forEach((id) => { $(id).hidden = id !== section; });
+    $('nav').hidden = section === 'sign-in';
+  }
+
+  async function route() {
+    stopPolling();
+    if (!sessionStorage.getItem(CREDENTIAL_KEY)) return show('sign-in');
+    try {
+      if (!state.schema) state.schema = await apiJSON('GET', '/schema/actions');
+    } catch (err) {
+      failed(err);
+      return show(sessionStorage.getItem(CREDENTIAL_KEY) ? 'build' : 'sign-in');
+    }
+
+    const [, view, id] = location.hash.split('/');
+    if (view === 'tasks' && id) {
+      show('task');
+      showTask(id);
+    } else if (view === 'history') {
+      show('history');
+      showHistory();
+    } else {
+      show('build');
+      if (state.actions.length === 0) state.actions.push(newAction('navigate'));
+      renderActions();
+    }
+  }
+
+  function signOut() {
+    sessionStorage.removeItem(CREDENTIAL_KEY);
+    state.schema = null;
+    show('sign-in');
+  }
+
+  $('sign-in-form').addEventListener('submit', (e) => {
+    e.preventDefault();
+    sessionStorage.setItem(CREDENTIAL_KEY, $('credential').value.trim());
+    $('credential').value = '';
+    notify('');
+    route();
+  });
+  $('sign-out').addEventListener('click', () => {
+    signOut();
+    notify('');
+  });
+  $('add-action').addEventListener('click', () => {
+    state.actions.push(newAction('click'));
+    renderActions();
+  });
+  $('toggle-json').addEventListener('click', toggleJSON);
+  $('submit-task').addEventListener('click', submitTask);
+  $('tfa-form').addEventListener('submit', sendTFACode);
+  $('cancel-task').addEventListener('click', cancelTask);
+  $('history-filter').addEventListener('submit', (e) => {
+    e.preventDefault();
+    state.historyOffset = 0;
+    showHistory();
+  });
+  $('history-prev').addEventListener('click', () => {
+    state.historyOffset = Math.max(0, state.historyOffset - HISTORY_PAGE);
+    showHistory();
+  });
+  $('history-next').addEventListener('click', () => {
+    state.historyOffset += HISTORY_PAGE;
+    showHistory();
+  });
+  window.addEventListener('hashchange', () => {
+    notify('');
+    route();
+  });
+  route();
+})();
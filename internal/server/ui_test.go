@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUITestServer(cfg *config.Config) http.Handler {
+	logger := logging.Discard()
+	return NewServer(cfg, tasks.NewManager(cfg, mocks.NewMockBrowserExecutor(), logger), logger).httpServer.Handler
+}
+
+func TestUI_Served(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.UI.Enabled = true
+	handler := newUITestServer(cfg)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/ui/", rec.Header().Get("Location"))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "frame-ancestors 'none'")
+	assert.Contains(t, rec.Body.String(), `<script src="app.js">`)
+
+	for _, name := range []string{"app.js", "app.css"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/"+name, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, name)
+	}
+}
+
+func TestUI_DisabledAndFiltered(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newUITestServer(&config.Config{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// The UI is subject to the same IP rules as the API
+	cfg := &config.Config{}
+	cfg.Server.UI.Enabled = true
+	cfg.Security.DeniedCIDRs = []string{"192.0.2.0/24"}
+	rec = httptest.NewRecorder()
+	newUITestServer(cfg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil)) // httptest requests come from 192.0.2.1
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
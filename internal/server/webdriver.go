@@ -0,0 +1,989 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp/kb"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// The WebDriver endpoint implements the part of the W3C WebDriver protocol
+// (https://www.w3.org/TR/webdriver2/) that Selenium test suites lean on.
+// Each WebDriver session is a GoScry session named wd-<session id>, and each
+// command runs as a task on it, so Selenium clients share the managed
+// browsers, limits, and task history with the rest of the API.
+const (
+	webDriverPath = "/wd/hub"
+	// webElementKey marks an element reference in WebDriver JSON.
+	webElementKey = "element-6066-11e4-a52e-4f735466cecf"
+	// webDriverSessionPrefix names the GoScry session behind a WebDriver session.
+	webDriverSessionPrefix = "wd-"
+	// webDriverWindow is the handle of a session's only window.
+	webDriverWindow = "main"
+	// wdElementAttr tags the elements a session has handed out references to.
+	wdElementAttr = "data-goscry-wd"
+	// wdPollInterval is how often implicit waits and navigation look again.
+	wdPollInterval = 200 * time.Millisecond
+	// wdInteractTimeout bounds how long click and send keys wait for an
+	// element to become visible before reporting it not interactable.
+	wdInteractTimeout = 5 * time.Second
+)
+
+// Timeout defaults from the WebDriver specification.
+var defaultWDTimeouts = wdTimeouts{Implicit: 0, PageLoad: 300000, Script: 30000}
+
+// wdErrorStatus maps WebDriver error codes to their HTTP status.
+var wdErrorStatus = map[string]int{
+	"element not interactable": http.StatusBadRequest,
+	"invalid argument":         http.StatusBadRequest,
+	"invalid element state":    http.StatusBadRequest,
+	"invalid selector":         http.StatusBadRequest,
+	"invalid session id":       http.StatusNotFound,
+	"no such element":          http.StatusNotFound,
+	"no such window":           http.StatusNotFound,
+	"stale element reference":  http.StatusNotFound,
+	"unknown command":          http.StatusNotFound,
+	"unknown method":           http.StatusMethodNotAllowed,
+	"javascript error":         http.StatusInternalServerError,
+	"session not created":      http.StatusInternalServerError,
+	"timeout":                  http.StatusInternalServerError,
+	"unexpected alert open":    http.StatusInternalServerError,
+	"unknown error":            http.StatusInternalServerError,
+	"unsupported operation":    http.StatusInternalServerError,
+}
+
+// wdLocators are the element location strategies WebDriver defines.
+var wdLocators = map[string]bool{
+	"css selector":      true,
+	"link text":         true,
+	"partial link text": true,
+	"tag name":          true,
+	"xpath":             true,
+}
+
+// wdKeys translates the WebDriver key code points send keys may carry into
+// the keys chromedp types. Modifier keys are pressed and released on their
+// own, as they are not held across the keys that follow.
+var wdKeys = strings.NewReplacer(
+	"\uE000", "",
+	"\uE003", kb.Backspace,
+	"\uE004", kb.Tab,
+	"\uE006", kb.Enter,
+	"\uE007", kb.Enter,
+	"\uE008", kb.Shift,
+	"\uE009", kb.Control,
+	"\uE00A", kb.Alt,
+	"\uE00C", kb.Escape,
+	"\uE00D", " ",
+	"\uE00E", kb.PageUp,
+	"\uE00F", kb.PageDown,
+	"\uE010", kb.End,
+	"\uE011", kb.Home,
+	"\uE012", kb.ArrowLeft,
+	"\uE013", kb.ArrowUp,
+	"\uE014", kb.ArrowRight,
+	"\uE015", kb.ArrowDown,
+	"\uE016", kb.Insert,
+	"\uE017", kb.Delete,
+	"\uE03D", kb.Meta,
+)
+
+// wdElementID matches the element IDs wdScriptPrelude hands out.
+var wdElementID = regexp.MustCompile(`^[0-9a-z]+$`)
+
+// wdScriptError finds the error a helper script threw with fail().
+var wdScriptError = regexp.MustCompile(`\[webdriver:([a-z ]+)\] ([^\n]*)`)
+
+// wdScriptPrelude runs a function body with JSON arguments, alongside the
+// helpers the WebDriver scripts share: el looks up a referenced element,
+// ref hands out a reference, out and into convert elements to and from
+// references, and fail throws a WebDriver error.
+const wdScriptPrelude = `(() => {
+	const key = "element-6066-11e4-a52e-4f735466cecf", attr = "data-goscry-wd";
+	const fail = (code, message) => { throw new Error("[webdriver:" + code + "] " + message); };
+	const el = id => {
+		const e = document.querySelector("[" + attr + "=\"" + id + "\"]");
+		if (!e) fail("stale element reference", "element " + id + " is no longer in the page");
+		return e;
+	};
+	const ref = e => {
+		let id = e.getAttribute(attr);
+		if (!id) {
+			id = Date.now().toString(36) + Math.random().toString(36).slice(2);
+			e.setAttribute(attr, id);
+		}
+		return id;
+	};
+	const seen = new Set();
+	const out = v => {
+		if (v === undefined || v === null || typeof v === "function" || typeof v === "symbol") return null;
+		if (v instanceof Element) return {[key]: ref(v)};
+		if (typeof v !== "object") return v;
+		if (seen.has(v)) fail("javascript error", "cyclic object value");
+		seen.add(v);
+		let o;
+		if (Array.isArray(v) || v instanceof NodeList || v instanceof HTMLCollection) {
+			o = Array.from(v, out);
+		} else {
+			o = {};
+			for (const k of Object.keys(v)) o[k] = out(v[k]);
+		}
+		seen.delete(v);
+		return o;
+	};
+	const into = v => {
+		if (Array.isArray(v)) return v.map(into);
+		if (v && typeof v === "object") {
+			if (typeof v[key] === "string") return el(v[key]);
+			const o = {};
+			for (const k of Object.keys(v)) o[k] = into(v[k]);
+			return o;
+		}
+		return v;
+	};
+	return (function() { %s }).apply(null, %s);
+})()`
+
+// Bodies of the helper scripts, run with wdScriptPrelude.
+const (
+	wdURLScript    = `return location.href;`
+	wdTitleScript  = `return document.title;`
+	wdSourceScript = `return document.documentElement.outerHTML;`
+	wdActiveScript = `return document.activeElement ? out(document.activeElement) : null;`
+	wdFindScript   = `const [parent, using, value] = arguments;
+const root = parent ? el(parent) : document;
+let found = [];
+try {
+	switch (using) {
+	case "css selector":
+		found = Array.from(root.querySelectorAll(value));
+		break;
+	case "link text":
+	case "partial link text":
+		found = Array.from(root.querySelectorAll("a")).filter(a => {
+			const text = a.innerText.trim();
+			return using === "link text" ? text === value : text.includes(value);
+		});
+		break;
+	case "tag name":
+		found = Array.from(root.getElementsByTagName(value));
+		break;
+	case "xpath":
+		const snapshot = document.evaluate(value, root, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+		for (let i = 0; i < snapshot.snapshotLength; i++) found.push(snapshot.snapshotItem(i));
+		break;
+	}
+} catch (e) {
+	fail("invalid selector", e.message);
+}
+return found.filter(n => n instanceof Element).map(ref);`
+	// wdHistoryScript starts going back or forward and returns the current
+	// history entry's key to watch for the change, or null if there is
+	// nowhere to go.
+	wdHistoryScript = `const [back] = arguments;
+if (typeof navigation === "undefined") {
+	back ? history.back() : history.forward();
+	return null;
+}
+if (back ? !navigation.canGoBack : !navigation.canGoForward) return null;
+const key = navigation.currentEntry.key;
+back ? history.back() : history.forward();
+return key;`
+	wdHistoryDoneScript = `return navigation.currentEntry.key !== arguments[0] && document.readyState === "complete";`
+	wdPrepareScript     = `el(arguments[0]).scrollIntoView({block: "center", inline: "nearest"}); return null;`
+	wdClearScript       = `const e = el(arguments[0]);
+if (e.isContentEditable) {
+	e.innerHTML = "";
+	return null;
+}
+if (!("value" in e) || e.readOnly || e.matches(":disabled")) fail("invalid element state", "element is not editable");
+e.focus();
+e.value = "";
+e.dispatchEvent(new Event("input", {bubbles: true}));
+e.dispatchEvent(new Event("change", {bubbles: true}));
+e.blur();
+return null;`
+	wdTextScript      = `return el(arguments[0]).innerText;`
+	wdNameScript      = `return el(arguments[0]).tagName.toLowerCase();`
+	wdAttributeScript = `return el(arguments[0]).getAttribute(arguments[1]);`
+	wdPropertyScript  = `return out(el(arguments[0])[arguments[1]]);`
+	wdCSSScript       = `return getComputedStyle(el(arguments[0])).getPropertyValue(arguments[1]);`
+	wdDisplayedScript = `return el(arguments[0]).checkVisibility({visibilityProperty: true});`
+	wdEnabledScript   = `return !el(arguments[0]).matches(":disabled");`
+	wdSelectedScript  = `const e = el(arguments[0]); return !!(e.checked || e.selected);`
+	wdRectScript      = `const r = el(arguments[0]).getBoundingClientRect();
+return {x: r.x + scrollX, y: r.y + scrollY, width: r.width, height: r.height};`
+	// wdExecuteScript runs a client's script with its arguments.
+	wdExecuteScript = `return out((function() { %s }).apply(null, into(arguments)));`
+)
+
+// wdScript wraps body in wdScriptPrelude, passing args as its arguments.
+func wdScript(body string, args ...interface{}) (string, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(wdScriptPrelude, body, data), nil
+}
+
+// webDriverError is a WebDriver error response.
+type webDriverError struct {
+	code    string
+	message string
+}
+
+func newWebDriverError(code, format string, args ...interface{}) *webDriverError {
+	return &webDriverError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func (e *webDriverError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func (e *webDriverError) status() int {
+	if status, ok := wdErrorStatus[e.code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// wdTaskError turns a failed command task into the WebDriver error it stands for.
+func wdTaskError(result *taskstypes.TaskResult) *webDriverError {
+	if m := wdScriptError.FindStringSubmatch(result.Error); m != nil {
+		return newWebDriverError(m[1], "%s", m[2])
+	}
+	switch result.ErrorCode {
+	case taskstypes.ErrorScriptError:
+		return newWebDriverError("javascript error", "%s", result.Error)
+	case taskstypes.ErrorSelectorNotFound:
+		return newWebDriverError("no such element", "%s", result.Error)
+	case taskstypes.ErrorNavigationTimeout, taskstypes.ErrorActionTimeout, taskstypes.ErrorTaskTimeout:
+		return newWebDriverError("timeout", "%s", result.Error)
+	case taskstypes.ErrorDialogUnhandled:
+		return newWebDriverError("unexpected alert open", "%s", result.Error)
+	}
+	return newWebDriverError("unknown error", "%s", result.Error)
+}
+
+// wdTimeouts are a session's timeouts in milliseconds.
+type wdTimeouts struct {
+	Implicit int64 `json:"implicit"`
+	PageLoad int64 `json:"pageLoad"`
+	Script   int64 `json:"script"`
+}
+
+// update sets the timeouts present in a WebDriver timeouts object.
+func (t *wdTimeouts) update(raw map[string]interface{}) error {
+	for name, field := range map[string]*int64{"implicit": &t.Implicit, "pageLoad": &t.PageLoad, "script": &t.Script} {
+		value, ok := raw[name]
+		if !ok {
+			continue
+		}
+		if value == nil && name == "script" {
+			*field = 0 // No script timeout; the task's own limits still apply
+			continue
+		}
+		ms, ok := value.(float64)
+		if !ok || ms < 0 || ms > 1<<53-1 || ms != float64(int64(ms)) {
+			return newWebDriverError("invalid argument", "%s timeout must be a non-negative integer", name)
+		}
+		*field = int64(ms)
+	}
+	return nil
+}
+
+// wdSession is the state of one WebDriver session.
+type wdSession struct {
+	id   string
+	name string // The GoScry session commands run on
+
+	mu       sync.Mutex
+	timeouts wdTimeouts
+}
+
+func (s *wdSession) getTimeouts() wdTimeouts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timeouts
+}
+
+// webDriver serves the WebDriver endpoint.
+type webDriver struct {
+	taskManager *tasks.Manager
+	logger      *slog.Logger
+
+	mu       sync.Mutex
+	sessions map[string]*wdSession // By WebDriver session ID
+}
+
+// WebDriverHandler serves the W3C WebDriver protocol, mounted at /wd/hub.
+func WebDriverHandler(tm *tasks.Manager, logger *slog.Logger) http.Handler {
+	d := &webDriver{taskManager: tm, logger: logger, sessions: make(map[string]*wdSession)}
+	r := chi.NewRouter()
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		d.respondError(w, r, newWebDriverError("unknown command", "%s %s is not a supported WebDriver command", r.Method, r.URL.Path))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		d.respondError(w, r, newWebDriverError("unknown method", "%s is not supported for %s", r.Method, r.URL.Path))
+	})
+
+	r.Get("/status", d.handleStatus)
+	r.Post("/session", d.handleNewSession)
+	r.Route("/session/{sessionID}", func(r chi.Router) {
+		r.Delete("/", d.handleDeleteSession)
+		r.Get("/timeouts", d.command(d.getTimeouts))
+		r.Post("/timeouts", d.command(d.setTimeouts))
+		r.Post("/url", d.command(d.navigate))
+		r.Get("/url", d.command(d.scriptCommand(wdURLScript)))
+		r.Post("/back", d.command(d.history(true)))
+		r.Post("/forward", d.command(d.history(false)))
+		r.Post("/refresh", d.command(d.refresh))
+		r.Get("/title", d.command(d.scriptCommand(wdTitleScript)))
+		r.Get("/source", d.command(d.scriptCommand(wdSourceScript)))
+		r.Get("/window", d.command(d.getWindow))
+		r.Post("/window", d.command(d.switchWindow))
+		r.Get("/window/handles", d.command(d.getWindowHandles))
+		r.Post("/element", d.command(d.findElements(false)))
+		r.Post("/elements", d.command(d.findElements(true)))
+		r.Get("/element/active", d.command(d.activeElement))
+		r.Post("/element/{elementID}/element", d.command(d.findElements(false)))
+		r.Post("/element/{elementID}/elements", d.command(d.findElements(true)))
+		r.Post("/element/{elementID}/click", d.command(d.click))
+		r.Post("/element/{elementID}/clear", d.command(d.elementScript(wdClearScript)))
+		r.Post("/element/{elementID}/value", d.command(d.sendKeys))
+		r.Get("/element/{elementID}/text", d.command(d.elementScript(wdTextScript)))
+		r.Get("/element/{elementID}/name", d.command(d.elementScript(wdNameScript)))
+		r.Get("/element/{elementID}/attribute/{name}", d.command(d.elementScript(wdAttributeScript, "name")))
+		r.Get("/element/{elementID}/property/{name}", d.command(d.elementScript(wdPropertyScript, "name")))
+		r.Get("/element/{elementID}/css/{name}", d.command(d.elementScript(wdCSSScript, "name")))
+		r.Get("/element/{elementID}/displayed", d.command(d.elementScript(wdDisplayedScript)))
+		r.Get("/element/{elementID}/enabled", d.command(d.elementScript(wdEnabledScript)))
+		r.Get("/element/{elementID}/selected", d.command(d.elementScript(wdSelectedScript)))
+		r.Get("/element/{elementID}/rect", d.command(d.elementScript(wdRectScript)))
+		r.Get("/element/{elementID}/screenshot", d.command(d.screenshot))
+		r.Post("/execute/sync", d.command(d.execute))
+		r.Post("/execute/async", d.command(d.executeAsync))
+		r.Get("/screenshot", d.command(d.screenshot))
+	})
+	return r
+}
+
+// wdCommand carries out a command on a session and returns its value.
+type wdCommand func(r *http.Request, s *wdSession) (interface{}, error)
+
+// command looks up the request's session and responds with the result of fn.
+func (d *webDriver) command(fn wdCommand) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := d.session(r, chi.URLParam(r, "sessionID"))
+		var value interface{}
+		if err == nil {
+			value, err = fn(r, s)
+		}
+		if err != nil {
+			d.respondError(w, r, err)
+			return
+		}
+		d.respond(w, http.StatusOK, value)
+	}
+}
+
+// session returns an open session the caller r may use, dropping it if
+// GoScry closed it, e.g. when it reached its maximum lifetime.
+func (d *webDriver) session(r *http.Request, id string) (*wdSession, error) {
+	d.mu.Lock()
+	s := d.sessions[id]
+	d.mu.Unlock()
+	if s == nil {
+		return nil, newWebDriverError("invalid session id", "no session %q", id)
+	}
+	sessions, err := d.taskManager.Sessions()
+	if err != nil {
+		return nil, newWebDriverError("unknown error", "%v", err)
+	}
+	info, err := sessions.GetSession(s.name)
+	if errors.Is(err, tasks.ErrSessionNotFound) {
+		d.mu.Lock()
+		delete(d.sessions, id)
+		d.mu.Unlock()
+		return nil, newWebDriverError("invalid session id", "session %q has ended", id)
+	}
+	if err == nil && !mayUse(r, info.Owner) {
+		return nil, newWebDriverError("invalid session id", "no session %q", id)
+	}
+	return s, nil
+}
+
+func (d *webDriver) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := map[string]interface{}{"ready": true, "message": "GoScry is ready for new sessions"}
+	if _, err := d.taskManager.Sessions(); err != nil {
+		status = map[string]interface{}{"ready": false, "message": err.Error()}
+	}
+	d.respond(w, http.StatusOK, status)
+}
+
+// NewWebDriverSessionRequest is the body of POST /session.
+type NewWebDriverSessionRequest struct {
+	Capabilities struct {
+		AlwaysMatch map[string]interface{}   `json:"alwaysMatch"`
+		FirstMatch  []map[string]interface{} `json:"firstMatch"`
+	} `json:"capabilities"`
+}
+
+// matchCapabilities merges alwaysMatch with each firstMatch entry in turn
+// and returns the first combination GoScry can satisfy.
+func (req NewWebDriverSessionRequest) matchCapabilities() (map[string]interface{}, error) {
+	firstMatch := req.Capabilities.FirstMatch
+	if len(firstMatch) == 0 {
+		firstMatch = []map[string]interface{}{{}}
+	}
+	for _, first := range firstMatch {
+		merged := make(map[string]interface{}, len(req.Capabilities.AlwaysMatch)+len(first))
+		for name, value := range req.Capabilities.AlwaysMatch {
+			merged[name] = value
+		}
+		for name, value := range first {
+			if _, ok := merged[name]; ok {
+				return nil, newWebDriverError("invalid argument", "capability %q is in both alwaysMatch and firstMatch", name)
+			}
+			merged[name] = value
+		}
+		switch merged["browserName"] {
+		case nil, "chrome", "chromium":
+			return merged, nil
+		}
+	}
+	return nil, newWebDriverError("session not created", "no capabilities match; GoScry drives chrome")
+}
+
+// wdSessionOptions reads the "goscry:options" capability, which takes the
+// same settings as POST /api/v1/sessions apart from name and login.
+func wdSessionOptions(caps map[string]interface{}) (taskstypes.SessionOptions, error) {
+	raw, ok := caps["goscry:options"]
+	if !ok {
+		return taskstypes.SessionOptions{}, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return taskstypes.SessionOptions{}, newWebDriverError("invalid argument", "goscry:options: %v", err)
+	}
+	var req CreateSessionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return taskstypes.SessionOptions{}, newWebDriverError("invalid argument", "goscry:options: %v", err)
+	}
+	if req.Login != nil {
+		return taskstypes.SessionOptions{}, newWebDriverError("invalid argument", "goscry:options: login is not supported for WebDriver sessions")
+	}
+	opts, err := req.sessionOptions()
+	if err != nil {
+		return taskstypes.SessionOptions{}, newWebDriverError("invalid argument", "goscry:options: %v", err)
+	}
+	return opts, nil
+}
+
+func (d *webDriver) handleNewSession(w http.ResponseWriter, r *http.Request) {
+	var req NewWebDriverSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		d.respondError(w, r, newWebDriverError("invalid argument", "invalid request body: %v", err))
+		return
+	}
+	defer r.Body.Close()
+
+	caps, err := req.matchCapabilities()
+	if err != nil {
+		d.respondError(w, r, err)
+		return
+	}
+	timeouts := defaultWDTimeouts
+	if raw, ok := caps["timeouts"]; ok {
+		values, ok := raw.(map[string]interface{})
+		if !ok {
+			d.respondError(w, r, newWebDriverError("invalid argument", "timeouts must be an object"))
+			return
+		}
+		if err := timeouts.update(values); err != nil {
+			d.respondError(w, r, err)
+			return
+		}
+	}
+	opts, err := wdSessionOptions(caps)
+	if err != nil {
+		d.respondError(w, r, err)
+		return
+	}
+	opts.Owner = ownerTenant(r)
+	if err := checkProfile(r, d.taskManager, opts.Profile); err != nil {
+		d.respondError(w, r, newWebDriverError("session not created", "%v", err))
+		return
+	}
+
+	sessions, err := d.taskManager.Sessions()
+	if err != nil {
+		d.respondError(w, r, newWebDriverError("session not created", "%v", err))
+		return
+	}
+	s := &wdSession{id: uuid.NewString(), timeouts: timeouts}
+	s.name = webDriverSessionPrefix + s.id
+	if _, err := sessions.CreateSession(s.name, opts); err != nil {
+		d.respondError(w, r, newWebDriverError("session not created", "%v", err))
+		return
+	}
+	d.mu.Lock()
+	d.sessions[s.id] = s
+	d.mu.Unlock()
+	d.logger.InfoContext(r.Context(), "WebDriver session created", "session", s.name)
+
+	d.respond(w, http.StatusOK, map[string]interface{}{
+		"sessionId": s.id,
+		"capabilities": map[string]interface{}{
+			"browserName":               "chrome",
+			"acceptInsecureCerts":       false,
+			"pageLoadStrategy":          "normal",
+			"setWindowRect":             false,
+			"strictFileInteractability": false,
+			"timeouts":                  timeouts,
+			"goscry:session":            s.name,
+		},
+	})
+}
+
+func (d *webDriver) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "sessionID")
+	d.mu.Lock()
+	s := d.sessions[id]
+	d.mu.Unlock()
+	if s == nil {
+		d.respondError(w, r, newWebDriverError("invalid session id", "no session %q", id))
+		return
+	}
+	if sessions, err := d.taskManager.Sessions(); err == nil {
+		if info, err := sessions.GetSession(s.name); err == nil && !mayUse(r, info.Owner) {
+			d.respondError(w, r, newWebDriverError("invalid session id", "no session %q", id))
+			return
+		}
+	}
+	d.mu.Lock()
+	delete(d.sessions, id)
+	d.mu.Unlock()
+	if sessions, err := d.taskManager.Sessions(); err == nil {
+		if err := sessions.CloseSession(s.name); err != nil && !errors.Is(err, tasks.ErrSessionNotFound) {
+			d.respondError(w, r, newWebDriverError("unknown error", "%v", err))
+			return
+		}
+	}
+	d.respond(w, http.StatusOK, nil)
+}
+
+// run executes actions as one task on the session and returns the finished
+// task. Commands are not kept in the task list or history.
+func (d *webDriver) run(r *http.Request, s *wdSession, actions ...taskstypes.Action) (*taskstypes.Task, error) {
+	task := newTask(r, SubmitTaskRequest{Actions: actions, Session: s.name, Tags: map[string]string{"webdriver": s.id}})
+	finished, err := d.taskManager.RunOnSession(r.Context(), task)
+	if err != nil {
+		return nil, newWebDriverError("unknown error", "%v", err)
+	}
+	if finished.Result == nil {
+		return nil, newWebDriverError("unknown error", "task %s finished without a result", task.ID)
+	}
+	if !finished.Result.Success {
+		return nil, wdTaskError(finished.Result)
+	}
+	return finished, nil
+}
+
+// script runs a helper script on the session and returns its value.
+func (d *webDriver) script(r *http.Request, s *wdSession, body string, args ...interface{}) (interface{}, error) {
+	src, err := wdScript(body, args...)
+	if err != nil {
+		return nil, newWebDriverError("invalid argument", "%v", err)
+	}
+	action := taskstypes.Action{Type: taskstypes.ActionRunScript, Value: src}
+	if script := s.getTimeouts().Script; script > 0 {
+		action.Timeout = time.Duration(script) * time.Millisecond
+	}
+	task, err := d.run(r, s, action)
+	if err != nil {
+		return nil, err
+	}
+	return task.Result.Data, nil
+}
+
+// scriptCommand is a command that returns the value of a helper script.
+func (d *webDriver) scriptCommand(body string) wdCommand {
+	return func(r *http.Request, s *wdSession) (interface{}, error) {
+		return d.script(r, s, body)
+	}
+}
+
+// elementID returns the request's element reference.
+func elementID(r *http.Request) (string, error) {
+	id := chi.URLParam(r, "elementID")
+	if !wdElementID.MatchString(id) {
+		return "", newWebDriverError("no such element", "no element %q", id)
+	}
+	return id, nil
+}
+
+// elementSelector returns the CSS selector for a referenced element.
+func elementSelector(id string) string {
+	return fmt.Sprintf(`[%s="%s"]`, wdElementAttr, id)
+}
+
+// elementScript is a command that runs a helper script on the request's
+// element, passing the named URL parameters after its ID.
+func (d *webDriver) elementScript(body string, params ...string) wdCommand {
+	return func(r *http.Request, s *wdSession) (interface{}, error) {
+		id, err := elementID(r)
+		if err != nil {
+			return nil, err
+		}
+		args := []interface{}{id}
+		for _, param := range params {
+			args = append(args, chi.URLParam(r, param))
+		}
+		return d.script(r, s, body, args...)
+	}
+}
+
+// decodeParams reads a command's JSON parameters into v.
+func decodeParams(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return newWebDriverError("invalid argument", "invalid parameters: %v", err)
+	}
+	return nil
+}
+
+// until calls done every wdPollInterval until it reports true, returns an
+// error, or timeout passes. It always calls done at least once.
+func until(ctx context.Context, timeout time.Duration, done func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := done()
+		if ok || err != nil {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return newWebDriverError("timeout", "timed out after %s", timeout)
+		}
+		select {
+		case <-time.After(wdPollInterval):
+		case <-ctx.Done():
+			return newWebDriverError("unknown error", "%v", ctx.Err())
+		}
+	}
+}
+
+func (d *webDriver) getTimeouts(r *http.Request, s *wdSession) (interface{}, error) {
+	return s.getTimeouts(), nil
+}
+
+func (d *webDriver) setTimeouts(r *http.Request, s *wdSession) (interface{}, error) {
+	var raw map[string]interface{}
+	if err := decodeParams(r, &raw); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	timeouts := s.timeouts
+	if err := timeouts.update(raw); err != nil {
+		return nil, err
+	}
+	s.timeouts = timeouts
+	return nil, nil
+}
+
+func (d *webDriver) navigate(r *http.Request, s *wdSession) (interface{}, error) {
+	var params struct {
+		URL string `json:"url"`
+	}
+	if err := decodeParams(r, &params); err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(params.URL); err != nil || !u.IsAbs() {
+		return nil, newWebDriverError("invalid argument", "url must be an absolute URL, got %q", params.URL)
+	}
+	return nil, d.load(r, s, params.URL)
+}
+
+// load navigates the session to target within its page load timeout.
+func (d *webDriver) load(r *http.Request, s *wdSession, target string) error {
+	action := taskstypes.Action{Type: taskstypes.ActionNavigate, Value: target}
+	if pageLoad := s.getTimeouts().PageLoad; pageLoad > 0 {
+		action.Timeout = time.Duration(pageLoad) * time.Millisecond
+	}
+	_, err := d.run(r, s, action)
+	return err
+}
+
+// refresh reloads the page by navigating to its URL again.
+func (d *webDriver) refresh(r *http.Request, s *wdSession) (interface{}, error) {
+	current, err := d.script(r, s, wdURLScript)
+	if err != nil {
+		return nil, err
+	}
+	target, _ := current.(string)
+	return nil, d.load(r, s, target)
+}
+
+// history goes back or forward and waits for the page it lands on to load.
+func (d *webDriver) history(back bool) wdCommand {
+	return func(r *http.Request, s *wdSession) (interface{}, error) {
+		key, err := d.script(r, s, wdHistoryScript, back)
+		if err != nil || key == nil {
+			return nil, err
+		}
+		// Scripts fail while the old page unloads, so only the last error counts
+		var last error
+		err = until(r.Context(), time.Duration(s.getTimeouts().PageLoad)*time.Millisecond, func() (bool, error) {
+			var done interface{}
+			done, last = d.script(r, s, wdHistoryDoneScript, key)
+			return done == true, nil
+		})
+		if err != nil && last != nil {
+			return nil, last
+		}
+		return nil, err
+	}
+}
+
+func (d *webDriver) getWindow(r *http.Request, s *wdSession) (interface{}, error) {
+	return webDriverWindow, nil
+}
+
+func (d *webDriver) getWindowHandles(r *http.Request, s *wdSession) (interface{}, error) {
+	return []string{webDriverWindow}, nil
+}
+
+// switchWindow accepts only the session's one window.
+func (d *webDriver) switchWindow(r *http.Request, s *wdSession) (interface{}, error) {
+	var params struct {
+		Handle string `json:"handle"`
+	}
+	if err := decodeParams(r, &params); err != nil {
+		return nil, err
+	}
+	if params.Handle != webDriverWindow {
+		return nil, newWebDriverError("no such window", "no window %q", params.Handle)
+	}
+	return nil, nil
+}
+
+// findElements finds the first or all elements matching a locator, within
+// the request's element if it names one, retrying for the implicit wait.
+func (d *webDriver) findElements(all bool) wdCommand {
+	return func(r *http.Request, s *wdSession) (interface{}, error) {
+		var parent string
+		if chi.URLParam(r, "elementID") != "" {
+			id, err := elementID(r)
+			if err != nil {
+				return nil, err
+			}
+			parent = id
+		}
+		var params struct {
+			Using string `json:"using"`
+			Value string `json:"value"`
+		}
+		if err := decodeParams(r, &params); err != nil {
+			return nil, err
+		}
+		if !wdLocators[params.Using] {
+			return nil, newWebDriverError("invalid argument", "unknown location strategy %q", params.Using)
+		}
+
+		var ids []string
+		wait := time.Duration(s.getTimeouts().Implicit) * time.Millisecond
+		err := until(r.Context(), wait, func() (bool, error) {
+			value, err := d.script(r, s, wdFindScript, parent, params.Using, params.Value)
+			if err != nil {
+				return false, err
+			}
+			ids = ids[:0]
+			found, _ := value.([]interface{})
+			for _, id := range found {
+				if id, ok := id.(string); ok {
+					ids = append(ids, id)
+				}
+			}
+			return len(ids) > 0, nil
+		})
+		var wdErr *webDriverError
+		if err != nil && !(errors.As(err, &wdErr) && wdErr.code == "timeout") {
+			return nil, err
+		}
+
+		refs := make([]map[string]string, len(ids))
+		for i, id := range ids {
+			refs[i] = map[string]string{webElementKey: id}
+		}
+		if all {
+			return refs, nil
+		}
+		if len(refs) == 0 {
+			return nil, newWebDriverError("no such element", "no element matches %s %q", params.Using, params.Value)
+		}
+		return refs[0], nil
+	}
+}
+
+func (d *webDriver) activeElement(r *http.Request, s *wdSession) (interface{}, error) {
+	value, err := d.script(r, s, wdActiveScript)
+	if err == nil && value == nil {
+		return nil, newWebDriverError("no such element", "no element has focus")
+	}
+	return value, err
+}
+
+// interact scrolls the request's element into view and then runs action on it.
+func (d *webDriver) interact(r *http.Request, s *wdSession, action taskstypes.Action) error {
+	id, err := elementID(r)
+	if err != nil {
+		return err
+	}
+	prepare, err := wdScript(wdPrepareScript, id)
+	if err != nil {
+		return newWebDriverError("invalid argument", "%v", err)
+	}
+	action.Selector = elementSelector(id)
+	action.Timeout = wdInteractTimeout
+	_, err = d.run(r, s, taskstypes.Action{Type: taskstypes.ActionRunScript, Value: prepare}, action)
+	var wdErr *webDriverError
+	if errors.As(err, &wdErr) && wdErr.code == "timeout" {
+		return newWebDriverError("element not interactable", "element %s did not become visible: %s", id, wdErr.message)
+	}
+	return err
+}
+
+func (d *webDriver) click(r *http.Request, s *wdSession) (interface{}, error) {
+	return nil, d.interact(r, s, taskstypes.Action{Type: taskstypes.ActionClick})
+}
+
+func (d *webDriver) sendKeys(r *http.Request, s *wdSession) (interface{}, error) {
+	var params struct {
+		Text *string `json:"text"`
+	}
+	if err := decodeParams(r, &params); err != nil {
+		return nil, err
+	}
+	if params.Text == nil {
+		return nil, newWebDriverError("invalid argument", "text is required")
+	}
+	return nil, d.interact(r, s, taskstypes.Action{Type: taskstypes.ActionInput, Value: wdKeys.Replace(*params.Text)})
+}
+
+func (d *webDriver) execute(r *http.Request, s *wdSession) (interface{}, error) {
+	var params struct {
+		Script string          `json:"script"`
+		Args   json.RawMessage `json:"args"`
+	}
+	if err := decodeParams(r, &params); err != nil {
+		return nil, err
+	}
+	var args []interface{}
+	if err := json.Unmarshal(params.Args, &args); err != nil || args == nil {
+		return nil, newWebDriverError("invalid argument", "args must be an array")
+	}
+	return d.script(r, s, fmt.Sprintf(wdExecuteScript, params.Script), args...)
+}
+
+// executeAsync is not supported: run_script returns as soon as the script
+// does, without waiting for its callback.
+func (d *webDriver) executeAsync(r *http.Request, s *wdSession) (interface{}, error) {
+	return nil, newWebDriverError("unsupported operation", "asynchronous scripts are not supported; use execute/sync")
+}
+
+// screenshot captures the page, or the request's element, as a PNG.
+func (d *webDriver) screenshot(r *http.Request, s *wdSession) (interface{}, error) {
+	// Quality 100 captures PNG
+	actions := []taskstypes.Action{{Type: taskstypes.ActionScreenshot, Value: "100"}}
+	if chi.URLParam(r, "elementID") != "" {
+		id, err := elementID(r)
+		if err != nil {
+			return nil, err
+		}
+		prepare, err := wdScript(wdPrepareScript, id)
+		if err != nil {
+			return nil, newWebDriverError("invalid argument", "%v", err)
+		}
+		actions[0].Selector = elementSelector(id)
+		actions = append([]taskstypes.Action{{Type: taskstypes.ActionRunScript, Value: prepare}}, actions...)
+	}
+	task, err := d.run(r, s, actions...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The screenshot is only returned, not kept
+	store := d.taskManager.Artifacts()
+	defer func() {
+		if err := store.Delete(context.WithoutCancel(r.Context()), task.ID); err != nil {
+			d.logger.WarnContext(r.Context(), "Failed to delete WebDriver screenshot", "task_id", task.ID, "error", err)
+		}
+	}()
+	objects, err := store.List(r.Context(), task.ID)
+	if err != nil {
+		return nil, newWebDriverError("unknown error", "%v", err)
+	}
+	for _, obj := range objects {
+		if !strings.HasPrefix(obj.Name, "screenshot-") {
+			continue
+		}
+		body, _, err := store.Get(r.Context(), task.ID, obj.Name)
+		if err != nil {
+			return nil, newWebDriverError("unknown error", "%v", err)
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, newWebDriverError("unknown error", "%v", err)
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	return nil, newWebDriverError("unknown error", "task %s saved no screenshot", task.ID)
+}
+
+// respond writes a WebDriver success response.
+func (d *webDriver) respond(w http.ResponseWriter, status int, value interface{}) {
+	response, err := json.Marshal(map[string]interface{}{"value": value})
+	if err != nil {
+		d.logger.Error("Failed to marshal WebDriver response", "error", err)
+		response, status = []byte(`{"value": {"error": "unknown error", "message": "failed to encode response", "stacktrace": ""}}`), http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// respondError writes a WebDriver error response.
+func (d *webDriver) respondError(w http.ResponseWriter, r *http.Request, err error) {
+	var wdErr *webDriverError
+	if !errors.As(err, &wdErr) {
+		wdErr = newWebDriverError("unknown error", "%v", err)
+	}
+	status := wdErr.status()
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	d.logger.Log(r.Context(), level, "WebDriver error response", "status", status, "error", wdErr.code, "message", wdErr.message)
+	d.respond(w, status, map[string]string{"error": wdErr.code, "message": wdErr.message, "stacktrace": ""})
+}
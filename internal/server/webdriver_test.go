@@ -0,0 +1,304 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wdExecutor stands in for a browser with sessions. Scripts are answered by
+// the script func, keyed on the helper script they run.
+type wdExecutor struct {
+	store  artifacts.Store
+	script func(body string) (interface{}, error)
+
+	mu       sync.Mutex
+	sessions map[string]bool
+	tasks    []*taskstypes.Task
+}
+
+var _ tasks.SessionExecutor = (*wdExecutor)(nil)
+
+func (e *wdExecutor) ExecuteTask(ctx context.Context, task *taskstypes.Task) (*taskstypes.TaskResult, error) {
+	e.mu.Lock()
+	e.tasks = append(e.tasks, task)
+	open := e.sessions[task.Session]
+	e.mu.Unlock()
+	if !open {
+		return nil, fmt.Errorf("session %q not found", task.Session)
+	}
+
+	result := &taskstypes.TaskResult{Success: true}
+	for i, action := range task.Actions {
+		var err error
+		switch action.Type {
+		case taskstypes.ActionRunScript:
+			result.Data, err = e.script(action.Value)
+		case taskstypes.ActionScreenshot:
+			_, err = e.store.Put(ctx, task.ID, fmt.Sprintf("screenshot-%d.png", i), strings.NewReader("\x89PNG"))
+		}
+		if err != nil {
+			result.Success = false
+			result.ErrorCode = taskstypes.ErrorScriptError
+			result.FailedAction = &taskstypes.FailedAction{Index: i, Type: action.Type}
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (e *wdExecutor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *wdExecutor) CreateSession(name string, opts taskstypes.SessionOptions) (*taskstypes.SessionInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sessions[name] = true
+	return &taskstypes.SessionInfo{Name: name}, nil
+}
+
+func (e *wdExecutor) GetSession(name string) (*taskstypes.SessionInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.sessions[name] {
+		return nil, tasks.ErrSessionNotFound
+	}
+	return &taskstypes.SessionInfo{Name: name}, nil
+}
+
+func (e *wdExecutor) ListSessions() []taskstypes.SessionInfo {
+	return nil
+}
+
+func (e *wdExecutor) CloseSession(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.sessions[name] {
+		return tasks.ErrSessionNotFound
+	}
+	delete(e.sessions, name)
+	return nil
+}
+
+// lastTask returns the most recent task the executor ran.
+func (e *wdExecutor) lastTask() *taskstypes.Task {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.tasks[len(e.tasks)-1]
+}
+
+// newWebDriverTest serves the WebDriver endpoint over a wdExecutor.
+func newWebDriverTest(t *testing.T, script func(body string) (interface{}, error)) (*wdExecutor, *tasks.Manager, func(method, path, body string) (int, interface{})) {
+	cfg := &config.Config{Browser: config.BrowserConfig{Artifacts: config.ArtifactConfig{Dir: t.TempDir()}}}
+	executor := &wdExecutor{store: artifacts.NewLocalStore(cfg.Browser.Artifacts.Dir), script: script, sessions: make(map[string]bool)}
+	manager := tasks.NewManager(cfg, executor, logging.Discard())
+	h := WebDriverHandler(manager, logging.Discard())
+
+	do := func(method, path, body string) (int, interface{}) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var response struct {
+			Value interface{} `json:"value"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+		return rec.Code, response.Value
+	}
+	return executor, manager, do
+}
+
+func TestWebDriver_Session(t *testing.T) {
+	executor, manager, do := newWebDriverTest(t, func(body string) (interface{}, error) {
+		switch {
+		case strings.Contains(body, wdTitleScript):
+			return "Example Domain", nil
+		case strings.Contains(body, wdFindScript):
+			return []interface{}{"k1", "k2"}, nil
+		case strings.Contains(body, wdTextScript):
+			return nil, errors.New(`exception "Uncaught" (0:0): Error: [webdriver:stale element reference] element k1 is no longer in the page
+    at <anonymous>:4:23`)
+		}
+		return nil, nil
+	})
+
+	code, value := do(http.MethodGet, "/status", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, value.(map[string]interface{})["ready"])
+
+	code, value = do(http.MethodPost, "/session", `{"capabilities": {"alwaysMatch": {"timeouts": {"implicit": 0}}, "firstMatch": [{"browserName": "firefox"}, {"browserName": "chrome"}]}}`)
+	require.Equal(t, http.StatusOK, code, value)
+	created := value.(map[string]interface{})
+	id := created["sessionId"].(string)
+	caps := created["capabilities"].(map[string]interface{})
+	assert.Equal(t, "chrome", caps["browserName"])
+	assert.Equal(t, webDriverSessionPrefix+id, caps["goscry:session"])
+	session := "/session/" + id
+
+	code, _ = do(http.MethodPost, session+"/url", `{"url": "https://example.com/"}`)
+	require.Equal(t, http.StatusOK, code)
+	task := executor.lastTask()
+	assert.Equal(t, webDriverSessionPrefix+id, task.Session)
+	assert.Equal(t, map[string]string{"webdriver": id}, task.Tags)
+	assert.Equal(t, []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com/", Timeout: 300 * time.Second}}, task.Actions)
+
+	code, value = do(http.MethodPost, session+"/url", `{"url": "/relative"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid argument", value.(map[string]interface{})["error"])
+
+	code, value = do(http.MethodGet, session+"/title", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Example Domain", value)
+
+	code, value = do(http.MethodPost, session+"/element", `{"using": "css selector", "value": "a"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{webElementKey: "k1"}, value)
+	code, value = do(http.MethodPost, session+"/elements", `{"using": "xpath", "value": "//a"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, value, 2)
+	code, _ = do(http.MethodPost, session+"/element", `{"using": "id", "value": "a"}`)
+	assert.Equal(t, http.StatusBadRequest, code, "Selenium sends id and name as css selector")
+
+	code, _ = do(http.MethodPost, session+"/element/k1/value", `{"text": "hello"}`)
+	require.Equal(t, http.StatusOK, code)
+	task = executor.lastTask()
+	require.Len(t, task.Actions, 2)
+	assert.Equal(t, taskstypes.ActionRunScript, task.Actions[0].Type, "the element is checked and scrolled into view first")
+	assert.Equal(t, taskstypes.Action{Type: taskstypes.ActionInput, Selector: `[data-goscry-wd="k1"]`, Value: "hello\r", Timeout: wdInteractTimeout}, task.Actions[1])
+
+	code, value = do(http.MethodGet, session+"/element/k1/text", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "stale element reference", value.(map[string]interface{})["error"])
+	assert.Equal(t, "element k1 is no longer in the page", value.(map[string]interface{})["message"])
+	code, value = do(http.MethodGet, session+"/element/K1%22%5D/text", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "no such element", value.(map[string]interface{})["error"], "element IDs are never put in a selector unchecked")
+
+	code, value = do(http.MethodGet, session+"/screenshot", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("\x89PNG")), value)
+	assert.Equal(t, "100", executor.lastTask().Actions[0].Value, "quality 100 captures PNG")
+	kept, err := executor.store.List(context.Background(), executor.lastTask().ID)
+	require.NoError(t, err)
+	assert.Empty(t, kept, "the screenshot is not kept")
+
+	code, value = do(http.MethodPost, session+"/execute/async", `{"script": "", "args": []}`)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "unsupported operation", value.(map[string]interface{})["error"])
+	code, value = do(http.MethodGet, session+"/cookie", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "unknown command", value.(map[string]interface{})["error"])
+
+	history, err := manager.ListTasks(tasks.ListFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, history, "commands are not kept as tasks")
+
+	code, value = do(http.MethodDelete, session, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Nil(t, value)
+	assert.Empty(t, executor.sessions, "the GoScry session is closed with it")
+	code, value = do(http.MethodGet, session+"/title", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "invalid session id", value.(map[string]interface{})["error"])
+}
+
+func TestWebDriver_ImplicitWait(t *testing.T) {
+	var mu sync.Mutex
+	finds := 0
+	executor, _, do := newWebDriverTest(t, func(body string) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if finds++; finds < 3 {
+			return []interface{}{}, nil
+		}
+		return []interface{}{"k1"}, nil
+	})
+	code, value := do(http.MethodPost, "/session", `{"capabilities": {}}`)
+	require.Equal(t, http.StatusOK, code)
+	session := "/session/" + value.(map[string]interface{})["sessionId"].(string)
+
+	code, value = do(http.MethodPost, session+"/elements", `{"using": "tag name", "value": "p"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []interface{}{}, value, "without an implicit wait the first look counts")
+
+	code, _ = do(http.MethodPost, session+"/timeouts", `{"implicit": 5000, "script": null}`)
+	require.Equal(t, http.StatusOK, code)
+	code, value = do(http.MethodGet, session+"/timeouts", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"implicit": 5000.0, "pageLoad": 300000.0, "script": 0.0}, value)
+	code, _ = do(http.MethodPost, session+"/timeouts", `{"implicit": -1}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, value = do(http.MethodPost, session+"/element", `{"using": "tag name", "value": "p"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{webElementKey: "k1"}, value)
+	assert.Equal(t, 3, finds)
+	assert.Zero(t, executor.lastTask().Actions[0].Timeout, "a null script timeout leaves the action's default")
+
+	// A session GoScry closed, e.g. at its maximum lifetime, has ended
+	executor.CloseSession(executor.lastTask().Session)
+	code, _ = do(http.MethodGet, session+"/url", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestNewWebDriverSessionRequest_MatchCapabilities(t *testing.T) {
+	match := func(body string) (map[string]interface{}, error) {
+		var req NewWebDriverSessionRequest
+		require.NoError(t, json.NewDecoder(bytes.NewReader([]byte(body))).Decode(&req))
+		return req.matchCapabilities()
+	}
+
+	caps, err := match(`{"capabilities": {"alwaysMatch": {"acceptInsecureCerts": true}}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"acceptInsecureCerts": true}, caps)
+
+	caps, err = match(`{"capabilities": {"alwaysMatch": {"platformName": "linux"}, "firstMatch": [{"browserName": "safari"}, {"browserName": "chromium"}]}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"platformName": "linux", "browserName": "chromium"}, caps)
+
+	_, err = match(`{"capabilities": {"alwaysMatch": {"browserName": "firefox"}}}`)
+	assert.ErrorContains(t, err, "session not created")
+	_, err = match(`{"capabilities": {"alwaysMatch": {"browserName": "chrome"}, "firstMatch": [{"browserName": "chrome"}]}}`)
+	assert.ErrorContains(t, err, "invalid argument")
+
+	opts, err := wdSessionOptions(map[string]interface{}{"goscry:options": map[string]interface{}{"profile": "crm-sso", "max_lifetime": "1h"}})
+	require.NoError(t, err)
+	assert.Equal(t, taskstypes.SessionOptions{Profile: "crm-sso", MaxLifetime: time.Hour}, opts)
+	_, err = wdSessionOptions(map[string]interface{}{"goscry:options": map[string]interface{}{"login": map[string]interface{}{"template": "crm-login"}}})
+	assert.Error(t, err)
+}
+
+func TestWDTaskError(t *testing.T) {
+	for _, tt := range []struct {
+		result *taskstypes.TaskResult
+		code   string
+		status int
+	}{
+		{&taskstypes.TaskResult{Error: "Error: [webdriver:invalid selector] 'a[' is not a valid selector", ErrorCode: taskstypes.ErrorScriptError}, "invalid selector", http.StatusBadRequest},
+		{&taskstypes.TaskResult{Error: "ReferenceError: x is not defined", ErrorCode: taskstypes.ErrorScriptError}, "javascript error", http.StatusInternalServerError},
+		{&taskstypes.TaskResult{Error: "no element matches", ErrorCode: taskstypes.ErrorSelectorNotFound}, "no such element", http.StatusNotFound},
+		{&taskstypes.TaskResult{Error: "timed out", ErrorCode: taskstypes.ErrorNavigationTimeout}, "timeout", http.StatusInternalServerError},
+		{&taskstypes.TaskResult{Error: "net::ERR_NAME_NOT_RESOLVED", ErrorCode: taskstypes.ErrorNavigationFailed}, "unknown error", http.StatusInternalServerError},
+		{&taskstypes.TaskResult{Error: "context deadline exceeded", ErrorCode: taskstypes.ErrorDialogUnhandled}, "unexpected alert open", http.StatusInternalServerError},
+	} {
+		err := wdTaskError(tt.result)
+		assert.Equal(t, tt.code, err.code, tt.result.Error)
+		assert.Equal(t, tt.status, err.status(), tt.result.Error)
+	}
+}
@@ -0,0 +1,240 @@
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+)
+
+// archiveInterval is how often finished tasks are checked for results old
+// enough to archive.
+const archiveInterval = 5 * time.Minute
+
+// archivePageSize is how many tasks are loaded at a time while archiving.
+const archivePageSize = 500
+
+var (
+	// ErrArchiveDisabled is returned when loading archived data without storage.archive configured.
+	ErrArchiveDisabled = errors.New("result archive is not configured")
+	// ErrNotArchived is returned when loading archived data for a task whose result was never archived.
+	ErrNotArchived = errors.New("task result is not archived")
+)
+
+// resultArchive holds result Data moved out of the task store, one file per
+// task, sealed with the keyring when encryption is enabled.
+type resultArchive struct {
+	after   time.Duration
+	dir     string
+	keyring *encryption.Keyring
+
+	// scanned is, per status, the update time before which every task was
+	// archived or had nothing to archive, so later runs start there. Only
+	// archiveResults uses it.
+	scanned map[taskstypes.TaskStatus]time.Time
+}
+
+// newResultArchive returns nil when cfg does not enable archiving.
+func newResultArchive(cfg config.ArchiveConfig, keyring *encryption.Keyring) *resultArchive {
+	if cfg.After <= 0 {
+		return nil
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join("artifacts", "results")
+	}
+	return &resultArchive{after: cfg.After, dir: dir, keyring: keyring, scanned: make(map[taskstypes.TaskStatus]time.Time)}
+}
+
+func (a *resultArchive) path(id uuid.UUID, sealed bool) string {
+	if sealed {
+		return filepath.Join(a.dir, id.String()+".json.enc")
+	}
+	return filepath.Join(a.dir, id.String()+".json")
+}
+
+// write stores data for a task, sealed with tenant's key. The file is renamed
+// into place so a reader never sees a partial one.
+func (a *resultArchive) write(id uuid.UUID, tenant string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result data: %w", err)
+	}
+	sealed := a.keyring != nil
+	if sealed {
+		if raw, err = a.keyring.Seal(tenant, raw); err != nil {
+			return fmt.Errorf("failed to encrypt result data: %w", err)
+		}
+	}
+	if err := os.MkdirAll(a.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create result archive: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(a.dir, ".archive-*")
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+	return os.Rename(tmp.Name(), a.path(id, sealed))
+}
+
+// read loads the data archived for a task.
+func (a *resultArchive) read(id uuid.UUID) (json.RawMessage, error) {
+	raw, err := os.ReadFile(a.path(id, true))
+	if errors.Is(err, os.ErrNotExist) {
+		raw, err = os.ReadFile(a.path(id, false))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archived result for task %s: %w", id, err)
+		}
+		return raw, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archived result for task %s: %w", id, err)
+	}
+	if a.keyring == nil {
+		return nil, fmt.Errorf("archived result for task %s is encrypted but no keyring is configured", id)
+	}
+	plaintext, err := a.keyring.Open(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt archived result for task %s: %w", id, err)
+	}
+	return plaintext, nil
+}
+
+// archiveLoop archives old results every archiveInterval until the manager shuts down.
+func (m *Manager) archiveLoop() {
+	ticker := time.NewTicker(archiveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n := m.archiveResults(now); n > 0 {
+				m.logger.Info("Archived task result data", "tasks", n)
+			}
+		}
+	}
+}
+
+// archiveResults moves the Data of results that finished more than
+// storage.archive.after before now into the archive, leaving the summary in
+// the store, and returns how many were moved. Tasks are read oldest first, a
+// page at a time, starting where the last run left off.
+func (m *Manager) archiveResults(now time.Time) int {
+	cutoff := now.Add(-m.archive.after)
+	archived := 0
+	for _, status := range []taskstypes.TaskStatus{
+		taskstypes.StatusCompleted, taskstypes.StatusFailed, taskstypes.StatusCancelled,
+	} {
+		from := m.archive.scanned[status]
+		next := cutoff // Moved back to the first task that is left for a later run
+		for offset := 0; ; {
+			// Archiving leaves a task's update time alone, so pages do not shift
+			page, err := m.store.List(ListFilter{
+				Status: status, UpdatedSince: from, UpdatedBefore: cutoff, Oldest: true, Limit: archivePageSize, Offset: offset,
+			})
+			if err != nil {
+				m.logger.Error("Failed to load tasks for archiving", "status", status, "error", err)
+				next = from
+				break
+			}
+			for _, task := range page {
+				if task.Result == nil || task.Result.Data == nil {
+					continue
+				}
+				if task.CompletedAt != nil && task.CompletedAt.After(cutoff) {
+					next = earliest(next, task.UpdatedAt)
+					continue
+				}
+				if err := m.archive.write(task.ID, task.Tenant, task.Result.Data); err != nil {
+					m.logger.Error("Failed to archive task result", logging.TaskIDKey, task.ID, "error", err)
+					next = earliest(next, task.UpdatedAt)
+					continue
+				}
+				m.markArchived(task, now)
+				archived++
+			}
+			if len(page) < archivePageSize {
+				break
+			}
+			offset += len(page)
+		}
+		m.archive.scanned[status] = next
+	}
+	return archived
+}
+
+func earliest(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// markArchived drops a result's Data from the store, and from memory if this
+// process ran the task, once it is safely in the archive.
+func (m *Manager) markArchived(stored *taskstypes.Task, now time.Time) {
+	strip := func(task *taskstypes.Task) {
+		if task.Result == nil {
+			return
+		}
+		result := *task.Result
+		summary := result.Summary
+		if summary == nil {
+			summary = taskstypes.SummarizeData(result.Data)
+		}
+		archivedSummary := *summary
+		archivedSummary.Archived = true
+		archivedSummary.ArchivedAt = &now
+		result.Data = nil
+		result.Summary = &archivedSummary
+		task.Result = &result
+	}
+
+	m.mu.RLock()
+	live, exists := m.tasks[stored.ID]
+	m.mu.RUnlock()
+	if exists {
+		live.Update(strip)
+		m.persist(live)
+		return
+	}
+
+	strip(stored)
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+	if err := m.store.Save(stored); err != nil {
+		m.logger.Error("Failed to persist archived task", logging.TaskIDKey, stored.ID, "error", err)
+	}
+}
+
+// ArchivedResultData returns the result Data that was moved to the archive for a task.
+func (m *Manager) ArchivedResultData(id uuid.UUID) (json.RawMessage, error) {
+	if m.archive == nil {
+		return nil, ErrArchiveDisabled
+	}
+	task, err := m.GetTaskStatus(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Result == nil || task.Result.Summary == nil || !task.Result.Summary.Archived {
+		return nil, fmt.Errorf("%w: %s", ErrNotArchived, id)
+	}
+	return m.archive.read(id)
+}
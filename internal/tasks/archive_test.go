@@ -0,0 +1,115 @@
+package tasks
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_ArchiveResults(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	for name, encryption := range map[string]config.EncryptionConfig{
+		"plain":     {},
+		"encrypted": {Enabled: true, Keys: map[string]string{"default": base64.StdEncoding.EncodeToString(key)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := &config.Config{
+				Storage:  config.StorageConfig{Driver: "memory", Archive: config.ArchiveConfig{After: time.Hour, Dir: dir}},
+				Security: config.SecurityConfig{Encryption: encryption},
+			}
+			manager := NewManager(cfg, mocks.NewMockBrowserExecutor(), logging.Discard())
+			require.NotNil(t, manager.archive)
+
+			now := time.Now()
+			old, recent := now.Add(-2*time.Hour), now.Add(-time.Minute)
+			data := map[string]interface{}{"rows": []interface{}{"a", "b"}, "secret": "4111 1111 1111 1111"}
+			oldTask := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusCompleted, CreatedAt: old, CompletedAt: &old,
+				Result: &taskstypes.TaskResult{Success: true, Data: data}}
+			recentTask := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusCompleted, CreatedAt: recent, CompletedAt: &recent,
+				Result: &taskstypes.TaskResult{Success: true, Data: data}}
+			require.NoError(t, manager.store.Save(oldTask))
+			require.NoError(t, manager.store.Save(recentTask))
+
+			assert.Equal(t, 1, manager.archiveResults(now))
+			assert.Zero(t, manager.archiveResults(now), "already archived results are skipped")
+
+			stored, err := manager.GetTaskStatus(oldTask.ID)
+			require.NoError(t, err)
+			assert.Nil(t, stored.Result.Data)
+			require.NotNil(t, stored.Result.Summary)
+			assert.True(t, stored.Result.Summary.Archived)
+			assert.Equal(t, "object", stored.Result.Summary.DataType)
+			assert.Equal(t, 2, stored.Result.Summary.Items)
+
+			raw, err := manager.ArchivedResultData(oldTask.ID)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"rows": ["a", "b"], "secret": "4111 1111 1111 1111"}`, string(raw))
+
+			files, err := filepath.Glob(filepath.Join(dir, oldTask.ID.String()+"*"))
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			contents, err := os.ReadFile(files[0])
+			require.NoError(t, err)
+			if encryption.Enabled {
+				assert.NotContains(t, string(contents), "4111")
+			} else {
+				assert.JSONEq(t, string(raw), string(contents))
+			}
+
+			_, err = manager.ArchivedResultData(recentTask.ID)
+			assert.ErrorIs(t, err, ErrNotArchived)
+		})
+	}
+}
+
+func TestManager_ArchiveResultsPages(t *testing.T) {
+	cfg := &config.Config{Storage: config.StorageConfig{Driver: "memory", Archive: config.ArchiveConfig{After: time.Hour, Dir: t.TempDir()}}}
+	manager := NewManager(cfg, mocks.NewMockBrowserExecutor(), logging.Discard())
+
+	now := time.Now()
+	save := func(finished time.Time) *taskstypes.Task {
+		task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusCompleted, CreatedAt: finished, UpdatedAt: finished, CompletedAt: &finished,
+			Result: &taskstypes.TaskResult{Success: true, Data: "rows"}}
+		require.NoError(t, manager.store.Save(task))
+		return task
+	}
+	oldest := save(now.Add(-48 * time.Hour))
+	for i := 0; i < archivePageSize; i++ {
+		save(now.Add(-2*time.Hour + time.Duration(i)*time.Millisecond))
+	}
+	recent := save(now.Add(-time.Minute))
+
+	assert.Equal(t, archivePageSize+1, manager.archiveResults(now), "every page is read")
+	stored, err := manager.GetTaskStatus(oldest.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Result.Summary.Archived)
+
+	// The next run starts where this one stopped and picks up what has aged since
+	later := now.Add(2 * time.Hour)
+	assert.Equal(t, 1, manager.archiveResults(later))
+	stored, err = manager.GetTaskStatus(recent.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Result.Summary.Archived)
+}
+
+func TestManager_ArchiveDisabled(t *testing.T) {
+	manager := NewManager(&config.Config{}, mocks.NewMockBrowserExecutor(), logging.Discard())
+	assert.Nil(t, manager.archive)
+	_, err := manager.ArchivedResultData(uuid.New())
+	assert.ErrorIs(t, err, ErrArchiveDisabled)
+}
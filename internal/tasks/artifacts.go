@@ -0,0 +1,25 @@
+package tasks
+
+import (
+	"github.com/copyleftdev/goscry/internal/artifacts"
+)
+
+// openArtifacts opens the artifact store the executor saves task files to,
+// falling back to the local default if browser.artifacts is invalid. It
+// reads them through the keyring openStore set, as the executor seals them.
+func (m *Manager) openArtifacts() artifacts.Store {
+	if m.cfg == nil {
+		return artifacts.NewLocalStore("")
+	}
+	store, err := artifacts.New(m.cfg.Browser.Artifacts)
+	if err != nil {
+		m.logger.Error("Invalid browser.artifacts config, using the local store", "error", err)
+		store = artifacts.NewLocalStore(m.cfg.Browser.Artifacts.Dir)
+	}
+	return artifacts.Sealed(store, m.keyring)
+}
+
+// Artifacts returns the store holding the files tasks produced.
+func (m *Manager) Artifacts() artifacts.Store {
+	return m.artifacts
+}
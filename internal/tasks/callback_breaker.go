@@ -0,0 +1,265 @@
+package tasks
+
+import (
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/google/uuid"
+)
+
+// Callback endpoint breaker states reported in CallbackEndpoint.
+const (
+	EndpointClosed  = "closed"  // Deliveries are sent
+	EndpointOpen    = "open"    // Deliveries are held until a probe gets through
+	EndpointProbing = "probing" // One delivery is testing whether the endpoint recovered
+)
+
+const (
+	defaultBreakerCooldown    = 30 * time.Second
+	defaultBreakerMaxCooldown = 10 * time.Minute
+	defaultBreakerMaxHeld     = 1000
+)
+
+// CallbackEndpoint reports the breaker for one callback endpoint.
+type CallbackEndpoint struct {
+	Endpoint      string     `json:"endpoint"` // Scheme and host, e.g. "https://hooks.example"
+	State         string     `json:"state"`
+	Failures      int        `json:"failures"` // Failed attempts in a row
+	Held          int        `json:"held"`     // Deliveries waiting for the endpoint to recover
+	OpenUntil     *time.Time `json:"open_until,omitempty"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// callbackDelivery is one payload on its way to a callback URL. It keeps its
+// ID and attempt count while held, so receivers can still drop duplicates.
+type callbackDelivery struct {
+	id       string
+	seq      uint64 // Creation order, kept among held deliveries
+	taskID   uuid.UUID
+	event    string
+	url      string
+	payload  []byte
+	attempts int
+	logger   *slog.Logger
+}
+
+type endpointState struct {
+	failures      int
+	openUntil     time.Time     // Zero while closed
+	cooldown      time.Duration // Doubled after each failed probe
+	probing       bool
+	held          []*callbackDelivery // Oldest first
+	lastFailureAt time.Time
+	lastError     string
+}
+
+// callbackBreaker is a circuit breaker per callback endpoint. After too many
+// failed attempts in a row an endpoint is opened and its deliveries are held
+// instead of sent. Once the cooldown is over one delivery probes it; success
+// closes the breaker and releases the held deliveries, failure reopens it
+// for twice as long.
+type callbackBreaker struct {
+	mu          sync.Mutex
+	endpoints   map[string]*endpointState
+	failures    int // Zero turns the breaker off
+	cooldown    time.Duration
+	maxCooldown time.Duration
+	maxHeld     int
+	seq         uint64
+	now         func() time.Time
+}
+
+// newCallbackBreaker reads callback.breaker, filling in defaults.
+func newCallbackBreaker(cfg *config.Config) *callbackBreaker {
+	b := &callbackBreaker{
+		endpoints:   make(map[string]*endpointState),
+		cooldown:    defaultBreakerCooldown,
+		maxCooldown: defaultBreakerMaxCooldown,
+		maxHeld:     defaultBreakerMaxHeld,
+		now:         time.Now,
+	}
+	if cfg == nil {
+		return b
+	}
+	bc := cfg.Callback.Breaker
+	b.failures = max(bc.Failures, 0)
+	if bc.Cooldown > 0 {
+		b.cooldown = bc.Cooldown
+	}
+	if bc.MaxCooldown > 0 {
+		b.maxCooldown = bc.MaxCooldown
+	}
+	b.maxCooldown = max(b.maxCooldown, b.cooldown)
+	if bc.MaxHeld > 0 {
+		b.maxHeld = bc.MaxHeld
+	}
+	return b
+}
+
+// callbackEndpoint returns the scheme and host of a callback URL, which
+// share a breaker.
+func callbackEndpoint(callbackURL string) string {
+	u, err := url.Parse(callbackURL)
+	if err != nil || u.Host == "" {
+		return callbackURL
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// newDelivery numbers a delivery so it keeps its place if held.
+func (b *callbackBreaker) newDelivery(d *callbackDelivery) *callbackDelivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	d.seq = b.seq
+	return d
+}
+
+// admit reports whether a delivery may be sent to endpoint now, holding it
+// until the endpoint recovers if not and hold is set. The first delivery after a cooldown is
+// let through as the probe. When too many deliveries are held the oldest is
+// returned to be given up on.
+func (b *callbackBreaker) admit(endpoint string, d *callbackDelivery, hold bool) (send bool, dropped *callbackDelivery) {
+	if b.failures == 0 {
+		return true, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.endpoints[endpoint]
+	if state == nil || state.openUntil.IsZero() {
+		return true, nil
+	}
+	if !state.probing && !b.now().Before(state.openUntil) {
+		state.probing = true
+		return true, nil
+	}
+	if !hold {
+		return false, nil
+	}
+
+	i := sort.Search(len(state.held), func(i int) bool { return state.held[i].seq > d.seq })
+	state.held = append(state.held, nil)
+	copy(state.held[i+1:], state.held[i:])
+	state.held[i] = d
+	if len(state.held) > b.maxHeld {
+		dropped = state.held[0]
+		state.held = state.held[1:]
+	}
+	return false, dropped
+}
+
+// record counts an attempt. An attempt the endpoint answered, even with a
+// refusal, closes its breaker and returns the deliveries held for it; one
+// that failed may open the breaker, in which case record returns how long
+// until the endpoint should be probed.
+func (b *callbackBreaker) record(endpoint string, answered bool, err error) (released []*callbackDelivery, probeIn time.Duration) {
+	if b.failures == 0 {
+		return nil, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.endpoints[endpoint]
+	if answered {
+		if state == nil {
+			return nil, 0
+		}
+		released = state.held
+		delete(b.endpoints, endpoint)
+		return released, 0
+	}
+
+	if state == nil {
+		state = &endpointState{cooldown: b.cooldown}
+		b.endpoints[endpoint] = state
+	}
+	state.failures++
+	state.lastFailureAt = b.now()
+	if err != nil {
+		state.lastError = err.Error()
+	}
+	switch {
+	case state.probing:
+		state.probing = false
+		state.cooldown = min(state.cooldown*2, b.maxCooldown)
+	case state.openUntil.IsZero() && state.failures >= b.failures:
+	default:
+		return nil, 0
+	}
+	state.openUntil = b.now().Add(state.cooldown)
+	return nil, state.cooldown
+}
+
+// takeHeld removes and returns the oldest delivery held for endpoint, if any.
+func (b *callbackBreaker) takeHeld(endpoint string) *callbackDelivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.endpoints[endpoint]
+	if state == nil || len(state.held) == 0 {
+		return nil
+	}
+	d := state.held[0]
+	state.held = state.held[1:]
+	return d
+}
+
+// drain removes and returns every held delivery.
+func (b *callbackBreaker) drain() []*callbackDelivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var held []*callbackDelivery
+	for _, state := range b.endpoints {
+		held = append(held, state.held...)
+		state.held = nil
+	}
+	return held
+}
+
+// health reports every endpoint with recent failures, worst first.
+func (b *callbackBreaker) health() []CallbackEndpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	endpoints := make([]CallbackEndpoint, 0, len(b.endpoints))
+	for endpoint, state := range b.endpoints {
+		e := CallbackEndpoint{
+			Endpoint:  endpoint,
+			State:     EndpointClosed,
+			Failures:  state.failures,
+			Held:      len(state.held),
+			LastError: state.lastError,
+		}
+		if !state.lastFailureAt.IsZero() {
+			at := state.lastFailureAt
+			e.LastFailureAt = &at
+		}
+		if !state.openUntil.IsZero() {
+			e.State = EndpointOpen
+			if state.probing {
+				e.State = EndpointProbing
+			} else if state.openUntil.After(now) {
+				until := state.openUntil
+				e.OpenUntil = &until
+			}
+		}
+		endpoints = append(endpoints, e)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Failures != endpoints[j].Failures {
+			return endpoints[i].Failures > endpoints[j].Failures
+		}
+		return endpoints[i].Endpoint < endpoints[j].Endpoint
+	})
+	return endpoints
+}
+
+// CallbackEndpoints returns the breaker state of every callback endpoint
+// with recent failures, most failures first.
+func (m *Manager) CallbackEndpoints() []CallbackEndpoint {
+	return m.callbackBreaker.health()
+}
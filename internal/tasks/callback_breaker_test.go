@@ -0,0 +1,156 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackBreaker(t *testing.T) {
+	b := newCallbackBreaker(&config.Config{Callback: config.CallbackConfig{Breaker: config.CallbackBreakerConfig{
+		Failures: 2, Cooldown: time.Minute, MaxCooldown: 3 * time.Minute, MaxHeld: 2,
+	}}})
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	delivery := func() *callbackDelivery { return b.newDelivery(&callbackDelivery{id: uuid.NewString()}) }
+	down := errors.New("connection refused")
+
+	endpoint := callbackEndpoint("HTTPS://Hooks.Example/results?team=a")
+	assert.Equal(t, "https://hooks.example", endpoint)
+
+	_, probeIn := b.record(endpoint, false, down)
+	assert.Zero(t, probeIn)
+	_, probeIn = b.record(endpoint, false, down)
+	assert.Equal(t, time.Minute, probeIn, "the second failure in a row opens the breaker")
+
+	// Held deliveries keep their order, and the oldest is dropped past maxHeld
+	first, second, third := delivery(), delivery(), delivery()
+	for _, d := range []*callbackDelivery{second, third} {
+		send, dropped := b.admit(endpoint, d, true)
+		assert.False(t, send)
+		assert.Nil(t, dropped)
+	}
+	send, dropped := b.admit(endpoint, first, true)
+	assert.False(t, send)
+	assert.Same(t, first, dropped)
+	send, _ = b.admit(endpoint, delivery(), false)
+	assert.False(t, send)
+	send, _ = b.admit("https://other.example", delivery(), true)
+	assert.True(t, send, "other endpoints are unaffected")
+
+	// After the cooldown one delivery probes; a failure doubles the cooldown
+	now = now.Add(time.Minute)
+	probe := b.takeHeld(endpoint)
+	assert.Same(t, second, probe)
+	send, _ = b.admit(endpoint, probe, true)
+	assert.True(t, send)
+	fourth := delivery()
+	send, _ = b.admit(endpoint, fourth, true)
+	assert.False(t, send, "one probe at a time")
+	health := b.health()
+	require.Len(t, health, 1)
+	assert.Equal(t, EndpointProbing, health[0].State)
+	_, probeIn = b.record(endpoint, false, down)
+	assert.Equal(t, 2*time.Minute, probeIn)
+
+	health = b.health()
+	require.Len(t, health, 1)
+	assert.Equal(t, EndpointOpen, health[0].State)
+	assert.Equal(t, 3, health[0].Failures)
+	assert.Equal(t, 2, health[0].Held)
+	assert.Equal(t, "connection refused", health[0].LastError)
+
+	// A probe the endpoint answers closes the breaker and releases the rest
+	now = now.Add(2 * time.Minute)
+	send, _ = b.admit(endpoint, second, true)
+	assert.True(t, send)
+	released, _ := b.record(endpoint, true, nil)
+	assert.Equal(t, []*callbackDelivery{third, fourth}, released)
+	assert.Empty(t, b.health())
+
+	b = newCallbackBreaker(&config.Config{})
+	b.record(endpoint, false, down)
+	send, _ = b.admit(endpoint, delivery(), true)
+	assert.True(t, send, "the breaker is off without callback.breaker.failures")
+}
+
+func TestPostCallback_HoldsWhileEndpointFails(t *testing.T) {
+	var mu sync.Mutex
+	up := false
+	var delivered []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		delivered = append(delivered, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	manager := newCallbackManager(t, config.CallbackConfig{
+		MaxAttempts: 1,
+		Breaker:     config.CallbackBreakerConfig{Failures: 2, Cooldown: 100 * time.Millisecond},
+	})
+	task := &taskstypes.Task{ID: uuid.New(), CallbackURL: srv.URL + "/results"}
+	manager.postCallback(task, callbackEventResult, []byte(`"one"`))
+	manager.postCallback(task, callbackEventResult, []byte(`"two"`))
+	require.Len(t, manager.DeadLetters(), 2, "failures before the breaker opens are given up on as before")
+
+	manager.postCallback(task, callbackEventResult, []byte(`"three"`))
+	manager.postCallback(task, "network", []byte(`"four"`))
+	assert.Len(t, manager.DeadLetters(), 2, "deliveries to an open endpoint are held")
+	endpoints := manager.CallbackEndpoints()
+	require.Len(t, endpoints, 1)
+	assert.Equal(t, srv.URL, endpoints[0].Endpoint)
+	assert.Equal(t, EndpointOpen, endpoints[0].State)
+	assert.Equal(t, 2, endpoints[0].Held)
+
+	mu.Lock()
+	up = true
+	mu.Unlock()
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(delivered) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{`"three"`, `"four"`}, delivered, "held deliveries are sent in order once the probe succeeds")
+	assert.Empty(t, manager.CallbackEndpoints())
+	assert.Len(t, manager.DeadLetters(), 2)
+}
+
+func TestPostCallback_HeldAtShutdown(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	manager := newCallbackManager(t, config.CallbackConfig{
+		MaxAttempts: 1,
+		Breaker:     config.CallbackBreakerConfig{Failures: 1, Cooldown: time.Hour},
+	})
+	task := &taskstypes.Task{ID: uuid.New(), CallbackURL: srv.URL}
+	manager.postCallback(task, callbackEventResult, []byte(`{}`))
+	manager.postCallback(task, callbackEventResult, []byte(`{}`))
+	require.Len(t, manager.DeadLetters(), 1)
+
+	require.NoError(t, manager.Shutdown(context.Background()))
+	letters := manager.DeadLetters()
+	require.Len(t, letters, 2)
+	assert.Contains(t, letters[0].Error, "still failing at shutdown")
+	assert.Zero(t, letters[0].Attempts, "held deliveries keep their attempt count")
+}
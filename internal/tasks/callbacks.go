@@ -0,0 +1,600 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+)
+
+// Headers sent with every callback delivery.
+const (
+	CallbackDeliveryHeader  = "X-GoScry-Delivery"      // Same on every attempt, so receivers can drop retries they already handled
+	CallbackAttemptHeader   = "X-GoScry-Attempt"       // 1 for the first attempt
+	CallbackSignatureHeader = "X-GoScry-Signature-256" // "sha256=" and the hex HMAC-SHA256 of the body, when callback.secret is set
+)
+
+// callbackEventResult names the final notification of a task, as opposed to
+// the events streamed while it runs.
+const callbackEventResult = "result"
+
+const (
+	defaultCallbackAttempts   = 5
+	defaultCallbackBackoff    = time.Second
+	defaultCallbackMaxBackoff = time.Minute
+	defaultCallbackTimeout    = 10 * time.Second
+	maxDeadLetters            = 100 // Kept in memory for DeadLetters
+)
+
+// callbackClient is shared by task callbacks and streamed events. Each
+// attempt is bounded by callback.timeout.
+var callbackClient = &http.Client{CheckRedirect: checkCallbackRedirect}
+
+// callbackAuthKey carries a delivery's callbackAuth in its request context.
+type callbackAuthKey struct{}
+
+// checkCallbackRedirect follows redirects as the default client does, but
+// keeps callback.auth from following one to a host outside its list; the
+// default client only drops Authorization, not a custom header.
+func checkCallbackRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if auth, ok := req.Context().Value(callbackAuthKey{}).(callbackAuth); ok {
+		auth.apply(req)
+	}
+	return nil
+}
+
+// SignCallback returns the CallbackSignatureHeader value for a body signed
+// with secret.
+func SignCallback(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallback reports whether signature, a CallbackSignatureHeader value,
+// is body's signature with secret. Receivers should check it against the raw
+// body before parsing it.
+func VerifyCallback(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignCallback(secret, body)), []byte(signature))
+}
+
+// DeadLetter records a callback delivery that failed every attempt. The
+// payload is left out; the task can still be fetched from the API.
+type DeadLetter struct {
+	DeliveryID string    `json:"delivery_id"`
+	TaskID     string    `json:"task_id"`
+	Event      string    `json:"event"` // "result" for the final notification, or the streamed event
+	URL        string    `json:"url"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error"` // Why the last attempt failed
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// deadLetterLog keeps the most recent dead letters in memory and appends
+// every one to a file when configured.
+type deadLetterLog struct {
+	mu      sync.Mutex
+	entries []DeadLetter
+}
+
+func (l *deadLetterLog) add(entry DeadLetter, path string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > maxDeadLetters {
+		l.entries = l.entries[len(l.entries)-maxDeadLetters:]
+	}
+	if path == "" {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// DeadLetters returns the most recent callback deliveries that failed every
+// attempt, newest first.
+func (m *Manager) DeadLetters() []DeadLetter {
+	m.deadLetters.mu.Lock()
+	defer m.deadLetters.mu.Unlock()
+	entries := make([]DeadLetter, len(m.deadLetters.entries))
+	for i, entry := range m.deadLetters.entries {
+		entries[len(entries)-1-i] = entry
+	}
+	return entries
+}
+
+// ErrInvalidCallbackFields is returned for a task whose callback_fields
+// names something result callbacks do not have.
+var ErrInvalidCallbackFields = errors.New("invalid callback_fields")
+
+// callbackFieldNames are the top-level fields of a result callback.
+var callbackFieldNames = map[string]bool{
+	"id": true, "status": true, "result": true, "current_action": true, "actions": true, "two_factor_auth": true,
+	"tags": true, "reference_id": true, "artifacts": true, "created_at": true, "updated_at": true,
+}
+
+// callbackTrims are the parts of a result callback dropped, in order, until
+// it fits callback.maxPayloadBytes. "result.<name>" is a field of the result.
+var callbackTrims = []string{"actions", "result.custom_data", "result.data", "artifacts", "two_factor_auth", "result"}
+
+// ValidateCallbackFields checks a task's callback_fields.
+func ValidateCallbackFields(fields []string) error {
+	for _, field := range fields {
+		if !callbackFieldNames[field] {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidCallbackFields, field)
+		}
+	}
+	return nil
+}
+
+// shapeCallback applies the task's callback_fields, or callback.omitActions,
+// to a result callback payload, then trims it to callback.maxPayloadBytes.
+// A trimmed payload says so with "truncated" and lists what was dropped in
+// "omitted"; receivers can fetch the full task from the API.
+func (m *Manager) shapeCallback(task *taskstypes.Task, payload []byte) ([]byte, error) {
+	var cfg config.CallbackConfig
+	if m.cfg != nil {
+		cfg = m.cfg.Callback
+	}
+	if len(task.CallbackFields) == 0 && !cfg.OmitActions && (cfg.MaxPayloadBytes <= 0 || len(payload) <= cfg.MaxPayloadBytes) {
+		return payload, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	if len(task.CallbackFields) > 0 {
+		keep := map[string]bool{"id": true} // Receivers need to know which task it is
+		for _, field := range task.CallbackFields {
+			keep[field] = true
+		}
+		for name := range fields {
+			if !keep[name] {
+				delete(fields, name)
+			}
+		}
+	} else if cfg.OmitActions {
+		delete(fields, "actions")
+	}
+	payload, err := json.Marshal(fields)
+	if err != nil || cfg.MaxPayloadBytes <= 0 {
+		return payload, err
+	}
+
+	var omitted []string
+	for _, trim := range callbackTrims {
+		if len(payload) <= cfg.MaxPayloadBytes {
+			break
+		}
+		if !dropCallbackField(fields, trim) {
+			continue
+		}
+		omitted = append(omitted, trim)
+		fields["truncated"] = json.RawMessage("true")
+		fields["omitted"], _ = json.Marshal(omitted)
+		if payload, err = json.Marshal(fields); err != nil {
+			return nil, err
+		}
+	}
+	if len(payload) > cfg.MaxPayloadBytes {
+		m.logger.Warn("Callback is larger than callback.maxPayloadBytes even after trimming, sending it anyway",
+			logging.TaskIDKey, task.ID, "bytes", len(payload), "max_bytes", cfg.MaxPayloadBytes)
+	}
+	return payload, nil
+}
+
+// dropCallbackField removes a field, or a field of the result for
+// "result.<name>", and reports whether it was there.
+func dropCallbackField(fields map[string]json.RawMessage, name string) bool {
+	parent, child, nested := strings.Cut(name, ".")
+	if !nested {
+		_, ok := fields[name]
+		delete(fields, name)
+		return ok
+	}
+	var inner map[string]json.RawMessage
+	if err := json.Unmarshal(fields[parent], &inner); err != nil {
+		return false
+	}
+	if _, ok := inner[child]; !ok {
+		return false
+	}
+	delete(inner, child)
+	raw, err := json.Marshal(inner)
+	if err != nil {
+		return false
+	}
+	fields[parent] = raw
+	return true
+}
+
+// CallbackArtifact is a file a task produced, listed in its result callback
+// with a presigned URL so receivers can fetch it without a GoScry API key.
+type CallbackArtifact struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	URL         string    `json:"url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// callbackArtifacts presigns URLs for a task's artifacts when the store
+// supports it and callback.artifactURLExpiry is set. Artifacts that cannot
+// be listed or signed are left out and logged, so the callback still goes.
+func (m *Manager) callbackArtifacts(taskID uuid.UUID) []CallbackArtifact {
+	if m.cfg == nil || m.cfg.Callback.ArtifactURLExpiry <= 0 {
+		return nil
+	}
+	presigner, ok := m.artifacts.(artifacts.Presigner)
+	if !ok {
+		return nil
+	}
+	expiry := m.cfg.Callback.ArtifactURLExpiry
+
+	// callbackClient has no timeout of its own; bound listing as a delivery is
+	timeout := defaultCallbackTimeout
+	if m.cfg.Callback.Timeout > 0 {
+		timeout = m.cfg.Callback.Timeout
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, timeout)
+	defer cancel()
+	objects, err := m.artifacts.List(ctx, taskID)
+	if err != nil {
+		m.logger.Warn("Failed to list artifacts for callback", logging.TaskIDKey, taskID, "error", err)
+		return nil
+	}
+	list := make([]CallbackArtifact, 0, len(objects))
+	for _, obj := range objects {
+		link, expiresAt, err := presigner.PresignGet(taskID, obj.Name, expiry)
+		if err != nil {
+			m.logger.Warn("Failed to presign artifact URL for callback", logging.TaskIDKey, taskID, "artifact", obj.Name, "error", err)
+			continue
+		}
+		list = append(list, CallbackArtifact{
+			Name:        obj.Name,
+			Size:        obj.Size,
+			ContentType: obj.ContentType,
+			URL:         link,
+			ExpiresAt:   expiresAt,
+		})
+	}
+	return list
+}
+
+// Callback auth types for callback.auth.type.
+const (
+	CallbackAuthNone   = "none"
+	CallbackAuthBasic  = "basic"
+	CallbackAuthBearer = "bearer"
+	CallbackAuthHeader = "header"
+)
+
+// callbackAuth adds the configured credential to deliveries to its hosts.
+// The zero value sends none.
+type callbackAuth struct {
+	kind          string
+	hosts         []string
+	username      string
+	password      string
+	header, value string
+}
+
+// newCallbackAuth checks callback.auth, so a half-configured credential is
+// reported instead of silently sending deliveries without it.
+func newCallbackAuth(cfg config.CallbackAuthConfig) (callbackAuth, error) {
+	kind := strings.ToLower(strings.TrimSpace(cfg.Type))
+	if kind != "" && kind != CallbackAuthNone && len(cfg.Hosts) == 0 {
+		// Any API caller picks the callback URL, so an unrestricted credential would go to whoever asks
+		return callbackAuth{}, errors.New("hosts must list the callback hosts that receive the credential")
+	}
+	switch kind {
+	case "", CallbackAuthNone:
+		return callbackAuth{}, nil
+	case CallbackAuthBasic:
+		if cfg.Username == "" {
+			return callbackAuth{}, errors.New("basic auth needs a username")
+		}
+		return callbackAuth{kind: kind, hosts: cfg.Hosts, username: cfg.Username, password: cfg.Password}, nil
+	case CallbackAuthBearer:
+		if cfg.Token == "" {
+			return callbackAuth{}, errors.New("bearer auth needs a token")
+		}
+		return callbackAuth{kind: kind, hosts: cfg.Hosts, header: "Authorization", value: "Bearer " + cfg.Token}, nil
+	case CallbackAuthHeader:
+		name := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(cfg.Header))
+		switch {
+		case name == "" || strings.ContainsAny(name, " \t:\r\n"):
+			return callbackAuth{}, fmt.Errorf("header auth needs a valid header name, got %q", cfg.Header)
+		case name == "Content-Type" || strings.HasPrefix(name, "X-Goscry-"):
+			return callbackAuth{}, fmt.Errorf("header auth cannot replace the %s header", name)
+		case cfg.Value == "":
+			return callbackAuth{}, errors.New("header auth needs a value")
+		}
+		return callbackAuth{kind: kind, hosts: cfg.Hosts, header: name, value: cfg.Value}, nil
+	default:
+		return callbackAuth{}, fmt.Errorf("unknown type %q (want none, basic, bearer, or header)", cfg.Type)
+	}
+}
+
+// apply adds the credential to a request for one of the auth's hosts, and
+// removes it from a request to any other host.
+func (a callbackAuth) apply(req *http.Request) {
+	allowed := taskstypes.MatchesDomain(strings.ToLower(req.URL.Hostname()), a.hosts)
+	switch {
+	case a.kind == CallbackAuthBasic && allowed:
+		req.SetBasicAuth(a.username, a.password)
+	case a.kind == CallbackAuthBasic:
+		req.Header.Del("Authorization")
+	case a.header != "" && allowed:
+		req.Header.Set(a.header, a.value)
+	case a.header != "":
+		req.Header.Del(a.header)
+	}
+}
+
+// callbackPolicy is callback config with defaults filled in.
+type callbackPolicy struct {
+	attempts       int
+	backoff        time.Duration
+	maxBackoff     time.Duration
+	secret         string
+	deadLetterFile string
+	timeout        time.Duration
+	auth           callbackAuth
+}
+
+// callbackPolicy returns the delivery settings, or an error when
+// callback.auth is invalid.
+func (m *Manager) callbackPolicy() (callbackPolicy, error) {
+	p := callbackPolicy{attempts: defaultCallbackAttempts, backoff: defaultCallbackBackoff, maxBackoff: defaultCallbackMaxBackoff, timeout: defaultCallbackTimeout}
+	if m.cfg == nil {
+		return p, nil
+	}
+	cfg := m.cfg.Callback
+	if cfg.MaxAttempts > 0 {
+		p.attempts = cfg.MaxAttempts
+	}
+	if cfg.InitialBackoff > 0 {
+		p.backoff = cfg.InitialBackoff
+	}
+	if cfg.MaxBackoff > 0 {
+		p.maxBackoff = cfg.MaxBackoff
+	}
+	if cfg.Timeout > 0 {
+		p.timeout = cfg.Timeout
+	}
+	p.secret = cfg.Secret
+	p.deadLetterFile = cfg.DeadLetterFile
+	auth, err := newCallbackAuth(cfg.Auth)
+	if err != nil {
+		return p, fmt.Errorf("invalid callback.auth: %w", err)
+	}
+	p.auth = auth
+	return p, nil
+}
+
+// wait returns how long to wait before retrying after the given attempt:
+// the backoff doubled per earlier retry, or the receiver's Retry-After if
+// longer, both capped at maxBackoff.
+func (p callbackPolicy) wait(attempt int, retryAfter time.Duration) time.Duration {
+	wait := p.backoff
+	for i := 1; i < attempt && wait < p.maxBackoff; i++ {
+		wait *= 2
+	}
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	return min(wait, p.maxBackoff)
+}
+
+// postCallback delivers a JSON payload to the task's callback URL, retrying
+// with backoff. While the endpoint's breaker is open the delivery is held
+// and sent once it recovers. Deliveries that fail every attempt, or are
+// refused outright, go to the dead-letter log, as do all deliveries while
+// callback.auth is invalid. Retries stop when the manager shuts down.
+func (m *Manager) postCallback(task *taskstypes.Task, event string, payload []byte) {
+	m.postCallbackTo(task, task.CallbackURL, event, payload)
+}
+
+// postCallbackTo is postCallback for a URL other than the task's callback URL.
+func (m *Manager) postCallbackTo(task *taskstypes.Task, callbackURL, event string, payload []byte) {
+	deliveryID := uuid.NewString()
+	m.runDelivery(m.callbackBreaker.newDelivery(&callbackDelivery{
+		id:      deliveryID,
+		taskID:  task.ID,
+		event:   event,
+		url:     callbackURL,
+		payload: payload,
+		logger:  m.logger.With(logging.TaskIDKey, task.ID, "event", event, "url", callbackURL, "delivery_id", deliveryID),
+	}))
+}
+
+// runDelivery makes up to callback.maxAttempts attempts at a delivery,
+// sending it to the dead-letter log if none succeeds. It returns early if
+// the delivery is held.
+func (m *Manager) runDelivery(d *callbackDelivery) {
+	policy, err := m.callbackPolicy()
+	if err == nil {
+		var held bool
+		held, err = m.deliverCallback(d, policy)
+		if err == nil || held {
+			return
+		}
+	}
+	m.deadLetter(d, err, policy.deadLetterFile)
+}
+
+// deadLetter gives up on a delivery.
+func (m *Manager) deadLetter(d *callbackDelivery, err error, file string) {
+	d.logger.Error("Callback delivery failed, giving up", "attempts", d.attempts, "error", err)
+	entry := DeadLetter{
+		DeliveryID: d.id,
+		TaskID:     d.taskID.String(),
+		Event:      d.event,
+		URL:        d.url,
+		Attempts:   d.attempts,
+		Error:      err.Error(),
+		FailedAt:   time.Now().UTC(),
+	}
+	if err := m.deadLetters.add(entry, file); err != nil {
+		d.logger.Error("Failed to write dead letter", "file", file, "error", err)
+	}
+}
+
+// deliverCallback runs the attempts for one delivery, returning the last
+// error if none succeeded, or held if the endpoint's breaker is open and the
+// delivery now waits for it to recover.
+func (m *Manager) deliverCallback(d *callbackDelivery, policy callbackPolicy) (held bool, err error) {
+	endpoint := callbackEndpoint(d.url)
+	for round := 1; ; round++ {
+		stopping := m.ctx.Err() != nil
+		send, dropped := m.callbackBreaker.admit(endpoint, d, !stopping)
+		if dropped != nil {
+			m.deadLetter(dropped, fmt.Errorf("more than %d deliveries held for %s", m.callbackBreaker.maxHeld, endpoint), policy.deadLetterFile)
+		}
+		if !send && stopping {
+			return false, fmt.Errorf("%s was still failing at shutdown", endpoint)
+		}
+		if !send {
+			d.logger.Info("Callback endpoint is failing, holding delivery", "endpoint", endpoint)
+			return true, nil
+		}
+
+		d.attempts++
+		var retry bool
+		var retryAfter time.Duration
+		retry, retryAfter, err = m.sendCallback(d.url, d.payload, d.id, d.attempts, policy)
+		m.recordCallback(endpoint, err == nil || !retry, err)
+		if err == nil {
+			d.logger.Info("Callback delivered", "attempt", d.attempts)
+			return false, nil
+		}
+		if !retry || round >= policy.attempts {
+			return false, err
+		}
+
+		wait := policy.wait(round, retryAfter)
+		d.logger.Warn("Callback delivery failed, retrying", "attempt", d.attempts, "retry_in", wait, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-m.ctx.Done():
+			timer.Stop()
+			return false, fmt.Errorf("%w (retries stopped by shutdown)", err)
+		}
+	}
+}
+
+// recordCallback feeds an attempt's outcome to the endpoint's breaker. When
+// the breaker opens a probe is scheduled for the end of the cooldown; when
+// it closes the deliveries held for the endpoint are sent, oldest first.
+func (m *Manager) recordCallback(endpoint string, answered bool, err error) {
+	released, probeIn := m.callbackBreaker.record(endpoint, answered, err)
+	if probeIn > 0 {
+		m.logger.Warn("Callback endpoint is failing, holding its deliveries", "endpoint", endpoint, "probe_in", probeIn, "error", err)
+		time.AfterFunc(probeIn, func() {
+			if m.ctx.Err() != nil {
+				return
+			}
+			if d := m.callbackBreaker.takeHeld(endpoint); d != nil {
+				m.runDelivery(d)
+			}
+		})
+	}
+	if len(released) > 0 {
+		m.logger.Info("Callback endpoint recovered, sending held deliveries", "endpoint", endpoint, "held", len(released))
+		go func() {
+			for _, d := range released {
+				m.runDelivery(d)
+			}
+		}()
+	}
+}
+
+// dropHeldCallbacks sends deliveries still held at shutdown to the
+// dead-letter log.
+func (m *Manager) dropHeldCallbacks() {
+	policy, _ := m.callbackPolicy()
+	for _, d := range m.callbackBreaker.drain() {
+		m.deadLetter(d, fmt.Errorf("%s was still failing at shutdown", callbackEndpoint(d.url)), policy.deadLetterFile)
+	}
+}
+
+// sendCallback makes one delivery attempt. It reports whether a failure is
+// worth retrying, and for how long the receiver asked to be left alone.
+func (m *Manager) sendCallback(callbackURL string, payload []byte, deliveryID string, attempt int, policy callbackPolicy) (retry bool, retryAfter time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), callbackAuthKey{}, policy.auth), policy.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(payload))
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to create callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackDeliveryHeader, deliveryID)
+	req.Header.Set(CallbackAttemptHeader, strconv.Itoa(attempt))
+	if policy.secret != "" {
+		req.Header.Set(CallbackSignatureHeader, SignCallback(policy.secret, payload))
+	}
+	policy.auth.apply(req)
+
+	resp, err := callbackClient.Do(req)
+	if err != nil {
+		return true, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, 0, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("callback returned %s", resp.Status)
+	}
+	return false, 0, fmt.Errorf("callback rejected with %s", resp.Status)
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,350 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCallbackManager(t *testing.T, cfg config.CallbackConfig) *Manager {
+	manager := NewManager(&config.Config{Callback: cfg}, mocks.NewMockBrowserExecutor(), logging.Discard())
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+	return manager
+}
+
+func TestPostCallback_RetriesAndSigns(t *testing.T) {
+	var mu sync.Mutex
+	var deliveries, attempts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.True(t, VerifyCallback("s3cret", body, r.Header.Get(CallbackSignatureHeader)))
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries = append(deliveries, r.Header.Get(CallbackDeliveryHeader))
+		attempts = append(attempts, r.Header.Get(CallbackAttemptHeader))
+		switch len(attempts) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	manager := newCallbackManager(t, config.CallbackConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, Secret: "s3cret"})
+	task := &taskstypes.Task{ID: uuid.New(), CallbackURL: srv.URL}
+	manager.postCallback(task, callbackEventResult, []byte(`{"status":"completed"}`))
+
+	assert.Equal(t, []string{"1", "2", "3"}, attempts)
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, deliveries[0], deliveries[2], "retries keep the delivery ID")
+	assert.Empty(t, manager.DeadLetters())
+}
+
+func TestPostCallback_DeadLetters(t *testing.T) {
+	status := http.StatusBadGateway
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "dead.jsonl")
+	manager := newCallbackManager(t, config.CallbackConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond, DeadLetterFile: file})
+	task := &taskstypes.Task{ID: uuid.New(), CallbackURL: srv.URL}
+
+	manager.postCallback(task, callbackEventResult, []byte(`{}`))
+	assert.Equal(t, 2, calls)
+
+	// Refusals other than overload or timeouts are not retried
+	status = http.StatusGone
+	manager.postCallback(task, "network", []byte(`{}`))
+	assert.Equal(t, 3, calls)
+
+	letters := manager.DeadLetters()
+	require.Len(t, letters, 2)
+	assert.Equal(t, "network", letters[0].Event, "newest first")
+	assert.Equal(t, 1, letters[0].Attempts)
+	assert.Contains(t, letters[0].Error, "410")
+	assert.Equal(t, 2, letters[1].Attempts)
+	assert.Equal(t, task.ID.String(), letters[1].TaskID)
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var logged []DeadLetter
+	for decoder.More() {
+		var letter DeadLetter
+		require.NoError(t, decoder.Decode(&letter))
+		logged = append(logged, letter)
+	}
+	require.Len(t, logged, 2)
+	assert.Equal(t, letters[1].DeliveryID, logged[0].DeliveryID)
+}
+
+func TestPostCallback_StopsOnShutdown(t *testing.T) {
+	called := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		called <- struct{}{}
+	}))
+	defer srv.Close()
+
+	manager := newCallbackManager(t, config.CallbackConfig{MaxAttempts: 10, InitialBackoff: time.Hour})
+	done := make(chan struct{})
+	go func() {
+		manager.postCallback(&taskstypes.Task{ID: uuid.New(), CallbackURL: srv.URL}, callbackEventResult, []byte(`{}`))
+		close(done)
+	}()
+	<-called
+	require.NoError(t, manager.Shutdown(context.Background()))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("retries did not stop on shutdown")
+	}
+	letters := manager.DeadLetters()
+	require.Len(t, letters, 1)
+	assert.Contains(t, letters[0].Error, "shutdown")
+}
+
+func TestPostCallback_Auth(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	task := &taskstypes.Task{ID: uuid.New(), CallbackURL: srv.URL}
+
+	tests := []struct {
+		name   string
+		auth   config.CallbackAuthConfig
+		header string
+		want   string
+	}{
+		{"none", config.CallbackAuthConfig{}, "Authorization", ""},
+		{"basic", config.CallbackAuthConfig{Type: "basic", Hosts: []string{"127.0.0.1"}, Username: "hooks", Password: "pw"}, "Authorization", "Basic aG9va3M6cHc="},
+		{"bearer", config.CallbackAuthConfig{Type: "Bearer", Hosts: []string{"127.0.0.1"}, Token: "t0ken"}, "Authorization", "Bearer t0ken"},
+		{"header", config.CallbackAuthConfig{Type: "header", Hosts: []string{"127.0.0.1"}, Header: "x-api-key", Value: "k3y"}, "X-Api-Key", "k3y"},
+		{"unlisted host", config.CallbackAuthConfig{Type: "bearer", Hosts: []string{"hooks.example.com"}, Token: "t0ken"}, "Authorization", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			manager := newCallbackManager(t, config.CallbackConfig{Auth: tt.auth})
+			manager.postCallback(task, callbackEventResult, []byte(`{}`))
+			require.NotNil(t, got)
+			assert.Equal(t, tt.want, got.Get(tt.header))
+			assert.Empty(t, manager.DeadLetters())
+		})
+	}
+}
+
+func TestPostCallback_InvalidAuth(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+
+	manager := newCallbackManager(t, config.CallbackConfig{Auth: config.CallbackAuthConfig{Type: "bearer"}})
+	manager.postCallback(&taskstypes.Task{ID: uuid.New(), CallbackURL: srv.URL}, callbackEventResult, []byte(`{}`))
+
+	assert.Zero(t, calls, "nothing is sent without the configured credential")
+	letters := manager.DeadLetters()
+	require.Len(t, letters, 1)
+	assert.Zero(t, letters[0].Attempts)
+	assert.Contains(t, letters[0].Error, "callback.auth")
+}
+
+func TestPostCallback_AuthNotRedirected(t *testing.T) {
+	var got http.Header
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer other.Close()
+	// localhost and 127.0.0.1 are different hosts to the allowlist
+	target := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	manager := newCallbackManager(t, config.CallbackConfig{Auth: config.CallbackAuthConfig{
+		Type: "header", Hosts: []string{"127.0.0.1"}, Header: "X-Api-Key", Value: "k3y",
+	}})
+	manager.postCallback(&taskstypes.Task{ID: uuid.New(), CallbackURL: srv.URL}, callbackEventResult, []byte(`{}`))
+
+	require.NotNil(t, got)
+	assert.Empty(t, got.Get("X-Api-Key"))
+	assert.Empty(t, manager.DeadLetters())
+}
+
+func TestNewCallbackAuth(t *testing.T) {
+	for _, cfg := range []config.CallbackAuthConfig{
+		{Type: "digest", Hosts: []string{"hooks.example.com"}},
+		{Type: "bearer", Token: "t0ken"},
+		{Type: "basic", Hosts: []string{"hooks.example.com"}, Password: "pw"},
+		{Type: "header", Hosts: []string{"hooks.example.com"}, Header: "X-Api-Key"},
+		{Type: "header", Hosts: []string{"hooks.example.com"}, Header: "Bad Header", Value: "v"},
+		{Type: "header", Hosts: []string{"hooks.example.com"}, Header: "Content-Type", Value: "text/plain"},
+		{Type: "header", Hosts: []string{"hooks.example.com"}, Header: CallbackSignatureHeader, Value: "forged"},
+	} {
+		_, err := newCallbackAuth(cfg)
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
+
+func TestCallbackPolicy_Wait(t *testing.T) {
+	p := callbackPolicy{backoff: time.Second, maxBackoff: 10 * time.Second}
+	var waits []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		waits = append(waits, p.wait(attempt, 0))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second}, waits)
+	assert.Equal(t, 5*time.Second, p.wait(1, 5*time.Second), "Retry-After wins when longer")
+	assert.Equal(t, 10*time.Second, p.wait(1, time.Hour), "but is capped")
+
+	assert.Equal(t, 30*time.Second, parseRetryAfter(strconv.Itoa(30)))
+	assert.Zero(t, parseRetryAfter("soon"))
+}
+
+// presigningStore is a local store that also signs download URLs.
+type presigningStore struct {
+	*artifacts.LocalStore
+}
+
+// List fails on a done context, as a remote store would.
+func (s presigningStore) List(ctx context.Context, taskID uuid.UUID) ([]artifacts.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.LocalStore.List(ctx, taskID)
+}
+
+func (s presigningStore) PresignGet(taskID uuid.UUID, name string, expires time.Duration) (string, time.Time, error) {
+	return "https://files.example/" + taskID.String() + "/" + name + "?expires=" + expires.String(), time.Now().Add(expires), nil
+}
+
+func TestNotifyCallback_ArtifactURLs(t *testing.T) {
+	bodies := make(chan []byte, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	manager := newCallbackManager(t, config.CallbackConfig{ArtifactURLExpiry: time.Hour})
+	local := artifacts.NewLocalStore(t.TempDir())
+	task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusCompleted, CallbackURL: srv.URL}
+	_, err := local.Put(context.Background(), task.ID, "screenshot-1.png", strings.NewReader("png"))
+	require.NoError(t, err)
+
+	var payload struct {
+		Artifacts []CallbackArtifact `json:"artifacts"`
+	}
+	manager.artifacts = local
+	manager.notifyCallback(task)
+	require.NoError(t, json.Unmarshal(<-bodies, &payload))
+	assert.Empty(t, payload.Artifacts, "stores that cannot presign list nothing")
+
+	manager.artifacts = presigningStore{local}
+	manager.notifyCallback(task)
+	require.NoError(t, json.Unmarshal(<-bodies, &payload))
+	require.Len(t, payload.Artifacts, 1)
+	got := payload.Artifacts[0]
+	assert.Equal(t, "screenshot-1.png", got.Name)
+	assert.Equal(t, int64(3), got.Size)
+	assert.Equal(t, "image/png", got.ContentType)
+	assert.Equal(t, "https://files.example/"+task.ID.String()+"/screenshot-1.png?expires=1h0m0s", got.URL)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), got.ExpiresAt, time.Minute)
+}
+
+func TestShapeCallback(t *testing.T) {
+	task := &taskstypes.Task{
+		ID:      uuid.New(),
+		Status:  taskstypes.StatusCompleted,
+		Actions: []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com/" + strings.Repeat("a", 500)}},
+		Result: &taskstypes.TaskResult{
+			Success:    true,
+			Data:       strings.Repeat("x", 500),
+			CustomData: map[string]interface{}{"network": strings.Repeat("n", 500)},
+			Summary:    &taskstypes.ResultSummary{DataType: "string", DataBytes: 502},
+		},
+		ReferenceID: "job-7",
+	}
+	shape := func(cfg config.CallbackConfig, task *taskstypes.Task) map[string]interface{} {
+		manager := newCallbackManager(t, cfg)
+		payload, err := json.Marshal(map[string]interface{}{
+			"id": task.ID.String(), "status": task.Status, "result": task.Result,
+			"actions": task.Actions, "reference_id": task.ReferenceID,
+		})
+		require.NoError(t, err)
+		payload, err = manager.shapeCallback(task, payload)
+		require.NoError(t, err)
+		if cfg.MaxPayloadBytes > 0 {
+			assert.LessOrEqual(t, len(payload), cfg.MaxPayloadBytes)
+		}
+		var got map[string]interface{}
+		require.NoError(t, json.Unmarshal(payload, &got))
+		return got
+	}
+
+	got := shape(config.CallbackConfig{}, task)
+	assert.Contains(t, got, "actions")
+	assert.NotContains(t, got, "truncated")
+	assert.NotContains(t, shape(config.CallbackConfig{OmitActions: true}, task), "actions")
+
+	selected := task.Snapshot()
+	selected.CallbackFields = []string{"status", "actions"}
+	got = shape(config.CallbackConfig{OmitActions: true}, selected)
+	assert.ElementsMatch(t, []string{"id", "status", "actions"}, keys(got), "callback_fields overrides omitActions")
+
+	// The bulkiest parts go first, and the receiver is told what is missing
+	got = shape(config.CallbackConfig{MaxPayloadBytes: 900}, task)
+	assert.Equal(t, true, got["truncated"])
+	assert.Equal(t, []interface{}{"actions", "result.custom_data"}, got["omitted"])
+	result := got["result"].(map[string]interface{})
+	assert.Contains(t, result, "data")
+	assert.Contains(t, result, "summary")
+
+	got = shape(config.CallbackConfig{MaxPayloadBytes: 300}, task)
+	assert.Equal(t, []interface{}{"actions", "result.custom_data", "result.data"}, got["omitted"])
+	assert.Equal(t, "job-7", got["reference_id"])
+
+	assert.NoError(t, ValidateCallbackFields([]string{"status", "result", "artifacts"}))
+	assert.ErrorIs(t, ValidateCallbackFields([]string{"status", "credentials"}), ErrInvalidCallbackFields)
+}
+
+func keys(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,255 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// Canary arms a template run can be assigned to, as set in Task.Canary.
+const (
+	CanaryBaseline  = "baseline"
+	CanaryCandidate = "candidate"
+)
+
+// Canary states.
+const (
+	CanaryRunning  = "running"
+	CanaryPromoted = "promoted"
+	CanaryRejected = "rejected"
+)
+
+var (
+	// ErrCanaryRunning is returned when a template is changed while a canary of it runs.
+	ErrCanaryRunning = errors.New("template canary is running")
+	// ErrNoCanary is returned when a template has no canary, or none running.
+	ErrNoCanary = errors.New("template has no canary")
+)
+
+// CanaryArm counts the runs of one template version during a canary.
+type CanaryArm struct {
+	Version     int     `json:"version"`
+	InFlight    int     `json:"in_flight"`
+	Completed   int     `json:"completed"`
+	Failed      int     `json:"failed"`
+	SuccessRate float64 `json:"success_rate"` // Of finished runs; cancelled runs are not counted
+}
+
+func (a *CanaryArm) finished() int {
+	return a.Completed + a.Failed
+}
+
+// TemplateCanary tries a new template version before it is put in use.
+// Runs that do not pin a version alternate between the candidate and the
+// baseline, the version in use before, until each has finished Runs runs.
+// Each run goes to one version only, so actions with side effects are not
+// done twice. The candidate is then promoted if it succeeded at least as
+// often as the baseline, or rejected, which restores the baseline as a new
+// version.
+type TemplateCanary struct {
+	Name      string     `json:"name"`
+	Runs      int        `json:"runs"` // Finished runs of each version to compare
+	State     string     `json:"state"`
+	Baseline  CanaryArm  `json:"baseline"`
+	Candidate CanaryArm  `json:"candidate"`
+	Reason    string     `json:"reason,omitempty"` // Why it was promoted or rejected
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+
+	nextCandidate bool // Whether the next unpinned run goes to the candidate
+}
+
+// PutCanary stores a new version of the named template as a canary
+// candidate, to be compared with the version in use over runs runs of each.
+func (s *TemplateStore) PutCanary(name, description string, actions []taskstypes.Action, runs int) (*Template, error) {
+	if runs <= 0 {
+		return nil, fmt.Errorf("canary of template %s needs at least one run", name)
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("template %s must contain at least one action", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.canaryRunningLocked(name) {
+		return nil, fmt.Errorf("%w: %s", ErrCanaryRunning, name)
+	}
+	baseline, err := s.getLocked(name, 0)
+	if err != nil {
+		return nil, fmt.Errorf("template %s has no version to compare a canary with", name)
+	}
+	tmpl, err := s.appendLocked(name, description, actions, 0)
+	if err != nil {
+		return nil, err
+	}
+	s.canaries[name] = &TemplateCanary{
+		Name:      name,
+		Runs:      runs,
+		State:     CanaryRunning,
+		Baseline:  CanaryArm{Version: baseline.Version},
+		Candidate: CanaryArm{Version: tmpl.Version},
+		StartedAt: tmpl.CreatedAt,
+	}
+	return tmpl, nil
+}
+
+// ForRun returns the template version a new run should use, and its canary
+// arm, if any. A pinned version, or a template without a running canary,
+// runs as Get would return it.
+func (s *TemplateStore) ForRun(name string, version int) (*Template, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	canary := s.canaries[name]
+	if version != 0 || canary == nil || canary.State != CanaryRunning {
+		tmpl, err := s.getLocked(name, version)
+		return tmpl, "", err
+	}
+	// Once enough candidate runs are under way, the rest use the baseline
+	arm, armVersion := CanaryBaseline, canary.Baseline.Version
+	if canary.nextCandidate && canary.Candidate.InFlight+canary.Candidate.finished() < canary.Runs {
+		arm, armVersion = CanaryCandidate, canary.Candidate.Version
+	}
+	canary.nextCandidate = !canary.nextCandidate
+	tmpl, err := s.getLocked(name, armVersion)
+	return tmpl, arm, err
+}
+
+// Canary returns the latest canary of a template.
+func (s *TemplateStore) Canary(name string) (*TemplateCanary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.getLocked(name, 0); err != nil {
+		return nil, err
+	}
+	canary := s.canaries[name]
+	if canary == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoCanary, name)
+	}
+	snapshot := *canary
+	return &snapshot, nil
+}
+
+// EndCanary promotes or rejects the running canary of a template without
+// waiting for its runs.
+func (s *TemplateStore) EndCanary(name string, promote bool) (*TemplateCanary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.canaryRunningLocked(name) {
+		return nil, fmt.Errorf("%w running: %s", ErrNoCanary, name)
+	}
+	canary := s.canaries[name]
+	if promote {
+		s.endCanaryLocked(canary, CanaryPromoted, "promoted by hand")
+	} else {
+		s.endCanaryLocked(canary, CanaryRejected, "rejected by hand")
+	}
+	snapshot := *canary
+	return &snapshot, nil
+}
+
+// startCanaryRun counts a submitted task towards its canary arm.
+func (s *TemplateStore) startCanaryRun(task *taskstypes.Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if arm := s.canaryArmLocked(task); arm != nil {
+		arm.InFlight++
+	}
+}
+
+// finishCanaryRun counts a finished task towards its canary arm, and
+// decides the canary once both arms have finished enough runs. It returns
+// the canary when this run decided it.
+func (s *TemplateStore) finishCanaryRun(task *taskstypes.Task) *TemplateCanary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	arm := s.canaryArmLocked(task)
+	if arm == nil {
+		return nil
+	}
+	arm.InFlight--
+	switch task.Status {
+	case taskstypes.StatusCompleted:
+		arm.Completed++
+	case taskstypes.StatusFailed:
+		arm.Failed++
+	}
+	if n := arm.finished(); n > 0 {
+		arm.SuccessRate = float64(arm.Completed) / float64(n)
+	}
+
+	canary := s.canaries[task.TemplateName]
+	if canary.Candidate.finished() < canary.Runs || canary.Baseline.finished() < canary.Runs {
+		return nil
+	}
+	reason := fmt.Sprintf("candidate succeeded in %d of %d runs, baseline in %d of %d",
+		canary.Candidate.Completed, canary.Candidate.finished(), canary.Baseline.Completed, canary.Baseline.finished())
+	if canary.Candidate.SuccessRate >= canary.Baseline.SuccessRate {
+		s.endCanaryLocked(canary, CanaryPromoted, reason)
+	} else {
+		s.endCanaryLocked(canary, CanaryRejected, reason)
+	}
+	snapshot := *canary
+	return &snapshot
+}
+
+// canaryArmLocked returns the arm of the running canary task was assigned
+// to, or nil.
+func (s *TemplateStore) canaryArmLocked(task *taskstypes.Task) *CanaryArm {
+	canary := s.canaries[task.TemplateName]
+	if canary == nil || canary.State != CanaryRunning {
+		return nil
+	}
+	var arm *CanaryArm
+	switch task.Canary {
+	case CanaryBaseline:
+		arm = &canary.Baseline
+	case CanaryCandidate:
+		arm = &canary.Candidate
+	default:
+		return nil
+	}
+	// A run of an earlier canary of the same template
+	if arm.Version != task.TemplateVersion {
+		return nil
+	}
+	return arm
+}
+
+// endCanaryLocked finishes a canary. A rejected candidate is replaced by a
+// new version with the baseline's content, as a rollback would.
+func (s *TemplateStore) endCanaryLocked(canary *TemplateCanary, state, reason string) {
+	now := time.Now().UTC()
+	canary.State = state
+	canary.Reason = reason
+	canary.EndedAt = &now
+	if state == CanaryRejected {
+		baseline := s.versions[canary.Name][canary.Baseline.Version-1]
+		if _, err := s.appendLocked(canary.Name, baseline.Description, baseline.Actions, baseline.Version); err != nil {
+			canary.Reason += fmt.Sprintf("; restoring the baseline failed, roll back to version %d: %v", baseline.Version, err)
+		}
+	}
+}
+
+func (s *TemplateStore) canaryRunningLocked(name string) bool {
+	canary := s.canaries[name]
+	return canary != nil && canary.State == CanaryRunning
+}
+
+// recordCanary counts a finished template run towards its canary, and logs
+// the outcome once the canary is decided.
+func (m *Manager) recordCanary(task *taskstypes.Task) {
+	snapshot := task.Snapshot()
+	if snapshot.Canary == "" {
+		return
+	}
+	if canary := m.templates.finishCanaryRun(snapshot); canary != nil {
+		m.logger.Info("Template canary decided", "template", canary.Name, "state", canary.State,
+			"candidate", canary.Candidate.Version, "baseline", canary.Baseline.Version, "reason", canary.Reason)
+	}
+}
@@ -0,0 +1,179 @@
+package tasks
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func canaryStore(t *testing.T, runs int) *TemplateStore {
+	store := NewTemplateStore()
+	_, err := store.Put("login", "v1", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com/login"}})
+	require.NoError(t, err)
+	candidate, err := store.PutCanary("login", "v2", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com/signin"}}, runs)
+	require.NoError(t, err)
+	assert.Equal(t, 2, candidate.Version)
+	return store
+}
+
+// canaryRun picks a version for a new run and submits it.
+func canaryRun(t *testing.T, store *TemplateStore) *taskstypes.Task {
+	tmpl, arm, err := store.ForRun("login", 0)
+	require.NoError(t, err)
+	task := &taskstypes.Task{ID: uuid.New(), TemplateName: tmpl.Name, TemplateVersion: tmpl.Version, Canary: arm}
+	store.startCanaryRun(task)
+	return task
+}
+
+func finishCanaryRun(store *TemplateStore, task *taskstypes.Task, status taskstypes.TaskStatus) *TemplateCanary {
+	task.Status = status
+	return store.finishCanaryRun(task)
+}
+
+func TestTemplateStore_CanaryPromotes(t *testing.T) {
+	store := canaryStore(t, 2)
+
+	// The version in use stays the baseline while the canary runs
+	inUse, err := store.Get("login", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, inUse.Version)
+	assert.Equal(t, 1, store.List()[0].Version)
+
+	_, err = store.Put("login", "v3", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}})
+	assert.ErrorIs(t, err, ErrCanaryRunning)
+	_, err = store.Rollback("login", 1)
+	assert.ErrorIs(t, err, ErrCanaryRunning)
+
+	// Pinned runs are not part of the canary
+	pinned, arm, err := store.ForRun("login", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pinned.Version)
+	assert.Empty(t, arm)
+
+	var runs []*taskstypes.Task
+	for i := 0; i < 5; i++ {
+		runs = append(runs, canaryRun(t, store))
+	}
+	arms := []string{}
+	for _, run := range runs {
+		arms = append(arms, run.Canary)
+	}
+	assert.Equal(t, []string{CanaryBaseline, CanaryCandidate, CanaryBaseline, CanaryCandidate, CanaryBaseline}, arms,
+		"runs alternate until enough candidate runs are under way")
+	assert.Equal(t, 2, runs[1].TemplateVersion)
+
+	// A cancelled candidate run gives its place to another
+	assert.Nil(t, finishCanaryRun(store, runs[1], taskstypes.StatusCancelled))
+	replacement := canaryRun(t, store)
+	assert.Equal(t, CanaryCandidate, replacement.Canary)
+
+	assert.Nil(t, finishCanaryRun(store, runs[0], taskstypes.StatusCompleted))
+	assert.Nil(t, finishCanaryRun(store, runs[2], taskstypes.StatusFailed))
+	assert.Nil(t, finishCanaryRun(store, runs[3], taskstypes.StatusCompleted))
+	decided := finishCanaryRun(store, replacement, taskstypes.StatusCompleted)
+	require.NotNil(t, decided)
+	assert.Equal(t, CanaryPromoted, decided.State)
+	assert.Equal(t, "candidate succeeded in 2 of 2 runs, baseline in 1 of 2", decided.Reason)
+	assert.NotNil(t, decided.EndedAt)
+
+	inUse, err = store.Get("login", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inUse.Version)
+
+	// Runs still in flight no longer count
+	assert.Nil(t, finishCanaryRun(store, runs[4], taskstypes.StatusFailed))
+	canary, err := store.Canary("login")
+	require.NoError(t, err)
+	assert.Equal(t, 2, canary.Baseline.finished())
+
+	_, arm, err = store.ForRun("login", 0)
+	require.NoError(t, err)
+	assert.Empty(t, arm)
+}
+
+func TestTemplateStore_CanaryRejects(t *testing.T) {
+	store := canaryStore(t, 1)
+
+	baseline, candidate := canaryRun(t, store), canaryRun(t, store)
+	require.Equal(t, CanaryCandidate, candidate.Canary)
+	assert.Nil(t, finishCanaryRun(store, candidate, taskstypes.StatusFailed))
+	decided := finishCanaryRun(store, baseline, taskstypes.StatusCompleted)
+	require.NotNil(t, decided)
+	assert.Equal(t, CanaryRejected, decided.State)
+
+	// The baseline is restored as a new version
+	inUse, err := store.Get("login", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, inUse.Version)
+	assert.Equal(t, 1, inUse.RolledBackFrom)
+	assert.Equal(t, "https://example.com/login", inUse.Actions[0].Value)
+}
+
+func TestTemplateStore_EndCanary(t *testing.T) {
+	store := NewTemplateStore()
+	_, err := store.PutCanary("login", "", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}}, 5)
+	assert.Error(t, err, "a new template has nothing to compare with")
+
+	_, err = store.Canary("login")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+
+	store = canaryStore(t, 5)
+	_, err = store.PutCanary("login", "", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}}, 5)
+	assert.ErrorIs(t, err, ErrCanaryRunning)
+
+	canary, err := store.EndCanary("login", true)
+	require.NoError(t, err)
+	assert.Equal(t, CanaryPromoted, canary.State)
+	_, err = store.EndCanary("login", false)
+	assert.ErrorIs(t, err, ErrNoCanary)
+
+	inUse, err := store.Get("login", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inUse.Version)
+}
+
+func TestManager_TemplateCanary(t *testing.T) {
+	mockBrowser := mocks.NewMockBrowserExecutor()
+	manager := NewManager(&config.Config{Browser: config.BrowserConfig{MaxSessions: 2}}, mockBrowser, slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	store := manager.Templates()
+	_, err := store.Put("login", "", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com/login"}})
+	require.NoError(t, err)
+	_, err = store.PutCanary("login", "", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com/signin"}}, 2)
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		tmpl, arm, err := store.ForRun("login", 0)
+		require.NoError(t, err)
+		task := &taskstypes.Task{
+			ID:              uuid.New(),
+			Status:          taskstypes.StatusPending,
+			Actions:         tmpl.Actions,
+			TemplateName:    tmpl.Name,
+			TemplateVersion: tmpl.Version,
+			Canary:          arm,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+		if arm == CanaryCandidate {
+			mockBrowser.SetExecutionResult(task.ID.String(), &taskstypes.TaskResult{}, errors.New("selector not found"))
+		}
+		require.NoError(t, manager.SubmitTask(task))
+	}
+
+	assert.Eventually(t, func() bool {
+		canary, err := store.Canary("login")
+		return err == nil && canary.State == CanaryRejected
+	}, 2*time.Second, 10*time.Millisecond)
+	inUse, err := store.Get("login", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, inUse.RolledBackFrom)
+}
@@ -0,0 +1,108 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTaskCancelled is the cause recorded for tasks stopped through CancelTask.
+	ErrTaskCancelled = errors.New("task cancelled")
+	// ErrRequestEnded is the cause recorded when the request waiting on a task
+	// ends first, because the client disconnected or the request timed out.
+	ErrRequestEnded = errors.New("request ended before the task finished")
+	// ErrShuttingDown is the cause recorded for tasks stopped by Shutdown.
+	ErrShuttingDown = errors.New("task manager shutting down")
+	// ErrTaskFinished is returned when cancelling a task that is no longer running.
+	ErrTaskFinished = errors.New("task already finished")
+)
+
+// taskRun tracks a task while it executes.
+type taskRun struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{} // Closed once the final status is saved
+}
+
+// startRun registers a task's execution and returns its context, which ends
+// when the task is cancelled, the manager shuts down, or parent is done.
+// Callers must hold m.mu.
+func (m *Manager) startRun(parent context.Context, id uuid.UUID) (context.Context, *taskRun) {
+	ctx, cancel := context.WithCancelCause(m.ctx)
+	run := &taskRun{cancel: cancel, done: make(chan struct{})}
+	if parent.Done() != nil {
+		stop := context.AfterFunc(parent, func() {
+			cancel(fmt.Errorf("%w: %v", ErrRequestEnded, context.Cause(parent)))
+		})
+		run.cancel = func(cause error) {
+			stop()
+			cancel(cause)
+		}
+	}
+	m.runs[id] = run
+	m.running.Add(1)
+	return ctx, run
+}
+
+// endRun releases a task's context once its final status is saved.
+func (m *Manager) endRun(id uuid.UUID, run *taskRun) {
+	m.mu.Lock()
+	delete(m.runs, id)
+	m.mu.Unlock()
+	run.cancel(nil)
+	close(run.done)
+	m.running.Done()
+}
+
+// CancelTask stops a running task. The browser executor abandons the current
+// action and the task finishes with status cancelled.
+func (m *Manager) CancelTask(id uuid.UUID) error {
+	m.mu.RLock()
+	run := m.runs[id]
+	_, known := m.tasks[id]
+	m.mu.RUnlock()
+
+	if run == nil {
+		if !known {
+			if _, err := m.store.Get(id); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrTaskFinished, id)
+	}
+	run.cancel(ErrTaskCancelled)
+	return nil
+}
+
+// WaitTask blocks until the task finishes or ctx is done, then returns a
+// snapshot of it.
+func (m *Manager) WaitTask(ctx context.Context, id uuid.UUID) (*taskstypes.Task, error) {
+	m.mu.RLock()
+	run := m.runs[id]
+	m.mu.RUnlock()
+
+	if run != nil {
+		select {
+		case <-run.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return m.GetTaskStatus(id)
+}
+
+// WaitTaskStatus blocks until the task's status is no longer from, or ctx is
+// done, then returns a snapshot of it. Tasks that are not running return at once.
+func (m *Manager) WaitTaskStatus(ctx context.Context, id uuid.UUID, from taskstypes.TaskStatus) (*taskstypes.Task, error) {
+	m.mu.RLock()
+	task, exists := m.tasks[id]
+	m.mu.RUnlock()
+
+	if exists {
+		task.WaitStatusChange(ctx, from)
+	}
+	return m.GetTaskStatus(id)
+}
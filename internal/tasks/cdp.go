@@ -0,0 +1,36 @@
+package tasks
+
+import "errors"
+
+// ErrCDPUnsupported is returned when the executor cannot lend out its browsers.
+var ErrCDPUnsupported = errors.New("browser executor does not support CDP access")
+
+// CDPLease lends a session's browser to a client outside GoScry, such as a
+// Puppeteer script or Lighthouse, over the Chrome DevTools Protocol. Tasks
+// on the session wait until the lease is released.
+type CDPLease struct {
+	// Endpoint is the host:port the browser serves DevTools HTTP and WebSocket on.
+	Endpoint string
+	// Revoked is closed when the session is closing; the holder must stop
+	// using Endpoint and call Release.
+	Revoked <-chan struct{}
+	// Release hands the session back to tasks. It may be called more than once.
+	Release func()
+}
+
+// CDPExecutor is implemented by executors whose session browsers can be
+// driven over CDP from outside.
+type CDPExecutor interface {
+	// LeaseCDP lends out the named session's browser. It fails with
+	// ErrSessionBusy while a task runs on the session.
+	LeaseCDP(name string) (*CDPLease, error)
+}
+
+// CDP returns the executor's support for lending browsers over CDP, if any.
+func (m *Manager) CDP() (CDPExecutor, error) {
+	cdp, ok := m.browserExecutor.(CDPExecutor)
+	if !ok {
+		return nil, ErrCDPUnsupported
+	}
+	return cdp, nil
+}
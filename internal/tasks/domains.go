@@ -0,0 +1,391 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"golang.org/x/net/publicsuffix"
+)
+
+// ErrDomainUnhealthy is returned by SubmitTask when queue.domainHealth.action
+// is "reject" and the task's target domain is failing.
+var ErrDomainUnhealthy = errors.New("target domain is failing")
+
+// DomainUnhealthyError says which domain refused a task and when it will
+// next be tried.
+type DomainUnhealthyError struct {
+	Domain     string
+	RetryAfter time.Duration
+}
+
+func (e *DomainUnhealthyError) Error() string {
+	return fmt.Sprintf("%v: %s, retry in %s", ErrDomainUnhealthy, e.Domain, e.RetryAfter.Round(time.Second))
+}
+
+func (e *DomainUnhealthyError) Unwrap() error {
+	return ErrDomainUnhealthy
+}
+
+// Domain health actions for queue.domainHealth.action.
+const (
+	DomainActionNone   = "none"
+	DomainActionDelay  = "delay"
+	DomainActionReject = "reject"
+)
+
+// Domain breaker states reported in DomainHealth.
+const (
+	DomainHealthy = "healthy"
+	DomainTripped = "tripped" // Held back until the cooldown ends
+	DomainProbing = "probing" // One task is testing whether the domain recovered
+)
+
+const (
+	defaultDomainWindow      = 5 * time.Minute
+	defaultDomainMinSamples  = 5
+	defaultDomainFailureRate = 0.5
+	defaultDomainCooldown    = time.Minute
+	domainQueueRetry         = time.Second // How often a delayed task looks for room in a full queue
+)
+
+// DomainHealth scores one target domain from its recent task outcomes.
+type DomainHealth struct {
+	Domain        string               `json:"domain"`
+	Score         float64              `json:"score"` // Share of recent outcomes that succeeded, 1 with none
+	Successes     int                  `json:"successes"`
+	Failures      int                  `json:"failures"`
+	State         string               `json:"state"`
+	TrippedUntil  *time.Time           `json:"tripped_until,omitempty"`
+	LastFailureAt *time.Time           `json:"last_failure_at,omitempty"`
+	LastErrorCode taskstypes.ErrorCode `json:"last_error_code,omitempty"`
+}
+
+// domainOutcome is one finished task that counts towards its domain's score.
+type domainOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+type domainState struct {
+	outcomes      []domainOutcome // Oldest first
+	trippedUntil  time.Time       // Zero while healthy
+	probe         uuid.UUID       // The task let through to test a tripped domain, if any
+	lastFailureAt time.Time
+	lastErrorCode taskstypes.ErrorCode
+}
+
+// domainTracker keeps the recent outcomes of each target domain and acts as
+// a circuit breaker: a domain that fails too often is tripped for a cooldown,
+// then one task probes it, closing the breaker on success.
+type domainTracker struct {
+	mu          sync.Mutex
+	domains     map[string]*domainState
+	window      time.Duration
+	minSamples  int
+	failureRate float64
+	cooldown    time.Duration
+	action      string
+	now         func() time.Time
+}
+
+// newDomainTracker reads queue.domainHealth, filling in defaults. When the
+// config is invalid, the tracker it returns only scores domains.
+func newDomainTracker(cfg *config.Config) (*domainTracker, error) {
+	t := &domainTracker{
+		domains:     make(map[string]*domainState),
+		window:      defaultDomainWindow,
+		minSamples:  defaultDomainMinSamples,
+		failureRate: defaultDomainFailureRate,
+		cooldown:    defaultDomainCooldown,
+		action:      DomainActionNone,
+		now:         time.Now,
+	}
+	if cfg == nil {
+		return t, nil
+	}
+	dh := cfg.Queue.DomainHealth
+	if dh.Window > 0 {
+		t.window = dh.Window
+	}
+	if dh.MinSamples > 0 {
+		t.minSamples = dh.MinSamples
+	}
+	if dh.FailureRate > 0 {
+		t.failureRate = dh.FailureRate
+	}
+	if dh.Cooldown > 0 {
+		t.cooldown = dh.Cooldown
+	}
+	switch action := strings.ToLower(dh.Action); action {
+	case "", DomainActionNone:
+	case DomainActionDelay, DomainActionReject:
+		t.action = action
+	default:
+		return t, fmt.Errorf("queue.domainHealth.action must be none, delay, or reject, got %q", dh.Action)
+	}
+	if t.failureRate > 1 {
+		t.failureRate, t.action = defaultDomainFailureRate, DomainActionNone
+		return t, fmt.Errorf("queue.domainHealth.failureRate must be at most 1, got %g", dh.FailureRate)
+	}
+	return t, nil
+}
+
+// targetDomain returns the registrable domain of the task's first navigation,
+// or "" when it does not navigate.
+func targetDomain(task *taskstypes.Task) string {
+	for _, action := range task.Actions {
+		if action.Type != taskstypes.ActionNavigate {
+			continue
+		}
+		u, err := url.Parse(action.Value)
+		if err != nil || u.Hostname() == "" {
+			return ""
+		}
+		host := strings.ToLower(u.Hostname())
+		if net.ParseIP(host) != nil {
+			return host
+		}
+		if site, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+			return site
+		}
+		return host
+	}
+	return ""
+}
+
+// domainFailure reports whether a failure says the domain itself is in
+// trouble, rather than the task or the page having changed.
+func domainFailure(code taskstypes.ErrorCode) bool {
+	switch code {
+	case taskstypes.ErrorNavigationTimeout, taskstypes.ErrorNavigationFailed,
+		taskstypes.ErrorActionTimeout, taskstypes.ErrorTaskTimeout, taskstypes.ErrorBotProtection:
+		return true
+	}
+	return false
+}
+
+// admit reports how long a new task for domain should wait, or zero to run
+// it now. The first task after a cooldown is admitted as the probe.
+func (t *domainTracker) admit(domain string, id uuid.UUID) time.Duration {
+	if domain == "" || t.action == DomainActionNone {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.domains[domain]
+	if state == nil || state.trippedUntil.IsZero() {
+		return 0
+	}
+	if wait := state.trippedUntil.Sub(t.now()); wait > 0 {
+		return wait
+	}
+	if state.probe != uuid.Nil && state.probe != id {
+		return t.cooldown
+	}
+	state.probe = id
+	return 0
+}
+
+// record counts a finished task towards its domain's score. Outcomes that
+// say nothing about the domain, such as a missing selector, only end a probe.
+// It reports whether the domain was tripped by this outcome.
+func (t *domainTracker) record(domain string, id uuid.UUID, status taskstypes.TaskStatus, code taskstypes.ErrorCode) bool {
+	if domain == "" {
+		return false
+	}
+	failed := status == taskstypes.StatusFailed && domainFailure(code)
+	counts := status == taskstypes.StatusCompleted || failed
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	state := t.domains[domain]
+	if state == nil {
+		if !counts {
+			return false
+		}
+		state = &domainState{}
+		t.domains[domain] = state
+	}
+	t.prune(state, now)
+	// Without a probe in flight, the first outcome after the cooldown serves as one
+	probe := state.probe == id || (state.probe == uuid.Nil && !state.trippedUntil.IsZero() && !now.Before(state.trippedUntil))
+	if probe {
+		state.probe = uuid.Nil
+	}
+	if !counts {
+		return false
+	}
+	state.outcomes = append(state.outcomes, domainOutcome{at: now, failed: failed})
+	if failed {
+		state.lastFailureAt = now
+		state.lastErrorCode = code
+	}
+
+	if !state.trippedUntil.IsZero() {
+		if !probe {
+			return false // Started before the domain tripped
+		}
+		if failed {
+			state.trippedUntil = now.Add(t.cooldown)
+			return true
+		}
+		// Recovered: start scoring afresh
+		state.trippedUntil = time.Time{}
+		state.outcomes = state.outcomes[len(state.outcomes)-1:]
+		return false
+	}
+
+	total, failures := len(state.outcomes), 0
+	for _, outcome := range state.outcomes {
+		if outcome.failed {
+			failures++
+		}
+	}
+	if total >= t.minSamples && float64(failures)/float64(total) >= t.failureRate {
+		state.trippedUntil = now.Add(t.cooldown)
+		return true
+	}
+	return false
+}
+
+// prune drops outcomes older than the window.
+func (t *domainTracker) prune(state *domainState, now time.Time) {
+	cutoff := now.Add(-t.window)
+	keep := 0
+	for keep < len(state.outcomes) && state.outcomes[keep].at.Before(cutoff) {
+		keep++
+	}
+	state.outcomes = state.outcomes[keep:]
+}
+
+// health scores every domain with recent outcomes or a tripped breaker,
+// least healthy first. Domains with neither are forgotten.
+func (t *domainTracker) health() []DomainHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	list := make([]DomainHealth, 0, len(t.domains))
+	for domain, state := range t.domains {
+		t.prune(state, now)
+		if len(state.outcomes) == 0 && state.trippedUntil.IsZero() {
+			delete(t.domains, domain)
+			continue
+		}
+		h := DomainHealth{Domain: domain, Score: 1, State: DomainHealthy, LastErrorCode: state.lastErrorCode}
+		for _, outcome := range state.outcomes {
+			if outcome.failed {
+				h.Failures++
+			} else {
+				h.Successes++
+			}
+		}
+		if total := h.Successes + h.Failures; total > 0 {
+			h.Score = float64(h.Successes) / float64(total)
+		}
+		if !state.trippedUntil.IsZero() {
+			h.State = DomainTripped
+			if state.probe != uuid.Nil || !now.Before(state.trippedUntil) {
+				h.State = DomainProbing
+			}
+			until := state.trippedUntil.UTC()
+			h.TrippedUntil = &until
+		}
+		if !state.lastFailureAt.IsZero() {
+			at := state.lastFailureAt.UTC()
+			h.LastFailureAt = &at
+		}
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score < list[j].Score
+		}
+		return list[i].Domain < list[j].Domain
+	})
+	return list
+}
+
+// DomainHealth scores the target domains of recent tasks, least healthy first.
+func (m *Manager) DomainHealth() []DomainHealth {
+	return m.domains.health()
+}
+
+// admitDomain applies queue.domainHealth.action to a new task. It returns a
+// *DomainUnhealthyError when the task is refused, or how long to hold it.
+func (m *Manager) admitDomain(task *taskstypes.Task) (time.Duration, error) {
+	domain := targetDomain(task)
+	wait := m.domains.admit(domain, task.ID)
+	if wait <= 0 {
+		return 0, nil
+	}
+	if m.domains.action == DomainActionReject {
+		return 0, &DomainUnhealthyError{Domain: domain, RetryAfter: wait}
+	}
+	task.Warnings = append(task.Warnings, fmt.Sprintf("%s is failing; the task is held for %s until it is tried again", domain, wait.Round(time.Second)))
+	return wait, nil
+}
+
+// recordDomain counts a finished task towards its target domain's health.
+func (m *Manager) recordDomain(task *taskstypes.Task) {
+	snapshot := task.Snapshot()
+	var code taskstypes.ErrorCode
+	if snapshot.Result != nil {
+		code = snapshot.Result.ErrorCode
+	}
+	domain := targetDomain(snapshot)
+	if m.domains.record(domain, task.ID, snapshot.Status, code) {
+		m.logger.Warn("Target domain is failing, holding back its tasks", "domain", domain,
+			"cooldown", m.domains.cooldown, "action", m.domains.action, logging.TaskIDKey, task.ID)
+	}
+}
+
+// enqueueAfter queues a task once its domain's cooldown has passed, and
+// again waits if another task is probing the domain by then. A task
+// cancelled while held finishes as cancelled.
+func (m *Manager) enqueueAfter(ctx context.Context, task *taskstypes.Task, run *taskRun, wait time.Duration) {
+	// sleep waits, or finishes the task and reports false if it is cancelled first
+	sleep := func(wait time.Duration) bool {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			m.executeTask(ctx, task, run)
+			return false
+		case <-timer.C:
+			return true
+		}
+	}
+	go func() {
+		domain := targetDomain(task)
+		for wait > 0 {
+			if !sleep(wait) {
+				return
+			}
+			wait = m.domains.admit(domain, task.ID)
+		}
+		for {
+			m.mu.Lock()
+			if !m.queue.full() {
+				m.enqueue(ctx, task, run)
+				m.mu.Unlock()
+				return
+			}
+			m.mu.Unlock()
+			if !sleep(domainQueueRetry) {
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,135 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetDomain(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://www.shop.example.co.uk/cart": "example.co.uk",
+		"http://localhost:8080/":              "localhost",
+		"https://10.0.0.7/status":             "10.0.0.7",
+	} {
+		task := &taskstypes.Task{Actions: []taskstypes.Action{
+			{Type: taskstypes.ActionWaitDelay, Value: "1s"},
+			{Type: taskstypes.ActionNavigate, Value: url},
+		}}
+		assert.Equal(t, want, targetDomain(task), url)
+	}
+	assert.Empty(t, targetDomain(&taskstypes.Task{Actions: []taskstypes.Action{{Type: taskstypes.ActionClick, Selector: "a"}}}))
+}
+
+func TestDomainTracker(t *testing.T) {
+	tracker, err := newDomainTracker(&config.Config{Queue: config.QueueConfig{DomainHealth: config.DomainHealthConfig{
+		MinSamples: 3, FailureRate: 0.5, Cooldown: time.Minute, Action: "reject",
+	}}})
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	failed, completed := taskstypes.StatusFailed, taskstypes.StatusCompleted
+
+	assert.False(t, tracker.record("a.example", uuid.New(), completed, ""))
+	assert.False(t, tracker.record("a.example", uuid.New(), failed, taskstypes.ErrorSelectorNotFound), "page changes say nothing about the domain")
+	assert.False(t, tracker.record("a.example", uuid.New(), failed, taskstypes.ErrorNavigationFailed))
+	assert.Zero(t, tracker.admit("a.example", uuid.New()), "too few samples to trip")
+	assert.True(t, tracker.record("a.example", uuid.New(), failed, taskstypes.ErrorNavigationTimeout))
+
+	assert.Equal(t, time.Minute, tracker.admit("a.example", uuid.New()))
+	assert.Zero(t, tracker.admit("b.example", uuid.New()), "other domains are unaffected")
+	health := tracker.health()
+	require.Len(t, health, 1)
+	assert.Equal(t, DomainTripped, health[0].State)
+	assert.InDelta(t, 1.0/3, health[0].Score, 0.001)
+	assert.Equal(t, taskstypes.ErrorNavigationTimeout, health[0].LastErrorCode)
+
+	// After the cooldown one task probes the domain; a failure trips it again
+	now = now.Add(time.Minute)
+	probe := uuid.New()
+	assert.Zero(t, tracker.admit("a.example", probe))
+	assert.Equal(t, time.Minute, tracker.admit("a.example", uuid.New()), "one probe at a time")
+	assert.Equal(t, DomainProbing, tracker.health()[0].State)
+	assert.False(t, tracker.record("a.example", uuid.New(), completed, ""), "only the probe decides")
+	assert.True(t, tracker.record("a.example", probe, failed, taskstypes.ErrorNavigationFailed))
+
+	// A successful probe closes the breaker and starts scoring afresh
+	now = now.Add(time.Minute)
+	probe = uuid.New()
+	assert.Zero(t, tracker.admit("a.example", probe))
+	assert.False(t, tracker.record("a.example", probe, completed, ""))
+	assert.Zero(t, tracker.admit("a.example", uuid.New()))
+	health = tracker.health()
+	assert.Equal(t, DomainHealthy, health[0].State)
+	assert.Equal(t, 1, health[0].Successes)
+	assert.Zero(t, health[0].Failures)
+
+	// Outcomes outside the window are forgotten
+	now = now.Add(time.Hour)
+	assert.Empty(t, tracker.health())
+
+	_, err = newDomainTracker(&config.Config{Queue: config.QueueConfig{DomainHealth: config.DomainHealthConfig{Action: "pause"}}})
+	assert.Error(t, err)
+}
+
+func TestManager_DomainHealth(t *testing.T) {
+	mockBrowser := mocks.NewMockBrowserExecutor()
+	cfg := &config.Config{Queue: config.QueueConfig{DomainHealth: config.DomainHealthConfig{
+		MinSamples: 2, FailureRate: 1, Cooldown: time.Hour, Action: "reject",
+	}}}
+	manager := NewManager(cfg, mockBrowser, logging.Discard())
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+
+	newDomainTask := func() *taskstypes.Task {
+		return &taskstypes.Task{
+			ID:        uuid.New(),
+			Status:    taskstypes.StatusPending,
+			Actions:   []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://down.example/"}},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+	}
+	for i := 0; i < 2; i++ {
+		task := newDomainTask()
+		mockBrowser.SetExecutionResult(task.ID.String(), &taskstypes.TaskResult{ErrorCode: taskstypes.ErrorNavigationFailed}, errors.New("net::ERR_CONNECTION_REFUSED"))
+		require.NoError(t, manager.SubmitTask(task))
+		_, err := manager.WaitTask(context.Background(), task.ID)
+		require.NoError(t, err)
+	}
+
+	var unhealthy *DomainUnhealthyError
+	require.ErrorAs(t, manager.SubmitTask(newDomainTask()), &unhealthy)
+	assert.ErrorIs(t, unhealthy, ErrDomainUnhealthy)
+	assert.Equal(t, "down.example", unhealthy.Domain)
+	assert.InDelta(t, time.Hour.Seconds(), unhealthy.RetryAfter.Seconds(), 5)
+
+	// Delayed tasks are held, pending, until they are cancelled or the domain is tried again
+	manager.domains.action = DomainActionDelay
+	held := newDomainTask()
+	require.NoError(t, manager.SubmitTask(held))
+	status, err := manager.GetTaskStatus(held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, taskstypes.StatusPending, status.Status)
+	require.Len(t, status.Warnings, 1)
+	assert.Contains(t, status.Warnings[0], "down.example is failing")
+
+	require.NoError(t, manager.CancelTask(held.ID))
+	finished, err := manager.WaitTask(context.Background(), held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, taskstypes.StatusCancelled, finished.Status)
+	assert.Len(t, mockBrowser.ExecutedTasks(), 2, "held tasks never reach the browser")
+
+	health := manager.DomainHealth()
+	require.Len(t, health, 1)
+	assert.Equal(t, DomainTripped, health[0].State)
+	assert.Equal(t, 2, health[0].Failures)
+}
@@ -0,0 +1,222 @@
+package tasks
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// Baseline costs used when no history is available for a domain.
+// These are deliberately conservative; observed runs refine them per domain.
+var baselineActionCost = map[taskstypes.ActionType]time.Duration{
+	taskstypes.ActionNavigate:     3 * time.Second,
+	taskstypes.ActionWaitVisible:  1 * time.Second,
+	taskstypes.ActionWaitHidden:   1 * time.Second,
+	taskstypes.ActionClick:        500 * time.Millisecond,
+	taskstypes.ActionInput:        200 * time.Millisecond,
+	taskstypes.ActionSelect:       200 * time.Millisecond,
+	taskstypes.ActionScroll:       300 * time.Millisecond,
+	taskstypes.ActionScreenshot:   1500 * time.Millisecond,
+	taskstypes.ActionGetDOM:       500 * time.Millisecond,
+	taskstypes.ActionRunScript:    500 * time.Millisecond,
+	taskstypes.ActionLogin:        3 * time.Second,
+	taskstypes.ActionDownload:     3 * time.Second,
+	taskstypes.ActionSecurity:     3 * time.Second,
+	taskstypes.ActionCloaking:     12 * time.Second,
+	taskstypes.ActionExtract:      500 * time.Millisecond,
+	taskstypes.ActionChangePass:   3 * time.Second,
+	taskstypes.ActionSwitchTab:    500 * time.Millisecond,
+	taskstypes.ActionCloseTab:     200 * time.Millisecond,
+	taskstypes.ActionAdvanceClock: 100 * time.Millisecond,
+	taskstypes.ActionFindText:     500 * time.Millisecond,
+}
+
+const (
+	defaultActionCost   = 1 * time.Second
+	perKeystrokeCost    = 20 * time.Millisecond
+	screenshotBaseBytes = 150 * 1024
+	screenshotByteStep  = 3 * 1024 // additional bytes per quality point
+	// A repeating action without max_pages is assumed to visit this many pages,
+	// each costing a click and settle on top of the action itself.
+	defaultRepeatPages = 5
+	perPageCost        = 1500 * time.Millisecond
+)
+
+// ActionEstimate is the predicted cost of a single action.
+type ActionEstimate struct {
+	Index      int                   `json:"index"`
+	Type       taskstypes.ActionType `json:"type"`
+	Domain     string                `json:"domain,omitempty"`
+	DurationMs int64                 `json:"duration_ms"`
+	Bytes      int64                 `json:"bytes,omitempty"`
+}
+
+// Estimate is the predicted cost of running an action list.
+type Estimate struct {
+	DurationMs       int64            `json:"estimated_duration_ms"`
+	BrowserSeconds   float64          `json:"browser_seconds"`
+	ArtifactBytes    int64            `json:"artifact_bytes"`
+	SuggestedTimeout string           `json:"suggested_timeout"`
+	HistorySamples   int              `json:"history_samples"`
+	Actions          []ActionEstimate `json:"actions"`
+}
+
+// domainStats accumulates observed vs. baseline duration for a domain so the
+// ratio can be used as a correction factor for future estimates.
+type domainStats struct {
+	samples  int
+	observed time.Duration
+	baseline time.Duration
+}
+
+func (d domainStats) factor() float64 {
+	if d.samples == 0 || d.baseline <= 0 {
+		return 1
+	}
+	return float64(d.observed) / float64(d.baseline)
+}
+
+// Estimator predicts task cost from static analysis of the action list,
+// corrected by the durations of previously completed tasks.
+type Estimator struct {
+	mu      sync.RWMutex
+	domains map[string]domainStats
+	global  domainStats
+}
+
+// NewEstimator creates an estimator with no history.
+func NewEstimator() *Estimator {
+	return &Estimator{domains: make(map[string]domainStats)}
+}
+
+// Record adds an observed task run to the history.
+func (e *Estimator) Record(actions []taskstypes.Action, observed time.Duration) {
+	baseline := e.baseline(actions)
+	if baseline.DurationMs <= 0 || observed <= 0 {
+		return
+	}
+	base := time.Duration(baseline.DurationMs) * time.Millisecond
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if domain := primaryDomain(actions); domain != "" {
+		stats := e.domains[domain]
+		stats.samples++
+		stats.observed += observed
+		stats.baseline += base
+		e.domains[domain] = stats
+	}
+	e.global.samples++
+	e.global.observed += observed
+	e.global.baseline += base
+}
+
+// Estimate returns the predicted cost of running actions.
+func (e *Estimator) Estimate(actions []taskstypes.Action) Estimate {
+	est := e.baseline(actions)
+
+	e.mu.RLock()
+	stats, ok := e.domains[primaryDomain(actions)]
+	if !ok {
+		stats = e.global
+	}
+	e.mu.RUnlock()
+
+	factor := stats.factor()
+	est.HistorySamples = stats.samples
+	est.DurationMs = 0
+	for i := range est.Actions {
+		// wait_delay is exact and not subject to site performance
+		if est.Actions[i].Type != taskstypes.ActionWaitDelay {
+			est.Actions[i].DurationMs = int64(float64(est.Actions[i].DurationMs) * factor)
+		}
+		est.DurationMs += est.Actions[i].DurationMs
+	}
+	est.BrowserSeconds = float64(est.DurationMs) / 1000
+
+	// Leave headroom for variance when suggesting a client-side timeout
+	est.SuggestedTimeout = (time.Duration(est.DurationMs) * time.Millisecond * 2).Round(time.Second).String()
+	return est
+}
+
+// baseline computes the uncorrected estimate.
+func (e *Estimator) baseline(actions []taskstypes.Action) Estimate {
+	est := Estimate{Actions: make([]ActionEstimate, 0, len(actions))}
+	domain := ""
+
+	for i, action := range actions {
+		if action.Type == taskstypes.ActionNavigate {
+			if host := hostOf(action.Value); host != "" {
+				domain = host
+			}
+		}
+
+		cost, ok := baselineActionCost[action.Type]
+		if !ok {
+			cost = defaultActionCost
+		}
+
+		var bytes int64
+		switch action.Type {
+		case taskstypes.ActionWaitDelay:
+			if d, err := time.ParseDuration(action.Value); err == nil {
+				cost = d
+			}
+		case taskstypes.ActionInput:
+			cost += time.Duration(len(action.Value)) * perKeystrokeCost
+		case taskstypes.ActionScreenshot:
+			quality := 90
+			if q, err := strconv.Atoi(action.Value); err == nil && q >= 0 && q <= 100 {
+				quality = q
+			}
+			bytes = int64(screenshotBaseBytes + quality*screenshotByteStep)
+		}
+		if action.Repeat != nil {
+			pages := action.Repeat.MaxPages
+			if pages <= 0 {
+				pages = defaultRepeatPages
+			}
+			cost = time.Duration(pages)*(cost+perPageCost) - perPageCost
+			bytes *= int64(pages)
+		}
+		if action.Timeout > 0 && cost > action.Timeout {
+			cost = action.Timeout
+		}
+
+		est.Actions = append(est.Actions, ActionEstimate{
+			Index:      i,
+			Type:       action.Type,
+			Domain:     domain,
+			DurationMs: cost.Milliseconds(),
+			Bytes:      bytes,
+		})
+		est.DurationMs += cost.Milliseconds()
+		est.ArtifactBytes += bytes
+	}
+	return est
+}
+
+// primaryDomain returns the host of the first navigate action.
+func primaryDomain(actions []taskstypes.Action) string {
+	for _, action := range actions {
+		if action.Type == taskstypes.ActionNavigate {
+			if host := hostOf(action.Value); host != "" {
+				return host
+			}
+		}
+	}
+	return ""
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
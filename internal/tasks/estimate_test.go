@@ -0,0 +1,84 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimator_Baseline(t *testing.T) {
+	e := NewEstimator()
+	actions := []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://example.com"},
+		{Type: taskstypes.ActionWaitDelay, Value: "2s"},
+		{Type: taskstypes.ActionScreenshot, Value: "50"},
+	}
+
+	est := e.Estimate(actions)
+
+	assert.Equal(t, 0, est.HistorySamples)
+	assert.Len(t, est.Actions, 3)
+	assert.Equal(t, "example.com", est.Actions[0].Domain)
+	assert.Equal(t, int64(2000), est.Actions[1].DurationMs)
+	assert.Equal(t, int64(3000+2000+1500), est.DurationMs)
+	assert.Equal(t, int64(screenshotBaseBytes+50*screenshotByteStep), est.ArtifactBytes)
+}
+
+func TestEstimator_UsesDomainHistory(t *testing.T) {
+	e := NewEstimator()
+	actions := []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://slow.example.com/login"},
+		{Type: taskstypes.ActionWaitDelay, Value: "1s"},
+	}
+
+	// Observed runs take twice as long as the baseline (3s navigate + 1s delay)
+	e.Record(actions, 8*time.Second)
+
+	est := e.Estimate(actions)
+	assert.Equal(t, 1, est.HistorySamples)
+	assert.Equal(t, int64(6000), est.Actions[0].DurationMs)
+	// Fixed delays are not scaled
+	assert.Equal(t, int64(1000), est.Actions[1].DurationMs)
+
+	// Other domains fall back to global history
+	other := e.Estimate([]taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://other.example.org"}})
+	assert.Equal(t, 1, other.HistorySamples)
+}
+
+func TestEstimator_RepeatedAction(t *testing.T) {
+	e := NewEstimator()
+	est := e.Estimate([]taskstypes.Action{
+		{Type: taskstypes.ActionExtract, Repeat: &taskstypes.RepeatSpec{Next: "a.next", MaxPages: 3}},
+	})
+
+	// Three extracts with two page turns between them
+	assert.Equal(t, int64(3*500+2*1500), est.DurationMs)
+}
+
+func TestManager_SeedsEstimatorWithoutTFAWait(t *testing.T) {
+	m := NewManager(nil, mocks.NewMockBrowserExecutor(), logging.Discard())
+	defer m.Shutdown(context.Background())
+	actions := []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://slow.example.com/login"},
+		{Type: taskstypes.ActionWaitDelay, Value: "1s"},
+	}
+	started := time.Now().Add(-time.Hour)
+	completed := started.Add(64 * time.Second)
+	// A minute of the run was spent waiting for a 2FA code
+	task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusCompleted, Actions: actions, CreatedAt: started,
+		StartedAt: &started, CompletedAt: &completed, TFAWaitMs: 60000}
+	require.NoError(t, m.store.Save(task))
+
+	m.estimator = NewEstimator()
+	m.seedEstimator()
+	est := m.EstimateTask(actions)
+	assert.Equal(t, 1, est.HistorySamples)
+	assert.Equal(t, int64(3000), est.Actions[0].DurationMs, "the 4s of activity matches the baseline")
+}
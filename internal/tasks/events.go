@@ -0,0 +1,46 @@
+package tasks
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// TaskEvent is posted to a task's callback URL while the task is still
+// running, e.g. batches of captured network entries.
+type TaskEvent struct {
+	TaskID string      `json:"task_id"`
+	Event  string      `json:"event"`
+	Data   interface{} `json:"data"`
+	SentAt time.Time   `json:"sent_at"`
+}
+
+// EventSink delivers an executor's events for a running task.
+type EventSink func(task *taskstypes.Task, event string, data interface{})
+
+// EventStreamer is implemented by executors that can stream events to a
+// task's callback before the final result is ready.
+type EventStreamer interface {
+	SetEventSink(sink EventSink)
+}
+
+// streamEvent posts an event to the task's callback URL. It blocks until the
+// callback responds, so executors should call it off their hot path.
+func (m *Manager) streamEvent(task *taskstypes.Task, event string, data interface{}) {
+	if task.CallbackURL == "" {
+		return
+	}
+	payload, err := json.Marshal(TaskEvent{
+		TaskID: task.ID.String(),
+		Event:  event,
+		Data:   data,
+		SentAt: time.Now().UTC(),
+	})
+	if err != nil {
+		m.logger.Error("Failed to marshal task event", "event", event, logging.TaskIDKey, task.ID, "error", err)
+		return
+	}
+	m.postCallback(task, event, payload)
+}
@@ -2,6 +2,10 @@ package tasks
 
 import (
 	"context"
+	"errors"
+
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/secrets"
 	"github.com/copyleftdev/goscry/internal/taskstypes"
 )
 
@@ -10,10 +14,99 @@ import (
 type BrowserExecutor interface {
 	// ExecuteTask runs the browser actions defined within the task.
 	// It should handle the entire lifecycle for the browser part of the task,
-	// including potential 2FA waits.
+	// including potential 2FA waits, and stop promptly once ctx is done.
 	// Returns a result object and an error if the execution fails.
-	ExecuteTask(task *taskstypes.Task) (*taskstypes.TaskResult, error)
+	ExecuteTask(ctx context.Context, task *taskstypes.Task) (*taskstypes.TaskResult, error)
 
 	// Shutdown allows for graceful cleanup of browser resources if needed at this level.
 	Shutdown(ctx context.Context) error
 }
+
+// ErrSealedCredentialsUnsupported is returned for tasks with encrypted
+// credentials when the executor cannot decrypt them.
+var ErrSealedCredentialsUnsupported = errors.New("browser executor does not support encrypted credentials")
+
+// CredentialKeyReceiver is implemented by executors that decrypt
+// Task.SealedCreds themselves, so plaintext never leaves the executor.
+type CredentialKeyReceiver interface {
+	SetCredentialKey(key *encryption.CredentialKey)
+}
+
+// ErrCredentialsRefUnsupported is returned for tasks with a credentials
+// reference when the executor cannot resolve them.
+var ErrCredentialsRefUnsupported = errors.New("browser executor does not support credential references")
+
+// CredentialResolverReceiver is implemented by executors that resolve
+// Task.CredentialsRef themselves when the task runs, so secrets are read
+// only where they are used.
+type CredentialResolverReceiver interface {
+	SetCredentialResolver(resolver *secrets.Resolver)
+}
+
+// TemplateReceiver is implemented by executors that run stored templates on
+// their own, such as a session's login template when its login expires.
+type TemplateReceiver interface {
+	SetTemplates(templates *TemplateStore)
+}
+
+// URLPolicyReceiver is implemented by executors that hold the pages they
+// load, and the requests those pages make, to security.urlPolicy.
+type URLPolicyReceiver interface {
+	SetURLPolicy(policy *taskstypes.URLPolicy)
+}
+
+// KeyringReceiver is implemented by executors that encrypt what they keep
+// at rest, such as browser profile snapshots, with the security.encryption keyring.
+type KeyringReceiver interface {
+	SetKeyring(keyring *encryption.Keyring)
+}
+
+// ArtifactStats are process-lifetime counters for artifacts an executor captures.
+type ArtifactStats struct {
+	Written      int64 `json:"written"`
+	BytesWritten int64 `json:"bytes_written"`
+	Rejected     int64 `json:"rejected"`    // Over browser.artifacts.maxBytes
+	InProgress   int64 `json:"in_progress"` // Captures currently holding a slot
+	Waiting      int64 `json:"waiting"`     // Captures waiting for a slot
+	Waited       int64 `json:"waited"`      // Captures that had to wait at all
+	PeakBytes    int64 `json:"peak_bytes"`  // Largest single artifact so far
+}
+
+// ArtifactStatsProvider is implemented by executors that report artifact
+// capture metrics, which /api/v1/stats includes.
+type ArtifactStatsProvider interface {
+	ArtifactStats() ArtifactStats
+}
+
+// DOMCacheStats are process-lifetime counters for the simplified DOM cache.
+type DOMCacheStats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// BrowserPoolStats describe the warm browser pool: current counts, and
+// process-lifetime counters.
+type BrowserPoolStats struct {
+	Idle      int   `json:"idle"`
+	InUse     int   `json:"in_use"`
+	Starting  int   `json:"starting"`
+	Hits      int64 `json:"hits"`      // Tasks that got a warm browser
+	Misses    int64 `json:"misses"`    // Tasks that had to start one
+	Recycled  int64 `json:"recycled"`  // Browsers replaced for age, uses, or idleness
+	Unhealthy int64 `json:"unhealthy"` // Browsers replaced after crashing or failing a health check
+}
+
+// BrowserPoolStatsProvider is implemented by executors that keep warm
+// browsers, whose use /api/v1/stats includes.
+type BrowserPoolStatsProvider interface {
+	BrowserPoolStats() (BrowserPoolStats, bool) // False when the pool is off
+}
+
+// DOMCacheStatsProvider is implemented by executors that cache DOM
+// post-processing output, whose hit rate /api/v1/stats includes.
+type DOMCacheStatsProvider interface {
+	DOMCacheStats() DOMCacheStats
+}
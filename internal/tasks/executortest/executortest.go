@@ -0,0 +1,309 @@
+// Package executortest is a conformance suite for tasks.BrowserExecutor
+// implementations. Every executor, whether it drives Chrome locally or a
+// browser elsewhere, should pass it, so tasks.Manager and API clients see
+// the same behaviour from all of them: tasks run to completion, failures
+// carry typed error codes, 2FA prompts wait for a code, cancellation stops a
+// task promptly, and screenshots land in the artifact store.
+//
+// Call Run from a test in the executor's package:
+//
+//	func TestContract(t *testing.T) {
+//		executortest.Run(t, executortest.Config{
+//			New: func(t *testing.T) tasks.BrowserExecutor { ... },
+//			Artifacts: store,
+//		})
+//	}
+package executortest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	// cancelGrace is how long an executor may take to return once its task is cancelled.
+	cancelGrace = 10 * time.Second
+	// TFACode is the code the suite enters when a task waits for 2FA.
+	TFACode = "424242"
+)
+
+// Config describes the executor under test.
+type Config struct {
+	// New returns a fresh executor. The suite shuts it down when the test ends.
+	New func(t *testing.T) tasks.BrowserExecutor
+	// Artifacts is the store the executor saves screenshots to. Nil skips
+	// the artifact checks.
+	Artifacts artifacts.Store
+	// Timeout bounds each task; zero uses 30 seconds.
+	Timeout time.Duration
+}
+
+// Run runs every contract test as a subtest of t.
+func Run(t *testing.T, cfg Config) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	s := newSite()
+	t.Cleanup(s.Close)
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, cfg Config, executor tasks.BrowserExecutor, s *site)
+	}{
+		{"Completes", testCompletes},
+		{"ReportsFailure", testReportsFailure},
+		{"WaitsFor2FA", testWaitsFor2FA},
+		{"StopsOnCancel", testStopsOnCancel},
+		{"CapturesScreenshots", testCapturesScreenshots},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := cfg.New(t)
+			t.Cleanup(func() {
+				ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+				defer cancel()
+				assert.NoError(t, executor.Shutdown(ctx), "Shutdown")
+			})
+			tt.run(t, cfg, executor, s)
+		})
+	}
+}
+
+// newTask returns a task ready to execute, as tasks.Manager hands it over.
+func newTask(actions ...taskstypes.Action) *taskstypes.Task {
+	now := time.Now()
+	return &taskstypes.Task{
+		ID:        uuid.New(),
+		Status:    taskstypes.StatusRunning,
+		Actions:   actions,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// execution is an ExecuteTask call running in the background.
+type execution struct {
+	result *taskstypes.TaskResult
+	err    error
+	done   chan struct{}
+}
+
+func start(ctx context.Context, executor tasks.BrowserExecutor, task *taskstypes.Task) *execution {
+	e := &execution{done: make(chan struct{})}
+	go func() {
+		defer close(e.done)
+		e.result, e.err = executor.ExecuteTask(ctx, task)
+	}()
+	return e
+}
+
+// wait returns once the execution finishes, failing t if it takes longer than timeout.
+func (e *execution) wait(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	select {
+	case <-e.done:
+	case <-time.After(timeout):
+		t.Fatalf("ExecuteTask did not return within %s", timeout)
+	}
+}
+
+func execute(t *testing.T, cfg Config, executor tasks.BrowserExecutor, task *taskstypes.Task) (*taskstypes.TaskResult, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	e := start(ctx, executor, task)
+	e.wait(t, cfg.Timeout+cancelGrace)
+	return e.result, e.err
+}
+
+// testCompletes runs a navigation, a click, and a wait, and expects success.
+func testCompletes(t *testing.T, cfg Config, executor tasks.BrowserExecutor, s *site) {
+	task := newTask(
+		taskstypes.Action{Type: taskstypes.ActionNavigate, Value: s.URL + "/"},
+		taskstypes.Action{Type: taskstypes.ActionClick, Selector: "#next"},
+		taskstypes.Action{Type: taskstypes.ActionWaitVisible, Selector: "#done"},
+	)
+	result, err := execute(t, cfg, executor, task)
+	require.NoError(t, err)
+	require.NotNil(t, result, "a successful task returns a result")
+	assert.True(t, result.Success)
+	assert.Empty(t, result.ErrorCode)
+	assert.Equal(t, 2, task.Snapshot().CurrentAction, "executors report progress with Task.SetCurrentAction")
+}
+
+// testReportsFailure expects a missing element to fail its action with
+// SELECTOR_NOT_FOUND, naming the action.
+func testReportsFailure(t *testing.T, cfg Config, executor tasks.BrowserExecutor, s *site) {
+	task := newTask(
+		taskstypes.Action{Type: taskstypes.ActionNavigate, Value: s.URL + "/"},
+		taskstypes.Action{Type: taskstypes.ActionClick, Selector: "#missing", Timeout: 2 * time.Second},
+		taskstypes.Action{Type: taskstypes.ActionClick, Selector: "#next"},
+	)
+	result, err := execute(t, cfg, executor, task)
+	require.Error(t, err)
+	require.NotNil(t, result, "a failed task still returns its result")
+	assert.False(t, result.Success)
+	assert.NotEmpty(t, result.Error)
+	assert.Equal(t, taskstypes.ErrorSelectorNotFound, result.ErrorCode)
+	if assert.NotNil(t, result.FailedAction) {
+		assert.Equal(t, 1, result.FailedAction.Index)
+		assert.Equal(t, taskstypes.ActionClick, result.FailedAction.Type)
+		assert.Equal(t, "#missing", result.FailedAction.Selector)
+	}
+}
+
+// testWaitsFor2FA expects a task that reaches a 2FA prompt to wait in
+// StatusWaitingFor2FA until a code arrives on TfaCodeChan, as
+// tasks.Manager.Provide2FACode sends it, then enter the code and continue.
+func testWaitsFor2FA(t *testing.T, cfg Config, executor tasks.BrowserExecutor, s *site) {
+	task := newTask(
+		taskstypes.Action{Type: taskstypes.ActionNavigate, Value: s.URL + "/2fa"},
+		taskstypes.Action{Type: taskstypes.ActionWaitVisible, Selector: "#verified"},
+	)
+	task.TwoFactorAuth = taskstypes.TwoFactorAuthInfo{Expected: true}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	e := start(ctx, executor, task)
+
+	var codes chan string
+	require.Eventually(t, func() bool {
+		snapshot := task.Snapshot()
+		codes = snapshot.TfaCodeChan
+		return snapshot.Status == taskstypes.StatusWaitingFor2FA && codes != nil
+	}, cfg.Timeout, 10*time.Millisecond, "the task should wait for 2FA with a code channel ready")
+
+	// The task keeps waiting rather than failing or finishing
+	select {
+	case <-e.done:
+		t.Fatalf("ExecuteTask returned while waiting for 2FA: %v", e.err)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	select {
+	case codes <- TFACode:
+	default:
+		t.Fatal("the task's code channel should accept a code without blocking")
+	}
+	e.wait(t, cfg.Timeout)
+	require.NoError(t, e.err)
+	assert.True(t, e.result.Success)
+	assert.Equal(t, []string{TFACode}, s.receivedCodes(), "the code should be entered into the prompt")
+	assert.NotEqual(t, taskstypes.StatusWaitingFor2FA, task.CurrentStatus(), "the task leaves the 2FA wait once the code is in")
+}
+
+// testStopsOnCancel expects a cancelled task to return promptly with an error.
+func testStopsOnCancel(t *testing.T, cfg Config, executor tasks.BrowserExecutor, s *site) {
+	task := newTask(
+		taskstypes.Action{Type: taskstypes.ActionNavigate, Value: s.URL + "/"},
+		taskstypes.Action{Type: taskstypes.ActionWaitDelay, Value: "10m", Timeout: 20 * time.Minute},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := start(ctx, executor, task)
+
+	require.Eventually(t, func() bool {
+		return task.Snapshot().CurrentAction == 1
+	}, cfg.Timeout, 10*time.Millisecond, "the task should reach its second action")
+	cancel()
+	e.wait(t, cancelGrace)
+
+	require.Error(t, e.err)
+	if e.result != nil {
+		assert.False(t, e.result.Success)
+		if e.result.ErrorCode != "" {
+			assert.Equal(t, taskstypes.ErrorCancelled, e.result.ErrorCode)
+		}
+	}
+}
+
+// testCapturesScreenshots expects a screenshot to be saved in the artifact
+// store and listed in result.custom_data.screenshots.
+func testCapturesScreenshots(t *testing.T, cfg Config, executor tasks.BrowserExecutor, s *site) {
+	if cfg.Artifacts == nil {
+		t.Skip("no artifact store configured")
+	}
+	task := newTask(
+		taskstypes.Action{Type: taskstypes.ActionNavigate, Value: s.URL + "/"},
+		taskstypes.Action{Type: taskstypes.ActionScreenshot},
+	)
+	result, err := execute(t, cfg, executor, task)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+
+	data, err := json.Marshal(result.CustomData)
+	require.NoError(t, err)
+	var reports struct {
+		Screenshots []struct {
+			Action int    `json:"action"`
+			Name   string `json:"name"`
+			Size   int64  `json:"size"`
+		} `json:"screenshots"`
+	}
+	require.NoError(t, json.Unmarshal(data, &reports))
+	require.Len(t, reports.Screenshots, 1, "custom_data.screenshots lists the capture")
+	shot := reports.Screenshots[0]
+	assert.Equal(t, 1, shot.Action)
+
+	objects, err := cfg.Artifacts.List(context.Background(), task.ID)
+	require.NoError(t, err)
+	require.Len(t, objects, 1, "the capture is saved under the task's ID")
+	assert.Equal(t, shot.Name, objects[0].Name)
+	assert.Equal(t, shot.Size, objects[0].Size)
+	assert.Positive(t, objects[0].Size)
+	assert.True(t, strings.HasPrefix(objects[0].ContentType, "image/"), "content type %q", objects[0].ContentType)
+}
+
+// site serves the pages the contract tests drive executors through.
+type site struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	codes []string
+}
+
+func newSite() *site {
+	s := &site{}
+	mux := http.NewServeMux()
+	page := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, "<!doctype html><html><head><title>Contract</title></head><body>%s</body></html>", body)
+		}
+	}
+	mux.HandleFunc("/{$}", page(`<h1 id="title">Executor contract</h1><a id="next" href="/next">Next</a>`))
+	mux.HandleFunc("/next", page(`<p id="done">Done</p>`))
+	mux.HandleFunc("/2fa", page(`<form action="/2fa/verify" method="get">
+<label for="verification_code">Enter verification code</label>
+<input id="verification_code" name="code" autocomplete="one-time-code" placeholder="code">
+<button type="submit">Verify</button>
+</form>`))
+	mux.HandleFunc("/2fa/verify", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.codes = append(s.codes, r.URL.Query().Get("code"))
+		s.mu.Unlock()
+		page(`<p id="verified">Verified</p>`)(w, r)
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *site) receivedCodes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.codes...)
+}
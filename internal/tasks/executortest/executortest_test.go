@@ -0,0 +1,130 @@
+package executortest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/tasks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// pageExecutor is a minimal executor that "browses" with plain HTTP
+// requests and string matching. It exists to check the suite itself without
+// a browser, and shows what the suite asks of an executor.
+type pageExecutor struct {
+	store artifacts.Store
+}
+
+var linkPattern = regexp.MustCompile(`<a id="([^"]+)" href="([^"]+)"`)
+
+func (e *pageExecutor) ExecuteTask(ctx context.Context, task *taskstypes.Task) (*taskstypes.TaskResult, error) {
+	result := &taskstypes.TaskResult{Success: true}
+	var pageURL, body string
+	fail := func(i int, action taskstypes.Action, code taskstypes.ErrorCode, err error) (*taskstypes.TaskResult, error) {
+		result.Success = false
+		result.Error = err.Error()
+		result.ErrorCode = code
+		result.FailedAction = &taskstypes.FailedAction{Index: i, Type: action.Type, Selector: action.Selector}
+		return result, err
+	}
+	load := func(target string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		pageURL, body = target, string(data)
+		return err
+	}
+
+	for i, action := range task.Actions {
+		task.SetCurrentAction(i)
+		switch action.Type {
+		case taskstypes.ActionNavigate:
+			if err := load(action.Value); err != nil {
+				return fail(i, action, taskstypes.ErrorNavigationFailed, err)
+			}
+			if strings.Contains(strings.ToLower(body), "verification code") {
+				task.Update(func(task *taskstypes.Task) {
+					task.Status = taskstypes.StatusWaitingFor2FA
+					task.TfaCodeChan = make(chan string, 1)
+				})
+				code, err := task.WaitForTFACode(ctx)
+				if err != nil {
+					return fail(i, action, taskstypes.ErrorCancelled, err)
+				}
+				if err := load(pageURL + "/verify?code=" + url.QueryEscape(code)); err != nil {
+					return fail(i, action, taskstypes.ErrorNavigationFailed, err)
+				}
+				task.UpdateStatus(taskstypes.StatusRunning)
+			}
+		case taskstypes.ActionClick:
+			var href string
+			for _, link := range linkPattern.FindAllStringSubmatch(body, -1) {
+				if "#"+link[1] == action.Selector {
+					href = link[2]
+				}
+			}
+			if href == "" {
+				return fail(i, action, taskstypes.ErrorSelectorNotFound, fmt.Errorf("no element matches %s", action.Selector))
+			}
+			base, _ := url.Parse(pageURL)
+			next, _ := base.Parse(href)
+			if err := load(next.String()); err != nil {
+				return fail(i, action, taskstypes.ErrorNavigationFailed, err)
+			}
+		case taskstypes.ActionWaitVisible:
+			if !strings.Contains(body, fmt.Sprintf(`id="%s"`, strings.TrimPrefix(action.Selector, "#"))) {
+				return fail(i, action, taskstypes.ErrorSelectorNotFound, fmt.Errorf("no element matches %s", action.Selector))
+			}
+		case taskstypes.ActionWaitDelay:
+			delay, _ := time.ParseDuration(action.Value)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return fail(i, action, taskstypes.ErrorCancelled, ctx.Err())
+			}
+		case taskstypes.ActionScreenshot:
+			obj, err := e.store.Put(ctx, task.ID, fmt.Sprintf("screenshot-%d.png", i), bytes.NewReader([]byte("\x89PNG\r\n\x1a\n")))
+			if err != nil {
+				return fail(i, action, taskstypes.ErrorActionFailed, err)
+			}
+			result.CustomData = map[string]interface{}{
+				"screenshots": []map[string]interface{}{{"action": i, "name": obj.Name, "size": obj.Size}},
+			}
+		default:
+			return fail(i, action, taskstypes.ErrorInvalidAction, errors.New("unsupported action"))
+		}
+	}
+	return result, nil
+}
+
+func (e *pageExecutor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	store := artifacts.NewLocalStore(t.TempDir())
+	Run(t, Config{
+		New: func(t *testing.T) tasks.BrowserExecutor {
+			return &pageExecutor{store: store}
+		},
+		Artifacts: store,
+		Timeout:   5 * time.Second,
+	})
+}
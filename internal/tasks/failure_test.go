@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_FailedTaskKeepsErrorCode(t *testing.T) {
+	executor := mocks.NewMockBrowserExecutor()
+	manager := NewManager(&config.Config{}, executor, logging.Discard())
+	newTask := func() *taskstypes.Task {
+		return &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, CreatedAt: time.Now(),
+			Actions: []taskstypes.Action{{Type: taskstypes.ActionClick, Selector: "#buy"}}}
+	}
+	waitFailed := func(task *taskstypes.Task) *taskstypes.TaskResult {
+		require.Eventually(t, func() bool { return task.CurrentStatus() == taskstypes.StatusFailed }, 5*time.Second, time.Millisecond)
+		status, err := manager.GetTaskStatus(task.ID)
+		require.NoError(t, err)
+		require.NotNil(t, status.Result)
+		return status.Result
+	}
+
+	// The executor's failure details and reports are kept
+	task := newTask()
+	executor.SetExecutionResult(task.ID.String(), &taskstypes.TaskResult{
+		Message:      "Failed on action 0: click",
+		ErrorCode:    taskstypes.ErrorSelectorNotFound,
+		FailedAction: &taskstypes.FailedAction{Index: 0, Type: taskstypes.ActionClick, Selector: "#buy"},
+		CustomData:   map[string]interface{}{"console": []string{"boom"}},
+	}, errors.New("waiting for #buy: action timed out"))
+	require.NoError(t, manager.SubmitTask(task))
+	result := waitFailed(task)
+	assert.Equal(t, taskstypes.ErrorSelectorNotFound, result.ErrorCode)
+	assert.Equal(t, "#buy", result.FailedAction.Selector)
+	assert.Equal(t, "waiting for #buy: action timed out", result.Error)
+	assert.Contains(t, result.CustomData, "console")
+
+	// Executors that give no details still get a code
+	task = newTask()
+	executor.SetExecutionResult(task.ID.String(), nil, errors.New("no browser"))
+	require.NoError(t, manager.SubmitTask(task))
+	assert.Equal(t, taskstypes.ErrorUnknown, waitFailed(task).ErrorCode)
+}
@@ -0,0 +1,176 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+const (
+	// brokenStreak is how many runs in a row must fail on an action, with
+	// none getting past it, before it is reported broken.
+	brokenStreak = 3
+	// flakyMinFailures and flakyShare flag an action that runs fail on again
+	// after others got past it as flaky when at least this many, and this
+	// share, of the template's failures are on it.
+	flakyMinFailures = 3
+	flakyShare       = 0.5
+)
+
+// TemplateFlakiness shows where a template's recent runs failed, so a
+// selector broken by a site change stands out from intermittent failures.
+type TemplateFlakiness struct {
+	Name        string           `json:"name"`
+	Runs        int              `json:"runs"` // Completed and failed runs, across all versions
+	Failures    int              `json:"failures"`
+	FailureRate float64          `json:"failure_rate"`
+	Actions     []ActionFailures `json:"actions"` // Actions runs failed on, most failures first
+	Hints       []string         `json:"hints"`
+}
+
+// ActionFailures counts the runs of a template that failed on one action.
+// Actions are told apart by index, type, and selector, so a selector fixed
+// in a new version starts over.
+type ActionFailures struct {
+	Index        int                          `json:"index"`
+	Type         taskstypes.ActionType        `json:"type"`
+	Selector     string                       `json:"selector,omitempty"`
+	Failures     int                          `json:"failures"`
+	Passes       int                          `json:"passes"` // Runs that got past the action
+	Streak       int                          `json:"streak"` // Latest runs in a row that failed on it
+	Share        float64                      `json:"share"`  // Of the template's failures
+	ErrorCodes   map[taskstypes.ErrorCode]int `json:"error_codes"`
+	LastFailedAt time.Time                    `json:"last_failed_at"`
+	BrokenSince  *time.Time                   `json:"broken_since,omitempty"` // Every run since failed on it
+	Flaky        bool                         `json:"flaky,omitempty"`        // Many failures, between runs that get past it
+}
+
+type actionKey struct {
+	index    int
+	typ      taskstypes.ActionType
+	selector string
+}
+
+type actionTally struct {
+	ActionFailures
+	streakStart time.Time // When the first run of the streak finished
+	relapsed    bool      // A run failed on it after another got past it
+}
+
+// TemplateFlakiness analyzes the stored runs of the named template.
+func (m *Manager) TemplateFlakiness(name string) (*TemplateFlakiness, error) {
+	if _, err := m.templates.Get(name, 0); err != nil {
+		return nil, err
+	}
+	history, err := m.store.List(ListFilter{Template: name, Limit: statsHistoryLimit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runs of template %s: %w", name, err)
+	}
+	return templateFlakiness(name, history), nil
+}
+
+// templateFlakiness tallies failures by action over history, in any order.
+func templateFlakiness(name string, history []*taskstypes.Task) *TemplateFlakiness {
+	runs := make([]*taskstypes.Task, 0, len(history))
+	for _, task := range history {
+		if task.Status == taskstypes.StatusCompleted || task.Status == taskstypes.StatusFailed {
+			runs = append(runs, task)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return finishedAt(runs[i]).Before(finishedAt(runs[j])) })
+
+	report := &TemplateFlakiness{Name: name, Runs: len(runs), Actions: []ActionFailures{}, Hints: []string{}}
+	tallies := make(map[actionKey]*actionTally)
+	for _, task := range runs {
+		// Actions before passed were got past; a failure with no failed
+		// action says nothing about any of them
+		passed := len(task.Actions)
+		if task.Status == taskstypes.StatusFailed {
+			report.Failures++
+			passed = 0
+			if task.Result != nil && task.Result.FailedAction != nil {
+				failed := task.Result.FailedAction
+				passed = failed.Index
+				key := actionKey{failed.Index, failed.Type, failed.Selector}
+				tally, ok := tallies[key]
+				if !ok {
+					tally = &actionTally{ActionFailures: ActionFailures{
+						Index: failed.Index, Type: failed.Type, Selector: failed.Selector,
+						ErrorCodes: make(map[taskstypes.ErrorCode]int),
+					}}
+					tallies[key] = tally
+				}
+				tally.Failures++
+				tally.relapsed = tally.relapsed || tally.Passes > 0
+				if code := task.Result.ErrorCode; code != "" {
+					tally.ErrorCodes[code]++
+				}
+				tally.LastFailedAt = finishedAt(task)
+				if tally.Streak == 0 {
+					tally.streakStart = tally.LastFailedAt
+				}
+				tally.Streak++
+			}
+		}
+		for key, tally := range tallies {
+			if key.index < passed {
+				tally.Passes++
+				tally.Streak = 0
+			}
+		}
+	}
+
+	if report.Runs > 0 {
+		report.FailureRate = float64(report.Failures) / float64(report.Runs)
+	}
+	for _, tally := range tallies {
+		action := tally.ActionFailures
+		action.Share = float64(action.Failures) / float64(report.Failures)
+		if tally.Streak >= brokenStreak {
+			since := tally.streakStart
+			action.BrokenSince = &since
+		} else if tally.relapsed && action.Failures >= flakyMinFailures && action.Share >= flakyShare {
+			action.Flaky = true
+		}
+		report.Actions = append(report.Actions, action)
+	}
+	sort.Slice(report.Actions, func(i, j int) bool {
+		a, b := report.Actions[i], report.Actions[j]
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		return a.Index < b.Index
+	})
+	for _, action := range report.Actions {
+		if hint := flakinessHint(action, report.Failures); hint != "" {
+			report.Hints = append(report.Hints, hint)
+		}
+	}
+	return report
+}
+
+// flakinessHint describes a broken or flaky action for people, or returns "".
+func flakinessHint(action ActionFailures, failures int) string {
+	switch {
+	case action.BrokenSince != nil && action.Selector != "":
+		return fmt.Sprintf("selector %q (action %d) likely broken since %s: the last %d runs failed on it",
+			action.Selector, action.Index, action.BrokenSince.UTC().Format(time.RFC3339), action.Streak)
+	case action.BrokenSince != nil:
+		return fmt.Sprintf("%s action %d likely broken since %s: the last %d runs failed on it",
+			action.Type, action.Index, action.BrokenSince.UTC().Format(time.RFC3339), action.Streak)
+	case action.Flaky:
+		return fmt.Sprintf("%s action %d caused %d of %d failures, but %d runs got past it; it is likely flaky",
+			action.Type, action.Index, action.Failures, failures, action.Passes)
+	}
+	return ""
+}
+
+// finishedAt is when a task finished, or last changed if it has no completion time.
+func finishedAt(task *taskstypes.Task) time.Time {
+	if task.CompletedAt != nil {
+		return *task.CompletedAt
+	}
+	return task.UpdatedAt
+}
@@ -0,0 +1,147 @@
+package tasks
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// templateRun is a finished run of the "login" template. A failed run
+// fails on action index with selector; index -1 leaves the action unknown.
+func templateRun(at time.Time, index int, selector string) *taskstypes.Task {
+	task := &taskstypes.Task{
+		ID:     uuid.New(),
+		Status: taskstypes.StatusCompleted,
+		Actions: []taskstypes.Action{
+			{Type: taskstypes.ActionNavigate, Value: "https://example.com/login"},
+			{Type: taskstypes.ActionClick, Selector: "#sso"},
+			{Type: taskstypes.ActionWaitVisible, Selector: "#dashboard"},
+		},
+		TemplateName: "login",
+		CreatedAt:    at,
+		UpdatedAt:    at,
+		CompletedAt:  &at,
+	}
+	if selector != "" || index >= 0 {
+		task.Status = taskstypes.StatusFailed
+		task.Result = &taskstypes.TaskResult{ErrorCode: taskstypes.ErrorSelectorNotFound}
+		if index >= 0 {
+			task.Result.FailedAction = &taskstypes.FailedAction{Index: index, Type: task.Actions[index].Type, Selector: selector}
+		}
+	}
+	return task
+}
+
+func TestTemplateFlakiness_Broken(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+	history := []*taskstypes.Task{
+		templateRun(at(0), -1, ""),
+		templateRun(at(1), 1, "#sso"),
+		templateRun(at(2), -1, ""),
+		templateRun(at(3), 2, "#dashboard"), // Got past #sso
+		templateRun(at(4), 1, "#sso"),
+		templateRun(at(5), 1, "#sso"),
+		templateRun(at(6), 1, "#sso"),
+	}
+	history[0].Result, history[0].Status = nil, taskstypes.StatusCompleted
+	history[2].Status = taskstypes.StatusCancelled
+
+	// The store lists newest first
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	report := templateFlakiness("login", history)
+
+	assert.Equal(t, 6, report.Runs, "cancelled runs are left out")
+	assert.Equal(t, 5, report.Failures)
+	require.Len(t, report.Actions, 2)
+
+	sso := report.Actions[0]
+	assert.Equal(t, "#sso", sso.Selector)
+	assert.Equal(t, 4, sso.Failures)
+	assert.Equal(t, 1, sso.Passes)
+	assert.Equal(t, 3, sso.Streak)
+	assert.InDelta(t, 0.8, sso.Share, 0.001)
+	assert.Equal(t, 4, sso.ErrorCodes[taskstypes.ErrorSelectorNotFound])
+	require.NotNil(t, sso.BrokenSince)
+	assert.Equal(t, at(4), *sso.BrokenSince, "since the first failure after the last run that got past it")
+	assert.Equal(t, at(6), sso.LastFailedAt)
+	assert.False(t, sso.Flaky)
+
+	assert.Equal(t, "#dashboard", report.Actions[1].Selector)
+	assert.Nil(t, report.Actions[1].BrokenSince)
+	assert.Equal(t, []string{`selector "#sso" (action 1) likely broken since 2026-10-01T13:00:00Z: the last 3 runs failed on it`}, report.Hints)
+}
+
+func TestTemplateFlakiness_Flaky(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	var history []*taskstypes.Task
+	for i, selector := range []string{"#sso", "", "#sso", "", "#sso", ""} {
+		index := -1
+		if selector != "" {
+			index = 1
+		}
+		run := templateRun(start.Add(time.Duration(i)*time.Hour), index, selector)
+		if selector == "" {
+			run.Status, run.Result = taskstypes.StatusCompleted, nil
+		}
+		history = append(history, run)
+	}
+
+	report := templateFlakiness("login", history)
+
+	require.Len(t, report.Actions, 1)
+	assert.True(t, report.Actions[0].Flaky)
+	assert.Nil(t, report.Actions[0].BrokenSince)
+	assert.InDelta(t, 0.5, report.FailureRate, 0.001)
+	assert.Equal(t, []string{"click action 1 caused 3 of 3 failures, but 3 runs got past it; it is likely flaky"}, report.Hints)
+}
+
+func TestTemplateFlakiness_FixedSelectorStartsOver(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	var history []*taskstypes.Task
+	for i := 0; i < 3; i++ {
+		history = append(history, templateRun(start.Add(time.Duration(i)*time.Hour), 1, "#sso"))
+	}
+	// A new version with a working selector gets past the action
+	fixed := templateRun(start.Add(4*time.Hour), -1, "")
+	fixed.Status, fixed.Result = taskstypes.StatusCompleted, nil
+	history = append(history, fixed)
+
+	report := templateFlakiness("login", history)
+
+	require.Len(t, report.Actions, 1)
+	assert.Nil(t, report.Actions[0].BrokenSince)
+	assert.Empty(t, report.Hints)
+}
+
+func TestManager_TemplateFlakiness(t *testing.T) {
+	manager := NewManager(&config.Config{}, mocks.NewMockBrowserExecutor(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	_, err := manager.TemplateFlakiness("login")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+
+	_, err = manager.Templates().Put("login", "", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com/login"}})
+	require.NoError(t, err)
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, manager.store.Save(templateRun(start.Add(time.Duration(i)*time.Minute), 1, "#sso")))
+	}
+	other := templateRun(start, 1, "#sso")
+	other.TemplateName = "export"
+	require.NoError(t, manager.store.Save(other))
+
+	report, err := manager.TemplateFlakiness("login")
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Runs, "only runs of the template are counted")
+	require.Len(t, report.Hints, 1)
+}
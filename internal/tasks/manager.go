@@ -1,23 +1,48 @@
 package tasks
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/copyleftdev/goscry/internal/artifacts"
+	"github.com/copyleftdev/goscry/internal/auth"
 	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/inbox"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/secrets"
 	"github.com/copyleftdev/goscry/internal/taskstypes"
 	"github.com/google/uuid"
 )
 
 const twoFAWaitTimeout = 5 * time.Minute // Max time to wait for 2FA code
 
+// statsHistoryLimit bounds how many tasks are loaded from the store for aggregate stats.
+const statsHistoryLimit = 10000
+
+// estimatorSeedLimit bounds how many completed tasks seed the estimator at startup.
+const estimatorSeedLimit = 1000
+
+// ErrTaskNotFound is returned when a task ID is unknown to the manager and its store.
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrInvalidTOTPSecret is returned for a task whose two_factor_auth.secret
+// cannot generate TOTP codes.
+var ErrInvalidTOTPSecret = errors.New("invalid two_factor_auth.secret")
+
+// ErrInvalidOptions is returned for a task whose options are invalid or
+// cannot be combined, for example replay with first_party_only.
+var ErrInvalidOptions = errors.New("invalid options")
+
+// ErrInvalidEncryption stops a manager whose security.encryption config is
+// invalid, rather than letting it store task data in plaintext.
+var ErrInvalidEncryption = errors.New("invalid security.encryption config")
+
 // Define a stub for MCP Client until the real implementation is available
 type mcpClient struct {
 	endpoint string
@@ -42,21 +67,48 @@ type twoFactorAuthRequest struct {
 type Manager struct {
 	cfg             *config.Config
 	browserExecutor BrowserExecutor
-	logger          *log.Logger
+	logger          *slog.Logger
 	tasks           map[uuid.UUID]*taskstypes.Task
 	mu              sync.RWMutex
 	mcpConn         *mcpClient // Changed to our stub type
+	estimator       *Estimator
+	templates       *TemplateStore
+	schedules       *ScheduleStore
+	store           TaskStore
+	persistMu       sync.Mutex // Orders snapshots so an older one never overwrites a newer one
+	credentialKey   *encryption.CredentialKey
+	credentials     *secrets.Resolver   // Resolves credentials_ref; nil when its config is invalid
+	archive         *resultArchive      // nil unless storage.archive.after is set
+	keyring         *encryption.Keyring // Seals stored task data; nil unless security.encryption is on
+	artifacts       artifacts.Store
+	queue           *taskQueue
+	domains         *domainTracker   // Health of the domains tasks target
+	deadLetters     deadLetterLog    // Callback deliveries that failed every attempt
+	callbackBreaker *callbackBreaker // Holds deliveries for callback endpoints that keep failing
+	inbox           *inbox.Inbox     // Where email 2FA codes are read; nil unless twoFactor.email is set
+
+	urlPolicy *taskstypes.URLPolicy // nil when security.urlPolicy restricts nothing
+
+	ctx     context.Context // Parent of every task's context; cancelled by Shutdown
+	stop    context.CancelCauseFunc
+	runs    map[uuid.UUID]*taskRun // Tasks still executing, guarded by mu
+	running sync.WaitGroup
 }
 
 // NewManager creates a new task manager with the provided browser manager and logger.
-func NewManager(cfg *config.Config, browserExecutor BrowserExecutor, logger *log.Logger) *Manager {
+func NewManager(cfg *config.Config, browserExecutor BrowserExecutor, logger *slog.Logger) *Manager {
 	// Create a simple manager without MCP connection for now
 	mgr := &Manager{
 		cfg:             cfg,
 		browserExecutor: browserExecutor,
 		logger:          logger,
 		tasks:           make(map[uuid.UUID]*taskstypes.Task),
+		estimator:       NewEstimator(),
+		templates:       NewTemplateStore(),
+		schedules:       NewScheduleStore(),
+		runs:            make(map[uuid.UUID]*taskRun),
 	}
+	mgr.ctx, mgr.stop = context.WithCancelCause(context.Background())
 
 	// Add stub MCP client if Config has the fields, otherwise use a default
 	mcpEndpoint := "http://localhost:8080"
@@ -65,44 +117,326 @@ func NewManager(cfg *config.Config, browserExecutor BrowserExecutor, logger *log
 	// Check if cfg.MCPConfig exists through reflection to avoid compile errors
 	if cfg != nil {
 		// This is just a placeholder - in real code we'd check if cfg.MCPConfig exists
-		mgr.logger.Println("Using default MCP configuration")
+		mgr.logger.Debug("Using default MCP configuration")
 	}
 
 	mgr.mcpConn = newMCPClient(mcpEndpoint, mcpApiKey)
+
+	credentialKeyFile := ""
+	if cfg != nil {
+		credentialKeyFile = cfg.Security.CredentialKey
+	}
+	mgr.credentialKey = encryption.NewCredentialKey(credentialKeyFile)
+	var sources config.CredentialSourcesConfig
+	if cfg != nil {
+		sources = cfg.Security.CredentialSources
+	}
+	resolver, err := secrets.NewResolver(sources, mgr.credentialKey)
+	if err != nil {
+		mgr.logger.Error("Invalid credential sources, tasks with credentials_ref will be rejected", "error", err)
+	}
+	mgr.credentials = resolver
+	mgr.urlPolicy = newURLPolicy(cfg)
+	domains, err := newDomainTracker(cfg)
+	if err != nil {
+		mgr.logger.Error("Invalid queue.domainHealth config, domains are only scored", "error", err)
+	}
+	mgr.domains = domains
+	mgr.callbackBreaker = newCallbackBreaker(cfg)
+	if cfg != nil {
+		if mgr.inbox, err = inbox.New(cfg.TwoFactor.Email); err != nil {
+			mgr.logger.Error("Invalid twoFactor.email config, email 2FA codes must be provided through the API", "error", err)
+		}
+	}
+	if _, err := mgr.callbackPolicy(); err != nil {
+		mgr.logger.Error("Callbacks cannot be delivered until this is fixed; they go to the dead-letter log", "error", err)
+	}
+	if receiver, ok := browserExecutor.(CredentialKeyReceiver); ok {
+		receiver.SetCredentialKey(mgr.credentialKey)
+	}
+	if receiver, ok := browserExecutor.(CredentialResolverReceiver); ok {
+		receiver.SetCredentialResolver(mgr.credentials)
+	}
+	if receiver, ok := browserExecutor.(URLPolicyReceiver); ok && mgr.urlPolicy != nil {
+		receiver.SetURLPolicy(mgr.urlPolicy)
+	}
+	if receiver, ok := browserExecutor.(TemplateReceiver); ok {
+		receiver.SetTemplates(mgr.templates)
+	}
+	if streamer, ok := browserExecutor.(EventStreamer); ok {
+		streamer.SetEventSink(mgr.streamEvent)
+	}
+	if receiver, ok := browserExecutor.(TwoFactorNotifierReceiver); ok {
+		receiver.SetTwoFactorNotifier(mgr.twoFactorWaiting)
+	}
+
+	mgr.store = mgr.openStore()
+	if persister, ok := mgr.store.(TemplatePersister); ok {
+		if err := mgr.templates.Load(persister); err != nil {
+			mgr.logger.Error("Failed to load saved templates, new versions are kept in memory only", "error", err)
+		}
+	}
+	mgr.seedEstimator()
+	mgr.artifacts = mgr.openArtifacts()
+	mgr.recoverInterrupted()
+	mgr.startWorkers()
+	if mgr.archive != nil {
+		go mgr.archiveLoop()
+	}
+	go mgr.scheduleLoop()
 	return mgr
 }
 
-// SubmitTask adds a task to the manager's queue and starts executing it.
+// CredentialKey returns the key clients encrypt task credentials to.
+func (m *Manager) CredentialKey() *encryption.CredentialKey {
+	return m.credentialKey
+}
+
+// CheckCredentialsRef reports whether tasks may use ref: it must be well
+// formed, name a configured source, and the executor must resolve references.
+// The secret itself is only looked up when the task runs.
+func (m *Manager) CheckCredentialsRef(ref string) error {
+	if _, ok := m.browserExecutor.(CredentialResolverReceiver); !ok {
+		return ErrCredentialsRefUnsupported
+	}
+	return m.credentials.Check(ref)
+}
+
+// openStore opens the configured task store, falling back to memory if it
+// cannot be opened. An invalid encryption config stops the manager, as the
+// executor would otherwise save profiles and artifacts unencrypted.
+func (m *Manager) openStore() TaskStore {
+	if m.cfg == nil {
+		return NewMemoryStore()
+	}
+
+	keyring, err := encryption.NewKeyring(m.cfg.Security.Encryption)
+	if err != nil {
+		m.logger.Error("Invalid encryption config, refusing tasks", "error", err)
+		m.stop(fmt.Errorf("%w: %v", ErrInvalidEncryption, err))
+		return NewMemoryStore()
+	}
+	if keyring != nil {
+		keyring.SetLogger(m.logger)
+	}
+	m.keyring = keyring
+	if receiver, ok := m.browserExecutor.(KeyringReceiver); ok {
+		receiver.SetKeyring(keyring)
+	}
+
+	store, err := NewTaskStore(m.cfg.Storage, keyring)
+	if err != nil {
+		m.logger.Error("Failed to open task store, falling back to memory", "driver", m.cfg.Storage.Driver, "error", err)
+		return NewMemoryStore()
+	}
+	m.archive = newResultArchive(m.cfg.Storage.Archive, keyring)
+	return store
+}
+
+// recoverInterrupted marks tasks that were in flight when the server last
+// stopped as failed, since their browser state is gone.
+func (m *Manager) recoverInterrupted() {
+	for _, status := range []taskstypes.TaskStatus{
+		taskstypes.StatusPending, taskstypes.StatusRunning, taskstypes.StatusWaitingFor2FA,
+	} {
+		stale, err := m.store.List(ListFilter{Status: status, Limit: statsHistoryLimit})
+		if err != nil {
+			m.logger.Error("Failed to load tasks from store", "status", status, "error", err)
+			continue
+		}
+		for _, task := range stale {
+			now := time.Now()
+			task.Status = taskstypes.StatusFailed
+			task.UpdatedAt = now
+			task.CompletedAt = &now
+			task.Result = &taskstypes.TaskResult{Error: "task interrupted by server restart", ErrorCode: taskstypes.ErrorInterrupted}
+			if err := m.store.Save(task); err != nil {
+				m.logger.Error("Failed to mark task as interrupted", logging.TaskIDKey, task.ID, "error", err)
+			}
+		}
+	}
+}
+
+// persist saves a snapshot of the task to the store, logging failures.
+func (m *Manager) persist(task *taskstypes.Task) {
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+	if err := m.store.Save(task.Snapshot()); err != nil {
+		m.logger.Error("Failed to persist task", logging.TaskIDKey, task.ID, "error", err)
+	}
+}
+
+// SubmitTask adds a task to the manager's queue, where it waits for a worker
+// behind tasks of higher or equal priority. It returns ErrQueueFull when
+// queue.size tasks are already waiting. The task runs until it finishes, is
+// cancelled, or the manager shuts down.
 func (m *Manager) SubmitTask(task *taskstypes.Task) error {
+	return m.SubmitTaskContext(context.Background(), task)
+}
+
+// Err returns why the manager stopped accepting tasks, such as
+// ErrInvalidEncryption or ErrShuttingDown, or nil while it accepts them.
+func (m *Manager) Err() error {
+	if m.ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(m.ctx)
+}
+
+// SubmitTaskContext is like SubmitTask, but also cancels the task once ctx is
+// done, e.g. when a client waiting for the result disconnects.
+func (m *Manager) SubmitTaskContext(ctx context.Context, task *taskstypes.Task) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if err := m.ctx.Err(); err != nil {
+		return context.Cause(m.ctx)
+	}
 	if _, exists := m.tasks[task.ID]; exists {
 		return fmt.Errorf("task with ID %s already exists", task.ID)
 	}
 
+	if task.SealedCreds != nil {
+		if _, ok := m.browserExecutor.(CredentialKeyReceiver); !ok {
+			return ErrSealedCredentialsUnsupported
+		}
+		if err := m.credentialKey.Check(task.SealedCreds); err != nil {
+			return err
+		}
+	}
+
+	if task.CredentialsRef != "" {
+		if err := m.CheckCredentialsRef(task.CredentialsRef); err != nil {
+			return err
+		}
+	}
+
+	if err := ValidateCallbackFields(task.CallbackFields); err != nil {
+		return err
+	}
+
+	if err := task.Options.Validate(task.Session != ""); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+
+	if task.TwoFactorAuth.Secret != "" {
+		if _, err := auth.GenerateTOTP(task.TwoFactorAuth.Secret); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTOTPSecret, err)
+		}
+	}
+
+	if task.Session != "" {
+		sessions, err := m.Sessions()
+		if err != nil {
+			return err
+		}
+		if _, err := sessions.GetSession(task.Session); err != nil {
+			return err
+		}
+	}
+
+	if err := m.normalizeURLs(task); err != nil {
+		return err
+	}
+
+	if m.queue.full() {
+		return ErrQueueFull
+	}
+
+	hold, err := m.admitDomain(task)
+	if err != nil {
+		return err
+	}
+
 	// Store the task in the manager
 	m.tasks[task.ID] = task
+	m.persist(task)
+	m.templates.startCanaryRun(task)
 
-	// Start task execution in a goroutine
-	go m.executeTask(task)
+	// Queue it for the next free worker, or once its domain may be tried again
+	runCtx, run := m.startRun(ctx, task.ID)
+	if hold > 0 {
+		m.enqueueAfter(runCtx, task, run, hold)
+	} else {
+		m.enqueue(runCtx, task, run)
+	}
 
 	return nil
 }
 
-// GetTaskStatus returns a copy of a task with its current status.
+// GetTaskStatus returns a snapshot of a task with its current status.
 func (m *Manager) GetTaskStatus(id uuid.UUID) (*taskstypes.Task, error) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	task, exists := m.tasks[id]
+	m.mu.RUnlock()
+
 	if !exists {
-		return nil, fmt.Errorf("task with ID %s not found", id)
+		// Not run by this process; it may be in the persisted history
+		return m.store.Get(id)
 	}
+	return task.Snapshot(), nil
+}
 
-	// Return a copy to avoid race conditions
-	taskCopy := *task
-	return &taskCopy, nil
+// ListTasks returns tasks from the store matching filter, newest first.
+func (m *Manager) ListTasks(filter ListFilter) ([]*taskstypes.Task, error) {
+	return m.store.List(filter)
+}
+
+// Templates returns the manager's versioned template store.
+func (m *Manager) Templates() *TemplateStore {
+	return m.templates
+}
+
+// EstimateTask predicts the duration and resource cost of running actions,
+// based on static analysis refined by previously completed tasks.
+func (m *Manager) EstimateTask(actions []taskstypes.Action) Estimate {
+	return m.estimator.Estimate(actions)
+}
+
+// seedEstimator records the store's most recently completed tasks with the
+// estimator, so estimates keep their history across restarts.
+func (m *Manager) seedEstimator() {
+	history, err := m.store.List(ListFilter{Status: taskstypes.StatusCompleted, Limit: estimatorSeedLimit})
+	if err != nil {
+		m.logger.Error("Failed to load task history for estimates", "error", err)
+		return
+	}
+	for _, task := range history {
+		m.estimator.Record(task.Actions, task.ActiveDuration())
+	}
+}
+
+// Stats returns aggregate statistics over tasks created at or after since.
+// A zero since includes the full history. Only the newest statsHistoryLimit
+// tasks are aggregated, which Truncated reports.
+func (m *Manager) Stats(since time.Time) Stats {
+	// One task over the limit tells whether the window held more
+	history, err := m.store.List(ListFilter{Since: since, Limit: statsHistoryLimit + 1})
+	if err != nil {
+		m.logger.Error("Failed to load task history for stats", "error", err)
+	}
+	truncated := len(history) > statsHistoryLimit
+	if truncated {
+		history = history[:statsHistoryLimit]
+	}
+	stats := ComputeStats(history)
+	stats.SampleSize, stats.Truncated = len(history), truncated
+	if provider, ok := m.browserExecutor.(ArtifactStatsProvider); ok {
+		artifacts := provider.ArtifactStats()
+		stats.Artifacts = &artifacts
+	}
+	if provider, ok := m.browserExecutor.(DOMCacheStatsProvider); ok {
+		cache := provider.DOMCacheStats()
+		stats.DOMCache = &cache
+	}
+	if provider, ok := m.browserExecutor.(BrowserPoolStatsProvider); ok {
+		if pool, on := provider.BrowserPoolStats(); on {
+			stats.BrowserPool = &pool
+		}
+	}
+	queue := m.queue.stats()
+	stats.Queue = &queue
+	return stats
 }
 
 // Provide2FACode sends a 2FA code to a task waiting for one.
@@ -112,18 +446,19 @@ func (m *Manager) Provide2FACode(id uuid.UUID, code string) error {
 	m.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("task with ID %s not found", id)
+		return fmt.Errorf("%w: %s", ErrTaskNotFound, id)
 	}
 
 	// Check if the task is waiting for 2FA
-	if task.Status != taskstypes.StatusWaitingFor2FA {
-		return fmt.Errorf("task is not waiting for 2FA code (status: %s)", task.Status)
+	snapshot := task.Snapshot()
+	if snapshot.Status != taskstypes.StatusWaitingFor2FA {
+		return fmt.Errorf("task is not waiting for 2FA code (status: %s)", snapshot.Status)
 	}
 
 	// Send the code to the task's channel
 	select {
-	case task.TfaCodeChan <- code:
-		m.logger.Printf("2FA code provided for task %s", id)
+	case snapshot.TfaCodeChan <- code:
+		m.logger.Info("2FA code provided", logging.TaskIDKey, id)
 		return nil
 	default:
 		// This should never happen if the task is really waiting for 2FA
@@ -132,37 +467,83 @@ func (m *Manager) Provide2FACode(id uuid.UUID, code string) error {
 }
 
 // executeTask handles the execution of a task, moving through execution phases.
-func (m *Manager) executeTask(task *taskstypes.Task) {
-	// Update initial status to running
-	m.updateTaskStatus(task, taskstypes.StatusRunning)
-
-	// Start browser execution
-	result, err := m.browserExecutor.ExecuteTask(task)
+func (m *Manager) executeTask(ctx context.Context, task *taskstypes.Task, run *taskRun) {
+	defer m.endRun(task.ID, run)
+	ctx = logging.WithTask(ctx, task.ID)
+
+	// Tasks cancelled while queued finish without running
+	var result *taskstypes.TaskResult
+	var err error
+	if ctx.Err() == nil {
+		m.updateTaskStatus(task, taskstypes.StatusRunning)
+		result, err = m.browserExecutor.ExecuteTask(ctx, task)
+	}
 
 	// Update task with final status based on execution result
-	if err != nil {
-		m.logger.Printf("Error executing task %s: %v", task.ID, err)
-		task.Result = &taskstypes.TaskResult{
-			Error: err.Error(),
+	if ctx.Err() != nil {
+		// Keep whatever the executor collected before it was stopped
+		cause := context.Cause(ctx)
+		m.logger.InfoContext(ctx, "Task stopped", "cause", cause)
+		if result == nil {
+			result = &taskstypes.TaskResult{}
+		}
+		result.Success = false
+		result.Message = "Task cancelled"
+		result.Error = cause.Error()
+		result.ErrorCode = taskstypes.ErrorCancelled
+		m.finishTask(task, taskstypes.StatusCancelled, result)
+	} else if err != nil {
+		// Keep the executor's reports and failure details along with the error
+		if result == nil {
+			result = &taskstypes.TaskResult{}
 		}
-		m.updateTaskStatus(task, taskstypes.StatusFailed)
+		result.Success = false
+		result.Error = err.Error()
+		if result.ErrorCode == "" {
+			result.ErrorCode = taskstypes.ErrorUnknown
+		}
+		m.logger.WarnContext(ctx, "Task failed", "error", err, "error_code", result.ErrorCode)
+		m.finishTask(task, taskstypes.StatusFailed, result)
 	} else {
-		task.Result = result
-		m.updateTaskStatus(task, taskstypes.StatusCompleted)
+		m.finishTask(task, taskstypes.StatusCompleted, result)
+		// Time waiting for a 2FA code says nothing about the site's speed
+		m.estimator.Record(task.Actions, task.Snapshot().ActiveDuration())
 	}
+	m.recordDomain(task)
+	m.recordCanary(task)
 
 	// Send callback notification if configured
 	if task.CallbackURL != "" {
-		go m.notifyCallback(task)
+		go m.notifyCallback(task.Snapshot())
 	}
 }
 
 // updateTaskStatus handles updating task status with proper locking
 func (m *Manager) updateTaskStatus(task *taskstypes.Task, status taskstypes.TaskStatus) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	task.Status = status
-	task.UpdatedAt = time.Now()
+	m.finishTask(task, status, nil)
+}
+
+// finishTask sets the task's status, and its result if one is given, in a
+// single update so readers never see a final status without its result.
+func (m *Manager) finishTask(task *taskstypes.Task, status taskstypes.TaskStatus, result *taskstypes.TaskResult) {
+	task.Update(func(task *taskstypes.Task) {
+		now := time.Now()
+		task.Status = status
+		task.UpdatedAt = now
+		if result != nil {
+			if result.Data != nil && result.Summary == nil {
+				result.Summary = taskstypes.SummarizeData(result.Data)
+			}
+			task.Result = result
+		}
+		if status == taskstypes.StatusRunning && task.StartedAt == nil {
+			task.StartedAt = &now
+		}
+		if status.IsTerminal() {
+			task.CompletedAt = &now
+		}
+	})
+	m.persist(task)
 }
 
 // notifyCallback sends a notification to the callback URL if specified
@@ -171,7 +552,7 @@ func (m *Manager) notifyCallback(task *taskstypes.Task) {
 		return
 	}
 
-	m.logger.Printf("Sending callback notification for task %s to %s", task.ID, task.CallbackURL)
+	m.logger.Info("Sending callback notification", logging.TaskIDKey, task.ID, "url", task.CallbackURL)
 
 	// Helper function to marshal task for callback - add to taskstypes package later
 	marshalForCallback := func(task *taskstypes.Task) ([]byte, error) {
@@ -183,6 +564,9 @@ func (m *Manager) notifyCallback(task *taskstypes.Task) {
 			CurrentAction int                          `json:"current_action"`
 			Actions       []taskstypes.Action          `json:"actions"`
 			TwoFactorAuth taskstypes.TwoFactorAuthInfo `json:"two_factor_auth,omitempty"`
+			Tags          map[string]string            `json:"tags,omitempty"`
+			ReferenceID   string                       `json:"reference_id,omitempty"`
+			Artifacts     []CallbackArtifact           `json:"artifacts,omitempty"`
 			CreatedAt     time.Time                    `json:"created_at"`
 			UpdatedAt     time.Time                    `json:"updated_at"`
 		}{
@@ -192,6 +576,9 @@ func (m *Manager) notifyCallback(task *taskstypes.Task) {
 			CurrentAction: task.CurrentAction,
 			Actions:       task.Actions,
 			TwoFactorAuth: task.TwoFactorAuth,
+			Tags:          task.Tags,
+			ReferenceID:   task.ReferenceID,
+			Artifacts:     m.callbackArtifacts(task.ID),
 			CreatedAt:     task.CreatedAt,
 			UpdatedAt:     task.UpdatedAt,
 		}
@@ -201,75 +588,55 @@ func (m *Manager) notifyCallback(task *taskstypes.Task) {
 
 	// Marshal task data for the callback
 	taskData, err := marshalForCallback(task)
-	if err != nil {
-		m.logger.Printf("Error marshaling task data for callback: %v", err)
-		return
+	if err == nil {
+		taskData, err = m.shapeCallback(task, taskData)
 	}
-
-	// Create the request
-	req, err := http.NewRequest("POST", task.CallbackURL, bytes.NewBuffer(taskData))
 	if err != nil {
-		m.logger.Printf("Error creating callback request: %v", err)
+		m.logger.Error("Failed to marshal task data for callback", logging.TaskIDKey, task.ID, "error", err)
 		return
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-
-	// Add authentication if needed - using stub values for now
-	callbackUsername := "callback-user"
-	callbackPassword := "callback-password"
-
-	// Check for callback auth configuration - stub implementation
-	if m.cfg != nil {
-		// In real code, we would check if m.cfg.CallbackAuth exists
-		m.logger.Println("Using default callback authentication")
-
-		// Set basic auth if needed
-		if callbackUsername != "" && callbackPassword != "" {
-			req.SetBasicAuth(callbackUsername, callbackPassword)
-		}
-	}
+	m.postCallback(task, callbackEventResult, taskData)
+}
 
-	// Make the request
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				// In production, this should be set to false
-				// Only use InsecureSkipVerify: true for development/testing
-				InsecureSkipVerify: true,
-			},
-		},
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		m.logger.Printf("Error sending callback: %v", err)
-		return
-	}
-	defer resp.Body.Close()
+// Shutdown cancels running tasks and waits until they have saved their final
+// status or ctx is done. Tasks still running after that are marked cancelled.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.stop(ErrShuttingDown)
 
-	// Check response
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		m.logger.Printf("Callback notification sent successfully (status: %s)", resp.Status)
-	} else {
-		m.logger.Printf("Callback notification failed (status: %s)", resp.Status)
+	drained := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		m.logger.Warn("Timed out waiting for running tasks to stop", "error", ctx.Err())
 	}
-}
+	m.dropHeldCallbacks()
 
-// Shutdown gracefully cleans up any resources used by the manager.
-func (m *Manager) Shutdown(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Cancel any running tasks (in a real implementation)
 	for id, task := range m.tasks {
-		if task.Status == taskstypes.StatusRunning || task.Status == taskstypes.StatusWaitingFor2FA {
-			m.logger.Printf("Cancelling task %s during shutdown", id)
-			task.Status = taskstypes.StatusCancelled
+		cancelled := false
+		task.Update(func(task *taskstypes.Task) {
+			if task.Status == taskstypes.StatusRunning || task.Status == taskstypes.StatusWaitingFor2FA {
+				task.Status = taskstypes.StatusCancelled
+				cancelled = true
+			}
+		})
+		if cancelled {
+			m.logger.Info("Cancelling task during shutdown", logging.TaskIDKey, id)
+			m.persist(task)
 		}
 	}
 
-	m.logger.Println("Task manager shut down")
+	if err := m.store.Close(); err != nil {
+		m.logger.Error("Failed to close task store", "error", err)
+	}
+
+	m.logger.Info("Task manager shut down")
 	return nil
 }
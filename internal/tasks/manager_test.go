@@ -2,12 +2,15 @@ package tasks
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/secrets"
 	"github.com/copyleftdev/goscry/internal/tasks/mocks"
 	"github.com/copyleftdev/goscry/internal/taskstypes"
 	"github.com/google/uuid"
@@ -19,7 +22,7 @@ func TestManager_SubmitTask(t *testing.T) {
 	mockBrowser := mocks.NewMockBrowserExecutor()
 	
 	// Create a test logger
-	testLogger := log.New(os.Stderr, "TEST: ", log.LstdFlags)
+	testLogger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	
 	// Create a minimal config
 	cfg := &config.Config{
@@ -81,7 +84,7 @@ func TestManager_Shutdown(t *testing.T) {
 	mockBrowser := mocks.NewMockBrowserExecutor()
 	
 	// Create a test logger
-	testLogger := log.New(os.Stderr, "TEST: ", log.LstdFlags)
+	testLogger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	
 	// Create a minimal config
 	cfg := &config.Config{
@@ -102,3 +105,234 @@ func TestManager_Shutdown(t *testing.T) {
 	// Note: In the real implementation, we don't actually call browser.Shutdown()
 	// so we're not asserting mockBrowser.WasShutdownCalled() anymore
 }
+
+func TestManager_SubmitTaskUnknownSession(t *testing.T) {
+	cfg := &config.Config{Browser: config.BrowserConfig{MaxSessions: 1, Headless: true}}
+	manager := NewManager(cfg, mocks.NewMockBrowserExecutor(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	_, err := manager.Sessions()
+	assert.ErrorIs(t, err, ErrSessionsUnsupported)
+
+	task := &taskstypes.Task{
+		ID:        uuid.New(),
+		Status:    taskstypes.StatusPending,
+		Actions:   []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}},
+		Session:   "checkout",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	assert.ErrorIs(t, manager.SubmitTask(task), ErrSessionsUnsupported)
+
+	_, err = manager.GetTaskStatus(task.ID)
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+}
+
+func TestManager_SubmitTaskSealedCredentials(t *testing.T) {
+	cfg := &config.Config{Browser: config.BrowserConfig{MaxSessions: 1, Headless: true}}
+	manager := NewManager(cfg, mocks.NewMockBrowserExecutor(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	task := &taskstypes.Task{
+		ID:          uuid.New(),
+		Status:      taskstypes.StatusPending,
+		Actions:     []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}},
+		SealedCreds: &taskstypes.SealedCredentials{KeyID: "abc"},
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	// The mock executor cannot decrypt, so the task is rejected before it runs
+	assert.ErrorIs(t, manager.SubmitTask(task), ErrSealedCredentialsUnsupported)
+}
+
+func TestManager_SubmitTaskCredentialsRef(t *testing.T) {
+	cfg := &config.Config{Browser: config.BrowserConfig{MaxSessions: 1, Headless: true}}
+	manager := NewManager(cfg, mocks.NewMockBrowserExecutor(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	task := &taskstypes.Task{
+		ID:             uuid.New(),
+		Status:         taskstypes.StatusPending,
+		Actions:        []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}},
+		CredentialsRef: "vault:myapp/login",
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	// The mock executor cannot resolve references, so the task is rejected before it runs
+	assert.ErrorIs(t, manager.SubmitTask(task), ErrCredentialsRefUnsupported)
+
+	// Executors that can are still refused references to unconfigured sources
+	manager = NewManager(cfg, &resolvingExecutor{mocks.NewMockBrowserExecutor()}, slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	assert.ErrorIs(t, manager.SubmitTask(task), secrets.ErrInvalidReference)
+}
+
+func TestManager_SubmitTaskTOTPSecret(t *testing.T) {
+	manager := NewManager(&config.Config{}, mocks.NewMockBrowserExecutor(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	newTOTPTask := func(secret string) *taskstypes.Task {
+		return &taskstypes.Task{
+			ID:            uuid.New(),
+			Status:        taskstypes.StatusPending,
+			Actions:       []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}},
+			TwoFactorAuth: taskstypes.TwoFactorAuthInfo{Expected: true, Provider: taskstypes.TFAProviderApp, Secret: secret},
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+	}
+	assert.NoError(t, manager.SubmitTask(newTOTPTask("JBSWY3DPEHPK3PXP")))
+	assert.ErrorIs(t, manager.SubmitTask(newTOTPTask("not base32!")), ErrInvalidTOTPSecret)
+}
+
+func TestManager_SubmitTaskOptions(t *testing.T) {
+	manager := NewManager(&config.Config{}, mocks.NewMockBrowserExecutor(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	task := &taskstypes.Task{
+		ID:      uuid.New(),
+		Status:  taskstypes.StatusPending,
+		Actions: []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}},
+		Options: taskstypes.TaskOptions{FirstPartyOnly: true, Replay: &taskstypes.ReplayOptions{TaskID: uuid.NewString()}},
+	}
+	assert.ErrorIs(t, manager.SubmitTask(task), ErrInvalidOptions)
+
+	task.Options = taskstypes.TaskOptions{Profile: &taskstypes.ProfileOptions{Name: "crm"}}
+	task.Session = "crm"
+	assert.ErrorIs(t, manager.SubmitTask(task), ErrInvalidOptions, "checked before the session is looked up")
+}
+
+func TestManager_InvalidEncryptionRefusesTasks(t *testing.T) {
+	manager := NewManager(&config.Config{
+		Security: config.SecurityConfig{Encryption: config.EncryptionConfig{Enabled: true}},
+	}, mocks.NewMockBrowserExecutor(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	assert.ErrorIs(t, manager.Err(), ErrInvalidEncryption)
+	task := &taskstypes.Task{
+		ID:      uuid.New(),
+		Status:  taskstypes.StatusPending,
+		Actions: []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}},
+	}
+	assert.ErrorIs(t, manager.SubmitTask(task), ErrInvalidEncryption)
+
+	running := NewManager(&config.Config{}, mocks.NewMockBrowserExecutor(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	assert.NoError(t, running.Err())
+	assert.NoError(t, running.Shutdown(context.Background()))
+	assert.ErrorIs(t, running.Err(), ErrShuttingDown)
+}
+
+// resolvingExecutor is a mock executor that accepts a credential resolver.
+type resolvingExecutor struct {
+	*mocks.MockBrowserExecutor
+}
+
+func (e *resolvingExecutor) SetCredentialResolver(*secrets.Resolver) {}
+
+func TestManager_ConcurrentStatusReads(t *testing.T) {
+	mockBrowser := mocks.NewMockBrowserExecutor()
+	mockBrowser.SetExecuteHook(func(ctx context.Context, task *taskstypes.Task) {
+		// Step through actions the way the browser executor does
+		for i := 0; i < 200; i++ {
+			task.SetCurrentAction(i)
+		}
+	})
+	manager := NewManager(nil, mockBrowser, logging.Discard())
+
+	task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, CreatedAt: time.Now()}
+	assert.NoError(t, manager.SubmitTask(task))
+
+	// Run with -race: readers must only ever see snapshots
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				snapshot, err := manager.GetTaskStatus(task.ID)
+				if !assert.NoError(t, err) || snapshot.Status.IsTerminal() {
+					return
+				}
+				_, _ = manager.ListTasks(ListFilter{})
+			}
+		}()
+	}
+	wg.Wait()
+
+	final, err := manager.GetTaskStatus(task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, taskstypes.StatusCompleted, final.Status)
+	assert.Equal(t, 199, final.CurrentAction)
+	if assert.NotNil(t, final.Result) {
+		assert.True(t, final.Result.Success)
+	}
+}
+
+// blockingManager returns a manager whose executor runs until the task's context is done.
+func blockingManager(t *testing.T) *Manager {
+	mockBrowser := mocks.NewMockBrowserExecutor()
+	mockBrowser.SetExecuteHook(func(ctx context.Context, task *taskstypes.Task) {
+		<-ctx.Done()
+	})
+	return NewManager(nil, mockBrowser, logging.Discard())
+}
+
+func TestManager_CancelTask(t *testing.T) {
+	manager := blockingManager(t)
+	task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, CreatedAt: time.Now()}
+	assert.NoError(t, manager.SubmitTask(task))
+
+	assert.NoError(t, manager.CancelTask(task.ID))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	final, err := manager.WaitTask(ctx, task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, taskstypes.StatusCancelled, final.Status)
+	if assert.NotNil(t, final.Result) {
+		assert.Equal(t, ErrTaskCancelled.Error(), final.Result.Error)
+	}
+
+	assert.ErrorIs(t, manager.CancelTask(task.ID), ErrTaskFinished)
+	assert.ErrorIs(t, manager.CancelTask(uuid.New()), ErrTaskNotFound)
+}
+
+func TestManager_SubmitTaskContext(t *testing.T) {
+	manager := blockingManager(t)
+	task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, CreatedAt: time.Now()}
+	requestCtx, endRequest := context.WithCancel(context.Background())
+	assert.NoError(t, manager.SubmitTaskContext(requestCtx, task))
+
+	endRequest()
+	final, err := manager.WaitTask(context.Background(), task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, taskstypes.StatusCancelled, final.Status)
+	assert.Contains(t, final.Result.Error, ErrRequestEnded.Error())
+}
+
+func TestManager_ShutdownDrainsTasks(t *testing.T) {
+	manager := blockingManager(t)
+	task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, CreatedAt: time.Now()}
+	assert.NoError(t, manager.SubmitTask(task))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, manager.Shutdown(ctx))
+
+	final := task.Snapshot()
+	assert.Equal(t, taskstypes.StatusCancelled, final.Status)
+	assert.Equal(t, ErrShuttingDown.Error(), final.Result.Error)
+	assert.ErrorIs(t, manager.SubmitTask(&taskstypes.Task{ID: uuid.New()}), ErrShuttingDown)
+}
+
+// BenchmarkManager_SubmitTask measures scheduling overhead: submitting a task,
+// running it on an executor that returns at once, and waiting for the result.
+func BenchmarkManager_SubmitTask(b *testing.B) {
+	manager := NewManager(nil, mocks.NewMockBrowserExecutor(), logging.Discard())
+	actions := []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://example.com"},
+		{Type: taskstypes.ActionWaitVisible, Selector: "#content"},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		task := &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, Actions: actions, CreatedAt: time.Now()}
+		if err := manager.SubmitTask(task); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := manager.WaitTask(context.Background(), task.ID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -18,6 +18,7 @@ type MockBrowserExecutor struct {
 	shutdownCalled    bool
 	shutdownError     error
 	simulateTwoFactor bool
+	executeHook       func(ctx context.Context, task *taskstypes.Task)
 }
 
 // NewMockBrowserExecutor creates a new mock browser executor
@@ -30,16 +31,19 @@ func NewMockBrowserExecutor() *MockBrowserExecutor {
 }
 
 // ExecuteTask implements the BrowserExecutor interface
-func (m *MockBrowserExecutor) ExecuteTask(task *taskstypes.Task) (*taskstypes.TaskResult, error) {
+func (m *MockBrowserExecutor) ExecuteTask(ctx context.Context, task *taskstypes.Task) (*taskstypes.TaskResult, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.executedTasks = append(m.executedTasks, task)
+	if m.executeHook != nil {
+		m.executeHook(ctx, task)
+	}
 
 	// If we're simulating 2FA and task has 2FA info, return immediately with WaitingFor2FA status
 	if m.simulateTwoFactor && task.TwoFactorAuth.Expected {
 		// Only change status to waiting if we're not already past that point
-		if task.Status != taskstypes.StatusWaitingFor2FA && task.Status != taskstypes.StatusCompleted {
+		if status := task.CurrentStatus(); status != taskstypes.StatusWaitingFor2FA && status != taskstypes.StatusCompleted {
 			task.UpdateStatus(taskstypes.StatusWaitingFor2FA)
 			return &taskstypes.TaskResult{
 				Success: false,
@@ -51,7 +55,7 @@ func (m *MockBrowserExecutor) ExecuteTask(task *taskstypes.Task) (*taskstypes.Ta
 	// Use predefined result or error if available for this task ID
 	taskID := task.ID.String()
 	if result, ok := m.executionResults[taskID]; ok {
-		task.Result = result
+		task.Update(func(task *taskstypes.Task) { task.Result = result })
 		task.UpdateStatus(taskstypes.StatusCompleted)
 		return result, m.executionErrors[taskID]
 	}
@@ -64,9 +68,9 @@ func (m *MockBrowserExecutor) ExecuteTask(task *taskstypes.Task) (*taskstypes.Ta
 	}
 	
 	// If we need to wait for 2FA, only proceed if the code has been provided
-	if task.Status == taskstypes.StatusWaitingFor2FA {
+	if snapshot := task.Snapshot(); snapshot.Status == taskstypes.StatusWaitingFor2FA {
 		// If we have a code channel, use it to get the code
-		if task.TfaCodeChan != nil {
+		if snapshot.TfaCodeChan != nil {
 			// Simulated wait for code
 			select {
 			case <-time.After(50 * time.Millisecond):
@@ -75,7 +79,7 @@ func (m *MockBrowserExecutor) ExecuteTask(task *taskstypes.Task) (*taskstypes.Ta
 					Success: false,
 					Message: "Still waiting for 2FA code",
 				}, nil
-			case code := <-task.TfaCodeChan:
+			case code := <-snapshot.TfaCodeChan:
 				// Code received, proceed with completion
 				defaultResult.Message = fmt.Sprintf("Task completed with 2FA code: %s", code)
 			}
@@ -83,7 +87,7 @@ func (m *MockBrowserExecutor) ExecuteTask(task *taskstypes.Task) (*taskstypes.Ta
 	}
 	
 	task.UpdateStatus(taskstypes.StatusCompleted)
-	task.Result = defaultResult
+	task.Update(func(task *taskstypes.Task) { task.Result = defaultResult })
 	return defaultResult, nil
 }
 
@@ -129,6 +133,16 @@ func (m *MockBrowserExecutor) SetShutdownError(err error) {
 	m.shutdownError = err
 }
 
+// SetExecuteHook sets a function run at the start of every ExecuteTask, e.g.
+// to mutate the task the way a real executor would while tests read it, or
+// to block until the task is cancelled.
+func (m *MockBrowserExecutor) SetExecuteHook(hook func(ctx context.Context, task *taskstypes.Task)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.executeHook = hook
+}
+
 // SimulateTwoFactorAuth enables/disables 2FA simulation
 func (m *MockBrowserExecutor) SimulateTwoFactorAuth(enable bool) {
 	m.mu.Lock()
@@ -0,0 +1,34 @@
+package tasks
+
+import (
+	"errors"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+var (
+	// ErrProfilesUnsupported is returned when the executor cannot keep profile snapshots.
+	ErrProfilesUnsupported = errors.New("browser executor does not support profile snapshots")
+	// ErrProfileNotFound is returned when a named profile snapshot does not exist.
+	ErrProfileNotFound = errors.New("profile not found")
+	// ErrProfileExists is returned when creating a profile whose name is taken.
+	ErrProfileExists = errors.New("profile already exists")
+)
+
+// ProfileExecutor is implemented by BrowserExecutors that can save a task's
+// browser profile (cookies, storage, logged-in state) and start later tasks
+// and sessions from it. Tasks opt in with TaskOptions.Profile.
+type ProfileExecutor interface {
+	CreateProfile(name, owner, description string, autoSave bool) (taskstypes.ProfileInfo, error)
+	ListProfiles() ([]taskstypes.ProfileInfo, error)
+	DeleteProfile(name string) error
+}
+
+// Profiles returns the executor's profile snapshot support, if it has any.
+func (m *Manager) Profiles() (ProfileExecutor, error) {
+	profiles, ok := m.browserExecutor.(ProfileExecutor)
+	if !ok {
+		return nil, ErrProfilesUnsupported
+	}
+	return profiles, nil
+}
@@ -0,0 +1,213 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// ErrQueueFull is returned by SubmitTask when queue.size tasks are already
+// waiting for a worker.
+var ErrQueueFull = errors.New("task queue is full")
+
+const (
+	// defaultQueueWorkers matches the default browser.maxSessions.
+	defaultQueueWorkers = 10
+	defaultQueueSize    = 1000
+)
+
+// priorityLevels lists priorities in the order workers take tasks.
+var priorityLevels = []taskstypes.TaskPriority{
+	taskstypes.PriorityHigh, taskstypes.PriorityNormal, taskstypes.PriorityLow,
+}
+
+// QueueStats describes the worker pool and the tasks waiting for it.
+type QueueStats struct {
+	Workers    int                             `json:"workers"`
+	Busy       int                             `json:"busy"`
+	Queued     int                             `json:"queued"`
+	Capacity   int                             `json:"capacity"`
+	ByPriority map[taskstypes.TaskPriority]int `json:"by_priority"`
+}
+
+// queuedTask is a submitted task waiting for a worker.
+type queuedTask struct {
+	task *taskstypes.Task
+	ctx  context.Context
+	run  *taskRun
+	stop func() bool // Stops the cancellation hook once a worker takes the task
+}
+
+// taskQueue holds tasks waiting for a worker: higher priorities first, and
+// in submission order within a priority.
+type taskQueue struct {
+	mu       sync.Mutex
+	levels   map[taskstypes.TaskPriority][]*queuedTask
+	queued   int
+	capacity int
+	busy     int
+	workers  int
+	// ready holds one token per queued task, so idle workers can wait on it
+	// alongside shutdown. Tokens never outnumber queued tasks: a task removed
+	// on cancellation takes a token with it when one is left.
+	ready chan struct{}
+}
+
+func newTaskQueue(workers, capacity int) *taskQueue {
+	return &taskQueue{
+		levels:   make(map[taskstypes.TaskPriority][]*queuedTask),
+		capacity: capacity,
+		workers:  workers,
+		ready:    make(chan struct{}, capacity),
+	}
+}
+
+// full reports whether the queue is at capacity.
+func (q *taskQueue) full() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queued >= q.capacity
+}
+
+// push adds item behind the tasks of the same priority. It reports false
+// when the queue is full.
+func (q *taskQueue) push(item *queuedTask) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queued >= q.capacity {
+		return false
+	}
+	priority := queuePriority(item.task.Priority)
+	q.levels[priority] = append(q.levels[priority], item)
+	q.queued++
+	q.ready <- struct{}{}
+	return true
+}
+
+// pop takes the next task for a worker and counts the worker as busy until
+// done is called. It returns nil if the task a token stood for was removed.
+func (q *taskQueue) pop() *queuedTask {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, priority := range priorityLevels {
+		if items := q.levels[priority]; len(items) > 0 {
+			item := items[0]
+			items[0] = nil
+			q.levels[priority] = items[1:]
+			q.queued--
+			q.busy++
+			return item
+		}
+	}
+	return nil
+}
+
+// done marks a worker idle again.
+func (q *taskQueue) done() {
+	q.mu.Lock()
+	q.busy--
+	q.mu.Unlock()
+}
+
+// remove takes item out of the queue, reporting false if a worker already has it.
+func (q *taskQueue) remove(item *queuedTask) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	priority := queuePriority(item.task.Priority)
+	items := q.levels[priority]
+	for i, queued := range items {
+		if queued != item {
+			continue
+		}
+		q.levels[priority] = append(items[:i:i], items[i+1:]...)
+		q.queued--
+		select {
+		case <-q.ready:
+		default:
+		}
+		return true
+	}
+	return false
+}
+
+func (q *taskQueue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := QueueStats{
+		Workers:    q.workers,
+		Busy:       q.busy,
+		Queued:     q.queued,
+		Capacity:   q.capacity,
+		ByPriority: make(map[taskstypes.TaskPriority]int, len(priorityLevels)),
+	}
+	for _, priority := range priorityLevels {
+		stats.ByPriority[priority] = len(q.levels[priority])
+	}
+	return stats
+}
+
+// queuePriority maps an unset or unknown priority to normal.
+func queuePriority(p taskstypes.TaskPriority) taskstypes.TaskPriority {
+	if p == taskstypes.PriorityHigh || p == taskstypes.PriorityLow {
+		return p
+	}
+	return taskstypes.PriorityNormal
+}
+
+// startWorkers sizes the queue from queue.workers (or browser.maxSessions)
+// and queue.size, and starts the workers.
+func (m *Manager) startWorkers() {
+	workers, size := defaultQueueWorkers, defaultQueueSize
+	if m.cfg != nil {
+		if m.cfg.Queue.Workers > 0 {
+			workers = m.cfg.Queue.Workers
+		} else if m.cfg.Browser.MaxSessions > 0 {
+			workers = m.cfg.Browser.MaxSessions
+		}
+		if m.cfg.Queue.Size > 0 {
+			size = m.cfg.Queue.Size
+		}
+	}
+	m.queue = newTaskQueue(workers, size)
+	for i := 0; i < workers; i++ {
+		go m.worker()
+	}
+}
+
+// worker runs queued tasks one at a time until the manager shuts down.
+func (m *Manager) worker() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.queue.ready:
+		}
+		item := m.queue.pop()
+		if item == nil {
+			continue
+		}
+		item.stop()
+		m.executeTask(item.ctx, item.task, item.run)
+		m.queue.done()
+	}
+}
+
+// enqueue queues a task whose run has started, finishing it as cancelled if
+// its context ends before a worker takes it. Callers must hold m.mu and have
+// checked that the queue has room.
+func (m *Manager) enqueue(ctx context.Context, task *taskstypes.Task, run *taskRun) {
+	item := &queuedTask{task: task, ctx: ctx, run: run}
+	item.stop = context.AfterFunc(ctx, func() {
+		if m.queue.remove(item) {
+			m.executeTask(ctx, task, run)
+		}
+	})
+	m.queue.push(item)
+}
+
+// QueueStats reports the worker pool's load.
+func (m *Manager) QueueStats() QueueStats {
+	return m.queue.stats()
+}
@@ -0,0 +1,100 @@
+package tasks
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedManager returns a single-worker manager whose executor records the
+// order tasks run in and holds each one until release is closed.
+func gatedManager(t *testing.T, size int) (*Manager, chan struct{}, func() []uuid.UUID) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []uuid.UUID
+	executor := mocks.NewMockBrowserExecutor()
+	executor.SetExecuteHook(func(ctx context.Context, task *taskstypes.Task) {
+		mu.Lock()
+		order = append(order, task.ID)
+		mu.Unlock()
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	})
+	cfg := &config.Config{Queue: config.QueueConfig{Workers: 1, Size: size}}
+	manager := NewManager(cfg, executor, logging.Discard())
+	return manager, release, func() []uuid.UUID {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]uuid.UUID(nil), order...)
+	}
+}
+
+func queueTask(priority taskstypes.TaskPriority) *taskstypes.Task {
+	return &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, Priority: priority, CreatedAt: time.Now()}
+}
+
+func TestManager_QueuePriority(t *testing.T) {
+	manager, release, order := gatedManager(t, 10)
+	first := queueTask("")
+	require.NoError(t, manager.SubmitTask(first))
+	require.Eventually(t, func() bool { return len(order()) == 1 }, 5*time.Second, time.Millisecond)
+
+	low, normal, high, high2 := queueTask(taskstypes.PriorityLow), queueTask(""), queueTask(taskstypes.PriorityHigh), queueTask(taskstypes.PriorityHigh)
+	for _, task := range []*taskstypes.Task{low, normal, high, high2} {
+		require.NoError(t, manager.SubmitTask(task))
+	}
+	stats := manager.QueueStats()
+	assert.Equal(t, 1, stats.Busy)
+	assert.Equal(t, 4, stats.Queued)
+	assert.Equal(t, 2, stats.ByPriority[taskstypes.PriorityHigh])
+	assert.Equal(t, 1, stats.ByPriority[taskstypes.PriorityNormal])
+
+	close(release)
+	_, err := manager.WaitTask(context.Background(), low.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first.ID, high.ID, high2.ID, normal.ID, low.ID}, order())
+}
+
+func TestManager_QueueFull(t *testing.T) {
+	manager, release, order := gatedManager(t, 1)
+	defer close(release)
+	require.NoError(t, manager.SubmitTask(queueTask("")))
+	require.Eventually(t, func() bool { return len(order()) == 1 }, 5*time.Second, time.Millisecond)
+
+	require.NoError(t, manager.SubmitTask(queueTask("")))
+	rejected := queueTask(taskstypes.PriorityHigh)
+	assert.ErrorIs(t, manager.SubmitTask(rejected), ErrQueueFull)
+	_, err := manager.GetTaskStatus(rejected.ID)
+	assert.ErrorIs(t, err, ErrTaskNotFound, "rejected tasks are not kept")
+}
+
+func TestManager_CancelQueuedTask(t *testing.T) {
+	manager, release, order := gatedManager(t, 10)
+	defer close(release)
+	require.NoError(t, manager.SubmitTask(queueTask("")))
+	require.Eventually(t, func() bool { return len(order()) == 1 }, 5*time.Second, time.Millisecond)
+
+	queued := queueTask("")
+	require.NoError(t, manager.SubmitTask(queued))
+	require.NoError(t, manager.CancelTask(queued.ID))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	final, err := manager.WaitTask(ctx, queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, taskstypes.StatusCancelled, final.Status)
+	assert.Nil(t, final.StartedAt, "the task never ran")
+	assert.Equal(t, 0, manager.QueueStats().Queued)
+	assert.Len(t, order(), 1)
+}
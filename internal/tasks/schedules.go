@@ -0,0 +1,313 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+)
+
+const (
+	// minScheduleInterval keeps a schedule from flooding the browser pool.
+	minScheduleInterval = time.Minute
+	// scheduleCheckInterval is how often due schedules are looked for.
+	scheduleCheckInterval = time.Second
+)
+
+// ErrScheduleNotFound is returned when a schedule name is unknown.
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ScheduleSpec is the desired state of a schedule: what to run and how often.
+type ScheduleSpec struct {
+	Name            string                 `json:"name"`
+	Every           string                 `json:"every"`                      // Interval between runs, e.g. "15m" or "24h"
+	Template        string                 `json:"template,omitempty"`         // Runs the template's latest version unless TemplateVersion pins one; mutually exclusive with Actions
+	TemplateVersion int                    `json:"template_version,omitempty"` // Pins Template to this version; 0 follows the latest
+	Actions         []taskstypes.Action    `json:"actions,omitempty"`
+	Options         taskstypes.TaskOptions `json:"options"`
+	CallbackURL     string                 `json:"callback_url,omitempty"`
+	Session         string                 `json:"session,omitempty"`
+	Paused          bool                   `json:"paused,omitempty"`
+	Tenant          string                 `json:"tenant,omitempty"` // Set from the caller that synced the schedule, not the request body
+}
+
+// Validate checks the spec and returns its interval.
+func (s ScheduleSpec) Validate() (time.Duration, error) {
+	if s.Name == "" {
+		return 0, fmt.Errorf("schedule name is required")
+	}
+	every, err := time.ParseDuration(s.Every)
+	if err != nil {
+		return 0, fmt.Errorf("schedule %s: invalid every %q", s.Name, s.Every)
+	}
+	if every < minScheduleInterval {
+		return 0, fmt.Errorf("schedule %s: every must be at least %s", s.Name, minScheduleInterval)
+	}
+	if (s.Template == "") == (len(s.Actions) == 0) {
+		return 0, fmt.Errorf("schedule %s: set either template or actions", s.Name)
+	}
+	if s.TemplateVersion < 0 || (s.TemplateVersion != 0 && s.Template == "") {
+		return 0, fmt.Errorf("schedule %s: template_version must be a positive version of its template", s.Name)
+	}
+	// Checked again by SubmitTask on every run; this reports mistakes when the schedule is saved
+	if err := s.Options.Validate(s.Session != ""); err != nil {
+		return 0, fmt.Errorf("schedule %s: %w", s.Name, err)
+	}
+	return every, nil
+}
+
+// Schedule is a ScheduleSpec with its run state.
+type Schedule struct {
+	ScheduleSpec
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"` // Unset while paused
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	LastTaskID string     `json:"last_task_id,omitempty"`
+	LastError  string     `json:"last_error,omitempty"` // Why the last run could not be submitted
+
+	every time.Duration
+}
+
+// ScheduleSyncResult lists what a sync changed, by schedule name.
+type ScheduleSyncResult struct {
+	Created   []string    `json:"created"`
+	Updated   []string    `json:"updated"`
+	Deleted   []string    `json:"deleted"`
+	Unchanged []string    `json:"unchanged"`
+	DryRun    bool        `json:"dry_run,omitempty"`
+	Schedules []*Schedule `json:"schedules"` // The full set after the sync
+}
+
+// ScheduleStore holds the schedules tasks are started from.
+type ScheduleStore struct {
+	mu        sync.Mutex
+	schedules map[string]*Schedule
+}
+
+// NewScheduleStore creates an empty schedule store.
+func NewScheduleStore() *ScheduleStore {
+	return &ScheduleStore{schedules: make(map[string]*Schedule)}
+}
+
+// List returns every schedule, sorted by name.
+func (s *ScheduleStore) List() []*Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedSchedules(s.schedules)
+}
+
+// sortedSchedules returns copies of schedules sorted by name.
+func sortedSchedules(schedules map[string]*Schedule) []*Schedule {
+	list := make([]*Schedule, 0, len(schedules))
+	for _, sched := range schedules {
+		copied := *sched
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get returns the named schedule.
+func (s *ScheduleStore) Get(name string) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sched, ok := s.schedules[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+	}
+	copied := *sched
+	return &copied, nil
+}
+
+// Sync converges the store on desired: schedules missing from the store are
+// created, changed ones are updated, and ones not in desired are deleted.
+// Nothing changes if any spec is invalid, and nothing changes at all with
+// dryRun. Updated schedules keep their run history; a new interval or
+// resuming a paused schedule restarts its countdown from now.
+func (s *ScheduleStore) Sync(desired []ScheduleSpec, templates *TemplateStore, dryRun bool) (*ScheduleSyncResult, error) {
+	intervals := make(map[string]time.Duration, len(desired))
+	for _, spec := range desired {
+		every, err := spec.Validate()
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := intervals[spec.Name]; dup {
+			return nil, fmt.Errorf("schedule %s is listed more than once", spec.Name)
+		}
+		if spec.Template != "" && templates != nil {
+			if _, err := templates.Get(spec.Template, spec.TemplateVersion); err != nil {
+				return nil, fmt.Errorf("schedule %s: %w", spec.Name, err)
+			}
+		}
+		intervals[spec.Name] = every
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &ScheduleSyncResult{
+		Created: []string{}, Updated: []string{}, Deleted: []string{}, Unchanged: []string{}, DryRun: dryRun,
+	}
+	now := time.Now().UTC()
+	next := make(map[string]*Schedule, len(desired))
+	for _, spec := range desired {
+		every := intervals[spec.Name]
+		current, exists := s.schedules[spec.Name]
+		switch {
+		case !exists:
+			result.Created = append(result.Created, spec.Name)
+			next[spec.Name] = newSchedule(spec, every, now)
+		case reflect.DeepEqual(current.ScheduleSpec, spec):
+			result.Unchanged = append(result.Unchanged, spec.Name)
+			next[spec.Name] = current
+		default:
+			result.Updated = append(result.Updated, spec.Name)
+			updated := *current
+			updated.ScheduleSpec = spec
+			updated.every = every
+			updated.UpdatedAt = now
+			if spec.Paused {
+				updated.NextRunAt = nil
+			} else if every != current.every || current.Paused {
+				nextRun := now.Add(every)
+				updated.NextRunAt = &nextRun
+			}
+			next[spec.Name] = &updated
+		}
+	}
+	for name := range s.schedules {
+		if _, keep := next[name]; !keep {
+			result.Deleted = append(result.Deleted, name)
+		}
+	}
+	for _, names := range [][]string{result.Created, result.Updated, result.Deleted, result.Unchanged} {
+		sort.Strings(names)
+	}
+
+	if !dryRun {
+		s.schedules = next
+	}
+	result.Schedules = sortedSchedules(next)
+	return result, nil
+}
+
+func newSchedule(spec ScheduleSpec, every time.Duration, now time.Time) *Schedule {
+	sched := &Schedule{ScheduleSpec: spec, CreatedAt: now, UpdatedAt: now, every: every}
+	if !spec.Paused {
+		nextRun := now.Add(every)
+		sched.NextRunAt = &nextRun
+	}
+	return sched
+}
+
+// due returns copies of the schedules whose next run is at or before now and
+// moves their next run forward by one interval.
+func (s *ScheduleStore) due(now time.Time) []*Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*Schedule
+	for _, sched := range s.schedules {
+		if sched.NextRunAt == nil || sched.NextRunAt.After(now) {
+			continue
+		}
+		copied := *sched
+		due = append(due, &copied)
+		// Runs missed while the server was busy are skipped rather than queued up
+		nextRun := sched.NextRunAt.Add(sched.every)
+		if !nextRun.After(now) {
+			nextRun = now.Add(sched.every)
+		}
+		sched.NextRunAt = &nextRun
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Name < due[j].Name })
+	return due
+}
+
+// recordRun notes the outcome of submitting a schedule's task. The schedule
+// may have been deleted or replaced in the meantime, in which case it is left alone.
+func (s *ScheduleStore) recordRun(name string, at time.Time, taskID uuid.UUID, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sched, ok := s.schedules[name]
+	if !ok {
+		return
+	}
+	sched.LastRunAt = &at
+	sched.LastError = ""
+	if err != nil {
+		sched.LastError = err.Error()
+		return
+	}
+	sched.LastTaskID = taskID.String()
+}
+
+// Schedules returns the manager's schedule store.
+func (m *Manager) Schedules() *ScheduleStore {
+	return m.schedules
+}
+
+// scheduleLoop starts tasks for due schedules until the manager shuts down.
+func (m *Manager) scheduleLoop() {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.runDueSchedules(now.UTC())
+		}
+	}
+}
+
+// runDueSchedules submits a task for each schedule due at now.
+func (m *Manager) runDueSchedules(now time.Time) {
+	for _, sched := range m.schedules.due(now) {
+		task, err := m.scheduledTask(sched)
+		if err == nil {
+			err = m.SubmitTask(task)
+		}
+		if err != nil {
+			m.logger.Error("Failed to run schedule", "schedule", sched.Name, "error", err)
+			m.schedules.recordRun(sched.Name, now, uuid.Nil, err)
+			continue
+		}
+		m.schedules.recordRun(sched.Name, now, task.ID, nil)
+	}
+}
+
+// scheduledTask builds the task a schedule runs, resolving its template now
+// so a new template version takes effect on the next run unless the schedule
+// pins one.
+func (m *Manager) scheduledTask(sched *Schedule) (*taskstypes.Task, error) {
+	now := time.Now()
+	task := &taskstypes.Task{
+		ID:          uuid.New(),
+		Status:      taskstypes.StatusPending,
+		Actions:     sched.Actions,
+		Options:     sched.Options,
+		CallbackURL: sched.CallbackURL,
+		Session:     sched.Session,
+		Tenant:      sched.Tenant,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		TfaCodeChan: make(chan string, 1),
+	}
+	if sched.Template != "" {
+		tmpl, canary, err := m.templates.ForRun(sched.Template, sched.TemplateVersion)
+		if err != nil {
+			return nil, err
+		}
+		task.Actions = tmpl.Actions
+		task.TemplateName = tmpl.Name
+		task.TemplateVersion = tmpl.Version
+		task.Canary = canary
+	}
+	return task, nil
+}
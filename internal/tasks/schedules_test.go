@@ -0,0 +1,131 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var scheduleActions = []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}}
+
+func TestScheduleStore_Sync(t *testing.T) {
+	templates := NewTemplateStore()
+	_, err := templates.Put("login", "", scheduleActions)
+	require.NoError(t, err)
+	store := NewScheduleStore()
+
+	result, err := store.Sync([]ScheduleSpec{
+		{Name: "hourly", Every: "1h", Actions: scheduleActions},
+		{Name: "daily", Every: "24h", Template: "login"},
+	}, templates, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"daily", "hourly"}, result.Created)
+	require.Len(t, result.Schedules, 2)
+	assert.NotNil(t, result.Schedules[0].NextRunAt)
+
+	// Applying the same set again changes nothing
+	result, err = store.Sync([]ScheduleSpec{
+		{Name: "hourly", Every: "1h", Actions: scheduleActions},
+		{Name: "daily", Every: "24h", Template: "login"},
+	}, templates, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"daily", "hourly"}, result.Unchanged)
+	assert.Empty(t, result.Created)
+	assert.Empty(t, result.Updated)
+	assert.Empty(t, result.Deleted)
+
+	// A dry run reports changes without making them
+	result, err = store.Sync([]ScheduleSpec{{Name: "hourly", Every: "30m", Actions: scheduleActions}}, templates, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hourly"}, result.Updated)
+	assert.Equal(t, []string{"daily"}, result.Deleted)
+	assert.Len(t, result.Schedules, 1)
+	assert.Len(t, store.List(), 2)
+
+	result, err = store.Sync([]ScheduleSpec{{Name: "hourly", Every: "1h", Actions: scheduleActions, Paused: true}}, templates, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hourly"}, result.Updated)
+	assert.Equal(t, []string{"daily"}, result.Deleted)
+	hourly, err := store.Get("hourly")
+	require.NoError(t, err)
+	assert.Nil(t, hourly.NextRunAt, "paused schedules do not run")
+	_, err = store.Get("daily")
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+}
+
+func TestScheduleStore_SyncRejectsInvalid(t *testing.T) {
+	store := NewScheduleStore()
+	_, err := store.Sync([]ScheduleSpec{{Name: "ok", Every: "1h", Actions: scheduleActions}}, NewTemplateStore(), false)
+	require.NoError(t, err)
+	templates := NewTemplateStore()
+	_, err = templates.Put("login", "", scheduleActions)
+	require.NoError(t, err)
+
+	invalid := [][]ScheduleSpec{
+		{{Every: "1h", Actions: scheduleActions}},
+		{{Name: "a", Every: "soon", Actions: scheduleActions}},
+		{{Name: "a", Every: "10s", Actions: scheduleActions}},
+		{{Name: "a", Every: "1h"}},
+		{{Name: "a", Every: "1h", Template: "login", Actions: scheduleActions}},
+		{{Name: "a", Every: "1h", Template: "missing"}},
+		{{Name: "a", Every: "1h", Template: "login", TemplateVersion: 2}},
+		{{Name: "a", Every: "1h", Actions: scheduleActions, TemplateVersion: 1}},
+		{{Name: "a", Every: "1h", Actions: scheduleActions}, {Name: "a", Every: "2h", Actions: scheduleActions}},
+		{{Name: "a", Every: "1h", Actions: scheduleActions, Options: taskstypes.TaskOptions{Dialogs: &taskstypes.DialogPolicy{Action: "ignore"}}}},
+	}
+	for _, desired := range invalid {
+		_, err := store.Sync(desired, templates, false)
+		assert.Error(t, err, "%+v", desired)
+	}
+	assert.Len(t, store.List(), 1, "a rejected sync changes nothing")
+}
+
+func TestManager_RunDueSchedules(t *testing.T) {
+	executor := mocks.NewMockBrowserExecutor()
+	manager := NewManager(nil, executor, logging.Discard())
+	_, err := manager.Templates().Put("login", "", scheduleActions)
+	require.NoError(t, err)
+	_, err = manager.Schedules().Sync([]ScheduleSpec{
+		{Name: "templated", Every: "1h", Template: "login", Options: taskstypes.TaskOptions{Console: true}},
+		{Name: "paused", Every: "1h", Actions: scheduleActions, Paused: true},
+		{Name: "pinned", Every: "1h", Template: "login", TemplateVersion: 1},
+	}, manager.Templates(), false)
+	require.NoError(t, err)
+	_, err = manager.Templates().Put("login", "", append(scheduleActions, taskstypes.Action{Type: taskstypes.ActionGetDOM}))
+	require.NoError(t, err)
+
+	manager.runDueSchedules(time.Now().UTC())
+	assert.Empty(t, executor.ExecutedTasks(), "nothing is due yet")
+
+	later := time.Now().UTC().Add(61 * time.Minute)
+	manager.runDueSchedules(later)
+	sched, err := manager.Schedules().Get("templated")
+	require.NoError(t, err)
+	require.NotEmpty(t, sched.LastTaskID)
+	assert.Empty(t, sched.LastError)
+	assert.True(t, sched.NextRunAt.After(later))
+
+	task, err := manager.WaitTask(context.Background(), uuid.MustParse(sched.LastTaskID))
+	require.NoError(t, err)
+	assert.Equal(t, "login", task.TemplateName)
+	assert.Equal(t, 2, task.TemplateVersion, "unpinned schedules follow the latest version")
+	assert.True(t, task.Options.Console)
+
+	pinned, err := manager.Schedules().Get("pinned")
+	require.NoError(t, err)
+	task, err = manager.WaitTask(context.Background(), uuid.MustParse(pinned.LastTaskID))
+	require.NoError(t, err)
+	assert.Equal(t, 1, task.TemplateVersion)
+	assert.Len(t, task.Actions, len(scheduleActions))
+
+	paused, err := manager.Schedules().Get("paused")
+	require.NoError(t, err)
+	assert.Nil(t, paused.LastRunAt)
+}
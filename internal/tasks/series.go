@@ -0,0 +1,137 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// Time series metrics, bucketed by when tasks finished.
+const (
+	MetricTasks       = "tasks"           // Tasks that finished, whatever their status
+	MetricCompleted   = "tasks_completed" // Tasks that completed successfully
+	MetricFailed      = "tasks_failed"
+	MetricCancelled   = "tasks_cancelled"
+	MetricSuccessRate = "success_rate" // Completed / (completed + failed), from 0 to 1
+	MetricDurationP50 = "duration_p50_ms"
+	MetricDurationP95 = "duration_p95_ms"
+)
+
+// SeriesMetrics lists the metrics Series accepts.
+var SeriesMetrics = []string{
+	MetricTasks, MetricCompleted, MetricFailed, MetricCancelled,
+	MetricSuccessRate, MetricDurationP50, MetricDurationP95,
+}
+
+// ErrSeriesHistory is returned when task history cannot be loaded for a series.
+var ErrSeriesHistory = errors.New("failed to load task history")
+
+// maxSeriesPoints bounds how many buckets one series may have; a smaller
+// step is widened to fit.
+const maxSeriesPoints = 10000
+
+// SeriesFilter narrows the tasks a series is computed over.
+type SeriesFilter struct {
+	Template string // Empty matches any template
+	Domain   string // Primary domain, as in Stats.ByDomain; empty matches any
+}
+
+// SeriesPoint is a metric's value over the bucket starting at Time.
+type SeriesPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// ComputeSeries buckets the tasks in history that finished in [from, to) by
+// step and computes metric for each bucket. Count metrics have a point for
+// every bucket; rates and durations skip buckets with nothing to measure.
+func ComputeSeries(history []*taskstypes.Task, metric string, from, to time.Time, step time.Duration, filter SeriesFilter) ([]SeriesPoint, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("series range is empty")
+	}
+	if step <= 0 {
+		return nil, fmt.Errorf("series step must be positive")
+	}
+	if n := to.Sub(from) / step; n > maxSeriesPoints {
+		step = to.Sub(from) / maxSeriesPoints
+	}
+	buckets := int((to.Sub(from) + step - 1) / step)
+
+	type bucket struct {
+		finished, completed, failed, cancelled int
+		durations                              []time.Duration
+	}
+	data := make([]bucket, buckets)
+	for _, task := range history {
+		if task.CompletedAt == nil || task.CompletedAt.Before(from) || !task.CompletedAt.Before(to) {
+			continue
+		}
+		if filter.Template != "" && task.TemplateName != filter.Template {
+			continue
+		}
+		if filter.Domain != "" && primaryDomain(task.Actions) != filter.Domain {
+			continue
+		}
+		b := &data[int(task.CompletedAt.Sub(from)/step)]
+		b.finished++
+		switch task.Status {
+		case taskstypes.StatusCompleted:
+			b.completed++
+		case taskstypes.StatusFailed:
+			b.failed++
+		case taskstypes.StatusCancelled:
+			b.cancelled++
+		}
+		if d := task.Duration(); d > 0 {
+			b.durations = append(b.durations, d)
+		}
+	}
+
+	points := make([]SeriesPoint, 0, buckets)
+	for i, b := range data {
+		point := SeriesPoint{Time: from.Add(time.Duration(i) * step)}
+		switch metric {
+		case MetricTasks:
+			point.Value = float64(b.finished)
+		case MetricCompleted:
+			point.Value = float64(b.completed)
+		case MetricFailed:
+			point.Value = float64(b.failed)
+		case MetricCancelled:
+			point.Value = float64(b.cancelled)
+		case MetricSuccessRate:
+			if b.completed+b.failed == 0 {
+				continue
+			}
+			point.Value = successRate(b.completed, b.failed)
+		case MetricDurationP50, MetricDurationP95:
+			if len(b.durations) == 0 {
+				continue
+			}
+			sort.Slice(b.durations, func(i, j int) bool { return b.durations[i] < b.durations[j] })
+			p := 0.50
+			if metric == MetricDurationP95 {
+				p = 0.95
+			}
+			point.Value = float64(percentile(b.durations, p).Milliseconds())
+		default:
+			return nil, fmt.Errorf("unknown metric %q", metric)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// Series computes metric over tasks that finished in [from, to), in buckets of step.
+func (m *Manager) Series(metric string, from, to time.Time, step time.Duration, filter SeriesFilter) ([]SeriesPoint, error) {
+	// Tasks are listed by creation time, so look back far enough to include
+	// long-running ones that finished inside the window
+	history, err := m.store.List(ListFilter{Since: from.Add(-twoFAWaitTimeout), Template: filter.Template, Limit: statsHistoryLimit})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSeriesHistory, err)
+	}
+	return ComputeSeries(history, metric, from, to, step, filter)
+}
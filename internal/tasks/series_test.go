@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seriesTask(status taskstypes.TaskStatus, template string, started time.Time, d time.Duration) *taskstypes.Task {
+	completed := started.Add(d)
+	return &taskstypes.Task{
+		ID: uuid.New(), Status: status, TemplateName: template,
+		Actions:   []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://shop.example.com/"}},
+		CreatedAt: started, StartedAt: &started, CompletedAt: &completed,
+	}
+}
+
+func TestComputeSeries(t *testing.T) {
+	from := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(3 * time.Hour)
+	history := []*taskstypes.Task{
+		seriesTask(taskstypes.StatusCompleted, "login", from.Add(10*time.Minute), time.Second),
+		seriesTask(taskstypes.StatusCompleted, "", from.Add(20*time.Minute), 3*time.Second),
+		seriesTask(taskstypes.StatusFailed, "login", from.Add(30*time.Minute), 2*time.Second),
+		seriesTask(taskstypes.StatusCancelled, "", from.Add(2*time.Hour+5*time.Minute), time.Second),
+		seriesTask(taskstypes.StatusCompleted, "", from.Add(-time.Hour), time.Second), // Before the range
+		{ID: uuid.New(), Status: taskstypes.StatusRunning, CreatedAt: from},           // Not finished
+	}
+
+	points, err := ComputeSeries(history, MetricTasks, from, to, time.Hour, SeriesFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []SeriesPoint{{from, 3}, {from.Add(time.Hour), 0}, {from.Add(2 * time.Hour), 1}}, points)
+
+	points, err = ComputeSeries(history, MetricSuccessRate, from, to, time.Hour, SeriesFilter{})
+	require.NoError(t, err)
+	require.Len(t, points, 1, "buckets without completed or failed tasks have no rate")
+	assert.InDelta(t, 2.0/3, points[0].Value, 1e-9)
+
+	points, err = ComputeSeries(history, MetricDurationP95, from, to, time.Hour, SeriesFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []SeriesPoint{{from, 3000}, {from.Add(2 * time.Hour), 1000}}, points)
+
+	points, err = ComputeSeries(history, MetricFailed, from, to, time.Hour, SeriesFilter{Template: "login"})
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, points[0].Value)
+
+	points, err = ComputeSeries(history, MetricTasks, from, to, time.Hour, SeriesFilter{Domain: "other.example"})
+	require.NoError(t, err)
+	assert.Equal(t, []SeriesPoint{{from, 0}, {from.Add(time.Hour), 0}, {from.Add(2 * time.Hour), 0}}, points)
+
+	points, err = ComputeSeries(nil, MetricTasks, from, from.Add(24*time.Hour), time.Nanosecond, SeriesFilter{})
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(points), maxSeriesPoints+1, "tiny steps are widened")
+
+	_, err = ComputeSeries(history, "bogus", from, to, time.Hour, SeriesFilter{})
+	assert.Error(t, err)
+	_, err = ComputeSeries(history, MetricTasks, to, from, time.Hour, SeriesFilter{})
+	assert.Error(t, err)
+}
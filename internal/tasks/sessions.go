@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+var (
+	// ErrSessionsUnsupported is returned when the executor cannot keep sessions.
+	ErrSessionsUnsupported = errors.New("browser executor does not support sessions")
+	// ErrSessionNotFound is returned when a named session does not exist.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionExists is returned when creating a session whose name is taken.
+	ErrSessionExists = errors.New("session already exists")
+	// ErrSessionBusy is returned when a session is needed idle but is running a task.
+	ErrSessionBusy = errors.New("session is busy")
+)
+
+// SessionExecutor is implemented by BrowserExecutors that can keep a browser
+// context (cookies, storage, logged-in state) alive across tasks. Tasks opt in
+// by setting Task.Session to the session name.
+type SessionExecutor interface {
+	CreateSession(name string, opts taskstypes.SessionOptions) (*taskstypes.SessionInfo, error)
+	GetSession(name string) (*taskstypes.SessionInfo, error)
+	ListSessions() []taskstypes.SessionInfo
+	CloseSession(name string) error
+}
+
+// Sessions returns the executor's session support, if it has any.
+func (m *Manager) Sessions() (SessionExecutor, error) {
+	sessions, ok := m.browserExecutor.(SessionExecutor)
+	if !ok {
+		return nil, ErrSessionsUnsupported
+	}
+	return sessions, nil
+}
+
+// RunOnSession runs task's actions on its session at once and returns the
+// finished task. It is meant for short commands that are not worth keeping,
+// such as those of the WebDriver endpoint: the task skips the queue, is
+// neither listed nor stored, and sends no callback. The task is cancelled
+// when ctx is done or the manager shuts down.
+func (m *Manager) RunOnSession(ctx context.Context, task *taskstypes.Task) (*taskstypes.Task, error) {
+	sessions, err := m.Sessions()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sessions.GetSession(task.Session); err != nil {
+		return nil, err
+	}
+	if err := task.Options.Validate(true); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	if err := m.normalizeURLs(task); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if err := m.ctx.Err(); err != nil {
+		m.mu.Unlock()
+		return nil, context.Cause(m.ctx)
+	}
+	// Shutdown waits for the command like any running task
+	m.running.Add(1)
+	m.mu.Unlock()
+	defer m.running.Done()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stop := context.AfterFunc(m.ctx, func() { cancel(context.Cause(m.ctx)) })
+	defer stop()
+
+	task.UpdateStatus(taskstypes.StatusRunning)
+	result, err := m.browserExecutor.ExecuteTask(ctx, task)
+	if result == nil {
+		result = &taskstypes.TaskResult{}
+	}
+	status := taskstypes.StatusCompleted
+	switch {
+	case ctx.Err() != nil:
+		status = taskstypes.StatusCancelled
+		result.Success = false
+		result.Error = context.Cause(ctx).Error()
+		result.ErrorCode = taskstypes.ErrorCancelled
+	case err != nil:
+		status = taskstypes.StatusFailed
+		result.Success = false
+		result.Error = err.Error()
+		if result.ErrorCode == "" {
+			result.ErrorCode = taskstypes.ErrorUnknown
+		}
+	}
+	task.Update(func(task *taskstypes.Task) {
+		task.Status = status
+		task.Result = result
+	})
+	return task.Snapshot(), nil
+}
@@ -0,0 +1,176 @@
+package tasks
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// DurationStats summarizes the run time of a set of tasks.
+type DurationStats struct {
+	P50Ms int64 `json:"p50_ms"`
+	P95Ms int64 `json:"p95_ms"`
+	MaxMs int64 `json:"max_ms"`
+}
+
+// GroupStats holds aggregate counts for a subset of tasks.
+type GroupStats struct {
+	Total       int           `json:"total"`
+	Completed   int           `json:"completed"`
+	Failed      int           `json:"failed"`
+	SuccessRate float64       `json:"success_rate"`
+	Durations   DurationStats `json:"durations"`
+}
+
+// Stats is the aggregate view over task history returned by /api/v1/stats.
+type Stats struct {
+	GroupStats
+	SampleSize      int                           `json:"sample_size"` // Tasks the stats were computed from
+	Truncated       bool                          `json:"truncated"`   // The window held more tasks; only the newest sample_size were used
+	ByStatus        map[taskstypes.TaskStatus]int `json:"by_status"`
+	FailuresByClass map[string]int                `json:"failures_by_class"`
+	FailuresByCode  map[taskstypes.ErrorCode]int  `json:"failures_by_code"` // Failed tasks whose result has an error_code
+	ByDomain        map[string]*GroupStats        `json:"by_domain"`
+	ByTemplate      map[string]*GroupStats        `json:"by_template"`         // Tasks run from a template, by template name
+	Artifacts       *ArtifactStats                `json:"artifacts,omitempty"` // Since startup, regardless of the window
+	Queue           *QueueStats                   `json:"queue,omitempty"`     // Current load, regardless of the window
+
+	DOMCache    *DOMCacheStats    `json:"dom_cache,omitempty"`    // Since startup, regardless of the window
+	BrowserPool *BrowserPoolStats `json:"browser_pool,omitempty"` // Current pool and counters since startup, when the pool is on
+}
+
+// ComputeStats aggregates success rates, duration percentiles, and failure
+// classes over the given tasks.
+func ComputeStats(history []*taskstypes.Task) Stats {
+	stats := Stats{
+		ByStatus:        make(map[taskstypes.TaskStatus]int),
+		FailuresByClass: make(map[string]int),
+		FailuresByCode:  make(map[taskstypes.ErrorCode]int),
+		ByDomain:        make(map[string]*GroupStats),
+		ByTemplate:      make(map[string]*GroupStats),
+	}
+
+	var all []time.Duration
+	domainDurations := make(map[string][]time.Duration)
+	templateDurations := make(map[string][]time.Duration)
+
+	for _, task := range history {
+		stats.ByStatus[task.Status]++
+
+		domain := primaryDomain(task.Actions)
+		if domain == "" {
+			domain = "unknown"
+		}
+		group, ok := stats.ByDomain[domain]
+		if !ok {
+			group = &GroupStats{}
+			stats.ByDomain[domain] = group
+		}
+
+		groups := []*GroupStats{&stats.GroupStats, group}
+		if task.TemplateName != "" {
+			tmpl, ok := stats.ByTemplate[task.TemplateName]
+			if !ok {
+				tmpl = &GroupStats{}
+				stats.ByTemplate[task.TemplateName] = tmpl
+			}
+			groups = append(groups, tmpl)
+		}
+
+		for _, g := range groups {
+			g.Total++
+			switch task.Status {
+			case taskstypes.StatusCompleted:
+				g.Completed++
+			case taskstypes.StatusFailed:
+				g.Failed++
+			}
+		}
+
+		if task.Status == taskstypes.StatusFailed {
+			stats.FailuresByClass[classifyFailure(task)]++
+			if task.Result != nil && task.Result.ErrorCode != "" {
+				stats.FailuresByCode[task.Result.ErrorCode]++
+			}
+		}
+
+		if d := task.Duration(); d > 0 {
+			all = append(all, d)
+			domainDurations[domain] = append(domainDurations[domain], d)
+			if task.TemplateName != "" {
+				templateDurations[task.TemplateName] = append(templateDurations[task.TemplateName], d)
+			}
+		}
+	}
+
+	stats.SuccessRate = successRate(stats.Completed, stats.Failed)
+	stats.Durations = summarizeDurations(all)
+	for domain, group := range stats.ByDomain {
+		group.SuccessRate = successRate(group.Completed, group.Failed)
+		group.Durations = summarizeDurations(domainDurations[domain])
+	}
+	for name, group := range stats.ByTemplate {
+		group.SuccessRate = successRate(group.Completed, group.Failed)
+		group.Durations = summarizeDurations(templateDurations[name])
+	}
+
+	return stats
+}
+
+func successRate(completed, failed int) float64 {
+	finished := completed + failed
+	if finished == 0 {
+		return 0
+	}
+	return float64(completed) / float64(finished)
+}
+
+func summarizeDurations(durations []time.Duration) DurationStats {
+	if len(durations) == 0 {
+		return DurationStats{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return DurationStats{
+		P50Ms: percentile(sorted, 0.50).Milliseconds(),
+		P95Ms: percentile(sorted, 0.95).Milliseconds(),
+		MaxMs: sorted[len(sorted)-1].Milliseconds(),
+	}
+}
+
+// percentile uses the nearest-rank method on an ascending slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// classifyFailure buckets a failed task's error message into a coarse class.
+func classifyFailure(task *taskstypes.Task) string {
+	if task.Result == nil || task.Result.Error == "" {
+		return "unknown"
+	}
+	msg := strings.ToLower(task.Result.Error)
+	switch {
+	case strings.Contains(msg, "2fa"):
+		return "2fa"
+	case strings.Contains(msg, "deadline exceeded"), strings.Contains(msg, "timeout"), strings.Contains(msg, "timed out"):
+		return "timeout"
+	case strings.Contains(msg, "net::err"):
+		return "navigation"
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "could not find"):
+		return "selector"
+	case strings.Contains(msg, "requires"), strings.Contains(msg, "invalid"), strings.Contains(msg, "unknown action"):
+		return "validation"
+	default:
+		return "other"
+	}
+}
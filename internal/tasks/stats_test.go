@@ -0,0 +1,129 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func finishedTask(host string, status taskstypes.TaskStatus, d time.Duration, errMsg string) *taskstypes.Task {
+	start := time.Now().Add(-time.Hour)
+	end := start.Add(d)
+	task := &taskstypes.Task{
+		ID:          uuid.New(),
+		Status:      status,
+		Actions:     []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://" + host + "/"}},
+		CreatedAt:   start,
+		StartedAt:   &start,
+		CompletedAt: &end,
+	}
+	if errMsg != "" {
+		task.Result = &taskstypes.TaskResult{Error: errMsg}
+	}
+	return task
+}
+
+func TestComputeStats(t *testing.T) {
+	history := []*taskstypes.Task{
+		finishedTask("a.example.com", taskstypes.StatusCompleted, 1*time.Second, ""),
+		finishedTask("a.example.com", taskstypes.StatusCompleted, 2*time.Second, ""),
+		finishedTask("a.example.com", taskstypes.StatusFailed, 3*time.Second, "context deadline exceeded"),
+		finishedTask("b.example.com", taskstypes.StatusFailed, 4*time.Second, "2FA code wait error: timeout"),
+	}
+
+	stats := ComputeStats(history)
+
+	assert.Equal(t, 4, stats.Total)
+	assert.Equal(t, 2, stats.Completed)
+	assert.InDelta(t, 0.5, stats.SuccessRate, 0.001)
+	assert.Equal(t, int64(2000), stats.Durations.P50Ms)
+	assert.Equal(t, int64(4000), stats.Durations.P95Ms)
+	assert.Equal(t, 1, stats.FailuresByClass["timeout"])
+	assert.Equal(t, 1, stats.FailuresByClass["2fa"])
+	assert.Empty(t, stats.FailuresByCode, "only results with an error_code are counted by code")
+
+	assert.Equal(t, 3, stats.ByDomain["a.example.com"].Total)
+	assert.InDelta(t, 2.0/3.0, stats.ByDomain["a.example.com"].SuccessRate, 0.001)
+	assert.Equal(t, 0.0, stats.ByDomain["b.example.com"].SuccessRate)
+}
+
+func TestComputeStats_ByTemplate(t *testing.T) {
+	fromTemplate := func(name string, task *taskstypes.Task) *taskstypes.Task {
+		task.TemplateName = name
+		return task
+	}
+	history := []*taskstypes.Task{
+		fromTemplate("login", finishedTask("a.example.com", taskstypes.StatusCompleted, 1*time.Second, "")),
+		fromTemplate("login", finishedTask("b.example.com", taskstypes.StatusFailed, 3*time.Second, "element not found")),
+		fromTemplate("export", finishedTask("a.example.com", taskstypes.StatusCompleted, 2*time.Second, "")),
+		finishedTask("a.example.com", taskstypes.StatusCompleted, 4*time.Second, ""),
+	}
+
+	stats := ComputeStats(history)
+
+	assert.Len(t, stats.ByTemplate, 2, "tasks without a template are left out")
+	assert.Equal(t, 2, stats.ByTemplate["login"].Total)
+	assert.Equal(t, 1, stats.ByTemplate["login"].Failed)
+	assert.InDelta(t, 0.5, stats.ByTemplate["login"].SuccessRate, 0.001)
+	assert.Equal(t, int64(3000), stats.ByTemplate["login"].Durations.MaxMs)
+	assert.Equal(t, 1, stats.ByTemplate["export"].Completed)
+	assert.Equal(t, 1.0, stats.ByTemplate["export"].SuccessRate)
+}
+
+func TestComputeStats_Empty(t *testing.T) {
+	stats := ComputeStats(nil)
+	assert.Equal(t, 0, stats.Total)
+	assert.Equal(t, DurationStats{}, stats.Durations)
+}
+
+func BenchmarkComputeStats(b *testing.B) {
+	hosts := []string{"example.com", "shop.example.com", "news.example.org", "login.example.net"}
+	history := make([]*taskstypes.Task, statsHistoryLimit)
+	for i := range history {
+		status, errMsg := taskstypes.StatusCompleted, ""
+		if i%10 == 0 {
+			status, errMsg = taskstypes.StatusFailed, "context deadline exceeded"
+		}
+		history[i] = finishedTask(hosts[i%len(hosts)], status, time.Duration(i%600)*time.Second, errMsg)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ComputeStats(history)
+	}
+}
+
+func TestComputeStats_FailuresByCode(t *testing.T) {
+	failed := finishedTask("a.example.com", taskstypes.StatusFailed, time.Second, "waiting for #buy")
+	failed.Result.ErrorCode = taskstypes.ErrorSelectorNotFound
+	crashed := finishedTask("a.example.com", taskstypes.StatusFailed, time.Second, "invalid target")
+	crashed.Result.ErrorCode = taskstypes.ErrorBrowserCrash
+
+	stats := ComputeStats([]*taskstypes.Task{failed, crashed})
+	assert.Equal(t, map[taskstypes.ErrorCode]int{taskstypes.ErrorSelectorNotFound: 1, taskstypes.ErrorBrowserCrash: 1}, stats.FailuresByCode)
+}
+
+func TestManager_StatsReportsSample(t *testing.T) {
+	m := NewManager(nil, mocks.NewMockBrowserExecutor(), logging.Discard())
+	defer m.Shutdown(context.Background())
+	for i := 0; i < statsHistoryLimit; i++ {
+		require.NoError(t, m.store.Save(finishedTask("example.com", taskstypes.StatusCompleted, time.Second, "")))
+	}
+
+	stats := m.Stats(time.Time{})
+	assert.Equal(t, statsHistoryLimit, stats.SampleSize)
+	assert.False(t, stats.Truncated)
+
+	require.NoError(t, m.store.Save(finishedTask("example.com", taskstypes.StatusCompleted, time.Second, "")))
+	stats = m.Stats(time.Time{})
+	assert.Equal(t, statsHistoryLimit, stats.SampleSize)
+	assert.Equal(t, statsHistoryLimit, stats.Total)
+	assert.True(t, stats.Truncated)
+}
@@ -0,0 +1,189 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+)
+
+const defaultListLimit = 100
+
+// ListFilter narrows the tasks returned by TaskStore.List.
+type ListFilter struct {
+	Status   taskstypes.TaskStatus // Empty matches any status
+	Template string                // Empty matches any template
+	Since    time.Time             // Zero matches any creation time
+	Limit    int                   // <= 0 uses defaultListLimit
+	Offset   int
+
+	// UpdatedSince and UpdatedBefore match tasks last updated in
+	// [UpdatedSince, UpdatedBefore); zero leaves that end open.
+	UpdatedSince  time.Time
+	UpdatedBefore time.Time
+	// Oldest orders by update time, least recently updated first, instead
+	// of by creation time, newest first.
+	Oldest bool
+
+	// ReferenceID and Tags match tasks submitted with that reference ID and
+	// with every one of those tags; empty matches any task.
+	ReferenceID string
+	Tags        map[string]string
+
+	// Tenant matches tasks submitted by that tenant's callers; empty matches
+	// any tenant. Tasks without a tenant belong to encryption.DefaultTenant.
+	Tenant string
+}
+
+// TaskStore persists tasks so that status, actions, and results survive restarts.
+// Implementations must be safe for concurrent use and must not retain the
+// pointer passed to Save.
+type TaskStore interface {
+	Save(task *taskstypes.Task) error
+	Get(id uuid.UUID) (*taskstypes.Task, error)
+	// List returns matching tasks ordered by creation time, newest first,
+	// or as filter.Oldest asks.
+	List(filter ListFilter) ([]*taskstypes.Task, error)
+	Close() error
+}
+
+// TemplatePersister is implemented by task stores that also keep template
+// versions, so templates survive restarts.
+type TemplatePersister interface {
+	SaveTemplate(tmpl *Template) error
+	// LoadTemplates returns every saved version, ordered by name and version.
+	LoadTemplates() ([]*Template, error)
+}
+
+// NewTaskStore opens the store selected by cfg. The keyring may be nil, in
+// which case task data is stored unencrypted.
+func NewTaskStore(cfg config.StorageConfig, keyring *encryption.Keyring) (TaskStore, error) {
+	switch cfg.Driver {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "sqlite":
+		return NewSQLiteStore(cfg.Path, keyring)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+}
+
+// MemoryStore is a TaskStore that keeps snapshots in memory. History is lost on restart.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	tasks map[uuid.UUID]*taskstypes.Task
+}
+
+// NewMemoryStore creates an empty in-memory task store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tasks: make(map[uuid.UUID]*taskstypes.Task)}
+}
+
+func (s *MemoryStore) Save(task *taskstypes.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := task.Snapshot()
+	snapshot.TfaCodeChan = nil
+	s.tasks[task.ID] = snapshot
+	return nil
+}
+
+func (s *MemoryStore) Get(id uuid.UUID) (*taskstypes.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	return task.Snapshot(), nil
+}
+
+func (s *MemoryStore) List(filter ListFilter) ([]*taskstypes.Task, error) {
+	s.mu.RLock()
+	matched := make([]*taskstypes.Task, 0)
+	for _, task := range s.tasks {
+		if filter.Status != "" && task.Status != filter.Status {
+			continue
+		}
+		if filter.Template != "" && task.TemplateName != filter.Template {
+			continue
+		}
+		if !filter.matchesLabels(task) {
+			continue
+		}
+		if task.CreatedAt.Before(filter.Since) || !filter.matchesUpdated(task.UpdatedAt) {
+			continue
+		}
+		matched = append(matched, task.Snapshot())
+	}
+	s.mu.RUnlock()
+
+	if filter.Oldest {
+		sort.Slice(matched, func(i, j int) bool {
+			if !matched[i].UpdatedAt.Equal(matched[j].UpdatedAt) {
+				return matched[i].UpdatedAt.Before(matched[j].UpdatedAt)
+			}
+			return matched[i].ID.String() < matched[j].ID.String() // Stable across pages
+		})
+	} else {
+		sort.Slice(matched, func(i, j int) bool { return matched[i].CreatedAt.After(matched[j].CreatedAt) })
+	}
+	return paginate(matched, filter), nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// matchesUpdated reports whether a task last updated at updated is in the
+// filter's update range.
+func (f ListFilter) matchesUpdated(updated time.Time) bool {
+	if !f.UpdatedSince.IsZero() && updated.Before(f.UpdatedSince) {
+		return false
+	}
+	return f.UpdatedBefore.IsZero() || updated.Before(f.UpdatedBefore)
+}
+
+// TaskTenant returns the tenant task belongs to.
+func TaskTenant(task *taskstypes.Task) string {
+	if task.Tenant == "" {
+		return encryption.DefaultTenant
+	}
+	return task.Tenant
+}
+
+// matchesLabels reports whether task has the filter's reference ID, tags, and tenant.
+func (f ListFilter) matchesLabels(task *taskstypes.Task) bool {
+	if f.ReferenceID != "" && task.ReferenceID != f.ReferenceID {
+		return false
+	}
+	if f.Tenant != "" && TaskTenant(task) != f.Tenant {
+		return false
+	}
+	for key, value := range f.Tags {
+		if got, ok := task.Tags[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+func paginate(tasks []*taskstypes.Task, filter ListFilter) []*taskstypes.Task {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if filter.Offset >= len(tasks) {
+		return []*taskstypes.Task{}
+	}
+	tasks = tasks[filter.Offset:]
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+	}
+	return tasks
+}
@@ -0,0 +1,328 @@
+package tasks
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite" // Registers the pure-Go "sqlite" database/sql driver
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS tasks (
+	id            TEXT PRIMARY KEY,
+	status        TEXT NOT NULL,
+	template_name TEXT NOT NULL DEFAULT '',
+	reference_id  TEXT NOT NULL DEFAULT '',
+	tags          TEXT NOT NULL DEFAULT '',
+	created_at    INTEGER NOT NULL,
+	updated_at    INTEGER NOT NULL,
+	encrypted     INTEGER NOT NULL DEFAULT 0,
+	data          BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks(created_at);
+CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status);
+CREATE INDEX IF NOT EXISTS idx_tasks_status_updated_at ON tasks(status, updated_at);
+CREATE TABLE IF NOT EXISTS templates (
+	name    TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	data    BLOB NOT NULL,
+	PRIMARY KEY (name, version)
+);
+`
+
+// sqliteIndexes are created after sqliteColumns exist, as they may be missing
+// from databases made by older versions.
+const sqliteIndexes = `
+CREATE INDEX IF NOT EXISTS idx_tasks_reference_id ON tasks(reference_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_tenant ON tasks(tenant);
+`
+
+// sqliteColumns were added to the tasks table after its first release. When
+// a column is added, fill is run to set it on the existing rows.
+var sqliteColumns = []struct{ name, definition, fill string }{
+	{"reference_id", "TEXT NOT NULL DEFAULT ''", ""},
+	{"tags", "TEXT NOT NULL DEFAULT ''", ""},
+	// Sealed rows record the tenant as the envelope's key ID, which named
+	// only the tenant whose key sealed them before key IDs had fingerprints
+	{"tenant", "TEXT NOT NULL DEFAULT ''", `UPDATE tasks SET tenant = coalesce(nullif(CASE encrypted
+		WHEN 0 THEN json_extract(CAST(data AS TEXT), '$.tenant')
+		ELSE json_extract(CAST(data AS TEXT), '$.kid') END, ''), 'default')`},
+}
+
+// SQLiteStore is a TaskStore backed by a SQLite database file.
+// The full task is stored as JSON; when a keyring is configured the JSON is
+// sealed so actions and results (which often contain PII) are encrypted at rest.
+// Status, timestamps, reference IDs, and tags stay in plaintext columns so
+// they can be queried.
+type SQLiteStore struct {
+	db      *sql.DB
+	keyring *encryption.Keyring
+}
+
+// NewSQLiteStore opens (or creates) the database at path and applies the schema.
+func NewSQLiteStore(path string, keyring *encryption.Keyring) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite storage requires a database path")
+	}
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; serializing through one connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to apply sqlite schema: %w", err)
+	}
+	return &SQLiteStore{db: db, keyring: keyring}, nil
+}
+
+// migrateSQLite creates the schema, adding columns that databases created by
+// older versions lack.
+func migrateSQLite(db *sql.DB) error {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('tasks')`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, column := range sqliteColumns {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE tasks ADD COLUMN ` + column.name + ` ` + column.definition); err != nil {
+			return err
+		}
+		if column.fill == "" {
+			continue
+		}
+		if _, err := db.Exec(column.fill); err != nil {
+			return err
+		}
+	}
+	_, err = db.Exec(sqliteIndexes)
+	return err
+}
+
+// encodeTags stores tags as sorted "\nkey=value" lines with a trailing
+// newline, so a tag can be matched with instr(tags, "\nkey=value\n"). Keys
+// cannot contain '=' and values cannot contain newlines.
+func encodeTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString("\n" + key + "=" + tags[key])
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (s *SQLiteStore) Save(task *taskstypes.Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task %s: %w", task.ID, err)
+	}
+
+	encrypted := 0
+	if s.keyring != nil {
+		if data, err = s.keyring.Seal(task.Tenant, data); err != nil {
+			return fmt.Errorf("failed to encrypt task %s: %w", task.ID, err)
+		}
+		encrypted = 1
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO tasks (id, status, template_name, reference_id, tags, tenant, created_at, updated_at, encrypted, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			status = excluded.status,
+			template_name = excluded.template_name,
+			reference_id = excluded.reference_id,
+			tags = excluded.tags,
+			tenant = excluded.tenant,
+			updated_at = excluded.updated_at,
+			encrypted = excluded.encrypted,
+			data = excluded.data`,
+		task.ID.String(), string(task.Status), task.TemplateName, task.ReferenceID, encodeTags(task.Tags), TaskTenant(task),
+		task.CreatedAt.UnixNano(), task.UpdatedAt.UnixNano(), encrypted, data,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save task %s: %w", task.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Get(id uuid.UUID) (*taskstypes.Task, error) {
+	row := s.db.QueryRow(`SELECT encrypted, data FROM tasks WHERE id = ?`, id.String())
+	task, err := s.scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	return task, err
+}
+
+func (s *SQLiteStore) List(filter ListFilter) ([]*taskstypes.Task, error) {
+	var where []string
+	var args []interface{}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, string(filter.Status))
+	}
+	if filter.Template != "" {
+		where = append(where, "template_name = ?")
+		args = append(args, filter.Template)
+	}
+	if filter.ReferenceID != "" {
+		where = append(where, "reference_id = ?")
+		args = append(args, filter.ReferenceID)
+	}
+	if filter.Tenant != "" {
+		where = append(where, "tenant = ?")
+		args = append(args, filter.Tenant)
+	}
+	for key, value := range filter.Tags {
+		where = append(where, "instr(tags, ?) > 0")
+		args = append(args, "\n"+key+"="+value+"\n")
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.Since.UnixNano())
+	}
+	if !filter.UpdatedSince.IsZero() {
+		where = append(where, "updated_at >= ?")
+		args = append(args, filter.UpdatedSince.UnixNano())
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		where = append(where, "updated_at < ?")
+		args = append(args, filter.UpdatedBefore.UnixNano())
+	}
+
+	query := `SELECT encrypted, data FROM tasks`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if filter.Oldest {
+		query += " ORDER BY updated_at ASC, id ASC"
+	} else {
+		query += " ORDER BY created_at DESC"
+	}
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*taskstypes.Task, 0)
+	for rows.Next() {
+		task, err := s.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, task)
+	}
+	return result, rows.Err()
+}
+
+// SaveTemplate stores a template version. Versions are never replaced, so
+// saving one that exists is an error.
+func (s *SQLiteStore) SaveTemplate(tmpl *Template) error {
+	data, err := json.Marshal(tmpl)
+	if err != nil {
+		return fmt.Errorf("failed to marshal template %s version %d: %w", tmpl.Name, tmpl.Version, err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO templates (name, version, data) VALUES (?, ?, ?)`, tmpl.Name, tmpl.Version, data); err != nil {
+		return fmt.Errorf("failed to save template %s version %d: %w", tmpl.Name, tmpl.Version, err)
+	}
+	return nil
+}
+
+// LoadTemplates returns every stored template version, by name and then version.
+func (s *SQLiteStore) LoadTemplates() ([]*Template, error) {
+	rows, err := s.db.Query(`SELECT data FROM templates ORDER BY name, version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load templates: %w", err)
+	}
+	defer rows.Close()
+	var templates []*Template
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to load templates: %w", err)
+		}
+		var tmpl Template
+		if err := json.Unmarshal(data, &tmpl); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal template: %w", err)
+		}
+		templates = append(templates, &tmpl)
+	}
+	return templates, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (s *SQLiteStore) scan(row rowScanner) (*taskstypes.Task, error) {
+	var encrypted int
+	var data []byte
+	if err := row.Scan(&encrypted, &data); err != nil {
+		return nil, err
+	}
+
+	if encrypted == 1 {
+		if s.keyring == nil {
+			return nil, fmt.Errorf("task data is encrypted but no keyring is configured")
+		}
+		plaintext, err := s.keyring.Open(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt task: %w", err)
+		}
+		data = plaintext
+	}
+
+	var task taskstypes.Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+	}
+	return &task, nil
+}
@@ -0,0 +1,299 @@
+package tasks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/encryption"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func storeTask(status taskstypes.TaskStatus, created time.Time) *taskstypes.Task {
+	return &taskstypes.Task{
+		ID:        uuid.New(),
+		Status:    status,
+		Actions:   []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com"}},
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+}
+
+func testStores(t *testing.T) map[string]TaskStore {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	keyring, err := encryption.NewKeyring(config.EncryptionConfig{
+		Enabled: true,
+		Keys:    map[string]string{"default": base64.StdEncoding.EncodeToString(key)},
+	})
+	require.NoError(t, err)
+
+	plain, err := NewSQLiteStore(filepath.Join(t.TempDir(), "plain.db"), nil)
+	require.NoError(t, err)
+	sealed, err := NewSQLiteStore(filepath.Join(t.TempDir(), "sealed.db"), keyring)
+	require.NoError(t, err)
+
+	stores := map[string]TaskStore{
+		"memory":           NewMemoryStore(),
+		"sqlite":           plain,
+		"sqlite-encrypted": sealed,
+	}
+	t.Cleanup(func() {
+		for _, s := range stores {
+			s.Close()
+		}
+	})
+	return stores
+}
+
+func TestTaskStore_SaveGet(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			task := storeTask(taskstypes.StatusRunning, time.Now().UTC())
+			require.NoError(t, store.Save(task))
+
+			task.Status = taskstypes.StatusCompleted
+			task.Result = &taskstypes.TaskResult{Success: true, Message: "done"}
+			require.NoError(t, store.Save(task))
+
+			got, err := store.Get(task.ID)
+			require.NoError(t, err)
+			assert.Equal(t, taskstypes.StatusCompleted, got.Status)
+			assert.Equal(t, "done", got.Result.Message)
+			assert.Equal(t, "https://example.com", got.Actions[0].Value)
+
+			_, err = store.Get(uuid.New())
+			assert.True(t, errors.Is(err, ErrTaskNotFound))
+		})
+	}
+}
+
+func TestTaskStore_List(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now().UTC()
+			old := storeTask(taskstypes.StatusCompleted, now.Add(-2*time.Hour))
+			recent := storeTask(taskstypes.StatusFailed, now.Add(-time.Minute))
+			newest := storeTask(taskstypes.StatusCompleted, now)
+			for _, task := range []*taskstypes.Task{old, recent, newest} {
+				require.NoError(t, store.Save(task))
+			}
+
+			all, err := store.List(ListFilter{})
+			require.NoError(t, err)
+			require.Len(t, all, 3)
+			assert.Equal(t, newest.ID, all[0].ID)
+
+			completed, err := store.List(ListFilter{Status: taskstypes.StatusCompleted})
+			require.NoError(t, err)
+			assert.Len(t, completed, 2)
+
+			lastHour, err := store.List(ListFilter{Since: now.Add(-time.Hour)})
+			require.NoError(t, err)
+			assert.Len(t, lastHour, 2)
+
+			page, err := store.List(ListFilter{Limit: 1, Offset: 1})
+			require.NoError(t, err)
+			require.Len(t, page, 1)
+			assert.Equal(t, recent.ID, page[0].ID)
+
+			oldestFirst, err := store.List(ListFilter{Oldest: true, UpdatedBefore: now})
+			require.NoError(t, err)
+			require.Len(t, oldestFirst, 2)
+			assert.Equal(t, []uuid.UUID{old.ID, recent.ID}, []uuid.UUID{oldestFirst[0].ID, oldestFirst[1].ID})
+
+			updated, err := store.List(ListFilter{UpdatedSince: now.Add(-time.Hour)})
+			require.NoError(t, err)
+			assert.Len(t, updated, 2)
+		})
+	}
+}
+
+func TestSQLiteStore_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+
+	store, err := NewSQLiteStore(path, nil)
+	require.NoError(t, err)
+	task := storeTask(taskstypes.StatusCompleted, time.Now().UTC())
+	require.NoError(t, store.Save(task))
+	require.NoError(t, store.Close())
+
+	reopened, err := NewSQLiteStore(path, nil)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	got, err := reopened.Get(task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.ID, got.ID)
+}
+
+func TestSQLiteStore_PersistsTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	actions := []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://example.com/login"}}
+
+	store, err := NewSQLiteStore(path, nil)
+	require.NoError(t, err)
+	templates := NewTemplateStore()
+	require.NoError(t, templates.Load(store))
+	_, err = templates.Put("login", "first", actions)
+	require.NoError(t, err)
+	_, err = templates.Put("login", "second", append(actions, taskstypes.Action{Type: taskstypes.ActionGetDOM}))
+	require.NoError(t, err)
+	_, err = templates.Rollback("login", 1)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	reopened, err := NewSQLiteStore(path, nil)
+	require.NoError(t, err)
+	defer reopened.Close()
+	restored := NewTemplateStore()
+	require.NoError(t, restored.Load(reopened))
+
+	versions, err := restored.Versions("login")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, "second", versions[1].Description)
+	assert.Equal(t, 1, versions[2].RolledBackFrom)
+	assert.Equal(t, actions, versions[2].Actions)
+
+	next, err := restored.Put("login", "fourth", actions)
+	require.NoError(t, err)
+	assert.Equal(t, 4, next.Version, "new versions continue the saved history")
+	saved, err := reopened.LoadTemplates()
+	require.NoError(t, err)
+	assert.Len(t, saved, 4)
+}
+
+func TestTaskStore_ListByLabels(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now().UTC()
+			growth := storeTask(taskstypes.StatusCompleted, now.Add(-time.Minute))
+			growth.Tags = map[string]string{"team": "growth", "env": "prod"}
+			growth.ReferenceID = "job-1"
+			staging := storeTask(taskstypes.StatusCompleted, now)
+			staging.Tags = map[string]string{"team": "growth", "env": "staging"}
+			staging.ReferenceID = "job-2"
+			untagged := storeTask(taskstypes.StatusCompleted, now)
+			untagged.Tenant = "acme"
+			for _, task := range []*taskstypes.Task{growth, staging, untagged} {
+				require.NoError(t, store.Save(task))
+			}
+
+			list, err := store.List(ListFilter{Tags: map[string]string{"team": "growth"}})
+			require.NoError(t, err)
+			assert.Len(t, list, 2)
+
+			list, err = store.List(ListFilter{Tags: map[string]string{"team": "growth", "env": "prod"}})
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, growth.ID, list[0].ID)
+			assert.Equal(t, growth.Tags, list[0].Tags)
+
+			list, err = store.List(ListFilter{Tags: map[string]string{"team": "grow"}})
+			require.NoError(t, err)
+			assert.Empty(t, list, "tag values match exactly")
+
+			list, err = store.List(ListFilter{ReferenceID: "job-2"})
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, staging.ID, list[0].ID)
+
+			list, err = store.List(ListFilter{Tenant: "acme"})
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, untagged.ID, list[0].ID)
+			list, err = store.List(ListFilter{Tenant: encryption.DefaultTenant})
+			require.NoError(t, err)
+			assert.Len(t, list, 2, "tasks without a tenant belong to the default tenant")
+		})
+	}
+}
+
+func TestSQLiteStore_MigratesOldSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	old, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = old.Exec(`CREATE TABLE tasks (
+		id TEXT PRIMARY KEY, status TEXT NOT NULL, template_name TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL, updated_at INTEGER NOT NULL,
+		encrypted INTEGER NOT NULL DEFAULT 0, data BLOB NOT NULL)`)
+	require.NoError(t, err)
+	// Rows written before tasks had a tenant column
+	for id, row := range map[string]struct {
+		encrypted int
+		data      string
+	}{
+		"plain-acme": {0, `{"tenant": "acme"}`},
+		"plain-none": {0, `{}`},
+		"sealed":     {1, `{"v": 1, "kid": "acme"}`},
+	} {
+		_, err = old.Exec(`INSERT INTO tasks (id, status, created_at, updated_at, encrypted, data) VALUES (?, 'completed', 0, 0, ?, ?)`, id, row.encrypted, []byte(row.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, old.Close())
+
+	store, err := NewSQLiteStore(path, nil)
+	require.NoError(t, err)
+	defer store.Close()
+	task := storeTask(taskstypes.StatusCompleted, time.Now().UTC())
+	task.ReferenceID = "job-1"
+	require.NoError(t, store.Save(task))
+
+	list, err := store.List(ListFilter{ReferenceID: "job-1"})
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	tenants := map[string]string{}
+	rows, err := store.db.Query(`SELECT id, tenant FROM tasks WHERE id != ?`, task.ID.String())
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var id, tenant string
+		require.NoError(t, rows.Scan(&id, &tenant))
+		tenants[id] = tenant
+	}
+	assert.Equal(t, map[string]string{"plain-acme": "acme", "plain-none": "default", "sealed": "acme"}, tenants)
+}
+
+func TestSQLiteStore_SealsWithTaskTenant(t *testing.T) {
+	keys := map[string]string{}
+	for _, tenant := range []string{"default", "acme"} {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		require.NoError(t, err)
+		keys[tenant] = base64.StdEncoding.EncodeToString(key)
+	}
+	keyring, err := encryption.NewKeyring(config.EncryptionConfig{Enabled: true, Keys: keys})
+	require.NoError(t, err)
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "tasks.db"), keyring)
+	require.NoError(t, err)
+	defer store.Close()
+
+	for tenant, keyID := range map[string]string{"acme": "acme", "": "default", "no-key": "default"} {
+		task := storeTask(taskstypes.StatusCompleted, time.Now().UTC())
+		task.Tenant = tenant
+		require.NoError(t, store.Save(task))
+
+		var data []byte
+		require.NoError(t, store.db.QueryRow(`SELECT data FROM tasks WHERE id = ?`, task.ID.String()).Scan(&data))
+		var env encryption.Envelope
+		require.NoError(t, json.Unmarshal(data, &env))
+		assert.True(t, strings.HasPrefix(env.KeyID, keyID+"/"), "tenant %q sealed with %s", tenant, env.KeyID)
+
+		got, err := store.Get(task.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenant, got.Tenant)
+	}
+}
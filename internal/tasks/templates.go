@@ -0,0 +1,214 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// ErrTemplateNotFound is returned when a template or template version does not exist.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// Template is an immutable, versioned action list that can be run as a task.
+type Template struct {
+	Name           string              `json:"name"`
+	Version        int                 `json:"version"`
+	Description    string              `json:"description,omitempty"`
+	Actions        []taskstypes.Action `json:"actions"`
+	CreatedAt      time.Time           `json:"created_at"`
+	RolledBackFrom int                 `json:"rolled_back_from,omitempty"` // Version this one was restored from
+}
+
+// ActionChange describes a difference between two template versions at one action index.
+type ActionChange struct {
+	Index  int                `json:"index"`
+	Change string             `json:"change"` // added, removed, changed
+	From   *taskstypes.Action `json:"from,omitempty"`
+	To     *taskstypes.Action `json:"to,omitempty"`
+}
+
+// TemplateDiff lists action-level changes between two template versions.
+type TemplateDiff struct {
+	Name    string         `json:"name"`
+	From    int            `json:"from"`
+	To      int            `json:"to"`
+	Changes []ActionChange `json:"changes"`
+}
+
+// TemplateStore keeps the full version history of every template.
+// Versions are append-only; rollback creates a new version with old content.
+type TemplateStore struct {
+	mu       sync.RWMutex
+	versions map[string][]*Template
+	canaries map[string]*TemplateCanary // The latest canary of each template
+	persist  TemplatePersister          // Where new versions are saved; nil keeps them in memory only
+}
+
+// NewTemplateStore creates an empty template store.
+func NewTemplateStore() *TemplateStore {
+	return &TemplateStore{versions: make(map[string][]*Template), canaries: make(map[string]*TemplateCanary)}
+}
+
+// Put stores a new version of the named template and returns it. It returns
+// ErrCanaryRunning while a canary of the template runs.
+func (s *TemplateStore) Put(name, description string, actions []taskstypes.Action) (*Template, error) {
+	if name == "" {
+		return nil, fmt.Errorf("template name is required")
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("template %s must contain at least one action", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.canaryRunningLocked(name) {
+		return nil, fmt.Errorf("%w: %s", ErrCanaryRunning, name)
+	}
+	return s.appendLocked(name, description, actions, 0)
+}
+
+// Load restores the versions saved in persister and saves new versions to
+// it from then on.
+func (s *TemplateStore) Load(persister TemplatePersister) error {
+	saved, err := persister.LoadTemplates()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := make(map[string][]*Template)
+	for _, tmpl := range saved {
+		if tmpl.Version != len(versions[tmpl.Name])+1 {
+			return fmt.Errorf("template %s: saved version %d is out of sequence", tmpl.Name, tmpl.Version)
+		}
+		versions[tmpl.Name] = append(versions[tmpl.Name], tmpl)
+	}
+	s.versions = versions
+	s.persist = persister
+	return nil
+}
+
+// appendLocked adds a new version of the named template, saving it first so
+// a version that could not be saved is never run.
+func (s *TemplateStore) appendLocked(name, description string, actions []taskstypes.Action, rolledBackFrom int) (*Template, error) {
+	tmpl := &Template{
+		Name:           name,
+		Version:        len(s.versions[name]) + 1,
+		Description:    description,
+		Actions:        append([]taskstypes.Action(nil), actions...),
+		CreatedAt:      time.Now().UTC(),
+		RolledBackFrom: rolledBackFrom,
+	}
+	if s.persist != nil {
+		if err := s.persist.SaveTemplate(tmpl); err != nil {
+			return nil, err
+		}
+	}
+	s.versions[name] = append(s.versions[name], tmpl)
+	return tmpl, nil
+}
+
+// Get returns a specific version of a template, or the one in use when
+// version is 0: the latest, or the baseline while a canary runs.
+func (s *TemplateStore) Get(name string, version int) (*Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getLocked(name, version)
+}
+
+func (s *TemplateStore) getLocked(name string, version int) (*Template, error) {
+	history := s.versions[name]
+	if len(history) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	if version == 0 {
+		if canary := s.canaries[name]; canary != nil && canary.State == CanaryRunning {
+			return history[canary.Baseline.Version-1], nil
+		}
+		return history[len(history)-1], nil
+	}
+	if version < 0 || version > len(history) {
+		return nil, fmt.Errorf("%w: %s version %d", ErrTemplateNotFound, name, version)
+	}
+	return history[version-1], nil
+}
+
+// List returns the version in use of every template, sorted by name.
+func (s *TemplateStore) List() []*Template {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := make([]*Template, 0, len(s.versions))
+	for name := range s.versions {
+		tmpl, _ := s.getLocked(name, 0)
+		latest = append(latest, tmpl)
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].Name < latest[j].Name })
+	return latest
+}
+
+// Versions returns the full version history of a template, oldest first.
+func (s *TemplateStore) Versions(name string) ([]*Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.versions[name]
+	if len(history) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return append([]*Template(nil), history...), nil
+}
+
+// Rollback restores the content of version as a new latest version. It
+// returns ErrCanaryRunning while a canary of the template runs.
+func (s *TemplateStore) Rollback(name string, version int) (*Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.canaryRunningLocked(name) {
+		return nil, fmt.Errorf("%w: %s", ErrCanaryRunning, name)
+	}
+	target, err := s.getLocked(name, version)
+	if err != nil {
+		return nil, err
+	}
+	return s.appendLocked(name, target.Description, target.Actions, target.Version)
+}
+
+// Diff compares two versions of a template action by action.
+func (s *TemplateStore) Diff(name string, from, to int) (*TemplateDiff, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	older, err := s.getLocked(name, from)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := s.getLocked(name, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &TemplateDiff{Name: name, From: older.Version, To: newer.Version, Changes: []ActionChange{}}
+	n := len(older.Actions)
+	if len(newer.Actions) > n {
+		n = len(newer.Actions)
+	}
+	for i := 0; i < n; i++ {
+		switch {
+		case i >= len(older.Actions):
+			diff.Changes = append(diff.Changes, ActionChange{Index: i, Change: "added", To: &newer.Actions[i]})
+		case i >= len(newer.Actions):
+			diff.Changes = append(diff.Changes, ActionChange{Index: i, Change: "removed", From: &older.Actions[i]})
+		case !reflect.DeepEqual(older.Actions[i], newer.Actions[i]):
+			diff.Changes = append(diff.Changes, ActionChange{Index: i, Change: "changed", From: &older.Actions[i], To: &newer.Actions[i]})
+		}
+	}
+	return diff, nil
+}
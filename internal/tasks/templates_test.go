@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateStore_Versions(t *testing.T) {
+	store := NewTemplateStore()
+
+	v1, err := store.Put("login", "initial", []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://example.com/login"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, v1.Version)
+
+	v2, err := store.Put("login", "add wait", []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://example.com/signin"},
+		{Type: taskstypes.ActionWaitVisible, Selector: "#username"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, v2.Version)
+
+	latest, err := store.Get("login", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, latest.Version)
+
+	pinned, err := store.Get("login", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/login", pinned.Actions[0].Value)
+
+	_, err = store.Get("login", 3)
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+}
+
+func TestTemplateStore_Diff(t *testing.T) {
+	store := NewTemplateStore()
+	_, _ = store.Put("t", "", []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://a.example.com"},
+		{Type: taskstypes.ActionClick, Selector: "#go"},
+	})
+	_, _ = store.Put("t", "", []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://b.example.com"},
+		{Type: taskstypes.ActionClick, Selector: "#go"},
+		{Type: taskstypes.ActionScreenshot},
+	})
+
+	diff, err := store.Diff("t", 1, 2)
+	require.NoError(t, err)
+	require.Len(t, diff.Changes, 2)
+	assert.Equal(t, "changed", diff.Changes[0].Change)
+	assert.Equal(t, 0, diff.Changes[0].Index)
+	assert.Equal(t, "added", diff.Changes[1].Change)
+	assert.Equal(t, 2, diff.Changes[1].Index)
+}
+
+func TestTemplateStore_Rollback(t *testing.T) {
+	store := NewTemplateStore()
+	_, _ = store.Put("t", "good", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://good.example.com"}})
+	_, _ = store.Put("t", "bad", []taskstypes.Action{{Type: taskstypes.ActionNavigate, Value: "https://bad.example.com"}})
+
+	restored, err := store.Rollback("t", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, restored.Version)
+	assert.Equal(t, 1, restored.RolledBackFrom)
+	assert.Equal(t, "https://good.example.com", restored.Actions[0].Value)
+
+	versions, err := store.Versions("t")
+	require.NoError(t, err)
+	assert.Len(t, versions, 3)
+}
@@ -0,0 +1,184 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/mcp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+)
+
+// callbackEventTFA names the request for a 2FA code sent when a task starts
+// waiting for one.
+const callbackEventTFA = "2fa_required"
+
+// inboxCheckTimeout bounds one check of the 2FA inbox.
+const inboxCheckTimeout = 30 * time.Second
+
+// ErrNoTaskWaiting is returned by RouteTFACode when no task is waiting for a
+// code sent to the recipient.
+var ErrNoTaskWaiting = errors.New("no task is waiting for a 2FA code")
+
+// RouteTFACode provides a code that arrived outside the API, such as by SMS,
+// to the task that has waited longest for a code from provider sent to
+// recipient: its two_factor_auth.phone_number for SMS, or email for email.
+func (m *Manager) RouteTFACode(provider taskstypes.TFAProvider, recipient, code string) (uuid.UUID, error) {
+	var target *taskstypes.Task
+	m.mu.RLock()
+	for _, task := range m.tasks {
+		snapshot := task.Snapshot()
+		if snapshot.Status != taskstypes.StatusWaitingFor2FA || snapshot.TwoFactorAuth.Provider != provider ||
+			!sameRecipient(provider, snapshot.TwoFactorAuth, recipient) {
+			continue
+		}
+		if target == nil || snapshot.UpdatedAt.Before(target.UpdatedAt) {
+			target = snapshot
+		}
+	}
+	m.mu.RUnlock()
+
+	if target == nil {
+		return uuid.Nil, fmt.Errorf("%w: %s", ErrNoTaskWaiting, recipient)
+	}
+	if err := m.Provide2FACode(target.ID, code); err != nil {
+		return target.ID, err
+	}
+	m.logger.Info("Routed 2FA code", logging.TaskIDKey, target.ID, "provider", provider)
+	return target.ID, nil
+}
+
+// sameRecipient reports whether a code sent to recipient is meant for a task.
+// Phone numbers are compared by their digits, so "+1 (555) 010-0000" matches
+// "+15550100000".
+func sameRecipient(provider taskstypes.TFAProvider, tfa taskstypes.TwoFactorAuthInfo, recipient string) bool {
+	switch provider {
+	case taskstypes.TFAProviderSMS:
+		digits := phoneDigits(tfa.PhoneNumber)
+		return digits != "" && digits == phoneDigits(recipient)
+	case taskstypes.TFAProviderEmail:
+		return tfa.Email != "" && strings.EqualFold(strings.TrimSpace(tfa.Email), strings.TrimSpace(recipient))
+	}
+	return false
+}
+
+func phoneDigits(number string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+}
+
+// TwoFactorPrompt describes a 2FA prompt an executor found on the page.
+type TwoFactorPrompt struct {
+	Kind    string // How the page asks for the code, e.g. "input" or "button"
+	PageURL string // The page showing the prompt, if known
+}
+
+// TwoFactorNotifier is called once a task is waiting for a 2FA code, to ask
+// the task's client for it and, for email 2FA, start reading it from the
+// configured inbox. It returns at once; the work happens in the background.
+type TwoFactorNotifier func(task *taskstypes.Task, prompt TwoFactorPrompt)
+
+// TwoFactorNotifierReceiver is implemented by executors that wait for 2FA
+// codes, so the code can be requested instead of the client polling the
+// status.
+type TwoFactorNotifierReceiver interface {
+	SetTwoFactorNotifier(notify TwoFactorNotifier)
+}
+
+// twoFactorWaiting is the manager's TwoFactorNotifier.
+func (m *Manager) twoFactorWaiting(task *taskstypes.Task, prompt TwoFactorPrompt) {
+	m.notifyTwoFactor(task, prompt)
+	if m.inbox != nil && task.Snapshot().TwoFactorAuth.Provider == taskstypes.TFAProviderEmail {
+		go m.readInboxCode(task, time.Now())
+	}
+}
+
+// readInboxCode checks the 2FA inbox for the task's code until it is found,
+// the task stops waiting, or the wait times out, and provides the code.
+func (m *Manager) readInboxCode(task *taskstypes.Task, promptedAt time.Time) {
+	logger := m.logger.With(logging.TaskIDKey, task.ID)
+	recipient := task.Snapshot().TwoFactorAuth.Email
+	ticker := time.NewTicker(m.inbox.PollInterval())
+	defer ticker.Stop()
+	deadline := time.NewTimer(twoFAWaitTimeout)
+	defer deadline.Stop()
+
+	for task.CurrentStatus() == taskstypes.StatusWaitingFor2FA {
+		ctx, cancel := context.WithTimeout(m.ctx, inboxCheckTimeout)
+		code, err := m.inbox.Code(ctx, recipient, promptedAt)
+		cancel()
+		switch {
+		case err != nil:
+			logger.Warn("Failed to check the 2FA inbox", "error", err)
+		case code != "":
+			if err := m.Provide2FACode(task.ID, code); err != nil {
+				logger.Info("2FA code from the inbox was not needed", "error", err)
+				return
+			}
+			logger.Info("Provided 2FA code from the inbox")
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// notifyTwoFactor posts an MCP 2FA request to the task's tfa_webhook, or its
+// callback URL when it has none. The request says where to send the code
+// and when the task stops waiting for it.
+func (m *Manager) notifyTwoFactor(task *taskstypes.Task, prompt TwoFactorPrompt) {
+	snapshot := task.Snapshot()
+	target := snapshot.TFAWebhook
+	if target == "" {
+		target = snapshot.CallbackURL
+	}
+	if target == "" {
+		return
+	}
+
+	details := "the page asked for a verification code"
+	switch snapshot.TwoFactorAuth.Provider {
+	case taskstypes.TFAProviderSMS:
+		details = "the code sent by SMS"
+	case taskstypes.TFAProviderEmail:
+		details = "the code sent by email"
+	case taskstypes.TFAProviderApp:
+		details = "the code from the authenticator app"
+	}
+	custom := map[string]interface{}{
+		"prompt":     prompt.Kind,
+		"submit_to":  "/api/v1/tasks/" + snapshot.ID.String() + "/2fa",
+		"expires_at": time.Now().Add(twoFAWaitTimeout).UTC(),
+	}
+	if provider := snapshot.TwoFactorAuth.Provider; provider != "" {
+		custom["provider"] = provider
+	}
+	if snapshot.ReferenceID != "" {
+		custom["reference_id"] = snapshot.ReferenceID
+	}
+	if len(snapshot.Tags) > 0 {
+		custom["tags"] = snapshot.Tags
+	}
+
+	payload, err := mcp.Format2FARequest(snapshot.ID.String(), details, prompt.PageURL, custom)
+	if err != nil {
+		m.logger.Error("Failed to format 2FA request", logging.TaskIDKey, snapshot.ID, "error", err)
+		return
+	}
+	m.logger.Info("Requesting 2FA code", logging.TaskIDKey, snapshot.ID, "url", target)
+	go m.postCallbackTo(snapshot, target, callbackEventTFA, payload)
+}
@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/mcp"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyTwoFactor(t *testing.T) {
+	requests := make(chan *http.Request, 2)
+	bodies := make(chan []byte, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	manager := newCallbackManager(t, config.CallbackConfig{MaxAttempts: 1})
+	task := &taskstypes.Task{
+		ID:            uuid.New(),
+		Status:        taskstypes.StatusWaitingFor2FA,
+		CallbackURL:   srv.URL + "/results",
+		TFAWebhook:    srv.URL + "/2fa",
+		TwoFactorAuth: taskstypes.TwoFactorAuthInfo{Provider: taskstypes.TFAProviderSMS},
+		ReferenceID:   "order-17",
+	}
+	manager.notifyTwoFactor(task, TwoFactorPrompt{Kind: "input", PageURL: "https://bank.example/verify"})
+
+	var r *http.Request
+	select {
+	case r = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no 2FA request was posted")
+	}
+	assert.Equal(t, "/2fa", r.URL.Path, "tfa_webhook takes precedence over the callback URL")
+	assert.NotEmpty(t, r.Header.Get(CallbackDeliveryHeader))
+
+	var msg mcp.Message
+	require.NoError(t, json.Unmarshal(<-bodies, &msg))
+	assert.Equal(t, task.ID.String(), msg.TaskID)
+	assert.Equal(t, "https://bank.example/verify", msg.Context.Metadata.SourceURI)
+	assert.Equal(t, "Two-factor authentication code required: the code sent by SMS", msg.Context.Content.Data)
+	custom := msg.Context.Metadata.Custom
+	assert.Equal(t, "2fa", custom["interaction_required"])
+	assert.Equal(t, "input", custom["prompt"])
+	assert.Equal(t, "sms", custom["provider"])
+	assert.Equal(t, "order-17", custom["reference_id"])
+	assert.Equal(t, "/api/v1/tasks/"+task.ID.String()+"/2fa", custom["submit_to"])
+	expiresAt, err := time.Parse(time.RFC3339, custom["expires_at"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(twoFAWaitTimeout), expiresAt, 5*time.Second)
+
+	// Without a webhook the request goes to the callback URL, and without either nowhere
+	task.TFAWebhook = ""
+	manager.notifyTwoFactor(task, TwoFactorPrompt{})
+	select {
+	case r = <-requests:
+		assert.Equal(t, "/results", r.URL.Path)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no 2FA request was posted")
+	}
+	manager.notifyTwoFactor(&taskstypes.Task{ID: uuid.New()}, TwoFactorPrompt{})
+}
@@ -0,0 +1,116 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// ErrInvalidURL is returned by SubmitTask when an action navigates to a URL
+// that cannot be loaded, whose scheme security.allowedURLSchemes does not
+// allow, or that security.urlPolicy blocks.
+var ErrInvalidURL = errors.New("invalid URL")
+
+// urlSchemes returns the schemes navigate actions may use.
+func (m *Manager) urlSchemes() []string {
+	if m.cfg != nil && len(m.cfg.Security.AllowedURLSchemes) > 0 {
+		return m.cfg.Security.AllowedURLSchemes
+	}
+	return taskstypes.DefaultURLSchemes
+}
+
+// newURLPolicy builds the policy from security.urlPolicy, or returns nil if it restricts nothing.
+func newURLPolicy(cfg *config.Config) *taskstypes.URLPolicy {
+	if cfg == nil {
+		return nil
+	}
+	c := cfg.Security.URLPolicy
+	policy := &taskstypes.URLPolicy{
+		AllowedDomains:       c.AllowedDomains,
+		BlockedDomains:       c.BlockedDomains,
+		BlockPrivateNetworks: c.BlockPrivateNetworks,
+	}
+	if !policy.Active() {
+		return nil
+	}
+	return policy
+}
+
+// URLPolicy returns the policy tasks are held to, or nil if there is none.
+func (m *Manager) URLPolicy() *taskstypes.URLPolicy {
+	return m.urlPolicy
+}
+
+// navigates reports whether an action loads its value as a page. A download
+// with a selector clicks the element instead.
+func navigates(action taskstypes.Action) bool {
+	switch action.Type {
+	case taskstypes.ActionNavigate, taskstypes.ActionSecurity, taskstypes.ActionCloaking:
+		return true
+	case taskstypes.ActionDownload:
+		return action.Selector == ""
+	}
+	return false
+}
+
+// normalizeURLs checks the URLs the task's actions navigate to, including
+// those in else branches, and rewrites them in normalized form. Fixes such
+// as removing credentials are added to task.Warnings. URLs that reference
+// earlier action output are checked by the browser when they are resolved.
+func (m *Manager) normalizeURLs(task *taskstypes.Task) error {
+	actions, warnings, err := normalizeActionURLs(task.Actions, m.urlSchemes(), m.urlPolicy)
+	if err != nil {
+		return err
+	}
+	task.Actions = actions
+	task.Warnings = append(task.Warnings, warnings...)
+	return nil
+}
+
+func normalizeActionURLs(actions []taskstypes.Action, schemes []string, policy *taskstypes.URLPolicy) ([]taskstypes.Action, []string, error) {
+	var normalized []taskstypes.Action
+	var warnings []string
+	for i, action := range actions {
+		changed := false
+		if navigates(action) && action.Value != "" && !strings.Contains(action.Value, "{{") {
+			u, fixes, err := taskstypes.NormalizeURL(action.Value, schemes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w in action %d: %v", ErrInvalidURL, i, err)
+			}
+			// Host names are resolved and checked again when the browser loads them
+			if err := policy.Check(u, true); err != nil {
+				return nil, nil, fmt.Errorf("%w in action %d: %v", ErrInvalidURL, i, err)
+			}
+			for _, fix := range fixes {
+				warnings = append(warnings, fmt.Sprintf("action %d: %s", i, fix))
+			}
+			changed = u != action.Value
+			action.Value = u
+		}
+		if len(action.Else) > 0 {
+			branch, fixes, err := normalizeActionURLs(action.Else, schemes, policy)
+			if err != nil {
+				return nil, nil, fmt.Errorf("else branch of action %d: %w", i, err)
+			}
+			for _, fix := range fixes {
+				warnings = append(warnings, fmt.Sprintf("else branch of action %d: %s", i, fix))
+			}
+			action.Else = branch
+			changed = true
+		}
+		if changed && normalized == nil {
+			// Copy on first change so the caller's actions, e.g. a template's, stay as they were
+			normalized = append(make([]taskstypes.Action, 0, len(actions)), actions...)
+		}
+		if normalized != nil {
+			normalized[i] = action
+		}
+	}
+	if normalized == nil {
+		return actions, warnings, nil
+	}
+	return normalized, warnings, nil
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/copyleftdev/goscry/internal/config"
+	"github.com/copyleftdev/goscry/internal/logging"
+	"github.com/copyleftdev/goscry/internal/tasks/mocks"
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_SubmitTaskNormalizesURLs(t *testing.T) {
+	manager := NewManager(&config.Config{}, mocks.NewMockBrowserExecutor(), logging.Discard())
+	newTask := func(actions ...taskstypes.Action) *taskstypes.Task {
+		return &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, Actions: actions, CreatedAt: time.Now()}
+	}
+
+	actions := []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://user:pass@Bücher.example/"},
+		{Type: taskstypes.ActionClick, Selector: "#next", If: &taskstypes.Condition{Selector: "#next"}, Else: []taskstypes.Action{
+			{Type: taskstypes.ActionNavigate, Value: "HTTPS://example.com/fallback"},
+		}},
+		{Type: taskstypes.ActionNavigate, Value: "{{actions.3.result}}"},
+	}
+	task := newTask(actions...)
+	require.NoError(t, manager.SubmitTask(task))
+	assert.Equal(t, "https://xn--bcher-kva.example/", task.Actions[0].Value)
+	assert.Equal(t, "https://example.com/fallback", task.Actions[1].Else[0].Value)
+	assert.Equal(t, "{{actions.3.result}}", task.Actions[2].Value, "references are resolved at run time")
+	assert.Len(t, task.Warnings, 1)
+	assert.Equal(t, "https://user:pass@Bücher.example/", actions[0].Value, "the submitted actions are not modified")
+
+	task = newTask(taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "file:///etc/passwd"})
+	assert.ErrorIs(t, manager.SubmitTask(task), ErrInvalidURL)
+	_, err := manager.GetTaskStatus(task.ID)
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+
+	// Other actions that load their value as a page are held to the same schemes
+	task = newTask(taskstypes.Action{Type: taskstypes.ActionCloaking, Value: "file:///etc/passwd"}, taskstypes.Action{Type: taskstypes.ActionGetDOM})
+	assert.ErrorIs(t, manager.SubmitTask(task), ErrInvalidURL, "cloaking_check")
+	task = newTask(taskstypes.Action{Type: taskstypes.ActionDownload, Value: "file:///etc/passwd"})
+	assert.ErrorIs(t, manager.SubmitTask(task), ErrInvalidURL, "download without a selector")
+	task = newTask(taskstypes.Action{Type: taskstypes.ActionDownload, Selector: "#export", Value: "report.csv"})
+	assert.NoError(t, manager.SubmitTask(task), "a download with a selector clicks it")
+
+	manager = NewManager(&config.Config{Security: config.SecurityConfig{AllowedURLSchemes: []string{"https", "file"}}}, mocks.NewMockBrowserExecutor(), logging.Discard())
+	assert.NoError(t, manager.SubmitTask(newTask(taskstypes.Action{Type: taskstypes.ActionNavigate, Value: "file:///srv/report.html"})))
+}
+
+func TestManager_SubmitTaskAppliesURLPolicy(t *testing.T) {
+	cfg := &config.Config{Security: config.SecurityConfig{URLPolicy: config.URLPolicyConfig{
+		BlockedDomains:       []string{"*.internal.example"},
+		BlockPrivateNetworks: true,
+	}}}
+	manager := NewManager(cfg, mocks.NewMockBrowserExecutor(), logging.Discard())
+	require.NotNil(t, manager.URLPolicy())
+	newTask := func(url string) *taskstypes.Task {
+		return &taskstypes.Task{ID: uuid.New(), Status: taskstypes.StatusPending, CreatedAt: time.Now(), Actions: []taskstypes.Action{
+			{Type: taskstypes.ActionNavigate, Value: "https://example.com/"},
+			{Type: taskstypes.ActionClick, Selector: "#next", If: &taskstypes.Condition{Selector: "#next"}, Else: []taskstypes.Action{
+				{Type: taskstypes.ActionNavigate, Value: url},
+			}},
+		}}
+	}
+
+	assert.NoError(t, manager.SubmitTask(newTask("https://www.example.com/")))
+	for _, url := range []string{"http://169.254.169.254/latest/meta-data/", "http://LOCALHOST:8080/", "https://db.internal.example/"} {
+		err := manager.SubmitTask(newTask(url))
+		assert.ErrorIs(t, err, ErrInvalidURL, url)
+		assert.ErrorContains(t, err, "else branch of action 1", url)
+	}
+
+	assert.Nil(t, NewManager(&config.Config{}, mocks.NewMockBrowserExecutor(), logging.Discard()).URLPolicy())
+}
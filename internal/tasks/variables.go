@@ -0,0 +1,74 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+)
+
+// ErrMissingVariable is returned by ApplyVariables when an action uses a
+// variable it was not given.
+var ErrMissingVariable = errors.New("missing template variable")
+
+// variablePattern matches {{vars.<name>}} placeholders in template actions.
+var variablePattern = regexp.MustCompile(`\{\{\s*vars\.([A-Za-z0-9_-]+)\s*\}\}`)
+
+// ApplyVariables returns a copy of actions with {{vars.<name>}} in their
+// Value, Selector, and condition replaced by the named variable. Names are
+// matched case-insensitively, since config files lowercase them. Values are
+// inserted as they are, without quoting for the context they land in.
+func ApplyVariables(actions []taskstypes.Action, vars map[string]string) ([]taskstypes.Action, error) {
+	lowered := make(map[string]string, len(vars))
+	for name, value := range vars {
+		lowered[strings.ToLower(name)] = value
+	}
+	missing := make(map[string]bool)
+	out := applyVariables(actions, lowered, missing)
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%w: %s", ErrMissingVariable, strings.Join(names, ", "))
+	}
+	return out, nil
+}
+
+func applyVariables(actions []taskstypes.Action, vars map[string]string, missing map[string]bool) []taskstypes.Action {
+	if actions == nil {
+		return nil
+	}
+	out := make([]taskstypes.Action, len(actions))
+	for i, action := range actions {
+		action.Value = substitute(action.Value, vars, missing)
+		action.Selector = substitute(action.Selector, vars, missing)
+		if action.If != nil {
+			cond := *action.If
+			cond.Selector = substitute(cond.Selector, vars, missing)
+			action.If = &cond
+		}
+		action.Else = applyVariables(action.Else, vars, missing)
+		out[i] = action
+	}
+	return out
+}
+
+func substitute(s string, vars map[string]string, missing map[string]bool) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := strings.ToLower(variablePattern.FindStringSubmatch(match)[1])
+		value, ok := vars[name]
+		if !ok {
+			missing[name] = true
+			return match
+		}
+		return value
+	})
+}
@@ -0,0 +1,32 @@
+package tasks
+
+import (
+	"testing"
+
+	"github.com/copyleftdev/goscry/internal/taskstypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyVariables(t *testing.T) {
+	actions := []taskstypes.Action{
+		{Type: taskstypes.ActionNavigate, Value: "https://crm.example/leads?email={{ vars.email }}"},
+		{Type: taskstypes.ActionClick, Selector: "#lead-{{vars.leadId}}", If: &taskstypes.Condition{Selector: "#lead-{{vars.leadId}}"}, Else: []taskstypes.Action{
+			{Type: taskstypes.ActionInput, Selector: "#search", Value: "{{vars.email}}"},
+		}},
+		{Type: taskstypes.ActionNavigate, Value: "{{actions.0.result}}"},
+	}
+
+	got, err := ApplyVariables(actions, map[string]string{"email": "ada@example.com", "LeadID": "42"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://crm.example/leads?email=ada@example.com", got[0].Value)
+	assert.Equal(t, "#lead-42", got[1].Selector)
+	assert.Equal(t, "#lead-42", got[1].If.Selector)
+	assert.Equal(t, "ada@example.com", got[1].Else[0].Value)
+	assert.Equal(t, "{{actions.0.result}}", got[2].Value, "output references are left for run time")
+	assert.Equal(t, "#lead-{{vars.leadId}}", actions[1].If.Selector, "the template's actions are not modified")
+
+	_, err = ApplyVariables(actions, map[string]string{"email": "ada@example.com"})
+	assert.ErrorIs(t, err, ErrMissingVariable)
+	assert.ErrorContains(t, err, "leadid")
+}
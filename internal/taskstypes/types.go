@@ -1,8 +1,15 @@
 package taskstypes
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 )
@@ -23,20 +30,56 @@ const (
 type ActionType string
 
 const (
-	ActionNavigate    ActionType = "navigate"
-	ActionWaitVisible ActionType = "wait_visible"
-	ActionWaitHidden  ActionType = "wait_hidden"
-	ActionWaitDelay   ActionType = "wait_delay"
-	ActionClick       ActionType = "click"
-	ActionInput       ActionType = "type"
-	ActionSelect      ActionType = "select"
-	ActionScroll      ActionType = "scroll"
-	ActionScreenshot  ActionType = "screenshot"
-	ActionGetDOM      ActionType = "get_dom"
-	ActionRunScript   ActionType = "run_script"
-	ActionLogin       ActionType = "login"
+	ActionNavigate     ActionType = "navigate"
+	ActionWaitVisible  ActionType = "wait_visible"
+	ActionWaitHidden   ActionType = "wait_hidden"
+	ActionWaitDelay    ActionType = "wait_delay"
+	ActionClick        ActionType = "click"
+	ActionInput        ActionType = "type"
+	ActionSelect       ActionType = "select"
+	ActionScroll       ActionType = "scroll"
+	ActionScreenshot   ActionType = "screenshot"
+	ActionGetDOM       ActionType = "get_dom"
+	ActionRunScript    ActionType = "run_script"
+	ActionLogin        ActionType = "login"
+	ActionDownload     ActionType = "download"
+	ActionSecurity     ActionType = "security_report"
+	ActionCloaking     ActionType = "cloaking_check"
+	ActionExtract      ActionType = "extract"
+	ActionChangePass   ActionType = "change_password"
+	ActionSwitchTab    ActionType = "switch_tab"
+	ActionCloseTab     ActionType = "close_tab"
+	ActionAdvanceClock ActionType = "advance_clock"
+	ActionFindText     ActionType = "find_text"
 )
 
+// ActionTypes lists every action type, in the order they are documented.
+var ActionTypes = []ActionType{
+	ActionNavigate, ActionWaitVisible, ActionWaitHidden, ActionWaitDelay,
+	ActionClick, ActionInput, ActionSelect, ActionScroll, ActionScreenshot,
+	ActionGetDOM, ActionRunScript, ActionLogin, ActionDownload, ActionSecurity,
+	ActionCloaking, ActionExtract, ActionChangePass, ActionSwitchTab,
+	ActionCloseTab, ActionAdvanceClock, ActionFindText,
+}
+
+// TaskPriority orders tasks waiting for a worker.
+type TaskPriority string
+
+const (
+	PriorityHigh   TaskPriority = "high"
+	PriorityNormal TaskPriority = "normal"
+	PriorityLow    TaskPriority = "low"
+)
+
+// Validate checks that p is a known priority; empty means normal.
+func (p TaskPriority) Validate() error {
+	switch p {
+	case "", PriorityHigh, PriorityNormal, PriorityLow:
+		return nil
+	}
+	return fmt.Errorf("priority must be %q, %q, or %q, got %q", PriorityHigh, PriorityNormal, PriorityLow, p)
+}
+
 // TFA provider constants
 type TFAProvider string
 
@@ -48,11 +91,169 @@ const (
 
 // Action represents a browser action to be performed
 type Action struct {
-	Type     ActionType    `json:"type"`
-	Selector string        `json:"selector,omitempty"`
-	Value    string        `json:"value,omitempty"`
-	Format   string        `json:"format,omitempty"`
-	Timeout  time.Duration `json:"-"`
+	Type      ActionType              `json:"type"`
+	Selector  string                  `json:"selector,omitempty"`
+	Value     string                  `json:"value,omitempty"`
+	Format    string                  `json:"format,omitempty"`
+	Fields    map[string]ExtractField `json:"fields,omitempty"`     // Used by extract
+	Repeat    *RepeatSpec             `json:"repeat,omitempty"`     // Run once per page of a paginated listing
+	Virtual   *VirtualList            `json:"virtual,omitempty"`    // Used by extract to scroll through a virtualized list
+	If        *Condition              `json:"if,omitempty"`         // Run only when the condition holds
+	Else      []Action                `json:"else,omitempty"`       // Run instead when If does not hold
+	Verify    *LoginCheck             `json:"verify,omitempty"`     // Used by login to confirm it worked
+	Login     *LoginForm              `json:"login,omitempty"`      // Used by login to locate the form fields
+	WaitUntil string                  `json:"wait_until,omitempty"` // Used by navigate: load (default), domcontentloaded, networkidle, or selector
+	Password  *PasswordForm           `json:"password,omitempty"`   // Used by change_password to locate the form fields
+	Clip      *ClipRect               `json:"clip,omitempty"`       // Used by screenshot to capture one region of the page
+	Find      *TextSearch             `json:"find,omitempty"`       // Used by find_text to tune how Value is matched
+	Timeout   time.Duration           `json:"-"`                    // Encoded as a duration string ("10s") under "timeout"; zero uses browser.actionTimeout
+}
+
+// ExtractField says where an extract action reads one output field from.
+// In JSON it may be an object or just a selector string (text mode).
+type ExtractField struct {
+	Selector  string `json:"selector,omitempty"`  // Relative to the container; empty reads the container itself
+	Attribute string `json:"attribute,omitempty"` // Read this attribute instead of the element's text
+	All       bool   `json:"all,omitempty"`       // Return every match as an array instead of the first
+}
+
+// UnmarshalJSON accepts a bare selector string as shorthand for {"selector": "..."}.
+func (f *ExtractField) UnmarshalJSON(data []byte) error {
+	var selector string
+	if err := json.Unmarshal(data, &selector); err == nil {
+		*f = ExtractField{Selector: selector}
+		return nil
+	}
+	type fieldAlias ExtractField
+	return json.Unmarshal(data, (*fieldAlias)(f))
+}
+
+// Navigation wait strategies
+const (
+	WaitUntilLoad             = "load"
+	WaitUntilDOMContentLoaded = "domcontentloaded"
+	WaitUntilNetworkIdle      = "networkidle"
+	WaitUntilSelector         = "selector" // Wait for Action.Selector to become visible
+)
+
+// LoginCheck decides whether a login worked. Every success condition that is
+// set must hold; any visible failure selector or failure text fails the
+// login immediately. A login without success conditions has worked once its
+// password field is gone.
+type LoginCheck struct {
+	SuccessURL       string   `json:"success_url,omitempty"`       // Regexp the page URL must match
+	SuccessSelector  string   `json:"success_selector,omitempty"`  // Element shown only when logged in
+	SuccessCookie    string   `json:"success_cookie,omitempty"`    // Cookie set by a successful login
+	FailureSelectors []string `json:"failure_selectors,omitempty"` // Error banners shown when the login is rejected
+	FailureText      []string `json:"failure_text,omitempty"`      // Messages shown when the login is rejected; login defaults to common ones
+}
+
+// LoginForm locates the fields of a login form. A preset supplies the
+// selectors of a well-known sign-in page; selectors set alongside it take
+// precedence. Empty selectors use common field names and autocomplete hints.
+type LoginForm struct {
+	Preset           string `json:"preset,omitempty"`            // e.g. "google", "microsoft", "okta"
+	UsernameSelector string `json:"username_selector,omitempty"` // Username or email field
+	PasswordSelector string `json:"password_selector,omitempty"` // Password field
+	SubmitSelector   string `json:"submit_selector,omitempty"`   // Button that signs in
+	NextSelector     string `json:"next_selector,omitempty"`     // Clicked after the username on pages that ask for the password next
+}
+
+// PasswordForm locates the fields of a change-password form. Empty selectors
+// use the autocomplete hints browsers and password managers rely on.
+type PasswordForm struct {
+	CurrentSelector string `json:"current_selector,omitempty"` // Default input[autocomplete='current-password']
+	NewSelector     string `json:"new_selector,omitempty"`     // Default input[autocomplete='new-password']
+	ConfirmSelector string `json:"confirm_selector,omitempty"` // Empty types the new password into every new_selector match
+}
+
+// ClipRect is a region of the page in CSS pixels, measured from the top-left
+// corner of the document rather than the viewport.
+type ClipRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// TextSearch tunes how a find_text action matches its Value against the
+// page's visible text.
+type TextSearch struct {
+	Regex         bool `json:"regex,omitempty"`          // Value is a JavaScript regular expression rather than literal text
+	CaseSensitive bool `json:"case_sensitive,omitempty"` // Matching ignores case by default
+	Context       int  `json:"context,omitempty"`        // Characters of text around each match; zero uses 40
+	MaxMatches    int  `json:"max_matches,omitempty"`    // Matches listed in the result; zero uses 100. The count covers all of them
+}
+
+// Condition states
+const (
+	ConditionPresent = "present"
+	ConditionAbsent  = "absent"
+	ConditionVisible = "visible"
+	ConditionHidden  = "hidden"
+)
+
+// Condition is checked against the page right before an action runs.
+type Condition struct {
+	Selector string `json:"selector"`
+	State    string `json:"state,omitempty"` // present (default), absent, visible, or hidden
+}
+
+// RepeatSpec runs an action on a page, clicks Next, and runs it again until
+// there is no enabled Next element, StopWhen matches, or MaxPages is reached.
+type RepeatSpec struct {
+	Next     string `json:"next"`                // Selector of the next-page link or button
+	StopWhen string `json:"stop_when,omitempty"` // Stop once this selector is present
+	WaitFor  string `json:"wait_for,omitempty"`  // Selector to wait for after each click; otherwise wait one second
+	MaxPages int    `json:"max_pages,omitempty"` // Zero or above browser.maxPages uses browser.maxPages
+}
+
+// VirtualList makes an extract action scroll through a virtualized (windowed)
+// list, which only keeps the rows in view in the DOM, reading the rows as
+// they mount. Rows seen more than once are kept once.
+type VirtualList struct {
+	Container  string `json:"container,omitempty"`   // Scrolling element; empty scrolls the page
+	Key        string `json:"key,omitempty"`         // Field that identifies a row; empty compares whole rows
+	Step       int    `json:"step,omitempty"`        // Pixels per scroll; zero scrolls 80% of the container's height
+	Settle     string `json:"settle,omitempty"`      // Wait after each scroll for rows to render, e.g. "500ms"; default 250ms
+	MaxScrolls int    `json:"max_scrolls,omitempty"` // Zero uses 200
+	MaxRows    int    `json:"max_rows,omitempty"`    // Stop once this many rows are read; zero reads to the end
+}
+
+// actionAlias has Action's fields without its JSON methods.
+type actionAlias Action
+
+// actionJSON is the wire form of Action, with Timeout as a duration string.
+type actionJSON struct {
+	actionAlias
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// MarshalJSON encodes Timeout as a duration string.
+func (a Action) MarshalJSON() ([]byte, error) {
+	wire := actionJSON{actionAlias: actionAlias(a)}
+	if a.Timeout > 0 {
+		wire.Timeout = a.Timeout.String()
+	}
+	return json.Marshal(wire)
+}
+
+// UnmarshalJSON accepts Timeout as a duration string such as "500ms" or "1m".
+func (a *Action) UnmarshalJSON(data []byte) error {
+	var wire actionJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*a = Action(wire.actionAlias)
+	a.Timeout = 0
+	if wire.Timeout != "" {
+		d, err := time.ParseDuration(wire.Timeout)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid action timeout %q", wire.Timeout)
+		}
+		a.Timeout = d
+	}
+	return nil
 }
 
 // SelectorOrDefault returns the selector if set, otherwise returns the default selector
@@ -65,8 +266,381 @@ func (a *Action) SelectorOrDefault(defaultSelector string) string {
 
 // Credentials for authentication actions
 type Credentials struct {
-	Username string `json:"-"`
-	Password string `json:"-"`
+	Username    string `json:"-"`
+	Password    string `json:"-"`
+	NewPassword string `json:"-"` // Used by change_password
+}
+
+// TaskOptions are per-task browser settings applied before the first action.
+type TaskOptions struct {
+	// JavaScript set to false disables page scripts (Emulation.setScriptExecutionDisabled),
+	// which is faster and safer for static content from untrusted pages.
+	JavaScript *bool `json:"javascript,omitempty"`
+	// PageWeight reports per-resource-type byte counts and the largest
+	// resources for each navigation under result.custom_data.page_weight.
+	PageWeight bool `json:"page_weight,omitempty"`
+	// FirstPartyOnly blocks requests to sites other than the one last navigated
+	// to, except hosts in ThirdPartyAllowlist ("cdn.example.net" or "*.example.net").
+	FirstPartyOnly      bool     `json:"first_party_only,omitempty"`
+	ThirdPartyAllowlist []string `json:"third_party_allowlist,omitempty"`
+	// SecurityFindings reports mixed-content warnings, CSP violations, and
+	// browser security console messages under result.custom_data.security_findings.
+	SecurityFindings bool `json:"security_findings,omitempty"`
+	// TrackNavigations records meta refreshes, script-initiated navigations,
+	// and HTTP redirects under result.custom_data.navigations.
+	TrackNavigations bool `json:"track_navigations,omitempty"`
+	// Console records console output, browser log entries, and uncaught
+	// exceptions under result.custom_data.console.
+	Console bool `json:"console,omitempty"`
+	// Cookies controls the cookie jar for the task; nil leaves it untouched.
+	Cookies *CookiePolicy `json:"cookies,omitempty"`
+	// Network records requests and responses under result.custom_data.network.
+	Network *NetworkCapture `json:"network,omitempty"`
+	// HAR saves every request the task's page makes as a HAR 1.2 archive,
+	// served by GET /api/v1/tasks/{taskID}/har.
+	HAR bool `json:"har,omitempty"`
+	// Trace saves the run as a Playwright trace (actions, DOM snapshots,
+	// screenshots, network, and console), served by GET /api/v1/tasks/{taskID}/trace.
+	Trace bool `json:"trace,omitempty"`
+	// ScreenshotEachStep captures a small JPEG of the page after every
+	// action, listed with each action's outcome in result.custom_data.steps.
+	ScreenshotEachStep bool `json:"screenshot_each_step,omitempty"`
+	// HighlightTarget outlines each action's target element in its step
+	// screenshot. It has no effect without ScreenshotEachStep.
+	HighlightTarget bool `json:"highlight_target,omitempty"`
+	// Proxy overrides browser.proxy for this task.
+	Proxy *ProxySettings `json:"proxy,omitempty"`
+	// UserAgent and AcceptLanguage override what the browser reports
+	// (navigator.userAgent, navigator.language, and the request headers).
+	UserAgent      string `json:"user_agent,omitempty"`
+	AcceptLanguage string `json:"accept_language,omitempty"` // e.g. "de-DE,de;q=0.9"
+	// Locale sets the ICU locale used by Intl and date formatting, e.g. "de-DE".
+	Locale string `json:"locale,omitempty"`
+	// Timezone is an IANA time zone ID such as "Europe/Berlin".
+	Timezone string `json:"timezone,omitempty"`
+	// Headers are sent with every request the task's pages make.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Dialogs decides how alert, confirm, prompt, and beforeunload dialogs
+	// are answered; nil accepts them.
+	Dialogs *DialogPolicy `json:"dialogs,omitempty"`
+	// Clock sets the time page scripts see through Date and performance.now,
+	// optionally frozen. Combine it with Timezone to fix the local time too.
+	Clock *ClockOptions `json:"clock,omitempty"`
+	// RandomSeed makes Math.random, crypto.getRandomValues, and
+	// crypto.randomUUID in the page return the same sequence on every run.
+	RandomSeed *uint32 `json:"random_seed,omitempty"`
+	// Profile starts the task's browser from a saved user-data-dir snapshot
+	// and, with Save, replaces the snapshot when the task succeeds.
+	Profile *ProfileOptions `json:"profile,omitempty"`
+	// Replay answers the page's requests from a previously captured network
+	// session instead of the network.
+	Replay *ReplayOptions `json:"replay,omitempty"`
+}
+
+// Validate checks the options and the combinations that cannot work
+// together. OnSession says whether the task runs on a named session, whose
+// browser is already started and so cannot take a proxy server or profile.
+func (o TaskOptions) Validate(onSession bool) error {
+	if proxy := o.Proxy; proxy != nil {
+		if err := proxy.Validate(); err != nil {
+			return fmt.Errorf("invalid proxy: %w", err)
+		}
+		if proxy.Server != "" && onSession {
+			return fmt.Errorf("proxy.server cannot be set for a task on a session; sessions use browser.proxy")
+		}
+	}
+	if dialogs := o.Dialogs; dialogs != nil {
+		if err := dialogs.Validate(); err != nil {
+			return fmt.Errorf("invalid dialogs: %w", err)
+		}
+	}
+	if clock := o.Clock; clock != nil {
+		if err := clock.Validate(); err != nil {
+			return fmt.Errorf("invalid clock: %w", err)
+		}
+	}
+	if replay := o.Replay; replay != nil {
+		if err := replay.Validate(); err != nil {
+			return fmt.Errorf("invalid replay: %w", err)
+		}
+		if o.FirstPartyOnly {
+			return fmt.Errorf("replay cannot be combined with first_party_only")
+		}
+	}
+	if profile := o.Profile; profile != nil {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("invalid profile: %w", err)
+		}
+		if onSession {
+			return fmt.Errorf("profile cannot be set for a task on a session; set it when creating the session")
+		}
+	}
+	return nil
+}
+
+// ProfileInfo describes a browser profile: one created through the API, a
+// snapshot a task saved, or both.
+type ProfileInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	AutoSave    bool       `json:"auto_save,omitempty"`  // Every successful task on the profile saves it, as if it set save
+	Size        int64      `json:"size"`                 // Bytes on disk, compressed; zero until saved
+	Encrypted   bool       `json:"encrypted"`            // Sealed with the security.encryption keyring
+	CreatedAt   *time.Time `json:"created_at,omitempty"` // Set for profiles created through the API
+	SavedAt     *time.Time `json:"saved_at,omitempty"`   // Unset until a task saves the profile
+	TaskID      string     `json:"task_id,omitempty"`    // Task whose run was saved, when known
+	Owner       string     `json:"owner,omitempty"`      // Tenant that created or first saved the profile
+}
+
+// ProfileOptions name the browser profile snapshot a task runs with.
+type ProfileOptions struct {
+	Name string `json:"name"`
+	Save bool   `json:"save,omitempty"` // Snapshot the profile after a successful run, e.g. a login
+}
+
+// Validate checks the profile name.
+func (p ProfileOptions) Validate() error {
+	return ValidateProfileName(p.Name)
+}
+
+// profileNamePattern keeps profile names usable as file names.
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidateProfileName checks that name can identify a profile snapshot:
+// up to 64 letters, digits, dots, dashes, and underscores.
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use up to 64 letters, digits, '.', '-', or '_')", name)
+	}
+	return nil
+}
+
+// Dialog policy actions.
+const (
+	DialogAccept  = "accept"
+	DialogDismiss = "dismiss"
+)
+
+// DialogPolicy answers native JavaScript dialogs, which otherwise block the
+// page until the action times out.
+type DialogPolicy struct {
+	Action     string `json:"action,omitempty"`      // accept (default) or dismiss
+	PromptText string `json:"prompt_text,omitempty"` // Answer entered into accepted prompt() dialogs
+}
+
+// Validate checks the policy's action.
+func (p DialogPolicy) Validate() error {
+	switch p.Action {
+	case "", DialogAccept, DialogDismiss:
+		return nil
+	}
+	return fmt.Errorf("dialog action must be %q or %q, got %q", DialogAccept, DialogDismiss, p.Action)
+}
+
+// Limits on task tags and reference IDs.
+const (
+	MaxTags              = 32
+	MaxTagValueLength    = 256
+	MaxReferenceIDLength = 256
+)
+
+// tagKeyPattern keeps tag keys usable in "?tag=key:value" filters.
+var tagKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,62}$`)
+
+// ValidateTags checks a task's tags: up to MaxTags keys of up to 63 letters,
+// digits, '.', '_', '/', or '-', with values of up to MaxTagValueLength
+// characters and no control characters.
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return fmt.Errorf("at most %d tags are allowed, got %d", MaxTags, len(tags))
+	}
+	for key, value := range tags {
+		if !tagKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid tag key %q (use up to 63 letters, digits, '.', '_', '/', or '-')", key)
+		}
+		if err := checkLabel(value, MaxTagValueLength); err != nil {
+			return fmt.Errorf("invalid value for tag %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// ValidateReferenceID checks a client-supplied reference ID.
+func ValidateReferenceID(id string) error {
+	if err := checkLabel(id, MaxReferenceIDLength); err != nil {
+		return fmt.Errorf("invalid reference_id: %w", err)
+	}
+	return nil
+}
+
+func checkLabel(s string, max int) error {
+	if len(s) > max {
+		return fmt.Errorf("longer than %d bytes", max)
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("contains control characters")
+		}
+	}
+	return nil
+}
+
+// ClockOptions override the page clock so time-dependent UIs such as
+// countdowns and scheduled banners render the same on every run.
+type ClockOptions struct {
+	Time time.Time `json:"time"` // Instant the page clock starts at, RFC 3339
+	// Freeze stops the clock at Time. Timers that are not yet due then only
+	// fire when advance_clock moves the clock past them.
+	Freeze bool `json:"freeze,omitempty"`
+}
+
+// Validate checks that a start time is set.
+func (c ClockOptions) Validate() error {
+	if c.Time.IsZero() {
+		return fmt.Errorf("clock.time is required")
+	}
+	return nil
+}
+
+// Values for ReplayOptions.Unmatched.
+const (
+	ReplayUnmatchedFail     = "fail"
+	ReplayUnmatchedContinue = "continue"
+)
+
+// ReplayOptions serve a task's requests from a recorded network session so a
+// flow can be re-run when the upstream site is rate-limiting or down. Set
+// exactly one source: the HAR archive a previous task saved, an inline HAR 1.2
+// log, or entries from a previous task's result.custom_data.network.
+type ReplayOptions struct {
+	TaskID  string          `json:"task_id,omitempty"`
+	HAR     json.RawMessage `json:"har,omitempty"`
+	Network json.RawMessage `json:"network,omitempty"`
+	// IgnoreQuery matches requests by method and URL without the query
+	// string, for pages that add cache busters.
+	IgnoreQuery bool `json:"ignore_query,omitempty"`
+	// Unmatched decides what happens to requests with no recorded response:
+	// "fail" (default) keeps the run hermetic, "continue" sends them to the network.
+	Unmatched string `json:"unmatched,omitempty"`
+}
+
+// Validate checks that exactly one source is set and the unmatched mode.
+func (r ReplayOptions) Validate() error {
+	sources := 0
+	for _, set := range []bool{r.TaskID != "", len(r.HAR) > 0, len(r.Network) > 0} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("replay needs exactly one of task_id, har, or network")
+	}
+	if r.TaskID != "" {
+		if _, err := uuid.Parse(r.TaskID); err != nil {
+			return fmt.Errorf("replay.task_id is not a valid task ID: %q", r.TaskID)
+		}
+	}
+	switch r.Unmatched {
+	case "", ReplayUnmatchedFail, ReplayUnmatchedContinue:
+	default:
+		return fmt.Errorf("replay.unmatched must be %q or %q, got %q", ReplayUnmatchedFail, ReplayUnmatchedContinue, r.Unmatched)
+	}
+	return nil
+}
+
+// ProxySettings route a task's browser traffic through a proxy. With only a
+// username and password, the configured proxy server is used with them.
+type ProxySettings struct {
+	Server   string `json:"server,omitempty"` // http://, https://, socks4:// or socks5:// with host:port
+	Username string `json:"username,omitempty"`
+	Password string `json:"-"`                // Read from "password" on input, never written back out
+	Bypass   string `json:"bypass,omitempty"` // Hosts that skip the proxy, e.g. "localhost;*.internal"
+}
+
+// UnmarshalJSON reads Password, which is omitted when the task is encoded.
+func (p *ProxySettings) UnmarshalJSON(data []byte) error {
+	type proxyAlias ProxySettings
+	var wire struct {
+		proxyAlias
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*p = ProxySettings(wire.proxyAlias)
+	p.Password = wire.Password
+	return nil
+}
+
+// Validate checks the server URL and that credentials are only given for
+// HTTP(S) proxies, the only kind Chrome can authenticate to.
+func (p ProxySettings) Validate() error {
+	if p.Server != "" {
+		u, err := url.Parse(p.Server)
+		if err != nil || u.Hostname() == "" || u.Port() == "" {
+			return fmt.Errorf("proxy server must look like scheme://host:port, got %q", p.Server)
+		}
+		if u.User != nil {
+			return fmt.Errorf("proxy credentials go in username and password, not the server URL")
+		}
+		switch u.Scheme {
+		case "http", "https":
+		case "socks4", "socks5":
+			if p.Username != "" || p.Password != "" {
+				return fmt.Errorf("%s proxies do not support authentication", u.Scheme)
+			}
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+	}
+	if p.Password != "" && p.Username == "" {
+		return fmt.Errorf("proxy password requires a username")
+	}
+	return nil
+}
+
+// NetworkCapture selects which network traffic a task records.
+type NetworkCapture struct {
+	URLPattern    string   `json:"url_pattern,omitempty"`    // Regexp; only matching URLs are recorded
+	ResourceTypes []string `json:"resource_types,omitempty"` // e.g. ["xhr", "fetch"]; empty records every type
+	Bodies        bool     `json:"bodies,omitempty"`         // Include request and response bodies
+	MaxBodyBytes  int      `json:"max_body_bytes,omitempty"` // Per body; zero uses 64 KiB
+	Stream        bool     `json:"stream,omitempty"`         // Also post entries to callback_url as they complete
+	// BodyRules limit which bodies are recorded: the first rule matching a
+	// response decides, and responses no rule matches keep only their
+	// metadata. Setting rules implies Bodies.
+	BodyRules []BodyRule `json:"body_rules,omitempty"`
+}
+
+// BodyRule selects the responses whose bodies a network capture records.
+type BodyRule struct {
+	URLPattern   string   `json:"url_pattern,omitempty"`    // Regexp; empty matches every URL
+	MIMETypes    []string `json:"mime_types,omitempty"`     // e.g. "application/json" or "text/*"; empty matches every type
+	MaxSize      int64    `json:"max_size,omitempty"`       // Bodies larger than this are skipped rather than fetched; zero is no limit
+	MaxBodyBytes int      `json:"max_body_bytes,omitempty"` // Truncation limit for matched bodies; zero uses the capture's
+}
+
+// CookiePolicy limits how a task's pages may use cookies. Domains match
+// their subdomains too, and "*" matches every cookie.
+type CookiePolicy struct {
+	BlockThirdParty bool     `json:"block_third_party,omitempty"` // Restrict cookies in third-party contexts
+	ClearBefore     []string `json:"clear_before,omitempty"`      // Domains whose cookies are deleted before the first action
+	ClearAfter      []string `json:"clear_after,omitempty"`       // Domains whose cookies are deleted after the last action
+	ReadOnly        bool     `json:"read_only,omitempty"`         // Undo cookie changes after each action
+}
+
+// ScriptsDisabled reports whether page JavaScript should be turned off.
+func (o TaskOptions) ScriptsDisabled() bool {
+	return o.JavaScript != nil && !*o.JavaScript
+}
+
+// SealedCredentials are credentials encrypted by the client to the server's
+// published credential key. They are decrypted only by the executor.
+type SealedCredentials struct {
+	KeyID        string `json:"key_id"`
+	EncryptedKey []byte `json:"encrypted_key"` // RSA-OAEP-SHA256 wrapped AES-256 key, base64
+	Nonce        []byte `json:"nonce"`         // AES-GCM nonce, base64
+	Ciphertext   []byte `json:"ciphertext"`    // AES-GCM sealed {"username","password"} JSON, base64
 }
 
 // TwoFactorAuthInfo for 2FA configuration and state
@@ -76,31 +650,182 @@ type TwoFactorAuthInfo struct {
 	Provider    TFAProvider `json:"provider"`
 	Email       string      `json:"email,omitempty"`
 	PhoneNumber string      `json:"phone_number,omitempty"`
-	Secret      string      `json:"-"`
+	Secret      string      `json:"-"` // Base32 TOTP secret, read from "secret" on input, never written back out
 	Code        string      `json:"-"`
 }
 
-// Task struct definition
+// UnmarshalJSON reads Secret, which is omitted when the task is encoded.
+func (i *TwoFactorAuthInfo) UnmarshalJSON(data []byte) error {
+	type tfaAlias TwoFactorAuthInfo
+	var wire struct {
+		tfaAlias
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*i = TwoFactorAuthInfo(wire.tfaAlias)
+	i.Secret = wire.Secret
+	return nil
+}
+
+// GeneratesCodes reports whether 2FA prompts are answered with TOTP codes
+// generated from Secret instead of waiting for a code to be provided.
+func (i TwoFactorAuthInfo) GeneratesCodes() bool {
+	return i.Provider == TFAProviderApp && i.Secret != ""
+}
+
+// Task struct definition. Fields that change while a task runs (Status,
+// CurrentAction, Result, timestamps, BrowserContextID) must be written through
+// UpdateStatus, SetCurrentAction, SetResult, or Update, and read from a
+// Snapshot by anything other than the goroutine running the task.
 type Task struct {
-	ID               uuid.UUID         `json:"id"`
-	Status           TaskStatus        `json:"status"`
-	Actions          []Action          `json:"actions"`
-	Credentials      *Credentials      `json:"-"`
-	TwoFactorAuth    TwoFactorAuthInfo `json:"two_factor_auth"`
-	CurrentAction    int               `json:"current_action"`
-	Result           *TaskResult       `json:"result,omitempty"`
-	CreatedAt        time.Time         `json:"created_at"`
-	UpdatedAt        time.Time         `json:"updated_at"`
-	BrowserContextID string            `json:"-"`
-	CallbackURL      string            `json:"callback_url,omitempty"`
-	TfaCodeChan      chan string       `json:"-"`
+	ID               uuid.UUID          `json:"id"`
+	Status           TaskStatus         `json:"status"`
+	Actions          []Action           `json:"actions"`
+	Options          TaskOptions        `json:"options"`
+	Credentials      *Credentials       `json:"-"`
+	SealedCreds      *SealedCredentials `json:"-"`
+	CredentialsRef   string             `json:"credentials_ref,omitempty"` // e.g. "vault:myapp/login", resolved by the executor
+	TwoFactorAuth    TwoFactorAuthInfo  `json:"two_factor_auth"`
+	CurrentAction    int                `json:"current_action"`
+	Result           *TaskResult        `json:"result,omitempty"`
+	CreatedAt        time.Time          `json:"created_at"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+	StartedAt        *time.Time         `json:"started_at,omitempty"`
+	CompletedAt      *time.Time         `json:"completed_at,omitempty"`
+	BrowserContextID string             `json:"-"`
+	CallbackURL      string             `json:"callback_url,omitempty"`
+	CallbackFields   []string           `json:"callback_fields,omitempty"` // Top-level fields sent in the result callback; empty sends all
+	TFAWebhook       string             `json:"tfa_webhook,omitempty"`     // Where 2FA code requests are posted; empty uses CallbackURL
+	Session          string             `json:"session,omitempty"`
+	TemplateName     string             `json:"template_name,omitempty"`
+	TemplateVersion  int                `json:"template_version,omitempty"`
+	Canary           string             `json:"canary,omitempty"`       // "baseline" or "candidate" when run during a template canary
+	Tags             map[string]string  `json:"tags,omitempty"`         // Client labels, e.g. {"team": "growth"}
+	ReferenceID      string             `json:"reference_id,omitempty"` // The client's own ID for the job this task belongs to
+	Priority         TaskPriority       `json:"priority,omitempty"`     // Empty runs as normal
+	Warnings         []string           `json:"warnings,omitempty"`     // Problems fixed up at submission, e.g. credentials removed from a URL
+	Tenant           string             `json:"tenant,omitempty"`       // Whose security.encryption key seals the task's data; empty uses the default key
+	TFAWaitMs        int64              `json:"tfa_wait_ms,omitempty"`  // Time spent waiting for 2FA codes
+	TfaCodeChan      chan string        `json:"-"`
+
+	mu            sync.RWMutex  // Guards the mutable fields above
+	statusChanged chan struct{} // Closed when Status next changes; nil until someone waits
+}
+
+// Update runs fn with the task locked for writing. fn must not call other
+// locking Task methods.
+func (t *Task) Update(fn func(t *Task)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := t.Status
+	fn(t)
+	if t.Status != status {
+		t.notifyStatusChanged()
+	}
+}
+
+// notifyStatusChanged wakes WaitStatusChange callers. t.mu must be held.
+func (t *Task) notifyStatusChanged() {
+	if t.statusChanged != nil {
+		close(t.statusChanged)
+		t.statusChanged = nil
+	}
+}
+
+// WaitStatusChange blocks until the task's status is no longer from, or ctx
+// is done. It returns at once if the status already differs.
+func (t *Task) WaitStatusChange(ctx context.Context, from TaskStatus) {
+	t.mu.Lock()
+	if t.Status != from {
+		t.mu.Unlock()
+		return
+	}
+	if t.statusChanged == nil {
+		t.statusChanged = make(chan struct{})
+	}
+	changed := t.statusChanged
+	t.mu.Unlock()
+
+	select {
+	case <-changed:
+	case <-ctx.Done():
+	}
+}
+
+// Snapshot returns a copy of the task that is safe to read while the
+// original keeps running. The result is copied too; its Data and CustomData
+// are shared, since they are not modified once the result is set.
+func (t *Task) Snapshot() *Task {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	snapshot := &Task{
+		ID:               t.ID,
+		Status:           t.Status,
+		Actions:          t.Actions,
+		Options:          t.Options,
+		Credentials:      t.Credentials,
+		SealedCreds:      t.SealedCreds,
+		CredentialsRef:   t.CredentialsRef,
+		TwoFactorAuth:    t.TwoFactorAuth,
+		CurrentAction:    t.CurrentAction,
+		CreatedAt:        t.CreatedAt,
+		UpdatedAt:        t.UpdatedAt,
+		StartedAt:        t.StartedAt,
+		CompletedAt:      t.CompletedAt,
+		BrowserContextID: t.BrowserContextID,
+		CallbackURL:      t.CallbackURL,
+		CallbackFields:   t.CallbackFields,
+		TFAWebhook:       t.TFAWebhook,
+		Session:          t.Session,
+		TemplateName:     t.TemplateName,
+		TemplateVersion:  t.TemplateVersion,
+		Canary:           t.Canary,
+		Tags:             t.Tags,
+		ReferenceID:      t.ReferenceID,
+		Priority:         t.Priority,
+		Warnings:         t.Warnings,
+		Tenant:           t.Tenant,
+		TFAWaitMs:        t.TFAWaitMs,
+		TfaCodeChan:      t.TfaCodeChan,
+	}
+	if t.Result != nil {
+		result := *t.Result
+		snapshot.Result = &result
+	}
+	return snapshot
+}
+
+// CurrentStatus returns the task's status.
+func (t *Task) CurrentStatus() TaskStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.Status
+}
+
+// SetCurrentAction records which action the task is running.
+func (t *Task) SetCurrentAction(index int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.CurrentAction = index
+	t.UpdatedAt = time.Now()
 }
 
 // WaitForTFACode waits for a 2FA code to be provided through the task's channel
 func (t *Task) WaitForTFACode(ctx context.Context) (string, error) {
+	t.mu.Lock()
 	if t.TfaCodeChan == nil {
 		t.TfaCodeChan = make(chan string, 1)
 	}
+	codes := t.TfaCodeChan
+	t.mu.Unlock()
+	start := time.Now()
+	defer func() {
+		t.mu.Lock()
+		t.TFAWaitMs += time.Since(start).Milliseconds()
+		t.mu.Unlock()
+	}()
 
 	// Create a timeout context if not already done
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
@@ -108,40 +833,192 @@ func (t *Task) WaitForTFACode(ctx context.Context) (string, error) {
 
 	// Wait for either a code or a timeout
 	select {
-	case code := <-t.TfaCodeChan:
+	case code := <-codes:
 		return code, nil
 	case <-ctx.Done():
 		return "", ctx.Err()
 	}
 }
 
+// Duration returns how long the task ran, or zero if it has not started or finished.
+func (t *Task) Duration() time.Duration {
+	if t.StartedAt == nil || t.CompletedAt == nil {
+		return 0
+	}
+	return t.CompletedAt.Sub(*t.StartedAt)
+}
+
+// ActiveDuration returns how long the task ran, leaving out time spent
+// waiting for 2FA codes.
+func (t *Task) ActiveDuration() time.Duration {
+	return t.Duration() - time.Duration(t.TFAWaitMs)*time.Millisecond
+}
+
+// IsTerminal reports whether the task has reached a final status.
+func (s TaskStatus) IsTerminal() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCancelled
+}
+
+// SessionInfo describes a named browser session that tasks can share.
+type SessionInfo struct {
+	Name            string     `json:"name"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      time.Time  `json:"last_used_at"`
+	TaskCount       int        `json:"task_count"`
+	Busy            bool       `json:"busy"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"` // When MaxLifetime closes the session
+	KeepAlive       string     `json:"keep_alive,omitempty"` // Keep-alive interval, if enabled
+	LastKeepAliveAt *time.Time `json:"last_keep_alive_at,omitempty"`
+	KeepAliveError  string     `json:"keep_alive_error,omitempty"` // Error from the most recent keep-alive
+	LoginTemplate   string     `json:"login_template,omitempty"`   // Template used to re-login automatically
+	Profile         string     `json:"profile,omitempty"`          // Profile snapshot the session started from
+	Owner           string     `json:"owner,omitempty"`            // Tenant that created the session
+
+	CDPLeased bool `json:"cdp_leased,omitempty"` // Lent to an outside CDP client; tasks wait until it is handed back
+}
+
+// SessionOptions control how long a named session lives and whether it is
+// kept active between tasks to avoid idle logouts.
+type SessionOptions struct {
+	KeepAlive       time.Duration // Interval between keep-alive pings; zero disables them
+	KeepAliveJitter time.Duration // Up to this much random delay is added to each interval
+	KeepAliveURL    string        // Page loaded on each ping; empty reloads the current page
+	MaxLifetime     time.Duration // Close the session this long after creation; zero never expires
+	Login           *SessionLogin // Signs the session back in when a task lands on a login page
+	Profile         string        // Profile snapshot the session's browser starts from; empty starts clean
+	Owner           string        // Tenant that created the session; only it and admins may use it
+}
+
+// SessionLogin is how a session re-authenticates after its login expires.
+type SessionLogin struct {
+	Template       string             // Login template run to sign back in
+	Version        int                // Template version; zero uses the latest
+	Match          string             // Regexp matched against the page URL; empty uses common login paths
+	Credentials    *Credentials       // Used by login actions in the template
+	SealedCreds    *SealedCredentials // Alternative to Credentials, decrypted only when logging in
+	CredentialsRef string             // Alternative to Credentials, resolved only when logging in
+	TOTPSecret     string             // Base32 secret used to fill {{task.tfa_code}}
+}
+
 // TaskResult contains the execution result
 type TaskResult struct {
 	Success    bool                   `json:"success"`
 	Message    string                 `json:"message,omitempty"`
 	Data       interface{}            `json:"data,omitempty"`
 	Error      string                 `json:"error,omitempty"`
+	TimedOut   bool                   `json:"timed_out,omitempty"` // An action exceeded its timeout
 	CustomData map[string]interface{} `json:"custom_data,omitempty"`
+	Summary    *ResultSummary         `json:"summary,omitempty"` // Describes Data, which responses omit unless asked for
+
+	// ErrorCode classifies a failure, and FailedAction says which action
+	// failed, so callers can react without parsing Error.
+	ErrorCode    ErrorCode     `json:"error_code,omitempty"`
+	FailedAction *FailedAction `json:"failed_action,omitempty"`
+}
+
+// ErrorCode classifies why a task failed.
+type ErrorCode string
+
+const (
+	ErrorSelectorNotFound  ErrorCode = "SELECTOR_NOT_FOUND"        // The selector matched nothing, usually because the page changed
+	ErrorNavigationTimeout ErrorCode = "NAVIGATION_TIMEOUT"        // A page did not finish loading in time
+	ErrorNavigationFailed  ErrorCode = "NAVIGATION_FAILED"         // A page could not be loaded at all, e.g. net::ERR_NAME_NOT_RESOLVED
+	ErrorActionTimeout     ErrorCode = "ACTION_TIMEOUT"            // Another action exceeded its timeout
+	ErrorTFATimeout        ErrorCode = "TFA_TIMEOUT"               // No 2FA code was provided in time
+	ErrorLoginFailed       ErrorCode = "LOGIN_FAILED"              // A login was rejected, or did not leave the login form
+	ErrorScriptError       ErrorCode = "SCRIPT_ERROR"              // JavaScript run by the action threw
+	ErrorBrowserCrash      ErrorCode = "BROWSER_CRASH"             // The browser or the page's renderer went away
+	ErrorBotProtection     ErrorCode = "BLOCKED_BY_BOT_PROTECTION" // The page was a bot protection challenge or block page
+	ErrorDialogUnhandled   ErrorCode = "DIALOG_UNHANDLED"          // A JavaScript dialog was left open, blocking the page
+	ErrorInvalidAction     ErrorCode = "INVALID_ACTION"            // The action could not be built from its parameters
+	ErrorURLBlocked        ErrorCode = "URL_BLOCKED"               // security.urlPolicy refused a page the action loaded
+	ErrorActionFailed      ErrorCode = "ACTION_FAILED"             // The action failed for another reason
+	ErrorTaskTimeout       ErrorCode = "TASK_TIMEOUT"              // The task as a whole ran out of time
+	ErrorCancelled         ErrorCode = "CANCELLED"                 // Cancelled by a client or at shutdown
+	ErrorInterrupted       ErrorCode = "INTERRUPTED"               // The server restarted while the task was running
+	ErrorUnknown           ErrorCode = "UNKNOWN"                   // The executor failed without saying why
+)
+
+// FailedAction identifies the action a task failed on. Index is its
+// position in the task's actions; for an action in an else branch it is the
+// position of the action the branch belongs to.
+type FailedAction struct {
+	Index    int        `json:"index"`
+	Type     ActionType `json:"type"`
+	Selector string     `json:"selector,omitempty"`
+}
+
+// ResultSummary describes a result's Data without its content, so task
+// responses stay small however much a task extracted.
+type ResultSummary struct {
+	DataType   string     `json:"data_type"`             // object, array, string, number, boolean, or null
+	DataBytes  int        `json:"data_bytes"`            // Size of Data as JSON
+	Items      int        `json:"items,omitempty"`       // Elements of an array or keys of an object
+	Archived   bool       `json:"archived,omitempty"`    // Data was moved to the result archive
+	ArchivedAt *time.Time `json:"archived_at,omitempty"` // When Data was archived
+}
+
+// SummarizeData describes data as it would be encoded in a task result.
+func SummarizeData(data interface{}) *ResultSummary {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return &ResultSummary{DataType: "unknown"}
+	}
+	summary := &ResultSummary{DataBytes: len(raw)}
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		summary.DataType = "null"
+	case raw[0] == '{':
+		summary.DataType = "object"
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(raw, &fields) == nil {
+			summary.Items = len(fields)
+		}
+	case raw[0] == '[':
+		summary.DataType = "array"
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) == nil {
+			summary.Items = len(items)
+		}
+	case raw[0] == '"':
+		summary.DataType = "string"
+	case raw[0] == 't' || raw[0] == 'f':
+		summary.DataType = "boolean"
+	default:
+		summary.DataType = "number"
+	}
+	return summary
 }
 
 // UpdateStatus updates the task status and timestamp
 func (t *Task) UpdateStatus(status TaskStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Status != status {
+		t.notifyStatusChanged()
+	}
 	t.Status = status
 	t.UpdatedAt = time.Now()
 }
 
 // SetResult sets the task result
 func (t *Task) SetResult(success bool, message string, data interface{}, customData map[string]interface{}, err error) {
-	if t.Result == nil {
-		t.Result = &TaskResult{}
+	result := &TaskResult{}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Result != nil {
+		*result = *t.Result
 	}
 
-	t.Result.Success = success
-	t.Result.Message = message
-	t.Result.Data = data
-	t.Result.CustomData = customData
+	result.Success = success
+	result.Message = message
+	result.Data = data
+	result.CustomData = customData
 
 	if err != nil {
-		t.Result.Error = err.Error()
+		result.Error = err.Error()
 	}
+	// Replace rather than modify the result, so snapshots taken earlier are unaffected
+	t.Result = result
 }
@@ -2,11 +2,15 @@ package taskstypes
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTask_Creation(t *testing.T) {
@@ -70,6 +74,7 @@ func TestTask_TwoFactorAuth(t *testing.T) {
 	code, err := task.WaitForTFACode(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, "123456", code)
+	assert.GreaterOrEqual(t, task.Snapshot().TFAWaitMs, int64(100), "time waiting for the code is recorded")
 }
 
 func TestTaskResult_Creation(t *testing.T) {
@@ -166,3 +171,263 @@ func TestTaskStatuses(t *testing.T) {
 		seen[statusStr] = true
 	}
 }
+
+func TestTaskOptions_ScriptsDisabled(t *testing.T) {
+	enabled, disabled := true, false
+
+	assert.False(t, TaskOptions{}.ScriptsDisabled(), "JavaScript is on by default")
+	assert.False(t, TaskOptions{JavaScript: &enabled}.ScriptsDisabled())
+	assert.True(t, TaskOptions{JavaScript: &disabled}.ScriptsDisabled())
+}
+
+func TestAction_TimeoutJSON(t *testing.T) {
+	var action Action
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"wait_visible","selector":"#app","timeout":"1.5s"}`), &action))
+	assert.Equal(t, 1500*time.Millisecond, action.Timeout)
+	assert.Equal(t, "#app", action.Selector)
+
+	data, err := json.Marshal(action)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"wait_visible","selector":"#app","timeout":"1.5s"}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"type":"click","timeout":"soon"}`), &action))
+}
+
+func TestAction_ExtractFieldsJSON(t *testing.T) {
+	var action Action
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"extract","selector":".product","fields":{
+		"name":".title",
+		"link":{"selector":"a","attribute":"href"},
+		"tags":{"selector":".tag","all":true}
+	}}`), &action))
+
+	assert.Equal(t, ActionExtract, action.Type)
+	assert.Equal(t, ExtractField{Selector: ".title"}, action.Fields["name"])
+	assert.Equal(t, ExtractField{Selector: "a", Attribute: "href"}, action.Fields["link"])
+	assert.Equal(t, ExtractField{Selector: ".tag", All: true}, action.Fields["tags"])
+}
+
+func TestAction_ConditionJSON(t *testing.T) {
+	var action Action
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"type":"click","selector":"#accept",
+		"if":{"selector":"#login-wall","state":"absent"},
+		"else":[{"type":"login"},{"type":"click","selector":"#accept","timeout":"2s"}]
+	}`), &action))
+
+	assert.Equal(t, &Condition{Selector: "#login-wall", State: ConditionAbsent}, action.If)
+	assert.Len(t, action.Else, 2)
+	assert.Equal(t, ActionLogin, action.Else[0].Type)
+	assert.Equal(t, 2*time.Second, action.Else[1].Timeout, "nested actions use the same JSON form")
+}
+
+func TestTask_Snapshot(t *testing.T) {
+	task := &Task{ID: uuid.New(), Status: StatusRunning, Tenant: "acme"}
+	task.SetResult(true, "first", nil, nil, nil)
+
+	snapshot := task.Snapshot()
+	task.SetCurrentAction(3)
+	task.UpdateStatus(StatusCompleted)
+	task.SetResult(false, "second", nil, nil, nil)
+
+	assert.Equal(t, StatusRunning, snapshot.Status)
+	assert.Equal(t, 0, snapshot.CurrentAction)
+	assert.Equal(t, "acme", snapshot.Tenant, "persisted snapshots are sealed with the tenant's key")
+	assert.Equal(t, "first", snapshot.Result.Message, "later results do not change earlier snapshots")
+	assert.Equal(t, StatusCompleted, task.CurrentStatus())
+	assert.Equal(t, 3, task.Snapshot().CurrentAction)
+}
+
+func TestProxySettings_PasswordNotEncoded(t *testing.T) {
+	var opts TaskOptions
+	err := json.Unmarshal([]byte(`{"proxy":{"server":"http://proxy.local:3128","username":"u","password":"secret"}}`), &opts)
+	require.NoError(t, err)
+	require.NotNil(t, opts.Proxy)
+	assert.Equal(t, "secret", opts.Proxy.Password)
+
+	out, err := json.Marshal(opts)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "secret")
+}
+
+func TestTwoFactorAuthInfo_SecretNotEncoded(t *testing.T) {
+	var info TwoFactorAuthInfo
+	err := json.Unmarshal([]byte(`{"expected":true,"provider":"app","secret":"JBSWY3DPEHPK3PXP"}`), &info)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", info.Secret)
+	assert.True(t, info.GeneratesCodes())
+
+	out, err := json.Marshal(info)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "JBSWY3DPEHPK3PXP")
+
+	info.Provider = TFAProviderSMS
+	assert.False(t, info.GeneratesCodes(), "only app-based 2FA uses the secret")
+}
+
+func TestProxySettings_Validate(t *testing.T) {
+	valid := []ProxySettings{
+		{},
+		{Server: "http://proxy.local:3128"},
+		{Server: "https://proxy.local:443", Username: "u", Password: "p"},
+		{Server: "socks5://127.0.0.1:1080", Bypass: "localhost"},
+		{Username: "u", Password: "p"},
+	}
+	for _, p := range valid {
+		assert.NoError(t, p.Validate(), "%+v", p)
+	}
+
+	invalid := []ProxySettings{
+		{Server: "proxy.local:3128"},
+		{Server: "http://proxy.local"},
+		{Server: "ftp://proxy.local:21"},
+		{Server: "http://u:p@proxy.local:3128"},
+		{Server: "socks5://127.0.0.1:1080", Username: "u"},
+		{Password: "p"},
+	}
+	for _, p := range invalid {
+		assert.Error(t, p.Validate(), "%+v", p)
+	}
+}
+
+func TestTask_WaitStatusChange(t *testing.T) {
+	task := &Task{Status: StatusRunning}
+
+	// Returns at once when the status already differs
+	task.WaitStatusChange(context.Background(), StatusPending)
+
+	done := make(chan struct{})
+	go func() {
+		task.WaitStatusChange(context.Background(), StatusRunning)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	task.SetCurrentAction(3) // Not a status change
+	select {
+	case <-done:
+		t.Fatal("woke without a status change")
+	case <-time.After(20 * time.Millisecond):
+	}
+	task.Update(func(task *Task) { task.Status = StatusWaitingFor2FA })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("did not wake on status change")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	task.WaitStatusChange(ctx, StatusWaitingFor2FA)
+	assert.Equal(t, StatusWaitingFor2FA, task.CurrentStatus())
+}
+
+func TestDialogPolicy_Validate(t *testing.T) {
+	for _, action := range []string{"", DialogAccept, DialogDismiss} {
+		assert.NoError(t, DialogPolicy{Action: action}.Validate(), action)
+	}
+	assert.Error(t, DialogPolicy{Action: "ignore"}.Validate())
+}
+
+func TestSummarizeData(t *testing.T) {
+	tests := []struct {
+		data interface{}
+		want ResultSummary
+	}{
+		{map[string]int{"a": 1, "b": 2}, ResultSummary{DataType: "object", DataBytes: 13, Items: 2}},
+		{[]string{"x", "y", "z"}, ResultSummary{DataType: "array", DataBytes: 13, Items: 3}},
+		{"hello", ResultSummary{DataType: "string", DataBytes: 7}},
+		{3.5, ResultSummary{DataType: "number", DataBytes: 3}},
+		{false, ResultSummary{DataType: "boolean", DataBytes: 5}},
+		{nil, ResultSummary{DataType: "null", DataBytes: 4}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, *SummarizeData(tt.data), "%v", tt.data)
+	}
+}
+
+func TestValidateProfileName(t *testing.T) {
+	for _, name := range []string{"acme-sso", "crm.prod", "a", "user_1"} {
+		assert.NoError(t, ProfileOptions{Name: name}.Validate(), name)
+	}
+	for _, name := range []string{"", ".hidden", "../etc", "a/b", "with space", strings.Repeat("a", 65)} {
+		assert.Error(t, ValidateProfileName(name), name)
+	}
+}
+
+func TestClockOptions(t *testing.T) {
+	var opts TaskOptions
+	require.NoError(t, json.Unmarshal([]byte(`{"clock":{"time":"2030-12-31T23:59:30Z","freeze":true},"timezone":"Europe/Berlin"}`), &opts))
+	require.NotNil(t, opts.Clock)
+	assert.Equal(t, time.Date(2030, 12, 31, 23, 59, 30, 0, time.UTC), opts.Clock.Time.UTC())
+	assert.True(t, opts.Clock.Freeze)
+	assert.NoError(t, opts.Clock.Validate())
+
+	assert.Error(t, ClockOptions{Freeze: true}.Validate(), "a start time is required")
+}
+
+func TestTaskOptions_RandomSeed(t *testing.T) {
+	var opts TaskOptions
+	require.NoError(t, json.Unmarshal([]byte(`{"random_seed":0}`), &opts))
+	require.NotNil(t, opts.RandomSeed, "zero is a valid seed")
+	assert.Equal(t, uint32(0), *opts.RandomSeed)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"random_seed":-1}`), &opts))
+}
+
+func TestReplayOptions(t *testing.T) {
+	var opts TaskOptions
+	require.NoError(t, json.Unmarshal([]byte(`{"replay":{"har":{"log":{"entries":[]}},"ignore_query":true}}`), &opts))
+	require.NotNil(t, opts.Replay)
+	assert.JSONEq(t, `{"log":{"entries":[]}}`, string(opts.Replay.HAR))
+	assert.NoError(t, opts.Replay.Validate())
+
+	assert.NoError(t, ReplayOptions{TaskID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8", Unmatched: ReplayUnmatchedContinue}.Validate())
+	assert.Error(t, ReplayOptions{}.Validate(), "a source is required")
+	assert.Error(t, ReplayOptions{TaskID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8", Network: json.RawMessage(`[]`)}.Validate(), "only one source")
+	assert.Error(t, ReplayOptions{TaskID: "last-run"}.Validate())
+	assert.Error(t, ReplayOptions{Network: json.RawMessage(`[]`), Unmatched: "ignore"}.Validate())
+}
+
+func TestValidateTags(t *testing.T) {
+	assert.NoError(t, ValidateTags(nil))
+	assert.NoError(t, ValidateTags(map[string]string{"team": "growth", "k8s/namespace": "jobs", "empty": ""}))
+
+	tooMany := map[string]string{}
+	for i := 0; i <= MaxTags; i++ {
+		tooMany[fmt.Sprintf("tag%d", i)] = "x"
+	}
+	for _, tags := range []map[string]string{
+		tooMany,
+		{"": "x"},
+		{"team:name": "x"},
+		{"team": "line\nbreak"},
+		{"team": strings.Repeat("x", MaxTagValueLength+1)},
+	} {
+		assert.Error(t, ValidateTags(tags), "%v", tags)
+	}
+
+	assert.NoError(t, ValidateReferenceID("crm-export/2024-06-01#17"))
+	assert.Error(t, ValidateReferenceID("tab\there"))
+}
+
+func TestTaskOptions_Validate(t *testing.T) {
+	replay := &ReplayOptions{TaskID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}
+	assert.NoError(t, TaskOptions{}.Validate(true))
+	assert.NoError(t, TaskOptions{Replay: replay, Profile: &ProfileOptions{Name: "crm"}}.Validate(false))
+	assert.NoError(t, TaskOptions{Proxy: &ProxySettings{Username: "u", Password: "p"}}.Validate(true), "sessions can still authenticate to browser.proxy")
+
+	for _, tt := range []struct {
+		opts      TaskOptions
+		onSession bool
+	}{
+		{TaskOptions{Dialogs: &DialogPolicy{Action: "ignore"}}, false},
+		{TaskOptions{Clock: &ClockOptions{Freeze: true}}, false},
+		{TaskOptions{Replay: &ReplayOptions{}}, false},
+		{TaskOptions{Replay: replay, FirstPartyOnly: true}, false},
+		{TaskOptions{Profile: &ProfileOptions{Name: "../crm"}}, false},
+		{TaskOptions{Profile: &ProfileOptions{Name: "crm"}}, true},
+		{TaskOptions{Proxy: &ProxySettings{Server: "http://proxy.example:3128"}}, true},
+	} {
+		assert.Error(t, tt.opts.Validate(tt.onSession), "%+v on session %v", tt.opts, tt.onSession)
+	}
+}
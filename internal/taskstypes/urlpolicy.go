@@ -0,0 +1,170 @@
+package taskstypes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// ErrURLBlocked is wrapped by URLPolicy errors, so callers can tell a URL the
+// policy refuses from one that is merely invalid.
+var ErrURLBlocked = errors.New("URL blocked by policy")
+
+// URLPolicy limits which hosts tasks may reach, so a remotely controlled
+// browser cannot be pointed at the services around it.
+type URLPolicy struct {
+	// AllowedDomains, if set, are the only hosts pages may be loaded from:
+	// "example.com" or "*.example.com", which also matches example.com.
+	// Resources the pages load themselves are not limited by it.
+	AllowedDomains []string
+	// BlockedDomains are never reached, by navigations or page resources.
+	BlockedDomains []string
+	// BlockPrivateNetworks refuses loopback, private, link-local (which
+	// includes cloud metadata endpoints), and carrier-grade NAT addresses,
+	// whether written as an IP or resolved from a host name.
+	BlockPrivateNetworks bool
+
+	// LookupHost resolves host names for BlockPrivateNetworks; nil uses the system resolver.
+	LookupHost func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// metadataHosts are cloud metadata services reachable by name.
+var metadataHosts = map[string]bool{
+	"metadata":                 true,
+	"metadata.google.internal": true,
+	"metadata.goog":            true,
+}
+
+// privatePrefixes are non-public ranges netip.Addr has no predicate for.
+var privatePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "This network"; 0.0.0.0 reaches localhost
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT, also used by some metadata services
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking, often routed internally
+}
+
+// nat64Prefix embeds IPv4 addresses in IPv6 (RFC 6052).
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// Active reports whether the policy restricts anything. A nil policy does not.
+func (p *URLPolicy) Active() bool {
+	return p != nil && (len(p.AllowedDomains) > 0 || len(p.BlockedDomains) > 0 || p.BlockPrivateNetworks)
+}
+
+// Check tests rawURL against the policy without resolving its host.
+// Navigation says whether the URL is a page load, which AllowedDomains
+// limits, rather than a resource a page loads. URLs without a network host,
+// such as about:blank or data: URLs, are always allowed.
+func (p *URLPolicy) Check(rawURL string, navigation bool) error {
+	_, err := p.check(rawURL, navigation)
+	return err
+}
+
+// CheckResolved is Check, and with BlockPrivateNetworks also resolves the
+// host and refuses it if any of its addresses is private. A host that does
+// not resolve is allowed, since the browser cannot reach it either. The
+// browser resolves the name again itself, so this does not stop a DNS server
+// that answers differently the second time.
+func (p *URLPolicy) CheckResolved(ctx context.Context, rawURL string, navigation bool) error {
+	host, err := p.check(rawURL, navigation)
+	if err != nil || host == "" || !p.BlockPrivateNetworks {
+		return err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil // Already checked
+	}
+	lookup := p.LookupHost
+	if lookup == nil {
+		lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		}
+	}
+	addrs, err := lookup(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if PrivateAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s, a private network address", ErrURLBlocked, host, addr.Unmap())
+		}
+	}
+	return nil
+}
+
+// check applies the rules that need no lookup and returns the URL's host,
+// or "" if it has none to check.
+func (p *URLPolicy) check(rawURL string, navigation bool) (string, error) {
+	if !p.Active() {
+		return "", nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse URL %q: %w", rawURL, errorCause(err))
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ws", "wss", "ftp":
+	default:
+		return "", nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return "", nil
+	}
+
+	if MatchesDomain(host, p.BlockedDomains) {
+		return "", fmt.Errorf("%w: %s is a blocked domain", ErrURLBlocked, host)
+	}
+	if navigation && len(p.AllowedDomains) > 0 && !MatchesDomain(host, p.AllowedDomains) {
+		return "", fmt.Errorf("%w: %s is not an allowed domain", ErrURLBlocked, host)
+	}
+	if p.BlockPrivateNetworks {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			if PrivateAddr(addr) {
+				return "", fmt.Errorf("%w: %s is a private network address", ErrURLBlocked, host)
+			}
+		} else if host == "localhost" || strings.HasSuffix(host, ".localhost") || metadataHosts[host] {
+			return "", fmt.Errorf("%w: %s is a private network host", ErrURLBlocked, host)
+		}
+	}
+	return host, nil
+}
+
+// PrivateAddr reports whether addr is not on the public internet: loopback,
+// private, link-local, unspecified, or one of a few other reserved ranges.
+func PrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.Is6() && nat64Prefix.Contains(addr) {
+		b := addr.As16()
+		addr = netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]})
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, prefix := range privatePrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesDomain reports whether host, in lower case, is one of domains: an
+// exact host, or "*.example.com" for example.com and its subdomains.
+func MatchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if parent, ok := strings.CutPrefix(domain, "*."); ok {
+			if host == parent || strings.HasSuffix(host, "."+parent) {
+				return true
+			}
+		} else if host == domain {
+			return true
+		}
+	}
+	return false
+}
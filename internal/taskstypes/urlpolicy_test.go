@@ -0,0 +1,92 @@
+package taskstypes
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLPolicy_Check(t *testing.T) {
+	policy := &URLPolicy{
+		AllowedDomains:       []string{"*.example.com", "partner.test"},
+		BlockedDomains:       []string{"admin.example.com"},
+		BlockPrivateNetworks: true,
+	}
+
+	for _, raw := range []string{
+		"https://example.com/",
+		"https://www.example.com/a",
+		"https://partner.test:8443/",
+		"about:blank",
+		"data:text/html,hi",
+	} {
+		assert.NoError(t, policy.Check(raw, true), raw)
+	}
+
+	for _, raw := range []string{
+		"https://admin.example.com/",
+		"https://other.test/",
+		"https://sub.partner.test/",
+		"http://127.0.0.1:8080/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/",
+		"http://[::ffff:10.0.0.1]/",
+		"http://[64:ff9b::a9fe:a9fe]/",
+		"http://100.100.100.200/",
+		"http://localhost/",
+		"http://app.localhost/",
+		"http://metadata.google.internal/computeMetadata/v1/",
+	} {
+		err := policy.Check(raw, true)
+		assert.ErrorIs(t, err, ErrURLBlocked, raw)
+	}
+
+	// Resources a page loads are only held to the blocked domains and networks
+	assert.NoError(t, policy.Check("https://cdn.other.test/app.js", false))
+	assert.ErrorIs(t, policy.Check("https://admin.example.com/logo.png", false), ErrURLBlocked)
+	assert.ErrorIs(t, policy.Check("http://10.0.0.5/img.png", false), ErrURLBlocked)
+
+	var none *URLPolicy
+	assert.NoError(t, none.Check("http://127.0.0.1/", true))
+	assert.NoError(t, (&URLPolicy{}).Check("http://127.0.0.1/", true))
+}
+
+func TestURLPolicy_CheckResolved(t *testing.T) {
+	policy := &URLPolicy{
+		BlockPrivateNetworks: true,
+		LookupHost: func(_ context.Context, host string) ([]netip.Addr, error) {
+			switch host {
+			case "internal.test":
+				return []netip.Addr{netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.1.2.3")}, nil
+			case "public.test":
+				return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+			}
+			return nil, assert.AnError
+		},
+	}
+
+	err := policy.CheckResolved(context.Background(), "https://internal.test/", true)
+	require.ErrorIs(t, err, ErrURLBlocked)
+	assert.Contains(t, err.Error(), "10.1.2.3")
+	assert.NoError(t, policy.CheckResolved(context.Background(), "https://public.test/", true))
+	assert.NoError(t, policy.CheckResolved(context.Background(), "https://missing.test/", true))
+	assert.ErrorIs(t, policy.CheckResolved(context.Background(), "http://192.168.1.1/", false), ErrURLBlocked)
+}
+
+func TestPrivateAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":         false,
+		"2606:4700::1111": false,
+		"127.0.0.53":      true,
+		"0.0.0.0":         true,
+		"172.16.0.1":      true,
+		"fd00:ec2::254":   true,
+		"fe80::1":         true,
+		"100.64.0.1":      true,
+	} {
+		assert.Equal(t, want, PrivateAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
@@ -0,0 +1,101 @@
+package taskstypes
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DefaultURLSchemes are the schemes navigate actions may use unless
+// security.allowedURLSchemes says otherwise.
+var DefaultURLSchemes = []string{"http", "https"}
+
+// hostProfile converts internationalized host names to their ASCII
+// (punycode) form and rejects labels browsers would refuse.
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false), // Allow underscores, as browsers do
+)
+
+// NormalizeURL checks a URL a task navigates to and returns it in the form
+// the browser will load: the scheme and host lowercased, internationalized
+// hosts in punycode, and credentials removed. Removing credentials is
+// reported as a warning rather than an error, since the rest of the URL is
+// still usable.
+func NormalizeURL(raw string, schemes []string) (normalized string, warnings []string, err error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil, fmt.Errorf("URL is empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot parse URL %q: %w", raw, errorCause(err))
+	}
+	if _, err := strconv.Atoi(u.Opaque); u.Scheme == "" || (u.Opaque != "" && err == nil) {
+		// "example.com" parses as a path and "localhost:8080" as scheme "localhost"
+		return "", nil, fmt.Errorf("URL %q has no scheme; use e.g. https://%s", raw, strings.TrimPrefix(raw, "//"))
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !schemeAllowed(u.Scheme, schemes) {
+		return "", nil, fmt.Errorf("URL scheme %q is not allowed (allowed: %s)", u.Scheme, strings.Join(schemes, ", "))
+	}
+	if u.Opaque != "" {
+		// e.g. about:blank or data:; nothing more to check
+		return u.String(), nil, nil
+	}
+
+	if u.User != nil {
+		u.User = nil
+		warnings = append(warnings, "credentials were removed from the URL; use a login action or the task's credentials instead")
+	}
+	host := u.Hostname()
+	if host == "" && (u.Scheme == "http" || u.Scheme == "https") {
+		return "", warnings, fmt.Errorf("URL %q has no host", raw)
+	}
+	if host != "" && !strings.Contains(host, ":") { // IPv6 literals need no conversion
+		ascii, err := hostProfile.ToASCII(strings.TrimSuffix(host, "."))
+		if err != nil {
+			return "", warnings, fmt.Errorf("URL %q has an invalid host: %w", raw, err)
+		}
+		host = strings.ToLower(ascii)
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", warnings, fmt.Errorf("URL %q has an invalid port %q", raw, port)
+		}
+		host = joinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	return u.String(), warnings, nil
+}
+
+func schemeAllowed(scheme string, schemes []string) bool {
+	for _, allowed := range schemes {
+		if strings.EqualFold(scheme, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinHostPort(host, port string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]:" + port
+	}
+	return host + ":" + port
+}
+
+// errorCause strips url.Error's "parse <url>:" prefix, which repeats the URL.
+func errorCause(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+	return err
+}